@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	kh "github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
+)
+
+// runUUIDLabel is the label that the Kuberhealthy webhook receiver matches delivered alerts to runs by
+const runUUIDLabel = "kuberhealthy_run_uuid"
+
+// Alert is an alert in the format of the Alertmanager v2 API
+type Alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+// newProbeAlert builds the synthetic alert of a run.  It ends on its own at the supplied time so that it never stays
+// firing when the check is killed before resolving it.
+func newProbeAlert(name string, runUUID string, labels map[string]string, start time.Time, end time.Time) Alert {
+	alert := Alert{
+		Labels: map[string]string{"alertname": name},
+		Annotations: map[string]string{
+			"summary":     "Synthetic alert fired by kuberhealthy to verify that alerts are delivered",
+			"description": "This alert is routed to the kuberhealthy webhook receiver and resolves on its own.  It does not need any action.",
+		},
+		StartsAt: start,
+		EndsAt:   end,
+	}
+	for k, v := range labels {
+		alert.Labels[k] = v
+	}
+	alert.Labels[runUUIDLabel] = runUUID
+	return alert
+}
+
+// postAlert sends an alert to every supplied Alertmanager.  Alerts are sent to every member of a highly available
+// Alertmanager cluster, the same way Prometheus sends them.  How long each phase of every post took is passed to the
+// measured callback.  Succeeding with any one of them is enough, and the errors of the others are passed to the
+// postFailed callback.
+func postAlert(ctx context.Context, client *http.Client, alertmanagers []string, headers http.Header, alert Alert, measured func(alertmanager string, timing probe.Timing), postFailed func(error)) error {
+	body, err := json.Marshal([]Alert{alert})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, am := range alertmanagers {
+		timing, err := postAlertTo(ctx, client, am, headers, body)
+		measured(am, timing)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == len(alertmanagers) {
+		return errors.Join(errs...)
+	}
+	for _, err := range errs {
+		postFailed(err)
+	}
+	return nil
+}
+
+// postAlertTo sends alerts to a single Alertmanager and returns how long each phase of the post took
+func postAlertTo(ctx context.Context, client *http.Client, alertmanager string, headers http.Header, body []byte) (probe.Timing, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(alertmanager, "/")+"/api/v2/alerts", bytes.NewReader(body))
+	if err != nil {
+		return probe.Timing{}, err
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, b, timing, err := probe.HTTP(client, req)
+	if err != nil {
+		return timing, fmt.Errorf("failed to reach %s: %w", alertmanager, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		if len(b) > 1024 {
+			b = b[:1024]
+		}
+		return timing, fmt.Errorf("%s refused the alert with status %d: %s", alertmanager, resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	return timing, nil
+}
+
+// waitForDelivery asks Kuberhealthy for the delivery of the alert every interval until it arrives or the context
+// ends.  Other errors than the alert not being delivered yet are passed to the supplied callback and retried.
+func waitForDelivery(ctx context.Context, getDelivery func() (status.AlertDelivery, error), interval time.Duration, lookupFailed func(error)) (status.AlertDelivery, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		delivery, err := getDelivery()
+		if err == nil {
+			return delivery, nil
+		}
+		if !errors.Is(err, kh.ErrAlertNotDelivered) {
+			lastErr = err
+			lookupFailed(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return status.AlertDelivery{}, fmt.Errorf("alert was not delivered in time, and the last lookup failed: %w", lastErr)
+			}
+			return status.AlertDelivery{}, errors.New("alert was not delivered in time")
+		case <-ticker.C:
+		}
+	}
+}
+
+// parseLabels parses comma separated name=value labels
+func parseLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+		name, value, found := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !found || len(name) == 0 {
+			return nil, fmt.Errorf("label %q is not in the form name=value", pair)
+		}
+		if name == "alertname" || name == runUUIDLabel {
+			return nil, fmt.Errorf("label %s is set by the check", name)
+		}
+		labels[name] = strings.TrimSpace(value)
+	}
+	return labels, nil
+}
+
+// parseAlertmanagers parses comma separated Alertmanager URLs
+func parseAlertmanagers(s string) ([]string, error) {
+	var urls []string
+	for _, u := range strings.Split(s, ",") {
+		u = strings.TrimSpace(u)
+		if len(u) == 0 {
+			continue
+		}
+		if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+			return nil, fmt.Errorf("alertmanager URL %s must start with http:// or https://", u)
+		}
+		urls = append(urls, u)
+	}
+	if len(urls) == 0 {
+		return nil, errors.New("no alertmanager URLs were given")
+	}
+	return urls, nil
+}
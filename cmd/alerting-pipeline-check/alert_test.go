@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	kh "github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
+)
+
+func TestPostAlert(t *testing.T) {
+	var received []Alert
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v2/alerts" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer healthy.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+
+	start := time.Unix(1700000000, 0).UTC()
+	alert := newProbeAlert("KuberhealthyAlertingPipelineProbe", "run-1", map[string]string{"severity": "none"}, start, start.Add(time.Minute))
+	headers := http.Header{"Authorization": []string{"Bearer token"}}
+
+	var failures []error
+	timings := map[string]probe.Timing{}
+	measured := func(alertmanager string, timing probe.Timing) { timings[alertmanager] = timing }
+	err := postAlert(context.Background(), healthy.Client(), []string{broken.URL, healthy.URL + "/"}, headers, alert, measured, func(err error) { failures = append(failures, err) })
+	if err != nil {
+		t.Fatalf("expected the alert to be accepted by one alertmanager, got: %s", err)
+	}
+	if len(failures) != 1 || !strings.HasPrefix(failures[0].Error(), broken.URL+" refused the alert with status 503") {
+		t.Fatalf("expected the broken alertmanager to be passed to the callback, got: %v", failures)
+	}
+	if len(timings) != 2 || timings[broken.URL].Total <= 0 || timings[healthy.URL+"/"].Total <= 0 {
+		t.Fatalf("expected the post to every alertmanager to be timed, got: %v", timings)
+	}
+
+	expectedLabels := map[string]string{"alertname": "KuberhealthyAlertingPipelineProbe", "kuberhealthy_run_uuid": "run-1", "severity": "none"}
+	if len(received) != 1 || !reflect.DeepEqual(received[0].Labels, expectedLabels) {
+		t.Fatalf("expected one alert with labels %v, got: %+v", expectedLabels, received)
+	}
+	if !received[0].StartsAt.Equal(start) || !received[0].EndsAt.Equal(start.Add(time.Minute)) {
+		t.Fatalf("alert was sent with the wrong times: %+v", received[0])
+	}
+
+	err = postAlert(context.Background(), healthy.Client(), []string{broken.URL}, headers, alert, measured, func(error) {})
+	if err == nil {
+		t.Fatalf("expected an error when no alertmanager accepted the alert")
+	}
+}
+
+func TestWaitForDelivery(t *testing.T) {
+	received := time.Now()
+	var testCases = []struct {
+		name      string
+		responses []error // the errors of each lookup before the delivery is returned
+		never     bool    // the delivery never arrives
+		expected  string
+	}{
+		{name: "delivered right away"},
+		{name: "delivered after a few lookups", responses: []error{kh.ErrAlertNotDelivered, kh.ErrAlertNotDelivered}},
+		{name: "kuberhealthy briefly unreachable", responses: []error{errors.New("connection refused"), kh.ErrAlertNotDelivered}},
+		{name: "never delivered", never: true, expected: "alert was not delivered in time"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			lookups := 0
+			getDelivery := func() (status.AlertDelivery, error) {
+				lookups++
+				if tc.never {
+					return status.AlertDelivery{}, kh.ErrAlertNotDelivered
+				}
+				if lookups <= len(tc.responses) {
+					return status.AlertDelivery{}, tc.responses[lookups-1]
+				}
+				return status.AlertDelivery{Received: received, Status: "firing"}, nil
+			}
+
+			failures := 0
+			delivery, err := waitForDelivery(ctx, getDelivery, 10*time.Millisecond, func(error) { failures++ })
+			if len(tc.expected) > 0 {
+				if err == nil || err.Error() != tc.expected {
+					t.Fatalf("expected error %q, got: %v", tc.expected, err)
+				}
+				if failures != 0 {
+					t.Fatalf("expected an undelivered alert not to count as a failed lookup")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected the alert to be delivered, got: %s", err)
+			}
+			if !delivery.Received.Equal(received) {
+				t.Fatalf("expected the delivery received at %s, got %+v", received, delivery)
+			}
+		})
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	var testCases = []struct {
+		input    string
+		expected map[string]string
+		err      bool
+	}{
+		{input: "", expected: map[string]string{}},
+		{input: "severity=none, team = platform ,", expected: map[string]string{"severity": "none", "team": "platform"}},
+		{input: "severity", err: true},
+		{input: "alertname=Other", err: true},
+		{input: "kuberhealthy_run_uuid=abc", err: true},
+	}
+
+	for _, tc := range testCases {
+		labels, err := parseLabels(tc.input)
+		if tc.err {
+			if err == nil {
+				t.Fatalf("expected %q to be refused, got %v", tc.input, labels)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("failed to parse %q: %s", tc.input, err)
+		}
+		if !reflect.DeepEqual(labels, tc.expected) {
+			t.Fatalf("expected %q to parse to %v, got %v", tc.input, tc.expected, labels)
+		}
+	}
+}
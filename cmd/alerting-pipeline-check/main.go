@@ -0,0 +1,187 @@
+// Package alerting-pipeline-check implements a checker for Kuberhealthy that fires a synthetic alert through
+// Alertmanager and confirms that it is delivered to the Kuberhealthy webhook receiver, validating the alert path
+// that pages humans
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	kh "github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/nodeCheck"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
+)
+
+var (
+	// alertmanagerURLs are the comma separated base URLs of the Alertmanagers that the alert is sent to
+	alertmanagerURLs = os.Getenv("ALERTMANAGER_URLS")
+
+	// alertmanagerHeaders are extra headers sent to Alertmanager, one Name: value per line
+	alertmanagerHeaders = os.Getenv("ALERTMANAGER_HEADERS")
+
+	// alertName is the alertname label of the synthetic alert, which Alertmanager routes to the Kuberhealthy receiver
+	alertName = os.Getenv("ALERT_NAME")
+
+	// alertLabels are extra comma separated name=value labels of the synthetic alert
+	alertLabels = os.Getenv("ALERT_LABELS")
+
+	// pollInterval is how often Kuberhealthy is asked whether the alert was delivered
+	pollInterval = os.Getenv("POLL_INTERVAL")
+
+	// maxDeliveryLatency fails the check when the alert takes longer than this to be delivered
+	maxDeliveryLatency = os.Getenv("MAX_DELIVERY_LATENCY")
+)
+
+// reportMargin is the time left before the deadline of the run to resolve the alert and report the result
+const reportMargin = 10 * time.Second
+
+func init() {
+	// set debug mode for nodeCheck pkg
+	nodeCheck.EnableDebugOutput()
+
+	if len(alertName) == 0 {
+		alertName = "KuberhealthyAlertingPipelineProbe"
+	}
+	if len(pollInterval) == 0 {
+		pollInterval = "2s"
+	}
+}
+
+func main() {
+	// the alert has until shortly before the deadline of the run to be delivered
+	deadline, err := kh.GetDeadline()
+	if err != nil {
+		ReportFailureAndExit(fmt.Errorf("failed to read the deadline of the run: %w", err))
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline.Add(-reportMargin))
+	defer cancel()
+
+	// hits kuberhealthy endpoint to see if node is ready
+	err = nodeCheck.WaitForKuberhealthy(ctx)
+	if err != nil {
+		log.Errorln("Error waiting for kuberhealthy endpoint to be contactable by checker pod with error:" + err.Error())
+	}
+
+	alertmanagers, headers, labels, interval, maxLatency, err := parseConfig()
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+	runUUID := os.Getenv(external.KHRunUUID)
+	if len(runUUID) == 0 {
+		ReportFailureAndExit(fmt.Errorf("%s is not set, so the alert can not be matched to this run", external.KHRunUUID))
+	}
+
+	// the alert resolves on its own at the deadline of the run in case the check is killed before resolving it
+	client := &http.Client{Timeout: 30 * time.Second}
+	start := time.Now()
+	alert := newProbeAlert(alertName, runUUID, labels, start, deadline)
+	measured := func(alertmanager string, timing probe.Timing) {
+		log.Infoln("Posted alert to", alertmanager, "in", timing)
+	}
+	postFailed := func(err error) {
+		log.Warningln("Failed to send the alert to one of the alertmanagers:", err)
+	}
+	log.Infoln("Firing alert", alertName, "for run", runUUID, "through", alertmanagers)
+	err = postAlert(ctx, client, alertmanagers, headers, alert, measured, postFailed)
+	if err != nil {
+		ReportFailureAndExit(fmt.Errorf("failed to fire the alert: %w", err))
+	}
+	defer func() {
+		alert.EndsAt = time.Now()
+		err := postAlert(context.Background(), client, alertmanagers, headers, alert, measured, postFailed)
+		if err != nil {
+			log.Warningln("Failed to resolve the alert:", err)
+			return
+		}
+		log.Infoln("Resolved the alert")
+	}()
+
+	delivery, err := waitForDelivery(ctx, kh.GetAlertDelivery, interval, func(err error) {
+		log.Warningln("Failed to look up the delivery of the alert:", err)
+	})
+	if err != nil {
+		reportFailure(err)
+		return
+	}
+
+	// the receipt time is taken by Kuberhealthy, so small clock differences can make the latency slightly negative
+	latency := delivery.Received.Sub(start)
+	if latency < 0 {
+		latency = 0
+	}
+	log.Infoln("Alert was delivered by receiver", delivery.Receiver, "after", latency.Round(time.Millisecond))
+	if maxLatency > 0 && latency > maxLatency {
+		reportFailure(fmt.Errorf("alert delivery latency of %s is over the limit of %s", latency.Round(time.Millisecond), maxLatency))
+		return
+	}
+
+	err = kh.ReportSuccess()
+	if err != nil {
+		log.Errorln("Error reporting success to Kuberhealthy servers:", err)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported success to Kuberhealthy servers")
+}
+
+// parseConfig reads the alertmanagers, alert and timings of the check from the environment
+func parseConfig() ([]string, http.Header, map[string]string, time.Duration, time.Duration, error) {
+	alertmanagers, err := parseAlertmanagers(alertmanagerURLs)
+	if err != nil {
+		return nil, nil, nil, 0, 0, fmt.Errorf("invalid ALERTMANAGER_URLS: %w", err)
+	}
+	headers, err := probe.ParseHeaders(alertmanagerHeaders)
+	if err != nil {
+		return nil, nil, nil, 0, 0, fmt.Errorf("invalid ALERTMANAGER_HEADERS: %w", err)
+	}
+	labels, err := parseLabels(alertLabels)
+	if err != nil {
+		return nil, nil, nil, 0, 0, fmt.Errorf("invalid ALERT_LABELS: %w", err)
+	}
+	if strings.ContainsAny(alertName, " \t\n") {
+		return nil, nil, nil, 0, 0, fmt.Errorf("ALERT_NAME %q must not contain whitespace", alertName)
+	}
+
+	interval, err := time.ParseDuration(pollInterval)
+	if err != nil || interval <= 0 {
+		return nil, nil, nil, 0, 0, fmt.Errorf("POLL_INTERVAL %q must be a positive duration", pollInterval)
+	}
+	var maxLatency time.Duration
+	if len(maxDeliveryLatency) > 0 {
+		maxLatency, err = time.ParseDuration(maxDeliveryLatency)
+		if err != nil {
+			return nil, nil, nil, 0, 0, fmt.Errorf("failed to parse MAX_DELIVERY_LATENCY: %w", err)
+		}
+	}
+	return alertmanagers, headers, labels, interval, maxLatency, nil
+}
+
+// reportFailure reports an error to Kuberhealthy without exiting, so that the alert is still resolved
+func reportFailure(err error) {
+	log.Errorln(err)
+	err = kh.ReportFailure([]string{err.Error()})
+	if err != nil {
+		log.Errorln("Error reporting failure to Kuberhealthy servers:", err)
+		return
+	}
+	log.Infoln("Successfully reported failure to Kuberhealthy servers")
+}
+
+// ReportFailureAndExit reports an error to Kuberhealthy and exits the program
+func ReportFailureAndExit(err error) {
+	log.Errorln(err)
+	err2 := kh.ReportFailure([]string{err.Error()})
+	if err2 != nil {
+		log.Errorln("Error reporting failure to Kuberhealthy servers:", err2)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported failure to Kuberhealthy servers")
+	os.Exit(0)
+}
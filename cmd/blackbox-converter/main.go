@@ -0,0 +1,85 @@
+// blackbox-converter turns Prometheus blackbox-exporter module configs into equivalent Kuberhealthy khchecks.  Each
+// target passed with --target becomes one khcheck that probes the target the way its module does.
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/integrii/flaggy"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/blackbox"
+)
+
+func main() {
+	var configPath string
+	var outputPath string
+	var targetArgs []string
+	var options blackbox.Options
+	runInterval := blackbox.DefaultRunInterval
+
+	flaggy.SetDescription("Converts blackbox-exporter modules into Kuberhealthy khchecks.")
+	flaggy.String(&configPath, "c", "config", "path to the blackbox-exporter config file")
+	flaggy.StringSlice(&targetArgs, "t", "target", "a target to convert in the form module=target. May be repeated.")
+	flaggy.String(&outputPath, "o", "output", "(optional) file to write the khchecks to instead of stdout")
+	flaggy.String(&options.Namespace, "n", "namespace", "(optional) namespace of the khchecks. Defaults to kuberhealthy.")
+	flaggy.Duration(&runInterval, "i", "run-interval", "(optional) run interval of the khchecks")
+	flaggy.String(&options.HTTPCheckImage, "", "http-image", "(optional) image used for http modules")
+	flaggy.String(&options.TCPCheckImage, "", "tcp-image", "(optional) image used for tcp modules")
+	flaggy.String(&options.DNSCheckImage, "", "dns-image", "(optional) image used for dns modules")
+	flaggy.Parse()
+	options.RunInterval = runInterval
+
+	err := run(configPath, targetArgs, outputPath, options)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// run converts the targets of the blackbox-exporter config and writes the resulting khchecks out
+func run(configPath string, targetArgs []string, outputPath string, options blackbox.Options) error {
+	if len(configPath) == 0 {
+		return fmt.Errorf("a blackbox-exporter config file must be set with --config")
+	}
+	if len(targetArgs) == 0 {
+		return fmt.Errorf("at least one --target must be set")
+	}
+
+	b, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read blackbox-exporter config: %w", err)
+	}
+	config, err := blackbox.ParseConfig(b)
+	if err != nil {
+		return err
+	}
+
+	var targets []blackbox.Target
+	for _, arg := range targetArgs {
+		t, err := blackbox.ParseTarget(arg)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, t)
+	}
+
+	checks, warnings, err := blackbox.Convert(config, targets, options)
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, "Warning:", w)
+	}
+	if err != nil {
+		return err
+	}
+
+	out, err := blackbox.Marshal(checks)
+	if err != nil {
+		return err
+	}
+	if len(outputPath) == 0 {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	return ioutil.WriteFile(outputPath, out, 0644)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+// awsCalls are the read only describe calls that the cloud controller manager, the EBS CSI driver and the cluster
+// autoscaler depend on.  Each asks for as few results as the API allows so that the check adds little to the request
+// rate it measures.  Retries are turned off so that every throttled call is seen instead of retried away.
+func awsCalls(sess *session.Session) map[string]APICall {
+	cfg := aws.NewConfig().WithMaxRetries(0)
+	ec2Client := ec2.New(sess, cfg)
+	elbClient := elb.New(sess, cfg)
+	elbv2Client := elbv2.New(sess, cfg)
+	autoscalingClient := autoscaling.New(sess, cfg)
+
+	return map[string]APICall{
+		"ec2:DescribeInstances": func(ctx aws.Context) error {
+			_, err := ec2Client.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{MaxResults: aws.Int64(5)})
+			return err
+		},
+		"ec2:DescribeVolumes": func(ctx aws.Context) error {
+			_, err := ec2Client.DescribeVolumesWithContext(ctx, &ec2.DescribeVolumesInput{MaxResults: aws.Int64(5)})
+			return err
+		},
+		"ec2:DescribeSecurityGroups": func(ctx aws.Context) error {
+			_, err := ec2Client.DescribeSecurityGroupsWithContext(ctx, &ec2.DescribeSecurityGroupsInput{MaxResults: aws.Int64(5)})
+			return err
+		},
+		"ec2:DescribeSubnets": func(ctx aws.Context) error {
+			_, err := ec2Client.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{MaxResults: aws.Int64(5)})
+			return err
+		},
+		"elb:DescribeLoadBalancers": func(ctx aws.Context) error {
+			_, err := elbClient.DescribeLoadBalancersWithContext(ctx, &elb.DescribeLoadBalancersInput{PageSize: aws.Int64(1)})
+			return err
+		},
+		"elbv2:DescribeLoadBalancers": func(ctx aws.Context) error {
+			_, err := elbv2Client.DescribeLoadBalancersWithContext(ctx, &elbv2.DescribeLoadBalancersInput{PageSize: aws.Int64(1)})
+			return err
+		},
+		"autoscaling:DescribeAutoScalingGroups": func(ctx aws.Context) error {
+			_, err := autoscalingClient.DescribeAutoScalingGroupsWithContext(ctx, &autoscaling.DescribeAutoScalingGroupsInput{MaxRecords: aws.Int64(1)})
+			return err
+		},
+	}
+}
+
+// isAWSThrottle tells if an AWS API call was throttled, either by its error code or by a 429 status
+func isAWSThrottle(err error) bool {
+	if request.IsErrorThrottle(err) {
+		return true
+	}
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		return reqErr.StatusCode() == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// callNames lists the names of calls, sorted
+func callNames(calls map[string]APICall) string {
+	names := make([]string, 0, len(calls))
+	for name := range calls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
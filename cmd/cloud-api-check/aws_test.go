@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestIsAWSThrottle(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "ec2 request limit", err: awserr.New("RequestLimitExceeded", "Request limit exceeded.", nil), expected: true},
+		{name: "throttling", err: awserr.New("Throttling", "Rate exceeded", nil), expected: true},
+		{name: "too many requests status", err: awserr.NewRequestFailure(awserr.New("SlowDown", "slow down", nil), http.StatusTooManyRequests, "id"), expected: true},
+		{name: "unauthorized", err: awserr.NewRequestFailure(awserr.New("UnauthorizedOperation", "denied", nil), http.StatusForbidden, "id")},
+		{name: "other error", err: errors.New("connection refused")},
+	}
+
+	for _, tc := range testCases {
+		if isAWSThrottle(tc.err) != tc.expected {
+			t.Fatalf("%s: expected throttled to be %t", tc.name, tc.expected)
+		}
+	}
+}
+
+func TestAWSCallsAreNotRetried(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "text/xml")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`<Response><Errors><Error><Code>RequestLimitExceeded</Code><Message>Request limit exceeded.</Message></Error></Errors><RequestID>1</RequestID></Response>`))
+	}))
+	defer server.Close()
+
+	sess := session.Must(session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithEndpoint(server.URL).
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", ""))))
+	call := awsCalls(sess)["ec2:DescribeInstances"]
+
+	err := call(context.Background())
+	if !isAWSThrottle(err) {
+		t.Fatalf("expected the call to be throttled, got: %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("expected the throttled call to be made once, got %d requests", requests)
+	}
+}
+
+func TestSelectCalls(t *testing.T) {
+	known := map[string]APICall{
+		"ec2:DescribeInstances": func(ctx context.Context) error { return nil },
+		"ec2:DescribeVolumes":   func(ctx context.Context) error { return nil },
+	}
+
+	calls, err := selectCalls(known, "")
+	if err != nil || len(calls) != 2 {
+		t.Fatalf("expected every known call when none are named, got %d calls and error %v", len(calls), err)
+	}
+	calls, err = selectCalls(known, " ec2:DescribeVolumes ,")
+	if err != nil || len(calls) != 1 || calls["ec2:DescribeVolumes"] == nil {
+		t.Fatalf("expected only the named call, got %d calls and error %v", len(calls), err)
+	}
+	_, err = selectCalls(known, "ec2:DescribeImages")
+	if err == nil {
+		t.Fatalf("expected an unknown call to be refused")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// APICall makes a single call to a cloud provider API
+type APICall func(ctx context.Context) error
+
+// CallStats are the outcomes of the calls made to one API
+type CallStats struct {
+	Name       string
+	Calls      int
+	Throttled  int
+	Failed     int           // calls that failed for other reasons than throttling
+	LastError  error         // the last error other than throttling, if any
+	MaxLatency time.Duration // the slowest call
+}
+
+// ThrottleRate is the fraction of the calls that were throttled
+func (s CallStats) ThrottleRate() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Throttled) / float64(s.Calls)
+}
+
+// runCalls makes every call the supplied number of times, waiting the supplied interval between rounds.  The calls
+// are made one at a time so that the check itself does not cause throttling.  Stats are returned sorted by name.
+func runCalls(ctx context.Context, calls map[string]APICall, rounds int, interval time.Duration, isThrottle func(error) bool) []CallStats {
+	names := make([]string, 0, len(calls))
+	for name := range calls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	stats := make([]CallStats, len(names))
+	for i, name := range names {
+		stats[i].Name = name
+	}
+
+	for round := 0; round < rounds; round++ {
+		if round > 0 {
+			select {
+			case <-ctx.Done():
+				return stats
+			case <-time.After(interval):
+			}
+		}
+		for i, name := range names {
+			if ctx.Err() != nil {
+				return stats
+			}
+			start := time.Now()
+			err := calls[name](ctx)
+			latency := time.Since(start)
+
+			stats[i].Calls++
+			if latency > stats[i].MaxLatency {
+				stats[i].MaxLatency = latency
+			}
+			switch {
+			case err == nil:
+			case isThrottle(err):
+				stats[i].Throttled++
+			default:
+				stats[i].Failed++
+				stats[i].LastError = err
+			}
+		}
+	}
+	return stats
+}
+
+// Thresholds are what the calls must meet for the check to pass
+type Thresholds struct {
+	MaxThrottleRate float64       // the largest fraction of calls to any API that may be throttled
+	MaxLatency      time.Duration // how long a call may take.  Unlimited when zero.
+}
+
+// callProblems lists the APIs whose calls fell short of the thresholds
+func callProblems(stats []CallStats, th Thresholds) []string {
+	var problems []string
+	for _, s := range stats {
+		if s.Throttled > 0 && s.ThrottleRate() > th.MaxThrottleRate {
+			problems = append(problems, fmt.Sprintf("%s was throttled on %d of %d calls, which is over the limit of %s", s.Name, s.Throttled, s.Calls, formatRate(th.MaxThrottleRate)))
+		}
+		if s.Failed > 0 {
+			problems = append(problems, fmt.Sprintf("%s failed on %d of %d calls: %s", s.Name, s.Failed, s.Calls, s.LastError))
+		}
+		if th.MaxLatency > 0 && s.MaxLatency > th.MaxLatency {
+			problems = append(problems, fmt.Sprintf("%s took %s to answer, which is over the limit of %s", s.Name, s.MaxLatency.Round(time.Millisecond), th.MaxLatency))
+		}
+	}
+	return problems
+}
+
+// callMetadata holds the throttling rate of all calls and the counts and slowest call of each API, which are reported
+// to Kuberhealthy with the result of the check
+func callMetadata(stats []CallStats) map[string]string {
+	metadata := make(map[string]string)
+	var calls, throttled int
+	for _, s := range stats {
+		calls += s.Calls
+		throttled += s.Throttled
+		metadata[s.Name+".throttled"] = strconv.Itoa(s.Throttled)
+		metadata[s.Name+".failed"] = strconv.Itoa(s.Failed)
+		metadata[s.Name+".maxLatency"] = s.MaxLatency.Round(time.Millisecond).String()
+	}
+	metadata["calls"] = strconv.Itoa(calls)
+	metadata["throttled"] = strconv.Itoa(throttled)
+	rate := 0.0
+	if calls > 0 {
+		rate = float64(throttled) / float64(calls)
+	}
+	metadata["throttleRate"] = formatRate(rate)
+	return metadata
+}
+
+// formatRate formats a fraction as a percentage
+func formatRate(rate float64) string {
+	return fmt.Sprintf("%.1f%%", rate*100)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var errThrottled = errors.New("throttled")
+
+func TestRunCalls(t *testing.T) {
+	made := 0
+	calls := map[string]APICall{
+		"ok": func(ctx context.Context) error { return nil },
+		"throttled": func(ctx context.Context) error {
+			made++
+			if made%2 == 0 {
+				return errThrottled
+			}
+			return nil
+		},
+		"denied": func(ctx context.Context) error { return errors.New("access denied") },
+	}
+	isThrottle := func(err error) bool { return errors.Is(err, errThrottled) }
+
+	stats := runCalls(context.Background(), calls, 4, time.Millisecond, isThrottle)
+	if len(stats) != 3 || stats[0].Name != "denied" || stats[1].Name != "ok" || stats[2].Name != "throttled" {
+		t.Fatalf("expected stats for every call sorted by name, got %+v", stats)
+	}
+	for _, s := range stats {
+		if s.Calls != 4 {
+			t.Fatalf("expected %s to be called 4 times, got %d", s.Name, s.Calls)
+		}
+	}
+	if stats[0].Failed != 4 || stats[0].Throttled != 0 || stats[0].LastError == nil {
+		t.Fatalf("expected every denied call to fail, got %+v", stats[0])
+	}
+	if stats[2].Throttled != 2 || stats[2].Failed != 0 || stats[2].ThrottleRate() != 0.5 {
+		t.Fatalf("expected half of the calls to be throttled, got %+v", stats[2])
+	}
+
+	// no more calls are made once the context ends
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stats = runCalls(ctx, calls, 4, time.Millisecond, isThrottle)
+	if stats[0].Calls != 0 {
+		t.Fatalf("expected no calls after the context ended, got %+v", stats)
+	}
+}
+
+func TestCallProblems(t *testing.T) {
+	stats := []CallStats{
+		{Name: "ec2:DescribeInstances", Calls: 10, Throttled: 1, MaxLatency: 200 * time.Millisecond},
+		{Name: "ec2:DescribeVolumes", Calls: 10, Throttled: 3, MaxLatency: 3 * time.Second},
+		{Name: "elb:DescribeLoadBalancers", Calls: 10, Failed: 10, LastError: errors.New("UnauthorizedOperation"), MaxLatency: 100 * time.Millisecond},
+	}
+
+	problems := callProblems(stats, Thresholds{MaxThrottleRate: 0.2, MaxLatency: 2 * time.Second})
+	expected := []string{
+		"ec2:DescribeVolumes was throttled on 3 of 10 calls, which is over the limit of 20.0%",
+		"ec2:DescribeVolumes took 3s to answer, which is over the limit of 2s",
+		"elb:DescribeLoadBalancers failed on 10 of 10 calls: UnauthorizedOperation",
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Fatalf("expected problems %q, got %q", expected, problems)
+	}
+
+	// with no throttling allowed, a single throttled call fails the check
+	problems = callProblems(stats[:1], Thresholds{})
+	if len(problems) != 1 {
+		t.Fatalf("expected a single throttled call to be a problem, got %q", problems)
+	}
+}
+
+func TestCallMetadata(t *testing.T) {
+	stats := []CallStats{
+		{Name: "ec2:DescribeInstances", Calls: 3, Throttled: 1, MaxLatency: 120 * time.Millisecond},
+		{Name: "ec2:DescribeVolumes", Calls: 3, Failed: 1, MaxLatency: 80 * time.Millisecond},
+	}
+	expected := map[string]string{
+		"calls":                            "6",
+		"throttled":                        "1",
+		"throttleRate":                     "16.7%",
+		"ec2:DescribeInstances.throttled":  "1",
+		"ec2:DescribeInstances.failed":     "0",
+		"ec2:DescribeInstances.maxLatency": "120ms",
+		"ec2:DescribeVolumes.throttled":    "0",
+		"ec2:DescribeVolumes.failed":       "1",
+		"ec2:DescribeVolumes.maxLatency":   "80ms",
+	}
+	metadata := callMetadata(stats)
+	if !reflect.DeepEqual(metadata, expected) {
+		t.Fatalf("expected metadata %v, got %v", expected, metadata)
+	}
+}
@@ -0,0 +1,203 @@
+// Package cloud-api-check implements a checker for Kuberhealthy that probes the cloud provider API calls that the
+// cloud controller manager, CSI drivers and the cluster autoscaler depend on and reports how often they are
+// throttled, which often comes before provisioning outages
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	log "github.com/sirupsen/logrus"
+
+	kh "github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/nodeCheck"
+)
+
+var (
+	// provider is the cloud provider whose API is probed
+	provider = os.Getenv("PROVIDER")
+
+	// awsRegion is the region of the AWS API.  It is read from the instance metadata when empty.
+	awsRegion = os.Getenv("AWS_REGION")
+
+	// apiCalls are the comma separated API calls to probe.  All known calls of the provider are probed when empty.
+	apiCalls = os.Getenv("API_CALLS")
+
+	// rounds is how many times each API call is made per run
+	rounds = os.Getenv("ROUNDS")
+
+	// roundInterval is how long to wait between rounds of calls
+	roundInterval = os.Getenv("ROUND_INTERVAL")
+
+	// maxThrottlePercent fails the check when more than this percentage of the calls to any API are throttled
+	maxThrottlePercent = os.Getenv("MAX_THROTTLE_PERCENT")
+
+	// maxLatency fails the check when an API call takes longer than this to answer
+	maxLatency = os.Getenv("MAX_LATENCY")
+)
+
+func init() {
+	// set debug mode for nodeCheck pkg
+	nodeCheck.EnableDebugOutput()
+
+	if len(provider) == 0 {
+		provider = "aws"
+	}
+}
+
+func main() {
+	deadline, err := kh.GetDeadline()
+	if err != nil {
+		log.Warningln("Failed to read the deadline of the run, allowing five minutes:", err)
+		deadline = time.Now().Add(5 * time.Minute)
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline.Add(-5*time.Second))
+	defer cancel()
+
+	// hits kuberhealthy endpoint to see if node is ready
+	err = nodeCheck.WaitForKuberhealthy(ctx)
+	if err != nil {
+		log.Errorln("Error waiting for kuberhealthy endpoint to be contactable by checker pod with error:" + err.Error())
+	}
+
+	n, interval, th, err := parseConfig()
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+
+	var calls map[string]APICall
+	var isThrottle func(error) bool
+	switch provider {
+	case "aws":
+		sess, err := awsSession(ctx)
+		if err != nil {
+			ReportFailureAndExit(err)
+		}
+		calls, isThrottle = awsCalls(sess), isAWSThrottle
+	default:
+		ReportFailureAndExit(fmt.Errorf("PROVIDER %q is not supported. The supported providers are: aws", provider))
+	}
+	calls, err = selectCalls(calls, apiCalls)
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+
+	log.Infoln("Making", n, "rounds of calls to", callNames(calls))
+	stats := runCalls(ctx, calls, n, interval, isThrottle)
+	for _, s := range stats {
+		log.Infoln(s.Name+":", s.Calls, "calls,", s.Throttled, "throttled,", s.Failed, "failed, slowest took", s.MaxLatency.Round(time.Millisecond))
+	}
+	metadata := callMetadata(stats)
+
+	problems := callProblems(stats, th)
+	if len(problems) > 0 {
+		log.Errorln("Cloud provider API calls are not healthy:", problems)
+		err = kh.ReportFailureWithMetadata(problems, metadata)
+		if err != nil {
+			log.Errorln("Error reporting failure to Kuberhealthy servers:", err)
+			os.Exit(1)
+		}
+		log.Infoln("Successfully reported failure to Kuberhealthy servers")
+		return
+	}
+
+	err = kh.ReportSuccessWithMetadata(metadata)
+	if err != nil {
+		log.Errorln("Error reporting success to Kuberhealthy servers:", err)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported success to Kuberhealthy servers")
+}
+
+// awsSession creates an AWS session with the credentials of the pod, in the region of the node when no region is
+// configured
+func awsSession(ctx context.Context) (*session.Session, error) {
+	sess, err := session.NewSession(aws.NewConfig().WithCredentialsChainVerboseErrors(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+	region := awsRegion
+	if len(region) == 0 {
+		region, err = ec2metadata.New(sess).RegionWithContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("AWS_REGION is not set and the region could not be read from the instance metadata: %w", err)
+		}
+	}
+	return sess.Copy(aws.NewConfig().WithRegion(region)), nil
+}
+
+// selectCalls picks the comma separated calls out of the known calls.  All known calls are picked when none are
+// named.
+func selectCalls(known map[string]APICall, names string) (map[string]APICall, error) {
+	if len(strings.TrimSpace(names)) == 0 {
+		return known, nil
+	}
+	selected := make(map[string]APICall)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			continue
+		}
+		call, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("API_CALLS names unknown call %s. The known calls are: %s", name, callNames(known))
+		}
+		selected[name] = call
+	}
+	return selected, nil
+}
+
+// parseConfig reads the rounds, interval and thresholds of the check from the environment
+func parseConfig() (int, time.Duration, Thresholds, error) {
+	n := 3
+	interval := 5 * time.Second
+	th := Thresholds{MaxThrottleRate: 0.2}
+
+	var err error
+	if len(rounds) > 0 {
+		n, err = strconv.Atoi(rounds)
+		if err != nil || n < 1 {
+			return 0, 0, Thresholds{}, fmt.Errorf("ROUNDS %q must be a positive number", rounds)
+		}
+	}
+	if len(roundInterval) > 0 {
+		interval, err = time.ParseDuration(roundInterval)
+		if err != nil {
+			return 0, 0, Thresholds{}, fmt.Errorf("failed to parse ROUND_INTERVAL: %w", err)
+		}
+	}
+	if len(maxThrottlePercent) > 0 {
+		percent, err := strconv.ParseFloat(maxThrottlePercent, 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return 0, 0, Thresholds{}, fmt.Errorf("MAX_THROTTLE_PERCENT %q must be a number from 0 to 100", maxThrottlePercent)
+		}
+		th.MaxThrottleRate = percent / 100
+	}
+	if len(maxLatency) > 0 {
+		th.MaxLatency, err = time.ParseDuration(maxLatency)
+		if err != nil {
+			return 0, 0, Thresholds{}, fmt.Errorf("failed to parse MAX_LATENCY: %w", err)
+		}
+	}
+	return n, interval, th, nil
+}
+
+// ReportFailureAndExit reports an error to Kuberhealthy and exits the program
+func ReportFailureAndExit(err error) {
+	log.Errorln(err)
+	err2 := kh.ReportFailure([]string{err.Error()})
+	if err2 != nil {
+		log.Errorln("Error reporting failure to Kuberhealthy servers:", err2)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported failure to Kuberhealthy servers")
+	os.Exit(0)
+}
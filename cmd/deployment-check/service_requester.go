@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -39,6 +40,11 @@ func makeRequestToDeploymentCheckService(ctx context.Context, hostname string) c
 			return
 		}
 
+		// IPv6 addresses must be wrapped in brackets to be used in a URL.
+		if ip := net.ParseIP(hostname); ip != nil && ip.To4() == nil {
+			hostname = "[" + hostname + "]"
+		}
+
 		// Prepend the hostname with a HTTP protocol.
 		if !strings.HasPrefix(hostname, "http://") {
 			hostname = "http://" + hostname
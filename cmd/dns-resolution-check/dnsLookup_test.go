@@ -7,6 +7,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
 )
 
 func TestDnsLookup(t *testing.T) {
@@ -26,7 +28,7 @@ func TestDnsLookup(t *testing.T) {
 	for arg, expectedValue := range testCase {
 		host := arg
 
-		err := dnsLookup(r, host)
+		err := dnsLookup(r, host, probe.Budget{})
 		switch err {
 		case nil:
 			if host != "google.com" {
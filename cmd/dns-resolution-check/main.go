@@ -8,6 +8,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -21,6 +22,7 @@ import (
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/nodeCheck"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/kubeClient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
 )
 
 const maxTimeInFailure = 60 * time.Second
@@ -204,16 +206,22 @@ func getIpsFromEndpoint(endpoints *v1.EndpointsList) ([]string, error) {
 	return ipList, errors.New("No Ip's found in endpoints list")
 }
 
-func dnsLookup(r *net.Resolver, host string) error {
-	_, err := r.LookupHost(context.Background(), host)
+// dnsLookup resolves a host with the supplied resolver, or the default resolver when it is nil.  Lookups that go
+// over the latency budget count as down.
+func dnsLookup(r *net.Resolver, host string, budget probe.Budget) error {
+	_, timing, err := probe.Lookup(context.Background(), r, host)
 	if err != nil {
 		errorMessage := "DNS Status check determined that " + host + " is DOWN: " + err.Error()
 		return errors.New(errorMessage)
 	}
+	log.Infoln("Resolved", host, "in", timing)
+	if overBudget := budget.Check(timing); len(overBudget) > 0 {
+		return errors.New("DNS Status check determined that " + host + " is over the latency budget: " + strings.Join(overBudget, ", "))
+	}
 	return nil
 }
 
-func (dc *Checker) checkEndpoints() error {
+func (dc *Checker) checkEndpoints(budget probe.Budget) error {
 	endpoints, err := dc.client.CoreV1().Endpoints(namespace).List(context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
 	if err != nil {
 		message := "DNS status check unable to get dns endpoints from cluster: " + err.Error()
@@ -236,7 +244,7 @@ func (dc *Checker) checkEndpoints() error {
 				return err
 			}
 			//run a lookup for each ip if we successfully created a resolver, return error
-			err = dnsLookup(r, dc.Hostname)
+			err = dnsLookup(r, dc.Hostname, budget)
 			if err != nil {
 				return err
 			}
@@ -252,9 +260,14 @@ func (dc *Checker) doChecks() error {
 
 	log.Infoln("DNS Status check testing hostname:", dc.Hostname)
 
+	budget, err := probe.BudgetFromEnv()
+	if err != nil {
+		return err
+	}
+
 	// if there's a label selector, do checks against endpoints
 	if len(labelSelector) > 0 {
-		err := dc.checkEndpoints()
+		err := dc.checkEndpoints(budget)
 		if err != nil {
 			return err
 		}
@@ -262,11 +275,10 @@ func (dc *Checker) doChecks() error {
 	}
 
 	// otherwise do lookup against service endpoint
-	_, err := net.LookupHost(dc.Hostname)
+	err = dnsLookup(nil, dc.Hostname, budget)
 	if err != nil {
-		errorMessage := "DNS Status check determined that " + dc.Hostname + " is DOWN: " + err.Error()
-		log.Errorln(errorMessage)
-		return errors.New(errorMessage)
+		log.Errorln(err.Error())
+		return err
 	}
 	log.Infoln("DNS Status check from service endpoint determined that", dc.Hostname, "was OK.")
 	return nil
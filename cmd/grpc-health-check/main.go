@@ -0,0 +1,137 @@
+// Package grpc-health-check implements a checker for Kuberhealthy that probes services with the standard
+// grpc.health.v1 health checking protocol
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	kh "github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/nodeCheck"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
+)
+
+var (
+	// targets are the comma separated host:port addresses that are probed
+	targets = os.Getenv("TARGETS")
+
+	// service is the service whose health is asked for.  The health of the whole server is asked for when empty.
+	service = os.Getenv("SERVICE")
+
+	// authority overrides the :authority header sent to targets, such as when they are reached through a proxy
+	authority = os.Getenv("AUTHORITY")
+
+	// timeout is the time limit of the probe of each target
+	timeout = os.Getenv("TIMEOUT")
+
+	// TLS settings of the connections to targets
+	useTLS             = os.Getenv("TLS")
+	caFile             = os.Getenv("CA_FILE")
+	certFile           = os.Getenv("CERT_FILE")
+	keyFile            = os.Getenv("KEY_FILE")
+	serverName         = os.Getenv("SERVER_NAME")
+	insecureSkipVerify = os.Getenv("INSECURE_SKIP_VERIFY")
+)
+
+func init() {
+	// set debug mode for nodeCheck pkg
+	nodeCheck.EnableDebugOutput()
+
+	if len(timeout) == 0 {
+		timeout = "10s"
+	}
+}
+
+func main() {
+	// create context
+	checkTimeLimit := time.Minute * 1
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeLimit)
+	defer cancel()
+
+	// hits kuberhealthy endpoint to see if node is ready
+	err := nodeCheck.WaitForKuberhealthy(ctx)
+	if err != nil {
+		log.Errorln("Error waiting for kuberhealthy endpoint to be contactable by checker pod with error:" + err.Error())
+	}
+
+	targetList, err := parseTargets(targets)
+	if err != nil {
+		ReportFailureAndExit(fmt.Errorf("invalid TARGETS: %w", err))
+	}
+	timeoutDuration, err := time.ParseDuration(timeout)
+	if err != nil {
+		ReportFailureAndExit(fmt.Errorf("failed to parse TIMEOUT: %w", err))
+	}
+
+	tlsOptions := TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: serverName}
+	tlsOptions.Enabled, err = parseBoolEnv("TLS", useTLS)
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+	tlsOptions.InsecureSkipVerify, err = parseBoolEnv("INSECURE_SKIP_VERIFY", insecureSkipVerify)
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+	creds, err := transportCredentials(tlsOptions)
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+	budget, err := probe.BudgetFromEnv()
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+	prober := Prober{Service: service, Timeout: timeoutDuration, Credentials: creds, Authority: authority, Budget: budget}
+
+	log.Infoln("Checking the health of service", strconv.Quote(service), "on", len(targetList), "targets:", targetList)
+	errs := prober.checkTargets(context.Background(), targetList, func(target string, timing probe.Timing) {
+		log.Infoln("Checked", target, "in", timing)
+	})
+	if len(errs) > 0 {
+		log.Errorln("Targets are not serving:", errs)
+		err = kh.ReportFailure(errs)
+		if err != nil {
+			log.Errorln("Error reporting failure to Kuberhealthy servers:", err)
+			os.Exit(1)
+		}
+		log.Infoln("Successfully reported failure to Kuberhealthy servers")
+		return
+	}
+
+	log.Infoln("Every target is serving")
+	err = kh.ReportSuccess()
+	if err != nil {
+		log.Errorln("Error reporting success to Kuberhealthy servers:", err)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported success to Kuberhealthy servers")
+}
+
+// parseBoolEnv parses a boolean environment variable, which is false when empty
+func parseBoolEnv(name string, value string) (bool, error) {
+	if len(value) == 0 {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return b, nil
+}
+
+// ReportFailureAndExit reports an error to Kuberhealthy and exits the program
+func ReportFailureAndExit(err error) {
+	log.Errorln(err)
+	err2 := kh.ReportFailure([]string{err.Error()})
+	if err2 != nil {
+		log.Errorln("Error reporting failure to Kuberhealthy servers:", err2)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported failure to Kuberhealthy servers")
+	os.Exit(0)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
+)
+
+// TLSOptions configures how the prober secures its connections to targets
+type TLSOptions struct {
+	Enabled            bool   // connect with TLS instead of plaintext
+	CAFile             string // the CA bundle that verifies the certificates of targets, the system pool when empty
+	CertFile           string // the client certificate presented to targets for mTLS
+	KeyFile            string // the key of the client certificate
+	ServerName         string // the name the certificates of targets are verified against, the target host when empty
+	InsecureSkipVerify bool   // skips verifying the certificates of targets
+}
+
+// parseTargets splits a comma separated list of host:port targets
+func parseTargets(s string) ([]string, error) {
+	var targets []string
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if len(t) == 0 {
+			continue
+		}
+		if !strings.Contains(t, ":") {
+			return nil, fmt.Errorf("target %s has no port", t)
+		}
+		targets = append(targets, t)
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("no targets configured")
+	}
+	return targets, nil
+}
+
+// transportCredentials builds the credentials targets are dialed with.  Plaintext is used unless TLS is enabled, and
+// a client certificate is only presented when both its certificate and key are set.
+func transportCredentials(o TLSOptions) (credentials.TransportCredentials, error) {
+	if !o.Enabled {
+		return insecure.NewCredentials(), nil
+	}
+
+	config := &tls.Config{
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if len(o.CAFile) > 0 {
+		b, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", o.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if len(o.CertFile) > 0 != (len(o.KeyFile) > 0) {
+		return nil, errors.New("both a client certificate and key are needed for mTLS")
+	}
+	if len(o.CertFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(config), nil
+}
+
+// Prober asks targets for the health of a service
+type Prober struct {
+	Service     string                           // the service asked for, the whole server when empty
+	Timeout     time.Duration                    // the time limit of the probe of each target
+	Credentials credentials.TransportCredentials // secures the connections to targets
+	Authority   string                           // overrides the :authority header sent to targets, when set
+	Budget      probe.Budget                     // the latency budget of each phase of a probe
+}
+
+// checkTarget asks a target for the health of the service with the grpc.health.v1 protocol and returns how long
+// each phase took.  The TLS phase covers everything after the TCP connection is up until the gRPC connection is
+// ready, and the time to first byte is the health check call.  Targets that do not report SERVING fail.
+func (p Prober) checkTarget(ctx context.Context, target string) (probe.Timing, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	// the dialer records how long resolving and connecting took
+	var mu sync.Mutex
+	var timing probe.Timing
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		conn, t, err := probe.Dial(ctx, "tcp", addr, nil)
+		mu.Lock()
+		timing.DNS, timing.Connect = t.DNS, t.Connect
+		mu.Unlock()
+		return conn, err
+	}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(p.Credentials),
+		grpc.WithContextDialer(dialer),
+		grpc.WithBlock(),
+		grpc.WithReturnConnectionError(),
+	}
+	if len(p.Authority) > 0 {
+		opts = append(opts, grpc.WithAuthority(p.Authority))
+	}
+
+	start := time.Now()
+	conn, err := grpc.DialContext(ctx, target, opts...)
+	connected := time.Since(start)
+	mu.Lock()
+	defer mu.Unlock()
+	if err != nil {
+		timing.Total = connected
+		return timing, fmt.Errorf("failed to dial: %w", err)
+	}
+	defer conn.Close()
+	if p.Credentials.Info().SecurityProtocol != "insecure" {
+		timing.TLS = connected - timing.DNS - timing.Connect
+	}
+
+	callStart := time.Now()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: p.Service})
+	timing.TTFB = time.Since(callStart)
+	timing.Total = time.Since(start)
+	if err != nil {
+		return timing, fmt.Errorf("health check failed: %w", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return timing, fmt.Errorf("service is %s", resp.GetStatus())
+	}
+	if overBudget := p.Budget.Check(timing); len(overBudget) > 0 {
+		return timing, fmt.Errorf("health check was over the latency budget: %s", strings.Join(overBudget, ", "))
+	}
+	return timing, nil
+}
+
+// checkTargets checks every target at once and returns the errors of the ones that are not serving, in the order
+// of the targets.  The timing of each target that connected is passed to the supplied function.
+func (p Prober) checkTargets(ctx context.Context, targets []string, measured func(target string, timing probe.Timing)) []string {
+	errs := make([]error, len(targets))
+	timings := make([]probe.Timing, len(targets))
+	wg := sync.WaitGroup{}
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			timings[i], errs[i] = p.checkTarget(ctx, target)
+		}(i, target)
+	}
+	wg.Wait()
+
+	var failures []string
+	for i, err := range errs {
+		if timings[i].Connect > 0 {
+			measured(targets[i], timings[i])
+		}
+		if err != nil {
+			failures = append(failures, targets[i]+": "+err.Error())
+		}
+	}
+	return failures
+}
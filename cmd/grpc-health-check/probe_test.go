@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
+)
+
+// startHealthServer serves the grpc.health.v1 protocol on a local port with the supplied service statuses
+func startHealthServer(t *testing.T, statuses map[string]healthpb.HealthCheckResponse_ServingStatus) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	healthServer := health.NewServer()
+	for service, status := range statuses {
+		healthServer.SetServingStatus(service, status)
+	}
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+// TestCheckTargets ensures only targets serving the requested service pass
+func TestCheckTargets(t *testing.T) {
+	serving := startHealthServer(t, map[string]healthpb.HealthCheckResponse_ServingStatus{"orders": healthpb.HealthCheckResponse_SERVING})
+	notServing := startHealthServer(t, map[string]healthpb.HealthCheckResponse_ServingStatus{"orders": healthpb.HealthCheckResponse_NOT_SERVING})
+
+	var testCases = []struct {
+		description string
+		targets     []string
+		service     string
+		budget      probe.Budget
+		expected    []string // the start of each expected error
+	}{
+		{description: "serving service", targets: []string{serving}, service: "orders"},
+		{description: "whole server", targets: []string{serving, notServing}, service: ""},
+		{description: "service not serving", targets: []string{serving, notServing}, service: "orders", expected: []string{notServing + ": service is NOT_SERVING"}},
+		{description: "over the latency budget", targets: []string{serving}, service: "orders", budget: probe.Budget{Total: time.Nanosecond}, expected: []string{serving + ": health check was over the latency budget: total took"}},
+		{description: "unknown service", targets: []string{serving}, service: "payments", expected: []string{serving + ": health check failed: rpc error: code = NotFound"}},
+	}
+
+	for _, tc := range testCases {
+		prober := Prober{Service: tc.service, Timeout: time.Second * 5, Credentials: insecure.NewCredentials(), Budget: tc.budget}
+		measured := 0
+		errs := prober.checkTargets(context.Background(), tc.targets, func(string, probe.Timing) { measured++ })
+		if measured != len(tc.targets) {
+			t.Fatalf("%s: %d of %d targets were timed", tc.description, measured, len(tc.targets))
+		}
+		if len(errs) != len(tc.expected) {
+			t.Fatalf("%s: got errors %v but expected %v", tc.description, errs, tc.expected)
+		}
+		for i := range errs {
+			if !strings.HasPrefix(errs[i], tc.expected[i]) {
+				t.Fatalf("%s: got error %q but expected it to start with %q", tc.description, errs[i], tc.expected[i])
+			}
+		}
+	}
+}
+
+// TestParseTargets ensures targets are split and need a port
+func TestParseTargets(t *testing.T) {
+	targets, err := parseTargets(" orders:50051, payments.default.svc:443 ,")
+	if err != nil || len(targets) != 2 || targets[0] != "orders:50051" || targets[1] != "payments.default.svc:443" {
+		t.Fatalf("parsed targets %v with error %v", targets, err)
+	}
+	for _, s := range []string{"", "orders"} {
+		if _, err = parseTargets(s); err == nil {
+			t.Fatalf("parsing targets %q did not fail", s)
+		}
+	}
+}
+
+// TestTransportCredentials ensures mTLS needs both a certificate and a key
+func TestTransportCredentials(t *testing.T) {
+	creds, err := transportCredentials(TLSOptions{})
+	if err != nil || creds.Info().SecurityProtocol != "insecure" {
+		t.Fatalf("plaintext credentials were %+v with error %v", creds, err)
+	}
+	creds, err = transportCredentials(TLSOptions{Enabled: true, ServerName: "orders.internal"})
+	if err != nil || creds.Info().SecurityProtocol != "tls" || creds.Info().ServerName != "orders.internal" {
+		t.Fatalf("TLS credentials were %+v with error %v", creds, err)
+	}
+	_, err = transportCredentials(TLSOptions{Enabled: true, CertFile: "client.crt"})
+	if err == nil {
+		t.Fatalf("client certificate without a key was accepted")
+	}
+}
@@ -16,6 +16,7 @@ import (
 
 	kh "github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/nodeCheck"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
 )
 
 var (
@@ -129,6 +130,12 @@ func main() {
 		expectedStatusCodeInt = 200
 	}
 
+	// requests that go over the latency budget count as failed
+	budget, err := probe.BudgetFromEnv()
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+
 	// if the passing count is empty, then default to 100%
 	if passingInt == 0 {
 		passingInt = 100
@@ -156,7 +163,7 @@ func main() {
 	// This for loop makes a http GET request to a known internet address, address can be changed in deployment spec yaml
 	// and returns a http status every second.
 	for checksRan < countInt {
-		r, err := callAPI(APIRequest{
+		r, timing, err := callAPI(APIRequest{
 			URL:  parsedUrl,
 			Type: requestType,
 			Body: bytes.NewBuffer([]byte(requestBody)),
@@ -170,11 +177,16 @@ func main() {
 		}
 
 		if r.StatusCode != expectedStatusCodeInt {
-			log.Errorln("Got a", r.StatusCode, "with a", http.MethodGet, "to", parsedUrl.Redacted())
+			log.Errorln("Got a", r.StatusCode, "with a", http.MethodGet, "to", parsedUrl.Redacted(), "in", timing)
 			checksFailed++
 			continue
 		}
-		log.Infoln("Got a", r.StatusCode, "with a", http.MethodGet, "to", parsedUrl.Redacted())
+		if overBudget := budget.Check(timing); len(overBudget) > 0 {
+			log.Errorln("Got a", r.StatusCode, "with a", http.MethodGet, "to", parsedUrl.Redacted(), "over the latency budget:", strings.Join(overBudget, ", "))
+			checksFailed++
+			continue
+		}
+		log.Infoln("Got a", r.StatusCode, "with a", http.MethodGet, "to", parsedUrl.Redacted(), "in", timing)
 		checksPassed++
 
 		// if we have a ticker, we wait for it to tick before checking again
@@ -213,29 +225,24 @@ func ReportFailureAndExit(err error) {
 }
 
 // callAPI performs an API call on the basis of the request type, body and URL provided to it.
-// It returns the response corresponding to the request.
-func callAPI(request APIRequest) (*http.Response, error) {
-	var response *http.Response
+// It returns the response corresponding to the request along with how long each phase of the request took.
+func callAPI(request APIRequest) (*http.Response, probe.Timing, error) {
+	var body io.Reader
 	switch request.Type {
 	case "GET":
-		resp, err := http.Get(request.URL.String())
-		if err != nil {
-			return nil, fmt.Errorf("error occurred while calling %s: %w", request.URL.Redacted(), err)
-		}
-		response = resp
 	case "POST", "PUT", "DELETE", "PATCH":
-		req, err := http.NewRequest(request.Type, request.URL.String(), request.Body)
-		if err != nil {
-			return nil, fmt.Errorf("error occurred while calling %s: %w", request.URL.Redacted(), err)
-		}
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("error occurred while calling %s: %w", request.URL.Redacted(), err)
-		}
-		response = resp
+		body = request.Body
 	default:
-		return nil, fmt.Errorf("error occurred while calling %s: wrong request type found", request.URL.Redacted())
+		return nil, probe.Timing{}, fmt.Errorf("error occurred while calling %s: wrong request type found", request.URL.Redacted())
 	}
 
-	return response, nil
+	req, err := http.NewRequest(request.Type, request.URL.String(), body)
+	if err != nil {
+		return nil, probe.Timing{}, fmt.Errorf("error occurred while calling %s: %w", request.URL.Redacted(), err)
+	}
+	resp, _, timing, err := probe.HTTP(http.DefaultClient, req)
+	if err != nil {
+		return nil, timing, fmt.Errorf("error occurred while calling %s: %w", request.URL.Redacted(), err)
+	}
+	return resp, timing, nil
 }
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"io"
 	"net/http"
 	"os"
 	"strings"
@@ -13,6 +12,7 @@ import (
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/nodeCheck"
 
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
 )
 
 var (
@@ -54,12 +54,22 @@ func main() {
 		log.Errorln("Error waiting for kuberhealthy endpoint to be contactable by checker pod with error:" + err.Error())
 	}
 
+	// responses that go over the latency budget fail the check
+	budget, err := probe.BudgetFromEnv()
+	if err != nil {
+		reportErrorAndStop(err.Error())
+	}
+
 	// attempt to fetch URL content and fail if we cannot
-	userURLstring, err := getURLContent(TargetURL)
+	userURLstring, timing, err := getURLContent(TargetURL)
 	log.Infoln("Attempting to fetch content from: " + TargetURL)
 	if err != nil {
 		reportErrorAndStop(err.Error())
 	}
+	log.Infoln("Fetched content in", timing)
+	if overBudget := budget.Check(timing); len(overBudget) > 0 {
+		reportErrorAndStop("response was over the latency budget: " + strings.Join(overBudget, ", "))
+	}
 
 	log.Infoln("Parsing content for string " + TargetString)
 
@@ -79,19 +89,22 @@ func main() {
 	log.Infoln("Successfully reported to Kuberhealthy")
 }
 
-// getURLContent retrieves bytes and error from URL
-func getURLContent(url string) ([]byte, error) {
+// getURLContent retrieves bytes and error from URL along with how long each phase of the request took
+func getURLContent(url string) ([]byte, probe.Timing, error) {
 	dur, err := time.ParseDuration(TimeoutDur)
 	if err != nil {
-		return []byte{}, err
+		return []byte{}, probe.Timing{}, err
 	}
-	client := http.Client{Timeout: dur}
-	resp, err := client.Get(url)
+	client := &http.Client{Timeout: dur}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return []byte{}, err
+		return []byte{}, probe.Timing{}, err
 	}
-	return io.ReadAll(resp.Body)
-
+	_, body, timing, err := probe.HTTP(client, req)
+	if err != nil {
+		return []byte{}, timing, err
+	}
+	return body, timing, nil
 }
 
 // findStringInContent parses through URL bytes for specified string and returns bool
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
+)
+
+// variablePattern matches the ${name} references substituted into steps
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z0-9_.\-]+)\}`)
+
+// Journey is a scripted sequence of HTTP requests that is run in order, such as logging in, creating something,
+// verifying it and deleting it again
+type Journey struct {
+	Variables map[string]string `yaml:"variables"` // values that steps can reference before any have been extracted
+	Steps     []Step            `yaml:"steps"`
+}
+
+// Step is a single HTTP request of a journey along with the assertions on its response and the values extracted
+// from it for later steps
+type Step struct {
+	Name    string                `yaml:"name"`
+	Method  string                `yaml:"method"` // GET when empty
+	URL     string                `yaml:"url"`
+	Headers map[string]string     `yaml:"headers"`
+	Body    string                `yaml:"body"`
+	Expect  Expectation           `yaml:"expect"`
+	Extract map[string]Extraction `yaml:"extract"` // the variables set from the response, by name
+	Always  bool                  `yaml:"always"`  // runs even after an earlier step failed, such as to clean up
+}
+
+// Expectation holds the assertions made on the response of a step
+type Expectation struct {
+	Status       int               `yaml:"status"`       // the expected status code.  Any 2xx status passes when empty.
+	BodyContains []string          `yaml:"bodyContains"` // strings that must be found in the body
+	Headers      map[string]string `yaml:"headers"`      // headers that must contain the supplied values
+	JSON         map[string]string `yaml:"json"`         // values that must be found at the supplied paths of a JSON body
+}
+
+// Extraction sets a variable from the response of a step.  Exactly one of its fields is set.
+type Extraction struct {
+	JSON   string `yaml:"json"`   // a dotted path into a JSON body, such as data.items.0.id
+	Header string `yaml:"header"` // the name of a response header
+	Regex  string `yaml:"regex"`  // a regular expression matched against the body.  Its first group is extracted.
+}
+
+// parseJourney reads a journey from YAML and validates its steps
+func parseJourney(b []byte) (Journey, error) {
+	j := Journey{}
+	err := yaml.UnmarshalStrict(b, &j)
+	if err != nil {
+		return Journey{}, fmt.Errorf("failed to parse journey: %w", err)
+	}
+	if len(j.Steps) == 0 {
+		return Journey{}, errors.New("journey has no steps")
+	}
+
+	for i, s := range j.Steps {
+		if len(s.Name) == 0 {
+			return Journey{}, fmt.Errorf("step %d has no name", i+1)
+		}
+		if len(s.URL) == 0 {
+			return Journey{}, fmt.Errorf("step %s has no url", s.Name)
+		}
+		for name, e := range s.Extract {
+			err = e.validate()
+			if err != nil {
+				return Journey{}, fmt.Errorf("step %s: extraction of %s: %w", s.Name, name, err)
+			}
+		}
+	}
+	return j, nil
+}
+
+// validate checks that an extraction has exactly one source and that its regular expression has a group to extract
+func (e Extraction) validate() error {
+	sources := 0
+	for _, s := range []string{e.JSON, e.Header, e.Regex} {
+		if len(s) > 0 {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return errors.New("exactly one of json, header or regex must be set")
+	}
+	if len(e.Regex) == 0 {
+		return nil
+	}
+	re, err := regexp.Compile(e.Regex)
+	if err != nil {
+		return fmt.Errorf("invalid regex: %w", err)
+	}
+	if re.NumSubexp() == 0 {
+		return errors.New("regex has no group to extract")
+	}
+	return nil
+}
+
+// substitute replaces the ${name} references in a string with the value of the variable.  References to ${env.NAME}
+// are read from the environment of the checker pod.
+func substitute(s string, vars map[string]string, env func(string) (string, bool)) (string, error) {
+	var missing []string
+	out := variablePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := variablePattern.FindStringSubmatch(ref)[1]
+		if strings.HasPrefix(name, "env.") {
+			if v, ok := env(strings.TrimPrefix(name, "env.")); ok {
+				return v
+			}
+		} else if v, ok := vars[name]; ok {
+			return v
+		}
+		missing = append(missing, name)
+		return ref
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("undefined variables: %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// lookupJSON returns the value found at a dotted path of a JSON document.  Numeric path elements index arrays.
+// Strings and numbers are returned as they are, anything else is returned as JSON.
+func lookupJSON(body []byte, path string) (string, error) {
+	var doc interface{}
+	d := json.NewDecoder(strings.NewReader(string(body)))
+	d.UseNumber()
+	err := d.Decode(&doc)
+	if err != nil {
+		return "", fmt.Errorf("body is not JSON: %w", err)
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			var ok bool
+			doc, ok = v[key]
+			if !ok {
+				return "", fmt.Errorf("%s not found in body", path)
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return "", fmt.Errorf("%s not found in body", path)
+			}
+			doc = v[i]
+		default:
+			return "", fmt.Errorf("%s not found in body", path)
+		}
+	}
+
+	switch v := doc.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// runJourney runs every step of a journey in order and returns the errors of the steps that failed.  Steps whose
+// request goes over the latency budget fail.  Once a step fails, only the steps marked always are run.
+func runJourney(ctx context.Context, client *http.Client, j Journey, budget probe.Budget, env func(string) (string, bool)) []string {
+	vars := make(map[string]string)
+	for k, v := range j.Variables {
+		vars[k] = v
+	}
+
+	var errs []string
+	for _, s := range j.Steps {
+		if len(errs) > 0 && !s.Always {
+			log.Infoln("Skipping step", s.Name, "after an earlier step failed")
+			continue
+		}
+		log.Infoln("Running step", s.Name)
+		err := runStep(ctx, client, s, budget, vars, env)
+		if err != nil {
+			log.Errorln("Step", s.Name, "failed:", err)
+			errs = append(errs, "step "+s.Name+": "+err.Error())
+		}
+	}
+	return errs
+}
+
+// runStep sends the request of a step, checks its response against the expectations of the step and the latency
+// budget and extracts its variables into vars
+func runStep(ctx context.Context, client *http.Client, s Step, budget probe.Budget, vars map[string]string, env func(string) (string, bool)) error {
+	method := s.Method
+	if len(method) == 0 {
+		method = http.MethodGet
+	}
+	url, err := substitute(s.URL, vars, env)
+	if err != nil {
+		return err
+	}
+	body, err := substitute(s.Body, vars, env)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), url, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range s.Headers {
+		v, err = substitute(v, vars, env)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(k, v)
+	}
+
+	resp, respBody, timing, err := probe.HTTP(client, req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	log.Infoln("Step", s.Name, "got a", resp.StatusCode, "in", timing)
+	if overBudget := budget.Check(timing); len(overBudget) > 0 {
+		return fmt.Errorf("request was over the latency budget: %s", strings.Join(overBudget, ", "))
+	}
+
+	err = checkExpectation(s.Expect, resp, respBody, vars, env)
+	if err != nil {
+		return err
+	}
+
+	for name, e := range s.Extract {
+		v, err := extract(e, resp, respBody)
+		if err != nil {
+			return fmt.Errorf("failed to extract %s: %w", name, err)
+		}
+		vars[name] = v
+	}
+	return nil
+}
+
+// checkExpectation makes the assertions of a step on its response.  Expected values can reference variables.
+func checkExpectation(e Expectation, resp *http.Response, body []byte, vars map[string]string, env func(string) (string, bool)) error {
+	if e.Status != 0 && resp.StatusCode != e.Status {
+		return fmt.Errorf("expected status %d but got %d", e.Status, resp.StatusCode)
+	}
+	if e.Status == 0 && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		return fmt.Errorf("expected a 2xx status but got %d", resp.StatusCode)
+	}
+
+	for _, s := range e.BodyContains {
+		s, err := substitute(s, vars, env)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(string(body), s) {
+			return fmt.Errorf("body does not contain %q", s)
+		}
+	}
+
+	for k, v := range e.Headers {
+		v, err := substitute(v, vars, env)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(resp.Header.Get(k), v) {
+			return fmt.Errorf("header %s is %q, which does not contain %q", k, resp.Header.Get(k), v)
+		}
+	}
+
+	for path, v := range e.JSON {
+		v, err := substitute(v, vars, env)
+		if err != nil {
+			return err
+		}
+		found, err := lookupJSON(body, path)
+		if err != nil {
+			return err
+		}
+		if found != v {
+			return fmt.Errorf("expected %s to be %q but it was %q", path, v, found)
+		}
+	}
+	return nil
+}
+
+// extract reads the value of an extraction from a response
+func extract(e Extraction, resp *http.Response, body []byte) (string, error) {
+	switch {
+	case len(e.JSON) > 0:
+		return lookupJSON(body, e.JSON)
+	case len(e.Header) > 0:
+		v := resp.Header.Get(e.Header)
+		if len(v) == 0 {
+			return "", fmt.Errorf("header %s not found in response", e.Header)
+		}
+		return v, nil
+	}
+	match := regexp.MustCompile(e.Regex).FindSubmatch(body)
+	if match == nil {
+		return "", fmt.Errorf("regex %q did not match body", e.Regex)
+	}
+	return string(match[1]), nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
+)
+
+// testJourney logs in, creates an item, verifies it and always deletes it again
+const testJourney = `
+variables:
+  user: kuberhealthy
+steps:
+- name: login
+  method: POST
+  url: ${baseURL}/login
+  body: '{"user":"${user}","password":"${env.PASSWORD}"}'
+  expect:
+    status: 200
+- name: create
+  method: POST
+  url: ${baseURL}/items
+  expect:
+    status: 201
+    headers:
+      Content-Type: application/json
+  extract:
+    id:
+      json: item.id
+    location:
+      header: Location
+- name: verify
+  url: ${baseURL}${location}
+  expect:
+    json:
+      item.id: ${id}
+      item.tags.0: synthetic
+    bodyContains:
+    - ${user}
+- name: delete
+  method: DELETE
+  url: ${baseURL}/items/${id}
+  always: true
+  expect:
+    status: 204
+`
+
+// newTestServer serves a small API that requires the session cookie set by /login.  Items that can't be found
+// fail verification, and items are found after the supplied delay.
+func newTestServer(findItems bool, verifyDelay time.Duration) (*httptest.Server, *[]string) {
+	var calls []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		creds := map[string]string{}
+		_ = json.NewDecoder(r.Body).Decode(&creds)
+		if creds["user"] != "kuberhealthy" || creds["password"] != "hunter2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1"})
+	})
+	mux.HandleFunc("/items", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		if _, err := r.Cookie("session"); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/items/42")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"item":{"id":42}}`))
+	})
+	mux.HandleFunc("/items/42", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		case !findItems:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			time.Sleep(verifyDelay)
+			_, _ = w.Write([]byte(`{"item":{"id":42,"owner":"kuberhealthy","tags":["synthetic"]}}`))
+		}
+	})
+	return httptest.NewServer(mux), &calls
+}
+
+// TestRunJourney ensures the steps of a journey run in order with the variables extracted by earlier steps, and
+// that steps marked always still run after a failure
+func TestRunJourney(t *testing.T) {
+	env := func(name string) (string, bool) {
+		v, ok := map[string]string{"PASSWORD": "hunter2"}[name]
+		return v, ok
+	}
+
+	var testCases = []struct {
+		description   string
+		findItems     bool
+		verifyDelay   time.Duration
+		budget        probe.Budget
+		expectedErrs  []string // the start of each expected error
+		expectedCalls []string
+	}{
+		{
+			description:   "every step passes",
+			findItems:     true,
+			expectedCalls: []string{"POST /login", "POST /items", "GET /items/42", "DELETE /items/42"},
+		},
+		{
+			description:   "cleanup runs after verification fails",
+			expectedErrs:  []string{"step verify: expected a 2xx status but got 404"},
+			expectedCalls: []string{"POST /login", "POST /items", "GET /items/42", "DELETE /items/42"},
+		},
+		{
+			description:   "step over the latency budget fails",
+			findItems:     true,
+			verifyDelay:   time.Millisecond * 50,
+			budget:        probe.Budget{TTFB: time.Millisecond * 30},
+			expectedErrs:  []string{"step verify: request was over the latency budget: ttfb took"},
+			expectedCalls: []string{"POST /login", "POST /items", "GET /items/42", "DELETE /items/42"},
+		},
+	}
+
+	for _, tc := range testCases {
+		server, calls := newTestServer(tc.findItems, tc.verifyDelay)
+		journey, err := parseJourney([]byte(strings.ReplaceAll(testJourney, "${baseURL}", server.URL)))
+		if err != nil {
+			t.Fatalf("%s: failed to parse journey: %s", tc.description, err)
+		}
+		jar, _ := cookiejar.New(nil)
+
+		errs := runJourney(context.Background(), &http.Client{Jar: jar}, journey, tc.budget, env)
+		server.Close()
+		if len(errs) != len(tc.expectedErrs) {
+			t.Fatalf("%s: journey returned errors %v but expected %v", tc.description, errs, tc.expectedErrs)
+		}
+		for i := range errs {
+			if !strings.HasPrefix(errs[i], tc.expectedErrs[i]) {
+				t.Fatalf("%s: journey returned error %q but expected it to start with %q", tc.description, errs[i], tc.expectedErrs[i])
+			}
+		}
+		if !reflect.DeepEqual(*calls, tc.expectedCalls) {
+			t.Fatalf("%s: server saw calls %v but expected %v", tc.description, *calls, tc.expectedCalls)
+		}
+	}
+}
+
+// TestParseJourney ensures that journeys with unusable steps are rejected
+func TestParseJourney(t *testing.T) {
+	var testCases = []struct {
+		description string
+		journey     string
+		valid       bool
+	}{
+		{description: "valid journey", journey: "steps:\n- name: ping\n  url: http://localhost\n", valid: true},
+		{description: "no steps", journey: "variables:\n  a: b\n", valid: false},
+		{description: "step without url", journey: "steps:\n- name: ping\n", valid: false},
+		{description: "unknown field", journey: "steps:\n- name: ping\n  url: http://localhost\n  retries: 3\n", valid: false},
+		{description: "extraction with two sources", journey: "steps:\n- name: ping\n  url: http://localhost\n  extract:\n    a:\n      json: a\n      header: A\n", valid: false},
+		{description: "regex without a group", journey: "steps:\n- name: ping\n  url: http://localhost\n  extract:\n    a:\n      regex: id=\\d+\n", valid: false},
+	}
+
+	for _, tc := range testCases {
+		_, err := parseJourney([]byte(tc.journey))
+		if (err == nil) != tc.valid {
+			t.Fatalf("%s: parsing returned %v, but expected it to be valid: %t", tc.description, err, tc.valid)
+		}
+	}
+}
+
+// TestSubstitute ensures variables and environment variables are substituted and undefined ones are reported
+func TestSubstitute(t *testing.T) {
+	env := func(name string) (string, bool) {
+		return "secret", name == "TOKEN"
+	}
+	out, err := substitute("${host}/items/${id}?token=${env.TOKEN}", map[string]string{"host": "http://api", "id": "7"}, env)
+	if err != nil || out != "http://api/items/7?token=secret" {
+		t.Fatalf("substitution returned %q and %v", out, err)
+	}
+	_, err = substitute("${missing} ${env.MISSING}", nil, env)
+	if err == nil || err.Error() != "undefined variables: missing, env.MISSING" {
+		t.Fatalf("substitution of undefined variables returned %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	kh "github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/nodeCheck"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
+)
+
+// defaultJourneyFile is where the journey is read from when JOURNEY_FILE is not set
+const defaultJourneyFile = "/etc/http-journey/journey.yaml"
+
+var (
+	// journeyFile is the path of the YAML file that describes the journey, usually mounted from a ConfigMap
+	journeyFile = os.Getenv("JOURNEY_FILE")
+
+	// requestTimeout is the time limit of each request of the journey
+	requestTimeout = os.Getenv("REQUEST_TIMEOUT")
+)
+
+func init() {
+	// set debug mode for nodeCheck pkg
+	nodeCheck.EnableDebugOutput()
+
+	if len(journeyFile) == 0 {
+		journeyFile = defaultJourneyFile
+	}
+	if len(requestTimeout) == 0 {
+		requestTimeout = "10s"
+	}
+}
+
+func main() {
+	// the journey has until the deadline of the run to finish
+	deadline, err := kh.GetDeadline()
+	if err != nil {
+		log.Warningln("Failed to read the deadline of the run, allowing one minute:", err)
+		deadline = time.Now().Add(time.Minute)
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	// hits kuberhealthy endpoint to see if node is ready
+	err = nodeCheck.WaitForKuberhealthy(ctx)
+	if err != nil {
+		log.Errorln("Error waiting for kuberhealthy endpoint to be contactable by checker pod with error:" + err.Error())
+	}
+
+	timeout, err := time.ParseDuration(requestTimeout)
+	if err != nil {
+		ReportFailureAndExit(fmt.Errorf("failed to parse REQUEST_TIMEOUT: %w", err))
+	}
+
+	budget, err := probe.BudgetFromEnv()
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+
+	b, err := os.ReadFile(journeyFile)
+	if err != nil {
+		ReportFailureAndExit(fmt.Errorf("failed to read journey file: %w", err))
+	}
+	journey, err := parseJourney(b)
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+
+	// cookies set by one step, such as the session of a login, are sent by the steps after it
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+	client := &http.Client{Timeout: timeout, Jar: jar}
+
+	log.Infoln("Running journey of", len(journey.Steps), "steps from", journeyFile)
+	errs := runJourney(ctx, client, journey, budget, os.LookupEnv)
+	if len(errs) > 0 {
+		err = kh.ReportFailure(errs)
+		if err != nil {
+			log.Errorln("Error reporting failure to Kuberhealthy servers:", err)
+			os.Exit(1)
+		}
+		log.Infoln("Successfully reported failure to Kuberhealthy servers")
+		return
+	}
+
+	log.Infoln("Every step of the journey passed")
+	err = kh.ReportSuccess()
+	if err != nil {
+		log.Errorln("Error reporting success to Kuberhealthy servers:", err)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported success to Kuberhealthy servers")
+}
+
+// ReportFailureAndExit reports an error to Kuberhealthy and exits the program
+func ReportFailureAndExit(err error) {
+	log.Errorln(err)
+	err2 := kh.ReportFailure([]string{err.Error()})
+	if err2 != nil {
+		log.Errorln("Error reporting failure to Kuberhealthy servers:", err2)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported failure to Kuberhealthy servers")
+	os.Exit(0)
+}
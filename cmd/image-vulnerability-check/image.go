@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// defaultRegistry is the registry of images that do not name one, as the vulnerability scanner records it
+const defaultRegistry = "index.docker.io"
+
+// ImageRef is an image reference broken into its parts
+type ImageRef struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// parseImage parses an image reference as found in pod specs and container statuses.  References without a registry
+// are on Docker Hub, and Docker Hub images without an organization are in the library organization.
+func parseImage(ref string) (ImageRef, error) {
+	// container runtimes prefix the image IDs in container statuses with a scheme
+	if i := strings.Index(ref, "://"); i >= 0 {
+		ref = ref[i+3:]
+	}
+	if len(ref) == 0 {
+		return ImageRef{}, fmt.Errorf("empty image reference")
+	}
+
+	var img ImageRef
+	name := ref
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, img.Digest = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, img.Tag = name[:i], name[i+1:]
+	}
+
+	// the first part of the name is a registry if it looks like a host
+	img.Registry = defaultRegistry
+	img.Repository = name
+	if i := strings.Index(name, "/"); i >= 0 {
+		host := name[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			img.Registry, img.Repository = host, name[i+1:]
+		}
+	}
+	if img.Registry == "docker.io" || img.Registry == "registry-1.docker.io" {
+		img.Registry = defaultRegistry
+	}
+	if img.Registry == defaultRegistry && !strings.Contains(img.Repository, "/") {
+		img.Repository = "library/" + img.Repository
+	}
+	if len(img.Repository) == 0 {
+		return ImageRef{}, fmt.Errorf("image reference %s has no repository", ref)
+	}
+	if len(img.Tag) == 0 && len(img.Digest) == 0 {
+		img.Tag = "latest"
+	}
+	return img, nil
+}
+
+// Name is the registry and repository of the image
+func (i ImageRef) Name() string {
+	return i.Registry + "/" + i.Repository
+}
+
+// Keys are the ways an image can be matched to a vulnerability report, by digest and by tag
+func (i ImageRef) Keys() []string {
+	var keys []string
+	if len(i.Digest) > 0 {
+		keys = append(keys, i.Name()+"@"+i.Digest)
+	}
+	if len(i.Tag) > 0 {
+		keys = append(keys, i.Name()+":"+i.Tag)
+	}
+	return keys
+}
+
+// RunningImage is an image that is running in the cluster, along with a pod that runs it
+type RunningImage struct {
+	Image string // the image as written in the pod spec
+	Ref   ImageRef
+	Pod   string // namespace/name of a pod running the image
+}
+
+// runningImages lists the images of the running pods in the supplied namespaces, or in all namespaces when none are
+// supplied.  Images that start with any of the ignored prefixes are left out.  Each image is listed once, sorted by
+// image.
+func runningImages(ctx context.Context, client kubernetes.Interface, namespaces []string, ignored []string) ([]RunningImage, error) {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	seen := make(map[string]bool)
+	var images []RunningImage
+	for _, ns := range namespaces {
+		pods, err := client.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{FieldSelector: "status.phase=" + string(v1.PodRunning)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods in namespace %q: %w", ns, err)
+		}
+		for _, pod := range pods.Items {
+			for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+				if isIgnored(status.Image, ignored) {
+					continue
+				}
+				ref, err := parseImage(status.Image)
+				if err != nil {
+					continue
+				}
+
+				// the image ID holds the digest that is actually running, which tags can move away from
+				if id, err := parseImage(status.ImageID); err == nil && len(id.Digest) > 0 {
+					ref.Digest = id.Digest
+				}
+				key := strings.Join(ref.Keys(), " ")
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				images = append(images, RunningImage{Image: status.Image, Ref: ref, Pod: pod.Namespace + "/" + pod.Name})
+			}
+		}
+	}
+
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Image < images[j].Image
+	})
+	return images, nil
+}
+
+// isIgnored tells if an image starts with any of the ignored prefixes
+func isIgnored(image string, ignored []string) bool {
+	for _, prefix := range ignored {
+		if strings.HasPrefix(image, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseImage(t *testing.T) {
+	var testCases = []struct {
+		input    string
+		expected ImageRef
+		err      bool
+	}{
+		{input: "nginx", expected: ImageRef{Registry: "index.docker.io", Repository: "library/nginx", Tag: "latest"}},
+		{input: "nginx:1.25", expected: ImageRef{Registry: "index.docker.io", Repository: "library/nginx", Tag: "1.25"}},
+		{input: "docker.io/bitnami/redis:7.0", expected: ImageRef{Registry: "index.docker.io", Repository: "bitnami/redis", Tag: "7.0"}},
+		{input: "quay.io/prometheus/node-exporter:v1.6.0", expected: ImageRef{Registry: "quay.io", Repository: "prometheus/node-exporter", Tag: "v1.6.0"}},
+		{input: "localhost:5000/app", expected: ImageRef{Registry: "localhost:5000", Repository: "app", Tag: "latest"}},
+		{input: "registry.k8s.io/pause@sha256:abc", expected: ImageRef{Registry: "registry.k8s.io", Repository: "pause", Digest: "sha256:abc"}},
+		{input: "docker-pullable://nginx@sha256:def", expected: ImageRef{Registry: "index.docker.io", Repository: "library/nginx", Digest: "sha256:def"}},
+		{input: "ghcr.io/org/app:v2@sha256:123", expected: ImageRef{Registry: "ghcr.io", Repository: "org/app", Tag: "v2", Digest: "sha256:123"}},
+		{input: "", err: true},
+	}
+
+	for _, tc := range testCases {
+		ref, err := parseImage(tc.input)
+		if tc.err {
+			if err == nil {
+				t.Fatalf("expected %q to be refused, got %+v", tc.input, ref)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("failed to parse %q: %s", tc.input, err)
+		}
+		if ref != tc.expected {
+			t.Fatalf("expected %q to parse to %+v, got %+v", tc.input, tc.expected, ref)
+		}
+	}
+}
+
+func TestRunningImages(t *testing.T) {
+	pod := func(namespace string, name string, statuses ...v1.ContainerStatus) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Status:     v1.PodStatus{Phase: v1.PodRunning, ContainerStatuses: statuses},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		pod("web", "web-1", v1.ContainerStatus{Image: "nginx:1.25", ImageID: "docker-pullable://nginx@sha256:aaa"}),
+		pod("web", "web-2", v1.ContainerStatus{Image: "nginx:1.25", ImageID: "docker-pullable://nginx@sha256:aaa"}),
+		pod("kube-system", "proxy", v1.ContainerStatus{Image: "registry.k8s.io/kube-proxy:v1.27.3", ImageID: "sha256:bbb"}),
+		pod("app", "app-1", v1.ContainerStatus{Image: "ghcr.io/org/app:v2", ImageID: "ghcr.io/org/app@sha256:ccc"}),
+	)
+
+	images, err := runningImages(context.Background(), client, nil, []string{"registry.k8s.io/"})
+	if err != nil {
+		t.Fatalf("failed to list running images: %s", err)
+	}
+	if len(images) != 2 {
+		t.Fatalf("expected two running images, got %+v", images)
+	}
+	if images[0].Image != "ghcr.io/org/app:v2" || images[0].Ref.Digest != "sha256:ccc" || images[0].Pod != "app/app-1" {
+		t.Fatalf("expected the app image with its running digest, got %+v", images[0])
+	}
+	if images[1].Image != "nginx:1.25" || images[1].Ref.Digest != "sha256:aaa" {
+		t.Fatalf("expected the nginx image listed once with its running digest, got %+v", images[1])
+	}
+
+	images, err = runningImages(context.Background(), client, []string{"web"}, nil)
+	if err != nil {
+		t.Fatalf("failed to list running images: %s", err)
+	}
+	if len(images) != 1 || images[0].Image != "nginx:1.25" {
+		t.Fatalf("expected only the image of the web namespace, got %+v", images)
+	}
+}
@@ -0,0 +1,173 @@
+// Package image-vulnerability-check implements a checker for Kuberhealthy that verifies the image scanner of the
+// cluster is producing fresh vulnerability reports and that no running image has more critical vulnerabilities than
+// allowed
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	kh "github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/nodeCheck"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/kubeClient"
+)
+
+var (
+	// kubeConfigFile is used when the check runs outside of a cluster
+	kubeConfigFile = filepath.Join(os.Getenv("HOME"), ".kube", "config")
+
+	// namespaces are the comma separated namespaces whose running images are checked.  All namespaces when empty.
+	namespaces = os.Getenv("NAMESPACES")
+
+	// ignoredImages are comma separated prefixes of images that are not checked
+	ignoredImages = os.Getenv("IGNORED_IMAGES")
+
+	// maxReportAge fails the check when vulnerability reports were last updated longer ago than this
+	maxReportAge = os.Getenv("MAX_REPORT_AGE")
+
+	// maxCritical fails the check when a running image has more critical vulnerabilities than this
+	maxCritical = os.Getenv("MAX_CRITICAL")
+
+	// ignoreUnfixed only counts critical vulnerabilities that have a fixed version available
+	ignoreUnfixed = os.Getenv("IGNORE_UNFIXED")
+
+	// maxUnscanned fails the check when more running images than this have no fresh vulnerability report
+	maxUnscanned = os.Getenv("MAX_UNSCANNED_IMAGES")
+)
+
+func init() {
+	// set debug mode for nodeCheck pkg
+	nodeCheck.EnableDebugOutput()
+}
+
+func main() {
+	deadline, err := kh.GetDeadline()
+	if err != nil {
+		log.Warningln("Failed to read the deadline of the run, allowing five minutes:", err)
+		deadline = time.Now().Add(5 * time.Minute)
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline.Add(-5*time.Second))
+	defer cancel()
+
+	// hits kuberhealthy endpoint to see if node is ready
+	err = nodeCheck.WaitForKuberhealthy(ctx)
+	if err != nil {
+		log.Errorln("Error waiting for kuberhealthy endpoint to be contactable by checker pod with error:" + err.Error())
+	}
+
+	th, err := parseThresholds()
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+
+	restConfig, err := kubeClient.RestConfig(kubeConfigFile)
+	if err != nil {
+		ReportFailureAndExit(fmt.Errorf("failed to create kubernetes client configuration: %w", err))
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		ReportFailureAndExit(fmt.Errorf("failed to create kubernetes client: %w", err))
+	}
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		ReportFailureAndExit(fmt.Errorf("failed to create kubernetes dynamic client: %w", err))
+	}
+
+	images, err := runningImages(ctx, client, splitList(namespaces), splitList(ignoredImages))
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+	reports, err := listReports(ctx, dynamicClient, splitList(namespaces))
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+
+	now := time.Now()
+	res := evaluate(images, reports, th, now)
+	metadata := res.Metadata()
+	log.Infoln("Checked", res.Images, "running images against", res.Reports, "vulnerability reports:", metadata)
+
+	problems := res.Problems(th, now)
+	if len(problems) > 0 {
+		log.Errorln("Found problems with running images:", problems)
+		err = kh.ReportFailureWithMetadata(problems, metadata)
+		if err != nil {
+			log.Errorln("Error reporting failure to Kuberhealthy servers:", err)
+			os.Exit(1)
+		}
+		log.Infoln("Successfully reported failure to Kuberhealthy servers")
+		return
+	}
+
+	err = kh.ReportSuccessWithMetadata(metadata)
+	if err != nil {
+		log.Errorln("Error reporting success to Kuberhealthy servers:", err)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported success to Kuberhealthy servers")
+}
+
+// parseThresholds reads the thresholds of the check from the environment
+func parseThresholds() (Thresholds, error) {
+	th := Thresholds{MaxReportAge: 48 * time.Hour, MaxUnscanned: -1}
+
+	var err error
+	if len(maxReportAge) > 0 {
+		th.MaxReportAge, err = time.ParseDuration(maxReportAge)
+		if err != nil {
+			return Thresholds{}, fmt.Errorf("failed to parse MAX_REPORT_AGE: %w", err)
+		}
+	}
+	if len(maxCritical) > 0 {
+		th.MaxCritical, err = strconv.ParseInt(maxCritical, 10, 64)
+		if err != nil {
+			return Thresholds{}, fmt.Errorf("failed to parse MAX_CRITICAL: %w", err)
+		}
+	}
+	if len(ignoreUnfixed) > 0 {
+		th.IgnoreUnfixed, err = strconv.ParseBool(ignoreUnfixed)
+		if err != nil {
+			return Thresholds{}, fmt.Errorf("failed to parse IGNORE_UNFIXED: %w", err)
+		}
+	}
+	if len(maxUnscanned) > 0 {
+		th.MaxUnscanned, err = strconv.Atoi(maxUnscanned)
+		if err != nil {
+			return Thresholds{}, fmt.Errorf("failed to parse MAX_UNSCANNED_IMAGES: %w", err)
+		}
+	}
+	return th, nil
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// ReportFailureAndExit reports an error to Kuberhealthy and exits the program
+func ReportFailureAndExit(err error) {
+	log.Errorln(err)
+	err2 := kh.ReportFailure([]string{err.Error()})
+	if err2 != nil {
+		log.Errorln("Error reporting failure to Kuberhealthy servers:", err2)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported failure to Kuberhealthy servers")
+	os.Exit(0)
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// reportResource is the resource that the Trivy operator writes the vulnerability reports of workloads to
+var reportResource = schema.GroupVersionResource{Group: "aquasecurity.github.io", Version: "v1alpha1", Resource: "vulnerabilityreports"}
+
+// maxListedImages is how many images are named in a single error before the rest are only counted
+const maxListedImages = 10
+
+// ImageReport is what the check reads from a vulnerability report
+type ImageReport struct {
+	Ref             ImageRef
+	Updated         time.Time
+	Critical        int64
+	High            int64
+	FixableCritical int64 // critical vulnerabilities that have a fixed version available
+}
+
+// imageReport reads a vulnerability report
+func imageReport(u *unstructured.Unstructured) (ImageReport, error) {
+	registry, _, _ := unstructured.NestedString(u.Object, "report", "registry", "server")
+	repository, _, _ := unstructured.NestedString(u.Object, "report", "artifact", "repository")
+	tag, _, _ := unstructured.NestedString(u.Object, "report", "artifact", "tag")
+	digest, _, _ := unstructured.NestedString(u.Object, "report", "artifact", "digest")
+	if len(repository) == 0 {
+		return ImageReport{}, fmt.Errorf("vulnerability report %s/%s does not name an image", u.GetNamespace(), u.GetName())
+	}
+
+	// the parts are joined and parsed again so that they are normalized the same way as the images of pods
+	name := repository
+	if len(registry) > 0 {
+		name = registry + "/" + repository
+	}
+	if len(tag) > 0 {
+		name += ":" + tag
+	}
+	if len(digest) > 0 {
+		name += "@" + digest
+	}
+	ref, err := parseImage(name)
+	if err != nil {
+		return ImageReport{}, err
+	}
+
+	r := ImageReport{Ref: ref}
+	updated, _, _ := unstructured.NestedString(u.Object, "report", "updateTimestamp")
+	r.Updated, err = time.Parse(time.RFC3339, updated)
+	if err != nil {
+		r.Updated = u.GetCreationTimestamp().Time
+	}
+	r.Critical, _, _ = unstructured.NestedInt64(u.Object, "report", "summary", "criticalCount")
+	r.High, _, _ = unstructured.NestedInt64(u.Object, "report", "summary", "highCount")
+
+	vulnerabilities, _, _ := unstructured.NestedSlice(u.Object, "report", "vulnerabilities")
+	for _, v := range vulnerabilities {
+		vuln, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		severity, _, _ := unstructured.NestedString(vuln, "severity")
+		fixed, _, _ := unstructured.NestedString(vuln, "fixedVersion")
+		if strings.EqualFold(severity, "CRITICAL") && len(fixed) > 0 {
+			r.FixableCritical++
+		}
+	}
+	return r, nil
+}
+
+// listReports lists the vulnerability reports in the supplied namespaces, or in all namespaces when none are
+// supplied.  Reports that can not be read are skipped.
+func listReports(ctx context.Context, client dynamic.Interface, namespaces []string) ([]ImageReport, error) {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	var reports []ImageReport
+	for _, ns := range namespaces {
+		list, err := client.Resource(reportResource).Namespace(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list vulnerability reports in namespace %q: %w", ns, err)
+		}
+		for i := range list.Items {
+			r, err := imageReport(&list.Items[i])
+			if err != nil {
+				continue
+			}
+			reports = append(reports, r)
+		}
+	}
+	return reports, nil
+}
+
+// Thresholds are what the running images and their reports must meet for the check to pass
+type Thresholds struct {
+	MaxReportAge  time.Duration // how long ago reports may have been updated
+	MaxCritical   int64         // the most critical vulnerabilities an image may have
+	IgnoreUnfixed bool          // only count critical vulnerabilities that have a fix available
+	MaxUnscanned  int           // the most running images without a fresh report.  Unlimited when negative.
+}
+
+// Result is the outcome of matching the running images to their vulnerability reports
+type Result struct {
+	Images        int       // running images
+	Scanned       int       // running images with a fresh report
+	Unscanned     []string  // running images without any report
+	Stale         []string  // running images whose report is older than the maximum age
+	OverThreshold []string  // running images with more critical vulnerabilities than allowed, and their counts
+	Critical      int64     // critical vulnerabilities in all running images
+	High          int64     // high vulnerabilities in all running images
+	NewestReport  time.Time // when the most recently updated report was updated
+	Reports       int       // reports found
+}
+
+// evaluate matches each running image to its most recently updated report and tallies the results
+func evaluate(images []RunningImage, reports []ImageReport, th Thresholds, now time.Time) Result {
+	res := Result{Images: len(images), Reports: len(reports)}
+
+	latest := make(map[string]ImageReport)
+	for _, r := range reports {
+		if r.Updated.After(res.NewestReport) {
+			res.NewestReport = r.Updated
+		}
+		for _, key := range r.Ref.Keys() {
+			if existing, ok := latest[key]; !ok || r.Updated.After(existing.Updated) {
+				latest[key] = r
+			}
+		}
+	}
+
+	for _, img := range images {
+		// the digest is preferred, since a tag may have been scanned while it pointed at another image
+		var report ImageReport
+		var found bool
+		for _, key := range img.Ref.Keys() {
+			if report, found = latest[key]; found {
+				break
+			}
+		}
+		if !found {
+			res.Unscanned = append(res.Unscanned, img.Image+" ("+img.Pod+")")
+			continue
+		}
+		if th.MaxReportAge > 0 && now.Sub(report.Updated) > th.MaxReportAge {
+			res.Stale = append(res.Stale, img.Image)
+		} else {
+			res.Scanned++
+		}
+
+		res.Critical += report.Critical
+		res.High += report.High
+		critical := report.Critical
+		if th.IgnoreUnfixed {
+			critical = report.FixableCritical
+		}
+		if critical > th.MaxCritical {
+			res.OverThreshold = append(res.OverThreshold, fmt.Sprintf("%s (%d)", img.Image, critical))
+		}
+	}
+	return res
+}
+
+// Problems lists the ways that the result falls short of the thresholds
+func (res Result) Problems(th Thresholds, now time.Time) []string {
+	var problems []string
+	if res.Reports == 0 {
+		problems = append(problems, "no vulnerability reports were found. Is the image scanner running?")
+	} else if th.MaxReportAge > 0 && now.Sub(res.NewestReport) > th.MaxReportAge {
+		problems = append(problems, fmt.Sprintf("no vulnerability report was updated in the last %s, the newest is from %s. Is the image scanner running?", th.MaxReportAge, res.NewestReport.UTC().Format(time.RFC3339)))
+	}
+
+	if th.MaxUnscanned >= 0 && len(res.Unscanned)+len(res.Stale) > th.MaxUnscanned {
+		if len(res.Unscanned) > 0 {
+			problems = append(problems, fmt.Sprintf("%d running images have no vulnerability report: %s", len(res.Unscanned), listImages(res.Unscanned)))
+		}
+		if len(res.Stale) > 0 {
+			problems = append(problems, fmt.Sprintf("%d running images have a vulnerability report older than %s: %s", len(res.Stale), th.MaxReportAge, listImages(res.Stale)))
+		}
+	}
+
+	if len(res.OverThreshold) > 0 {
+		kind := "critical vulnerabilities"
+		if th.IgnoreUnfixed {
+			kind = "fixable critical vulnerabilities"
+		}
+		problems = append(problems, fmt.Sprintf("%d running images have more than %d %s: %s", len(res.OverThreshold), th.MaxCritical, kind, listImages(res.OverThreshold)))
+	}
+	return problems
+}
+
+// Metadata holds the counts of the result, which are reported to Kuberhealthy with the result of the check
+func (res Result) Metadata() map[string]string {
+	metadata := map[string]string{
+		"imagesRunning":           strconv.Itoa(res.Images),
+		"imagesScanned":           strconv.Itoa(res.Scanned),
+		"imagesUnscanned":         strconv.Itoa(len(res.Unscanned)),
+		"imagesStale":             strconv.Itoa(len(res.Stale)),
+		"imagesOverThreshold":     strconv.Itoa(len(res.OverThreshold)),
+		"criticalVulnerabilities": strconv.FormatInt(res.Critical, 10),
+		"highVulnerabilities":     strconv.FormatInt(res.High, 10),
+		"vulnerabilityReports":    strconv.Itoa(res.Reports),
+	}
+	if !res.NewestReport.IsZero() {
+		metadata["newestReport"] = res.NewestReport.UTC().Format(time.RFC3339)
+	}
+	return metadata
+}
+
+// listImages joins the first images of a list for an error message and counts the rest
+func listImages(images []string) string {
+	sorted := append([]string(nil), images...)
+	sort.Strings(sorted)
+	if len(sorted) <= maxListedImages {
+		return strings.Join(sorted, ", ")
+	}
+	return strings.Join(sorted[:maxListedImages], ", ") + fmt.Sprintf(" and %d more", len(sorted)-maxListedImages)
+}
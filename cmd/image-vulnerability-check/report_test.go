@@ -0,0 +1,153 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestImageReport(t *testing.T) {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "replicaset-web-nginx", "namespace": "web"},
+		"report": map[string]interface{}{
+			"updateTimestamp": "2023-06-01T10:00:00Z",
+			"registry":        map[string]interface{}{"server": "index.docker.io"},
+			"artifact":        map[string]interface{}{"repository": "library/nginx", "tag": "1.25", "digest": "sha256:aaa"},
+			"summary":         map[string]interface{}{"criticalCount": int64(3), "highCount": int64(7)},
+			"vulnerabilities": []interface{}{
+				map[string]interface{}{"vulnerabilityID": "CVE-1", "severity": "CRITICAL", "fixedVersion": "1.2.3"},
+				map[string]interface{}{"vulnerabilityID": "CVE-2", "severity": "CRITICAL", "fixedVersion": ""},
+				map[string]interface{}{"vulnerabilityID": "CVE-3", "severity": "CRITICAL"},
+				map[string]interface{}{"vulnerabilityID": "CVE-4", "severity": "HIGH", "fixedVersion": "2.0"},
+			},
+		},
+	}}
+
+	r, err := imageReport(u)
+	if err != nil {
+		t.Fatalf("failed to read the report: %s", err)
+	}
+	expectedKeys := []string{"index.docker.io/library/nginx@sha256:aaa", "index.docker.io/library/nginx:1.25"}
+	if !reflect.DeepEqual(r.Ref.Keys(), expectedKeys) {
+		t.Fatalf("expected the report to match %v, got %v", expectedKeys, r.Ref.Keys())
+	}
+	if !r.Updated.Equal(time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC)) || r.Critical != 3 || r.High != 7 || r.FixableCritical != 1 {
+		t.Fatalf("report was read wrong: %+v", r)
+	}
+
+	_, err = imageReport(&unstructured.Unstructured{Object: map[string]interface{}{"report": map[string]interface{}{}}})
+	if err == nil {
+		t.Fatalf("expected a report without an image to be refused")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	image := func(image string, digest string) RunningImage {
+		ref, _ := parseImage(image)
+		ref.Digest = digest
+		return RunningImage{Image: image, Ref: ref, Pod: "ns/pod"}
+	}
+	report := func(image string, updated time.Time, critical int64, fixable int64) ImageReport {
+		ref, _ := parseImage(image)
+		return ImageReport{Ref: ref, Updated: updated, Critical: critical, High: 1, FixableCritical: fixable}
+	}
+	images := []RunningImage{
+		image("nginx:1.25", "sha256:new"),
+		image("redis:7", ""),
+		image("ghcr.io/org/app:v2", ""),
+		image("ghcr.io/org/unscanned:v1", ""),
+	}
+
+	var testCases = []struct {
+		name     string
+		reports  []ImageReport
+		th       Thresholds
+		expected []string
+		metadata map[string]string
+	}{
+		{
+			name: "fresh reports under the threshold",
+			reports: []ImageReport{
+				report("nginx@sha256:new", now.Add(-time.Hour), 0, 0),
+				report("redis:7", now.Add(-2*time.Hour), 0, 0),
+				report("ghcr.io/org/app:v2", now.Add(-3*time.Hour), 0, 0),
+			},
+			th: Thresholds{MaxReportAge: 48 * time.Hour, MaxUnscanned: -1},
+		},
+		{
+			name: "the digest is matched before the tag",
+			reports: []ImageReport{
+				report("nginx:1.25@sha256:old", now.Add(-time.Hour), 4, 4),
+				report("nginx@sha256:new", now.Add(-2*time.Hour), 0, 0),
+			},
+			th: Thresholds{MaxReportAge: 48 * time.Hour, MaxUnscanned: -1},
+		},
+		{
+			name: "critical vulnerabilities over the threshold",
+			reports: []ImageReport{
+				report("nginx@sha256:new", now.Add(-time.Hour), 2, 0),
+				report("redis:7", now.Add(-time.Hour), 5, 1),
+			},
+			th:       Thresholds{MaxReportAge: 48 * time.Hour, MaxCritical: 1, MaxUnscanned: -1},
+			expected: []string{"2 running images have more than 1 critical vulnerabilities: nginx:1.25 (2), redis:7 (5)"},
+		},
+		{
+			name: "unfixed vulnerabilities ignored",
+			reports: []ImageReport{
+				report("nginx@sha256:new", now.Add(-time.Hour), 2, 0),
+				report("redis:7", now.Add(-time.Hour), 5, 1),
+			},
+			th:       Thresholds{MaxReportAge: 48 * time.Hour, IgnoreUnfixed: true, MaxUnscanned: -1},
+			expected: []string{"1 running images have more than 0 fixable critical vulnerabilities: redis:7 (1)"},
+		},
+		{
+			name: "scanner stopped producing reports",
+			reports: []ImageReport{
+				report("nginx@sha256:new", now.Add(-72*time.Hour), 0, 0),
+				report("redis:7", now.Add(-50*time.Hour), 0, 0),
+			},
+			th: Thresholds{MaxReportAge: 48 * time.Hour, MaxUnscanned: 2},
+			expected: []string{
+				"no vulnerability report was updated in the last 48h0m0s, the newest is from 2023-05-30T10:00:00Z. Is the image scanner running?",
+				"2 running images have no vulnerability report: ghcr.io/org/app:v2 (ns/pod), ghcr.io/org/unscanned:v1 (ns/pod)",
+				"2 running images have a vulnerability report older than 48h0m0s: nginx:1.25, redis:7",
+			},
+		},
+		{
+			name:     "no reports",
+			th:       Thresholds{MaxReportAge: 48 * time.Hour, MaxUnscanned: -1},
+			expected: []string{"no vulnerability reports were found. Is the image scanner running?"},
+			metadata: map[string]string{
+				"imagesRunning": "4", "imagesScanned": "0", "imagesUnscanned": "4", "imagesStale": "0", "imagesOverThreshold": "0",
+				"criticalVulnerabilities": "0", "highVulnerabilities": "0", "vulnerabilityReports": "0",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := evaluate(images, tc.reports, tc.th, now)
+			problems := res.Problems(tc.th, now)
+			if !reflect.DeepEqual(problems, tc.expected) {
+				t.Fatalf("expected problems %q, got %q", tc.expected, problems)
+			}
+			if tc.metadata != nil && !reflect.DeepEqual(res.Metadata(), tc.metadata) {
+				t.Fatalf("expected metadata %v, got %v", tc.metadata, res.Metadata())
+			}
+		})
+	}
+}
+
+func TestListImages(t *testing.T) {
+	var images []string
+	for i := 0; i < 12; i++ {
+		images = append(images, string(rune('a'+i)))
+	}
+	listed := listImages(images)
+	if listed != "a, b, c, d, e, f, g, h, i, j and 2 more" {
+		t.Fatalf("expected the first ten images and a count of the rest, got %q", listed)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// unmatchedEndpoint is the endpoint requests that match no registered handler are recorded under
+const unmatchedEndpoint = "unmatched"
+
+// accessLogResponseWriter remembers the status code and the number of bytes written in a response
+type accessLogResponseWriter struct {
+	http.ResponseWriter
+	code    int
+	written int64
+}
+
+// WriteHeader remembers the status code of the response
+func (w *accessLogResponseWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write counts the bytes of the response body
+func (w *accessLogResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Flush flushes the underlying writer when it supports flushing
+func (w *accessLogResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// countingReadCloser counts the bytes read from a request body
+type countingReadCloser struct {
+	io.ReadCloser
+	read int64
+}
+
+// Read counts the bytes read
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.read += int64(n)
+	return n, err
+}
+
+// requestMethod returns the method of a request for metric labels.  Methods the web server does not serve are
+// grouped so that clients can't create a series per made up method.
+func requestMethod(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return r.Method
+	}
+	return "other"
+}
+
+// instrumentRequests wraps the handlers of a mux to record the latency, status code and body sizes of every request
+// in the request metrics under the pattern it was routed by.  Each request is also written to the access log when it
+// is enabled.
+func (k *Kuberhealthy) instrumentRequests(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		_, endpoint := mux.Handler(r)
+		if len(endpoint) == 0 {
+			endpoint = unmatchedEndpoint
+		}
+
+		body := &countingReadCloser{ReadCloser: r.Body}
+		r.Body = body
+		rw := &accessLogResponseWriter{ResponseWriter: w, code: http.StatusOK}
+		mux.ServeHTTP(rw, r)
+		duration := time.Since(start)
+
+		k.requestMetrics.Observe(endpoint, requestMethod(r), rw.code, duration, body.read, rw.written)
+		if cfg == nil || !cfg.EnableAccessLog {
+			return
+		}
+		log.WithFields(log.Fields{
+			"method":        r.Method,
+			"path":          r.URL.Path,
+			"endpoint":      endpoint,
+			"status":        rw.code,
+			"duration":      duration.String(),
+			"requestBytes":  body.read,
+			"responseBytes": rw.written,
+			"remoteAddr":    r.RemoteAddr,
+			"userAgent":     r.UserAgent(),
+			"requestID":     rw.Header().Get(external.KHRequestIDHeader),
+		}).Infoln("access")
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/metrics"
+)
+
+// TestInstrumentRequests ensures requests are recorded under the pattern they were routed by, with their status code
+// and body sizes
+func TestInstrumentRequests(t *testing.T) {
+	k := &Kuberhealthy{requestMetrics: metrics.NewRequestMetrics()}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/externalCheckStatus/", func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("no such run"))
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+	handler := k.instrumentRequests(mux)
+
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/externalCheckStatus/3d1c0e7a", strings.NewReader("0123456789")),
+		httptest.NewRequest(http.MethodGet, "/healthz", nil),
+		httptest.NewRequest("BREW", "/healthz", nil),
+		httptest.NewRequest(http.MethodGet, "/nothing-here", nil),
+	}
+	for _, r := range requests {
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	output := k.requestMetrics.PrometheusMetrics()
+	for _, expected := range []string{
+		"kuberhealthy_http_requests_total{endpoint=\"/externalCheckStatus/\",method=\"POST\",code=\"404\"} 1\n",
+		"kuberhealthy_http_requests_total{endpoint=\"/healthz\",method=\"GET\",code=\"200\"} 1\n",
+		"kuberhealthy_http_requests_total{endpoint=\"/healthz\",method=\"other\",code=\"200\"} 1\n",
+		"kuberhealthy_http_requests_total{endpoint=\"unmatched\",method=\"GET\",code=\"404\"} 1\n",
+		"kuberhealthy_http_request_size_bytes_sum{endpoint=\"/externalCheckStatus/\"} 10\n",
+		"kuberhealthy_http_response_size_bytes_sum{endpoint=\"/externalCheckStatus/\"} 11\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("metrics are missing %q:\n%s", expected, output)
+		}
+	}
+}
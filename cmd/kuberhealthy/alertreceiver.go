@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// alertRunUUIDLabel is the label that synthetic alerts carry the UUID of the run that fired them in
+const alertRunUUIDLabel = "kuberhealthy_run_uuid"
+
+// alertDeliveryRetention is how long alert deliveries are remembered
+const alertDeliveryRetention = time.Hour
+
+// alertWebhook is the part of the Alertmanager webhook payload that the receiver needs
+type alertWebhook struct {
+	Receiver string `json:"receiver"`
+	Alerts   []struct {
+		Status string            `json:"status"`
+		Labels map[string]string `json:"labels"`
+	} `json:"alerts"`
+}
+
+// alertDeliveries remembers the synthetic alerts delivered to the webhook receiver by the run that fired them
+type alertDeliveries struct {
+	mu         sync.Mutex
+	deliveries map[string]status.AlertDelivery
+}
+
+// newAlertDeliveries creates an empty set of alert deliveries
+func newAlertDeliveries() *alertDeliveries {
+	return &alertDeliveries{deliveries: make(map[string]status.AlertDelivery)}
+}
+
+// record remembers the delivery of an alert for a run.  The time of the first delivery is kept, while the status is
+// updated by every later delivery.  Deliveries older than the retention are forgotten.
+func (a *alertDeliveries) record(runUUID string, alertStatus string, receiver string, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for uuid, d := range a.deliveries {
+		if now.Sub(d.Received) > alertDeliveryRetention {
+			delete(a.deliveries, uuid)
+		}
+	}
+
+	d, found := a.deliveries[runUUID]
+	if !found {
+		d.Received = now
+	}
+	d.Status = alertStatus
+	d.Receiver = receiver
+	a.deliveries[runUUID] = d
+}
+
+// get returns the delivery of the alert of a run, if one was delivered
+func (a *alertDeliveries) get(runUUID string) (status.AlertDelivery, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	d, found := a.deliveries[runUUID]
+	return d, found
+}
+
+// alertReceiverTokenConfigured indicates that webhook deliveries can be authenticated
+func alertReceiverTokenConfigured() bool {
+	return cfg != nil && len(cfg.AlertReceiverToken) > 0
+}
+
+// alertReceiverAuthorized indicates that a webhook delivery carries the configured alertReceiverToken as its bearer
+// token, which Alertmanager sends when the token is set in the http_config of the webhook receiver
+func alertReceiverAuthorized(r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(cfg.AlertReceiverToken)) == 1
+}
+
+// alertReceiverHandler is an Alertmanager webhook receiver for the synthetic alerts fired by checks of the alerting
+// pipeline.  Alerts are matched to runs by their kuberhealthy_run_uuid label.  Alerts of runs that Kuberhealthy is
+// not tracking are ignored, so that arbitrary alerts routed here don't pile up.  Deliveries must carry the
+// alertReceiverToken as a bearer token, so that other clients in the cluster can't fake the delivery of an alert.
+// Every delivery is refused while no token is configured.
+func (k *Kuberhealthy) alertReceiverHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+	if !alertReceiverTokenConfigured() {
+		w.WriteHeader(http.StatusForbidden)
+		return fmt.Errorf("refused alertmanager webhook from %s: alertReceiverToken is not configured", r.RemoteAddr)
+	}
+	if !alertReceiverAuthorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return fmt.Errorf("refused alertmanager webhook from %s: missing or invalid bearer token", r.RemoteAddr)
+	}
+
+	webhook := alertWebhook{}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&webhook)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return fmt.Errorf("failed to decode alertmanager webhook from %s: %w", r.RemoteAddr, err)
+	}
+
+	now := time.Now()
+	for _, alert := range webhook.Alerts {
+		runUUID := alert.Labels[alertRunUUIDLabel]
+		if len(runUUID) == 0 {
+			continue
+		}
+		if _, known := k.runTracker.Get(runUUID); !known {
+			log.Debugln("alertReceiver: Ignoring alert of unknown run", runUUID)
+			continue
+		}
+		log.Infoln("alertReceiver: Received", alert.Status, "alert of run", runUUID, "from receiver", webhook.Receiver)
+		k.alertDeliveries.record(runUUID, alert.Status, webhook.Receiver, now)
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// alertDeliveryHandler serves the delivery of the alert of the run UUID at the end of the request path, so that the
+// checker pod that fired it can confirm that it went through
+func (k *Kuberhealthy) alertDeliveryHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	runUUID := strings.TrimPrefix(r.URL.Path, "/alertReceiver/")
+	if len(runUUID) == 0 || strings.Contains(runUUID, "/") {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+
+	delivery, found := k.alertDeliveries.get(runUUID)
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+
+	b, err := json.Marshal(delivery)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to marshal alert delivery of run %s: %w", runUUID, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(b)
+	if err != nil {
+		return fmt.Errorf("failed to write alert delivery of run %s: %w", runUUID, err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestAlertReceiver ensures alerts delivered by Alertmanager are recorded for the runs that fired them and can be
+// looked up by run UUID
+func TestAlertReceiver(t *testing.T) {
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg = &Config{AlertReceiverToken: "alertmanager-token"}
+
+	kh := &Kuberhealthy{runTracker: external.NewRunTracker(), alertDeliveries: newAlertDeliveries()}
+	kh.runTracker.Start("firing-run", "alerting-pipeline-check", "kuberhealthy", "check-1", time.Now().Add(time.Minute))
+
+	webhook := `{"version":"4","status":"firing","receiver":"kuberhealthy","alerts":[
+		{"status":"firing","labels":{"alertname":"KuberhealthyAlertingPipelineProbe","kuberhealthy_run_uuid":"firing-run"}},
+		{"status":"firing","labels":{"alertname":"KuberhealthyAlertingPipelineProbe","kuberhealthy_run_uuid":"unknown-run"}},
+		{"status":"firing","labels":{"alertname":"KubePodCrashLooping"}}
+	]}`
+	forged := strings.Replace(webhook, "firing-run", "forged-run", 1)
+	kh.runTracker.Start("forged-run", "alerting-pipeline-check", "kuberhealthy", "check-1", time.Now().Add(time.Minute))
+	var testCases = []struct {
+		description   string
+		method        string
+		body          string
+		authorization string
+		code          int
+	}{
+		{"delivery", http.MethodPost, webhook, "Bearer alertmanager-token", http.StatusOK},
+		{"delivery without a token", http.MethodPost, forged, "", http.StatusUnauthorized},
+		{"delivery with the wrong token", http.MethodPost, forged, "Bearer guessed-token", http.StatusUnauthorized},
+		{"delivery with the token but no bearer scheme", http.MethodPost, forged, "alertmanager-token", http.StatusUnauthorized},
+		{"invalid body", http.MethodPost, "not json", "Bearer alertmanager-token", http.StatusBadRequest},
+		{"wrong method", http.MethodGet, "", "Bearer alertmanager-token", http.StatusMethodNotAllowed},
+	}
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, "/alertReceiver", strings.NewReader(tc.body))
+		if len(tc.authorization) > 0 {
+			req.Header.Set("Authorization", tc.authorization)
+		}
+		_ = kh.alertReceiverHandler(recorder, req)
+		if recorder.Code != tc.code {
+			t.Fatalf("%s: %s /alertReceiver returned code %d but expected %d", tc.description, tc.method, recorder.Code, tc.code)
+		}
+	}
+	if _, found := kh.alertDeliveries.get("forged-run"); found {
+		t.Fatalf("expected deliveries without the alert receiver token to be refused")
+	}
+
+	cfg = &Config{}
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/alertReceiver", strings.NewReader(forged))
+	req.Header.Set("Authorization", "Bearer ")
+	_ = kh.alertReceiverHandler(recorder, req)
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("expected deliveries to be refused while no alert receiver token is configured but got code %d", recorder.Code)
+	}
+
+	var lookups = []struct {
+		path     string
+		code     int
+		received bool
+	}{
+		{"/alertReceiver/firing-run", http.StatusOK, true},
+		{"/alertReceiver/unknown-run", http.StatusNotFound, false},
+		{"/alertReceiver/", http.StatusBadRequest, false},
+	}
+	for _, tc := range lookups {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		err := kh.alertDeliveryHandler(recorder, req)
+		if err != nil {
+			t.Fatalf("GET %s returned an error: %s", tc.path, err)
+		}
+		if recorder.Code != tc.code {
+			t.Fatalf("GET %s returned code %d but expected %d", tc.path, recorder.Code, tc.code)
+		}
+		if !tc.received {
+			continue
+		}
+
+		delivery := status.AlertDelivery{}
+		err = json.Unmarshal(recorder.Body.Bytes(), &delivery)
+		if err != nil {
+			t.Fatalf("failed to unmarshal alert delivery from %s: %s", tc.path, err)
+		}
+		if delivery.Status != "firing" || delivery.Receiver != "kuberhealthy" || delivery.Received.IsZero() {
+			t.Fatalf("%s returned delivery %+v", tc.path, delivery)
+		}
+	}
+}
+
+// TestAlertDeliveries ensures the first delivery time is kept as the status changes and old deliveries are forgotten
+func TestAlertDeliveries(t *testing.T) {
+
+	deliveries := newAlertDeliveries()
+	start := time.Now()
+	deliveries.record("old-run", "firing", "kuberhealthy", start)
+	deliveries.record("run", "firing", "kuberhealthy", start.Add(time.Minute))
+	deliveries.record("run", "resolved", "kuberhealthy", start.Add(2*time.Minute))
+
+	d, found := deliveries.get("run")
+	if !found || d.Status != "resolved" || !d.Received.Equal(start.Add(time.Minute)) {
+		t.Fatalf("expected resolved delivery first received at %s, got %+v (found %t)", start.Add(time.Minute), d, found)
+	}
+
+	deliveries.record("new-run", "firing", "kuberhealthy", start.Add(alertDeliveryRetention+30*time.Second))
+	_, found = deliveries.get("old-run")
+	if found {
+		t.Fatalf("expected delivery older than the retention to be forgotten")
+	}
+	_, found = deliveries.get("run")
+	if !found {
+		t.Fatalf("expected delivery within the retention to be kept")
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// kuberhealthyNode looks up the node that this kuberhealthy pod runs on.  Returns an empty node name when it can't be
+// found, which leaves checker pods free to be scheduled onto any node.
+func kuberhealthyNode(ctx context.Context, client kubernetes.Interface, namespace string, podName string) string {
+	if len(namespace) == 0 || len(podName) == 0 {
+		return ""
+	}
+	pod, err := client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		log.Warningln("control: Failed to look up the node of kuberhealthy pod", podName, "- checker pods will not avoid it:", err)
+		return ""
+	}
+	return pod.Spec.NodeName
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestKuberhealthyNode ensures the node of the kuberhealthy pod is looked up, and left empty when the pod is not found
+func TestKuberhealthyNode(t *testing.T) {
+	client := fake.NewSimpleClientset(&apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "kuberhealthy-abc", Namespace: "kuberhealthy"},
+		Spec:       apiv1.PodSpec{NodeName: "node-1"},
+	})
+	ctx := context.Background()
+
+	if node := kuberhealthyNode(ctx, client, "kuberhealthy", "kuberhealthy-abc"); node != "node-1" {
+		t.Fatalf("expected the kuberhealthy pod to be found on node-1 but got %q", node)
+	}
+	if node := kuberhealthyNode(ctx, client, "kuberhealthy", "missing"); len(node) != 0 {
+		t.Fatalf("expected no node for a missing pod but got %q", node)
+	}
+	if node := kuberhealthyNode(ctx, client, "", ""); len(node) != 0 {
+		t.Fatalf("expected no node without a pod name but got %q", node)
+	}
+}
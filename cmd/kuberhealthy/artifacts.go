@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// validateArtifacts ensures the artifacts of a report are valid.  Artifacts explain failures, so they are only
+// accepted on failure reports.
+func validateArtifacts(state status.Report) error {
+	if len(state.Artifacts) == 0 {
+		return nil
+	}
+	if state.OK {
+		return fmt.Errorf("report is OK but has %d artifacts", len(state.Artifacts))
+	}
+	return status.ValidateArtifacts(state.Artifacts)
+}
+
+// newArtifacts turns the artifacts of a report into the artifacts stored on its khstate.  Content longer than
+// status.MaxArtifactBytes is cut down to its end so that khstates stay well within the size limit of a resource.
+func newArtifacts(artifacts []status.Artifact) []khstatev1.Artifact {
+	if len(artifacts) == 0 {
+		return nil
+	}
+	stored := make([]khstatev1.Artifact, 0, len(artifacts))
+	for _, a := range artifacts {
+		a = status.TruncateArtifact(a)
+		stored = append(stored, khstatev1.Artifact{Name: a.Name, ContentType: a.ContentType, Content: a.Content, Truncated: a.Truncated})
+	}
+	return stored
+}
+
+// checkDetailPath is the path of the endpoint that serves the full state of a single check or job, including the
+// artifacts of its last failure, at checkDetailPath + namespace/name
+const checkDetailPath = "/api/v2/checks/"
+
+// withoutArtifacts leaves the artifacts out of the details of a check or job.  Artifacts can be several kilobytes for
+// every failing check, so they are only served by the check detail endpoint instead of on every status request.
+func withoutArtifacts(details khstatev1.WorkloadDetails) khstatev1.WorkloadDetails {
+	details.Artifacts = nil
+	return details
+}
+
+// checkDetailHandler serves the full khstate of the check or job named in the request path, so that the artifacts
+// attached to its last failure can be read without running it again
+func (k *Kuberhealthy) checkDetailHandler(w http.ResponseWriter, r *http.Request) error {
+	log.Infoln("Client connected to check detail endpoint from", r.RemoteAddr, r.UserAgent())
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	namespace, name, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, checkDetailPath), "/")
+	if !ok || len(namespace) == 0 || len(name) == 0 || strings.Contains(name, "/") {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("request path must look like " + checkDetailPath + "namespace/name"))
+		return nil
+	}
+
+	state, found := k.stateReflector.State(namespace, sanitizeResourceName(name))
+	if !found || len(state.Spec.AuthoritativePod) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+
+	b, err := json.MarshalIndent(state.Spec, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to marshal details of %s/%s: %w", namespace, name, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(b)
+	if err != nil {
+		log.Warningln("Error writing check details to caller:", err)
+	}
+	return err
+}
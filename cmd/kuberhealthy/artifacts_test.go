@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestValidateArtifacts ensures artifacts are only accepted on failure reports and when they are valid
+func TestValidateArtifacts(t *testing.T) {
+	tooMany := make([]status.Artifact, status.MaxArtifacts+1)
+	for i := range tooMany {
+		tooMany[i] = status.Artifact{Name: "artifact-" + string(rune('a'+i)), Content: "x"}
+	}
+	var testCases = []struct {
+		description string
+		report      status.Report
+		expectErr   bool
+	}{
+		{"no artifacts", status.NewReport([]string{"failed"}), false},
+		{"failure with artifacts", status.Report{Errors: []string{"failed"}, Artifacts: []status.Artifact{{Name: "checker.log", Content: "timed out"}}}, false},
+		{"over-long content", status.Report{Errors: []string{"failed"}, Artifacts: []status.Artifact{{Name: "checker.log", Content: strings.Repeat("x", status.MaxArtifactBytes+1)}}}, false},
+		{"artifacts on an OK report", status.Report{OK: true, Artifacts: []status.Artifact{{Name: "checker.log"}}}, true},
+		{"artifact without a name", status.Report{Errors: []string{"failed"}, Artifacts: []status.Artifact{{Content: "timed out"}}}, true},
+		{"duplicate names", status.Report{Errors: []string{"failed"}, Artifacts: []status.Artifact{{Name: "a"}, {Name: "a"}}}, true},
+		{"too many artifacts", status.Report{Errors: []string{"failed"}, Artifacts: tooMany}, true},
+	}
+	for _, tc := range testCases {
+		err := validateArtifacts(tc.report)
+		if (err != nil) != tc.expectErr {
+			t.Fatalf("%s: returned error %v but expected an error: %t", tc.description, err, tc.expectErr)
+		}
+	}
+}
+
+// TestNewArtifacts ensures over-long artifacts are stored with only the end of their content
+func TestNewArtifacts(t *testing.T) {
+	if artifacts := newArtifacts(nil); artifacts != nil {
+		t.Fatalf("expected no artifacts but got %+v", artifacts)
+	}
+	artifacts := newArtifacts([]status.Artifact{
+		{Name: "checker.log", ContentType: "text/plain", Content: strings.Repeat("x", status.MaxArtifactBytes) + "the end"},
+		{Name: "short.log", Content: "short"},
+	})
+	if len(artifacts) != 2 {
+		t.Fatalf("unexpected artifacts %+v", artifacts)
+	}
+	if !artifacts[0].Truncated || len(artifacts[0].Content) != status.MaxArtifactBytes || !strings.HasSuffix(artifacts[0].Content, "the end") || artifacts[0].ContentType != "text/plain" {
+		t.Fatalf("artifact %s was stored with %d bytes, truncated: %v", artifacts[0].Name, len(artifacts[0].Content), artifacts[0].Truncated)
+	}
+	if artifacts[1].Truncated || artifacts[1].Content != "short" {
+		t.Fatalf("unexpected artifact %+v", artifacts[1])
+	}
+}
+
+// TestCheckDetailHandler ensures the detail endpoint serves the artifacts of a check, which the status page leaves out
+func TestCheckDetailHandler(t *testing.T) {
+	failing := testState("dns-check", "10", time.Now(), "dns failed")
+	failing.Spec.Artifacts = []khstatev1.Artifact{{Name: "checker.log", Content: "lookup timed out"}}
+	neverRan := testState("never-ran", "11", time.Now())
+	neverRan.Spec.AuthoritativePod = ""
+
+	sr := &StateReflector{store: cache.NewStore(cache.MetaNamespaceKeyFunc)}
+	for _, state := range []*khstatev1.KuberhealthyState{failing, neverRan} {
+		if err := sr.store.Add(state); err != nil {
+			t.Fatal("failed to add khstate to the store:", err)
+		}
+	}
+	kh := &Kuberhealthy{stateReflector: sr}
+
+	var testCases = []struct {
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{http.MethodGet, checkDetailPath + "kuberhealthy/dns-check", http.StatusOK},
+		{http.MethodGet, checkDetailPath + "kuberhealthy/DNS-Check", http.StatusOK},
+		{http.MethodPost, checkDetailPath + "kuberhealthy/dns-check", http.StatusMethodNotAllowed},
+		{http.MethodGet, checkDetailPath + "kuberhealthy", http.StatusBadRequest},
+		{http.MethodGet, checkDetailPath + "kuberhealthy/dns-check/extra", http.StatusBadRequest},
+		{http.MethodGet, checkDetailPath + "kuberhealthy/missing", http.StatusNotFound},
+		{http.MethodGet, checkDetailPath + "kuberhealthy/never-ran", http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		err := kh.checkDetailHandler(recorder, httptest.NewRequest(tc.method, tc.path, nil))
+		if err != nil {
+			t.Fatal(tc.path, "returned an error:", err)
+		}
+		if recorder.Code != tc.expectedCode {
+			t.Fatalf("%s %s returned %d but expected %d", tc.method, tc.path, recorder.Code, tc.expectedCode)
+		}
+		if recorder.Code != http.StatusOK {
+			continue
+		}
+		var details khstatev1.WorkloadDetails
+		if err := json.Unmarshal(recorder.Body.Bytes(), &details); err != nil {
+			t.Fatal("failed to decode check details:", err)
+		}
+		if len(details.Artifacts) != 1 || details.Artifacts[0].Content != "lookup timed out" {
+			t.Fatalf("check details have artifacts %+v", details.Artifacts)
+		}
+	}
+
+	delta := stateDelta([]*khstatev1.KuberhealthyState{failing}, "", deltaCursor{}, stateChanges{}, func(string, string) khstatev1.KHWorkload { return khstatev1.KHCheck })
+	if artifacts := delta.CheckDetails["kuberhealthy/dns-check"].Artifacts; artifacts != nil {
+		t.Fatalf("expected the status delta to leave out artifacts, got %+v", artifacts)
+	}
+	if len(failing.Spec.Artifacts) != 1 {
+		t.Fatal("leaving artifacts out of the status delta removed them from the cached khstate")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// defaultMaxConcurrentReports is the default number of check reports that can be handled at once before checker
+// pods are asked to back off
+const defaultMaxConcurrentReports = 50
+
+// defaultReportRetryAfter is how long checker pods are asked to wait before retrying a report when Kuberhealthy is
+// overloaded and the Kubernetes API did not suggest a delay of its own
+const defaultReportRetryAfter = time.Second * 5
+
+// maxConcurrentReports returns the number of check reports that can be handled at once
+func maxConcurrentReports() int {
+	if cfg != nil && cfg.MaxConcurrentReports > 0 {
+		return cfg.MaxConcurrentReports
+	}
+	return defaultMaxConcurrentReports
+}
+
+// acquireReportSlot reserves room to handle a check report.  False is returned when too many reports are already
+// being handled.  Every successful call must be followed by a call to releaseReportSlot.
+func (k *Kuberhealthy) acquireReportSlot() bool {
+	if atomic.AddInt32(&k.reportsInFlight, 1) > int32(maxConcurrentReports()) {
+		atomic.AddInt32(&k.reportsInFlight, -1)
+		return false
+	}
+	return true
+}
+
+// releaseReportSlot frees room reserved by acquireReportSlot
+func (k *Kuberhealthy) releaseReportSlot() {
+	atomic.AddInt32(&k.reportsInFlight, -1)
+}
+
+// writeRetryAfter tells the client that Kuberhealthy is overloaded and that it should retry after the supplied delay
+func writeRetryAfter(w http.ResponseWriter, delay time.Duration) {
+	w.Header().Set("Retry-After", retryAfterSeconds(delay))
+	w.WriteHeader(http.StatusTooManyRequests)
+}
+
+// retryAfterSeconds formats a delay as the whole number of seconds of a Retry-After header, rounding up to at least
+// one second
+func retryAfterSeconds(delay time.Duration) string {
+	seconds := int((delay + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
+
+// retryAfterForError determines if an error from the Kubernetes API means that Kuberhealthy is being throttled.  If
+// so, the delay clients should wait before retrying is returned.
+func retryAfterForError(err error) (time.Duration, bool) {
+	if !k8sErrors.IsTooManyRequests(err) && !k8sErrors.IsServerTimeout(err) {
+		return 0, false
+	}
+	seconds, ok := k8sErrors.SuggestsClientDelay(err)
+	if ok && seconds > 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	return defaultReportRetryAfter, true
+}
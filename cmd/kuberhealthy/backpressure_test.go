@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// TestReportSlots ensures that no more than the maximum number of reports can be handled at once
+func TestReportSlots(t *testing.T) {
+
+	kh := &Kuberhealthy{}
+	for i := 0; i < maxConcurrentReports(); i++ {
+		if !kh.acquireReportSlot() {
+			t.Fatalf("failed to acquire report slot %d of %d", i+1, maxConcurrentReports())
+		}
+	}
+	if kh.acquireReportSlot() {
+		t.Fatal("acquired more report slots than the maximum")
+	}
+	kh.releaseReportSlot()
+	if !kh.acquireReportSlot() {
+		t.Fatal("failed to acquire a report slot after one was released")
+	}
+}
+
+// TestWriteRetryAfter ensures that the Retry-After header is rounded up to whole seconds
+func TestWriteRetryAfter(t *testing.T) {
+
+	var testCases = []struct {
+		delay  time.Duration
+		header string
+	}{
+		{time.Second * 5, "5"},
+		{time.Millisecond * 1500, "2"},
+		{0, "1"},
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		writeRetryAfter(recorder, tc.delay)
+		if recorder.Code != http.StatusTooManyRequests {
+			t.Fatalf("writeRetryAfter wrote code %d but expected %d", recorder.Code, http.StatusTooManyRequests)
+		}
+		if recorder.Header().Get("Retry-After") != tc.header {
+			t.Fatalf("writeRetryAfter for %s wrote `%s` but expected `%s`", tc.delay, recorder.Header().Get("Retry-After"), tc.header)
+		}
+	}
+}
+
+// TestRetryAfterForError ensures that throttling errors from the Kubernetes API are detected
+func TestRetryAfterForError(t *testing.T) {
+
+	resource := schema.GroupResource{Group: stateCRDGroup, Resource: stateCRDResource}
+	var testCases = []struct {
+		err       error
+		throttled bool
+		delay     time.Duration
+	}{
+		{k8sErrors.NewTooManyRequests("slow down", 10), true, time.Second * 10},
+		{fmt.Errorf("failed to store check state: %w", k8sErrors.NewTooManyRequests("slow down", 3)), true, time.Second * 3},
+		{k8sErrors.NewServerTimeout(resource, "update", 0), true, defaultReportRetryAfter},
+		{k8sErrors.NewNotFound(resource, "check"), false, 0},
+		{errors.New("something else"), false, 0},
+	}
+
+	for _, tc := range testCases {
+		delay, throttled := retryAfterForError(tc.err)
+		if throttled != tc.throttled || delay != tc.delay {
+			t.Fatalf("retryAfterForError(%s) returned %s %t but expected %s %t", tc.err, delay, throttled, tc.delay, tc.throttled)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// batchApplyPath is the path of the endpoint that validates and applies a batch of khchecks
+const batchApplyPath = "/api/v2/checks:batchApply"
+
+// batchApplyFieldManager is the field manager used when applying khchecks with server side apply
+const batchApplyFieldManager = "kuberhealthy-batch-apply"
+
+// maxBatchApplyBytes is the largest batch of khcheck manifests accepted
+const maxBatchApplyBytes = 4 << 20
+
+// The result of each khcheck of a batch
+const (
+	batchItemValid      = "valid"      // the khcheck passed validation and the dry run, but was not applied
+	batchItemInvalid    = "invalid"    // the khcheck failed validation
+	batchItemForbidden  = "forbidden"  // the caller is not allowed to apply khchecks in its namespace
+	batchItemRejected   = "rejected"   // the API server refused the khcheck
+	batchItemApplied    = "applied"    // the khcheck was applied
+	batchItemRolledBack = "rolledBack" // the khcheck was applied, then put back as it was because another khcheck failed
+	batchItemSkipped    = "skipped"    // the khcheck was not applied because another khcheck failed first
+)
+
+// batchApplyReport is the result of a batch apply request.  Batches are applied as a whole: when any khcheck is
+// invalid or refused, none of them are left applied.
+type batchApplyReport struct {
+	Applied bool // every khcheck of the batch was applied
+	DryRun  bool `json:",omitempty"` // the batch was only validated
+	Items   []batchApplyItem
+}
+
+// batchApplyItem is the result of one khcheck of a batch, in the order the batch listed them
+type batchApplyItem struct {
+	Name      string
+	Namespace string
+	Result    string
+	Errors    []string `json:",omitempty"`
+}
+
+// batchApplyEnabled tells if khchecks can be applied through the batch apply endpoint
+func batchApplyEnabled() bool {
+	return cfg != nil && cfg.EnableBatchApply
+}
+
+// batchApplyHandler validates a batch of khcheck manifests and applies them all or none of them.  Callers
+// authenticate with a Kubernetes bearer token and must be allowed to patch khchecks in the namespace of every khcheck
+// of the batch.  Batches are only validated when the dryRun query parameter is true.
+func (k *Kuberhealthy) batchApplyHandler(w http.ResponseWriter, r *http.Request) error {
+	if !batchApplyEnabled() {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	user, err := authenticateBearerToken(r.Context(), kubernetesClient, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return fmt.Errorf("refused batch apply from %s: %w", r.RemoteAddr, err)
+	}
+
+	b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBatchApplyBytes))
+	if err != nil {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return fmt.Errorf("failed to read batch apply request from %s: %w", r.RemoteAddr, err)
+	}
+	checks, err := parseCheckBundle(b)
+	if err == nil && len(checks) == 0 {
+		err = errors.New("batch has no khchecks")
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error()))
+		return nil
+	}
+
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+	allowed := func(namespace string, verbs []string) (bool, error) {
+		return canApplyChecks(r.Context(), kubernetesClient, user, namespace, verbs)
+	}
+	report, code := applyCheckBatch(r.Context(), dynamicClient, checks, allowed, dryRun)
+	log.Infoln("batchApply: Batch of", len(checks), "khchecks from", user.Username, "answered with", code, "applied:", report.Applied, "dry run:", dryRun)
+
+	out, err := json.Marshal(report)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to marshal batch apply report: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, err = w.Write(out)
+	if err != nil {
+		return fmt.Errorf("failed to write batch apply report: %w", err)
+	}
+	return nil
+}
+
+// applyCheckBatch validates a batch of khchecks, dry runs them against the API server and then applies them.  When a
+// khcheck fails to apply, the khchecks applied before it are put back as they were.  Returns the report of the batch
+// along with the status code to answer with.
+func applyCheckBatch(ctx context.Context, client dynamic.Interface, checks []*unstructured.Unstructured, allowed func(namespace string, verbs []string) (bool, error), dryRun bool) (batchApplyReport, int) {
+	report := batchApplyReport{DryRun: dryRun}
+	for _, check := range checks {
+		report.Items = append(report.Items, batchApplyItem{Name: check.GetName(), Namespace: check.GetNamespace(), Result: batchItemValid})
+	}
+
+	// every khcheck is validated and authorized before any of them is sent to the API server
+	code := http.StatusOK
+	seen := make(map[string]bool)
+	for i, check := range checks {
+		item := &report.Items[i]
+		item.Errors = checkManifestErrors(check)
+		key := check.GetNamespace() + "/" + check.GetName()
+		if seen[key] {
+			item.Errors = append(item.Errors, "khcheck "+key+" is listed more than once")
+		}
+		seen[key] = true
+		if len(item.Errors) > 0 {
+			item.Result = batchItemInvalid
+			code = http.StatusUnprocessableEntity
+		}
+	}
+	if code != http.StatusOK {
+		return report, code
+	}
+
+	// applying a khcheck that does not exist yet creates it, so the caller must be allowed to create khchecks as well
+	for i, check := range checks {
+		item := &report.Items[i]
+		verbs := []string{"patch"}
+		exists, err := checkExists(ctx, client, check)
+		if err == nil && !exists {
+			verbs = append(verbs, "create")
+		}
+		ok := false
+		if err == nil {
+			ok, err = allowed(check.GetNamespace(), verbs)
+		}
+		if err != nil {
+			item.Result = batchItemForbidden
+			item.Errors = []string{"failed to authorize: " + err.Error()}
+			code = http.StatusInternalServerError
+			continue
+		}
+		if !ok {
+			item.Result = batchItemForbidden
+			item.Errors = []string{"not allowed to " + strings.Join(verbs, " and ") + " khchecks in namespace " + check.GetNamespace()}
+			if code == http.StatusOK {
+				code = http.StatusForbidden
+			}
+		}
+	}
+	if code != http.StatusOK {
+		return report, code
+	}
+
+	// a server side dry run catches what only the API server checks, such as the schema of the CRD
+	rejected := false
+	for i, check := range checks {
+		_, err := applyCheck(ctx, client, check, true)
+		if err != nil {
+			report.Items[i].Result = batchItemRejected
+			report.Items[i].Errors = []string{err.Error()}
+			rejected = true
+		}
+	}
+	if rejected {
+		return report, http.StatusUnprocessableEntity
+	}
+	if dryRun {
+		return report, http.StatusOK
+	}
+
+	var previous []*unstructured.Unstructured
+	for i, check := range checks {
+		prior, err := applyCheck(ctx, client, check, false)
+		if err == nil {
+			report.Items[i].Result = batchItemApplied
+			previous = append(previous, prior)
+			continue
+		}
+
+		log.Errorln("batchApply: Failed to apply khcheck", check.GetNamespace()+"/"+check.GetName()+". Rolling back the batch:", err)
+		report.Items[i].Result = batchItemRejected
+		report.Items[i].Errors = []string{err.Error()}
+		for j := i + 1; j < len(checks); j++ {
+			report.Items[j].Result = batchItemSkipped
+		}
+		for j := i - 1; j >= 0; j-- {
+			report.Items[j].Result = batchItemRolledBack
+			err := restoreCheck(ctx, client, checks[j], previous[j])
+			if err != nil {
+				log.Errorln("batchApply: Failed to roll back khcheck", checks[j].GetNamespace()+"/"+checks[j].GetName()+":", err)
+				report.Items[j].Result = batchItemApplied
+				report.Items[j].Errors = []string{"failed to roll back: " + err.Error()}
+			}
+		}
+		return report, http.StatusConflict
+	}
+
+	report.Applied = true
+	return report, http.StatusOK
+}
+
+// checkManifestErrors validates a khcheck manifest with the rules Kuberhealthy uses when it loads a khcheck, so that
+// problems are reported before the khcheck is applied rather than on its first run
+func checkManifestErrors(check *unstructured.Unstructured) []string {
+	var errs []string
+	if len(check.GetName()) == 0 {
+		errs = append(errs, "metadata.name is required")
+	}
+	if len(check.GetNamespace()) == 0 {
+		errs = append(errs, "metadata.namespace is required")
+	}
+
+	khCheck, err := convertUnstructuredKhCheck(*check)
+	if err != nil {
+		return append(errs, err.Error())
+	}
+	c := external.New(nil, &khCheck, nil, nil, "")
+	errs = append(errs, checkModeErrors(c)...)
+	errs = append(errs, podTemplateErrors(khCheck.Spec.PodSpec, khCheck.Spec.PodTemplate)...)
+	errs = append(errs, isolatedNamespaceErrors(c.IsolatedNamespace)...)
+
+	durations := []struct {
+		field string
+		value string
+	}{
+		{"runInterval", khCheck.Spec.RunInterval},
+		{"timeout", khCheck.Spec.Timeout},
+		{"maxDeadlineExtension", khCheck.Spec.MaxDeadlineExtension},
+		{"startTimeout", khCheck.Spec.StartTimeout},
+	}
+	for _, d := range durations {
+		if len(d.value) == 0 {
+			continue
+		}
+		_, err := parseSpecDuration(d.field, d.value, 0)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(khCheck.Spec.HeartbeatTimeout) > 0 {
+		_, err := parseHeartbeatTimeout(khCheck.Spec.HeartbeatTimeout)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	// resident checks hand their runs to long lived checkers and have no checker pod
+	if !c.Resident {
+		err = c.ValidatePodSpec()
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	return errs
+}
+
+// checkExists tells if a khcheck already exists
+func checkExists(ctx context.Context, client dynamic.Interface, check *unstructured.Unstructured) (bool, error) {
+	gvr := schema.GroupVersionResource{Group: checkCRDGroup, Version: checkCRDVersion, Resource: checkCRDResource}
+	_, err := client.Resource(gvr).Namespace(check.GetNamespace()).Get(ctx, check.GetName(), metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get khcheck %s: %w", check.GetName(), err)
+	}
+	return true, nil
+}
+
+// applyCheck applies a khcheck with server side apply and returns it as it was before, or nil if it did not exist
+func applyCheck(ctx context.Context, client dynamic.Interface, check *unstructured.Unstructured, dryRun bool) (*unstructured.Unstructured, error) {
+	gvr := schema.GroupVersionResource{Group: checkCRDGroup, Version: checkCRDVersion, Resource: checkCRDResource}
+	resource := client.Resource(gvr).Namespace(check.GetNamespace())
+
+	prior, err := resource.Get(ctx, check.GetName(), metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		prior = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get khcheck %s: %w", check.GetName(), err)
+	}
+
+	data, err := json.Marshal(check.Object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode khcheck %s: %w", check.GetName(), err)
+	}
+	force := true
+	patchOptions := metav1.PatchOptions{FieldManager: batchApplyFieldManager, Force: &force}
+	if dryRun {
+		patchOptions.DryRun = []string{metav1.DryRunAll}
+	}
+	_, err = resource.Patch(ctx, check.GetName(), types.ApplyPatchType, data, patchOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply khcheck %s: %w", check.GetName(), err)
+	}
+	return prior, nil
+}
+
+// restoreCheck puts a khcheck applied by a batch back as it was before, or deletes it if it did not exist
+func restoreCheck(ctx context.Context, client dynamic.Interface, check *unstructured.Unstructured, prior *unstructured.Unstructured) error {
+	gvr := schema.GroupVersionResource{Group: checkCRDGroup, Version: checkCRDVersion, Resource: checkCRDResource}
+	resource := client.Resource(gvr).Namespace(check.GetNamespace())
+	if prior == nil {
+		err := resource.Delete(ctx, check.GetName(), metav1.DeleteOptions{})
+		if k8sErrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	current, err := resource.Get(ctx, check.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	restored := prior.DeepCopy()
+	restored.SetResourceVersion(current.GetResourceVersion())
+	_, err = resource.Update(ctx, restored, metav1.UpdateOptions{})
+	return err
+}
+
+// authenticateBearerToken authenticates the bearer token of a request with a TokenReview and returns its user
+func authenticateBearerToken(ctx context.Context, client kubernetes.Interface, r *http.Request) (authenticationv1.UserInfo, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token = strings.TrimSpace(token)
+	if !ok || len(token) == 0 {
+		return authenticationv1.UserInfo{}, errors.New("request has no bearer token")
+	}
+
+	review, err := client.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token}}, metav1.CreateOptions{})
+	if err != nil {
+		return authenticationv1.UserInfo{}, fmt.Errorf("failed to review token: %w", err)
+	}
+	if !review.Status.Authenticated {
+		return authenticationv1.UserInfo{}, fmt.Errorf("token was not authenticated: %s", review.Status.Error)
+	}
+	return review.Status.User, nil
+}
+
+// canApplyChecks tells if a user is allowed to use every one of the supplied verbs on khchecks in a namespace
+func canApplyChecks(ctx context.Context, client kubernetes.Interface, user authenticationv1.UserInfo, namespace string, verbs []string) (bool, error) {
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	for _, verb := range verbs {
+		review := &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				User:   user.Username,
+				UID:    user.UID,
+				Groups: user.Groups,
+				Extra:  extra,
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: namespace,
+					Verb:      verb,
+					Group:     checkCRDGroup,
+					Resource:  checkCRDResource,
+				},
+			},
+		}
+		review, err := client.AuthorizationV1().SubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return false, err
+		}
+		if !review.Status.Allowed {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// testBatchCheck returns a khcheck that passes validation
+func testBatchCheck(namespace string, name string, runInterval string) *unstructured.Unstructured {
+	return testKHResource("KuberhealthyCheck", namespace, name, map[string]interface{}{
+		"runInterval": runInterval,
+		"timeout":     "1m",
+		"podSpec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": "main", "image": "kuberhealthy/dns-resolution-check:v1.5.0"}},
+		},
+	})
+}
+
+// newBatchApplyClient returns a fake dynamic client that handles server side apply.  The fake does not tell dry runs
+// apart, so the first apply of each khcheck is treated as its dry run and not stored.  Applies of the khcheck named
+// broken fail after its dry run.
+func newBatchApplyClient(t *testing.T) *dynamicfake.FakeDynamicClient {
+	gvr := schema.GroupVersionResource{Group: checkCRDGroup, Version: checkCRDVersion, Resource: checkCRDResource}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "KuberhealthyCheckList"})
+	applies := make(map[string]int)
+	client.PrependReactor("patch", checkCRDResource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		key := patch.GetNamespace() + "/" + patch.GetName()
+		applies[key]++
+		obj := &unstructured.Unstructured{}
+		err := json.Unmarshal(patch.GetPatch(), &obj.Object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if applies[key] == 1 {
+			return true, obj, nil
+		}
+		if patch.GetName() == "broken" {
+			return true, nil, errors.New("admission webhook denied the request")
+		}
+		_, err = client.Tracker().Get(gvr, patch.GetNamespace(), patch.GetName())
+		if err != nil {
+			return true, obj, client.Tracker().Create(gvr, obj, patch.GetNamespace())
+		}
+		return true, obj, client.Tracker().Update(gvr, obj, patch.GetNamespace())
+	})
+	return client
+}
+
+// TestCheckManifestErrors ensures khchecks are validated with the rules used when khchecks are loaded
+func TestCheckManifestErrors(t *testing.T) {
+	if errs := checkManifestErrors(testBatchCheck("kuberhealthy", "dns", "5m")); len(errs) != 0 {
+		t.Fatalf("valid khcheck had errors: %v", errs)
+	}
+
+	invalid := testKHResource("KuberhealthyCheck", "", "dns", map[string]interface{}{
+		"runInterval":   "often",
+		"timeout":       "1m",
+		"runOnAllNodes": true,
+		"runPerZone":    true,
+	})
+	errs := strings.Join(checkManifestErrors(invalid), "\n")
+	for _, expected := range []string{"metadata.namespace is required", "spec.runInterval", "runOnAllNodes and runPerZone", "no containers"} {
+		if !strings.Contains(errs, expected) {
+			t.Fatalf("expected an error about %q but got:\n%s", expected, errs)
+		}
+	}
+
+	resident := testKHResource("KuberhealthyCheck", "kuberhealthy", "resident", map[string]interface{}{"runInterval": "1m", "timeout": "30s", "resident": true})
+	if errs := checkManifestErrors(resident); len(errs) != 0 {
+		t.Fatalf("resident khcheck without a pod spec had errors: %v", errs)
+	}
+
+	podTemplate := map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": map[string]interface{}{"team": "dns"}},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": "main", "image": "kuberhealthy/dns-resolution-check:v1.5.0"}},
+		},
+	}
+	templated := testKHResource("KuberhealthyCheck", "kuberhealthy", "templated", map[string]interface{}{"runInterval": "1m", "timeout": "30s", "podTemplate": podTemplate})
+	if errs := checkManifestErrors(templated); len(errs) != 0 {
+		t.Fatalf("khcheck with a pod template had errors: %v", errs)
+	}
+
+	both := testBatchCheck("kuberhealthy", "both", "5m")
+	unstructured.SetNestedField(both.Object, podTemplate, "spec", "podTemplate")
+	errs = strings.Join(checkManifestErrors(both), "\n")
+	if !strings.Contains(errs, "podSpec and podTemplate can not both be set") {
+		t.Fatalf("expected an error about setting both podSpec and podTemplate but got:\n%s", errs)
+	}
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg = &Config{IsolatedNamespaceRoles: []string{"kuberhealthy-test-resources"}}
+	for role, allowed := range map[string]bool{"": true, "edit": true, "kuberhealthy-test-resources": true, "cluster-admin": false} {
+		isolated := testBatchCheck("kuberhealthy", "isolated", "5m")
+		unstructured.SetNestedField(isolated.Object, map[string]interface{}{"enabled": true, "clusterRole": role}, "spec", "isolatedNamespace")
+		errs = strings.Join(checkManifestErrors(isolated), "\n")
+		if allowed != !strings.Contains(errs, "isolatedNamespace.clusterRole") {
+			t.Fatalf("isolated namespace cluster role %q should be allowed: %t but got errors:\n%s", role, allowed, errs)
+		}
+	}
+}
+
+// TestApplyCheckBatch ensures batches are applied as a whole and rolled back when a khcheck fails to apply
+func TestApplyCheckBatch(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: checkCRDGroup, Version: checkCRDVersion, Resource: checkCRDResource}
+	allowAll := func(namespace string, verbs []string) (bool, error) { return true, nil }
+	ctx := context.Background()
+
+	// invalid and forbidden batches are not sent to the API server
+	client := newBatchApplyClient(t)
+	invalid := testBatchCheck("kuberhealthy", "dns", "never")
+	report, code := applyCheckBatch(ctx, client, []*unstructured.Unstructured{testBatchCheck("kuberhealthy", "ok", "5m"), invalid}, allowAll, false)
+	if code != http.StatusUnprocessableEntity || report.Applied || report.Items[0].Result != batchItemValid || report.Items[1].Result != batchItemInvalid {
+		t.Fatalf("invalid batch was answered with %d: %+v", code, report)
+	}
+	onlyKuberhealthy := func(namespace string, verbs []string) (bool, error) { return namespace == "kuberhealthy", nil }
+	report, code = applyCheckBatch(ctx, client, []*unstructured.Unstructured{testBatchCheck("payments", "dns", "5m")}, onlyKuberhealthy, false)
+	if code != http.StatusForbidden || report.Items[0].Result != batchItemForbidden {
+		t.Fatalf("forbidden batch was answered with %d: %+v", code, report)
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() != "get" {
+			t.Fatalf("invalid and forbidden batches reached the API server: %v", client.Actions())
+		}
+	}
+
+	// creating a khcheck takes permission to create khchecks, not just to patch them
+	_, err := client.Resource(gvr).Namespace("kuberhealthy").Create(ctx, testBatchCheck("kuberhealthy", "existing", "5m"), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	onlyPatch := func(namespace string, verbs []string) (bool, error) {
+		for _, verb := range verbs {
+			if verb != "patch" {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	report, code = applyCheckBatch(ctx, client, []*unstructured.Unstructured{testBatchCheck("kuberhealthy", "existing", "10m"), testBatchCheck("kuberhealthy", "new", "5m")}, onlyPatch, true)
+	if code != http.StatusForbidden || report.Items[0].Result != batchItemValid || report.Items[1].Result != batchItemForbidden {
+		t.Fatalf("batch creating a khcheck without permission to create khchecks was answered with %d: %+v", code, report)
+	}
+	if !strings.Contains(strings.Join(report.Items[1].Errors, "\n"), "not allowed to patch and create khchecks") {
+		t.Fatalf("expected the forbidden khcheck to name the verbs it needs but got: %v", report.Items[1].Errors)
+	}
+
+	// dry runs validate the batch without applying it
+	report, code = applyCheckBatch(ctx, client, []*unstructured.Unstructured{testBatchCheck("kuberhealthy", "dns", "5m")}, allowAll, true)
+	if code != http.StatusOK || report.Applied || !report.DryRun || report.Items[0].Result != batchItemValid {
+		t.Fatalf("dry run was answered with %d: %+v", code, report)
+	}
+	if _, err := client.Resource(gvr).Namespace("kuberhealthy").Get(ctx, "dns", metav1.GetOptions{}); err == nil {
+		t.Fatal("dry run applied a khcheck")
+	}
+
+	// a khcheck failing to apply rolls back the khchecks applied before it
+	client = newBatchApplyClient(t)
+	_, err = client.Resource(gvr).Namespace("kuberhealthy").Create(ctx, testBatchCheck("kuberhealthy", "dns", "5m"), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := []*unstructured.Unstructured{testBatchCheck("kuberhealthy", "dns", "10m"), testBatchCheck("kuberhealthy", "pods", "5m"), testBatchCheck("kuberhealthy", "broken", "5m"), testBatchCheck("kuberhealthy", "nodes", "5m")}
+	report, code = applyCheckBatch(ctx, client, batch, allowAll, false)
+	expected := []string{batchItemRolledBack, batchItemRolledBack, batchItemRejected, batchItemSkipped}
+	if code != http.StatusConflict || report.Applied {
+		t.Fatalf("failed batch was answered with %d: %+v", code, report)
+	}
+	for i, result := range expected {
+		if report.Items[i].Result != result {
+			t.Fatalf("khcheck %s had result %s but expected %s", report.Items[i].Name, report.Items[i].Result, result)
+		}
+	}
+	dns, err := client.Resource(gvr).Namespace("kuberhealthy").Get(ctx, "dns", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runInterval, _, _ := unstructured.NestedString(dns.Object, "spec", "runInterval"); runInterval != "5m" {
+		t.Fatalf("rolled back khcheck has a run interval of %s", runInterval)
+	}
+	if _, err := client.Resource(gvr).Namespace("kuberhealthy").Get(ctx, "pods", metav1.GetOptions{}); err == nil {
+		t.Fatal("khcheck created by a rolled back batch was not deleted")
+	}
+
+	// batches without failures are applied
+	report, code = applyCheckBatch(ctx, client, batch[:2], allowAll, false)
+	if code != http.StatusOK || !report.Applied || report.Items[0].Result != batchItemApplied || report.Items[1].Result != batchItemApplied {
+		t.Fatalf("batch was answered with %d: %+v", code, report)
+	}
+}
+
+// TestCanApplyChecks ensures a user must be allowed every verb asked for with a subject access review for each
+func TestCanApplyChecks(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	var reviewed []string
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		reviewed = append(reviewed, review.Spec.ResourceAttributes.Verb)
+		review.Status.Allowed = review.Spec.ResourceAttributes.Verb == "patch"
+		return true, review, nil
+	})
+	user := authenticationv1.UserInfo{Username: "system:serviceaccount:ci:deployer"}
+
+	var testCases = []struct {
+		verbs    []string
+		expected bool
+	}{
+		{[]string{"patch"}, true},
+		{[]string{"patch", "create"}, false},
+	}
+	for _, tc := range testCases {
+		reviewed = nil
+		allowed, err := canApplyChecks(context.Background(), client, user, "kuberhealthy", tc.verbs)
+		if err != nil {
+			t.Fatalf("%v: failed to review access: %s", tc.verbs, err)
+		}
+		if allowed != tc.expected || strings.Join(reviewed, ",") != strings.Join(tc.verbs, ",") {
+			t.Fatalf("%v: reviewed %v and allowed %t but expected %t", tc.verbs, reviewed, allowed, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// maxBulkReports is the most reports accepted in a single request to the bulk report endpoint
+const maxBulkReports = 500
+
+// maxBulkReportBytes is the largest request body accepted by the bulk report endpoint
+const maxBulkReportBytes = 8 << 20
+
+// decodeBulkReports reads a batch of reports from a request body.  Returns the status code to refuse the batch with
+// when it can't be handled.
+func decodeBulkReports(body io.Reader) ([]status.BulkReport, int, error) {
+	var reports []status.BulkReport
+	err := json.NewDecoder(io.LimitReader(body, maxBulkReportBytes)).Decode(&reports)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("failed to decode bulk reports: %w", err)
+	}
+	if len(reports) == 0 {
+		return nil, http.StatusBadRequest, errors.New("no reports were sent")
+	}
+	if len(reports) > maxBulkReports {
+		return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("%d reports were sent but at most %d are accepted at once", len(reports), maxBulkReports)
+	}
+	return reports, http.StatusOK, nil
+}
+
+// bulkReportTarget returns the run a report in a batch is for.  Reports that don't name a namespace are for checks
+// in the namespace of the reporting pod.
+func bulkReportTarget(report status.BulkReport, callerNamespace string) (PodReportInfo, error) {
+	target := PodReportInfo{Name: report.Check, Namespace: report.Namespace, UUID: report.UUID}
+	if len(target.Namespace) == 0 {
+		target.Namespace = callerNamespace
+	}
+	if len(target.Name) == 0 || len(target.Namespace) == 0 {
+		return target, errors.New("report did not name its check")
+	}
+	if len(target.UUID) == 0 {
+		return target, errors.New("report did not include its run uuid")
+	}
+	return target, nil
+}
+
+// bulkCheckReportHandler handles batches of reports from agent style checkers that evaluate many checks per cycle.
+// The calling pod is validated the same way as single reports.  Each report in the batch must then carry the run UUID
+// its check expects, and is recorded the same way /externalCheckStatus records it.  The batch is answered with the
+// outcome of each report.
+func (k *Kuberhealthy) bulkCheckReportHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+	reportRequestID := getRequestID(r)
+	w.Header().Set(external.KHRequestIDHeader, reportRequestID)
+	requestID := "web: " + reportRequestID
+	w.Header().Set(external.KHReportVersionsHeader, status.FormatReportVersions(status.SupportedReportVersions))
+	ctx := r.Context()
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBulkReportBytes))
+	if err != nil {
+		k.externalCheckReportHandlerLog(requestID, "Failed to read bulk report body from", r.RemoteAddr+":", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+	reports, code, err := decodeBulkReports(bytes.NewReader(body))
+	if err != nil {
+		k.externalCheckReportHandlerLog(requestID, "Refusing bulk report from", r.RemoteAddr+":", err)
+		w.WriteHeader(code)
+		return nil
+	}
+	k.externalCheckReportHandlerLog(requestID, "Client connected to bulk report handler with", len(reports), "reports from", r.UserAgent())
+
+	// the whole batch counts as one report against the number handled at once
+	if !k.acquireReportSlot() {
+		k.externalCheckReportHandlerLog(requestID, "Too many reports are being handled. Asking client to retry in", defaultReportRetryAfter)
+		writeRetryAfter(w, defaultReportRetryAfter)
+		return nil
+	}
+	defer k.releaseReportSlot()
+
+	// the caller must be a checker pod, though its own run may since have timed out or been overtaken
+	caller, validated, err := k.validateUsingRequestHeader(ctx, r)
+	if !validated && !errors.Is(err, errLateReport) && !errors.Is(err, errOvertakenReport) {
+		caller, err = k.validatePodReportBySourceIP(ctx, r)
+	}
+	if err != nil && !errors.Is(err, errLateReport) && !errors.Is(err, errOvertakenReport) {
+		k.externalCheckReportHandlerLog(requestID, "Failed to validate bulk report from", r.RemoteAddr+":", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+	requestID = requestID + " (" + caller.Namespace + "/" + caller.Name + ")"
+
+	// when signed reports are required, the batch must be signed with the signing key of the calling pod's run
+	err = verifyReportSignature(r, caller.UUID, body)
+	if err != nil {
+		k.externalCheckReportHandlerLog(requestID, "Refusing bulk report:", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil
+	}
+
+	var retryAfter time.Duration
+	results := make([]status.BulkReportResult, 0, len(reports))
+	for i, report := range reports {
+		target, err := bulkReportTarget(report, caller.Namespace)
+		result := status.BulkReportResult{Check: target.Name, Namespace: target.Namespace, UUID: target.UUID}
+
+		// once the Kubernetes API throttles khstate writes, the rest of the batch is left for the client to retry
+		if err == nil && retryAfter > 0 {
+			result.Code = http.StatusTooManyRequests
+			results = append(results, result)
+			continue
+		}
+
+		var lateReport, overtakenReport bool
+		if err == nil {
+			var whitelisted bool
+			whitelisted, err = k.isUUIDWhitelistedForCheck(target.Name, target.Namespace, target.UUID)
+			if err == nil && !whitelisted {
+				err = k.unlistedRunError(target.Name, target.Namespace, target.UUID)
+				lateReport = errors.Is(err, errLateReport)
+				overtakenReport = errors.Is(err, errOvertakenReport)
+				if lateReport || overtakenReport {
+					err = nil
+				}
+			}
+		}
+		if err != nil {
+			k.externalCheckReportHandlerLog(requestID, "Refusing bulk report for", target.Namespace+"/"+target.Name, "with uuid", target.UUID+":", err)
+			result.Code = http.StatusBadRequest
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		itemRequestID := reportRequestID + "-" + strconv.Itoa(i)
+		result.Code, retryAfter, err = k.recordReport(requestID+" ["+target.Namespace+"/"+target.Name+"]", itemRequestID, target, report.Report, lateReport, overtakenReport)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	b, err := json.Marshal(results)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to marshal bulk report results: %w", err)
+	}
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(b)
+	return err
+}
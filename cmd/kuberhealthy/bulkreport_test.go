@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestDecodeBulkReports ensures malformed, empty and oversized batches are refused
+func TestDecodeBulkReports(t *testing.T) {
+
+	tooMany := "[" + strings.Repeat(`{"Check":"c","UUID":"u"},`, maxBulkReports) + `{"Check":"c","UUID":"u"}]`
+
+	var testCases = []struct {
+		body  string
+		code  int
+		count int
+	}{
+		{`[{"check":"dns","uuid":"1234","report":{"OK":true}}]`, http.StatusOK, 1},
+		{`[]`, http.StatusBadRequest, 0},
+		{`{"check":"dns"}`, http.StatusBadRequest, 0},
+		{tooMany, http.StatusRequestEntityTooLarge, 0},
+	}
+
+	for _, tc := range testCases {
+		reports, code, err := decodeBulkReports(strings.NewReader(tc.body))
+		if code != tc.code || len(reports) != tc.count {
+			t.Fatalf("decoding %.40s returned code %d with %d reports (%v) but expected %d with %d", tc.body, code, len(reports), err, tc.code, tc.count)
+		}
+		if code == http.StatusOK && (reports[0].Check != "dns" || reports[0].UUID != "1234" || !reports[0].Report.OK) {
+			t.Fatalf("report was decoded incorrectly: %+v", reports[0])
+		}
+	}
+}
+
+// TestBulkReportTarget ensures reports default to the namespace of the reporting pod and must name their run
+func TestBulkReportTarget(t *testing.T) {
+
+	var testCases = []struct {
+		report    status.BulkReport
+		namespace string
+		valid     bool
+	}{
+		{status.BulkReport{Check: "dns", UUID: "1234"}, "kuberhealthy", true},
+		{status.BulkReport{Check: "dns", Namespace: "other", UUID: "1234"}, "other", true},
+		{status.BulkReport{UUID: "1234"}, "kuberhealthy", false},
+		{status.BulkReport{Check: "dns"}, "kuberhealthy", false},
+	}
+
+	for _, tc := range testCases {
+		target, err := bulkReportTarget(tc.report, "kuberhealthy")
+		if (err == nil) != tc.valid || target.Namespace != tc.namespace {
+			t.Fatalf("target of %+v was %+v (%v) but expected namespace %s and valid %t", tc.report, target, err, tc.namespace, tc.valid)
+		}
+	}
+}
+
+// TestBulkCheckReportHandler ensures batches are refused before the caller is looked up when they can't be handled
+func TestBulkCheckReportHandler(t *testing.T) {
+
+	kh := &Kuberhealthy{}
+
+	var testCases = []struct {
+		method string
+		body   string
+		code   int
+	}{
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "not json", http.StatusBadRequest},
+		{http.MethodPost, "[]", http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, "/bulkCheckStatus", strings.NewReader(tc.body))
+		err := kh.bulkCheckReportHandler(recorder, req)
+		if err != nil {
+			t.Fatalf("%s with body %s returned an error: %s", tc.method, tc.body, err)
+		}
+		if recorder.Code != tc.code {
+			t.Fatalf("%s with body %s returned code %d but expected %d", tc.method, tc.body, recorder.Code, tc.code)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// cancelHandler tells a checker pod whether Kuberhealthy still wants the result of the run UUID at the end of the
+// request path.  Long running checks poll this to learn that their run was replaced, their check was removed or
+// stopped, or that they timed out, so they can clean up their test resources and exit early.
+func (k *Kuberhealthy) cancelHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	runUUID := strings.TrimPrefix(r.URL.Path, "/cancel/")
+	if len(runUUID) == 0 || strings.Contains(runUUID, "/") {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+
+	run, known := k.runTracker.Get(runUUID)
+	if !known {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+
+	b, err := json.Marshal(run.Cancellation(time.Now()))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to marshal cancellation of run %s: %w", runUUID, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(b)
+	if err != nil {
+		return fmt.Errorf("failed to write cancellation of run %s: %w", runUUID, err)
+	}
+	return nil
+}
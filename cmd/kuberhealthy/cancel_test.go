@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestCancelHandler ensures checker pods can learn that their run was canceled by polling its UUID
+func TestCancelHandler(t *testing.T) {
+
+	kh := &Kuberhealthy{runTracker: external.NewRunTracker()}
+	kh.runTracker.Start("running", "check", "kuberhealthy", "check-1", time.Now().Add(time.Minute))
+	kh.runTracker.Start("replaced", "check", "kuberhealthy", "check-1", time.Now().Add(time.Minute))
+	kh.runTracker.Cancel("replaced", "replaced by a newer run")
+
+	var testCases = []struct {
+		method   string
+		path     string
+		code     int
+		canceled bool
+	}{
+		{http.MethodGet, "/cancel/running", http.StatusOK, false},
+		{http.MethodGet, "/cancel/replaced", http.StatusOK, true},
+		{http.MethodGet, "/cancel/unknown", http.StatusNotFound, false},
+		{http.MethodGet, "/cancel/", http.StatusBadRequest, false},
+		{http.MethodPost, "/cancel/running", http.StatusMethodNotAllowed, false},
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		err := kh.cancelHandler(recorder, req)
+		if err != nil {
+			t.Fatalf("%s %s returned an error: %s", tc.method, tc.path, err)
+		}
+		if recorder.Code != tc.code {
+			t.Fatalf("%s %s returned code %d but expected %d", tc.method, tc.path, recorder.Code, tc.code)
+		}
+		if recorder.Code != http.StatusOK {
+			continue
+		}
+
+		cancellation := status.Cancellation{}
+		err = json.Unmarshal(recorder.Body.Bytes(), &cancellation)
+		if err != nil {
+			t.Fatalf("failed to unmarshal cancellation from %s: %s", tc.path, err)
+		}
+		if cancellation.Canceled != tc.canceled {
+			t.Fatalf("%s returned canceled %t but expected %t", tc.path, cancellation.Canceled, tc.canceled)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/integrii/flaggy"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/kubeClient"
+)
+
+// importFieldManager is the field manager used when applying imported checks with server side apply
+const importFieldManager = "kuberhealthy-import"
+
+// lastAppliedAnnotation is the annotation kubectl keeps the last applied configuration in.  It is left out of
+// exported checks because it describes the cluster the check was exported from.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// exportChecksOptions customize the export-checks subcommand
+type exportChecksOptions struct {
+	Namespace      string
+	Selector       string
+	Output         string
+	KubeConfigFile string
+}
+
+// importChecksOptions customize the import-checks subcommand
+type importChecksOptions struct {
+	File           string
+	NamespaceMap   []string
+	Set            []string
+	Render         bool
+	KubeConfigFile string
+}
+
+// exportChecksCommand is the subcommand that exports khchecks to a bundle
+var exportChecksCommand *flaggy.Subcommand
+
+// importChecksCommand is the subcommand that imports khchecks from a bundle
+var importChecksCommand *flaggy.Subcommand
+
+// exportChecksOpts holds the options of the export-checks subcommand
+var exportChecksOpts = exportChecksOptions{}
+
+// importChecksOpts holds the options of the import-checks subcommand
+var importChecksOpts = importChecksOptions{}
+
+// addCheckBundleCommands registers the export-checks and import-checks subcommands with flaggy
+func addCheckBundleCommands() {
+	exportChecksCommand = flaggy.NewSubcommand("export-checks")
+	exportChecksCommand.Description = "Exports khchecks to a portable bundle that can be imported into another cluster with import-checks."
+	exportChecksCommand.String(&exportChecksOpts.Namespace, "n", "namespace", "Only export khchecks in this namespace. Defaults to all namespaces.")
+	exportChecksCommand.String(&exportChecksOpts.Selector, "l", "selector", "Only export khchecks matching this label selector.")
+	exportChecksCommand.String(&exportChecksOpts.Output, "o", "output", "File to write the bundle to. Defaults to stdout.")
+	exportChecksCommand.String(&exportChecksOpts.KubeConfigFile, "k", "kubeconfig", "Kube config file used to export the khchecks when not running in a cluster.")
+	flaggy.AttachSubcommand(exportChecksCommand, 1)
+
+	importChecksCommand = flaggy.NewSubcommand("import-checks")
+	importChecksCommand.Description = "Applies the khchecks of a bundle written by export-checks to the cluster, optionally moving them to other namespaces and overriding values."
+	importChecksCommand.String(&importChecksOpts.File, "f", "file", "Bundle to import. Use - to read it from stdin.")
+	importChecksCommand.StringSlice(&importChecksOpts.NamespaceMap, "", "namespace-map", "Moves khchecks from one namespace to another, such as staging=production. Can be repeated.")
+	importChecksCommand.StringSlice(&importChecksOpts.Set, "", "set", "Overrides a field of every imported khcheck, such as spec.runInterval=10m. Can be repeated.")
+	importChecksCommand.Bool(&importChecksOpts.Render, "r", "render", "Write the khchecks to stdout instead of applying them to the cluster.")
+	importChecksCommand.String(&importChecksOpts.KubeConfigFile, "k", "kubeconfig", "Kube config file used to import the khchecks when not running in a cluster.")
+	flaggy.AttachSubcommand(importChecksCommand, 1)
+}
+
+// runExportChecks exports the khchecks matching the options to a file or out
+func runExportChecks(ctx context.Context, o exportChecksOptions, out io.Writer) error {
+	restConfig, err := kubeClient.RestConfig(o.KubeConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to build kubernetes client config: %w", err)
+	}
+	client, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	b, err := exportChecks(ctx, client, o.Namespace, o.Selector)
+	if err != nil {
+		return err
+	}
+	if len(o.Output) == 0 {
+		_, err = out.Write(b)
+		return err
+	}
+	err = ioutil.WriteFile(o.Output, b, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", o.Output, err)
+	}
+	return nil
+}
+
+// exportChecks lists the khchecks in a namespace, or all namespaces when it is blank, that match the label selector
+// and returns them as a YAML List.  Fields that only make sense in the cluster the checks were exported from, such as
+// the status, UID and resource version, are left out.
+func exportChecks(ctx context.Context, client dynamic.Interface, namespace string, selector string) ([]byte, error) {
+	gvr := schema.GroupVersionResource{Group: checkCRDGroup, Version: checkCRDVersion, Resource: checkCRDResource}
+	list, err := client.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list khchecks: %w", err)
+	}
+
+	sort.Slice(list.Items, func(i, j int) bool {
+		if list.Items[i].GetNamespace() != list.Items[j].GetNamespace() {
+			return list.Items[i].GetNamespace() < list.Items[j].GetNamespace()
+		}
+		return list.Items[i].GetName() < list.Items[j].GetName()
+	})
+
+	items := make([]interface{}, 0, len(list.Items))
+	for _, item := range list.Items {
+		items = append(items, portableCheck(item).Object)
+	}
+	b, err := yaml.Marshal(map[string]interface{}{"apiVersion": "v1", "kind": "List", "items": items})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal khchecks: %w", err)
+	}
+	return b, nil
+}
+
+// portableCheck copies a khcheck without the fields that are specific to the cluster it was read from
+func portableCheck(check unstructured.Unstructured) *unstructured.Unstructured {
+	portable := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	portable.SetAPIVersion(check.GetAPIVersion())
+	portable.SetKind(check.GetKind())
+	portable.SetName(check.GetName())
+	portable.SetNamespace(check.GetNamespace())
+	if labels := check.GetLabels(); len(labels) > 0 {
+		portable.SetLabels(labels)
+	}
+	annotations := check.GetAnnotations()
+	delete(annotations, lastAppliedAnnotation)
+	if len(annotations) > 0 {
+		portable.SetAnnotations(annotations)
+	}
+	if spec, ok := check.Object["spec"]; ok {
+		portable.Object["spec"] = runtime.DeepCopyJSONValue(spec)
+	}
+	return portable
+}
+
+// runImportChecks imports the khchecks of a bundle into the cluster, or writes them to out when rendering
+func runImportChecks(ctx context.Context, o importChecksOptions, in io.Reader, out io.Writer) error {
+	if len(o.File) == 0 {
+		return fmt.Errorf("a bundle to import must be given with --file")
+	}
+	var b []byte
+	var err error
+	if o.File == "-" {
+		b, err = ioutil.ReadAll(in)
+	} else {
+		b, err = os.ReadFile(o.File)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read bundle %s: %w", o.File, err)
+	}
+
+	checks, err := parseCheckBundle(b)
+	if err != nil {
+		return err
+	}
+	namespaceMap, err := parseNamespaceMap(o.NamespaceMap)
+	if err != nil {
+		return err
+	}
+	overrides, err := parseOverrides(o.Set)
+	if err != nil {
+		return err
+	}
+	err = prepareImportedChecks(checks, namespaceMap, overrides)
+	if err != nil {
+		return err
+	}
+
+	if o.Render {
+		return renderManifests(checks, out)
+	}
+
+	restConfig, err := kubeClient.RestConfig(o.KubeConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to build kubernetes client config: %w", err)
+	}
+	client, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	return applyManifests(ctx, client, checks, importFieldManager, out)
+}
+
+// parseCheckBundle reads the khchecks of a bundle.  Bundles are YAML Lists as written by export-checks, or streams of
+// YAML documents holding khchecks or Lists of them.
+func parseCheckBundle(b []byte) ([]*unstructured.Unstructured, error) {
+	var checks []*unstructured.Unstructured
+	for _, doc := range bytes.Split(b, []byte("\n---")) {
+		if len(bytes.TrimSpace(bytes.TrimPrefix(bytes.TrimSpace(doc), []byte("---")))) == 0 {
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		err := yaml.Unmarshal(doc, &obj.Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse bundle: %w", err)
+		}
+
+		objects := []*unstructured.Unstructured{obj}
+		if obj.IsList() {
+			list, err := obj.ToList()
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse bundle: %w", err)
+			}
+			objects = objects[:0]
+			for i := range list.Items {
+				objects = append(objects, &list.Items[i])
+			}
+		}
+
+		for _, o := range objects {
+			if o.GetKind() != "KuberhealthyCheck" {
+				return nil, fmt.Errorf("bundle holds a %s named %s, but only khchecks can be imported", o.GetKind(), o.GetName())
+			}
+			checks = append(checks, o)
+		}
+	}
+	return checks, nil
+}
+
+// parseNamespaceMap parses from=to namespace mappings
+func parseNamespaceMap(mappings []string) (map[string]string, error) {
+	namespaceMap := make(map[string]string)
+	for _, m := range mappings {
+		from, to, ok := strings.Cut(m, "=")
+		if !ok || len(from) == 0 || len(to) == 0 {
+			return nil, fmt.Errorf("namespace mapping %q must look like from=to", m)
+		}
+		namespaceMap[from] = to
+	}
+	return namespaceMap, nil
+}
+
+// checkOverride sets a field of imported khchecks
+type checkOverride struct {
+	Path  []string
+	Value interface{}
+}
+
+// parseOverrides parses path=value overrides.  Paths are dot separated fields, such as spec.runInterval.  Values are
+// parsed as YAML so that numbers and booleans keep their types.
+func parseOverrides(sets []string) ([]checkOverride, error) {
+	var overrides []checkOverride
+	for _, set := range sets {
+		path, value, ok := strings.Cut(set, "=")
+		if !ok || len(path) == 0 {
+			return nil, fmt.Errorf("override %q must look like path=value", set)
+		}
+		fields := strings.Split(path, ".")
+		for _, f := range fields {
+			if len(f) == 0 {
+				return nil, fmt.Errorf("override %q has an empty field in its path", set)
+			}
+		}
+		if fields[0] != "spec" && fields[0] != "metadata" {
+			return nil, fmt.Errorf("override %q must set a field of spec or metadata", set)
+		}
+
+		var parsed interface{}
+		err := yaml.Unmarshal([]byte(value), &parsed)
+		if err != nil || parsed == nil {
+			parsed = value
+		}
+		overrides = append(overrides, checkOverride{Path: fields, Value: parsed})
+	}
+	return overrides, nil
+}
+
+// prepareImportedChecks moves khchecks to their mapped namespaces and applies the overrides to each of them
+func prepareImportedChecks(checks []*unstructured.Unstructured, namespaceMap map[string]string, overrides []checkOverride) error {
+	for _, check := range checks {
+		if to, ok := namespaceMap[check.GetNamespace()]; ok {
+			check.SetNamespace(to)
+		}
+		for _, o := range overrides {
+			err := unstructured.SetNestedField(check.Object, o.Value, o.Path...)
+			if err != nil {
+				return fmt.Errorf("failed to set %s of khcheck %s: %w", strings.Join(o.Path, "."), check.GetName(), err)
+			}
+		}
+		if len(check.GetNamespace()) == 0 {
+			return fmt.Errorf("khcheck %s has no namespace", check.GetName())
+		}
+	}
+	return nil
+}
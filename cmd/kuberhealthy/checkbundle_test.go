@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// TestExportImportChecks ensures exported khchecks leave out cluster specific fields and can be imported into other
+// namespaces with overridden values
+func TestExportImportChecks(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: checkCRDGroup, Version: checkCRDVersion, Resource: checkCRDResource}
+	dynClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "KuberhealthyCheckList"})
+
+	dns := testKHResource("KuberhealthyCheck", "staging", "dns", map[string]interface{}{"runInterval": "5m", "timeout": "1m"})
+	dns.SetLabels(map[string]string{"suite": "core"})
+	dns.SetAnnotations(map[string]string{lastAppliedAnnotation: "{}", "owner": "platform"})
+	dns.SetUID("0123")
+	dns.Object["status"] = map[string]interface{}{"observed": true}
+	deployment := testKHResource("KuberhealthyCheck", "staging", "deployment", map[string]interface{}{"runInterval": "10m"})
+	deployment.SetLabels(map[string]string{"suite": "core"})
+	other := testKHResource("KuberhealthyCheck", "staging", "experimental", map[string]interface{}{"runInterval": "1m"})
+	for _, check := range []*unstructured.Unstructured{dns, deployment, other} {
+		_, err := dynClient.Resource(gvr).Namespace(check.GetNamespace()).Create(context.Background(), check, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := exportChecks(context.Background(), dynClient, "", "suite=core")
+	if err != nil {
+		t.Fatal("Failed to export khchecks:", err)
+	}
+	for _, unwanted := range []string{"experimental", "uid", "status", lastAppliedAnnotation} {
+		if strings.Contains(string(b), unwanted) {
+			t.Fatalf("exported bundle contains %q:\n%s", unwanted, b)
+		}
+	}
+
+	checks, err := parseCheckBundle(b)
+	if err != nil {
+		t.Fatal("Failed to parse bundle:", err)
+	}
+	if len(checks) != 2 || checks[0].GetName() != "deployment" || checks[1].GetName() != "dns" {
+		t.Fatalf("expected the deployment and dns khchecks in the bundle but got %d khchecks", len(checks))
+	}
+
+	namespaceMap, err := parseNamespaceMap([]string{"staging=production"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrides, err := parseOverrides([]string{"spec.runInterval=15m", "spec.runOnAllNodes=true", "metadata.labels.env=production"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = prepareImportedChecks(checks, namespaceMap, overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := checks[1]
+	runInterval, _, _ := unstructured.NestedString(imported.Object, "spec", "runInterval")
+	timeout, _, _ := unstructured.NestedString(imported.Object, "spec", "timeout")
+	runOnAllNodes, _, _ := unstructured.NestedBool(imported.Object, "spec", "runOnAllNodes")
+	if imported.GetNamespace() != "production" || runInterval != "15m" || timeout != "1m" || !runOnAllNodes {
+		t.Fatalf("unexpected imported khcheck %+v", imported.Object)
+	}
+	if imported.GetLabels()["env"] != "production" || imported.GetLabels()["suite"] != "core" || imported.GetAnnotations()["owner"] != "platform" {
+		t.Fatalf("unexpected metadata of imported khcheck %+v", imported.Object["metadata"])
+	}
+
+	out := &bytes.Buffer{}
+	err = renderManifests(checks, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendered, err := parseCheckBundle(out.Bytes())
+	if err != nil || len(rendered) != 2 {
+		t.Fatalf("rendered khchecks could not be read back as a bundle: %v", err)
+	}
+}
+
+// TestParseCheckBundleErrors ensures bundles holding anything but khchecks and malformed options are refused
+func TestParseCheckBundleErrors(t *testing.T) {
+	_, err := parseCheckBundle([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n"))
+	if err == nil {
+		t.Fatal("expected a bundle holding a configmap to be refused")
+	}
+	_, err = parseNamespaceMap([]string{"staging"})
+	if err == nil {
+		t.Fatal("expected a namespace mapping without a target to be refused")
+	}
+	for _, set := range []string{"runInterval", "status.ok=true", "spec..timeout=1m"} {
+		_, err = parseOverrides([]string{set})
+		if err == nil {
+			t.Fatalf("expected override %q to be refused", set)
+		}
+	}
+}
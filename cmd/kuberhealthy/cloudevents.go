@@ -0,0 +1,63 @@
+package main
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/cloudevents"
+)
+
+// configureCloudEvents sets up sending check results as CloudEvents to the configured sink
+func (k *Kuberhealthy) configureCloudEvents() {
+	log.Infoln("Sending check results as CloudEvents to", cfg.CloudEventsSink)
+	k.EventSender = cloudevents.NewSender(cfg.CloudEventsSink, cfg.CloudEventsSource)
+}
+
+// seedEventState gives the CloudEvents sender the state currently stored for a check or job so that the next result
+// stored for it can be compared against it.  Must be called before a new state is stored.
+func (k *Kuberhealthy) seedEventState(name string, namespace string, workload khstatev1.KHWorkload) {
+	key := namespace + "/" + name
+	if k.EventSender == nil || k.EventSender.Known(key) {
+		return
+	}
+
+	current := k.stateReflector.CurrentStatus()
+	details, ok := current.CheckDetails[key]
+	if workload == khstatev1.KHJob {
+		details, ok = current.JobDetails[key]
+	}
+	if ok {
+		k.EventSender.Seed(key, details.OK)
+	}
+}
+
+// publishRunCompleted sends the CloudEvents for a completed check or job run and hands the run to notifier plugins
+func (k *Kuberhealthy) publishRunCompleted(name string, namespace string, details khstatev1.WorkloadDetails) {
+	details = k.withCorrelations(details)
+	details = k.withLabels(name, namespace, details)
+	k.EventSender.RunCompleted(cloudEventResult(name, namespace, details))
+	k.notifyPlugins(name, namespace, details)
+}
+
+// publishStateObserved sends a CloudEvent if a newly stored check or job state changed from the last one
+func (k *Kuberhealthy) publishStateObserved(name string, namespace string, details khstatev1.WorkloadDetails) {
+	details = k.withCorrelations(details)
+	details = k.withLabels(name, namespace, details)
+	k.EventSender.StateObserved(cloudEventResult(name, namespace, details))
+}
+
+// cloudEventResult creates the data of a CloudEvent from the state of a check or job
+func cloudEventResult(name string, namespace string, details khstatev1.WorkloadDetails) cloudevents.Result {
+	return cloudevents.Result{
+		Name:         name,
+		Namespace:    namespace,
+		Workload:     string(details.GetKHWorkload()),
+		OK:           details.OK,
+		Errors:       details.Errors,
+		RunDuration:  details.RunDuration,
+		UUID:         details.CurrentUUID,
+		Node:         details.Node,
+		Correlations: details.Correlations,
+		Labels:       details.Labels,
+	}
+}
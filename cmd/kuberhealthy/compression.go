@@ -0,0 +1,222 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	log "github.com/sirupsen/logrus"
+)
+
+// minCompressSize is the smallest response body that is compressed.  Smaller bodies are not worth the overhead.
+const minCompressSize = 1024
+
+// supportedEncodings are the content encodings the web server can compress with, in order of preference when a
+// client accepts several of them equally
+var supportedEncodings = []string{"br", "gzip"}
+
+// negotiateEncoding picks the content encoding to use for a request from its Accept-Encoding header.  A blank
+// encoding means the response should not be compressed.
+func negotiateEncoding(acceptEncoding string) string {
+	weights := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		if len(coding) == 0 {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+			if err != nil {
+				q = 0
+				continue
+			}
+			q = parsed
+		}
+		if coding == "*" {
+			wildcard = q
+			continue
+		}
+		weights[coding] = q
+	}
+
+	var best string
+	var bestWeight float64
+	for _, encoding := range supportedEncodings {
+		q, ok := weights[encoding]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestWeight {
+			best = encoding
+			bestWeight = q
+		}
+	}
+	return best
+}
+
+// compressedResponseWriter compresses what is written to it with the negotiated encoding.  Whether to compress is
+// decided on the first write so that small bodies and bodiless responses are passed through untouched.
+type compressedResponseWriter struct {
+	http.ResponseWriter
+	encoding string
+	encoder  io.WriteCloser
+	status   int
+	decided  bool
+}
+
+// WriteHeader holds on to the status code until the first write decides whether the body is compressed
+func (c *compressedResponseWriter) WriteHeader(status int) {
+	if c.decided {
+		c.ResponseWriter.WriteHeader(status)
+		return
+	}
+	c.status = status
+	if status == http.StatusNotModified || status == http.StatusNoContent || status < http.StatusOK {
+		c.decide(0)
+	}
+}
+
+// Write compresses the body when it is large enough to be worth it
+func (c *compressedResponseWriter) Write(b []byte) (int, error) {
+	if !c.decided {
+		c.decide(len(b))
+	}
+	if c.encoder != nil {
+		return c.encoder.Write(b)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// decide sets up compression for a body of the supplied size and writes any held status code
+func (c *compressedResponseWriter) decide(size int) {
+	c.decided = true
+
+	header := c.ResponseWriter.Header()
+	if size >= minCompressSize && len(header.Get("Content-Encoding")) == 0 {
+		header.Set("Content-Encoding", c.encoding)
+		header.Del("Content-Length")
+
+		// the compressed body is no longer byte for byte what a strong etag describes
+		if etag := header.Get("ETag"); len(etag) > 0 && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+
+		switch c.encoding {
+		case "br":
+			c.encoder = brotli.NewWriterLevel(c.ResponseWriter, brotli.DefaultCompression)
+		case "gzip":
+			c.encoder = gzip.NewWriter(c.ResponseWriter)
+		}
+	}
+
+	if c.status != 0 {
+		c.ResponseWriter.WriteHeader(c.status)
+	}
+}
+
+// Close flushes the compressed body, or the held status code when nothing was written
+func (c *compressedResponseWriter) Close() error {
+	if !c.decided {
+		c.decide(0)
+	}
+	if c.encoder != nil {
+		return c.encoder.Close()
+	}
+	return nil
+}
+
+// compressHandler wraps a handler so that its responses are compressed with gzip or brotli when the client accepts
+// it.  Large clusters produce status documents that are several megabytes of JSON.
+func compressHandler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if len(encoding) == 0 || r.Method == http.MethodHead {
+			next(w, r)
+			return
+		}
+
+		cw := &compressedResponseWriter{ResponseWriter: w, encoding: encoding}
+		defer func() {
+			err := cw.Close()
+			if err != nil {
+				log.Warningln("Error finishing compressed response to caller:", err)
+			}
+		}()
+		next(cw, r)
+	}
+}
+
+// maxDecompressedRequestBytes is the largest a compressed request body may grow to when it is decompressed, so that a
+// small body can't expand to exhaust the memory of Kuberhealthy
+const maxDecompressedRequestBytes = 8 << 20
+
+// errDecompressedTooLarge is returned when reading a compressed request body that decompresses to more than
+// maxDecompressedRequestBytes
+var errDecompressedTooLarge = fmt.Errorf("request body decompresses to more than %d bytes", maxDecompressedRequestBytes)
+
+// decompressedBody reads a gzip request body and fails once it decompresses to more than the limit
+type decompressedBody struct {
+	reader *gzip.Reader
+	body   io.ReadCloser
+	read   int64
+}
+
+// Read reads the decompressed body
+func (d *decompressedBody) Read(p []byte) (int, error) {
+	n, err := d.reader.Read(p)
+	d.read += int64(n)
+	if d.read > maxDecompressedRequestBytes {
+		return n, errDecompressedTooLarge
+	}
+	return n, err
+}
+
+// Close closes the compressed body
+func (d *decompressedBody) Close() error {
+	d.reader.Close()
+	return d.body.Close()
+}
+
+// decompressHandler wraps a handler so that gzip request bodies are decompressed before it reads them.  Checks that
+// report hundreds of errors compress their reports to stay under the body limits of ingresses.  Bodies in other
+// encodings are refused with 415 Unsupported Media Type.
+func decompressHandler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+		switch encoding {
+		case "", "identity":
+			next(w, r)
+			return
+		case "gzip":
+		default:
+			log.Warningln("Refusing request to", r.URL.Path, "from", r.RemoteAddr, "with unsupported content encoding", encoding)
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			log.Warningln("Refusing request to", r.URL.Path, "from", r.RemoteAddr, "with a body that is not gzip:", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.Body = &decompressedBody{reader: reader, body: r.Body}
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+		next(w, r)
+	}
+}
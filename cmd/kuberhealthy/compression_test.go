@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// TestNegotiateEncoding ensures the best supported encoding is picked from Accept-Encoding headers
+func TestNegotiateEncoding(t *testing.T) {
+	var testCases = []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0.1", "gzip"},
+		{"*", "br"},
+		{"*;q=0.5, br;q=0", "gzip"},
+		{"GZIP", "gzip"},
+	}
+
+	for _, tc := range testCases {
+		encoding := negotiateEncoding(tc.acceptEncoding)
+		if encoding != tc.expected {
+			t.Fatalf("Accept-Encoding %q negotiated %q but expected %q", tc.acceptEncoding, encoding, tc.expected)
+		}
+	}
+}
+
+// TestCompressHandler ensures large bodies are compressed while small and bodiless responses are left alone
+func TestCompressHandler(t *testing.T) {
+	large := strings.Repeat(`{"OK": true}`, 500)
+
+	var testCases = []struct {
+		acceptEncoding string
+		body           string
+		status         int
+		encoding       string
+	}{
+		{"gzip", large, http.StatusOK, "gzip"},
+		{"br", large, http.StatusOK, "br"},
+		{"", large, http.StatusOK, ""},
+		{"gzip", "{}", http.StatusOK, ""},
+		{"gzip", "", http.StatusNotModified, ""},
+	}
+
+	for _, tc := range testCases {
+		handler := compressHandler(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"abc"`)
+			w.WriteHeader(tc.status)
+			_, _ = w.Write([]byte(tc.body))
+		})
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Encoding", tc.acceptEncoding)
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		if w.Code != tc.status {
+			t.Fatalf("Accept-Encoding %q returned status %d but expected %d", tc.acceptEncoding, w.Code, tc.status)
+		}
+		if w.Header().Get("Content-Encoding") != tc.encoding {
+			t.Fatalf("Accept-Encoding %q returned Content-Encoding %q but expected %q", tc.acceptEncoding, w.Header().Get("Content-Encoding"), tc.encoding)
+		}
+		if w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Fatalf("Accept-Encoding %q did not set Vary", tc.acceptEncoding)
+		}
+
+		var reader io.Reader = w.Body
+		switch tc.encoding {
+		case "gzip":
+			gz, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatalf("failed to read gzip body: %s", err)
+			}
+			reader = gz
+		case "br":
+			reader = brotli.NewReader(w.Body)
+		}
+		body, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("failed to read %q body: %s", tc.encoding, err)
+		}
+		if !bytes.Equal(body, []byte(tc.body)) {
+			t.Fatalf("Accept-Encoding %q returned a body that did not match what the handler wrote", tc.acceptEncoding)
+		}
+		if len(tc.encoding) > 0 && w.Header().Get("ETag") != `W/"abc"` {
+			t.Fatalf("compressed response kept a strong etag: %s", w.Header().Get("ETag"))
+		}
+	}
+}
+
+// gzipBytes compresses b with gzip
+func gzipBytes(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, err := gw.Write(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestDecompressHandler ensures gzip request bodies are decompressed, other encodings are refused and bodies that
+// decompress to more than the limit can't be read
+func TestDecompressHandler(t *testing.T) {
+	report := []byte(`{"OK":false,"Errors":["` + strings.Repeat("pod failed to start ", 100) + `"]}`)
+
+	var testCases = []struct {
+		description  string
+		encoding     string
+		body         []byte
+		expectStatus int
+		expectBody   []byte
+		expectError  bool
+	}{
+		{"plain", "", report, http.StatusOK, report, false},
+		{"gzip", "gzip", gzipBytes(t, report), http.StatusOK, report, false},
+		{"gzip in capitals", "GZIP", gzipBytes(t, report), http.StatusOK, report, false},
+		{"not gzip", "gzip", report, http.StatusBadRequest, nil, false},
+		{"unsupported encoding", "br", report, http.StatusUnsupportedMediaType, nil, false},
+		{"too large", "gzip", gzipBytes(t, make([]byte, maxDecompressedRequestBytes+1)), http.StatusOK, nil, true},
+	}
+	for _, tc := range testCases {
+		var read []byte
+		var readErr error
+		handler := decompressHandler(func(w http.ResponseWriter, r *http.Request) {
+			if len(r.Header.Get("Content-Encoding")) > 0 {
+				t.Errorf("%s: content encoding header was left on the decompressed request", tc.description)
+			}
+			read, readErr = io.ReadAll(r.Body)
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/externalCheckStatus", bytes.NewReader(tc.body))
+		if len(tc.encoding) > 0 {
+			req.Header.Set("Content-Encoding", tc.encoding)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		if rec.Code != tc.expectStatus {
+			t.Fatalf("%s: got status %d but expected %d", tc.description, rec.Code, tc.expectStatus)
+		}
+		if tc.expectError {
+			if readErr != errDecompressedTooLarge {
+				t.Fatalf("%s: expected the body to be too large, got %v", tc.description, readErr)
+			}
+			continue
+		}
+		if readErr != nil {
+			t.Fatalf("%s: %v", tc.description, readErr)
+		}
+		if tc.expectBody != nil && !bytes.Equal(read, tc.expectBody) {
+			t.Fatalf("%s: handler read %q", tc.description, read)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// runAction is what the scheduler of a check does when the next run is due
+type runAction int
+
+const (
+	startRun   runAction = iota // start the next run
+	skipRun                     // skip the next run because one is still going
+	overlapRun                  // start the next run alongside the ones still going
+	replaceRun                  // cancel the runs still going and start the next run
+)
+
+// concurrencyAction decides what to do when the next run of a check is due given its concurrency policy and the
+// number of its runs still going.  Checks without a policy forbid overlapping runs.
+func concurrencyAction(policy khcheckv1.ConcurrencyPolicy, inFlight int) runAction {
+	if inFlight == 0 {
+		return startRun
+	}
+	switch policy {
+	case khcheckv1.AllowConcurrent:
+		return overlapRun
+	case khcheckv1.ReplaceConcurrent:
+		return replaceRun
+	default:
+		return skipRun
+	}
+}
+
+// removeChecker removes a checker from a list of checkers
+func removeChecker(checkers []*external.Checker, c *external.Checker) []*external.Checker {
+	for i := range checkers {
+		if checkers[i] == c {
+			return append(checkers[:i], checkers[i+1:]...)
+		}
+	}
+	return checkers
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// TestConcurrencyAction ensures runs that are due while another is still going follow the concurrency policy
+func TestConcurrencyAction(t *testing.T) {
+	var testCases = []struct {
+		policy   khcheckv1.ConcurrencyPolicy
+		inFlight int
+		expected runAction
+	}{
+		{"", 0, startRun},
+		{khcheckv1.AllowConcurrent, 0, startRun},
+		{khcheckv1.ReplaceConcurrent, 0, startRun},
+		{"", 1, skipRun},
+		{khcheckv1.ForbidConcurrent, 1, skipRun},
+		{khcheckv1.AllowConcurrent, 2, overlapRun},
+		{khcheckv1.ReplaceConcurrent, 1, replaceRun},
+	}
+
+	for _, tc := range testCases {
+		action := concurrencyAction(tc.policy, tc.inFlight)
+		if action != tc.expected {
+			t.Fatalf("action for policy %q with %d runs in flight was %d but expected %d", tc.policy, tc.inFlight, action, tc.expected)
+		}
+	}
+}
+
+// TestRemoveChecker ensures only the supplied checker is removed
+func TestRemoveChecker(t *testing.T) {
+	a, b, c := &external.Checker{}, &external.Checker{}, &external.Checker{}
+	checkers := removeChecker([]*external.Checker{a, b, c}, b)
+	if len(checkers) != 2 || checkers[0] != a || checkers[1] != c {
+		t.Fatalf("removing a checker left %v", checkers)
+	}
+	checkers = removeChecker(checkers, b)
+	if len(checkers) != 2 {
+		t.Fatalf("removing a missing checker changed the list to %v", checkers)
+	}
+}
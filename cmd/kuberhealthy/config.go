@@ -6,29 +6,80 @@ import (
 	"time"
 
 	"github.com/codingsince1985/checksum"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/digest"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/duration"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/metrics"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/plugins"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/rules"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 )
 
 // Config holds all configurable options
 type Config struct {
-	kubeConfigFile            string
-	ListenAddress             string                    `yaml:"listenAddress,omitempty"`
-	EnableForceMaster         bool                      `yaml:"enableForceMaster,omitempty"`
-	LogLevel                  string                    `yaml:"logLevel,omitempty"`
-	InfluxUsername            string                    `yaml:"influxUsername,omitempty"`
-	InfluxPassword            string                    `yaml:"influxPassword,omitempty"`
-	InfluxURL                 string                    `yaml:"influxURL,omitempty"`
-	InfluxDB                  string                    `yaml:"influxDB,omitempty"`
-	EnableInflux              bool                      `yaml:"enableInflux,omitempty"`
-	ExternalCheckReportingURL string                    `yaml:"externalCheckReportingURL,omitempty"`
-	MaxKHJobAge               time.Duration             `yaml:"maxKHJobAge,omitempty"`
-	MaxCheckPodAge            time.Duration             `yaml:"maxCheckPodAge,omitempty"`
-	MaxCompletedPodCount      int                       `yaml:"maxCompletedPodCount,omitempty"`
-	MaxErrorPodCount          int                       `yaml:"maxErrorPodCount,omitempty"`
-	StateMetadata             map[string]string         `yaml:"stateMetadata,omitempty"`
-	PromMetricsConfig         metrics.PromMetricsConfig `yaml:"promMetricsConfig,omitempty"`
+	kubeConfigFile               string
+	ListenAddress                string                    `yaml:"listenAddress,omitempty"`
+	EnableForceMaster            bool                      `yaml:"enableForceMaster,omitempty"`
+	LogLevel                     string                    `yaml:"logLevel,omitempty"`
+	EnableAccessLog              bool                      `yaml:"enableAccessLog,omitempty"`
+	InfluxUsername               string                    `yaml:"influxUsername,omitempty"`
+	InfluxPassword               string                    `yaml:"influxPassword,omitempty"`
+	InfluxURL                    string                    `yaml:"influxURL,omitempty"`
+	InfluxDB                     string                    `yaml:"influxDB,omitempty"`
+	EnableInflux                 bool                      `yaml:"enableInflux,omitempty"`
+	ExternalCheckReportingURL    string                    `yaml:"externalCheckReportingURL,omitempty"`
+	ReportingURLMode             string                    `yaml:"reportingURLMode,omitempty"`
+	ExternalReportingHostname    string                    `yaml:"externalReportingHostname,omitempty"`
+	ReportSigningKey             string                    `yaml:"reportSigningKey,omitempty"`
+	AlertReceiverToken           string                    `yaml:"alertReceiverToken,omitempty"`
+	GRPCListenAddress            string                    `yaml:"grpcListenAddress,omitempty"`
+	GRPCReportingAddress         string                    `yaml:"grpcReportingAddress,omitempty"`
+	MaxKHJobAge                  duration.Duration         `yaml:"maxKHJobAge,omitempty"`
+	MaxCheckPodAge               duration.Duration         `yaml:"maxCheckPodAge,omitempty"`
+	MaxCompletedPodCount         int                       `yaml:"maxCompletedPodCount,omitempty"`
+	MaxErrorPodCount             int                       `yaml:"maxErrorPodCount,omitempty"`
+	MaxRunHistory                int                       `yaml:"maxRunHistory,omitempty"`
+	StaleResultIntervals         int                       `yaml:"staleResultIntervals,omitempty"`
+	MaxConcurrentReports         int                       `yaml:"maxConcurrentReports,omitempty"`
+	ProvisioningFailureThreshold int                       `yaml:"provisioningFailureThreshold,omitempty"`
+	MaxProvisioningBackoff       duration.Duration         `yaml:"maxProvisioningBackoff,omitempty"`
+	ShutdownPolicy               string                    `yaml:"shutdownPolicy,omitempty"`
+	ShutdownWaitTimeout          duration.Duration         `yaml:"shutdownWaitTimeout,omitempty"`
+	SecurityContextPolicy        string                    `yaml:"securityContextPolicy,omitempty"`
+	IsolatedNamespaceRoles       []string                  `yaml:"isolatedNamespaceRoles,omitempty"`
+	KuberhealthyAntiAffinity     string                    `yaml:"kuberhealthyAntiAffinity,omitempty"`
+	EnableSelfChecks             bool                      `yaml:"enableSelfChecks,omitempty"`
+	SelfCheckInterval            duration.Duration         `yaml:"selfCheckInterval,omitempty"`
+	SelfCheckImage               string                    `yaml:"selfCheckImage,omitempty"`
+	SelfCheckMaxWriteLatency     duration.Duration         `yaml:"selfCheckMaxWriteLatency,omitempty"`
+	SelfCheckMaxRunsInFlight     int                       `yaml:"selfCheckMaxRunsInFlight,omitempty"`
+	EnableFaultInjection         bool                      `yaml:"enableFaultInjection,omitempty"`
+	FaultInjectionDropPercent    int                       `yaml:"faultInjectionDropPercent,omitempty"`
+	FaultInjectionErrorPercent   int                       `yaml:"faultInjectionErrorPercent,omitempty"`
+	FaultInjectionDelay          duration.Duration         `yaml:"faultInjectionDelay,omitempty"`
+	StateMetadata                map[string]string         `yaml:"stateMetadata,omitempty"`
+	CloudEventsSink              string                    `yaml:"cloudEventsSink,omitempty"`
+	CloudEventsSource            string                    `yaml:"cloudEventsSource,omitempty"`
+	EnableRemediation            bool                      `yaml:"enableRemediation,omitempty"`
+	EnableCoverage               bool                      `yaml:"enableCoverage,omitempty"`
+	CoverageExcludeNamespaces    []string                  `yaml:"coverageExcludeNamespaces,omitempty"`
+	EnableDiscovery              bool                      `yaml:"enableDiscovery,omitempty"`
+	DiscoveryHTTPImage           string                    `yaml:"discoveryHTTPImage,omitempty"`
+	DiscoveryTCPImage            string                    `yaml:"discoveryTCPImage,omitempty"`
+	DiscoveryDefaultInterval     string                    `yaml:"discoveryDefaultInterval,omitempty"`
+	EnableProbes                 bool                      `yaml:"enableProbes,omitempty"`
+	EnableResultExport           bool                      `yaml:"enableResultExport,omitempty"`
+	EnableImageVersions          bool                      `yaml:"enableImageVersions,omitempty"`
+	EnableBatchApply             bool                      `yaml:"enableBatchApply,omitempty"`
+	ImageVersionRepositories     []string                  `yaml:"imageVersionRepositories,omitempty"`
+	ImageAutoUpdate              string                    `yaml:"imageAutoUpdate,omitempty"`
+	ImageRollbackFailures        int                       `yaml:"imageRollbackFailures,omitempty"`
+	Plugins                      []plugins.Config          `yaml:"plugins,omitempty"`
+	HealthRules                  []rules.Rule              `yaml:"healthRules,omitempty"`
+	Digest                       digest.Config             `yaml:"digest,omitempty"`
+	Correlations                 []correlationSource       `yaml:"correlations,omitempty"`
+	PropagateLabels              []string                  `yaml:"propagateLabels,omitempty"`
+	PromMetricsConfig            metrics.PromMetricsConfig `yaml:"promMetricsConfig,omitempty"`
 }
 
 // Load loads file from disk
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+)
+
+// defaultCorrelationInterval is how often correlation IDs are read from their configmaps
+const defaultCorrelationInterval = time.Second * 30
+
+// correlationSource configures a correlation ID that is added to check states and notifications so that incident
+// tooling can match failures to recent changes.  The ID is either a fixed value or the value of a key in a configmap,
+// such as a deploy SHA kept up to date by a CD pipeline.
+type correlationSource struct {
+	Name      string `yaml:"name"`                // the name the ID is added under
+	Value     string `yaml:"value,omitempty"`     // a fixed value, such as the current change freeze ID
+	ConfigMap string `yaml:"configMap,omitempty"` // the namespace/name of the configmap to read the value from
+	Key       string `yaml:"key,omitempty"`       // the key of the configmap that holds the value
+}
+
+// validate ensures the source has a name and exactly one place to get its value from
+func (c correlationSource) validate() error {
+	if len(c.Name) == 0 {
+		return fmt.Errorf("correlation IDs need a name")
+	}
+	if len(c.Value) > 0 && len(c.ConfigMap) > 0 {
+		return fmt.Errorf("correlation ID %s can have a value or a configMap, but not both", c.Name)
+	}
+	if len(c.ConfigMap) > 0 {
+		namespace, name, ok := strings.Cut(c.ConfigMap, "/")
+		if !ok || len(namespace) == 0 || len(name) == 0 {
+			return fmt.Errorf("configMap of correlation ID %s must look like namespace/name, got %q", c.Name, c.ConfigMap)
+		}
+		if len(c.Key) == 0 {
+			return fmt.Errorf("correlation ID %s needs the key of its configMap", c.Name)
+		}
+	}
+	return nil
+}
+
+// resolveCorrelations reads the current value of each correlation ID.  IDs that can't be read, such as because their
+// configmap or key is missing, are logged and left out so that the others are still added.
+func resolveCorrelations(ctx context.Context, client kubernetes.Interface, sources []correlationSource) map[string]string {
+	correlations := make(map[string]string)
+	for _, source := range sources {
+		err := source.validate()
+		if err != nil {
+			log.Errorln("correlations:", err)
+			continue
+		}
+		if len(source.ConfigMap) == 0 {
+			if len(source.Value) > 0 {
+				correlations[source.Name] = source.Value
+			}
+			continue
+		}
+
+		namespace, name, _ := strings.Cut(source.ConfigMap, "/")
+		cm, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			log.Warningln("correlations: failed to read correlation ID", source.Name, "from configmap", source.ConfigMap+":", err)
+			continue
+		}
+		value, ok := cm.Data[source.Key]
+		if !ok || len(value) == 0 {
+			log.Debugln("correlations: configmap", source.ConfigMap, "has no value for key", source.Key, "of correlation ID", source.Name)
+			continue
+		}
+		correlations[source.Name] = value
+	}
+	if len(correlations) == 0 {
+		return nil
+	}
+	return correlations
+}
+
+// monitorCorrelations refreshes the correlation IDs on an interval until the supplied context is canceled
+func (k *Kuberhealthy) monitorCorrelations(ctx context.Context) {
+	log.Infoln("correlations: Reading", len(cfg.Correlations), "correlation IDs every", defaultCorrelationInterval)
+	ticker := time.NewTicker(defaultCorrelationInterval)
+	defer ticker.Stop()
+
+	for {
+		correlations := resolveCorrelations(ctx, kubernetesClient, cfg.Correlations)
+		k.correlationsMu.Lock()
+		k.correlations = correlations
+		k.correlationsMu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// withCorrelations adds the current correlation IDs to the details of a check or job state, unless it already has
+// the IDs of when it was first stored
+func (k *Kuberhealthy) withCorrelations(details khstatev1.WorkloadDetails) khstatev1.WorkloadDetails {
+	if details.Correlations != nil {
+		return details
+	}
+	k.correlationsMu.RLock()
+	defer k.correlationsMu.RUnlock()
+	if len(k.correlations) == 0 {
+		return details
+	}
+	details.Correlations = make(map[string]string, len(k.correlations))
+	for name, value := range k.correlations {
+		details.Correlations[name] = value
+	}
+	return details
+}
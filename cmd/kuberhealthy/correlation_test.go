@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+)
+
+// TestResolveCorrelations ensures correlation IDs are read from their fixed values and configmaps, and that IDs that
+// can't be read are left out
+func TestResolveCorrelations(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "platform"},
+		Data:       map[string]string{"sha": "4f2a9c1"},
+	})
+	sources := []correlationSource{
+		{Name: "changeFreeze", Value: "CF-1234"},
+		{Name: "deploySHA", ConfigMap: "platform/release", Key: "sha"},
+		{Name: "missingKey", ConfigMap: "platform/release", Key: "version"},
+		{Name: "missingConfigMap", ConfigMap: "platform/nope", Key: "sha"},
+		{Name: "invalid", Value: "x", ConfigMap: "platform/release", Key: "sha"},
+	}
+
+	correlations := resolveCorrelations(context.Background(), client, sources)
+	expected := map[string]string{"changeFreeze": "CF-1234", "deploySHA": "4f2a9c1"}
+	if !reflect.DeepEqual(correlations, expected) {
+		t.Fatalf("resolved %v but expected %v", correlations, expected)
+	}
+
+	if resolveCorrelations(context.Background(), client, sources[2:4]) != nil {
+		t.Fatal("expected no correlation IDs when none can be read")
+	}
+}
+
+// TestWithCorrelations ensures the current correlation IDs are added to states without them and that states keep the
+// IDs of when they were first stored
+func TestWithCorrelations(t *testing.T) {
+	k := &Kuberhealthy{}
+	details := k.withCorrelations(khstatev1.NewWorkloadDetails(khstatev1.KHCheck))
+	if details.Correlations != nil {
+		t.Fatalf("expected no correlation IDs, got %v", details.Correlations)
+	}
+
+	k.correlations = map[string]string{"deploySHA": "4f2a9c1"}
+	details = k.withCorrelations(details)
+	if details.Correlations["deploySHA"] != "4f2a9c1" {
+		t.Fatalf("expected the current deploy SHA, got %v", details.Correlations)
+	}
+
+	k.correlations = map[string]string{"deploySHA": "b81e07d"}
+	details = k.withCorrelations(details)
+	if details.Correlations["deploySHA"] != "4f2a9c1" {
+		t.Fatalf("expected the deploy SHA of when the state was stored, got %v", details.Correlations)
+	}
+}
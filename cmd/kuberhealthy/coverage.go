@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/coverage"
+)
+
+// defaultCoverageInterval is how often the coverage report is refreshed
+const defaultCoverageInterval = time.Minute * 5
+
+// collectCoverage lists the namespaces and workloads of the cluster and works out which are covered by the
+// supplied checks
+func collectCoverage(ctx context.Context, client kubernetes.Interface, checks []khcheckv1.KuberhealthyCheck, opts coverage.Options) (coverage.Report, error) {
+	namespaces, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return coverage.Report{}, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	var workloads []coverage.Workload
+	deployments, err := client.AppsV1().Deployments("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return coverage.Report{}, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	for _, d := range deployments.Items {
+		workloads = append(workloads, coverage.Workload{Namespace: d.Namespace, Kind: "Deployment", Name: d.Name, Labels: d.Labels})
+	}
+
+	statefulSets, err := client.AppsV1().StatefulSets("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return coverage.Report{}, fmt.Errorf("failed to list statefulsets: %w", err)
+	}
+	for _, s := range statefulSets.Items {
+		workloads = append(workloads, coverage.Workload{Namespace: s.Namespace, Kind: "StatefulSet", Name: s.Name, Labels: s.Labels})
+	}
+
+	daemonSets, err := client.AppsV1().DaemonSets("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return coverage.Report{}, fmt.Errorf("failed to list daemonsets: %w", err)
+	}
+	for _, d := range daemonSets.Items {
+		workloads = append(workloads, coverage.Workload{Namespace: d.Namespace, Kind: "DaemonSet", Name: d.Name, Labels: d.Labels})
+	}
+
+	return coverage.Compute(namespaces.Items, checks, workloads, opts), nil
+}
+
+// monitorCoverage refreshes the coverage report on an interval until the supplied context is canceled
+func (k *Kuberhealthy) monitorCoverage(ctx context.Context) {
+	log.Infoln("coverage: Reporting khcheck coverage every", defaultCoverageInterval)
+	ticker := time.NewTicker(defaultCoverageInterval)
+	defer ticker.Stop()
+
+	for {
+		k.refreshCoverage(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshCoverage generates a new coverage report and stores it for the web server
+func (k *Kuberhealthy) refreshCoverage(ctx context.Context) {
+	checks, err := khCheckClient.KuberhealthyChecks("").List(metav1.ListOptions{})
+	if err != nil {
+		log.Errorln("coverage: failed to list khchecks:", err)
+		return
+	}
+
+	report, err := collectCoverage(ctx, kubernetesClient, checks.Items, coverage.Options{ExcludeNamespaces: cfg.CoverageExcludeNamespaces})
+	if err != nil {
+		log.Errorln("coverage:", err)
+		return
+	}
+	log.Debugln("coverage:", report.Namespaces.Covered, "of", report.Namespaces.Total, "namespaces and", report.Workloads.Covered, "of", report.Workloads.Total, "workloads covered")
+
+	k.coverageMu.Lock()
+	defer k.coverageMu.Unlock()
+	k.coverageReport = &report
+}
+
+// currentCoverage returns the most recent coverage report and whether one has been generated
+func (k *Kuberhealthy) currentCoverage() (coverage.Report, bool) {
+	k.coverageMu.RLock()
+	defer k.coverageMu.RUnlock()
+	if k.coverageReport == nil {
+		return coverage.Report{}, false
+	}
+	return *k.coverageReport, true
+}
+
+// coverageHandler serves the most recent coverage report as JSON
+func (k *Kuberhealthy) coverageHandler(w http.ResponseWriter, r *http.Request) error {
+	log.Infoln("Client connected to coverage endpoint from", r.RemoteAddr, r.UserAgent())
+	if !cfg.EnableCoverage {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+
+	report, ok := k.currentCoverage()
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return nil
+	}
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to marshal coverage report: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(b)
+	if err != nil {
+		log.Warningln("Error writing coverage report to caller:", err)
+	}
+	return err
+}
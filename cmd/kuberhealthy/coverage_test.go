@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/coverage"
+)
+
+// TestCollectCoverage ensures that deployments, statefulsets and daemonsets are all considered for coverage
+func TestCollectCoverage(t *testing.T) {
+	labels := map[string]string{coverage.WorkloadCheckLabel: "app-check"}
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "app", Labels: labels}},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "app"}},
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "other", Labels: labels}},
+	)
+	checks := []khcheckv1.KuberhealthyCheck{khcheckv1.NewKuberhealthyCheck("app-check", "app", khcheckv1.CheckConfig{})}
+
+	report, err := collectCoverage(context.Background(), client, checks, coverage.Options{})
+	if err != nil {
+		t.Fatalf("failed to collect coverage: %s", err)
+	}
+	if !reflect.DeepEqual(report.Namespaces.Uncovered(), []string{"other"}) {
+		t.Fatalf("uncovered namespaces were %v but expected [other]", report.Namespaces.Uncovered())
+	}
+	expected := []string{"app/StatefulSet/db", "other/DaemonSet/agent"}
+	if report.Workloads.Total != 3 || !reflect.DeepEqual(report.Workloads.Uncovered(), expected) {
+		t.Fatalf("uncovered workloads were %v of %d but expected %v of 3", report.Workloads.Uncovered(), report.Workloads.Total, expected)
+	}
+}
@@ -28,6 +28,11 @@ func setCheckStateResource(checkName string, checkNamespace string, state khstat
 	}
 	resourceVersion := existingState.GetResourceVersion()
 
+	// carry over the run history unless the caller is replacing it
+	if state.History == nil {
+		state.History = existingState.Spec.History
+	}
+
 	// set the pod name that wrote the khstate
 	state.AuthoritativePod = podHostname
 	now := metav1.Now() // set the time the khstate was last
@@ -42,6 +47,39 @@ func setCheckStateResource(checkName string, checkNamespace string, state khstat
 	return err
 }
 
+// appendRunHistory adds an entry to the run history of a khstate without changing anything else about its state.
+// Conflicting writes are retried a few times.
+func appendRunHistory(checkName string, checkNamespace string, entry khstatev1.RunHistoryEntry) error {
+
+	name := sanitizeResourceName(checkName)
+
+	var err error
+	maxTries := 3
+	for tries := 0; tries < maxTries; tries++ {
+		var existingState khstatev1.KuberhealthyState
+		existingState, err = khStateClient.KuberhealthyStates(checkNamespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return errors.New("Error retrieving CRD for: " + name + " " + err.Error())
+		}
+
+		existingState.Spec.AppendHistory(entry, maxRunHistory())
+		_, err = khStateClient.KuberhealthyStates(checkNamespace).Update(&existingState)
+		if err == nil || !strings.Contains(err.Error(), "the object has been modified") {
+			return err
+		}
+		log.Debugln(checkNamespace, checkName, "khstate was modified while appending run history. Retrying.")
+	}
+	return err
+}
+
+// maxRunHistory returns the number of runs to keep in the history of each khstate
+func maxRunHistory() int {
+	if cfg.MaxRunHistory > 0 {
+		return cfg.MaxRunHistory
+	}
+	return defaultMaxRunHistory
+}
+
 // sanitizeResourceName cleans up the check names for use in CRDs.
 // DNS-1123 subdomains must consist of lower case alphanumeric characters, '-'
 // or '.', and must start and end with an alphanumeric character (e.g.
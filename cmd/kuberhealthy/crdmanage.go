@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// manageCRDs indicates that kuberhealthy should install and upgrade its own CRDs at startup.  Disabled with
+// --crd-manage=false for clusters where CRDs are managed some other way.
+var manageCRDs = true
+
+// crdEstablishTimeout is how long kuberhealthy waits for its CRDs to be established after applying them
+const crdEstablishTimeout = time.Second * 30
+
+// crdEstablishPollInterval is how often the CRDs are checked while waiting for them to be established
+const crdEstablishPollInterval = time.Second
+
+// crdSchemaHashAnnotation is the annotation that holds the hash of the spec of a CRD as shipped with kuberhealthy.
+// An installed CRD with the same hash as the embedded one needs no upgrade before kuberhealthy starts.
+const crdSchemaHashAnnotation = "comcast.github.io/crd-schema-hash"
+
+// crdGroupVersionResource is the resource of custom resource definitions
+var crdGroupVersionResource = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// ensureCRDs installs or upgrades the khcheck, khjob and khstate CRDs to the structural schemas shipped with this
+// version of kuberhealthy.  Applying them replaces older definitions left behind by previous installs, which keeps
+// the CRDs in the cluster from falling out of step with the fields kuberhealthy writes.  Clusters that do not permit
+// kuberhealthy to manage CRDs are left alone.  When every CRD is already established and has the schema hash of the
+// embedded CRD, kuberhealthy starts on the installed CRDs straight away and applies them again in the background, so
+// restarts and failovers do not wait on it.  CRDs installed by another version of kuberhealthy, or by anything else,
+// are upgraded and waited on first so that kuberhealthy never writes fields the installed schema would prune.
+//
+// Every Kuberhealthy CRD has only ever had the v1 version, so there is no conversion webhook.  Once the CRDs are
+// applied, objects still stored at any other version listed in the stored versions of a CRD are migrated to the
+// storage version.
+func ensureCRDs(ctx context.Context, client dynamic.Interface) error {
+	crds, err := installCRDs()
+	if err != nil {
+		return err
+	}
+
+	if crdsCurrent(ctx, client, crds) {
+		log.Infoln("Kuberhealthy CRDs are established and up to date. Applying them in the background")
+		go func() {
+			applied, err := applyCRDs(ctx, client, crds)
+			if err == nil && applied {
+				err = migrateCRDs(ctx, client, crds)
+			}
+			if err != nil {
+				log.Errorln("Failed to manage Kuberhealthy CRDs:", err)
+			}
+		}()
+		return nil
+	}
+
+	applied, err := applyCRDs(ctx, client, crds)
+	if err != nil || !applied {
+		return err
+	}
+	err = waitForCRDsEstablished(ctx, client, crds, crdEstablishTimeout)
+	if err != nil {
+		return err
+	}
+	return migrateCRDs(ctx, client, crds)
+}
+
+// applyCRDs applies the CRDs to the cluster.  Returns false when kuberhealthy is not permitted to manage its CRDs, in
+// which case it carries on with the CRDs already installed.
+func applyCRDs(ctx context.Context, client dynamic.Interface, crds []*unstructured.Unstructured) (bool, error) {
+	log.Infoln("Applying Kuberhealthy CRDs")
+	err := applyManifests(ctx, client, crds, installFieldManager, ioutil.Discard)
+	if k8sErrors.IsForbidden(err) {
+		log.Warningln("Kuberhealthy is not permitted to manage its CRDs and will use the CRDs already installed:", err)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// crdsCurrent determines if every CRD is already installed, established and has the schema hash of the embedded
+// CRD.  The CRDs are looked up in parallel.
+func crdsCurrent(ctx context.Context, client dynamic.Interface, crds []*unstructured.Unstructured) bool {
+	results := make(chan bool, len(crds))
+	for _, crd := range crds {
+		go func(crd *unstructured.Unstructured) {
+			current, err := crdCurrent(ctx, client, crd)
+			if err != nil {
+				log.Debugln("CRD", crd.GetName(), "could not be checked:", err)
+			}
+			results <- err == nil && current
+		}(crd)
+	}
+
+	allCurrent := true
+	for range crds {
+		if !<-results {
+			allCurrent = false
+		}
+	}
+	return allCurrent
+}
+
+// crdCurrent determines if the installed CRD with the name of the supplied one is established and has its schema hash
+func crdCurrent(ctx context.Context, client dynamic.Interface, crd *unstructured.Unstructured) (bool, error) {
+	installed, err := client.Resource(crdGroupVersionResource).Get(ctx, crd.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch CRD %s: %w", crd.GetName(), err)
+	}
+	hash := crd.GetAnnotations()[crdSchemaHashAnnotation]
+	if len(hash) == 0 || installed.GetAnnotations()[crdSchemaHashAnnotation] != hash {
+		log.Infoln("CRD", crd.GetName(), "differs from the one shipped with this version of Kuberhealthy")
+		return false, nil
+	}
+	return crdObjectEstablished(installed)
+}
+
+// setCRDSchemaHash annotates a CRD with the hash of its spec
+func setCRDSchemaHash(crd *unstructured.Unstructured) error {
+	spec, _, err := unstructured.NestedMap(crd.Object, "spec")
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(b)
+
+	annotations := crd.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[crdSchemaHashAnnotation] = hex.EncodeToString(sum[:])
+	crd.SetAnnotations(annotations)
+	return nil
+}
+
+// waitForCRDsEstablished waits until the API server reports every CRD as established so that informers started
+// afterwards can list and watch the resources.  The CRDs are waited on in parallel.
+func waitForCRDsEstablished(ctx context.Context, client dynamic.Interface, crds []*unstructured.Unstructured, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	errs := make(chan error, len(crds))
+	for _, crd := range crds {
+		go func(name string) {
+			errs <- waitForCRDEstablished(ctx, client, name)
+		}(crd.GetName())
+	}
+
+	var firstErr error
+	for range crds {
+		err := <-errs
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// waitForCRDEstablished polls the CRD with the supplied name until it is established or the context is done
+func waitForCRDEstablished(ctx context.Context, client dynamic.Interface, name string) error {
+	for {
+		established, err := crdEstablished(ctx, client, name)
+		if err != nil {
+			return err
+		}
+		if established {
+			log.Debugln("CRD", name, "is established")
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for CRD %s to be established", name)
+		case <-time.After(crdEstablishPollInterval):
+		}
+	}
+}
+
+// crdEstablished determines if the CRD with the supplied name has the Established condition
+func crdEstablished(ctx context.Context, client dynamic.Interface, name string) (bool, error) {
+	crd, err := client.Resource(crdGroupVersionResource).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch CRD %s: %w", name, err)
+	}
+	return crdObjectEstablished(crd)
+}
+
+// crdObjectEstablished determines if the supplied installed CRD has the Established condition
+func crdObjectEstablished(crd *unstructured.Unstructured) (bool, error) {
+	conditions, _, err := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	if err != nil {
+		return false, fmt.Errorf("failed to read conditions of CRD %s: %w", crd.GetName(), err)
+	}
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == "Established" && condition["status"] == "True" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// migrateCRDs migrates the stored objects of every CRD to its storage version
+func migrateCRDs(ctx context.Context, client dynamic.Interface, crds []*unstructured.Unstructured) error {
+	for _, crd := range crds {
+		err := migrateStoredVersions(ctx, client, crd)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateStoredVersions rewrites every object of the supplied CRD when the CRD lists stored versions other than its
+// storage version, so that the API server stores them at the storage version, and then drops the other versions from
+// the stored versions of the CRD.  Objects that are changed or deleted while they are being rewritten are already
+// stored at the storage version and are skipped.
+func migrateStoredVersions(ctx context.Context, client dynamic.Interface, crd *unstructured.Unstructured) error {
+	storageVersion, err := crdStorageVersion(crd)
+	if err != nil {
+		return err
+	}
+
+	installed, err := client.Resource(crdGroupVersionResource).Get(ctx, crd.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to fetch CRD %s: %w", crd.GetName(), err)
+	}
+	storedVersions, _, err := unstructured.NestedStringSlice(installed.Object, "status", "storedVersions")
+	if err != nil {
+		return fmt.Errorf("failed to read stored versions of CRD %s: %w", crd.GetName(), err)
+	}
+	if len(storedVersions) == 0 || (len(storedVersions) == 1 && storedVersions[0] == storageVersion) {
+		return nil
+	}
+
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+	resource := schema.GroupVersionResource{Group: group, Version: storageVersion, Resource: plural}
+	log.Infoln("Migrating", plural, "stored at versions", storedVersions, "to", storageVersion)
+
+	var migrated int
+	opts := metav1.ListOptions{Limit: 500}
+	for {
+		list, err := client.Resource(resource).List(ctx, opts)
+		if err != nil {
+			return fmt.Errorf("failed to list %s to migrate them: %w", plural, err)
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			_, err := client.Resource(resource).Namespace(obj.GetNamespace()).Update(ctx, obj, metav1.UpdateOptions{})
+			if k8sErrors.IsNotFound(err) || k8sErrors.IsConflict(err) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to migrate %s %s/%s: %w", plural, obj.GetNamespace(), obj.GetName(), err)
+			}
+			migrated++
+		}
+		opts.Continue = list.GetContinue()
+		if len(opts.Continue) == 0 {
+			break
+		}
+	}
+
+	err = unstructured.SetNestedStringSlice(installed.Object, []string{storageVersion}, "status", "storedVersions")
+	if err != nil {
+		return fmt.Errorf("failed to set stored versions of CRD %s: %w", crd.GetName(), err)
+	}
+	_, err = client.Resource(crdGroupVersionResource).UpdateStatus(ctx, installed, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update stored versions of CRD %s: %w", crd.GetName(), err)
+	}
+	log.Infoln("Migrated", migrated, plural, "to", storageVersion)
+	return nil
+}
+
+// crdStorageVersion returns the version the supplied CRD stores its objects at
+func crdStorageVersion(crd *unstructured.Unstructured) (string, error) {
+	versions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	if err != nil {
+		return "", fmt.Errorf("failed to read versions of CRD %s: %w", crd.GetName(), err)
+	}
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if ok && version["storage"] == true {
+			name, _ := version["name"].(string)
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("CRD %s has no storage version", crd.GetName())
+}
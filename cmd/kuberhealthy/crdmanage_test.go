@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// testCRD makes a CRD with the supplied name and established condition status
+func testCRD(name string, established string) *unstructured.Unstructured {
+	crd := &unstructured.Unstructured{}
+	crd.SetAPIVersion("apiextensions.k8s.io/v1")
+	crd.SetKind("CustomResourceDefinition")
+	crd.SetName(name)
+	unstructured.SetNestedSlice(crd.Object, []interface{}{
+		map[string]interface{}{"type": "Established", "status": established},
+	}, "status", "conditions")
+	return crd
+}
+
+// TestWaitForCRDsEstablished ensures that kuberhealthy waits for CRDs to be established and gives up after the timeout
+func TestWaitForCRDsEstablished(t *testing.T) {
+
+	var testCases = []struct {
+		description string
+		established string
+		expectError bool
+	}{
+		{"established", "True", false},
+		{"not established", "False", true},
+	}
+
+	for _, tc := range testCases {
+		crd := testCRD("khchecks.comcast.github.io", tc.established)
+		client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{crdGroupVersionResource: "CustomResourceDefinitionList"}, crd)
+
+		err := waitForCRDsEstablished(context.Background(), client, []*unstructured.Unstructured{crd}, crdEstablishPollInterval/2)
+		if tc.expectError != (err != nil) {
+			t.Fatalf("%s: error was %v but expected an error to be %t", tc.description, err, tc.expectError)
+		}
+	}
+}
+
+// TestEnsureCRDsForbidden ensures that kuberhealthy carries on with the installed CRDs when it is not permitted to
+// manage them
+func TestEnsureCRDsForbidden(t *testing.T) {
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{crdGroupVersionResource: "CustomResourceDefinitionList"})
+	client.PrependReactor("patch", "customresourcedefinitions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8sErrors.NewForbidden(crdGroupVersionResource.GroupResource(), "khchecks.comcast.github.io", nil)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	err := ensureCRDs(ctx, client)
+	if err != nil {
+		t.Fatalf("expected forbidden CRD management to be skipped but got: %s", err)
+	}
+}
+
+// TestEnsureCRDsEstablished ensures that kuberhealthy does not wait on CRDs that are already established and up to
+// date and applies them in the background instead, while CRDs with another schema are applied before it starts
+func TestEnsureCRDsEstablished(t *testing.T) {
+
+	crds, err := installCRDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		description string
+		schemaHash  func(crd *unstructured.Unstructured) string
+		background  bool
+	}{
+		{"up to date", func(crd *unstructured.Unstructured) string { return crd.GetAnnotations()[crdSchemaHashAnnotation] }, true},
+		{"other schema", func(crd *unstructured.Unstructured) string { return "0123" }, false},
+		{"no schema hash", func(crd *unstructured.Unstructured) string { return "" }, false},
+	}
+
+	for _, tc := range testCases {
+		var objects []runtime.Object
+		for _, crd := range crds {
+			installed := testCRD(crd.GetName(), "True")
+			if hash := tc.schemaHash(crd); len(hash) > 0 {
+				installed.SetAnnotations(map[string]string{crdSchemaHashAnnotation: hash})
+			}
+			objects = append(objects, installed)
+		}
+		client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{crdGroupVersionResource: "CustomResourceDefinitionList"}, objects...)
+
+		var patches int32
+		applied := make(chan struct{})
+		client.PrependReactor("patch", "customresourcedefinitions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if atomic.AddInt32(&patches, 1) == int32(len(crds)) {
+				close(applied)
+			}
+			return true, &unstructured.Unstructured{}, nil
+		})
+
+		err = ensureCRDs(context.Background(), client)
+		if err != nil {
+			t.Fatalf("%s: expected established CRDs to be ensured without error but got: %s", tc.description, err)
+		}
+		if !tc.background && atomic.LoadInt32(&patches) != int32(len(crds)) {
+			t.Fatalf("%s: expected %d CRDs to be applied before starting but %d were", tc.description, len(crds), atomic.LoadInt32(&patches))
+		}
+
+		select {
+		case <-applied:
+		case <-time.After(time.Second * 5):
+			t.Fatalf("%s: expected %d CRDs to be applied but %d were", tc.description, len(crds), atomic.LoadInt32(&patches))
+		}
+	}
+}
+
+// TestCRDSchemaHash ensures that embedded CRDs are annotated with a hash that changes with their spec
+func TestCRDSchemaHash(t *testing.T) {
+	crds, err := installCRDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := installCRDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crd := crds[0]
+	hash := crd.GetAnnotations()[crdSchemaHashAnnotation]
+	if len(hash) == 0 || again[0].GetAnnotations()[crdSchemaHashAnnotation] != hash {
+		t.Fatalf("expected a stable schema hash on CRD %s but got %q and %q", crd.GetName(), hash, again[0].GetAnnotations()[crdSchemaHashAnnotation])
+	}
+
+	unstructured.SetNestedField(crd.Object, "Cluster", "spec", "scope")
+	err = setCRDSchemaHash(crd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crd.GetAnnotations()[crdSchemaHashAnnotation] == hash {
+		t.Fatalf("expected the schema hash of CRD %s to change with its spec", crd.GetName())
+	}
+}
+
+// TestMigrateStoredVersions ensures that objects stored at older versions are rewritten and that the stored versions
+// of the CRD are trimmed to the storage version afterwards
+func TestMigrateStoredVersions(t *testing.T) {
+
+	crds, err := installCRDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var crd *unstructured.Unstructured
+	for _, c := range crds {
+		if c.GetName() == "khchecks.comcast.github.io" {
+			crd = c
+		}
+	}
+	if crd == nil {
+		t.Fatal("expected the khcheck CRD to be installed")
+	}
+
+	installed := testCRD(crd.GetName(), "True")
+	unstructured.SetNestedStringSlice(installed.Object, []string{"v1beta1", "v1"}, "status", "storedVersions")
+	check := &unstructured.Unstructured{}
+	check.SetAPIVersion("comcast.github.io/v1")
+	check.SetKind("KuberhealthyCheck")
+	check.SetNamespace("kuberhealthy")
+	check.SetName("pod-restarts")
+	khchecks := schema.GroupVersionResource{Group: "comcast.github.io", Version: "v1", Resource: "khchecks"}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{crdGroupVersionResource: "CustomResourceDefinitionList", khchecks: "KuberhealthyCheckList"}, installed)
+	_, err = client.Resource(khchecks).Namespace(check.GetNamespace()).Create(context.Background(), check, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var updates int32
+	client.PrependReactor("update", "khchecks", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		atomic.AddInt32(&updates, 1)
+		return false, nil, nil
+	})
+
+	err = migrateStoredVersions(context.Background(), client, crd)
+	if err != nil {
+		t.Fatal("expected stored versions to be migrated without error but got:", err)
+	}
+	if atomic.LoadInt32(&updates) != 1 {
+		t.Fatalf("expected the khcheck to be rewritten once but it was rewritten %d times", updates)
+	}
+
+	migrated, err := client.Resource(crdGroupVersionResource).Get(context.Background(), crd.GetName(), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storedVersions, _, _ := unstructured.NestedStringSlice(migrated.Object, "status", "storedVersions")
+	if len(storedVersions) != 1 || storedVersions[0] != "v1" {
+		t.Fatalf("expected the stored versions to be trimmed to v1 but got %v", storedVersions)
+	}
+
+	// a CRD stored only at its storage version is left alone
+	err = migrateStoredVersions(context.Background(), client, crd)
+	if err != nil || atomic.LoadInt32(&updates) != 1 {
+		t.Fatalf("expected a migrated CRD to be left alone but got error %v and %d rewrites", err, updates)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+)
+
+// defaultAPIProbeInterval is how often the availability of the Kubernetes API and the Kuberhealthy CRDs is checked
+const defaultAPIProbeInterval = time.Second * 15
+
+// degradedMode tracks when the Kubernetes API or the Kuberhealthy CRDs become unavailable.  While they are, the status
+// page keeps serving the last known state of checks from the khstate cache marked as stale, and khstate writes are
+// queued until the API returns instead of failing.
+type degradedMode struct {
+	sync.Mutex
+	since  time.Time                   // when the API was first seen unavailable, zero while it is available
+	queued map[string]queuedStateWrite // khstate writes waiting for the API to return, keyed by namespace/name
+}
+
+// queuedStateWrite is a khstate write held back while the API is unavailable
+type queuedStateWrite struct {
+	name      string
+	namespace string
+	details   khstatev1.WorkloadDetails
+}
+
+// probeStateAPI checks that khstates and khchecks can be listed from the API
+var probeStateAPI = func() error {
+	_, err := khStateClient.KuberhealthyStates(cfg.ListenNamespace).List(metav1.ListOptions{Limit: 1})
+	if err != nil {
+		return err
+	}
+	_, err = khCheckClient.KuberhealthyChecks(cfg.ListenNamespace).List(metav1.ListOptions{Limit: 1})
+	return err
+}
+
+// markDegraded records that the API is unavailable.  Only the first failure of an outage is logged.
+func (d *degradedMode) markDegraded(now time.Time, err error) {
+	d.Lock()
+	defer d.Unlock()
+	if !d.since.IsZero() {
+		return
+	}
+	d.since = now
+	log.Warningln("degraded: Kubernetes API or Kuberhealthy CRDs are unavailable. Serving the last known status and queueing khstate writes:", err)
+}
+
+// markAvailable records that the API is available again and returns the khstate writes queued while it was not
+func (d *degradedMode) markAvailable() []queuedStateWrite {
+	d.Lock()
+	defer d.Unlock()
+	if !d.since.IsZero() {
+		log.Infoln("degraded: Kubernetes API is available again after", time.Since(d.since).Round(time.Second), "with", len(d.queued), "queued khstate writes")
+	}
+	d.since = time.Time{}
+
+	writes := make([]queuedStateWrite, 0, len(d.queued))
+	for _, w := range d.queued {
+		writes = append(writes, w)
+	}
+	d.queued = nil
+	return writes
+}
+
+// degradedSince returns when the API became unavailable and if it still is
+func (d *degradedMode) degradedSince() (time.Time, bool) {
+	d.Lock()
+	defer d.Unlock()
+	return d.since, !d.since.IsZero()
+}
+
+// queue holds a khstate write until the API returns.  A newer write for the same khstate replaces the queued one.
+func (d *degradedMode) queue(name string, namespace string, details khstatev1.WorkloadDetails) {
+	d.Lock()
+	defer d.Unlock()
+	if d.queued == nil {
+		d.queued = make(map[string]queuedStateWrite)
+	}
+	d.queued[namespace+"/"+name] = queuedStateWrite{name: name, namespace: namespace, details: details}
+}
+
+// queuedWrites returns how many khstate writes are waiting for the API to return
+func (d *degradedMode) queuedWrites() int {
+	d.Lock()
+	defer d.Unlock()
+	return len(d.queued)
+}
+
+// drop forgets a queued khstate write once a newer state was written, so that the stale one is not written over it
+func (d *degradedMode) drop(name string, namespace string) {
+	d.Lock()
+	defer d.Unlock()
+	delete(d.queued, namespace+"/"+name)
+}
+
+// queueIfDegraded queues a khstate write that failed because the API is unavailable and reports if it did.  Writes
+// that failed for any other reason, such as throttling or validation, are left to the caller.
+func (k *Kuberhealthy) queueIfDegraded(name string, namespace string, details khstatev1.WorkloadDetails, writeErr error) bool {
+	if _, throttled := retryAfterForError(writeErr); throttled {
+		return false
+	}
+	err := probeStateAPI()
+	if err == nil {
+		return false
+	}
+	k.degraded.markDegraded(k.clock().Now(), err)
+	k.degraded.queue(name, namespace, details)
+	log.Warningln("degraded: Queued khstate write for", namespace+"/"+name, "until the Kubernetes API is available:", writeErr)
+	return true
+}
+
+// monitorAPIAvailability checks the availability of the API on an interval until the supplied context is canceled.
+// Writes queued while the API was unavailable are made once it returns.
+func (k *Kuberhealthy) monitorAPIAvailability(ctx context.Context) {
+	ticker := time.NewTicker(defaultAPIProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		k.checkAPIAvailability()
+	}
+}
+
+// checkAPIAvailability probes the API once and writes the queued khstates if it is available
+func (k *Kuberhealthy) checkAPIAvailability() {
+	err := probeStateAPI()
+	if err != nil {
+		k.degraded.markDegraded(k.clock().Now(), err)
+		return
+	}
+	for _, w := range k.degraded.markAvailable() {
+		err := k.storeCheckState(w.name, w.namespace, w.details)
+		if err != nil {
+			log.Errorln("degraded: Failed to write queued khstate for", w.namespace+"/"+w.name+":", err)
+		}
+	}
+}
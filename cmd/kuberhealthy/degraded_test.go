@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+)
+
+// TestStoreCheckStateWhileDegraded ensures khstate writes are queued while the API is unavailable, written once it
+// returns and never written over a newer state
+func TestStoreCheckStateWhileDegraded(t *testing.T) {
+	apiErr := errors.New("connection refused")
+	var apiDown bool
+	written := make(map[string]khstatev1.WorkloadDetails)
+
+	defer func(probe func() error, write func(string, string, khstatev1.WorkloadDetails) error) {
+		probeStateAPI = probe
+		writeCheckState = write
+	}(probeStateAPI, writeCheckState)
+	probeStateAPI = func() error {
+		if apiDown {
+			return apiErr
+		}
+		return nil
+	}
+	writeCheckState = func(checkName string, checkNamespace string, details khstatev1.WorkloadDetails) error {
+		if apiDown {
+			return apiErr
+		}
+		written[checkNamespace+"/"+checkName] = details
+		return nil
+	}
+
+	k := &Kuberhealthy{}
+	apiDown = true
+	failing := khstatev1.NewWorkloadDetails(khstatev1.KHCheck)
+	failing.Errors = []string{"dns lookup failed"}
+	for _, name := range []string{"dns", "ntp"} {
+		err := k.storeCheckState(name, "kuberhealthy", failing)
+		if err != nil {
+			t.Fatalf("expected the %s state to be queued, got %v", name, err)
+		}
+	}
+	if _, degraded := k.degraded.degradedSince(); !degraded || len(written) != 0 {
+		t.Fatalf("expected to be degraded with nothing written, wrote %v", written)
+	}
+
+	// a state written after the api returns is newer than the queued one
+	apiDown = false
+	passing := khstatev1.NewWorkloadDetails(khstatev1.KHCheck)
+	passing.OK = true
+	err := k.storeCheckState("ntp", "kuberhealthy", passing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k.checkAPIAvailability()
+	if _, degraded := k.degraded.degradedSince(); degraded {
+		t.Fatal("expected to no longer be degraded once the api returned")
+	}
+	if len(written["kuberhealthy/dns"].Errors) != 1 || !written["kuberhealthy/ntp"].OK {
+		t.Fatalf("expected the queued dns state and the newer ntp state, wrote %v", written)
+	}
+}
+
+// TestStoreCheckStateError ensures writes that fail while the API is available are not queued
+func TestStoreCheckStateError(t *testing.T) {
+	defer func(probe func() error, write func(string, string, khstatev1.WorkloadDetails) error) {
+		probeStateAPI = probe
+		writeCheckState = write
+	}(probeStateAPI, writeCheckState)
+	probeStateAPI = func() error { return nil }
+	writeCheckState = func(checkName string, checkNamespace string, details khstatev1.WorkloadDetails) error {
+		return errors.New("admission webhook denied the request")
+	}
+
+	k := &Kuberhealthy{}
+	err := k.storeCheckState("dns", "kuberhealthy", khstatev1.NewWorkloadDetails(khstatev1.KHCheck))
+	if err == nil {
+		t.Fatal("expected the write error to be returned")
+	}
+	if _, degraded := k.degraded.degradedSince(); degraded || len(k.degraded.queued) != 0 {
+		t.Fatal("expected nothing to be queued while the api is available")
+	}
+}
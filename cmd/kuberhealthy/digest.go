@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/digest"
+)
+
+// monitorDigests sends a digest of the check results every time one is due on the configured schedule until the
+// supplied context is canceled.  Only the master sends digests.
+func (k *Kuberhealthy) monitorDigests(ctx context.Context) {
+	err := cfg.Digest.Validate()
+	if err != nil {
+		log.Errorln("digest: Not sending digests:", err)
+		return
+	}
+
+	for {
+		next := digest.NextSend(cfg.Digest.Schedule, time.Now())
+		log.Infoln("digest: Sending the next", cfg.Digest.Schedule, "digest at", next)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !isMaster {
+			log.Debugln("digest: Not sending the digest because this instance is not the master")
+			continue
+		}
+		err := sendDigest(ctx, next)
+		if err != nil {
+			log.Errorln("digest:", err)
+		}
+	}
+}
+
+// sendDigest builds the digest of the period ending at end and sends it to the configured destinations
+func sendDigest(ctx context.Context, end time.Time) error {
+	checks, err := khCheckClient.KuberhealthyChecks("").List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list khchecks: %w", err)
+	}
+	states, err := khStateClient.KuberhealthyStates("").List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list khstates: %w", err)
+	}
+
+	d := digest.Build(cfg.Digest, digestChecks(checks.Items, states.Items), end)
+	errs := digest.Send(ctx, cfg.Digest, d)
+	for _, err := range errs {
+		log.Errorln("digest:", err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to send the %s digest to %d destinations", d.Schedule, len(errs))
+	}
+	log.Infoln("digest: Sent the", d.Schedule, "digest of", len(checks.Items), "khchecks")
+	return nil
+}
+
+// digestChecks pairs each khcheck with the run history of its khstate.  Checks without a khstate have no history.
+func digestChecks(checks []khcheckv1.KuberhealthyCheck, states []khstatev1.KuberhealthyState) []digest.Check {
+	histories := make(map[string][]khstatev1.RunHistoryEntry)
+	for _, state := range states {
+		histories[state.Namespace+"/"+state.Name] = state.Spec.History
+	}
+
+	var digestChecks []digest.Check
+	for _, c := range checks {
+		digestChecks = append(digestChecks, digest.Check{
+			Name:      c.Name,
+			Namespace: c.Namespace,
+			Labels:    c.Labels,
+			Created:   c.CreationTimestamp.Time,
+			History:   histories[c.Namespace+"/"+c.Name],
+		})
+	}
+	return digestChecks
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+)
+
+// TestDigestChecks ensures khchecks are paired with the run history of the khstate with the same name and namespace
+func TestDigestChecks(t *testing.T) {
+	checks := []khcheckv1.KuberhealthyCheck{
+		khcheckv1.NewKuberhealthyCheck("dns", "kuberhealthy", khcheckv1.CheckConfig{}),
+		khcheckv1.NewKuberhealthyCheck("dns", "payments", khcheckv1.CheckConfig{}),
+	}
+	state := khstatev1.NewKuberhealthyState("dns", khstatev1.WorkloadDetails{})
+	state.Namespace = "kuberhealthy"
+	state.Spec.History = []khstatev1.RunHistoryEntry{{UUID: "run-1", Result: khstatev1.RunSuccess}}
+
+	digestChecks := digestChecks(checks, []khstatev1.KuberhealthyState{state})
+	if len(digestChecks) != 2 {
+		t.Fatalf("expected 2 checks, got %d", len(digestChecks))
+	}
+	if len(digestChecks[0].History) != 1 || digestChecks[0].History[0].UUID != "run-1" {
+		t.Fatalf("kuberhealthy/dns has history %+v", digestChecks[0].History)
+	}
+	if len(digestChecks[1].History) != 0 {
+		t.Fatalf("payments/dns should have no history, got %+v", digestChecks[1].History)
+	}
+}
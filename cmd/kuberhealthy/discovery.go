@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/discovery"
+)
+
+// defaultDiscoveryInterval is how often khchecks are generated from the probe annotations of services and ingresses
+const defaultDiscoveryInterval = time.Minute
+
+// discoveryOptions returns the defaults of discovered khchecks, with those set in the config taking precedence
+func discoveryOptions() discovery.Options {
+	opts := discovery.DefaultOptions()
+	if len(cfg.DiscoveryHTTPImage) > 0 {
+		opts.HTTPImage = cfg.DiscoveryHTTPImage
+	}
+	if len(cfg.DiscoveryTCPImage) > 0 {
+		opts.TCPImage = cfg.DiscoveryTCPImage
+	}
+	if len(cfg.DiscoveryDefaultInterval) > 0 {
+		opts.DefaultInterval = cfg.DiscoveryDefaultInterval
+	}
+	return opts
+}
+
+// collectDiscovery lists the services and ingresses of the cluster and generates the khchecks that their probe
+// annotations ask for.  Objects with invalid annotations are skipped with a warning.
+func collectDiscovery(ctx context.Context, client kubernetes.Interface, namespace string, opts discovery.Options) ([]khcheckv1.KuberhealthyCheck, error) {
+	var desired []khcheckv1.KuberhealthyCheck
+
+	services, err := client.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+	for _, svc := range services.Items {
+		check, err := discovery.ForService(svc, opts)
+		if err != nil {
+			log.Warningln("discovery: skipping service", svc.Namespace+"/"+svc.Name+":", err)
+			continue
+		}
+		if check != nil {
+			desired = append(desired, *check)
+		}
+	}
+
+	ingresses, err := client.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ingresses: %w", err)
+	}
+	for _, ing := range ingresses.Items {
+		check, err := discovery.ForIngress(ing, opts)
+		if err != nil {
+			log.Warningln("discovery: skipping ingress", ing.Namespace+"/"+ing.Name+":", err)
+			continue
+		}
+		if check != nil {
+			desired = append(desired, *check)
+		}
+	}
+	return desired, nil
+}
+
+// generatedPlan is what must change for the generated khchecks on the cluster to match the desired ones
+type generatedPlan struct {
+	Create []khcheckv1.KuberhealthyCheck
+	Update []khcheckv1.KuberhealthyCheck
+	Delete []khcheckv1.KuberhealthyCheck
+}
+
+// planGeneratedChecks compares the khchecks on the cluster with the desired ones.  Only khchecks that the supplied
+// func reports as generated are ever updated or deleted, so a khcheck written by hand is never overwritten by one of
+// the same name.
+func planGeneratedChecks(existing []khcheckv1.KuberhealthyCheck, desired []khcheckv1.KuberhealthyCheck, generated func(khcheckv1.KuberhealthyCheck) bool) generatedPlan {
+	current := make(map[string]khcheckv1.KuberhealthyCheck)
+	for _, c := range existing {
+		current[c.Namespace+"/"+c.Name] = c
+	}
+
+	var plan generatedPlan
+	wanted := make(map[string]bool)
+	for _, d := range desired {
+		key := d.Namespace + "/" + d.Name
+		wanted[key] = true
+		c, ok := current[key]
+		switch {
+		case !ok:
+			plan.Create = append(plan.Create, d)
+		case !generated(c):
+			log.Warningln("not replacing khcheck", key, "with a generated khcheck because it was written by hand")
+		case c.Annotations[discovery.SpecHashAnnotation] != d.Annotations[discovery.SpecHashAnnotation]:
+			c.Spec = d.Spec
+			c.Labels = d.Labels
+			c.Annotations = d.Annotations
+			c.OwnerReferences = d.OwnerReferences
+			plan.Update = append(plan.Update, c)
+		}
+	}
+
+	for _, c := range existing {
+		if generated(c) && !wanted[c.Namespace+"/"+c.Name] {
+			plan.Delete = append(plan.Delete, c)
+		}
+	}
+	sort.Slice(plan.Delete, func(i, j int) bool {
+		return plan.Delete[i].Namespace+"/"+plan.Delete[i].Name < plan.Delete[j].Namespace+"/"+plan.Delete[j].Name
+	})
+	return plan
+}
+
+// syncGeneratedChecks creates, updates and deletes generated khchecks so that they match the desired ones.  The
+// existing khchecks are those listed by the label of the generator.  Log lines start with the supplied prefix.
+func syncGeneratedChecks(prefix string, existing []khcheckv1.KuberhealthyCheck, desired []khcheckv1.KuberhealthyCheck, generated func(khcheckv1.KuberhealthyCheck) bool) {
+	// khchecks written by hand are looked up by name so that they are not overwritten
+	listed := make(map[string]bool)
+	for _, c := range existing {
+		listed[c.Namespace+"/"+c.Name] = true
+	}
+	for _, d := range desired {
+		if listed[d.Namespace+"/"+d.Name] {
+			continue
+		}
+		c, err := khCheckClient.KuberhealthyChecks(d.Namespace).Get(d.Name, metav1.GetOptions{})
+		if err == nil {
+			existing = append(existing, c)
+		}
+	}
+
+	plan := planGeneratedChecks(existing, desired, generated)
+	for i := range plan.Create {
+		c := plan.Create[i]
+		log.Infoln(prefix+": creating khcheck", c.Namespace+"/"+c.Name, "for", c.Annotations[discovery.SourceAnnotation])
+		_, err := khCheckClient.KuberhealthyChecks(c.Namespace).Create(&c)
+		if err != nil {
+			log.Errorln(prefix+": failed to create khcheck", c.Namespace+"/"+c.Name+":", err)
+		}
+	}
+	for i := range plan.Update {
+		c := plan.Update[i]
+		log.Infoln(prefix+": updating khcheck", c.Namespace+"/"+c.Name, "for", c.Annotations[discovery.SourceAnnotation])
+		_, err := khCheckClient.KuberhealthyChecks(c.Namespace).Update(&c)
+		if err != nil {
+			log.Errorln(prefix+": failed to update khcheck", c.Namespace+"/"+c.Name+":", err)
+		}
+	}
+	for _, c := range plan.Delete {
+		log.Infoln(prefix+": deleting khcheck", c.Namespace+"/"+c.Name, "because", c.Annotations[discovery.SourceAnnotation], "is no longer probed")
+		err := khCheckClient.KuberhealthyChecks(c.Namespace).Delete(c.Name, &metav1.DeleteOptions{})
+		if err != nil {
+			log.Errorln(prefix+": failed to delete khcheck", c.Namespace+"/"+c.Name+":", err)
+		}
+	}
+}
+
+// discovered tells if a khcheck was generated from probe annotations
+func discovered(c khcheckv1.KuberhealthyCheck) bool {
+	return c.Labels[discovery.DiscoveredLabel] == "true"
+}
+
+// monitorDiscovery generates khchecks from probe annotations on an interval until the supplied context is canceled.
+// Only the master instance makes changes.
+func (k *Kuberhealthy) monitorDiscovery(ctx context.Context) {
+	log.Infoln("discovery: Generating khchecks from probe annotations every", defaultDiscoveryInterval)
+	ticker := time.NewTicker(defaultDiscoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		if isMaster {
+			k.reconcileDiscovery(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcileDiscovery creates, updates and deletes discovered khchecks so that they match the probe annotations
+func (k *Kuberhealthy) reconcileDiscovery(ctx context.Context) {
+	desired, err := collectDiscovery(ctx, kubernetesClient, cfg.ListenNamespace, discoveryOptions())
+	if err != nil {
+		log.Errorln("discovery:", err)
+		return
+	}
+
+	existing, err := khCheckClient.KuberhealthyChecks(cfg.ListenNamespace).List(metav1.ListOptions{LabelSelector: discovery.DiscoveredLabel + "=true"})
+	if err != nil {
+		log.Errorln("discovery: failed to list discovered khchecks:", err)
+		return
+	}
+	syncGeneratedChecks("discovery", existing.Items, desired, discovered)
+}
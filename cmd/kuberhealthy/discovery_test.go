@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/discovery"
+)
+
+// TestCollectDiscovery ensures that annotated services and ingresses get khchecks and invalid annotations are skipped
+func TestCollectDiscovery(t *testing.T) {
+	port := []corev1.ServicePort{{Name: "http", Port: 80}}
+	client := fake.NewSimpleClientset(
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "app", Annotations: map[string]string{discovery.ProbePathAnnotation: "/healthz"}}, Spec: corev1.ServiceSpec{Ports: port}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "plain", Namespace: "app"}, Spec: corev1.ServiceSpec{Ports: port}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "broken", Namespace: "app", Annotations: map[string]string{discovery.ProbeTypeAnnotation: "udp"}}, Spec: corev1.ServiceSpec{Ports: port}},
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "app", Annotations: map[string]string{discovery.ProbePathAnnotation: "/"}},
+			Spec:       networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{{Host: "site.example.com"}}},
+		},
+	)
+
+	desired, err := collectDiscovery(context.Background(), client, "", discovery.DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to collect discovered khchecks: %s", err)
+	}
+	if len(desired) != 2 || desired[0].Name != "svc-web-probe" || desired[1].Name != "ing-site-probe" {
+		t.Fatalf("expected khchecks for the web service and the site ingress, got %+v", desired)
+	}
+}
+
+// TestPlanGeneratedChecks ensures that discovered khchecks are created, updated and deleted and hand written ones are left alone
+func TestPlanGeneratedChecks(t *testing.T) {
+	check := func(name string, discovered bool, hash string) khcheckv1.KuberhealthyCheck {
+		c := khcheckv1.NewKuberhealthyCheck(name, "app", khcheckv1.CheckConfig{RunInterval: hash})
+		if discovered {
+			c.Labels = map[string]string{discovery.DiscoveredLabel: "true"}
+		}
+		c.Annotations = map[string]string{discovery.SpecHashAnnotation: hash}
+		return c
+	}
+
+	existing := []khcheckv1.KuberhealthyCheck{
+		check("svc-same-probe", true, "a"),
+		check("svc-changed-probe", true, "a"),
+		check("svc-gone-probe", true, "a"),
+		check("svc-manual-probe", false, "a"),
+	}
+	desired := []khcheckv1.KuberhealthyCheck{
+		check("svc-same-probe", true, "a"),
+		check("svc-changed-probe", true, "b"),
+		check("svc-new-probe", true, "a"),
+		check("svc-manual-probe", true, "b"),
+	}
+
+	plan := planGeneratedChecks(existing, desired, discovered)
+	if len(plan.Create) != 1 || plan.Create[0].Name != "svc-new-probe" {
+		t.Fatalf("expected svc-new-probe to be created, got %+v", plan.Create)
+	}
+	if len(plan.Update) != 1 || plan.Update[0].Name != "svc-changed-probe" || plan.Update[0].Spec.RunInterval != "b" {
+		t.Fatalf("expected svc-changed-probe to be updated, got %+v", plan.Update)
+	}
+	if len(plan.Delete) != 1 || plan.Delete[0].Name != "svc-gone-probe" {
+		t.Fatalf("expected svc-gone-probe to be deleted, got %+v", plan.Delete)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// validateErrorDetails ensures the structured errors of a report are valid and describe its errors.  Details are
+// only accepted on failure reports and each of them must match an error of the report.
+func validateErrorDetails(state status.Report) error {
+	if len(state.ErrorDetails) == 0 {
+		return nil
+	}
+	if state.OK {
+		return fmt.Errorf("report is OK but has %d error details", len(state.ErrorDetails))
+	}
+	reported := make(map[string]bool, len(state.Errors))
+	for _, e := range state.Errors {
+		reported[e] = true
+	}
+	for _, e := range state.ErrorDetails {
+		if err := e.Validate(); err != nil {
+			return err
+		}
+		if !reported[e.Message] {
+			return fmt.Errorf("error detail %q is not one of the errors of the report", e.Message)
+		}
+	}
+	return nil
+}
+
+// newErrorDetails turns the structured errors of a report into the error details stored on its khstate.  Errors
+// without a severity are critical.
+func newErrorDetails(checkErrors []status.CheckError) []khstatev1.ErrorDetail {
+	if len(checkErrors) == 0 {
+		return nil
+	}
+	details := make([]khstatev1.ErrorDetail, 0, len(checkErrors))
+	for _, e := range checkErrors {
+		severity := e.Severity
+		if len(severity) == 0 {
+			severity = status.SeverityCritical
+		}
+		details = append(details, khstatev1.ErrorDetail{Message: e.Message, Severity: severity, Code: e.Code, Metadata: e.Metadata})
+	}
+	return details
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestValidateErrorDetails ensures error details are only accepted when they are valid and describe the report errors
+func TestValidateErrorDetails(t *testing.T) {
+	var testCases = []struct {
+		description string
+		report      status.Report
+		expectErr   bool
+	}{
+		{"no details", status.NewReport([]string{"failed"}), false},
+		{"detailed report", status.NewDetailedReport([]status.CheckError{{Message: "failed", Code: "FAILED"}}), false},
+		{"details on an OK report", status.Report{OK: true, ErrorDetails: []status.CheckError{{Message: "failed"}}}, true},
+		{"unknown severity", status.Report{Errors: []string{"failed"}, ErrorDetails: []status.CheckError{{Message: "failed", Severity: "fatal"}}}, true},
+		{"detail without an error", status.Report{Errors: []string{"failed"}, ErrorDetails: []status.CheckError{{Message: "other"}}}, true},
+	}
+	for _, tc := range testCases {
+		err := validateErrorDetails(tc.report)
+		if (err != nil) != tc.expectErr {
+			t.Fatalf("%s: returned error %v but expected an error: %t", tc.description, err, tc.expectErr)
+		}
+	}
+}
+
+// TestNewErrorDetails ensures the structured errors of a report are stored with a severity
+func TestNewErrorDetails(t *testing.T) {
+	if details := newErrorDetails(nil); details != nil {
+		t.Fatalf("expected no error details but got %+v", details)
+	}
+	details := newErrorDetails([]status.CheckError{{Message: "failed", Code: "FAILED", Metadata: map[string]string{"host": "a"}}})
+	if len(details) != 1 || details[0].Severity != status.SeverityCritical || details[0].Code != "FAILED" || details[0].Metadata["host"] != "a" {
+		t.Fatalf("unexpected error details %+v", details)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// extendRun grants a deadline extension to a run and returns the response for the checker pod along with the HTTP
+// status code to send it with
+func extendRun(runs *external.RunTracker, uuid string, req status.ExtensionRequest, now time.Time) (status.Extension, int, error) {
+	if req.Seconds <= 0 {
+		return status.Extension{}, http.StatusBadRequest, errors.New("extension must be at least one second")
+	}
+
+	deadline, granted, err := runs.Extend(uuid, time.Duration(req.Seconds)*time.Second, now)
+	switch {
+	case errors.Is(err, external.ErrExtensionNotAllowed), errors.Is(err, external.ErrExtensionExhausted):
+		return status.Extension{Deadline: deadline.Unix()}, http.StatusForbidden, err
+	case err != nil:
+		return status.Extension{}, http.StatusGone, err
+	}
+	return status.Extension{Deadline: deadline.Unix(), Granted: int64(granted / time.Second)}, http.StatusOK, nil
+}
+
+// extendRunNamespaceDeadline moves the deadline annotation of a run's ephemeral namespace along with the run so
+// that the reaper does not remove it while the run is still going.  Runs without an ephemeral namespace are skipped.
+func extendRunNamespaceDeadline(ctx context.Context, client kubernetes.Interface, checkName string, runUUID string, deadline time.Time) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{external.RunNamespaceDeadlineAnnotation: deadline.UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	name := external.RunNamespaceName(checkName, runUUID)
+	_, err = client.CoreV1().Namespaces().Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return fmt.Errorf("failed to move the deadline of run namespace %s: %w", name, err)
+	}
+	return nil
+}
+
+// extendDeadlineHandler lets a running checker pod push back the deadline of its run.  The calling pod is validated
+// the same way as status reports.  Checks must allow extensions with maxDeadlineExtension, and runs can't be
+// extended by more than that in total.
+func (k *Kuberhealthy) extendDeadlineHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+	requestID := "web: " + getRequestID(r)
+	ctx := r.Context()
+
+	podReport, validated, err := k.validateUsingRequestHeader(ctx, r)
+	if !validated && !errors.Is(err, errLateReport) {
+		podReport, err = k.validatePodReportBySourceIP(ctx, r)
+	}
+	if errors.Is(err, errLateReport) {
+		log.Infoln(requestID, "Run", podReport.UUID, "asked for a deadline extension after it was timed out")
+		w.WriteHeader(http.StatusGone)
+		return nil
+	}
+	if err != nil && !errors.Is(err, errOvertakenReport) {
+		log.Infoln(requestID, "Failed to validate deadline extension request from", r.RemoteAddr+":", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+
+	req := status.ExtensionRequest{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Infoln(requestID, "Failed to decode deadline extension request:", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+
+	extension, code, err := extendRun(k.runTracker, podReport.UUID, req, time.Now())
+	if err != nil {
+		log.Infoln(requestID, "Refused deadline extension of", req.Seconds, "seconds for run", podReport.UUID, "of", podReport.Namespace+"/"+podReport.Name+":", err)
+	} else {
+		log.Infoln(requestID, "Extended the deadline of run", podReport.UUID, "of", podReport.Namespace+"/"+podReport.Name, "by", extension.Granted, "seconds")
+		err = extendRunNamespaceDeadline(ctx, kubernetesClient, podReport.Name, podReport.UUID, time.Unix(extension.Deadline, 0))
+		if err != nil {
+			log.Warningln(requestID, err)
+		}
+	}
+
+	b, err := json.Marshal(extension)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to marshal deadline extension: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestExtendRun ensures deadline extension requests are answered with the right status codes
+func TestExtendRun(t *testing.T) {
+	runs := external.NewRunTracker()
+	now := time.Now()
+	deadline := now.Add(time.Minute)
+	runs.Start("allowed", "check", "kuberhealthy", "check-1", deadline)
+	runs.AllowExtension("allowed", time.Minute)
+	runs.Start("not-allowed", "check", "kuberhealthy", "check-1", deadline)
+
+	var testCases = []struct {
+		uuid     string
+		seconds  int64
+		code     int
+		deadline int64
+		granted  int64
+	}{
+		{"allowed", 0, http.StatusBadRequest, 0, 0},
+		{"allowed", 45, http.StatusOK, deadline.Add(45 * time.Second).Unix(), 45},
+		{"allowed", 45, http.StatusOK, deadline.Add(time.Minute).Unix(), 15},
+		{"allowed", 45, http.StatusForbidden, deadline.Add(time.Minute).Unix(), 0},
+		{"not-allowed", 45, http.StatusForbidden, deadline.Unix(), 0},
+		{"unknown", 45, http.StatusGone, 0, 0},
+	}
+
+	for _, tc := range testCases {
+		extension, code, _ := extendRun(runs, tc.uuid, status.ExtensionRequest{Seconds: tc.seconds}, now)
+		if code != tc.code || extension.Deadline != tc.deadline || extension.Granted != tc.granted {
+			t.Fatalf("extending %s by %d seconds returned %d %+v but expected %d with deadline %d granted %d", tc.uuid, tc.seconds, code, extension, tc.code, tc.deadline, tc.granted)
+		}
+	}
+}
+
+// TestExtendRunNamespaceDeadline ensures run namespaces follow extended deadlines and runs without one are skipped
+func TestExtendRunNamespaceDeadline(t *testing.T) {
+	name := external.RunNamespaceName("check", "0123456789")
+	client := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        name,
+		Annotations: map[string]string{external.RunNamespaceDeadlineAnnotation: time.Now().UTC().Format(time.RFC3339)},
+	}})
+	deadline := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	err := extendRunNamespaceDeadline(context.Background(), client, "check", "0123456789", deadline)
+	if err != nil {
+		t.Fatal("Failed to extend run namespace deadline:", err)
+	}
+	ns, err := client.CoreV1().Namespaces().Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("Failed to get run namespace:", err)
+	}
+	if ns.Annotations[external.RunNamespaceDeadlineAnnotation] != deadline.Format(time.RFC3339) {
+		t.Fatalf("run namespace deadline was %s but expected %s", ns.Annotations[external.RunNamespaceDeadlineAnnotation], deadline.Format(time.RFC3339))
+	}
+
+	err = extendRunNamespaceDeadline(context.Background(), client, "other", "0123456789", deadline)
+	if err != nil {
+		t.Fatal("Extending the deadline of a run without a namespace returned an error:", err)
+	}
+}
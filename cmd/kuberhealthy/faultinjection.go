@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// reportFault is a fault injected into the handling of a check report
+type reportFault string
+
+const (
+	faultNone  reportFault = ""
+	faultDrop  reportFault = "drop"  // the report is thrown away and the client is told it was accepted
+	faultError reportFault = "error" // the report is refused with an internal server error
+)
+
+// faultInjectionEnabled tells if faults are injected into the handling of check reports
+func faultInjectionEnabled() bool {
+	return cfg != nil && cfg.EnableFaultInjection
+}
+
+// faultInjectionPercents returns the percent of reports to drop and the percent to fail.  Percents are capped so that
+// they add up to no more than 100.
+func faultInjectionPercents() (int, int) {
+	if cfg == nil {
+		return 0, 0
+	}
+	drop := clampPercent(cfg.FaultInjectionDropPercent)
+	fail := clampPercent(cfg.FaultInjectionErrorPercent)
+	if drop+fail > 100 {
+		log.Debugln("faultInjection: Drop and error percents add up to more than 100. Capping the error percent to", 100-drop)
+		fail = 100 - drop
+	}
+	return drop, fail
+}
+
+// clampPercent keeps a percent between 0 and 100
+func clampPercent(percent int) int {
+	if percent < 0 {
+		return 0
+	}
+	if percent > 100 {
+		return 100
+	}
+	return percent
+}
+
+// faultInjectionDelay returns how long responses to check reports are held back
+func faultInjectionDelay() time.Duration {
+	if cfg == nil || cfg.FaultInjectionDelay.Duration < 0 {
+		return 0
+	}
+	return cfg.FaultInjectionDelay.Duration
+}
+
+// chooseReportFault picks the fault to inject into a report from a roll between 0 and 99.  Rolls below the drop
+// percent drop the report and the rolls after them, up to the error percent, fail it.
+func chooseReportFault(dropPercent int, errorPercent int, roll int) reportFault {
+	if roll < dropPercent {
+		return faultDrop
+	}
+	if roll < dropPercent+errorPercent {
+		return faultError
+	}
+	return faultNone
+}
+
+// injectReportFaults wraps a reporting endpoint with the faults configured for validating alerting and the retries
+// of checks.  Responses are delayed, and reports are dropped or failed at random.  Requests are passed through
+// untouched while fault injection is disabled.
+func (k *Kuberhealthy) injectReportFaults(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !faultInjectionEnabled() {
+			next(w, r)
+			return
+		}
+
+		if delay := faultInjectionDelay(); delay > 0 {
+			log.Debugln("faultInjection: Delaying report from", r.RemoteAddr, "by", delay)
+			select {
+			case <-k.clock().After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		dropPercent, errorPercent := faultInjectionPercents()
+		switch chooseReportFault(dropPercent, errorPercent, rand.Intn(100)) {
+		case faultDrop:
+			log.Infoln("faultInjection: Dropping report from", r.RemoteAddr, "to", r.URL.Path)
+			io.Copy(ioutil.Discard, r.Body)
+			w.WriteHeader(http.StatusOK)
+		case faultError:
+			log.Infoln("faultInjection: Failing report from", r.RemoteAddr, "to", r.URL.Path)
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			next(w, r)
+		}
+	}
+}
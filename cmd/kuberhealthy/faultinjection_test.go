@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestChooseReportFault ensures reports are dropped and failed in the configured proportions
+func TestChooseReportFault(t *testing.T) {
+	var testCases = []struct {
+		dropPercent  int
+		errorPercent int
+		roll         int
+		expected     reportFault
+	}{
+		{0, 0, 0, faultNone},
+		{10, 0, 9, faultDrop},
+		{10, 0, 10, faultNone},
+		{10, 20, 10, faultError},
+		{10, 20, 29, faultError},
+		{10, 20, 30, faultNone},
+		{0, 100, 99, faultError},
+	}
+	for _, tc := range testCases {
+		fault := chooseReportFault(tc.dropPercent, tc.errorPercent, tc.roll)
+		if fault != tc.expected {
+			t.Fatalf("drop %d%% error %d%% roll %d: expected fault %q but got %q", tc.dropPercent, tc.errorPercent, tc.roll, tc.expected, fault)
+		}
+	}
+}
+
+// TestFaultInjectionPercents ensures the fault percents are kept between 0 and 100 in total
+func TestFaultInjectionPercents(t *testing.T) {
+	oldCfg := cfg
+	defer func() {
+		cfg = oldCfg
+	}()
+
+	cfg = &Config{FaultInjectionDropPercent: 70, FaultInjectionErrorPercent: 50}
+	drop, fail := faultInjectionPercents()
+	if drop != 70 || fail != 30 {
+		t.Fatalf("expected percents to be capped to 70 and 30 but got %d and %d", drop, fail)
+	}
+	cfg = &Config{FaultInjectionDropPercent: -5, FaultInjectionErrorPercent: 150}
+	drop, fail = faultInjectionPercents()
+	if drop != 0 || fail != 100 {
+		t.Fatalf("expected percents to be clamped to 0 and 100 but got %d and %d", drop, fail)
+	}
+}
+
+// TestInjectReportFaults ensures reports only reach the endpoint while fault injection lets them through
+func TestInjectReportFaults(t *testing.T) {
+	oldCfg := cfg
+	defer func() {
+		cfg = oldCfg
+	}()
+	k := &Kuberhealthy{}
+
+	var handled bool
+	handler := k.injectReportFaults(func(w http.ResponseWriter, r *http.Request) {
+		handled = true
+		w.WriteHeader(http.StatusOK)
+	})
+
+	var testCases = []struct {
+		description   string
+		cfg           *Config
+		expectHandled bool
+		expectCode    int
+	}{
+		{"disabled", &Config{FaultInjectionErrorPercent: 100}, true, http.StatusOK},
+		{"no faults", &Config{EnableFaultInjection: true}, true, http.StatusOK},
+		{"drop all", &Config{EnableFaultInjection: true, FaultInjectionDropPercent: 100}, false, http.StatusOK},
+		{"fail all", &Config{EnableFaultInjection: true, FaultInjectionErrorPercent: 100}, false, http.StatusInternalServerError},
+	}
+	for _, tc := range testCases {
+		cfg = tc.cfg
+		handled = false
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodPost, "/externalCheckStatus", strings.NewReader(`{"OK": true}`)))
+		if handled != tc.expectHandled || w.Code != tc.expectCode {
+			t.Fatalf("%s: report was handled %t with code %d but expected %t with code %d", tc.description, handled, w.Code, tc.expectHandled, tc.expectCode)
+		}
+	}
+}
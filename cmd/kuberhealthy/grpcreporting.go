@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	grpcstatus "google.golang.org/grpc/status"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/reportpb"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// reportingServer serves the gRPC reporting service.  Reports and progress sent to it are validated and recorded the
+// same way as those posted to the HTTP endpoints.
+type reportingServer struct {
+	reportpb.UnimplementedReportingServer
+	k *Kuberhealthy
+}
+
+// StartGRPCServer serves the gRPC reporting service on the configured gRPC listen address and restarts it if it exits
+func (k *Kuberhealthy) StartGRPCServer() {
+	server := grpc.NewServer()
+	reportpb.RegisterReportingServer(server, &reportingServer{k: k})
+
+	for {
+		log.Infoln("Starting gRPC reporting service on", cfg.GRPCListenAddress)
+		listener, err := net.Listen("tcp", cfg.GRPCListenAddress)
+		if err == nil {
+			err = server.Serve(listener)
+		}
+		log.Errorln("gRPC reporting service ERROR:", err)
+		time.Sleep(time.Second / 2)
+	}
+}
+
+// grpcReportingAddress is the address checker pods are told to send gRPC reports to.  Unless it is configured, it is
+// the host of the external check reporting URL on the port of the gRPC listen address.  Nothing is returned when the
+// gRPC reporting service is not served.
+func grpcReportingAddress() string {
+	if cfg == nil || len(cfg.GRPCListenAddress) == 0 {
+		return ""
+	}
+	if len(cfg.GRPCReportingAddress) > 0 {
+		return cfg.GRPCReportingAddress
+	}
+	_, port, err := net.SplitHostPort(cfg.GRPCListenAddress)
+	if err != nil {
+		log.Errorln("Failed to read the port of grpcListenAddress", cfg.GRPCListenAddress+":", err)
+		return ""
+	}
+	reportingURL, err := url.Parse(cfg.ExternalCheckReportingURL)
+	if err != nil || len(reportingURL.Hostname()) == 0 {
+		log.Errorln("Failed to read the host of externalCheckReportingURL", cfg.ExternalCheckReportingURL, "- set grpcReportingAddress to tell checker pods where the gRPC reporting service is")
+		return ""
+	}
+	return net.JoinHostPort(reportingURL.Hostname(), port)
+}
+
+// grpcCallerRequest builds the HTTP request a report would have been posted with from the metadata and peer address of
+// a gRPC call, so that gRPC callers are validated by the same code as HTTP callers
+func grpcCallerRequest(ctx context.Context) (*http.Request, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return nil, errors.New("the call has no peer address")
+	}
+	r := (&http.Request{Header: http.Header{}, RemoteAddr: p.Addr.String()}).WithContext(ctx)
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, key := range []string{"kh-run-uuid", external.KHRequestIDHeader, external.KHSignatureHeader, external.TraceParentHeader, "user-agent"} {
+		values := md.Get(key)
+		if len(values) > 0 {
+			r.Header.Set(key, values[0])
+		}
+	}
+	return r, nil
+}
+
+// grpcStatusForCode turns the status code an HTTP report would have been answered with into the status of a gRPC call
+func grpcStatusForCode(code int, reportRequestID string) error {
+	var c codes.Code
+	switch code {
+	case http.StatusOK:
+		return nil
+	case http.StatusBadRequest:
+		c = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		c = codes.Unauthenticated
+	case http.StatusGone:
+		c = codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		c = codes.ResourceExhausted
+	default:
+		c = codes.Internal
+	}
+	return grpcstatus.Errorf(c, "%s (request ID %s)", http.StatusText(code), reportRequestID)
+}
+
+// grpcRetryAfter tells the client that Kuberhealthy is overloaded and that it should retry after the supplied delay.
+// The delay is sent in the retry-after trailer of the call.
+func grpcRetryAfter(ctx context.Context, delay time.Duration) error {
+	err := grpc.SetTrailer(ctx, metadata.Pairs("retry-after", retryAfterSeconds(delay)))
+	if err != nil {
+		log.Warningln("Failed to set retry-after trailer of gRPC call:", err)
+	}
+	return grpcstatus.Error(codes.ResourceExhausted, "kuberhealthy is overloaded")
+}
+
+// grpcReportVersions tells the client which report versions Kuberhealthy accepts, the same way the
+// X-Kuberhealthy-Report-Versions header does for HTTP reports.  The versions are sent in the header of the call, so they
+// also reach clients whose report was refused.
+func grpcReportVersions(ctx context.Context) {
+	err := grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(external.KHReportVersionsHeader), status.FormatReportVersions(status.SupportedReportVersions)))
+	if err != nil {
+		log.Warningln("Failed to set report versions header of gRPC call:", err)
+	}
+}
+
+// SendReport records the report of a run.  The report is refused with the same conditions as a report posted to the
+// /externalCheckStatus endpoint, which are told apart by the code of the returned status.
+func (s *reportingServer) SendReport(ctx context.Context, in *reportpb.Report) (*reportpb.ReportResponse, error) {
+	k := s.k
+	grpcReportVersions(ctx)
+	r, err := grpcCallerRequest(ctx)
+	if err != nil {
+		return nil, grpcstatus.Error(codes.InvalidArgument, err.Error())
+	}
+	reportRequestID := getRequestID(r)
+	requestID := "grpc: " + reportRequestID
+
+	k.externalCheckReportHandlerLog(requestID, "Client connected to gRPC report service from", r.RemoteAddr, r.UserAgent())
+
+	// ask the client to back off when too many reports are already being handled
+	if !k.acquireReportSlot() {
+		k.externalCheckReportHandlerLog(requestID, "Too many reports are being handled. Asking client to retry in", defaultReportRetryAfter)
+		return nil, grpcRetryAfter(ctx, defaultReportRetryAfter)
+	}
+	defer k.releaseReportSlot()
+
+	podReport, lateReport, overtakenReport, err := k.validateReportCaller(ctx, requestID, r)
+	if err != nil {
+		return nil, grpcstatus.Errorf(codes.PermissionDenied, "caller is not the checker pod of a known run (request ID %s)", reportRequestID)
+	}
+	k.externalCheckReportHandlerLog(requestID, "Calling pod is", podReport.Name, "in namespace", podReport.Namespace)
+	k.logReportTrace(requestID, podReport.UUID, r)
+
+	// append pod info to request id for easy check tracing in logs
+	requestID = requestID + " (" + podReport.Namespace + "/" + podReport.Name + ")"
+
+	// when signed reports are required, the report must be signed with the signing key of the calling pod's run
+	b, err := reportpb.SigningBytes(in)
+	if err != nil {
+		return nil, grpcstatus.Errorf(codes.Internal, "failed to encode report: %s", err)
+	}
+	err = verifyReportSignature(r, podReport.UUID, b)
+	if err != nil {
+		k.externalCheckReportHandlerLog(requestID, "Refusing report:", err)
+		return nil, grpcstatus.Error(codes.Unauthenticated, err.Error())
+	}
+
+	// refuse reports written for a version of the report schema that may not be read the way the client meant
+	report := in.Status()
+	err = status.ValidateReportVersion(report)
+	if err != nil {
+		k.externalCheckReportHandlerLog(requestID, "Client sent a report that can't be read:", err)
+		return nil, grpcstatus.Errorf(codes.InvalidArgument, "%s (request ID %s)", err, reportRequestID)
+	}
+
+	code, retryAfter, err := k.recordReport(requestID, reportRequestID, podReport, report, lateReport, overtakenReport)
+	if err != nil {
+		log.Errorln("gRPC reporting service error:", err)
+	}
+	if retryAfter > 0 {
+		return nil, grpcRetryAfter(ctx, retryAfter)
+	}
+	if code != http.StatusOK {
+		return nil, grpcStatusForCode(code, reportRequestID)
+	}
+	return &reportpb.ReportResponse{RequestId: reportRequestID}, nil
+}
+
+// StreamProgress records the progress of a run until the client closes the stream.  Progress that arrives too soon
+// after the last recorded progress is dropped rather than ending the stream.  The stream ends with an error when the
+// run times out or is overtaken by a newer run.
+func (s *reportingServer) StreamProgress(stream reportpb.Reporting_StreamProgressServer) error {
+	k := s.k
+	ctx := stream.Context()
+	r, err := grpcCallerRequest(ctx)
+	if err != nil {
+		return grpcstatus.Error(codes.InvalidArgument, err.Error())
+	}
+	reportRequestID := getRequestID(r)
+	requestID := "grpc: " + reportRequestID
+
+	podReport, lateReport, overtakenReport, err := k.validateReportCaller(ctx, requestID, r)
+	if err != nil {
+		return grpcstatus.Errorf(codes.PermissionDenied, "caller is not the checker pod of a known run (request ID %s)", reportRequestID)
+	}
+	if lateReport || overtakenReport {
+		log.Infoln(requestID, "Run", podReport.UUID, "streamed progress after it was timed out or overtaken")
+		return grpcStatusForCode(http.StatusGone, reportRequestID)
+	}
+
+	summary := &reportpb.ProgressSummary{}
+	for {
+		p, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(summary)
+		}
+		if err != nil {
+			return err
+		}
+
+		code, retryAfter, err := k.recordProgress(requestID, podReport, status.ProgressReport{Message: p.GetMessage(), Percent: int(p.GetPercent())})
+		if err != nil {
+			log.Errorln("gRPC reporting service error:", err)
+		}
+		if retryAfter > 0 {
+			summary.Dropped++
+			continue
+		}
+		if code != http.StatusOK {
+			return grpcStatusForCode(code, reportRequestID)
+		}
+		summary.Recorded++
+	}
+}
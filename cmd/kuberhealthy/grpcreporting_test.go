@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/reportpb"
+)
+
+// TestGRPCReportingAddress ensures checker pods are only told about the gRPC reporting service when it is served, and
+// that the address defaults to the host of the reporting URL on the gRPC port
+func TestGRPCReportingAddress(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	var testCases = []struct {
+		description string
+		config      Config
+		expected    string
+	}{
+		{"not served", Config{ExternalCheckReportingURL: "http://kuberhealthy.kuberhealthy.svc.cluster.local/externalCheckStatus"}, ""},
+		{"default", Config{GRPCListenAddress: ":9090", ExternalCheckReportingURL: "http://kuberhealthy.kuberhealthy.svc.cluster.local/externalCheckStatus"}, "kuberhealthy.kuberhealthy.svc.cluster.local:9090"},
+		{"configured", Config{GRPCListenAddress: ":9090", GRPCReportingAddress: "reports.example.com:443"}, "reports.example.com:443"},
+		{"no reporting url host", Config{GRPCListenAddress: ":9090"}, ""},
+	}
+	for _, tc := range testCases {
+		c := tc.config
+		cfg = &c
+		if got := grpcReportingAddress(); got != tc.expected {
+			t.Fatalf("%s: expected address %q, got %q", tc.description, tc.expected, got)
+		}
+	}
+}
+
+// TestGRPCCallerRequest ensures the run UUID, request ID and signature of a gRPC call are read like the headers of an
+// HTTP report and that the peer address becomes the remote address
+func TestGRPCCallerRequest(t *testing.T) {
+	_, err := grpcCallerRequest(context.Background())
+	if err == nil {
+		t.Fatal("expected an error for a call without a peer address")
+	}
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 43210}})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("kh-run-uuid", "run-1", external.KHRequestIDHeader, "request-1", external.KHSignatureHeader, "sha256=abc"))
+	r, err := grpcCallerRequest(ctx)
+	if err != nil {
+		t.Fatalf("failed to build request: %s", err)
+	}
+	if r.RemoteAddr != "10.1.2.3:43210" {
+		t.Fatalf("expected the peer address as remote address, got %s", r.RemoteAddr)
+	}
+	if r.Header.Get("kh-run-uuid") != "run-1" || getRequestID(r) != "request-1" || r.Header.Get(external.KHSignatureHeader) != "sha256=abc" {
+		t.Fatalf("expected the metadata of the call as headers, got %v", r.Header)
+	}
+}
+
+// TestGRPCStatusForCode ensures the answers to HTTP reports map to gRPC codes the checkclient can act on
+func TestGRPCStatusForCode(t *testing.T) {
+	if grpcStatusForCode(http.StatusOK, "request-1") != nil {
+		t.Fatal("expected no error for an accepted report")
+	}
+	for code, expected := range map[int]codes.Code{
+		http.StatusBadRequest:          codes.InvalidArgument,
+		http.StatusUnauthorized:        codes.Unauthenticated,
+		http.StatusGone:                codes.FailedPrecondition,
+		http.StatusTooManyRequests:     codes.ResourceExhausted,
+		http.StatusInternalServerError: codes.Internal,
+	} {
+		if got := grpcstatus.Code(grpcStatusForCode(code, "request-1")); got != expected {
+			t.Fatalf("expected code %s for status %d, got %s", expected, code, got)
+		}
+	}
+}
+
+// TestGRPCReportBackpressure ensures reports sent over gRPC while too many reports are being handled are refused with
+// a retry-after trailer, and that refused reports are still told the report versions Kuberhealthy accepts
+func TestGRPCReportBackpressure(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg = &Config{MaxConcurrentReports: 1}
+
+	k := &Kuberhealthy{}
+	if !k.acquireReportSlot() {
+		t.Fatal("expected a free report slot")
+	}
+	defer k.releaseReportSlot()
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	reportpb.RegisterReportingServer(server, &reportingServer{k: k})
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial reporting service: %s", err)
+	}
+	defer conn.Close()
+
+	var header, trailer metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "kh-run-uuid", "run-1")
+	_, err = reportpb.NewReportingClient(conn).SendReport(ctx, &reportpb.Report{Ok: true}, grpc.Header(&header), grpc.Trailer(&trailer))
+	if grpcstatus.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected the report to be refused as overloaded, got %v", err)
+	}
+	if got := trailer.Get("retry-after"); len(got) != 1 || got[0] != "5" {
+		t.Fatalf("expected a retry-after trailer of 5 seconds, got %v", got)
+	}
+	if got := header.Get(external.KHReportVersionsHeader); len(got) != 1 || got[0] != "1,2" {
+		t.Fatalf("expected the report versions header to list versions 1 and 2, got %v", got)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// parseHeartbeatTimeout parses the heartbeatTimeout field of a khcheck or khjob spec.  Timeouts shorter than
+// external.MinHeartbeatTimeout are refused.
+func parseHeartbeatTimeout(value string) (time.Duration, error) {
+	d, err := parseSpecDuration("heartbeatTimeout", value, 0)
+	if err != nil {
+		return 0, err
+	}
+	if d < external.MinHeartbeatTimeout {
+		return 0, fmt.Errorf("spec.heartbeatTimeout: duration %q must be at least %s", value, external.MinHeartbeatTimeout)
+	}
+	return d, nil
+}
+
+// recordHeartbeat records a heartbeat of a run and returns the response for the checker pod along with the HTTP
+// status code to send it with
+func recordHeartbeat(runs *external.RunTracker, uuid string, now time.Time) (status.Heartbeat, int, error) {
+	deadline, err := runs.RecordHeartbeat(uuid, now)
+	switch {
+	case errors.Is(err, external.ErrHeartbeatsNotExpected), errors.Is(err, external.ErrExtensionExhausted):
+		return status.Heartbeat{Deadline: deadline.Unix()}, http.StatusForbidden, err
+	case err != nil:
+		return status.Heartbeat{}, http.StatusGone, err
+	}
+	return status.Heartbeat{Deadline: deadline.Unix()}, http.StatusOK, nil
+}
+
+// heartbeatHandler lets a running checker pod tell Kuberhealthy that it is still making progress.  The calling pod is
+// validated the same way as status reports.  Each heartbeat pushes the deadline of the run back to the heartbeat
+// timeout of its check from now, so runs are only timed out once their heartbeats stop.
+func (k *Kuberhealthy) heartbeatHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+	requestID := "web: " + getRequestID(r)
+	ctx := r.Context()
+
+	podReport, validated, err := k.validateUsingRequestHeader(ctx, r)
+	if !validated && !errors.Is(err, errLateReport) {
+		podReport, err = k.validatePodReportBySourceIP(ctx, r)
+	}
+	if errors.Is(err, errLateReport) {
+		log.Infoln(requestID, "Run", podReport.UUID, "sent a heartbeat after it was timed out")
+		w.WriteHeader(http.StatusGone)
+		return nil
+	}
+	if err != nil && !errors.Is(err, errOvertakenReport) {
+		log.Infoln(requestID, "Failed to validate heartbeat from", r.RemoteAddr+":", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+
+	heartbeat, code, err := recordHeartbeat(k.runTracker, podReport.UUID, time.Now())
+	if err != nil {
+		log.Infoln(requestID, "Refused heartbeat of run", podReport.UUID, "of", podReport.Namespace+"/"+podReport.Name+":", err)
+	} else {
+		log.Debugln(requestID, "Received heartbeat of run", podReport.UUID, "of", podReport.Namespace+"/"+podReport.Name)
+		err = extendRunNamespaceDeadline(ctx, kubernetesClient, podReport.Name, podReport.UUID, time.Unix(heartbeat.Deadline, 0))
+		if err != nil {
+			log.Warningln(requestID, err)
+		}
+	}
+
+	b, err := json.Marshal(heartbeat)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to marshal heartbeat: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, err = w.Write(b)
+	return err
+}
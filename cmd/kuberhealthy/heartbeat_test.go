@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// TestParseHeartbeatTimeout ensures heartbeat timeouts that are too short are refused
+func TestParseHeartbeatTimeout(t *testing.T) {
+	var testCases = []struct {
+		value     string
+		expected  time.Duration
+		expectErr bool
+	}{
+		{"1m", time.Minute, false},
+		{"10s", time.Second * 10, false},
+		{"5s", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tc := range testCases {
+		d, err := parseHeartbeatTimeout(tc.value)
+		if d != tc.expected || (err != nil) != tc.expectErr {
+			t.Fatalf("parsing %q returned %s and error %v but expected %s and an error: %t", tc.value, d, err, tc.expected, tc.expectErr)
+		}
+	}
+}
+
+// TestRecordHeartbeat ensures heartbeats push back the deadline of runs that expect them, up to the maximum extension
+func TestRecordHeartbeat(t *testing.T) {
+	runs := external.NewRunTracker()
+	now := time.Now()
+	deadline := now.Add(time.Minute)
+	runs.Start("heartbeats", "check", "kuberhealthy", "check-1", deadline)
+	runs.ExpectHeartbeats("heartbeats", time.Minute*2)
+	runs.AllowExtension("heartbeats", time.Minute*3)
+	runs.Start("no-heartbeats", "check", "kuberhealthy", "check-1", deadline)
+
+	var testCases = []struct {
+		description string
+		uuid        string
+		at          time.Time
+		code        int
+		deadline    int64
+	}{
+		{"heartbeat pushes back the deadline", "heartbeats", now, http.StatusOK, now.Add(time.Minute * 2).Unix()},
+		{"earlier heartbeat keeps the deadline", "heartbeats", now.Add(-time.Second * 30), http.StatusOK, now.Add(time.Minute * 2).Unix()},
+		{"heartbeat limited by the maximum extension", "heartbeats", now.Add(time.Minute * 2), http.StatusOK, deadline.Add(time.Minute * 3).Unix()},
+		{"maximum extension reached", "heartbeats", now.Add(time.Minute * 3), http.StatusForbidden, deadline.Add(time.Minute * 3).Unix()},
+		{"run without heartbeats", "no-heartbeats", now, http.StatusForbidden, deadline.Unix()},
+		{"unknown run", "unknown", now, http.StatusGone, 0},
+	}
+	for _, tc := range testCases {
+		heartbeat, code, _ := recordHeartbeat(runs, tc.uuid, tc.at)
+		if code != tc.code || heartbeat.Deadline != tc.deadline {
+			t.Fatalf("%s: returned %d with deadline %d but expected %d with deadline %d", tc.description, code, heartbeat.Deadline, tc.code, tc.deadline)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/discovery"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/imageversions"
+)
+
+// defaultImageVersionInterval is how often the versions of check images are looked up
+const defaultImageVersionInterval = time.Hour * 6
+
+// defaultImageRollbackFailures is the number of failed runs in a row after an automatic image update that roll the
+// update back
+const defaultImageRollbackFailures = 3
+
+// imageUpdateAnnotation is set on a khcheck whose image was updated automatically until enough runs of the new image
+// have been seen to keep or roll back the update
+const imageUpdateAnnotation = "kuberhealthy.io/image-update"
+
+// imageRollbackAnnotation lists the images, separated by commas, that automatic updates of a khcheck were rolled back
+// from.  These images are not updated to again.
+const imageRollbackAnnotation = "kuberhealthy.io/image-rollback"
+
+// imageUpdate records an automatic image update of a khcheck
+type imageUpdate struct {
+	Container string    `json:"container"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Time      time.Time `json:"time"`
+	Passing   bool      `json:"passing"` // whether the check was passing before the update
+}
+
+// imageVersionOptions reads the image version configuration
+func imageVersionOptions() imageversions.Options {
+	opts := imageversions.Options{Repositories: imageversions.DefaultRepositories}
+	if len(cfg.ImageVersionRepositories) > 0 {
+		opts.Repositories = cfg.ImageVersionRepositories
+	}
+	constraint, err := imageversions.ParseConstraint(cfg.ImageAutoUpdate)
+	if err != nil {
+		log.Errorln("image versions:", err, "Images will only be reported")
+	}
+	opts.Constraint = constraint
+	return opts
+}
+
+// imageRollbackFailures is the number of failed runs that roll back an automatic image update
+func imageRollbackFailures() int {
+	if cfg.ImageRollbackFailures > 0 {
+		return cfg.ImageRollbackFailures
+	}
+	return defaultImageRollbackFailures
+}
+
+// rolledBackImages gathers the images that automatic updates of the supplied checks were rolled back from, as
+// repository:tag
+func rolledBackImages(checks []khcheckv1.KuberhealthyCheck, repositories []string) map[string]bool {
+	skip := make(map[string]bool)
+	for _, c := range checks {
+		for _, image := range strings.Split(c.Annotations[imageRollbackAnnotation], ",") {
+			repository, tag, ok := imageversions.Tracked(strings.TrimSpace(image), repositories)
+			if ok {
+				skip[repository+":"+tag] = true
+			}
+		}
+	}
+	return skip
+}
+
+// pendingImageUpdate reads the automatic image update of a khcheck that is still being watched, if any
+func pendingImageUpdate(c khcheckv1.KuberhealthyCheck) (imageUpdate, bool, error) {
+	value, ok := c.Annotations[imageUpdateAnnotation]
+	if !ok {
+		return imageUpdate{}, false, nil
+	}
+	var u imageUpdate
+	err := json.Unmarshal([]byte(value), &u)
+	if err != nil {
+		return imageUpdate{}, false, fmt.Errorf("invalid %s annotation: %w", imageUpdateAnnotation, err)
+	}
+	return u, true, nil
+}
+
+// applyImageUpdate changes the image of a container of a khcheck to a new tag and records the update so that it can
+// be rolled back
+func applyImageUpdate(c *khcheckv1.KuberhealthyCheck, container string, tag string, passing bool, now time.Time) (imageUpdate, error) {
+	podSpec := c.Spec.EffectivePodSpec()
+	for i := range podSpec.Containers {
+		cont := &podSpec.Containers[i]
+		if cont.Name != container {
+			continue
+		}
+		u := imageUpdate{Container: container, From: cont.Image, To: imageversions.UpdatedImage(cont.Image, tag), Time: now.UTC(), Passing: passing}
+		b, err := json.Marshal(u)
+		if err != nil {
+			return imageUpdate{}, err
+		}
+		cont.Image = u.To
+		if c.Annotations == nil {
+			c.Annotations = make(map[string]string)
+		}
+		c.Annotations[imageUpdateAnnotation] = string(b)
+		return u, nil
+	}
+	return imageUpdate{}, fmt.Errorf("khcheck has no container %s", container)
+}
+
+// rollbackImageUpdate restores the image a khcheck ran before an automatic update and remembers the image rolled back
+// from so that it is not updated to again
+func rollbackImageUpdate(c *khcheckv1.KuberhealthyCheck, u imageUpdate) {
+	podSpec := c.Spec.EffectivePodSpec()
+	for i := range podSpec.Containers {
+		cont := &podSpec.Containers[i]
+		if cont.Name == u.Container && cont.Image == u.To {
+			cont.Image = u.From
+		}
+	}
+	rolledBack := []string{u.To}
+	for _, image := range strings.Split(c.Annotations[imageRollbackAnnotation], ",") {
+		if len(image) > 0 && image != u.To {
+			rolledBack = append(rolledBack, image)
+		}
+	}
+	c.Annotations[imageRollbackAnnotation] = strings.Join(rolledBack, ",")
+	delete(c.Annotations, imageUpdateAnnotation)
+}
+
+// failedRun tells if a run history entry records a run that did not pass
+func failedRun(r khstatev1.RunResult) bool {
+	return r == khstatev1.RunFailure || r == khstatev1.RunLateFailure || r == khstatev1.RunProvisioningError
+}
+
+// rollbackDue decides the fate of an automatic image update from the first runs after it.  An update is rolled back
+// when the check was passing before it and each of the first threshold runs after it failed.  An update is settled,
+// and no longer watched, once threshold runs have been seen without it being rolled back.
+func rollbackDue(u imageUpdate, history []khstatev1.RunHistoryEntry, threshold int) (rollback bool, settled bool) {
+	var runs []khstatev1.RunHistoryEntry
+	for _, entry := range history {
+		if entry.Time != nil && !entry.Time.Time.Before(u.Time) {
+			runs = append(runs, entry)
+		}
+	}
+	if len(runs) < threshold {
+		return false, false
+	}
+	if !u.Passing {
+		return false, true
+	}
+	for _, r := range runs[:threshold] {
+		if !failedRun(r.Result) {
+			return false, true
+		}
+	}
+	return true, false
+}
+
+// monitorImageVersions looks up the versions of check images on an interval until the supplied context is canceled.
+// Only the master instance updates images.
+func (k *Kuberhealthy) monitorImageVersions(ctx context.Context) {
+	log.Infoln("image versions: Looking up check image versions every", defaultImageVersionInterval)
+	ticker := time.NewTicker(defaultImageVersionInterval)
+	defer ticker.Stop()
+
+	for {
+		k.refreshImageVersions(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshImageVersions generates a new image version report, stores it for the web server and updates images when
+// automatic updates are configured
+func (k *Kuberhealthy) refreshImageVersions(ctx context.Context) {
+	checks, err := khCheckClient.KuberhealthyChecks("").List(metav1.ListOptions{})
+	if err != nil {
+		log.Errorln("image versions: failed to list khchecks:", err)
+		return
+	}
+
+	opts := imageVersionOptions()
+	opts.Skip = rolledBackImages(checks.Items, opts.Repositories)
+	report := imageversions.Compute(ctx, imageversions.RegistryTagLister{}, checks.Items, opts, time.Now())
+	log.Debugln("image versions:", report.OutOfDate, "of", len(report.Images), "check images are out of date")
+
+	k.imageVersionMu.Lock()
+	k.imageVersionReport = &report
+	k.imageVersionMu.Unlock()
+
+	if isMaster && opts.Constraint != imageversions.ConstraintNone {
+		k.updateCheckImages(checks.Items, report)
+	}
+}
+
+// updateCheckImages updates the images of khchecks to the newest versions the update constraint allows.  Generated
+// khchecks are skipped because their images are set by configuration, and a khcheck is updated only once its previous
+// update has settled.
+func (k *Kuberhealthy) updateCheckImages(checks []khcheckv1.KuberhealthyCheck, report imageversions.Report) {
+	updated := make(map[string]bool)
+	for _, c := range checks {
+		if discovered(c) || len(c.Labels[discovery.ProbeLabel]) > 0 {
+			continue
+		}
+		if _, pending := c.Annotations[imageUpdateAnnotation]; pending {
+			continue
+		}
+		for _, s := range report.Images {
+			key := c.Namespace + "/" + c.Name
+			if s.Namespace != c.Namespace || s.Check != c.Name || len(s.Allowed) == 0 || updated[key] {
+				continue
+			}
+
+			var passing bool
+			state, err := khStateClient.KuberhealthyStates(c.Namespace).Get(sanitizeResourceName(c.Name), metav1.GetOptions{})
+			if err == nil {
+				passing = state.Spec.OK
+			}
+			u, err := applyImageUpdate(&c, s.Container, s.Allowed, passing, time.Now())
+			if err != nil {
+				log.Errorln("image versions: failed to update khcheck", key+":", err)
+				break
+			}
+			_, err = khCheckClient.KuberhealthyChecks(c.Namespace).Update(&c)
+			if err != nil {
+				log.Errorln("image versions: failed to update khcheck", key+":", err)
+				break
+			}
+			log.Infoln("image versions: Updated container", u.Container, "of khcheck", key, "from", u.From, "to", u.To)
+			updated[key] = true
+		}
+	}
+}
+
+// checkImageRollback rolls back the automatic image update of a khcheck when the runs after it keep failing, and
+// stops watching the update once it has settled
+func (k *Kuberhealthy) checkImageRollback(checkName string, namespace string) {
+	if !cfg.EnableImageVersions || len(cfg.ImageAutoUpdate) == 0 {
+		return
+	}
+
+	check, err := khCheckClient.KuberhealthyChecks(namespace).Get(checkName, metav1.GetOptions{})
+	if err != nil {
+		log.Errorln("image versions: failed to get khcheck", namespace+"/"+checkName+":", err)
+		return
+	}
+	u, pending, err := pendingImageUpdate(check)
+	if err != nil {
+		log.Warningln("image versions: khcheck", namespace+"/"+checkName+":", err)
+		return
+	}
+	if !pending {
+		return
+	}
+
+	state, err := khStateClient.KuberhealthyStates(namespace).Get(sanitizeResourceName(checkName), metav1.GetOptions{})
+	if err != nil {
+		log.Errorln("image versions: failed to get khstate", namespace+"/"+checkName+":", err)
+		return
+	}
+	rollback, settled := rollbackDue(u, state.Spec.History, imageRollbackFailures())
+	switch {
+	case rollback:
+		rollbackImageUpdate(&check, u)
+		log.Warningln("image versions: Rolling back container", u.Container, "of khcheck", namespace+"/"+checkName, "from", u.To, "to", u.From, "after", imageRollbackFailures(), "failed runs")
+	case settled:
+		delete(check.Annotations, imageUpdateAnnotation)
+		log.Infoln("image versions: Keeping", u.To, "for container", u.Container, "of khcheck", namespace+"/"+checkName)
+	default:
+		return
+	}
+	_, err = khCheckClient.KuberhealthyChecks(namespace).Update(&check)
+	if err != nil {
+		log.Errorln("image versions: failed to update khcheck", namespace+"/"+checkName+":", err)
+	}
+}
+
+// currentImageVersions returns the most recent image version report and whether one has been generated
+func (k *Kuberhealthy) currentImageVersions() (imageversions.Report, bool) {
+	k.imageVersionMu.RLock()
+	defer k.imageVersionMu.RUnlock()
+	if k.imageVersionReport == nil {
+		return imageversions.Report{}, false
+	}
+	return *k.imageVersionReport, true
+}
+
+// imageVersionsHandler serves the most recent image version report as JSON
+func (k *Kuberhealthy) imageVersionsHandler(w http.ResponseWriter, r *http.Request) error {
+	log.Infoln("Client connected to image versions endpoint from", r.RemoteAddr, r.UserAgent())
+	if !cfg.EnableImageVersions {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+
+	report, ok := k.currentImageVersions()
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return nil
+	}
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to marshal image version report: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(b)
+	if err != nil {
+		log.Warningln("Error writing image version report to caller:", err)
+	}
+	return err
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/imageversions"
+)
+
+// TestImageUpdateRollback ensures that an automatic image update is recorded and can be rolled back to the previous
+// image, which is then never updated to again
+func TestImageUpdateRollback(t *testing.T) {
+	check := khcheckv1.NewKuberhealthyCheck("web", "kuberhealthy", khcheckv1.CheckConfig{PodSpec: corev1.PodSpec{Containers: []corev1.Container{
+		{Name: "main", Image: "kuberhealthy/http-check:v1.5.0"},
+	}}})
+	now := time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	u, err := applyImageUpdate(&check, "main", "v1.5.1", true, now)
+	if err != nil {
+		t.Fatalf("failed to update image: %s", err)
+	}
+	if check.Spec.PodSpec.Containers[0].Image != "kuberhealthy/http-check:v1.5.1" {
+		t.Fatalf("expected the image to be updated, got %s", check.Spec.PodSpec.Containers[0].Image)
+	}
+	pending, ok, err := pendingImageUpdate(check)
+	if err != nil || !ok || pending != u {
+		t.Fatalf("expected the update %+v to be pending, got %+v (%t, %v)", u, pending, ok, err)
+	}
+
+	_, err = applyImageUpdate(&check, "sidecar", "v1.5.1", true, now)
+	if err == nil {
+		t.Fatalf("expected an error updating a missing container")
+	}
+
+	rollbackImageUpdate(&check, u)
+	if check.Spec.PodSpec.Containers[0].Image != "kuberhealthy/http-check:v1.5.0" {
+		t.Fatalf("expected the image to be rolled back, got %s", check.Spec.PodSpec.Containers[0].Image)
+	}
+	if _, ok, _ := pendingImageUpdate(check); ok {
+		t.Fatalf("expected no pending update after the rollback")
+	}
+
+	skip := rolledBackImages([]khcheckv1.KuberhealthyCheck{check}, imageversions.DefaultRepositories)
+	if !skip["index.docker.io/kuberhealthy/http-check:v1.5.1"] || len(skip) != 1 {
+		t.Fatalf("expected the rolled back image to be skipped, got %v", skip)
+	}
+}
+
+// TestRollbackDue ensures that updates are rolled back only when every watched run after them failed
+func TestRollbackDue(t *testing.T) {
+	updated := time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC)
+	run := func(minutes int, result khstatev1.RunResult) khstatev1.RunHistoryEntry {
+		at := metav1.NewTime(updated.Add(time.Duration(minutes) * time.Minute))
+		return khstatev1.RunHistoryEntry{Result: result, Time: &at}
+	}
+
+	var testCases = []struct {
+		name     string
+		passing  bool
+		history  []khstatev1.RunHistoryEntry
+		rollback bool
+		settled  bool
+	}{
+		{
+			name:    "too few runs",
+			passing: true,
+			history: []khstatev1.RunHistoryEntry{run(-5, khstatev1.RunSuccess), run(5, khstatev1.RunFailure), run(10, khstatev1.RunFailure)},
+		},
+		{
+			name:     "failing runs",
+			passing:  true,
+			history:  []khstatev1.RunHistoryEntry{run(-5, khstatev1.RunSuccess), run(5, khstatev1.RunFailure), run(10, khstatev1.RunProvisioningError), run(15, khstatev1.RunLateFailure)},
+			rollback: true,
+		},
+		{
+			name:    "passing run",
+			passing: true,
+			history: []khstatev1.RunHistoryEntry{run(5, khstatev1.RunFailure), run(10, khstatev1.RunSuccess), run(15, khstatev1.RunFailure)},
+			settled: true,
+		},
+		{
+			name:    "failing before the update",
+			history: []khstatev1.RunHistoryEntry{run(5, khstatev1.RunFailure), run(10, khstatev1.RunFailure), run(15, khstatev1.RunFailure)},
+			settled: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := imageUpdate{Container: "main", Time: updated, Passing: tc.passing}
+			rollback, settled := rollbackDue(u, tc.history, 3)
+			if rollback != tc.rollback || settled != tc.settled {
+				t.Fatalf("expected rollback %t and settled %t, got %t and %t", tc.rollback, tc.settled, rollback, settled)
+			}
+		})
+	}
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/integrii/flaggy"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/deploy"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/kubeClient"
+)
+
+// installDefaultImage is the kuberhealthy image installed when no image is specified
+const installDefaultImage = "docker.io/kuberhealthy/kuberhealthy:v2.7.1"
+
+// installFieldManager is the field manager used when applying manifests with server side apply
+const installFieldManager = "kuberhealthy-install"
+
+// installOptions customize the manifests rendered or applied by the install subcommand
+type installOptions struct {
+	Render         bool
+	Namespace      string
+	Image          string
+	Replicas       int
+	ServiceType    string
+	LogLevel       string
+	KubeConfigFile string
+
+	// IsolatedNamespaceRoles are the cluster roles, besides edit, that checks may bind within their run namespaces
+	IsolatedNamespaceRoles []string
+}
+
+// installCommand is the subcommand that installs kuberhealthy without helm
+var installCommand *flaggy.Subcommand
+
+// installOpts holds the options of the install subcommand
+var installOpts = installOptions{
+	Namespace:   "kuberhealthy",
+	Image:       installDefaultImage,
+	Replicas:    2,
+	ServiceType: string(apiv1.ServiceTypeClusterIP),
+	LogLevel:    "info",
+}
+
+// addInstallCommand registers the install subcommand with flaggy
+func addInstallCommand() {
+	installCommand = flaggy.NewSubcommand("install")
+	installCommand.Description = "Renders or applies the namespace, CRDs, RBAC, deployment and service needed to run Kuberhealthy without Helm."
+	installCommand.Bool(&installOpts.Render, "r", "render", "Write the manifests to stdout instead of applying them to the cluster.")
+	installCommand.String(&installOpts.Namespace, "n", "namespace", "Namespace to install Kuberhealthy into.")
+	installCommand.String(&installOpts.Image, "i", "image", "Kuberhealthy image to run.")
+	installCommand.Int(&installOpts.Replicas, "", "replicas", "Number of Kuberhealthy replicas to run.")
+	installCommand.String(&installOpts.ServiceType, "", "service-type", "Type of the Kuberhealthy service.")
+	installCommand.String(&installOpts.LogLevel, "", "log-level", "Log level of the installed Kuberhealthy.")
+	installCommand.StringSlice(&installOpts.IsolatedNamespaceRoles, "", "isolated-namespace-role", "Cluster role, besides edit, that khchecks and khjobs may be granted within their isolated run namespaces. Can be repeated.")
+	installCommand.String(&installOpts.KubeConfigFile, "k", "kubeconfig", "Kube config file used to apply the manifests when not running in a cluster.")
+	flaggy.AttachSubcommand(installCommand, 1)
+}
+
+// runInstall renders the install manifests to out or applies them to the cluster
+func runInstall(ctx context.Context, o installOptions, out io.Writer) error {
+	objects, err := installManifests(o)
+	if err != nil {
+		return err
+	}
+
+	if o.Render {
+		return renderManifests(objects, out)
+	}
+
+	restConfig, err := kubeClient.RestConfig(o.KubeConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to build kubernetes client config: %w", err)
+	}
+	client, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	return applyManifests(ctx, client, objects, installFieldManager, out)
+}
+
+// installManifests builds every object needed to run kuberhealthy in the order they must be applied
+func installManifests(o installOptions) ([]*unstructured.Unstructured, error) {
+	if len(o.Namespace) == 0 {
+		return nil, fmt.Errorf("namespace can not be empty")
+	}
+	if o.Replicas < 1 {
+		return nil, fmt.Errorf("replicas must be at least 1")
+	}
+
+	objects, err := installCRDs()
+	if err != nil {
+		return nil, err
+	}
+
+	isolatedNamespaceRoles := isolatedNamespaceClusterRoles(o.IsolatedNamespaceRoles)
+	config := []string{
+		`listenAddress: ":8080"`,
+		"logLevel: " + o.LogLevel,
+		"maxKHJobAge: 15m",
+		"maxCheckPodAge: 72h",
+		"maxCompletedPodCount: 1",
+		"maxErrorPodCount: 2",
+	}
+	if len(isolatedNamespaceRoles) > 1 {
+		config = append(config, "isolatedNamespaceRoles:")
+		for _, role := range isolatedNamespaceRoles[1:] {
+			config = append(config, "- "+role)
+		}
+	}
+
+	labels := map[string]string{"app": "kuberhealthy"}
+	replicas := int32(o.Replicas)
+	runAsNonRoot := true
+	runAsUser := int64(999)
+	allowPrivilegeEscalation := false
+	readOnlyRootFilesystem := true
+	automountServiceAccountToken := true
+
+	typed := []runtime.Object{
+		&apiv1.Namespace{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+			ObjectMeta: metav1.ObjectMeta{Name: o.Namespace},
+		},
+		&apiv1.ServiceAccount{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: metav1.ObjectMeta{Name: "kuberhealthy", Namespace: o.Namespace},
+		},
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: "kuberhealthy"},
+			Rules:      installClusterRoleRules(isolatedNamespaceRoles),
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: "kuberhealthy"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "kuberhealthy"},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "kuberhealthy", Namespace: o.Namespace}},
+		},
+		&apiv1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{Name: "kuberhealthy", Namespace: o.Namespace},
+			Data: map[string]string{
+				"kuberhealthy.yaml": strings.Join(config, "\n"),
+			},
+		},
+		&appsv1.Deployment{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+			ObjectMeta: metav1.ObjectMeta{Name: "kuberhealthy", Namespace: o.Namespace, Labels: labels},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Strategy: appsv1.DeploymentStrategy{
+					Type: appsv1.RollingUpdateDeploymentStrategyType,
+					RollingUpdate: &appsv1.RollingUpdateDeployment{
+						MaxSurge:       &intstr.IntOrString{IntVal: 0},
+						MaxUnavailable: &intstr.IntOrString{IntVal: 1},
+					},
+				},
+				Template: apiv1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+					Spec: apiv1.PodSpec{
+						ServiceAccountName:           "kuberhealthy",
+						AutomountServiceAccountToken: &automountServiceAccountToken,
+						Volumes: []apiv1.Volume{{
+							Name:         "config-volume",
+							VolumeSource: apiv1.VolumeSource{ConfigMap: &apiv1.ConfigMapVolumeSource{LocalObjectReference: apiv1.LocalObjectReference{Name: "kuberhealthy"}}},
+						}},
+						Containers: []apiv1.Container{{
+							Name:            "kuberhealthy",
+							Image:           o.Image,
+							ImagePullPolicy: apiv1.PullIfNotPresent,
+							Command:         []string{"/app/kuberhealthy"},
+							Ports:           []apiv1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+							SecurityContext: &apiv1.SecurityContext{
+								RunAsNonRoot:             &runAsNonRoot,
+								RunAsUser:                &runAsUser,
+								AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+								ReadOnlyRootFilesystem:   &readOnlyRootFilesystem,
+								SeccompProfile:           &apiv1.SeccompProfile{Type: apiv1.SeccompProfileTypeRuntimeDefault},
+							},
+							LivenessProbe: &apiv1.Probe{
+								ProbeHandler:        apiv1.ProbeHandler{TCPSocket: &apiv1.TCPSocketAction{Port: intstr.FromInt(8080)}},
+								InitialDelaySeconds: 2,
+								PeriodSeconds:       4,
+								TimeoutSeconds:      1,
+								SuccessThreshold:    1,
+								FailureThreshold:    3,
+							},
+							ReadinessProbe: &apiv1.Probe{
+								ProbeHandler:        apiv1.ProbeHandler{HTTPGet: &apiv1.HTTPGetAction{Path: "/readyz", Port: intstr.FromInt(8080)}},
+								InitialDelaySeconds: 2,
+								PeriodSeconds:       4,
+								TimeoutSeconds:      1,
+								SuccessThreshold:    1,
+								FailureThreshold:    3,
+							},
+							VolumeMounts: []apiv1.VolumeMount{{Name: "config-volume", MountPath: "/etc/config/"}},
+							Env: []apiv1.EnvVar{
+								{Name: "POD_NAME", ValueFrom: &apiv1.EnvVarSource{FieldRef: &apiv1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+								{Name: "POD_NAMESPACE", ValueFrom: &apiv1.EnvVarSource{FieldRef: &apiv1.ObjectFieldSelector{FieldPath: "metadata.namespace"}}},
+								{Name: "POD_IP", ValueFrom: &apiv1.EnvVarSource{FieldRef: &apiv1.ObjectFieldSelector{FieldPath: "status.podIP"}}},
+							},
+						}},
+					},
+				},
+			},
+		},
+		&apiv1.Service{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+			ObjectMeta: metav1.ObjectMeta{Name: "kuberhealthy", Namespace: o.Namespace, Labels: labels},
+			Spec: apiv1.ServiceSpec{
+				Type:     apiv1.ServiceType(o.ServiceType),
+				Selector: labels,
+				Ports:    []apiv1.ServicePort{{Name: "http", Port: 80, TargetPort: intstr.FromString("http")}},
+			},
+		},
+	}
+
+	for _, t := range typed {
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(t)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s to an unstructured object: %w", t.GetObjectKind().GroupVersionKind().Kind, err)
+		}
+
+		// drop the empty fields the converter fills in for objects that have never been created
+		unstructured.RemoveNestedField(u, "metadata", "creationTimestamp")
+		unstructured.RemoveNestedField(u, "spec", "template", "metadata", "creationTimestamp")
+		unstructured.RemoveNestedField(u, "status")
+		objects = append(objects, &unstructured.Unstructured{Object: u})
+	}
+	return objects, nil
+}
+
+// installClusterRoleRules returns the permissions kuberhealthy needs, matching the helm chart.  Kuberhealthy can only
+// bind the cluster roles that isolated run namespaces are allowed to grant.
+func installClusterRoleRules(isolatedNamespaceRoles []string) []rbacv1.PolicyRule {
+	manage := []string{"create", "delete", "deletecollection", "get", "list", "patch", "update", "watch"}
+	return []rbacv1.PolicyRule{
+		{APIGroups: []string{"apps"}, Resources: []string{"daemonsets"}, Verbs: manage},
+		{APIGroups: []string{"extensions"}, Resources: []string{"daemonsets"}, Verbs: manage},
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: manage},
+		{APIGroups: []string{"comcast.github.io"}, Resources: []string{"khstates", "khchecks", "khjobs", "khremediations", "khprobes"}, Verbs: []string{"*"}},
+		{APIGroups: []string{""}, Resources: []string{"namespaces", "componentstatuses", "nodes"}, Verbs: []string{"get", "list", "watch"}},
+		{APIGroups: []string{""}, Resources: []string{"pods/eviction"}, Verbs: []string{"create"}},
+		{APIGroups: []string{"scheduling.k8s.io"}, Resources: []string{"priorityclasses"}, Verbs: []string{"get"}},
+		{APIGroups: []string{"node.k8s.io"}, Resources: []string{"runtimeclasses"}, Verbs: []string{"get"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments", "statefulsets"}, Verbs: []string{"list", "patch"}},
+		{APIGroups: []string{""}, Resources: []string{"services"}, Verbs: []string{"list", "patch"}},
+		{APIGroups: []string{"networking.k8s.io"}, Resources: []string{"ingresses"}, Verbs: []string{"list", "patch"}},
+		{APIGroups: []string{"batch"}, Resources: []string{"jobs"}, Verbs: []string{"create"}},
+		{APIGroups: []string{"coordination.k8s.io"}, Resources: []string{"leases"}, Verbs: []string{"create", "get", "update"}},
+		{APIGroups: []string{"apiextensions.k8s.io"}, Resources: []string{"customresourcedefinitions"}, Verbs: []string{"create", "get", "patch"}},
+		{APIGroups: []string{"apiextensions.k8s.io"}, Resources: []string{"customresourcedefinitions/status"}, Verbs: []string{"update"}},
+		{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"create", "delete"}},
+		{APIGroups: []string{""}, Resources: []string{"resourcequotas", "limitranges"}, Verbs: []string{"create"}},
+		{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"rolebindings"}, Verbs: []string{"create"}},
+		{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"clusterroles"}, Verbs: []string{"bind"}, ResourceNames: isolatedNamespaceRoles},
+	}
+}
+
+// installCRDs loads the custom resource definitions embedded from the helm chart
+func installCRDs() ([]*unstructured.Unstructured, error) {
+	entries, err := fs.ReadDir(deploy.CRDs, deploy.CRDDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embedded CRDs: %w", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+
+	var crds []*unstructured.Unstructured
+	for _, name := range names {
+		b, err := deploy.CRDs.ReadFile(path.Join(deploy.CRDDir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read embedded CRD %s: %w", name, err)
+		}
+		j, err := yaml.YAMLToJSON(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse embedded CRD %s: %w", name, err)
+		}
+		crd := &unstructured.Unstructured{}
+		err = crd.UnmarshalJSON(j)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse embedded CRD %s: %w", name, err)
+		}
+		unstructured.RemoveNestedField(crd.Object, "metadata", "creationTimestamp")
+		unstructured.RemoveNestedField(crd.Object, "status")
+		err = setCRDSchemaHash(crd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash embedded CRD %s: %w", name, err)
+		}
+		crds = append(crds, crd)
+	}
+	return crds, nil
+}
+
+// renderManifests writes the objects to out as a multi-document YAML stream
+func renderManifests(objects []*unstructured.Unstructured, out io.Writer) error {
+	for _, obj := range objects {
+		b, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return fmt.Errorf("failed to render %s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		_, err = fmt.Fprintf(out, "---\n%s", b)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyManifests applies the objects to the cluster in order using server side apply as the supplied field manager
+func applyManifests(ctx context.Context, client dynamic.Interface, objects []*unstructured.Unstructured, fieldManager string, out io.Writer) error {
+	for _, obj := range objects {
+		gvr, err := installResource(obj.GroupVersionKind())
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(obj.Object)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+
+		force := true
+		patchOptions := metav1.PatchOptions{FieldManager: fieldManager, Force: &force}
+		var resource dynamic.ResourceInterface = client.Resource(gvr)
+		if len(obj.GetNamespace()) > 0 {
+			resource = client.Resource(gvr).Namespace(obj.GetNamespace())
+		}
+		_, err = resource.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, patchOptions)
+		if err != nil {
+			return fmt.Errorf("failed to apply %s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		fmt.Fprintln(out, obj.GetKind(), obj.GetName(), "applied")
+	}
+	return nil
+}
+
+// installResource maps the kinds of the install manifests and imported checks to their API resources
+func installResource(gvk schema.GroupVersionKind) (schema.GroupVersionResource, error) {
+	resources := map[string]string{
+		"CustomResourceDefinition": "customresourcedefinitions",
+		"Namespace":                "namespaces",
+		"ServiceAccount":           "serviceaccounts",
+		"ClusterRole":              "clusterroles",
+		"ClusterRoleBinding":       "clusterrolebindings",
+		"ConfigMap":                "configmaps",
+		"Deployment":               "deployments",
+		"Service":                  "services",
+		"KuberhealthyCheck":        checkCRDResource,
+	}
+	resource, ok := resources[gvk.Kind]
+	if !ok {
+		return schema.GroupVersionResource{}, fmt.Errorf("unable to apply unknown kind %s", gvk.Kind)
+	}
+	return gvk.GroupVersion().WithResource(resource), nil
+}
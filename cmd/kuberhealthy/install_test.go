@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestInstallRender ensures that the install subcommand renders every manifest needed to run kuberhealthy with the
+// requested customizations
+func TestInstallRender(t *testing.T) {
+
+	o := installOpts
+	o.Render = true
+	o.Namespace = "monitoring"
+	o.Image = "registry.example.com/kuberhealthy:test"
+	o.Replicas = 3
+	o.IsolatedNamespaceRoles = []string{"kuberhealthy-test-resources"}
+
+	out := &bytes.Buffer{}
+	err := runInstall(context.Background(), o, out)
+	if err != nil {
+		t.Fatalf("failed to render install manifests: %s", err)
+	}
+	rendered := out.String()
+
+	var testCases = []string{
+		"kind: CustomResourceDefinition\nmetadata:\n  annotations:",
+		"name: khchecks.comcast.github.io",
+		"name: khjobs.comcast.github.io",
+		"name: khstates.comcast.github.io",
+		"kind: Namespace\nmetadata:\n  name: monitoring",
+		"kind: ServiceAccount",
+		"kind: ClusterRole\n",
+		"kind: ClusterRoleBinding",
+		"kind: ConfigMap",
+		"kind: Deployment",
+		"image: registry.example.com/kuberhealthy:test",
+		"replicas: 3",
+		"kind: Service\n",
+		"resourceNames:\n  - edit\n  - kuberhealthy-test-resources\n  resources:\n  - clusterroles\n  verbs:\n  - bind",
+		"isolatedNamespaceRoles:\n    - kuberhealthy-test-resources",
+	}
+	for _, tc := range testCases {
+		if !strings.Contains(rendered, tc) {
+			t.Fatalf("rendered manifests did not contain %q:\n%s", tc, rendered)
+		}
+	}
+	if strings.Contains(rendered, "creationTimestamp: null") {
+		t.Fatal("rendered manifests contained empty creation timestamps")
+	}
+	if strings.Count(rendered, "namespace: monitoring") < 5 {
+		t.Fatal("not all namespaced objects were placed in the requested namespace")
+	}
+}
+
+// TestInstallManifestsValidation ensures that invalid install options are rejected
+func TestInstallManifestsValidation(t *testing.T) {
+	o := installOpts
+	o.Namespace = ""
+	_, err := installManifests(o)
+	if err == nil {
+		t.Fatal("expected an error for an empty namespace")
+	}
+
+	o = installOpts
+	o.Replicas = 0
+	_, err = installManifests(o)
+	if err == nil {
+		t.Fatal("expected an error for zero replicas")
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"net"
 	"net/http"
 	"reflect"
 	"strconv"
@@ -29,9 +28,14 @@ import (
 	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/clock"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/cloudevents"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/coverage"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/health"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/imageversions"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/masterCalculation"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/metrics"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/plugins"
 )
 
 // Kuberhealthy represents the kuberhealthy server and its checks
@@ -39,54 +43,109 @@ type Kuberhealthy struct {
 	Checks             []*external.Checker
 	ListenAddr         string // the listen address, such as ":80"
 	MetricForwarder    metrics.Client
+	EventSender        *cloudevents.Sender // sends check results as CloudEvents when a sink is configured
+	Plugins            *plugins.Set        // the notifier, state store and metric sink plugins
 	overrideKubeClient *kubernetes.Clientset
-	cancelChecksFunc   context.CancelFunc // invalidates the context of all running checks
-	cancelReaperFunc   context.CancelFunc // invalidates the context of the reaper
-	wg                 sync.WaitGroup     // used to track running checks
-	shutdownCtxFunc    context.CancelFunc // used to shutdown the main control select
-	stateReflector     *StateReflector    // a reflector that can cache the current state of the khState resources
+	cancelChecksFunc   context.CancelFunc         // invalidates the context of all running checks
+	cancelReaperFunc   context.CancelFunc         // invalidates the context of the reaper
+	wg                 sync.WaitGroup             // used to track running checks
+	shutdownCtxFunc    context.CancelFunc         // used to shutdown the main control select
+	stateReflector     *StateReflector            // a reflector that can cache the current state of the khState resources
+	runTracker         *external.RunTracker       // tracks the validity window of run UUIDs so that late reports can be detected
+	residents          *external.ResidentRegistry // hands the runs of resident checks to their registered resident checkers
+	reportsInFlight    int32                      // the number of check reports currently being handled
+	coverageReport     *coverage.Report           // the most recent khcheck coverage report
+	coverageMu         sync.RWMutex               // guards coverageReport
+	imageVersionReport *imageversions.Report      // the most recent check image version report
+	imageVersionMu     sync.RWMutex               // guards imageVersionReport
+	ruleResults        *ruleResults               // the most recent results of the health rules
+	ruleMu             sync.RWMutex               // guards ruleResults
+	correlations       map[string]string          // the current values of the configured correlation IDs
+	correlationsMu     sync.RWMutex               // guards correlations
+	alertDeliveries    *alertDeliveries           // the synthetic alerts delivered to the webhook receiver
+	nodeCache          *nodeCache                 // the nodes of the cluster, shared by every check
+	node               string                     // the node this kuberhealthy pod runs on, kept free of checker pods with anti-affinity
+	requestMetrics     *metrics.RequestMetrics    // the requests served by the web server
+	degraded           degradedMode               // tracks outages of the Kubernetes API and the khstate writes queued during them
+	schedule           runSchedule                // when each check scheduled by this replica runs next
+	Clock              clock.Clock                // times check intervals, backoffs, deadlines and master changes. The real clock is used when nil.
 }
 
 // NewKuberhealthy creates a new kuberhealthy checker instance
 func NewKuberhealthy() *Kuberhealthy {
 	kh := &Kuberhealthy{}
 	kh.stateReflector = NewStateReflector()
+	kh.runTracker = external.NewRunTracker()
+	kh.residents = external.NewResidentRegistry()
+	kh.alertDeliveries = newAlertDeliveries()
+	kh.requestMetrics = metrics.NewRequestMetrics()
 	return kh
 }
 
+// clock returns the clock check intervals, backoffs, deadlines and master changes are timed with
+func (k *Kuberhealthy) clock() clock.Clock {
+	return clock.OrReal(k.Clock)
+}
+
 // setCheckExecutionError sets an execution error for a check name in
-// its crd status
-func (k *Kuberhealthy) setCheckExecutionError(checkName string, checkNamespace string, exErr error) error {
+// its crd status.  Provisioning errors are recorded as such and return how long the check should back off for
+// before its next run.
+func (k *Kuberhealthy) setCheckExecutionError(checkName string, checkNamespace string, exErr error, interval time.Duration) (time.Duration, error) {
 	details := khstatev1.NewWorkloadDetails(khstatev1.KHCheck)
 	check, err := k.getCheck(checkName, checkNamespace)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if check.Namespace != "" {
 		details.Namespace = check.CheckNamespace()
 	}
 	details.OK = false
 	details.Errors = []string{"Check execution error: " + exErr.Error()}
+	if external.IsProvisioningError(exErr) {
+		details.Errors = []string{"Check provisioning error: " + exErr.Error()}
+	}
+	details.Reason = external.FailureReason(exErr)
+	var fanOutErr *external.FanOutError
+	if errors.As(exErr, &fanOutErr) {
+		details.NodeStatuses = fanOutErr.NodeStatuses
+		details.ZoneStatuses = fanOutErr.ZoneStatuses
+
+		// runs that timed out waiting on some of their targets still pass when enough of the others did
+		if fanOutErr.Passed {
+			details.OK = true
+			details.Errors = []string{}
+			details.Reason = ""
+		}
+	}
 
 	// we need to maintain the current UUID, which means fetching it first
 	khc, err := k.getCheck(checkName, checkNamespace)
 	if err != nil {
-		return fmt.Errorf("Error when setting execution error on check %s %s %w", checkName, checkNamespace, err)
+		return 0, fmt.Errorf("Error when setting execution error on check %s %s %w", checkName, checkNamespace, err)
 	}
 
 	checkState, err := getCheckState(khc)
 	if err != nil {
-		return fmt.Errorf("Error when setting execution error on check (getting check state for current UUID) %s %s %w", checkName, checkNamespace, err)
+		return 0, fmt.Errorf("Error when setting execution error on check (getting check state for current UUID) %s %s %w", checkName, checkNamespace, err)
 	}
 	details.CurrentUUID = checkState.CurrentUUID
+	details.History = checkState.History
+	k.recordRunHistory(&details)
+
+	var backoff time.Duration
+	if external.IsProvisioningError(exErr) {
+		backoff = applyProvisioningError(&details, checkState, interval, k.clock().Now())
+	}
 	log.Debugln("Setting execution state of check", checkName, "to", details.OK, details.Errors, details.CurrentUUID, details.GetKHWorkload())
 
 	// store the check state with the CRD
+	k.seedEventState(checkName, checkNamespace, khstatev1.KHCheck)
 	err = k.storeCheckState(checkName, checkNamespace, details)
 	if err != nil {
-		return fmt.Errorf("Was unable to write an execution error to the CRD status with error: %w", err)
+		return backoff, fmt.Errorf("Was unable to write an execution error to the CRD status with error: %w", err)
 	}
-	return nil
+	k.publishRunCompleted(checkName, checkNamespace, details)
+	return backoff, nil
 }
 
 // setJobExecutionError sets an execution error for a job name in its crd status
@@ -101,6 +160,7 @@ func (k *Kuberhealthy) setJobExecutionError(jobName string, jobNamespace string,
 	}
 	details.OK = false
 	details.Errors = []string{"Job execution error: " + exErr.Error()}
+	details.Reason = external.FailureReason(exErr)
 
 	// we need to maintain the current UUID, which means fetching it first
 	khj, err := k.getJob(jobName, jobNamespace)
@@ -112,14 +172,18 @@ func (k *Kuberhealthy) setJobExecutionError(jobName string, jobNamespace string,
 		return fmt.Errorf("Error when setting execution error on job (getting job state for current UUID) %s %s %w", jobName, jobNamespace, err)
 	}
 	details.CurrentUUID = jobState.CurrentUUID
+	details.History = jobState.History
+	k.recordRunHistory(&details)
 
 	log.Debugln("Setting execution state of job", jobName, "to", details.OK, details.Errors, details.CurrentUUID, details.GetKHWorkload())
 
 	// store the check state with the CRD
+	k.seedEventState(jobName, jobNamespace, khstatev1.KHJob)
 	err = k.storeCheckState(jobName, jobNamespace, details)
 	if err != nil {
 		return fmt.Errorf("Was unable to write an execution error to the CRD status with error: %w", err)
 	}
+	k.publishRunCompleted(jobName, jobNamespace, details)
 	return nil
 }
 
@@ -131,6 +195,8 @@ func (k *Kuberhealthy) AddCheck(c *external.Checker) {
 
 // Shutdown causes the kuberhealthy chec k group to shutdown gracefully
 func (k *Kuberhealthy) Shutdown(doneChan chan struct{}) {
+	// wait on or leave behind the runs in flight before the checks are stopped
+	k.applyShutdownPolicy()
 	if k.shutdownCtxFunc != nil {
 		log.Infoln("shutdown: aborting control context")
 		k.shutdownCtxFunc() // stop the control system
@@ -138,6 +204,7 @@ func (k *Kuberhealthy) Shutdown(doneChan chan struct{}) {
 	time.Sleep(5) // help prevent more checks from starting in a race before control system stop happens
 	log.Infoln("shutdown: stopping checks")
 	k.StopChecks() // stop all checks
+	k.closePlugins()
 	log.Infoln("shutdown: ready for main program shutdown")
 	doneChan <- struct{}{}
 }
@@ -177,14 +244,74 @@ func (k *Kuberhealthy) Start(ctx context.Context) {
 	// start the khState reflector
 	go k.stateReflector.Start()
 
+	// cache the nodes of the cluster for the checks that fan out over them and for health rules
+	k.nodeCache = newNodeCache(kubernetesClient)
+	k.nodeCache.Start(ctx)
+
+	// persist the runs in flight so that they survive restarts and master changes
+	k.runTracker.SetStore(newLeaseRunStore(kubernetesClient, podNamespace))
+
 	// if influxdb is enabled, configure it
 	if cfg.EnableInflux == true {
 		k.configureInfluxForwarding()
 	}
 
+	// keep serving the last known status and queue khstate writes while the Kubernetes API is unavailable
+	go k.monitorAPIAvailability(ctx)
+
+	// add the configured correlation IDs to check states and notifications
+	if len(cfg.Correlations) > 0 {
+		go k.monitorCorrelations(ctx)
+	}
+
+	// if a CloudEvents sink is set, send check results to it
+	if len(cfg.CloudEventsSink) > 0 {
+		k.configureCloudEvents()
+	}
+
+	// load the notifier, state store and metric sink plugins
+	if len(cfg.Plugins) > 0 {
+		k.configurePlugins()
+	}
+
 	// Start the web server and restart it if it crashes
 	go k.StartWebServer()
 
+	// serve the gRPC reporting service if it is configured
+	if len(cfg.GRPCListenAddress) > 0 {
+		go k.StartGRPCServer()
+	}
+
+	// report which namespaces and workloads are covered by khchecks
+	if cfg.EnableCoverage {
+		go k.monitorCoverage(ctx)
+	}
+
+	// generate khchecks from the probe annotations of services and ingresses
+	if cfg.EnableDiscovery {
+		go k.monitorDiscovery(ctx)
+	}
+
+	// expand khprobes into a khcheck for each of their targets
+	if cfg.EnableProbes {
+		go k.monitorProbes(ctx)
+	}
+
+	// report out of date check images and update them if configured
+	if cfg.EnableImageVersions {
+		go k.monitorImageVersions(ctx)
+	}
+
+	// evaluate health rules over the check results and report them as synthetic checks
+	if len(cfg.HealthRules) > 0 {
+		go k.monitorHealthRules(ctx)
+	}
+
+	// send a regular digest of the check results to slack or email
+	if cfg.Digest.Enabled() {
+		go k.monitorDigests(ctx)
+	}
+
 	// find all the external checks from the khcheckcrd resources on the cluster and keep them in sync.
 	// use rate limiting to avoid reconfiguration spam
 	maxUpdateInterval := time.Second * 10
@@ -345,6 +472,12 @@ func (k *Kuberhealthy) reapKHStateResources(ctx context.Context) error {
 			}
 		}
 
+		// self-check khstates have no khcheck behind them and are kept while self-checks are enabled
+		if cfg.EnableSelfChecks && isSelfCheckState(khState.GetName(), khState.GetNamespace()) {
+			log.Debugln("khState reaper:", khState.GetName(), "in", khState.GetNamespace(), "holds a self-check result")
+			continue
+		}
+
 		// if we didn't find a matching khCheck or khJob, delete the rogue khState
 		if !foundKHCheck && !foundKHJob {
 			log.Infoln("khState reaper: removing khState", khState.GetName(), "in", khState.GetNamespace())
@@ -511,6 +644,7 @@ func (k *Kuberhealthy) monitorExternalChecks(ctx context.Context, notify chan st
 
 	// make a map of resource versions so we know when things change
 	knownSettings := make(map[string]khcheckv1.CheckConfig)
+	knownLabels := make(map[string]map[string]string) // the propagated labels of each khcheck
 
 	// start watching for events to changes in the background
 	c := make(chan struct{})
@@ -552,6 +686,7 @@ func (k *Kuberhealthy) monitorExternalChecks(ctx context.Context, notify chan st
 			if !existsInItems {
 				log.Debugln("Detected khcheck deletion for", mapName)
 				delete(knownSettings, mapName)
+				delete(knownLabels, mapName)
 				foundChange = true
 			}
 		}
@@ -609,14 +744,106 @@ func (k *Kuberhealthy) monitorExternalChecks(ctx context.Context, notify chan st
 				foundChange = true
 			}
 
+			// check if the reporting URL mode has changed
+			if knownSettings[mapName].ReportingURLMode != i.Spec.ReportingURLMode {
+				log.Debugln("The khcheck reporting URL mode for", mapName, "has changed.")
+				foundChange = true
+			}
+
+			// check if the sidecar handling has changed
+			if knownSettings[mapName].SidecarHandling != i.Spec.SidecarHandling {
+				log.Debugln("The khcheck sidecar handling for", mapName, "has changed.")
+				foundChange = true
+			}
+
+			// check if the operating system or architecture has changed
+			if knownSettings[mapName].OS != i.Spec.OS || knownSettings[mapName].Arch != i.Spec.Arch {
+				log.Debugln("The khcheck platform for", mapName, "has changed.")
+				foundChange = true
+			}
+
+			// check if the security context policy has changed
+			if knownSettings[mapName].SecurityContextPolicy != i.Spec.SecurityContextPolicy {
+				log.Debugln("The khcheck security context policy for", mapName, "has changed.")
+				foundChange = true
+			}
+
+			// check if the kuberhealthy anti-affinity has changed
+			if knownSettings[mapName].KuberhealthyAntiAffinity != i.Spec.KuberhealthyAntiAffinity {
+				log.Debugln("The khcheck kuberhealthy anti-affinity for", mapName, "has changed.")
+				foundChange = true
+			}
+
+			// check if the projected service account tokens have changed
+			if !reflect.DeepEqual(knownSettings[mapName].ServiceAccountTokens, i.Spec.ServiceAccountTokens) {
+				log.Debugln("The khcheck service account tokens for", mapName, "have changed.")
+				foundChange = true
+			}
+
+			// check if the max deadline extension has changed
+			if knownSettings[mapName].MaxDeadlineExtension != i.Spec.MaxDeadlineExtension {
+				log.Debugln("The khcheck max deadline extension for", mapName, "has changed.")
+				foundChange = true
+			}
+
+			// check if the start timeout has changed
+			if knownSettings[mapName].StartTimeout != i.Spec.StartTimeout {
+				log.Debugln("The khcheck start timeout for", mapName, "has changed.")
+				foundChange = true
+			}
+
+			// check if the heartbeat timeout has changed
+			if knownSettings[mapName].HeartbeatTimeout != i.Spec.HeartbeatTimeout {
+				log.Debugln("The khcheck heartbeat timeout for", mapName, "has changed.")
+				foundChange = true
+			}
+
+			// check if the concurrency policy has changed
+			if knownSettings[mapName].ConcurrencyPolicy != i.Spec.ConcurrencyPolicy {
+				log.Debugln("The khcheck concurrency policy for", mapName, "has changed.")
+				foundChange = true
+			}
+
+			// check if the check has switched to or from resident checkers
+			if knownSettings[mapName].Resident != i.Spec.Resident {
+				log.Debugln("The khcheck resident setting for", mapName, "has changed.")
+				foundChange = true
+			}
+
+			// check if the check has switched to or from running on all nodes or per zone, runs on other nodes, or combines
+			// the results of its nodes or zones differently
+			if knownSettings[mapName].RunOnAllNodes != i.Spec.RunOnAllNodes || knownSettings[mapName].RunPerZone != i.Spec.RunPerZone || !reflect.DeepEqual(knownSettings[mapName].NodeSelector, i.Spec.NodeSelector) || !reflect.DeepEqual(knownSettings[mapName].Aggregation, i.Spec.Aggregation) {
+				log.Debugln("The khcheck node fan out settings for", mapName, "have changed.")
+				foundChange = true
+			}
+
+			// check if the isolated namespace settings have changed
+			if !reflect.DeepEqual(knownSettings[mapName].IsolatedNamespace, i.Spec.IsolatedNamespace) {
+				log.Debugln("The khcheck isolated namespace settings for", mapName, "have changed.")
+				foundChange = true
+			}
+
+			// check if the labels propagated to checker pods have changed
+			if !reflect.DeepEqual(knownLabels[mapName], propagatedLabels(i.Labels)) {
+				log.Debugln("The khcheck propagated labels for", mapName, "have changed.")
+				foundChange = true
+			}
+
 			// check if CheckConfig has changed (PodSpec)
 			if !foundChange && !reflect.DeepEqual(knownSettings[mapName].PodSpec, i.Spec.PodSpec) {
 				log.Debugln("The khcheck for", mapName, "has changed.")
 				foundChange = true
 			}
 
+			// check if the pod template has changed
+			if !foundChange && !reflect.DeepEqual(knownSettings[mapName].PodTemplate, i.Spec.PodTemplate) {
+				log.Debugln("The khcheck pod template for", mapName, "has changed.")
+				foundChange = true
+			}
+
 			// finally, update known settings before continuing to the next interval
 			knownSettings[mapName] = i.Spec
+			knownLabels[mapName] = propagatedLabels(i.Labels)
 		}
 
 		// if a change was detected, we signal the notify channel
@@ -653,39 +880,86 @@ func (k *Kuberhealthy) addExternalChecks(ctx context.Context) error {
 
 		// create a new kubernetes client for this external checker
 		log.Infoln("Enabling external check:", r.Name)
-		c := external.New(kubernetesClient, &r, khCheckClient, khStateClient, cfg.ExternalCheckReportingURL)
+		c := external.New(kubernetesClient, &r, khCheckClient, khStateClient, reportingURLOrDefault(r.Spec.ReportingURLMode, r.Namespace+"/"+r.Name))
+		c.Runs = k.runTracker
+		c.Residents = k.residents
+		c.Clock = k.Clock
+		if k.nodeCache != nil {
+			c.Nodes = k.nodeCache
+		}
+		if c.Resident {
+			k.residents.Allow(c.CheckName, c.Namespace)
+		}
+		c.SpecErrors = append(c.SpecErrors, checkModeErrors(c)...)
+		c.SpecErrors = append(c.SpecErrors, podTemplateErrors(r.Spec.PodSpec, r.Spec.PodTemplate)...)
+		c.SpecErrors = append(c.SpecErrors, isolatedNamespaceErrors(c.IsolatedNamespace)...)
+		if len(c.SecurityContextPolicy) == 0 {
+			c.SecurityContextPolicy = cfg.SecurityContextPolicy
+		}
+		if len(c.KuberhealthyAntiAffinity) == 0 {
+			c.KuberhealthyAntiAffinity = cfg.KuberhealthyAntiAffinity
+		}
+		c.ReportSigningKey = cfg.ReportSigningKey
+		c.GRPCReportingAddress = grpcReportingAddress()
+		c.KuberhealthyNode = k.node
 
 		// parse the run interval string from the custom resource and setup the run interval
-		c.RunInterval, err = time.ParseDuration(r.Spec.RunInterval)
+		c.RunInterval, err = parseSpecDuration("runInterval", r.Spec.RunInterval, DefaultRunInterval)
 		if err != nil {
 			log.Errorln("Error parsing duration for check", c.CheckName, "in namespace", c.Namespace, err)
-			log.Errorln("Defaulting check to a runtime of ten minutes.")
-			c.RunInterval = DefaultRunInterval
+			log.Errorln("Runs of the check are scheduled every", DefaultRunInterval, "and fail with the spec error until it is fixed")
+			c.SpecErrors = append(c.SpecErrors, err.Error())
 		}
 
 		log.Debugln("RunInterval for check:", c.CheckName, "set to", c.RunInterval)
 
 		// parse the user specified timeout if present
-		c.RunTimeout = DefaultTimeout
-		if len(r.Spec.Timeout) > 0 {
-			c.RunTimeout, err = time.ParseDuration(r.Spec.Timeout)
+		c.RunTimeout, err = parseSpecDuration("timeout", r.Spec.Timeout, DefaultTimeout)
+		if err != nil {
+			log.Errorln("Error parsing timeout for check", c.CheckName, "in namespace", c.Namespace, err)
+			log.Errorln("Runs of the check fail with the spec error until it is fixed")
+			c.SpecErrors = append(c.SpecErrors, err.Error())
+		}
+
+		log.Debugln("RunTimeout for check:", c.CheckName, "set to", c.RunTimeout)
+
+		// parse the most a run can be extended by, if extensions are allowed
+		if len(r.Spec.MaxDeadlineExtension) > 0 {
+			c.MaxDeadlineExtension, err = parseSpecDuration("maxDeadlineExtension", r.Spec.MaxDeadlineExtension, 0)
 			if err != nil {
-				log.Errorln("Error parsing timeout for check", c.CheckName, "in namespace", c.Namespace, err)
-				log.Errorln("Defaulting check to a timeout of", DefaultTimeout)
+				log.Errorln("Error parsing max deadline extension for check", c.CheckName, "in namespace", c.Namespace, err)
+				c.SpecErrors = append(c.SpecErrors, err.Error())
 			}
 		}
 
-		log.Debugln("RunTimeout for check:", c.CheckName, "set to", c.RunTimeout)
+		// parse how long the checker pod can take to start, if it is bounded separately from the run
+		if len(r.Spec.StartTimeout) > 0 {
+			c.StartTimeout, err = parseSpecDuration("startTimeout", r.Spec.StartTimeout, 0)
+			if err != nil {
+				log.Errorln("Error parsing start timeout for check", c.CheckName, "in namespace", c.Namespace, err)
+				c.SpecErrors = append(c.SpecErrors, err.Error())
+			}
+		}
+
+		// parse how long runs may go without a heartbeat, if the checker pod sends them
+		if len(r.Spec.HeartbeatTimeout) > 0 {
+			c.HeartbeatTimeout, err = parseHeartbeatTimeout(r.Spec.HeartbeatTimeout)
+			if err != nil {
+				log.Errorln("Error parsing heartbeat timeout for check", c.CheckName, "in namespace", c.Namespace, err)
+				c.SpecErrors = append(c.SpecErrors, err.Error())
+			}
+		}
 
 		// add on extra annotations and labels
 		if c.ExtraAnnotations != nil {
 			log.Debugln("External check setting extra annotations:", c.ExtraAnnotations)
-			c.ExtraAnnotations = r.Spec.ExtraAnnotations
+			c.ExtraAnnotations = r.Spec.EffectivePodAnnotations()
 		}
 		if c.ExtraLabels != nil {
 			log.Debugln("External check setting extra labels:", c.ExtraLabels)
-			c.ExtraLabels = r.Spec.ExtraLabels
+			c.ExtraLabels = r.Spec.EffectivePodLabels()
 		}
+		c.PropagatedLabels = propagatedLabels(r.Labels)
 		log.Debugln("External check labels and annotations:", c.ExtraLabels, c.ExtraAnnotations)
 
 		// add the check into the checker
@@ -695,6 +969,70 @@ func (k *Kuberhealthy) addExternalChecks(ctx context.Context) error {
 	return nil
 }
 
+// checkModeErrors returns the problems with how a check is set to run, such as a resident check that is also fanned
+// out to every node, and with its aggregation
+func checkModeErrors(c *external.Checker) []string {
+	var errs []string
+	if c.Resident && (c.RunOnAllNodes || c.RunPerZone) {
+		errs = append(errs, "resident checks can not also set runOnAllNodes or runPerZone")
+	}
+	if c.RunOnAllNodes && c.RunPerZone {
+		errs = append(errs, "runOnAllNodes and runPerZone can not both be set")
+	}
+	err := external.ValidateAggregation(c.Aggregation)
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	return errs
+}
+
+// podTemplateErrors returns the problems with how the pod of a check or job is described.  The pod is described by
+// either podSpec or podTemplate, but not both.
+func podTemplateErrors(podSpec v1.PodSpec, podTemplate *v1.PodTemplateSpec) []string {
+	if podTemplate != nil && !reflect.DeepEqual(podSpec, v1.PodSpec{}) {
+		return []string{"podSpec and podTemplate can not both be set"}
+	}
+	return nil
+}
+
+// isolatedNamespaceErrors returns the problems with the isolated namespace settings of a check or job.  Kuberhealthy
+// can only bind the cluster roles it is configured to allow within run namespaces.
+func isolatedNamespaceErrors(iso *external.IsolatedNamespace) []string {
+	if iso == nil {
+		return nil
+	}
+	clusterRole := iso.ClusterRole
+	if len(clusterRole) == 0 {
+		clusterRole = external.DefaultRunNamespaceClusterRole
+	}
+
+	var configured []string
+	if cfg != nil {
+		configured = cfg.IsolatedNamespaceRoles
+	}
+	allowed := isolatedNamespaceClusterRoles(configured)
+	for _, role := range allowed {
+		if role == clusterRole {
+			return nil
+		}
+	}
+	return []string{fmt.Sprintf("isolatedNamespace.clusterRole %s is not allowed. Allowed cluster roles: %s", clusterRole, strings.Join(allowed, ", "))}
+}
+
+// isolatedNamespaceClusterRoles returns the cluster roles that checks and jobs may be granted within their run
+// namespaces.  The default cluster role is always allowed.
+func isolatedNamespaceClusterRoles(configured []string) []string {
+	roles := []string{external.DefaultRunNamespaceClusterRole}
+	for _, role := range configured {
+		role = strings.TrimSpace(role)
+		if len(role) == 0 || containsString(role, roles) {
+			continue
+		}
+		roles = append(roles, role)
+	}
+	return roles
+}
+
 // addExternalJobs syncs up the state of the all jobs installed in this Kuberhealthy struct.
 func (k *Kuberhealthy) configureJob(job khjobv1.KuberhealthyJob) *external.Checker {
 
@@ -702,30 +1040,65 @@ func (k *Kuberhealthy) configureJob(job khjobv1.KuberhealthyJob) *external.Check
 
 	// create a new kubernetes client for this external checker
 	log.Infoln("Enabling external job:", job.Name)
-	kj := external.NewJob(kubernetesClient, &job, khJobClient, khStateClient, cfg.ExternalCheckReportingURL)
+	kj := external.NewJob(kubernetesClient, &job, khJobClient, khStateClient, reportingURLOrDefault(job.Spec.ReportingURLMode, job.Namespace+"/"+job.Name))
+	kj.Runs = k.runTracker
+	kj.Clock = k.Clock
+	kj.SpecErrors = append(kj.SpecErrors, podTemplateErrors(job.Spec.PodSpec, job.Spec.PodTemplate)...)
+	kj.SpecErrors = append(kj.SpecErrors, isolatedNamespaceErrors(kj.IsolatedNamespace)...)
+	if len(kj.SecurityContextPolicy) == 0 {
+		kj.SecurityContextPolicy = cfg.SecurityContextPolicy
+	}
+	kj.ReportSigningKey = cfg.ReportSigningKey
+	kj.GRPCReportingAddress = grpcReportingAddress()
 
-	var err error
 	// parse the user specified timeout if present
-	kj.RunTimeout = DefaultTimeout
-	if len(job.Spec.Timeout) > 0 {
-		kj.RunTimeout, err = time.ParseDuration(job.Spec.Timeout)
+	var err error
+	kj.RunTimeout, err = parseSpecDuration("timeout", job.Spec.Timeout, DefaultTimeout)
+	if err != nil {
+		log.Errorln("Error parsing timeout for job", kj.CheckName, "in namespace", kj.Namespace, err)
+		log.Errorln("The job fails with the spec error instead of running")
+		kj.SpecErrors = append(kj.SpecErrors, err.Error())
+	}
+
+	log.Debugln("RunTimeout for job:", kj.CheckName, "set to", kj.RunTimeout)
+
+	// parse the most a run can be extended by, if extensions are allowed
+	if len(job.Spec.MaxDeadlineExtension) > 0 {
+		kj.MaxDeadlineExtension, err = parseSpecDuration("maxDeadlineExtension", job.Spec.MaxDeadlineExtension, 0)
 		if err != nil {
-			log.Errorln("Error parsing timeout for check", kj.CheckName, "in namespace", kj.Namespace, err)
-			log.Errorln("Defaulting check to a timeout of", DefaultTimeout)
+			log.Errorln("Error parsing max deadline extension for job", kj.CheckName, "in namespace", kj.Namespace, err)
+			kj.SpecErrors = append(kj.SpecErrors, err.Error())
 		}
 	}
 
-	log.Debugln("RunTimeout for job:", kj.CheckName, "set to", kj.RunTimeout)
+	// parse how long the job pod can take to start, if it is bounded separately from the run
+	if len(job.Spec.StartTimeout) > 0 {
+		kj.StartTimeout, err = parseSpecDuration("startTimeout", job.Spec.StartTimeout, 0)
+		if err != nil {
+			log.Errorln("Error parsing start timeout for job", kj.CheckName, "in namespace", kj.Namespace, err)
+			kj.SpecErrors = append(kj.SpecErrors, err.Error())
+		}
+	}
+
+	// parse how long runs may go without a heartbeat, if the job pod sends them
+	if len(job.Spec.HeartbeatTimeout) > 0 {
+		kj.HeartbeatTimeout, err = parseHeartbeatTimeout(job.Spec.HeartbeatTimeout)
+		if err != nil {
+			log.Errorln("Error parsing heartbeat timeout for job", kj.CheckName, "in namespace", kj.Namespace, err)
+			kj.SpecErrors = append(kj.SpecErrors, err.Error())
+		}
+	}
 
 	// add on extra annotations and labels
 	if kj.ExtraAnnotations != nil {
 		log.Debugln("External job setting extra annotations:", kj.ExtraAnnotations)
-		kj.ExtraAnnotations = job.Spec.ExtraAnnotations
+		kj.ExtraAnnotations = job.Spec.EffectivePodAnnotations()
 	}
 	if kj.ExtraLabels != nil {
 		log.Debugln("External job setting extra labels:", kj.ExtraLabels)
-		kj.ExtraLabels = job.Spec.ExtraLabels
+		kj.ExtraLabels = job.Spec.EffectivePodLabels()
 	}
+	kj.PropagatedLabels = propagatedLabels(job.Labels)
 	log.Debugln("External job labels and annotations:", kj.ExtraLabels, kj.ExtraAnnotations)
 	return kj
 }
@@ -745,8 +1118,28 @@ func (k *Kuberhealthy) StartChecks(ctx context.Context) {
 	// wait for all check wg to be done, just in case
 	k.wg.Wait()
 
+	// pick up the runs that were in flight when the previous master stopped so that checks can resume them.  This is
+	// done while the checks are configured so that a new master starts its checks sooner after a failover.
+	restored := make(chan struct{})
+	go func() {
+		defer close(restored)
+		err := k.runTracker.Restore()
+		if err != nil {
+			log.Errorln("control:", err)
+		}
+	}()
+
+	// look up the node this master runs on so that checks can keep their pods off of it
+	if kubernetesClient != nil {
+		k.node = kuberhealthyNode(ctx, kubernetesClient, podNamespace, podHostname)
+	}
+
 	log.Infoln("control: Reloading check configuration...")
 	k.configureChecks(ctx)
+	<-restored
+
+	// adopt the checker pods left running by the previous master and reap the ones no check expects
+	k.reconcileRuns(ctx)
 
 	// sleep to make a more graceful switch-up during lots of master and check changes coming in
 	log.Infoln("control:", len(k.Checks), "checks starting!")
@@ -762,6 +1155,11 @@ func (k *Kuberhealthy) StartChecks(ctx context.Context) {
 		go k.runCheck(checkGroupCtx, c)
 	}
 
+	// watch over the internals of kuberhealthy along with the checks
+	if cfg.EnableSelfChecks {
+		k.startSelfChecks(checkGroupCtx)
+	}
+
 	// spin up the khState reaper with a context after checks have been configured and started
 	log.Infoln("control: reaper starting!")
 	go k.khStateResourceReaper(ctx)
@@ -805,7 +1203,7 @@ func (k *Kuberhealthy) masterStatusWatcher(ctx context.Context) {
 		for range watcher.ResultChan() {
 
 			// update the time we last saw a master event
-			lastMasterChangeTime = time.Now()
+			lastMasterChangeTime = k.clock().Now()
 
 			// determine if we are becoming master or not
 			var err error
@@ -816,7 +1214,7 @@ func (k *Kuberhealthy) masterStatusWatcher(ctx context.Context) {
 
 			// update the time we last saw a master event
 			log.Debugln("master status monitor saw a master event")
-			lastMasterChangeTime = time.Now()
+			lastMasterChangeTime = k.clock().Now()
 		}
 
 		// cancel the watcher by revoking its context
@@ -832,6 +1230,9 @@ func (k *Kuberhealthy) masterStatusWatcher(ctx context.Context) {
 	}
 }
 
+// masterSettleInterval is how long master change events must stop for before the master state is changed
+const masterSettleInterval = time.Second * 10
+
 // masterMonitor periodically evaluates the current and upcoming master state
 // and makes it so when appropriate
 func (k *Kuberhealthy) masterMonitor(ctx context.Context, becameMasterChan chan struct{}, lostMasterChan chan struct{}) {
@@ -839,16 +1240,25 @@ func (k *Kuberhealthy) masterMonitor(ctx context.Context, becameMasterChan chan
 	// watch master pod event changes and recalculate the current master state of this pdo with each
 	go k.masterStatusWatcher(ctx)
 
-	interval := time.Second * 10
+	k.settleMasterChanges(ctx, masterSettleInterval, becameMasterChan, lostMasterChan)
+}
 
-	ticker := time.NewTicker(interval)
+// settleMasterChanges makes the upcoming master state the current one once no master change event has been seen
+// for an interval, until the context is done
+func (k *Kuberhealthy) settleMasterChanges(ctx context.Context, interval time.Duration, becameMasterChan chan struct{}, lostMasterChan chan struct{}) {
+	ticker := k.clock().NewTicker(interval)
 	defer ticker.Stop()
 
 	// on each tick, we ensure that enough time has passed since the last master change
 	// event, then we calculate if we should become or lose master.
-	for range ticker.C {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
 
-		if time.Now().Sub(lastMasterChangeTime) < interval {
+		if k.clock().Since(lastMasterChangeTime) < interval {
 			log.Println("control: waiting for master changes to settle...")
 			continue
 		}
@@ -891,7 +1301,7 @@ func (k *Kuberhealthy) runJob(ctx context.Context, job khjobv1.KuberhealthyJob)
 	// Run the job
 	log.Infoln("Running job:", j.Name())
 	// Record job run start time
-	jobStartTime := time.Now()
+	jobStartTime := k.clock().Now()
 	// set KHJob phase to running
 	err := setJobPhase(job.Name, job.Namespace, khjobv1.JobRunning)
 	if err != nil {
@@ -918,7 +1328,7 @@ func (k *Kuberhealthy) runJob(ctx context.Context, job khjobv1.KuberhealthyJob)
 	// Subtract 10 seconds from run time since there are two 5 second sleeps during the job run where kuberhealthy
 	// waits for all pods to clear before running the check and waits for all pods to exit once the check has finished
 	// running. Both occur before and after the kh job pod completes its run.
-	jobRunDuration := time.Now().Sub(jobStartTime) - time.Second*10
+	jobRunDuration := k.clock().Since(jobStartTime) - time.Second*10
 
 	// make a new state for this job and fill it from the job's current status
 	jobDetails, err := getJobState(j)
@@ -928,8 +1338,17 @@ func (k *Kuberhealthy) runJob(ctx context.Context, job khjobv1.KuberhealthyJob)
 	details := khstatev1.NewWorkloadDetails(khstatev1.KHJob)
 	details.Namespace = j.CheckNamespace()
 	details.OK, details.Errors = j.CurrentStatus()
+	details.Reason = carriedFailureReason(details.OK, jobDetails.Reason)
 	details.RunDuration = jobRunDuration.String()
 	details.CurrentUUID = jobDetails.CurrentUUID
+	details.History = jobDetails.History
+	details.Metadata = jobDetails.Metadata
+	details.ErrorDetails = jobDetails.ErrorDetails
+	details.Artifacts = jobDetails.Artifacts
+	details.Metrics = jobDetails.Metrics
+	details.Warnings = jobDetails.Warnings
+	details.SubChecks = jobDetails.SubChecks
+	k.recordRunHistory(&details)
 
 	// Fetch node information from running check pod using kh run uuid
 	selector := "kuberhealthy-run-id=" + details.CurrentUUID
@@ -972,9 +1391,12 @@ func (k *Kuberhealthy) runJob(ctx context.Context, job khjobv1.KuberhealthyJob)
 	log.Infoln("Setting state of job", j.Name(), "in namespace", j.CheckNamespace(), "to", details.OK, details.Errors, details.RunDuration, details.CurrentUUID, details.GetKHWorkload())
 
 	// store the job state with the CRD
+	k.seedEventState(j.Name(), j.CheckNamespace(), khstatev1.KHJob)
 	err = k.storeCheckState(j.Name(), j.CheckNamespace(), details)
 	if err != nil {
 		log.Errorln("Error storing CRD state for job:", j.Name(), "in namespace", j.CheckNamespace(), err)
+	} else {
+		k.publishRunCompleted(j.Name(), j.CheckNamespace(), details)
 	}
 
 	// set KHJob phase to running:
@@ -984,120 +1406,258 @@ func (k *Kuberhealthy) runJob(ctx context.Context, job khjobv1.KuberhealthyJob)
 	}
 }
 
-// runCheck runs a check on an interval and sets its status each run
+// runCheck runs a check on an interval and sets its status each run.  A run that is still going when the next run is
+// due is handled according to the concurrency policy of the check.
 func (k *Kuberhealthy) runCheck(ctx context.Context, c *external.Checker) {
 
 	log.Println("Starting check:", c.CheckNamespace(), "/", c.Name())
 
 	// run on an interval specified by the package
-	ticker := time.NewTicker(c.Interval())
+	ticker := k.clock().NewTicker(c.Interval())
+	defer ticker.Stop()
 
-	// run the check forever and write its results to the kuberhealthy
-	// CRD resource for the check
-	for {
+	// runs report back on done with how long the check should back off for before its next run
+	type checkRun struct {
+		checker *external.Checker
+		backoff time.Duration
+	}
+	done := make(chan checkRun)
+	var inFlight []*external.Checker
+	start := func(checker *external.Checker) {
+		inFlight = append(inFlight, checker)
+		go func() {
+			r := checkRun{checker: checker, backoff: k.runCheckOnce(ctx, checker)}
+			select {
+			case done <- r:
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	// checks whose pods keep failing to start are backed off instead of creating a doomed pod every interval
+	var backoffDone <-chan time.Time
 
-		// break out if context cancels
+	start(c)
+	k.schedule.planned(c, k.clock().Now().Add(c.Interval()))
+	defer k.schedule.forget(c)
+	for {
 		select {
 		case <-ctx.Done():
 			// we don't need to call a check shutdown here because the same func that cancels this context calls
-			// shutdown on all the checks configured in the kuberhealthy struct.
+			// shutdown on all the checks configured in the kuberhealthy struct.  Runs in flight stop with the context.
 			log.Infoln("Shutting down check run due to context cancellation:", c.Name(), "in namespace", c.CheckNamespace())
 			return
-		default:
-		}
 
-		// Run the check
-		log.Infoln("Running check:", c.Name())
-		// Record check run start time
-		checkStartTime := time.Now()
-		err := c.Run(ctx, kubernetesClient)
-		if err != nil {
-			log.Errorln("Error running check:", c.Name(), "in namespace", c.CheckNamespace()+":", err)
-			if strings.Contains(err.Error(), "pod deleted expectedly") {
-				log.Infoln("Skipping this run due to expected pod removal before completion")
-				<-ticker.C
+		case r := <-done:
+			inFlight = removeChecker(inFlight, r.checker)
+			if r.backoff > 0 {
+				log.Warningln("Checker pods of check", c.Name(), "in namespace", c.CheckNamespace(), "keep failing to start. Backing off for", r.backoff)
+				backoffDone = k.clock().After(r.backoff)
+				k.schedule.backedOff(c, k.clock().Now().Add(r.backoff))
+				continue
 			}
-			// set any check run errors in the CRD
-			err = k.setCheckExecutionError(c.Name(), c.CheckNamespace(), err)
-			if err != nil {
-				log.Errorln("Error setting check execution error:", err)
+			if len(inFlight) == 0 {
+				log.Infoln("Waiting for next run of check", c.Name(), "in namespace", c.CheckNamespace())
+			}
+
+		case <-backoffDone:
+			backoffDone = nil
+			ticker.Reset(c.Interval())
+			k.schedule.planned(c, k.clock().Now().Add(c.Interval()))
+			if len(inFlight) == 0 {
+				start(c)
+			}
+
+		case <-ticker.C():
+			if backoffDone != nil {
+				continue
+			}
+			k.schedule.planned(c, k.clock().Now().Add(c.Interval()))
+			switch concurrencyAction(c.ConcurrencyPolicy, len(inFlight)) {
+			case startRun:
+				start(c)
+			case skipRun:
+				log.Infoln("Skipping run of check", c.Name(), "in namespace", c.CheckNamespace(), "because its previous run is still going")
+			case overlapRun:
+				log.Infoln("Starting run of check", c.Name(), "in namespace", c.CheckNamespace(), "alongside", len(inFlight), "run(s) still going")
+				start(c.Copy())
+			case replaceRun:
+				log.Infoln("Replacing the run of check", c.Name(), "in namespace", c.CheckNamespace(), "that is still going")
+				for _, checker := range inFlight {
+					checker.CancelRun("replaced by a newer run")
+				}
+				for len(inFlight) > 0 {
+					select {
+					case r := <-done:
+						inFlight = removeChecker(inFlight, r.checker)
+					case <-ctx.Done():
+						return
+					}
+				}
+				start(c)
 			}
-			<-ticker.C
-			continue
 		}
-		log.Debugln("Done running check:", c.Name(), "in namespace", c.CheckNamespace())
+	}
+}
 
-		// Record check run end time
-		// Subtract 10 seconds from run time since there are two 5 second sleeps during the check run where kuberhealthy
-		// waits for all pods to clear before running the check and waits for all pods to exit once the check has finished
-		// running. Both occur before and after the checker pod completes its run.
-		checkRunDuration := time.Now().Sub(checkStartTime) - time.Second*10
+// runCheckOnce runs a check one time and stores its result.  Returns how long the check should back off for before
+// its next run, or zero when it should keep its interval.
+func (k *Kuberhealthy) runCheckOnce(ctx context.Context, c *external.Checker) time.Duration {
 
-		// make a new state for this check and fill it from the check's current status
-		checkDetails, err := getCheckState(c)
+	// Run the check
+	log.Infoln("Running check:", c.Name())
+	// Record check run start time
+	checkStartTime := k.clock().Now()
+	err := c.Run(ctx, kubernetesClient)
+	if err != nil {
+		log.Errorln("Error running check:", c.Name(), "in namespace", c.CheckNamespace()+":", err)
+		if errors.Is(err, external.ErrRunCanceled) {
+			log.Infoln("Run of check", c.Name(), "in namespace", c.CheckNamespace(), "was canceled. Skipping its result")
+			k.recordCanceledRun(c.Name(), c.CheckNamespace(), c.CurrentUUID(), err)
+			return 0
+		}
+		if strings.Contains(err.Error(), "pod deleted expectedly") {
+			log.Infoln("Skipping this run due to expected pod removal before completion")
+		}
+		// set any check run errors in the CRD
+		runErr := err
+		backoff, err := k.setCheckExecutionError(c.Name(), c.CheckNamespace(), runErr, c.Interval())
 		if err != nil {
-			log.Errorln("Error setting check state after run:", c.Name(), "in namespace", c.CheckNamespace()+":", err)
+			log.Errorln("Error setting check execution error:", err)
+		}
+		var fanOutErr *external.FanOutError
+		if errors.As(runErr, &fanOutErr) && fanOutErr.Passed {
+			k.remediate(ctx, c.Name(), c.CheckNamespace(), true, c.CurrentUUID(), []string{})
+			k.exportCheckResult(ctx, c.Name(), c.CheckNamespace(), true)
+			k.checkImageRollback(c.Name(), c.CheckNamespace())
+			return backoff
 		}
-		details := khstatev1.NewWorkloadDetails(khstatev1.KHCheck)
-		details.Namespace = c.CheckNamespace()
-		details.OK, details.Errors = c.CurrentStatus()
-		details.RunDuration = checkRunDuration.String()
-		details.CurrentUUID = checkDetails.CurrentUUID
+		k.remediate(ctx, c.Name(), c.CheckNamespace(), false, c.CurrentUUID(), []string{"Check execution error: " + runErr.Error()})
+		k.exportCheckResult(ctx, c.Name(), c.CheckNamespace(), false)
+		k.checkImageRollback(c.Name(), c.CheckNamespace())
 
-		// Fetch node information from running check pod using kh run uuid
+		return backoff
+	}
+	log.Debugln("Done running check:", c.Name(), "in namespace", c.CheckNamespace())
+
+	// Record check run end time
+	// Subtract 10 seconds from run time since there are two 5 second sleeps during the check run where kuberhealthy
+	// waits for all pods to clear before running the check and waits for all pods to exit once the check has finished
+	// running. Both occur before and after the checker pod completes its run.
+	checkRunDuration := k.clock().Since(checkStartTime) - time.Second*10
+
+	// make a new state for this check and fill it from the check's current status
+	checkDetails, err := getCheckState(c)
+	if err != nil {
+		log.Errorln("Error setting check state after run:", c.Name(), "in namespace", c.CheckNamespace()+":", err)
+	}
+
+	// a run overtaken by a newer overlapping run had its report recorded in the run history. The state belongs to
+	// the newer run.
+	if c.Overlaps() && len(checkDetails.CurrentUUID) > 0 && checkDetails.CurrentUUID != c.CurrentUUID() {
+		log.Infoln("Run", c.CurrentUUID(), "of check", c.Name(), "in namespace", c.CheckNamespace(), "was overtaken by run", checkDetails.CurrentUUID+". Leaving the state to the newer run")
+		return 0
+	}
+	details := khstatev1.NewWorkloadDetails(khstatev1.KHCheck)
+	details.Namespace = c.CheckNamespace()
+	details.OK, details.Errors = c.CurrentStatus()
+	details.Reason = carriedFailureReason(details.OK, checkDetails.Reason)
+	details.RunDuration = checkRunDuration.String()
+	details.CurrentUUID = checkDetails.CurrentUUID
+	details.History = checkDetails.History
+	details.NodeStatuses = checkDetails.NodeStatuses
+	details.ZoneStatuses = checkDetails.ZoneStatuses
+	details.Metadata = checkDetails.Metadata
+	details.ErrorDetails = checkDetails.ErrorDetails
+	details.Artifacts = checkDetails.Artifacts
+	details.Metrics = checkDetails.Metrics
+	details.Warnings = checkDetails.Warnings
+	details.SubChecks = checkDetails.SubChecks
+	k.recordRunHistory(&details)
+
+	// Fetch node information from running check pod using kh run uuid.  Fanned out runs have a pod on many nodes.
+	if !c.RunOnAllNodes && !c.RunPerZone {
 		selector := "kuberhealthy-run-id=" + details.CurrentUUID
 		pod, err := k.fetchPodBySelector(ctx, selector)
 		if err != nil {
 			log.Errorln(err)
 		}
 		details.Node = pod.Spec.NodeName
+	}
 
-		log.Debugln("node name:", details.Node, "nodeName", c.Node)
-
-		// send data to the metric forwarder if configured
-		if k.MetricForwarder != nil {
-			checkStatus := 0
-			if details.OK {
-				checkStatus = 1
-			}
-
-			runDuration, err := time.ParseDuration(details.RunDuration)
-			if err != nil {
-				log.Errorln("Error parsing run duration", err)
-			}
+	log.Debugln("node name:", details.Node, "nodeName", c.Node)
 
-			tags := map[string]string{
-				"KuberhealthyPod": details.AuthoritativePod,
-				"Namespace":       c.CheckNamespace(),
-				"Name":            c.Name(),
-				"Errors":          strings.Join(details.Errors, ","),
-			}
-			metric := metrics.Metric{
-				{c.Name() + "." + c.CheckNamespace(): checkStatus},
-				{"RunDuration." + c.Name() + "." + c.CheckNamespace(): runDuration.Seconds()},
-			}
-			err = k.MetricForwarder.Push(metric, tags)
-			if err != nil {
-				log.Errorln("Error forwarding metrics", err)
-			}
+	// send data to the metric forwarder if configured
+	if k.MetricForwarder != nil {
+		checkStatus := 0
+		if details.OK {
+			checkStatus = 1
 		}
 
-		log.Infoln("Setting state of check", c.Name(), "in namespace", c.CheckNamespace(), "to", details.OK, details.Errors, details.RunDuration, details.CurrentUUID, details.GetKHWorkload())
+		runDuration, err := time.ParseDuration(details.RunDuration)
+		if err != nil {
+			log.Errorln("Error parsing run duration", err)
+		}
 
-		// store the check state with the CRD
-		err = k.storeCheckState(c.Name(), c.CheckNamespace(), details)
+		tags := map[string]string{
+			"KuberhealthyPod": details.AuthoritativePod,
+			"Namespace":       c.CheckNamespace(),
+			"Name":            c.Name(),
+			"Errors":          strings.Join(details.Errors, ","),
+		}
+		metric := metrics.Metric{
+			{c.Name() + "." + c.CheckNamespace(): checkStatus},
+			{"RunDuration." + c.Name() + "." + c.CheckNamespace(): runDuration.Seconds()},
+		}
+		err = k.MetricForwarder.Push(metric, tags)
 		if err != nil {
-			log.Errorln("Error storing CRD state for check:", c.Name(), "in namespace", c.CheckNamespace(), err)
+			log.Errorln("Error forwarding metrics", err)
 		}
+	}
 
-		log.Infoln("Waiting for next run of check", c.Name(), "in namespace", c.CheckNamespace())
-		<-ticker.C // wait for next run
+	log.Infoln("Setting state of check", c.Name(), "in namespace", c.CheckNamespace(), "to", details.OK, details.Errors, details.RunDuration, details.CurrentUUID, details.GetKHWorkload())
+
+	// store the check state with the CRD
+	k.seedEventState(c.Name(), c.CheckNamespace(), khstatev1.KHCheck)
+	err = k.storeCheckState(c.Name(), c.CheckNamespace(), details)
+	if err != nil {
+		log.Errorln("Error storing CRD state for check:", c.Name(), "in namespace", c.CheckNamespace(), err)
+	} else {
+		k.publishRunCompleted(c.Name(), c.CheckNamespace(), details)
 	}
+
+	// run any remediations configured for the check
+	k.remediate(ctx, c.Name(), c.CheckNamespace(), details.OK, details.CurrentUUID, details.Errors)
+
+	// annotate the result onto the workloads the check probes
+	k.exportCheckResult(ctx, c.Name(), c.CheckNamespace(), details.OK)
+
+	// roll back an automatic image update that broke the check
+	k.checkImageRollback(c.Name(), c.CheckNamespace())
+	return 0
 }
 
-// storeCheckState stores the check state in its cluster CRD
+// storeCheckState stores the check state in its cluster CRD.  When the Kubernetes API is unavailable, the state is
+// queued and written once the API returns.
 func (k *Kuberhealthy) storeCheckState(checkName string, checkNamespace string, details khstatev1.WorkloadDetails) error {
+	details = k.withCorrelations(details)
+	details = k.withLabels(checkName, checkNamespace, details)
+
+	err := writeCheckState(checkName, checkNamespace, details)
+	if err != nil {
+		if k.queueIfDegraded(checkName, checkNamespace, details, err) {
+			return nil
+		}
+		return err
+	}
 
+	k.degraded.drop(checkName, checkNamespace)
+	k.storePluginState(checkName, checkNamespace, details)
+	return nil
+}
+
+// writeCheckState writes the check state to its khstate resource, creating it if needed
+var writeCheckState = func(checkName string, checkNamespace string, details khstatev1.WorkloadDetails) error {
 	// ensure the CRD resource exits
 	err := ensureStateResourceExists(checkName, checkNamespace, details.GetKHWorkload())
 	if err != nil {
@@ -1142,33 +1702,184 @@ func (k *Kuberhealthy) storeCheckState(checkName string, checkNamespace string,
 // StartWebServer starts a JSON status web server at the specified listener.
 func (k *Kuberhealthy) StartWebServer() {
 	log.Infoln("Configuring web server")
-	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+	if faultInjectionEnabled() {
+		log.Warningln("Fault injection is enabled. Check reports will be delayed, dropped and failed on purpose")
+	}
+	http.HandleFunc("/metrics", compressHandler(func(w http.ResponseWriter, r *http.Request) {
 		err := k.prometheusMetricsHandler(w, r)
 		if err != nil {
 			log.Errorln(err)
 		}
+	}))
+
+	// Serve a grafana dashboard generated for the configured checks
+	http.HandleFunc("/grafana/dashboard.json", compressHandler(func(w http.ResponseWriter, r *http.Request) {
+		err := k.grafanaDashboardHandler(w, r)
+		if err != nil {
+			log.Errorln("grafana dashboard endpoint error:", err)
+		}
+	}))
+
+	// Serve only the checks that changed since the cursor supplied by the client
+	http.HandleFunc(statusDeltaPath, compressHandler(func(w http.ResponseWriter, r *http.Request) {
+		err := k.statusDeltaHandler(w, r)
+		if err != nil {
+			log.Errorln("status delta endpoint error:", err)
+		}
+	}))
+
+	// Serve the full state of a single check or job, including the artifacts of its last failure
+	http.HandleFunc(checkDetailPath, compressHandler(func(w http.ResponseWriter, r *http.Request) {
+		err := k.checkDetailHandler(w, r)
+		if err != nil {
+			log.Errorln("check detail endpoint error:", err)
+		}
+	}))
+
+	// Validate and apply batches of khchecks for configuration pipelines
+	http.HandleFunc(batchApplyPath, func(w http.ResponseWriter, r *http.Request) {
+		err := k.batchApplyHandler(w, r)
+		if err != nil {
+			log.Errorln("batch apply endpoint error:", err)
+		}
+	})
+
+	// Serve which replica is the master, when each check runs next and the runs in flight
+	http.HandleFunc(schedulerPath, func(w http.ResponseWriter, r *http.Request) {
+		err := k.schedulerHandler(w, r)
+		if err != nil {
+			log.Errorln("scheduler endpoint error:", err)
+		}
+	})
+
+	// Serve the report of which namespaces and workloads are covered by khchecks
+	http.HandleFunc("/coverage", compressHandler(func(w http.ResponseWriter, r *http.Request) {
+		err := k.coverageHandler(w, r)
+		if err != nil {
+			log.Errorln("coverage endpoint error:", err)
+		}
+	}))
+
+	// Serve the report of which check images have newer versions available
+	http.HandleFunc("/imageversions", compressHandler(func(w http.ResponseWriter, r *http.Request) {
+		err := k.imageVersionsHandler(w, r)
+		if err != nil {
+			log.Errorln("image versions endpoint error:", err)
+		}
+	}))
+
+	// Report if this replica has filled its caches and is ready to serve status requests
+	http.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		err := k.readyHandler(w, r)
+		if err != nil {
+			log.Errorln("readiness endpoint error:", err)
+		}
 	})
 
 	// Accept status reports coming from external checker pods
-	http.HandleFunc("/externalCheckStatus", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/externalCheckStatus", k.injectReportFaults(decompressHandler(func(w http.ResponseWriter, r *http.Request) {
 		err := k.externalCheckReportHandler(w, r)
 		if err != nil {
 			log.Errorln("externalCheckStatus endpoint error:", err)
 		}
+	})))
+
+	// Accept batches of reports from agent style checkers that evaluate many checks per cycle
+	http.HandleFunc("/bulkCheckStatus", k.injectReportFaults(decompressHandler(func(w http.ResponseWriter, r *http.Request) {
+		err := k.bulkCheckReportHandler(w, r)
+		if err != nil {
+			log.Errorln("bulkCheckStatus endpoint error:", err)
+		}
+	})))
+
+	// Let long lived resident checkers register for resident checks and take their runs
+	http.HandleFunc("/resident/register", func(w http.ResponseWriter, r *http.Request) {
+		err := k.residentRegisterHandler(w, r)
+		if err != nil {
+			log.Errorln("resident register endpoint error:", err)
+		}
+	})
+	http.HandleFunc("/resident/runs", func(w http.ResponseWriter, r *http.Request) {
+		err := k.residentRunsHandler(w, r)
+		if err != nil {
+			log.Errorln("resident runs endpoint error:", err)
+		}
+	})
+
+	// Let running checker pods push back the deadline of their run
+	http.HandleFunc("/extendDeadline", func(w http.ResponseWriter, r *http.Request) {
+		err := k.extendDeadlineHandler(w, r)
+		if err != nil {
+			log.Errorln("extendDeadline endpoint error:", err)
+		}
+	})
+
+	// Let running checker pods show that they are still making progress
+	http.HandleFunc("/heartbeat", func(w http.ResponseWriter, r *http.Request) {
+		err := k.heartbeatHandler(w, r)
+		if err != nil {
+			log.Errorln("heartbeat endpoint error:", err)
+		}
+	})
+
+	// Let running checker pods report how far along their run is
+	http.HandleFunc("/reportProgress", func(w http.ResponseWriter, r *http.Request) {
+		err := k.reportProgressHandler(w, r)
+		if err != nil {
+			log.Errorln("reportProgress endpoint error:", err)
+		}
+	})
+
+	// Accept the call back from the pod spawned by the reporting self-check
+	http.HandleFunc("/selfCheck/", func(w http.ResponseWriter, r *http.Request) {
+		err := k.selfCheckHandler(w, r)
+		if err != nil {
+			log.Errorln("selfCheck endpoint error:", err)
+		}
+	})
+
+	// Let running checker pods learn that Kuberhealthy no longer wants the result of their run
+	http.HandleFunc("/cancel/", func(w http.ResponseWriter, r *http.Request) {
+		err := k.cancelHandler(w, r)
+		if err != nil {
+			log.Errorln("cancel endpoint error:", err)
+		}
+	})
+
+	// Serve what was recorded for individual check runs so that checks can verify that their report was delivered
+	http.HandleFunc("/externalCheckStatus/", func(w http.ResponseWriter, r *http.Request) {
+		err := k.externalCheckRunStatusHandler(w, r)
+		if err != nil {
+			log.Errorln("externalCheckStatus run status endpoint error:", err)
+		}
+	})
+
+	// Receive the synthetic alerts of alerting pipeline checks from Alertmanager and let the checks confirm delivery
+	http.HandleFunc("/alertReceiver", func(w http.ResponseWriter, r *http.Request) {
+		err := k.alertReceiverHandler(w, r)
+		if err != nil {
+			log.Errorln("alertReceiver endpoint error:", err)
+		}
+	})
+	http.HandleFunc("/alertReceiver/", func(w http.ResponseWriter, r *http.Request) {
+		err := k.alertDeliveryHandler(w, r)
+		if err != nil {
+			log.Errorln("alertReceiver delivery endpoint error:", err)
+		}
 	})
 
 	// Assign all requests to be handled by the healthCheckHandler function
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/", compressHandler(func(w http.ResponseWriter, r *http.Request) {
 		err := k.healthCheckHandler(w, r)
 		if err != nil {
 			log.Errorln(err)
 		}
-	})
+	}))
 
 	// start web server any time it exits
 	for {
 		log.Infoln("Starting web services on port", k.ListenAddr)
-		err := http.ListenAndServe(k.ListenAddr, nil)
+		err := http.ListenAndServe(k.ListenAddr, k.instrumentRequests(http.DefaultServeMux))
 		if err != nil {
 			log.Errorln("Web server ERROR:", err)
 		}
@@ -1176,11 +1887,51 @@ func (k *Kuberhealthy) StartWebServer() {
 	}
 }
 
+// errLateReport indicates that a report came from a run that Kuberhealthy has already given up waiting on
+var errLateReport = errors.New("report received after the run was timed out")
+
+// errOvertakenReport indicates that a report came on time from a run that a newer overlapping run has overtaken
+var errOvertakenReport = errors.New("report received from a run overtaken by a newer run")
+
+// maxRequestIDLength is the longest request ID accepted from clients
+const maxRequestIDLength = 128
+
+// getRequestID returns the request ID supplied by the client in the request ID header.  If the client did not
+// supply one, or supplied one that is too long or contains unexpected characters, a new request ID is made.
+func getRequestID(r *http.Request) string {
+	requestID := r.Header.Get(external.KHRequestIDHeader)
+	if len(requestID) == 0 || len(requestID) > maxRequestIDLength {
+		return uuid.New().String()
+	}
+	for _, c := range requestID {
+		isAlphanumeric := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlphanumeric && !strings.ContainsRune("-_.:", c) {
+			return uuid.New().String()
+		}
+	}
+	return requestID
+}
+
+// recordRunHistory appends the outcome of a completed run to the run history in the supplied details.  If the
+// run reported in, the ID of the request that delivered its report is recorded as well.
+func (k *Kuberhealthy) recordRunHistory(details *khstatev1.WorkloadDetails) {
+	entry := khstatev1.NewRunHistoryEntry(details.CurrentUUID, details.OK, details.Errors, false)
+	entry.Reason = details.Reason
+	run, known := k.runTracker.Get(details.CurrentUUID)
+	if known && run.State == external.RunReported {
+		entry.RequestID = run.RequestID
+		details.ReportRequestID = run.RequestID
+	}
+	details.AppendHistory(entry, maxRunHistory())
+}
+
 // PodReportInfo holds info about an incoming IP to the external check reporting endpoint
 type PodReportInfo struct {
 	Name      string
 	UUID      string
 	Namespace string
+	Node      string // the node the calling pod runs on
+	Zone      string // the zone the calling pod was spawned for, if it belongs to a run per zone
 }
 
 // validateExternalRequest calls the Kubernetes API to fetch details about a pod using a selector string.
@@ -1251,6 +2002,8 @@ func (k *Kuberhealthy) validateExternalRequest(ctx context.Context, selector str
 	reportInfo.Name = podCheckName
 	reportInfo.Namespace = podCheckNamespace
 	reportInfo.UUID = podUUID
+	reportInfo.Node = pod.Spec.NodeName
+	reportInfo.Zone = pod.Annotations[external.ZoneAnnotation]
 
 	// next, we check the uuid against the check name to see if this uuid is the expected one.  if it isn't,
 	// we return an error
@@ -1259,12 +2012,27 @@ func (k *Kuberhealthy) validateExternalRequest(ctx context.Context, selector str
 		return reportInfo, fmt.Errorf("failed to fetch whitelisted UUID for check with error: %w", err)
 	}
 	if !whitelisted {
-		return reportInfo, errors.New("pod was not properly whitelisted for reporting status of check " + podCheckName + " with uuid " + podUUID + " and namespace " + podCheckNamespace)
+		return reportInfo, k.unlistedRunError(podCheckName, podCheckNamespace, podUUID)
 	}
 
 	return reportInfo, nil
 }
 
+// unlistedRunError explains why a run that its check no longer expects is reporting.  A run we handed out that has
+// since been replaced is reporting late, or was overtaken by a newer run when its check lets runs overlap, rather than
+// being invalid.
+func (k *Kuberhealthy) unlistedRunError(checkName string, checkNamespace string, uuid string) error {
+	run, known := k.runTracker.Get(uuid)
+	if known && run.CheckName == checkName && run.Namespace == checkNamespace {
+		// runs only go on past the start of a newer run when the check allows them to overlap
+		if run.State == external.RunRunning && !run.Ended && !run.IsLate(k.clock().Now()) {
+			return errOvertakenReport
+		}
+		return errLateReport
+	}
+	return errors.New("pod was not properly whitelisted for reporting status of check " + checkName + " with uuid " + uuid + " and namespace " + checkNamespace)
+}
+
 // fetchPodBySelectorForDuration attempts to fetch a pod by a specified selector repeatedly for the supplied duration.
 // If the pod is found, then we return it.  If the pod is not found after the duration, we return an error
 func (k *Kuberhealthy) fetchPodBySelectorForDuration(ctx context.Context, selector string, d time.Duration) (v1.Pod, error) {
@@ -1286,6 +2054,9 @@ func (k *Kuberhealthy) fetchPodBySelectorForDuration(ctx context.Context, select
 	}
 }
 
+// podIPSelectorPrefix is the start of field selectors that look up pods by their IP
+const podIPSelectorPrefix = "status.podIP=="
+
 // fetchPodBySelector fetches the pod by it's `kuberhealthy-run-id` label selector or by its `status.podIP` field selector
 func (k *Kuberhealthy) fetchPodBySelector(ctx context.Context, selector string) (v1.Pod, error) {
 	var pod v1.Pod
@@ -1313,6 +2084,24 @@ func (k *Kuberhealthy) fetchPodBySelector(ctx context.Context, selector string)
 		return pod, errors.New("failed to fetch pod with selector " + selector + " with error: " + err.Error())
 	}
 
+	// the podIP field only holds the primary IP of dual-stack pods.  If the pod called in from its other IP, we
+	// look through all the IPs of running checker pods instead.
+	if len(podList.Items) == 0 && strings.HasPrefix(selector, podIPSelectorPrefix) {
+		ip := strings.Split(strings.TrimPrefix(selector, podIPSelectorPrefix), ",")[0]
+		checkerPods, err := podClient.List(ctx, metav1.ListOptions{
+			LabelSelector: "kuberhealthy-run-id",
+			FieldSelector: "status.phase==Running",
+		})
+		if err != nil {
+			return pod, errors.New("failed to fetch checker pods to find pod with ip " + ip + " with error: " + err.Error())
+		}
+		for _, p := range checkerPods.Items {
+			if podHasIP(p, ip) {
+				podList.Items = append(podList.Items, p)
+			}
+		}
+	}
+
 	// ensure that we only got back one pod, because two means something awful has happened and 0 means we
 	// didnt find one
 	if len(podList.Items) == 0 {
@@ -1343,6 +2132,18 @@ func (k *Kuberhealthy) validateUsingRequestHeader(ctx context.Context, r *http.R
 	if len(r.Header.Get("kh-run-uuid")) == 0 {
 		return podReport, false, nil
 	}
+
+	// resident checkers are long lived, so they don't carry the run UUID they report for
+	if run, known := k.runTracker.Get(r.Header.Get("kh-run-uuid")); known && k.residents.IsResidentCheck(run.CheckName, run.Namespace) {
+		podReport, err = k.validateResidentRequest(r, run)
+		return podReport, err == nil, err
+	}
+
+	// the checker pods of a fanned out run share its run UUID, so they are told apart by their IP instead
+	if run, known := k.runTracker.Get(r.Header.Get("kh-run-uuid")); known && len(run.FanOut) > 0 {
+		podReport, err = k.validatePodReportBySourceIP(ctx, r)
+		return podReport, err == nil, err
+	}
 	selector := "kuberhealthy-run-id=" + r.Header.Get("kh-run-uuid")
 	podReport, err = k.validateExternalRequest(ctx, selector)
 	if err != nil {
@@ -1356,11 +2157,11 @@ func (k *Kuberhealthy) validateUsingRequestHeader(ctx context.Context, r *http.R
 func (k *Kuberhealthy) validatePodReportBySourceIP(ctx context.Context, r *http.Request) (PodReportInfo, error) {
 
 	var podReport PodReportInfo
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	ip, err := remoteIP(r.RemoteAddr)
 	if err != nil {
 		return podReport, err
 	}
-	selector := "status.podIP==" + ip + ",status.phase==Running"
+	selector := podIPSelectorPrefix + ip + ",status.phase==Running"
 	podReport, err = k.validateExternalRequest(ctx, selector)
 	if err != nil {
 		return podReport, err
@@ -1368,6 +2169,33 @@ func (k *Kuberhealthy) validatePodReportBySourceIP(ctx context.Context, r *http.
 	return podReport, nil
 }
 
+// validateReportCaller validates that a report comes from a checker pod.  The pod is looked up by the kh-run-uuid header
+// of the request, or by its remote IP if the header is missing or does not validate.  Reports from runs that timed out
+// or were overtaken by a newer run are flagged rather than refused, so that they can be kept in the run history.
+func (k *Kuberhealthy) validateReportCaller(ctx context.Context, requestID string, r *http.Request) (PodReportInfo, bool, bool, error) {
+	k.externalCheckReportHandlerLog(requestID, "validating external check status report from its reporting kuberhealthy run uuid:", r.Header.Get("kh-run-uuid"))
+	podReport, reportValidated, err := k.validateUsingRequestHeader(ctx, r)
+	if err != nil {
+		k.externalCheckReportHandlerLog(requestID, "Failed to look up pod by its kh-run-uuid header:", r.Header.Get("kh-run-uuid"), err)
+	}
+	lateReport := errors.Is(err, errLateReport)
+	overtakenReport := errors.Is(err, errOvertakenReport)
+	if reportValidated || lateReport || overtakenReport {
+		return podReport, lateReport, overtakenReport, nil
+	}
+
+	// If the check uuid header is missing, attempt to validate using calling pod's source IP
+	k.externalCheckReportHandlerLog(requestID, "validating external check status report from the pod's remote IP:", r.RemoteAddr)
+	podReport, err = k.validatePodReportBySourceIP(ctx, r)
+	lateReport = errors.Is(err, errLateReport)
+	overtakenReport = errors.Is(err, errOvertakenReport)
+	if err != nil && !lateReport && !overtakenReport {
+		k.externalCheckReportHandlerLog(requestID, "Failed to look up pod by its IP:", r.RemoteAddr, err)
+		return podReport, false, false, err
+	}
+	return podReport, lateReport, overtakenReport, nil
+}
+
 // externalCheckReportHandler handles requests coming from external checkers reporting their status.
 // This endpoint checks that the external check report is coming from the correct UUID or pod IP before recording
 // the reported status of the corresponding external check.  This endpoint expects a JSON payload of
@@ -1375,32 +2203,40 @@ func (k *Kuberhealthy) validatePodReportBySourceIP(ctx context.Context, r *http.
 // causes a check of the calling pod's spec via the API to ensure that the calling pod is expected
 // to be reporting its status.
 func (k *Kuberhealthy) externalCheckReportHandler(w http.ResponseWriter, r *http.Request) error {
-	// make a request ID for tracking this request
-	requestID := "web: " + uuid.New().String()
+	// use the request ID supplied by the client or make one for tracking this request.  It is returned to the client
+	// so that a report can be traced through the logs and khstate resources.
+	reportRequestID := getRequestID(r)
+	w.Header().Set(external.KHRequestIDHeader, reportRequestID)
+	requestID := "web: " + reportRequestID
+
+	// tell the client which report versions it can send, so that it can tell an old Kuberhealthy from a new one
+	w.Header().Set(external.KHReportVersionsHeader, status.FormatReportVersions(status.SupportedReportVersions))
 
 	ctx := r.Context()
 
 	k.externalCheckReportHandlerLog(requestID, "Client connected to check report handler from", r.UserAgent())
 
-	// Validate request using the kh-run-uuid header. If the header doesn't exist, or there's an error with validation,
-	// validate using the pod's remote IP.
-	k.externalCheckReportHandlerLog(requestID, "validating external check status report from its reporting kuberhealthy run uuid:", r.Header.Get("kh-run-uuid"))
-	podReport, reportValidated, err := k.validateUsingRequestHeader(ctx, r)
-	if err != nil {
-		k.externalCheckReportHandlerLog(requestID, "Failed to look up pod by its kh-run-uuid header:", r.Header.Get("kh-run-uuid"), err)
+	// answer the reachability probes of checkclient.Preflight without validating the caller
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return nil
 	}
 
-	// If the check uuid header is missing, attempt to validate using calling pod's source IP
-	if !reportValidated {
-		k.externalCheckReportHandlerLog(requestID, "validating external check status report from the pod's remote IP:", r.RemoteAddr)
-		podReport, err = k.validatePodReportBySourceIP(ctx, r)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			k.externalCheckReportHandlerLog(requestID, "Failed to look up pod by its IP:", r.RemoteAddr, err)
-			return nil
-		}
+	// ask the client to back off when too many reports are already being handled
+	if !k.acquireReportSlot() {
+		k.externalCheckReportHandlerLog(requestID, "Too many reports are being handled. Asking client to retry in", defaultReportRetryAfter)
+		writeRetryAfter(w, defaultReportRetryAfter)
+		return nil
+	}
+	defer k.releaseReportSlot()
+
+	podReport, lateReport, overtakenReport, err := k.validateReportCaller(ctx, requestID, r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
 	}
 	k.externalCheckReportHandlerLog(requestID, "Calling pod is", podReport.Name, "in namespace", podReport.Namespace)
+	k.logReportTrace(requestID, podReport.UUID, r)
 
 	// append pod info to request id for easy check tracing in logs
 	requestID = requestID + " (" + podReport.Namespace + "/" + podReport.Name + ")"
@@ -1414,6 +2250,14 @@ func (k *Kuberhealthy) externalCheckReportHandler(w http.ResponseWriter, r *http
 	}
 	log.Debugln("Check report body:", string(b))
 
+	// when signed reports are required, the report must be signed with the signing key of the calling pod's run
+	err = verifyReportSignature(r, podReport.UUID, b)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		k.externalCheckReportHandlerLog(requestID, "Refusing report:", err)
+		return nil
+	}
+
 	// decode the bytes into a status struct as used by the client
 	state := status.Report{}
 	err = json.Unmarshal(b, &state)
@@ -1424,21 +2268,136 @@ func (k *Kuberhealthy) externalCheckReportHandler(w http.ResponseWriter, r *http
 	}
 	log.Debugf("Check report after unmarshal: +%v\n", state)
 
+	code, retryAfter, err := k.recordReport(requestID, reportRequestID, podReport, state, lateReport, overtakenReport)
+	if retryAfter > 0 {
+		writeRetryAfter(w, retryAfter)
+		return nil
+	}
+	w.WriteHeader(code)
+	return err
+}
+
+// recordReport stores a validated report from a run.  Reports from runs that timed out or were overtaken by a newer
+// run are only recorded in the run history.  Returns the status code to answer the report with, or how long the
+// client should wait before retrying when the Kubernetes API is throttling khstate writes.
+func (k *Kuberhealthy) recordReport(requestID string, reportRequestID string, podReport PodReportInfo, state status.Report, lateReport bool, overtakenReport bool) (int, time.Duration, error) {
+
+	// refuse reports written for a version of the report schema that may not be read the way the client meant
+	if err := status.ValidateReportVersion(state); err != nil {
+		k.externalCheckReportHandlerLog(requestID, "Client sent a report that can't be read:", err)
+		return http.StatusBadRequest, 0, nil
+	}
+
 	// ensure that if ok is set to false, then an error is provided
 	if !state.OK {
 		if len(state.Errors) == 0 {
-			w.WriteHeader(http.StatusBadRequest)
 			k.externalCheckReportHandlerLog(requestID, "Client attempted to report OK false without any error strings")
-			return nil
+			return http.StatusBadRequest, 0, nil
 		}
 		for _, e := range state.Errors {
 			if len(e) == 0 {
-				w.WriteHeader(http.StatusBadRequest)
 				k.externalCheckReportHandlerLog(requestID, "Client attempted to report a blank error string")
-				return nil
+				return http.StatusBadRequest, 0, nil
 			}
 		}
 	}
+	if err := validateErrorDetails(state); err != nil {
+		k.externalCheckReportHandlerLog(requestID, "Client reported invalid error details:", err)
+		return http.StatusBadRequest, 0, nil
+	}
+	if err := status.ValidateMetrics(state.Metrics); err != nil {
+		k.externalCheckReportHandlerLog(requestID, "Client reported invalid metrics:", err)
+		return http.StatusBadRequest, 0, nil
+	}
+	if err := status.ValidateWarnings(state.Warnings); err != nil {
+		k.externalCheckReportHandlerLog(requestID, "Client reported invalid warnings:", err)
+		return http.StatusBadRequest, 0, nil
+	}
+	if err := validateArtifacts(state); err != nil {
+		k.externalCheckReportHandlerLog(requestID, "Client reported invalid artifacts:", err)
+		return http.StatusBadRequest, 0, nil
+	}
+	if err := validateSubChecks(state); err != nil {
+		k.externalCheckReportHandlerLog(requestID, "Client reported invalid sub-checks:", err)
+		return http.StatusBadRequest, 0, nil
+	}
+
+	// reports for runs that already timed out or were overtaken by a newer run are recorded in the run history, but
+	// do not change the current state
+	if !lateReport {
+		run, known := k.runTracker.Get(podReport.UUID)
+		lateReport = known && run.IsLate(k.clock().Now())
+	}
+	if overtakenReport && !lateReport {
+		if k.runTracker.IsDuplicate(podReport.UUID, state) {
+			k.externalCheckReportHandlerLog(requestID, "Report for uuid", podReport.UUID, "was already recorded.")
+			return http.StatusOK, 0, nil
+		}
+		k.externalCheckReportHandlerLog(requestID, "Report for uuid", podReport.UUID, "came from a run overtaken by a newer run. Recording it in the run history.")
+		entry := khstatev1.NewRunHistoryEntry(podReport.UUID, state.OK, state.Errors, false)
+		entry.RequestID = reportRequestID
+		entry.Reason = reportedFailureReason(state)
+		err := appendRunHistory(podReport.Name, podReport.Namespace, entry)
+		if delay, throttled := retryAfterForError(err); throttled {
+			k.externalCheckReportHandlerLog(requestID, "Kubernetes API is throttling khstate writes. Asking client to retry in", delay)
+			return http.StatusTooManyRequests, delay, nil
+		}
+		if err != nil {
+			k.externalCheckReportHandlerLog(requestID, "failed to record report of overtaken run for", podReport.Name, err)
+			return http.StatusInternalServerError, 0, fmt.Errorf("failed to record report of overtaken run for %s: %w", podReport.Name, err)
+		}
+		k.runTracker.MarkReported(podReport.UUID, state, reportRequestID)
+		return http.StatusOK, 0, nil
+	}
+	if lateReport {
+		if k.runTracker.IsDuplicate(podReport.UUID, state) {
+			k.externalCheckReportHandlerLog(requestID, "Late report for uuid", podReport.UUID, "was already recorded.")
+			return http.StatusGone, 0, nil
+		}
+		k.externalCheckReportHandlerLog(requestID, "Report for uuid", podReport.UUID, "arrived after its run was timed out. Recording it as late.")
+		entry := khstatev1.NewRunHistoryEntry(podReport.UUID, state.OK, state.Errors, true)
+		entry.RequestID = reportRequestID
+		entry.Reason = reportedFailureReason(state)
+		err := appendRunHistory(podReport.Name, podReport.Namespace, entry)
+		if delay, throttled := retryAfterForError(err); throttled {
+			k.externalCheckReportHandlerLog(requestID, "Kubernetes API is throttling khstate writes. Asking client to retry in", delay)
+			return http.StatusTooManyRequests, delay, nil
+		}
+		if err != nil {
+			k.externalCheckReportHandlerLog(requestID, "failed to record late report for", podReport.Name, err)
+			return http.StatusInternalServerError, 0, fmt.Errorf("failed to record late report for %s: %w", podReport.Name, err)
+		}
+		k.runTracker.MarkLate(podReport.UUID, state, reportRequestID)
+		return http.StatusGone, 0, nil
+	}
+
+	// clients that retry after losing our response send the same report again, which has already been stored
+	if k.runTracker.IsDuplicate(podReport.UUID, state) {
+		k.externalCheckReportHandlerLog(requestID, "Report for uuid", podReport.UUID, "was already accepted. Skipping duplicate.")
+		return http.StatusOK, 0, nil
+	}
+
+	// runs fanned out to every node or zone collect the report of each of them before the combined result is stored
+	var nodeStatuses []khstatev1.NodeStatus
+	var zoneStatuses []khstatev1.ZoneStatus
+	if run, known := k.runTracker.Get(podReport.UUID); known && len(run.FanOut) > 0 {
+		target := podReport.Node
+		if run.FanOut == external.FanOutZones {
+			target = podReport.Zone
+		}
+		reported, complete, err := k.runTracker.ReportTarget(podReport.UUID, target, state)
+		if err != nil {
+			k.externalCheckReportHandlerLog(requestID, "Report for uuid", podReport.UUID, "came from", run.FanOut, target+":", err)
+			return http.StatusBadRequest, 0, nil
+		}
+		if !complete {
+			k.externalCheckReportHandlerLog(requestID, "Recorded report of", run.FanOut, target, "for uuid", podReport.UUID+".", len(reported.TargetReports), "of", len(reported.Targets), "have reported.")
+			return http.StatusOK, 0, nil
+		}
+		nodeStatuses = reported.NodeStatuses("")
+		zoneStatuses = reported.ZoneStatuses("")
+		state = reported.AggregateReport("")
+	}
 
 	checkRunDuration := time.Duration(0).String()
 	khWorkload := determineKHWorkload(podReport.Name, podReport.Namespace)
@@ -1459,22 +2418,108 @@ func (k *Kuberhealthy) externalCheckReportHandler(w http.ResponseWriter, r *http
 	details.RunDuration = checkRunDuration
 	details.Namespace = podReport.Namespace
 	details.CurrentUUID = podReport.UUID
+	details.ReportRequestID = reportRequestID
+	details.NodeStatuses = nodeStatuses
+	details.ZoneStatuses = zoneStatuses
+	details.Metadata = state.Metadata
+	details.ErrorDetails = newErrorDetails(state.ErrorDetails)
+	details.Artifacts = newArtifacts(state.Artifacts)
+	details.Metrics = state.Metrics
+	details.Warnings = state.Warnings
+	details.SubChecks = newSubCheckStatuses(state.SubChecks)
+	details.Reason = reportedFailureReason(state)
 
 	// since the check is validated, we can proceed to update the status now
 	k.externalCheckReportHandlerLog(requestID, "Setting check with name", podReport.Name, "in namespace", podReport.Namespace, "to 'OK' state:", details.OK, "uuid", details.CurrentUUID, details.GetKHWorkload())
-	err = k.storeCheckState(podReport.Name, podReport.Namespace, details)
+	k.seedEventState(podReport.Name, podReport.Namespace, khWorkload)
+	err := k.storeCheckState(podReport.Name, podReport.Namespace, details)
+	if delay, throttled := retryAfterForError(err); throttled {
+		k.externalCheckReportHandlerLog(requestID, "Kubernetes API is throttling khstate writes. Asking client to retry in", delay)
+		return http.StatusTooManyRequests, delay, nil
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
 		k.externalCheckReportHandlerLog(requestID, "failed to store check state for %s: %w", podReport.Name, err)
-		return fmt.Errorf("failed to store check state for %s: %w", podReport.Name, err)
+		return http.StatusInternalServerError, 0, fmt.Errorf("failed to store check state for %s: %w", podReport.Name, err)
 	}
 
-	// write ok back to caller
-	w.WriteHeader(http.StatusOK)
+	k.runTracker.MarkReported(podReport.UUID, state, reportRequestID)
+	k.publishStateObserved(podReport.Name, podReport.Namespace, details)
+
 	k.externalCheckReportHandlerLog(requestID, "Request completed successfully.")
+	return http.StatusOK, 0, nil
+}
+
+// externalCheckRunStatusHandler serves what Kuberhealthy has recorded for the run UUID at the end of the request path
+func (k *Kuberhealthy) externalCheckRunStatusHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	runUUID := strings.TrimPrefix(r.URL.Path, "/externalCheckStatus/")
+	if len(runUUID) == 0 || strings.Contains(runUUID, "/") {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+
+	runStatus, found := k.getRunStatus(runUUID)
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+
+	b, err := json.MarshalIndent(runStatus, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to marshal status of run %s: %w", runUUID, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(b)
+	if err != nil {
+		return fmt.Errorf("failed to write status of run %s: %w", runUUID, err)
+	}
 	return nil
 }
 
+// getRunStatus looks up a run by its UUID.  Runs that are still tracked in memory are served from the run tracker.
+// Older runs, or runs from before a restart, are looked up in the run history of the khstate resources.
+func (k *Kuberhealthy) getRunStatus(runUUID string) (status.RunStatus, bool) {
+	run, known := k.runTracker.Get(runUUID)
+	if known {
+		return run.Status(), true
+	}
+
+	if k.stateReflector == nil {
+		return status.RunStatus{}, false
+	}
+	currentStatus := k.stateReflector.CurrentStatus()
+	for _, details := range []map[string]khstatev1.WorkloadDetails{currentStatus.CheckDetails, currentStatus.JobDetails} {
+		for name, d := range details {
+			for _, entry := range d.History {
+				if entry.UUID != runUUID {
+					continue
+				}
+				late := entry.Result == khstatev1.RunLateSuccess || entry.Result == khstatev1.RunLateFailure
+				runStatus := status.RunStatus{
+					UUID:      runUUID,
+					CheckName: strings.TrimPrefix(name, d.Namespace+"/"),
+					Namespace: d.Namespace,
+					State:     string(external.RunReported),
+					Accepted:  !late,
+					Late:      late,
+					OK:        entry.Result == khstatev1.RunSuccess || entry.Result == khstatev1.RunLateSuccess,
+					Errors:    entry.Errors,
+				}
+				if late {
+					runStatus.State = string(external.RunExpired)
+				}
+				return runStatus, true
+			}
+		}
+	}
+	return status.RunStatus{}, false
+}
+
 // writeHealthCheckError writes an error to the client when things go wrong in a health check handling
 func (k *Kuberhealthy) writeHealthCheckError(w http.ResponseWriter, r *http.Request, err error, state health.State) {
 	// if creating a CRD client fails, then write the error back to the user
@@ -1494,6 +2539,13 @@ func (k *Kuberhealthy) prometheusMetricsHandler(w http.ResponseWriter, r *http.R
 	state := k.getCurrentState([]string{})
 
 	m := metrics.GenerateMetrics(state, cfg.PromMetricsConfig)
+	if report, ok := k.currentCoverage(); ok {
+		m += coverage.PrometheusMetrics(report)
+	}
+	if report, ok := k.currentImageVersions(); ok {
+		m += imageversions.PrometheusMetrics(report)
+	}
+	m += k.requestMetrics.PrometheusMetrics()
 	// write summarized health check results back to caller
 	_, err := w.Write([]byte(m))
 	if err != nil {
@@ -1502,6 +2554,26 @@ func (k *Kuberhealthy) prometheusMetricsHandler(w http.ResponseWriter, r *http.R
 	return err
 }
 
+// grafanaDashboardHandler serves a grafana dashboard generated for the currently configured checks so that operators
+// can import an up to date dashboard instead of maintaining one by hand
+func (k *Kuberhealthy) grafanaDashboardHandler(w http.ResponseWriter, r *http.Request) error {
+	log.Infoln("Client connected to grafana dashboard endpoint from", r.RemoteAddr, r.UserAgent())
+	state := k.getCurrentState([]string{})
+
+	dashboard, err := metrics.GenerateDashboard(state)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to generate grafana dashboard: %w", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(dashboard)
+	if err != nil {
+		log.Warningln("Error writing grafana dashboard to caller:", err)
+	}
+	return err
+}
+
 // healthCheckHandler returns the current status of checks loaded into Kuberhealthy
 // as JSON to the client. Respects namespace requests via URL query parameters (i.e. /?namespace=default)
 func (k *Kuberhealthy) healthCheckHandler(w http.ResponseWriter, r *http.Request) error {
@@ -1535,7 +2607,7 @@ func (k *Kuberhealthy) healthCheckHandler(w http.ResponseWriter, r *http.Request
 	state := k.getCurrentState(namespaces)
 
 	// write summarized health check results back to caller
-	err = state.WriteHTTPStatusResponse(w)
+	err = state.WriteCacheableHTTPStatusResponse(w, r)
 	if err != nil {
 		log.Warningln("Error writing health check results to caller:", err)
 	}
@@ -1548,7 +2620,7 @@ func (k *Kuberhealthy) healthCheckHandler(w http.ResponseWriter, r *http.Request
 // Failures to fetch CRD state return an error.
 func (k *Kuberhealthy) getCurrentState(namespaces []string) health.State {
 
-	currentMaster, err := masterCalculation.CalculateMaster(kubernetesClient)
+	master, err := currentMaster.Get(time.Now())
 	if err != nil {
 		log.Errorln("Failed to calculate master:", err)
 	}
@@ -1560,7 +2632,18 @@ func (k *Kuberhealthy) getCurrentState(namespaces []string) health.State {
 		currentState = k.stateReflector.CurrentStatus()
 	}
 
-	currentState.CurrentMaster = currentMaster
+	currentState = markStaleResults(currentState, k.clock().Now(), k.stateReflector.checkSchedule)
+
+	// the state of all namespaces is the shared snapshot of the reflector, so rule results are added to a copy
+	if results, ok := k.currentRuleResults(); ok {
+		currentState = addRuleResults(currentState.Copy(), results, namespaces)
+	}
+
+	currentState.CurrentMaster = master
+	if since, degraded := k.degraded.degradedSince(); degraded {
+		currentState.Degraded = true
+		currentState.StaleSince = &since
+	}
 	if len(cfg.StateMetadata) != 0 {
 		currentState.Metadata = cfg.StateMetadata
 	}
@@ -1576,6 +2659,7 @@ func (k *Kuberhealthy) getCurrentStatusForNamespaces(namespaces []string) health
 	states := k.stateReflector.CurrentStatus()
 	statesForNamespaces := states
 	statesForNamespaces.Errors = []string{}
+	statesForNamespaces.Warnings = nil
 	statesForNamespaces.OK = true
 	statesForNamespaces.CheckDetails = make(map[string]khstatev1.WorkloadDetails)
 	statesForNamespaces.JobDetails = make(map[string]khstatev1.WorkloadDetails)
@@ -1616,6 +2700,15 @@ func validateCurrentStatusForNamespaces(details map[string]khstatev1.WorkloadDet
 			statesForNamespaces.OK = false
 		}
 
+		// warnings are shown apart from errors and leave the global OK state alone
+		for _, w := range checkState.Warnings {
+			if len(strings.TrimSpace(w)) == 0 {
+				log.Warningln("Skipped a warning that was blank when adding check details to current state.")
+				continue
+			}
+			statesForNamespaces.AddWarning(w)
+		}
+
 		// update details struct
 		switch workload {
 		case khstatev1.KHCheck:
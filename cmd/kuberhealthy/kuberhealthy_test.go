@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/testutil"
+)
+
+// TestSettleMasterChanges ensures master changes only take effect once master change events have settled for an
+// interval, and that losing master is signaled the same way
+func TestSettleMasterChanges(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	k := &Kuberhealthy{Clock: clock}
+
+	previousMaster, previousUpcoming, previousChange := isMaster, upcomingMasterState, lastMasterChangeTime
+	t.Cleanup(func() {
+		isMaster, upcomingMasterState, lastMasterChangeTime = previousMaster, previousUpcoming, previousChange
+	})
+	isMaster = false
+	upcomingMasterState = true
+	lastMasterChangeTime = clock.Now()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	becameMaster := make(chan struct{})
+	lostMaster := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		k.settleMasterChanges(ctx, time.Second*10, becameMaster, lostMaster)
+		close(stopped)
+	}()
+	clock.BlockUntil(1)
+
+	// another master event arrives half way through the first interval, so the first tick keeps waiting
+	clock.Step(time.Second * 5)
+	lastMasterChangeTime = clock.Now()
+	clock.Step(time.Second * 5)
+	expectNoSignal(t, becameMaster, lostMaster)
+
+	clock.Step(time.Second * 10)
+	select {
+	case <-becameMaster:
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected to become master once master events settled")
+	}
+
+	// the failover to another replica is seen on the next tick after it settles
+	upcomingMasterState = false
+	clock.Step(time.Second * 10)
+	select {
+	case <-lostMaster:
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected to lose master once another replica took over")
+	}
+
+	cancel()
+	<-stopped
+}
+
+// expectNoSignal fails the test if the master monitor signals a master change within a short time
+func expectNoSignal(t *testing.T, becameMaster chan struct{}, lostMaster chan struct{}) {
+	t.Helper()
+	select {
+	case <-becameMaster:
+		t.Fatal("became master before master events settled")
+	case <-lostMaster:
+		t.Fatal("lost master before master events settled")
+	case <-time.After(time.Millisecond * 100):
+	}
+}
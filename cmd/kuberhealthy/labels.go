@@ -0,0 +1,70 @@
+package main
+
+import (
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	khjobv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khjob/v1"
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+)
+
+// propagatedLabels picks the labels of a khcheck or khjob that are configured with propagateLabels.  These are carried
+// onto checker pods, khstates, metrics and notifications so that results can be routed by labels such as team or tier.
+// Nothing is returned when none of the configured labels are set.
+func propagatedLabels(labels map[string]string) map[string]string {
+	if cfg == nil || len(cfg.PropagateLabels) == 0 || len(labels) == 0 {
+		return nil
+	}
+	picked := make(map[string]string)
+	for _, key := range cfg.PropagateLabels {
+		value, ok := labels[key]
+		if ok {
+			picked[key] = value
+		}
+	}
+	if len(picked) == 0 {
+		return nil
+	}
+	return picked
+}
+
+// workloadLabels returns the labels of a khcheck or khjob from the cached khchecks and khjobs.  Nothing is found until
+// the caches have synced.
+func (sr *StateReflector) workloadLabels(namespace string, name string, workload khstatev1.KHWorkload) (map[string]string, bool) {
+	if sr == nil || !sr.workloadsSynced() {
+		return nil, false
+	}
+	key := namespace + "/" + name
+	if workload == khstatev1.KHJob {
+		item, exists, err := sr.jobStore.GetByKey(key)
+		if err != nil || !exists {
+			return nil, false
+		}
+		khJob, ok := item.(*khjobv1.KuberhealthyJob)
+		if !ok {
+			return nil, false
+		}
+		return khJob.Labels, true
+	}
+	item, exists, err := sr.checkStore.GetByKey(key)
+	if err != nil || !exists {
+		return nil, false
+	}
+	khCheck, ok := item.(*khcheckv1.KuberhealthyCheck)
+	if !ok {
+		return nil, false
+	}
+	return khCheck.Labels, true
+}
+
+// withLabels adds the propagated labels of a check or job to the details of its state.  The labels the details were
+// stored with are kept when the khcheck or khjob can not be found in the caches.
+func (k *Kuberhealthy) withLabels(name string, namespace string, details khstatev1.WorkloadDetails) khstatev1.WorkloadDetails {
+	if cfg == nil || len(cfg.PropagateLabels) == 0 {
+		return details
+	}
+	labels, ok := k.stateReflector.workloadLabels(namespace, name, details.GetKHWorkload())
+	if !ok {
+		return details
+	}
+	details.Labels = propagatedLabels(labels)
+	return details
+}
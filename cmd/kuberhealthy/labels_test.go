@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+)
+
+// TestPropagatedLabels ensures only the configured labels of a khcheck or khjob are propagated
+func TestPropagatedLabels(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	labels := map[string]string{"team": "network", "tier": "critical", "app": "dns-check"}
+
+	cfg = &Config{}
+	if propagatedLabels(labels) != nil {
+		t.Fatal("expected no labels to be propagated when none are configured")
+	}
+
+	cfg = &Config{PropagateLabels: []string{"team", "tier", "owner"}}
+	expected := map[string]string{"team": "network", "tier": "critical"}
+	if got := propagatedLabels(labels); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("propagated %v but expected %v", got, expected)
+	}
+	if propagatedLabels(map[string]string{"app": "dns-check"}) != nil {
+		t.Fatal("expected no labels when none of the configured labels are set")
+	}
+}
+
+// TestWithLabelsUnsynced ensures the labels a state was stored with are kept until the khcheck and khjob caches sync
+func TestWithLabelsUnsynced(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg = &Config{PropagateLabels: []string{"team"}}
+
+	k := &Kuberhealthy{stateReflector: &StateReflector{}}
+	details := khstatev1.NewWorkloadDetails(khstatev1.KHCheck)
+	details.Labels = map[string]string{"team": "network"}
+	details = k.withLabels("dns", "kuberhealthy", details)
+	if details.Labels["team"] != "network" {
+		t.Fatalf("expected the stored labels to be kept, got %v", details.Labels)
+	}
+}
@@ -15,10 +15,11 @@ import (
 	log "github.com/sirupsen/logrus"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 
 	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
 	khjobv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khjob/v1"
+	khprobev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khprobe/v1"
+	khremediationv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khremediation/v1"
 	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/kubeClient"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/masterCalculation"
@@ -29,6 +30,7 @@ var cfg *Config
 var configPath = "/etc/config/kuberhealthy.yaml"
 
 var podNamespace = os.Getenv("POD_NAMESPACE")
+var podIP = os.Getenv("POD_IP")
 var isMaster bool                  // indicates this instance is the master and should be running checks
 var upcomingMasterState bool       // the upcoming master state on next interval
 var lastMasterChangeTime time.Time // indicates the last time a master change was seen
@@ -49,6 +51,9 @@ const DefaultRunInterval = time.Minute * 10
 // DefaultTimeout is the default timeout for external checks
 var DefaultTimeout = time.Minute * 5
 
+// defaultMaxRunHistory is the default number of runs kept in the history of each khstate
+const defaultMaxRunHistory = 10
+
 // KHCheckNameAnnotationKey is the key used in the annotation that holds the check's short name
 const KHCheckNameAnnotationKey = "comcast.github.io/check-name"
 
@@ -61,6 +66,12 @@ var khCheckClient *khcheckv1.KHCheckV1Client
 // khJobClient is a client for khjob custom resources
 var khJobClient *khjobv1.KHJobV1Client
 
+// khRemediationClient is a client for khremediation custom resources
+var khRemediationClient *khremediationv1.KHRemediationV1Client
+
+// khProbeClient is a client for khprobe custom resources
+var khProbeClient *khprobev1.KHProbeV1Client
+
 // constants for using the kuberhealthy status CRD
 const stateCRDGroup = "comcast.github.io"
 const stateCRDVersion = "v1"
@@ -71,12 +82,21 @@ const checkCRDGroup = "comcast.github.io"
 const checkCRDVersion = "v1"
 const checkCRDResource = "khchecks"
 
+// constants for using the kuberhealthy job CRD
+const jobCRDResource = "khjobs"
+
 // the global kubernetes client
 var kubernetesClient *kubernetes.Clientset
 
 // Set dynamicClient that represents the client used to watch and list unstructured khchecks
 var dynamicClient dynamic.Interface
 
+// the rate limits, request timeout and throttling retries of the clients kuberhealthy talks to the API server with
+var apiQPS = 20.0
+var apiBurst = 40
+var apiTimeout time.Duration
+var apiRetries = 5
+
 // setUpConfig loads and sets default Kuberhealthy configurations
 // Everytime kuberhealthy sees a configuration change, configurations should reload and reset
 func setUpConfig() error {
@@ -114,8 +134,50 @@ func setUp() error {
 	flaggy.SetDescription("Kuberhealthy is an in-cluster synthetic health checker for Kubernetes.")
 	flaggy.String(&configPath, "c", "config", "(optional) absolute path to the kuberhealthy config file")
 	flaggy.Bool(&useDebugMode, "d", "debug", "Set to true to enable debug.")
+	flaggy.Bool(&manageCRDs, "", "crd-manage", "Install and upgrade the Kuberhealthy CRDs at startup. Defaults to true.")
+	flaggy.Float64(&apiQPS, "", "api-qps", "Requests per second allowed to the Kubernetes API. Defaults to 20.")
+	flaggy.Int(&apiBurst, "", "api-burst", "Requests allowed to the Kubernetes API above the QPS in a burst. Defaults to 40.")
+	flaggy.Duration(&apiTimeout, "", "api-timeout", "How long a single Kubernetes API request can take. Watches are restarted when they reach it. Defaults to no timeout.")
+	flaggy.Int(&apiRetries, "", "api-retries", "How many times Kubernetes API requests throttled with a 429 are retried with jittered backoff. Defaults to 5.")
+	addInstallCommand()
+	addSupportBundleCommand()
+	addCheckBundleCommands()
 	flaggy.Parse()
 
+	// the install subcommand renders or applies the kuberhealthy manifests instead of running kuberhealthy
+	if installCommand.Used {
+		err := runInstall(context.Background(), installOpts, os.Stdout)
+		if err != nil {
+			log.Fatalln("Error installing Kuberhealthy:", err)
+		}
+		os.Exit(0)
+	}
+
+	// the support-bundle subcommand collects or inspects a support bundle instead of running kuberhealthy
+	if supportBundleCommand.Used {
+		err := runSupportBundle(context.Background(), supportBundleOpts, os.Stdout)
+		if err != nil {
+			log.Fatalln("Error with support bundle:", err)
+		}
+		os.Exit(0)
+	}
+
+	// the export-checks and import-checks subcommands move khchecks between clusters instead of running kuberhealthy
+	if exportChecksCommand.Used {
+		err := runExportChecks(context.Background(), exportChecksOpts, os.Stdout)
+		if err != nil {
+			log.Fatalln("Error exporting khchecks:", err)
+		}
+		os.Exit(0)
+	}
+	if importChecksCommand.Used {
+		err := runImportChecks(context.Background(), importChecksOpts, os.Stdin, os.Stdout)
+		if err != nil {
+			log.Fatalln("Error importing khchecks:", err)
+		}
+		os.Exit(0)
+	}
+
 	err := setUpConfig()
 	if err != nil {
 		return err
@@ -170,6 +232,14 @@ func main() {
 		log.Fatalln("Error setting up Kuberhealthy:", err)
 	}
 
+	// install or upgrade the kuberhealthy CRDs unless they are managed some other way
+	if manageCRDs {
+		err = ensureCRDs(context.Background(), dynamicClient)
+		if err != nil {
+			log.Errorln("Failed to manage Kuberhealthy CRDs:", err)
+		}
+	}
+
 	// Create a new Kuberhealthy struct
 	kuberhealthy := NewKuberhealthy()
 	kuberhealthy.ListenAddr = cfg.ListenAddress
@@ -221,45 +291,66 @@ func listenForInterrupts(k *Kuberhealthy) {
 }
 
 // initKubernetesClients creates the appropriate CRD clients and kubernetes client to be used in all cases. Issue #181
+// Every client is built from one shared HTTP client so that they reuse the same connections to the API server.
 func initKubernetesClients() error {
 
+	shared, err := kubeClient.NewShared(cfg.kubeConfigFile, kubeClient.Options{
+		QPS:        float32(apiQPS),
+		Burst:      apiBurst,
+		Timeout:    apiTimeout,
+		UserAgent:  kubeClient.UserAgent,
+		MaxRetries: apiRetries,
+	})
+	if err != nil {
+		return err
+	}
+
 	// make a new kuberhealthy client
-	kc, err := kubeClient.Create(cfg.kubeConfigFile)
+	kc, err := shared.Clientset()
 	if err != nil {
 		return err
 	}
 	kubernetesClient = kc
 
 	// make a new crd check client
-	checkClient, err := khcheckv1.Client(cfg.kubeConfigFile)
+	checkClient, err := khcheckv1.NewForConfigAndClient(shared.Config, shared.HTTPClient)
 	if err != nil {
 		return err
 	}
 	khCheckClient = checkClient
 
 	// make a new crd state client
-	stateClient, err := khstatev1.Client(cfg.kubeConfigFile)
+	stateClient, err := khstatev1.NewForConfigAndClient(shared.Config, shared.HTTPClient)
 	if err != nil {
 		return err
 	}
 	khStateClient = stateClient
 
 	// make a new crd job client
-	jobClient, err := khjobv1.Client(cfg.kubeConfigFile)
+	jobClient, err := khjobv1.NewForConfigAndClient(shared.Config, shared.HTTPClient)
 	if err != nil {
 		return err
 	}
 	khJobClient = jobClient
 
-	// make a dynamicClient for kubernetes unstructured checks
-	restConfig, err := clientcmd.BuildConfigFromFlags("", configPath)
+	// make a new crd remediation client
+	remediationClient, err := khremediationv1.NewForConfigAndClient(shared.Config, shared.HTTPClient)
+	if err != nil {
+		return err
+	}
+	khRemediationClient = remediationClient
+
+	// make a new crd probe client
+	probeClient, err := khprobev1.NewForConfigAndClient(shared.Config, shared.HTTPClient)
 	if err != nil {
-		log.Fatalln("Failed to build kubernetes configuration from configuration flags:", err)
+		return err
 	}
+	khProbeClient = probeClient
 
-	dynamicClient, err = dynamic.NewForConfig(restConfig)
+	// make a dynamicClient for kubernetes unstructured checks
+	dynamicClient, err = shared.Dynamic()
 	if err != nil {
-		log.Fatalln("Failed to create kubernetes dynamic client configuration")
+		return err
 	}
 
 	return nil
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// nodeCacheResync is how often the node cache is fully resynced from the API
+const nodeCacheResync = time.Minute * 10
+
+// nodeCache is a process wide cache of the nodes of the cluster.  Fanned out check runs and health rules look nodes up
+// in it instead of each listing every node from the API.
+type nodeCache struct {
+	informer cache.SharedIndexInformer
+	lister   corelisters.NodeLister
+}
+
+// newNodeCache creates a node cache that watches nodes with the supplied client
+func newNodeCache(client kubernetes.Interface) *nodeCache {
+	nodes := informers.NewSharedInformerFactory(client, nodeCacheResync).Core().V1().Nodes()
+	return &nodeCache{informer: nodes.Informer(), lister: nodes.Lister()}
+}
+
+// Start fills and watches the cache in the background until the context is done
+func (c *nodeCache) Start(ctx context.Context) {
+	log.Infoln("Node cache starting")
+	go c.informer.Run(ctx.Done())
+}
+
+// HasSynced returns true once the cache has been filled from the API
+func (c *nodeCache) HasSynced() bool {
+	if c == nil {
+		return false
+	}
+	return c.informer.HasSynced()
+}
+
+// List returns the cached nodes that match the selector
+func (c *nodeCache) List(selector labels.Selector) ([]*apiv1.Node, error) {
+	return c.lister.List(selector)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+// TestNodeCache ensures nodes are served from the cache once it has synced, without listing them from the API again
+func TestNodeCache(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: map[string]string{corev1.LabelTopologyZone: "zone-a"}}},
+	)
+	nodes := newNodeCache(client)
+	if nodes.HasSynced() {
+		t.Fatal("expected the node cache not to be synced before it started")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	nodes.Start(ctx)
+	if !cache.WaitForCacheSync(ctx.Done(), nodes.HasSynced) {
+		t.Fatal("node cache did not sync")
+	}
+
+	// nodes added after the sync arrive through the watch
+	_, err := client.CoreV1().Nodes().Create(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "b"}}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		listed, err := nodes.lister.List(labels.Everything())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(listed) == 2 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("expected 2 cached nodes, got %d", len(listed))
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+
+	listsBefore := countNodeLists(client)
+	zones, err := listNodeZones(ctx, client, nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zones["a"] != "zone-a" || len(zones) != 2 {
+		t.Fatalf("unexpected node zones: %v", zones)
+	}
+	if countNodeLists(client) != listsBefore {
+		t.Fatal("expected node zones to be served from the cache")
+	}
+}
+
+// countNodeLists counts the node list calls made to a fake client
+func countNodeLists(client *fake.Clientset) int {
+	var lists int
+	for _, a := range client.Actions() {
+		if a.GetVerb() == "list" && a.GetResource().Resource == "nodes" {
+			lists++
+		}
+	}
+	return lists
+}
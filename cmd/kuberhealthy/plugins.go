@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/metrics"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/plugins"
+)
+
+// defaultPluginTimeout is how long plugins may take to handle a result
+const defaultPluginTimeout = time.Second * 10
+
+// metricForwarderPlugin lets the metric forwarder configured before the plugins keep receiving metrics once the
+// plugins take over forwarding
+type metricForwarderPlugin struct {
+	metrics.Client
+}
+
+// Close does nothing because metric forwarders hold no resources
+func (metricForwarderPlugin) Close() error {
+	return nil
+}
+
+// configurePlugins loads the configured plugins.  Plugins that fail to load are logged and left out.  When any plugin
+// is a metric sink, the plugins become the metric forwarder, along with InfluxDB if it is enabled.
+func (k *Kuberhealthy) configurePlugins() {
+	set, errs := plugins.Load(cfg.Plugins)
+	for _, err := range errs {
+		log.Errorln("plugins:", err)
+	}
+	log.Infoln("plugins: Loaded", set.Len(), "of", len(cfg.Plugins), "plugins")
+
+	if set.HasMetricSinks() {
+		if k.MetricForwarder != nil {
+			set.Add("influxdb", metricForwarderPlugin{k.MetricForwarder})
+		}
+		k.MetricForwarder = set
+	}
+	k.Plugins = set
+}
+
+// closePlugins closes the loaded plugins, such as to stop the processes of executable plugins
+func (k *Kuberhealthy) closePlugins() {
+	for _, err := range k.Plugins.Close() {
+		log.Warningln("plugins:", err)
+	}
+}
+
+// notifyPlugins hands a completed check or job run to the notifier plugins in the background
+func (k *Kuberhealthy) notifyPlugins(name string, namespace string, details khstatev1.WorkloadDetails) {
+	if k.Plugins.Len() == 0 {
+		return
+	}
+	r := plugins.Result{
+		Name:         name,
+		Namespace:    namespace,
+		Workload:     string(details.GetKHWorkload()),
+		OK:           details.OK,
+		Errors:       details.Errors,
+		RunDuration:  details.RunDuration,
+		UUID:         details.CurrentUUID,
+		Node:         details.Node,
+		Correlations: details.Correlations,
+		Labels:       details.Labels,
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultPluginTimeout)
+		defer cancel()
+		for _, err := range k.Plugins.Notify(ctx, r) {
+			log.Warningln("plugins:", err)
+		}
+	}()
+}
+
+// storePluginState hands a newly stored check or job state to the state store plugins in the background.  The
+// fields filled in when the khstate is written are filled in the same way.
+func (k *Kuberhealthy) storePluginState(name string, namespace string, details khstatev1.WorkloadDetails) {
+	if k.Plugins.Len() == 0 {
+		return
+	}
+	now := metav1.Now()
+	details.AuthoritativePod = podHostname
+	details.LastRun = &now
+	s := plugins.State{Name: name, Namespace: namespace, Details: details}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultPluginTimeout)
+		defer cancel()
+		for _, err := range k.Plugins.Store(ctx, s) {
+			log.Warningln("plugins:", err)
+		}
+	}()
+}
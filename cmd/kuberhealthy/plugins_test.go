@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/metrics"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/plugins"
+)
+
+// countingSink is a metric sink plugin that counts the metrics pushed to it
+type countingSink struct {
+	pushed int
+}
+
+func (s *countingSink) Push(points metrics.Metric, tags map[string]string) error {
+	s.pushed++
+	return nil
+}
+
+func (s *countingSink) Close() error {
+	return nil
+}
+
+// countingNotifier is a notifier plugin that counts the runs it is told about
+type countingNotifier struct {
+	notified int
+}
+
+func (n *countingNotifier) Notify(ctx context.Context, r plugins.Result) error {
+	n.notified++
+	return nil
+}
+
+func (n *countingNotifier) Close() error {
+	return nil
+}
+
+// TestConfigurePlugins ensures that metric sink plugins take over metric forwarding without dropping InfluxDB, and
+// that the metric forwarder is left alone when no plugin is a metric sink
+func TestConfigurePlugins(t *testing.T) {
+	oldCfg := cfg
+	defer func() {
+		cfg = oldCfg
+	}()
+
+	sink := &countingSink{}
+	plugins.Register("test-sink", func(settings map[string]string) (plugins.Plugin, error) {
+		return sink, nil
+	})
+	plugins.Register("test-notifier", func(settings map[string]string) (plugins.Plugin, error) {
+		return &countingNotifier{}, nil
+	})
+
+	influx := &countingSink{}
+	k := &Kuberhealthy{MetricForwarder: influx}
+	cfg = &Config{Plugins: []plugins.Config{{Name: "test-sink"}, {Name: "missing"}}}
+	k.configurePlugins()
+	if k.Plugins.Len() != 2 {
+		t.Fatalf("expected the sink and InfluxDB in the plugins, got %d plugins", k.Plugins.Len())
+	}
+	err := k.MetricForwarder.Push(metrics.Metric{{"dns.kuberhealthy": 1}}, nil)
+	if err != nil || sink.pushed != 1 || influx.pushed != 1 {
+		t.Fatalf("expected metrics to reach the plugin and InfluxDB, got %d and %d (%v)", sink.pushed, influx.pushed, err)
+	}
+
+	k = &Kuberhealthy{MetricForwarder: influx}
+	cfg = &Config{Plugins: []plugins.Config{{Name: "test-notifier"}}}
+	k.configurePlugins()
+	if k.MetricForwarder != influx || k.Plugins.Len() != 1 {
+		t.Fatalf("expected InfluxDB to stay the metric forwarder without metric sink plugins")
+	}
+}
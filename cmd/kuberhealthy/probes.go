@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	khprobev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khprobe/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/discovery"
+)
+
+// defaultProbeSyncInterval is how often khprobes are expanded to keep up with their targets coming and going
+const defaultProbeSyncInterval = time.Second * 30
+
+// expandProbe lists the targets that a khprobe selects and expands it into a khcheck for each of them
+func expandProbe(ctx context.Context, client kubernetes.Interface, kp khprobev1.KuberhealthyProbe, opts discovery.Options) (discovery.Expansion, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&kp.Spec.Selector)
+	if err != nil {
+		return discovery.Expansion{}, fmt.Errorf("invalid selector: %w", err)
+	}
+	listOptions := metav1.ListOptions{LabelSelector: selector.String()}
+
+	var services []corev1.Service
+	var pods []corev1.Pod
+	switch kp.Spec.TargetKind {
+	case khprobev1.TargetPod:
+		podList, err := client.CoreV1().Pods(kp.Namespace).List(ctx, listOptions)
+		if err != nil {
+			return discovery.Expansion{}, fmt.Errorf("failed to list pods: %w", err)
+		}
+		pods = podList.Items
+	default:
+		serviceList, err := client.CoreV1().Services(kp.Namespace).List(ctx, listOptions)
+		if err != nil {
+			return discovery.Expansion{}, fmt.Errorf("failed to list services: %w", err)
+		}
+		services = serviceList.Items
+	}
+	return discovery.ForProbe(kp, services, pods, opts)
+}
+
+// probeStatus is the status of a khprobe after it was expanded
+func probeStatus(exp discovery.Expansion, err error) khprobev1.ProbeStatus {
+	if err != nil {
+		return khprobev1.ProbeStatus{Errors: []string{err.Error()}}
+	}
+	return khprobev1.ProbeStatus{
+		TargetCount: len(exp.Targets),
+		Targets:     exp.Targets,
+		Errors:      exp.Errors,
+	}
+}
+
+// monitorProbes expands khprobes on an interval until the supplied context is canceled.  Only the master instance
+// makes changes.
+func (k *Kuberhealthy) monitorProbes(ctx context.Context) {
+	log.Infoln("probes: Expanding khprobes into khchecks every", defaultProbeSyncInterval)
+	ticker := time.NewTicker(defaultProbeSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		if isMaster {
+			k.reconcileProbes(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcileProbes creates, updates and deletes the khchecks of every khprobe so that there is one for each of its
+// current targets.  The khchecks of deleted khprobes are garbage collected through their owner references.
+func (k *Kuberhealthy) reconcileProbes(ctx context.Context) {
+	probes, err := khProbeClient.KuberhealthyProbes(cfg.ListenNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		log.Errorln("probes: failed to list khprobes:", err)
+		return
+	}
+
+	opts := discoveryOptions()
+	for _, kp := range probes.Items {
+		exp, err := expandProbe(ctx, kubernetesClient, kp, opts)
+		if err != nil {
+			log.Errorln("probes: failed to expand khprobe", kp.Namespace+"/"+kp.Name+":", err)
+		}
+		for _, e := range exp.Errors {
+			log.Warningln("probes: khprobe", kp.Namespace+"/"+kp.Name, "skipped", e)
+		}
+
+		// when the khprobe could not be expanded its khchecks are kept until it can be
+		if err == nil {
+			existing, err := khCheckClient.KuberhealthyChecks(kp.Namespace).List(metav1.ListOptions{LabelSelector: discovery.ProbeLabel + "=" + kp.Name})
+			if err != nil {
+				log.Errorln("probes: failed to list the khchecks of khprobe", kp.Namespace+"/"+kp.Name+":", err)
+				continue
+			}
+			name := kp.Name
+			syncGeneratedChecks("probes", existing.Items, exp.Checks, func(c khcheckv1.KuberhealthyCheck) bool {
+				return c.Labels[discovery.ProbeLabel] == name
+			})
+		}
+
+		status := probeStatus(exp, err)
+		if reflect.DeepEqual(status, kp.Status) {
+			continue
+		}
+		kp.Status = status
+		_, err = khProbeClient.KuberhealthyProbes(kp.Namespace).Update(&kp)
+		if err != nil {
+			log.Errorln("probes: failed to update the status of khprobe", kp.Namespace+"/"+kp.Name+":", err)
+		}
+	}
+}
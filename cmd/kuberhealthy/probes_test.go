@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	khprobev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khprobe/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/discovery"
+)
+
+// TestExpandProbe ensures that only the targets matching the selector of a khprobe in its namespace are probed
+func TestExpandProbe(t *testing.T) {
+	running := corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.0.1"}
+	spec := corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Ports: []corev1.ContainerPort{{ContainerPort: 8080}}}}}
+	labels := map[string]string{"app": "web"}
+	client := fake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "shop", Labels: labels}, Spec: spec, Status: running},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-1", Namespace: "shop", Labels: map[string]string{"app": "db"}}, Spec: spec, Status: running},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "other", Labels: labels}, Spec: spec, Status: running},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", Labels: labels}, Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80}}}},
+	)
+
+	var testCases = []struct {
+		name    string
+		spec    khprobev1.ProbeConfig
+		targets []string
+	}{
+		{
+			name:    "pods",
+			spec:    khprobev1.ProbeConfig{Selector: metav1.LabelSelector{MatchLabels: labels}, TargetKind: khprobev1.TargetPod},
+			targets: []string{"web-1"},
+		},
+		{
+			name:    "services",
+			spec:    khprobev1.ProbeConfig{Selector: metav1.LabelSelector{MatchLabels: labels}},
+			targets: []string{"web"},
+		},
+		{
+			name: "nothing selected",
+			spec: khprobev1.ProbeConfig{Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "cache"}}, TargetKind: khprobev1.TargetPod},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kp := khprobev1.NewKuberhealthyProbe("web", "shop", tc.spec)
+			exp, err := expandProbe(context.Background(), client, kp, discovery.DefaultOptions())
+			if err != nil {
+				t.Fatalf("failed to expand khprobe: %s", err)
+			}
+			if !reflect.DeepEqual(exp.Targets, tc.targets) || len(exp.Checks) != len(tc.targets) {
+				t.Fatalf("expected targets %v, got %v with %d khchecks", tc.targets, exp.Targets, len(exp.Checks))
+			}
+		})
+	}
+}
+
+// TestProbeStatus ensures that the status of a khprobe shows its targets or why it could not be expanded
+func TestProbeStatus(t *testing.T) {
+	status := probeStatus(discovery.Expansion{Targets: []string{"web-1", "web-2"}, Errors: []string{"Pod/web-3: pod has no port web"}}, nil)
+	expected := khprobev1.ProbeStatus{TargetCount: 2, Targets: []string{"web-1", "web-2"}, Errors: []string{"Pod/web-3: pod has no port web"}}
+	if !reflect.DeepEqual(status, expected) {
+		t.Fatalf("expected status %+v, got %+v", expected, status)
+	}
+
+	status = probeStatus(discovery.Expansion{}, errors.New("invalid selector"))
+	if status.TargetCount != 0 || !reflect.DeepEqual(status.Errors, []string{"invalid selector"}) {
+		t.Fatalf("expected the expansion error in the status, got %+v", status)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// maxProgressMessageLength is the most characters of a progress message stored on a khstate.  Longer messages are
+// truncated.
+const maxProgressMessageLength = 256
+
+// newRunProgress validates a progress report and turns it into the progress stored on the khstate of the run
+func newRunProgress(uuid string, p status.ProgressReport, now time.Time) (*khstatev1.RunProgress, error) {
+	if p.Percent < 0 || p.Percent > 100 {
+		return nil, fmt.Errorf("progress percent must be between 0 and 100, got %d", p.Percent)
+	}
+	message := []rune(strings.TrimSpace(p.Message))
+	if len(message) > maxProgressMessageLength {
+		message = message[:maxProgressMessageLength]
+	}
+	t := metav1.NewTime(now)
+	return &khstatev1.RunProgress{UUID: uuid, Message: string(message), Percent: p.Percent, Time: &t}, nil
+}
+
+// setRunProgress puts the progress of a run onto its khstate without changing anything else about its state.  The
+// progress is dropped when the khstate has moved on to another run.  Conflicting writes are retried a few times.
+func setRunProgress(checkName string, checkNamespace string, progress *khstatev1.RunProgress) error {
+
+	name := sanitizeResourceName(checkName)
+
+	var err error
+	maxTries := 3
+	for tries := 0; tries < maxTries; tries++ {
+		var existingState khstatev1.KuberhealthyState
+		existingState, err = khStateClient.KuberhealthyStates(checkNamespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return errors.New("Error retrieving CRD for: " + name + " " + err.Error())
+		}
+		if existingState.Spec.CurrentUUID != progress.UUID {
+			log.Debugln(checkNamespace, checkName, "khstate has moved on from run", progress.UUID, "- dropping its progress.")
+			return nil
+		}
+
+		existingState.Spec.Progress = progress
+		_, err = khStateClient.KuberhealthyStates(checkNamespace).Update(&existingState)
+		if err == nil || !strings.Contains(err.Error(), "the object has been modified") {
+			return err
+		}
+		log.Debugln(checkNamespace, checkName, "khstate was modified while setting run progress. Retrying.")
+	}
+	return err
+}
+
+// reportProgressHandler lets a running checker pod report how far along its run is before it reports its result.
+// The calling pod is validated the same way as status reports.  The progress is shown on the status page under the
+// check until the run reports or times out.
+func (k *Kuberhealthy) reportProgressHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+	requestID := "web: " + getRequestID(r)
+	ctx := r.Context()
+
+	podReport, validated, err := k.validateUsingRequestHeader(ctx, r)
+	if !validated && !errors.Is(err, errLateReport) && !errors.Is(err, errOvertakenReport) {
+		podReport, err = k.validatePodReportBySourceIP(ctx, r)
+	}
+	if errors.Is(err, errLateReport) || errors.Is(err, errOvertakenReport) {
+		log.Infoln(requestID, "Run", podReport.UUID, "reported progress after it was timed out or overtaken")
+		w.WriteHeader(http.StatusGone)
+		return nil
+	}
+	if err != nil {
+		log.Infoln(requestID, "Failed to validate progress report from", r.RemoteAddr+":", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+
+	report := status.ProgressReport{}
+	err = json.NewDecoder(r.Body).Decode(&report)
+	if err != nil {
+		log.Infoln(requestID, "Failed to decode progress report:", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+	code, retryAfter, err := k.recordProgress(requestID, podReport, report)
+	if retryAfter > 0 {
+		writeRetryAfter(w, retryAfter)
+		return nil
+	}
+	w.WriteHeader(code)
+	return err
+}
+
+// recordProgress stores the progress reported by a validated run.  Returns the status code to answer the progress
+// report with, or how long the client should wait before reporting progress again when it reported too soon or the
+// Kubernetes API is throttling khstate writes.
+func (k *Kuberhealthy) recordProgress(requestID string, podReport PodReportInfo, report status.ProgressReport) (int, time.Duration, error) {
+	now := k.clock().Now()
+	progress, err := newRunProgress(podReport.UUID, report, now)
+	if err != nil {
+		log.Infoln(requestID, "Refused progress report for run", podReport.UUID+":", err)
+		return http.StatusBadRequest, 0, nil
+	}
+
+	wait, err := k.runTracker.RecordProgress(podReport.UUID, now)
+	if errors.Is(err, external.ErrProgressTooSoon) {
+		log.Debugln(requestID, "Run", podReport.UUID, "reported progress too soon. Asking it to wait", wait)
+		return http.StatusTooManyRequests, wait, nil
+	}
+	if err != nil {
+		log.Infoln(requestID, "Refused progress report for run", podReport.UUID+":", err)
+		return http.StatusGone, 0, nil
+	}
+
+	err = setRunProgress(podReport.Name, podReport.Namespace, progress)
+	if delay, throttled := retryAfterForError(err); throttled {
+		log.Infoln(requestID, "Kubernetes API is throttling khstate writes. Asking client to retry in", delay)
+		return http.StatusTooManyRequests, delay, nil
+	}
+	if err != nil {
+		return http.StatusInternalServerError, 0, fmt.Errorf("failed to store progress of run %s for %s: %w", podReport.UUID, podReport.Namespace+"/"+podReport.Name, err)
+	}
+	log.Infoln(requestID, "Run", podReport.UUID, "of", podReport.Namespace+"/"+podReport.Name, "is", progress.Percent, "percent done:", progress.Message)
+	return http.StatusOK, 0, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestNewRunProgress ensures progress reports are validated and long messages are truncated
+func TestNewRunProgress(t *testing.T) {
+	now := time.Now()
+
+	var testCases = []struct {
+		description string
+		report      status.ProgressReport
+		message     string
+		expectErr   bool
+	}{
+		{"started", status.ProgressReport{Message: " creating deployment ", Percent: 0}, "creating deployment", false},
+		{"done", status.ProgressReport{Percent: 100}, "", false},
+		{"negative", status.ProgressReport{Percent: -1}, "", true},
+		{"over 100", status.ProgressReport{Percent: 101}, "", true},
+		{"long message", status.ProgressReport{Message: strings.Repeat("é", maxProgressMessageLength+10), Percent: 50}, strings.Repeat("é", maxProgressMessageLength), false},
+	}
+	for _, tc := range testCases {
+		progress, err := newRunProgress("run-uuid", tc.report, now)
+		if (err != nil) != tc.expectErr {
+			t.Fatalf("%s: returned error %v but expected an error: %t", tc.description, err, tc.expectErr)
+		}
+		if err != nil {
+			continue
+		}
+		if progress.UUID != "run-uuid" || progress.Percent != tc.report.Percent || progress.Message != tc.message || !progress.Time.Time.Equal(now) {
+			t.Fatalf("%s: unexpected progress %+v", tc.description, progress)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+)
+
+// defaultProvisioningFailureThreshold is the number of provisioning errors in a row after which a check is backed off
+const defaultProvisioningFailureThreshold = 3
+
+// defaultMaxProvisioningBackoff is the longest a check is backed off for after provisioning errors
+const defaultMaxProvisioningBackoff = time.Hour
+
+// provisioningFailureThreshold returns the configured provisioning failure threshold or the default
+func provisioningFailureThreshold() int {
+	if cfg != nil && cfg.ProvisioningFailureThreshold > 0 {
+		return cfg.ProvisioningFailureThreshold
+	}
+	return defaultProvisioningFailureThreshold
+}
+
+// maxProvisioningBackoff returns the configured maximum provisioning backoff or the default
+func maxProvisioningBackoff() time.Duration {
+	if cfg != nil && cfg.MaxProvisioningBackoff.Duration > 0 {
+		return cfg.MaxProvisioningBackoff.Duration
+	}
+	return defaultMaxProvisioningBackoff
+}
+
+// provisioningBackoff returns how long to wait before the next run of a check after the supplied number of
+// provisioning errors in a row.  Below the threshold the check keeps its interval and zero is returned.  From the
+// threshold on, the wait doubles with every failure up to the maximum.  The wait is never shorter than the interval.
+func provisioningBackoff(failures int, interval time.Duration, threshold int, max time.Duration) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+	if max < interval {
+		return interval
+	}
+
+	backoff := interval
+	for i := threshold; i <= failures; i++ {
+		backoff *= 2
+		if backoff >= max {
+			return max
+		}
+	}
+	return backoff
+}
+
+// applyProvisioningError records a provisioning error on the state of a check.  The failures in a row are carried over
+// from the previous state of the check so that they survive restarts.  Returns how long the check should back off
+// for, or zero when it should keep its interval.
+func applyProvisioningError(details *khstatev1.WorkloadDetails, previous khstatev1.WorkloadDetails, interval time.Duration, now time.Time) time.Duration {
+	details.ProvisioningFailures = previous.ProvisioningFailures + 1
+	if len(details.History) > 0 {
+		details.History[len(details.History)-1].Result = khstatev1.RunProvisioningError
+	}
+
+	backoff := provisioningBackoff(details.ProvisioningFailures, interval, provisioningFailureThreshold(), maxProvisioningBackoff())
+	if backoff > 0 {
+		until := metav1.NewTime(now.Add(backoff))
+		details.BackoffUntil = &until
+	}
+	return backoff
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+)
+
+// TestProvisioningBackoff ensures checks keep their interval below the threshold and then back off exponentially up
+// to the maximum
+func TestProvisioningBackoff(t *testing.T) {
+	var testCases = []struct {
+		failures int
+		interval time.Duration
+		max      time.Duration
+		expected time.Duration
+	}{
+		{failures: 1, interval: time.Minute, max: time.Hour, expected: 0},
+		{failures: 2, interval: time.Minute, max: time.Hour, expected: 0},
+		{failures: 3, interval: time.Minute, max: time.Hour, expected: time.Minute * 2},
+		{failures: 4, interval: time.Minute, max: time.Hour, expected: time.Minute * 4},
+		{failures: 6, interval: time.Minute, max: time.Hour, expected: time.Minute * 16},
+		{failures: 9, interval: time.Minute, max: time.Hour, expected: time.Hour},
+		{failures: 100, interval: time.Minute, max: time.Hour, expected: time.Hour},
+		{failures: 3, interval: time.Hour * 2, max: time.Hour, expected: time.Hour * 2},
+	}
+
+	for _, tc := range testCases {
+		backoff := provisioningBackoff(tc.failures, tc.interval, 3, tc.max)
+		if backoff != tc.expected {
+			t.Fatalf("backoff after %d failures with interval %s and max %s was %s but expected %s", tc.failures, tc.interval, tc.max, backoff, tc.expected)
+		}
+	}
+}
+
+// TestApplyProvisioningError ensures provisioning errors are counted across runs, marked in the run history and set
+// a backoff once the threshold is reached
+func TestApplyProvisioningError(t *testing.T) {
+	now := time.Now()
+	previous := khstatev1.NewWorkloadDetails(khstatev1.KHCheck)
+
+	for i := 1; i <= defaultProvisioningFailureThreshold; i++ {
+		details := khstatev1.NewWorkloadDetails(khstatev1.KHCheck)
+		details.History = []khstatev1.RunHistoryEntry{{Result: khstatev1.RunFailure}}
+
+		backoff := applyProvisioningError(&details, previous, time.Minute, now)
+		if details.ProvisioningFailures != i {
+			t.Fatalf("provisioning failures were %d but expected %d", details.ProvisioningFailures, i)
+		}
+		if details.History[0].Result != khstatev1.RunProvisioningError {
+			t.Fatalf("run was recorded as %q but expected %q", details.History[0].Result, khstatev1.RunProvisioningError)
+		}
+		if i < defaultProvisioningFailureThreshold && (backoff != 0 || details.BackoffUntil != nil) {
+			t.Fatalf("check was backed off for %s after %d failures", backoff, i)
+		}
+		previous = details
+	}
+
+	if previous.BackoffUntil == nil || !previous.BackoffUntil.Time.Equal(now.Add(time.Minute*2)) {
+		t.Fatalf("backoffUntil was %v but expected %v", previous.BackoffUntil, now.Add(time.Minute*2))
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/masterCalculation"
+)
+
+// currentMasterTTL is how long a calculated master is reused when serving status requests
+const currentMasterTTL = time.Second * 10
+
+// masterNameCache remembers the name of the current master for a short time so that status and metrics requests do
+// not each list the Kuberhealthy pods.  This lets every replica serve dashboards and scrapes from its own caches.
+type masterNameCache struct {
+	sync.Mutex
+	name      string
+	fetched   time.Time
+	calculate func() (string, error)
+}
+
+// Get returns the name of the current master, calculating it again once the cached name is older than the TTL.  If
+// calculating fails, the last known master is returned along with the error.
+func (c *masterNameCache) Get(now time.Time) (string, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if !c.fetched.IsZero() && now.Sub(c.fetched) < currentMasterTTL {
+		return c.name, nil
+	}
+
+	name, err := c.calculate()
+	if err != nil {
+		return c.name, err
+	}
+	c.name = name
+	c.fetched = now
+	return c.name, nil
+}
+
+// currentMaster caches the name of the current master for status requests
+var currentMaster = &masterNameCache{
+	calculate: func() (string, error) {
+		return masterCalculation.CalculateMaster(kubernetesClient)
+	},
+}
+
+// readyHandler reports if this replica has filled its caches and can serve status requests.  Replicas that are not
+// ready are left out of the service so that pollers never see a partial status page.
+func (k *Kuberhealthy) readyHandler(w http.ResponseWriter, r *http.Request) error {
+	if k.stateReflector == nil || !k.stateReflector.HasSynced() {
+		log.Debugln("Readiness requested before the khstate cache synced")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, err := w.Write([]byte("waiting for caches to sync"))
+		return err
+	}
+	_, err := w.Write([]byte("ok"))
+	return err
+}
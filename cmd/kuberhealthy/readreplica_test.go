@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestMasterNameCache ensures the master is only calculated again after the TTL and that the last known master is
+// kept when calculating fails
+func TestMasterNameCache(t *testing.T) {
+	calls := 0
+	var calculateErr error
+	c := &masterNameCache{calculate: func() (string, error) {
+		calls++
+		if calculateErr != nil {
+			return "", calculateErr
+		}
+		return "kuberhealthy-" + string(rune('a'+calls-1)), nil
+	}}
+
+	now := time.Now()
+	var testCases = []struct {
+		now      time.Time
+		fail     bool
+		expected string
+		calls    int
+	}{
+		{now, false, "kuberhealthy-a", 1},
+		{now.Add(currentMasterTTL / 2), false, "kuberhealthy-a", 1},
+		{now.Add(currentMasterTTL), false, "kuberhealthy-b", 2},
+		{now.Add(currentMasterTTL * 3), true, "kuberhealthy-b", 3},
+	}
+
+	for i, tc := range testCases {
+		calculateErr = nil
+		if tc.fail {
+			calculateErr = errors.New("failed to list pods")
+		}
+		name, err := c.Get(tc.now)
+		if tc.fail != (err != nil) {
+			t.Fatalf("case %d returned error %v", i, err)
+		}
+		if name != tc.expected || calls != tc.calls {
+			t.Fatalf("case %d returned %s after %d calculations but expected %s after %d", i, name, calls, tc.expected, tc.calls)
+		}
+	}
+}
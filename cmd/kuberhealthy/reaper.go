@@ -7,9 +7,12 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	khjobv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khjob/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/duration"
 
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
@@ -35,18 +38,18 @@ func parseDurationOrUseDefault(d string, defaultDuration time.Duration) (time.Du
 		return defaultDuration, nil
 	}
 
-	duration, err := time.ParseDuration(d)
+	parsed, err := duration.Parse(d)
 	if err != nil {
 		return defaultDuration, err
 	}
 
-	if duration == 0 {
+	if parsed == 0 {
 		log.Errorln("checkReaper: duration value 0 is not valid")
 		log.Infoln("checkReaper: Using default duration:", defaultDuration)
 		return defaultDuration, nil
 	}
 
-	return duration, nil
+	return parsed, nil
 
 }
 
@@ -69,14 +72,14 @@ func reaper(ctx context.Context) {
 
 	// set MaxCheckPodAge to minCheckPodAge before getting reaped if no maxCheckPodAge is set
 	// Want to make sure the completed pod is around for at least 30s before getting reaped
-	if cfg.MaxCheckPodAge < minCheckPodAge {
-		cfg.MaxCheckPodAge = minCheckPodAge
+	if cfg.MaxCheckPodAge.Duration < minCheckPodAge {
+		cfg.MaxCheckPodAge.Duration = minCheckPodAge
 	}
 
 	// set MaxKHJobAge to minKHJobAge before getting reaped if no maxCheckPodAge is set
 	// Want to make sure the completed job is around for at least 5m before getting reaped
-	if cfg.MaxKHJobAge < minKHJobAge {
-		cfg.MaxKHJobAge = minKHJobAge
+	if cfg.MaxKHJobAge.Duration < minKHJobAge {
+		cfg.MaxKHJobAge.Duration = minKHJobAge
 	}
 
 	// start a new ticker
@@ -95,6 +98,7 @@ func reaper(ctx context.Context) {
 		// run our check and job reapers
 		runCheckReap(runCtx)
 		runJobReap(runCtx)
+		runNamespaceReap(runCtx, kubernetesClient)
 
 		// check if the parent context has expired
 		select {
@@ -134,16 +138,33 @@ func runCheckReap(ctx context.Context) {
 
 }
 
-// runJobReap runs a process to reap jobs that need deleted (those that were created by a khjob)
-func runJobReap(ctx context.Context) {
-	jobClient, err := khjobv1.Client(cfg.kubeConfigFile)
+// runNamespaceReap deletes the ephemeral run namespaces of isolated checks that were left behind past the deadline of
+// their run, such as when Kuberhealthy restarted before the run could clean up after itself
+func runNamespaceReap(ctx context.Context, client kubernetes.Interface) {
+	namespaces, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: external.RunNamespaceLabel})
 	if err != nil {
-		log.Errorln("checkReaper: Unable to create khJob client", err)
+		log.Errorln("checkReaper: Failed to list run namespaces:", err)
+		return
 	}
 
+	now := time.Now()
+	for _, ns := range namespaces.Items {
+		if !external.RunNamespaceExpired(ns, now) || ns.DeletionTimestamp != nil {
+			continue
+		}
+		log.Infoln("checkReaper: Deleting run namespace", ns.Name, "left behind past the deadline of its run")
+		err = client.CoreV1().Namespaces().Delete(ctx, ns.Name, metav1.DeleteOptions{})
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			log.Errorln("checkReaper: Failed to delete run namespace", ns.Name+":", err)
+		}
+	}
+}
+
+// runJobReap runs a process to reap jobs that need deleted (those that were created by a khjob)
+func runJobReap(ctx context.Context) {
 	log.Infoln("checkReaper: Beginning to search for khjobs.")
 	// fetch and delete khjobs that meet criteria
-	err = khJobDelete(jobClient)
+	err := khJobDelete(khJobClient)
 	if err != nil {
 		log.Errorln("checkReaper: Failed to reap khjobs with error: ", err)
 	}
@@ -186,7 +207,7 @@ func (k *KubernetesAPI) deleteFilteredCheckerPods(ctx context.Context, client *k
 			continue
 		}
 		// Delete pods older than maxCheckPodAge and is in status Succeeded
-		if v.Status.Phase == v1.PodSucceeded && time.Now().Sub(podTerminatedTime) > cfg.MaxCheckPodAge {
+		if v.Status.Phase == v1.PodSucceeded && time.Now().Sub(podTerminatedTime) > cfg.MaxCheckPodAge.Duration {
 			log.Infoln("checkReaper: Found completed pod older than:", cfg.MaxCheckPodAge, "in status `Succeeded`. Deleting pod:", n)
 
 			err = k.deletePod(ctx, v)
@@ -198,7 +219,7 @@ func (k *KubernetesAPI) deleteFilteredCheckerPods(ctx context.Context, client *k
 		}
 
 		// Delete failed pods (status Failed) older than maxCheckPodAge
-		if v.Status.Phase == v1.PodFailed && time.Now().Sub(podTerminatedTime) > cfg.MaxCheckPodAge {
+		if v.Status.Phase == v1.PodFailed && time.Now().Sub(podTerminatedTime) > cfg.MaxCheckPodAge.Duration {
 			log.Infoln("checkReaper: Found completed pod older than:", cfg.MaxCheckPodAge, "in status `Failed`. Deleting pod:", n)
 
 			err = k.deletePod(ctx, v)
@@ -319,7 +340,7 @@ func khJobDelete(client *khjobv1.KHJobV1Client) error {
 
 	// Range over list and delete khjobs
 	for _, j := range list.Items {
-		if jobConditions(j, cfg.MaxKHJobAge, "Completed") {
+		if jobConditions(j, cfg.MaxKHJobAge.Duration, "Completed") {
 			log.Infoln("checkReaper: Deleting khjob", j.Name)
 			err := client.KuberhealthyJobs(j.Namespace).Delete(j.Name, &del)
 			if err != nil {
@@ -10,6 +10,8 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
 )
 
 // TestParseConfigs ensures that all checkReaper configs are properly parsed and that there are no 0 duration values
@@ -48,6 +50,7 @@ func TestParseDurationOrUseDefault(t *testing.T) {
 		{"Valid duration", "5m", time.Minute * 15, time.Minute * 5, ""},
 		{"0 Duration value, not allowed", "0", time.Minute * 15, time.Minute * 15, ""},
 		{"No duration value", "", time.Minute * 15, time.Minute * 15, ""},
+		{"Duration in seconds", "90", time.Minute * 15, time.Second * 90, ""},
 	}
 
 	for _, test := range testCases {
@@ -229,3 +232,33 @@ func TestGetAllPodsWithCheckName(t *testing.T) {
 }
 
 //TODO: TestDeleteFilteredCheckerPods
+
+// TestRunNamespaceReap ensures only run namespaces left behind past their deadline are deleted
+func TestRunNamespaceReap(t *testing.T) {
+	runNamespace := func(name string, deadline time.Time) *v1.Namespace {
+		return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{external.RunNamespaceLabel: "kuberhealthy"},
+			Annotations: map[string]string{external.RunNamespaceDeadlineAnnotation: deadline.Format(time.RFC3339)},
+		}}
+	}
+	client := fake.NewSimpleClientset(
+		runNamespace("kh-run-expired-abc", time.Now().Add(-time.Minute)),
+		runNamespace("kh-run-running-def", time.Now().Add(time.Minute)),
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+	)
+
+	runNamespaceReap(context.Background(), client)
+
+	namespaces, err := client.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list namespaces: %s", err)
+	}
+	var remaining []string
+	for _, ns := range namespaces.Items {
+		remaining = append(remaining, ns.Name)
+	}
+	if len(remaining) != 2 || remaining[0] != "default" || remaining[1] != "kh-run-running-def" {
+		t.Fatalf("remaining namespaces were %v but expected [default kh-run-running-def]", remaining)
+	}
+}
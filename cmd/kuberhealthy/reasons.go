@@ -0,0 +1,48 @@
+package main
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// reportedFailureReason returns the failure reason of a report sent in by a checker pod
+func reportedFailureReason(state status.Report) khstatev1.FailureReason {
+	if state.OK {
+		return ""
+	}
+	return khstatev1.ReasonReportedErrors
+}
+
+// carriedFailureReason returns the failure reason of a run that finished without an execution error.  Its result was
+// reported into its khstate, which already holds the reason for it.
+func carriedFailureReason(ok bool, reported khstatev1.FailureReason) khstatev1.FailureReason {
+	if ok {
+		return ""
+	}
+	if len(reported) == 0 {
+		return khstatev1.ReasonReportedErrors
+	}
+	return reported
+}
+
+// recordCanceledRun records a run that was canceled before it finished in the run history of its khstate.  The
+// current state belongs to the run that replaced it, so it is left alone.  Runs that reported before they were
+// canceled are already in the history.
+func (k *Kuberhealthy) recordCanceledRun(checkName string, checkNamespace string, uuid string, runErr error) {
+	if len(uuid) == 0 {
+		return
+	}
+	if run, known := k.runTracker.Get(uuid); known && run.State == external.RunReported {
+		return
+	}
+	entry := khstatev1.NewRunHistoryEntry(uuid, false, []string{"Check execution error: " + runErr.Error()}, false)
+	entry.Result = khstatev1.RunCanceled
+	entry.Reason = khstatev1.ReasonCancelled
+	err := appendRunHistory(checkName, checkNamespace, entry)
+	if err != nil {
+		log.Errorln("Error recording canceled run", uuid, "of check", checkName, "in namespace", checkNamespace+":", err)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestReportedFailureReason ensures only failed reports are given a reason
+func TestReportedFailureReason(t *testing.T) {
+	if reason := reportedFailureReason(status.NewReport(nil)); len(reason) != 0 {
+		t.Fatalf("expected no reason for a passing report but got %q", reason)
+	}
+	if reason := reportedFailureReason(status.NewReport([]string{"failed"})); reason != khstatev1.ReasonReportedErrors {
+		t.Fatalf("expected %q for a failed report but got %q", khstatev1.ReasonReportedErrors, reason)
+	}
+}
+
+// TestCarriedFailureReason ensures runs that finished keep the reason stored by their report
+func TestCarriedFailureReason(t *testing.T) {
+	if reason := carriedFailureReason(true, khstatev1.ReasonTimedOut); len(reason) != 0 {
+		t.Fatalf("expected no reason for a passing run but got %q", reason)
+	}
+	if reason := carriedFailureReason(false, ""); reason != khstatev1.ReasonReportedErrors {
+		t.Fatalf("expected a failed run without a stored reason to have reported errors but got %q", reason)
+	}
+	if reason := carriedFailureReason(false, khstatev1.ReasonReportedErrors); reason != khstatev1.ReasonReportedErrors {
+		t.Fatalf("expected the stored reason to be kept but got %q", reason)
+	}
+}
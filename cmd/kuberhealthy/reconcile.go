@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// reconcileResult counts what happened to the checker pods found when becoming master
+type reconcileResult struct {
+	Adopted int // pods of configured checks whose runs will be resumed
+	Kept    int // pods of khjobs that are left to finish
+	Reaped  int // pods that no khstate expects any more
+}
+
+// reconcileCheckerPods looks at the checker pods that are still pending or running.  A pod is adopted when it belongs
+// to a configured check whose khstate still expects the pod's run UUID, or whose run is in flight in the run tracker,
+// so that the check resumes the run and its deadline instead of starting over.  Runs in flight include those restored
+// from the run store, such as overlapping runs allowed by the Allow concurrency policy, which a khstate can't list.
+// Pods of khjobs are left to finish.  Every other checker pod is reaped.  expectedRuns maps the namespace/name of each
+// khstate to the run UUID it expects and checks holds the namespace/name of every configured check.
+func reconcileCheckerPods(ctx context.Context, client kubernetes.Interface, namespace string, expectedRuns map[string]string, checks map[string]bool, runs *external.RunTracker) (reconcileResult, error) {
+	var result reconcileResult
+
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: "kuberhealthy-run-id"})
+	if err != nil {
+		return result, fmt.Errorf("failed to list checker pods: %w", err)
+	}
+
+	inFlight := make(map[string]bool)
+	for _, r := range runs.InFlight() {
+		inFlight[r.UUID] = true
+	}
+
+	api := KubernetesAPI{Client: client}
+	for _, p := range pods.Items {
+		if p.Status.Phase != v1.PodPending && p.Status.Phase != v1.PodRunning {
+			continue
+		}
+		run, ok := external.RunFromPod(p)
+		if !ok {
+			continue
+		}
+
+		key := run.Namespace + "/" + run.CheckName
+		if expectedRuns[key] == run.UUID || inFlight[run.UUID] {
+			if checks[key] {
+				log.Infoln("reconcile: Adopting checker pod", p.Namespace+"/"+p.Name, "of run", run.UUID, "with deadline", run.Deadline)
+				runs.Adopt(run)
+				result.Adopted++
+				continue
+			}
+			log.Infoln("reconcile: Leaving job pod", p.Namespace+"/"+p.Name, "of run", run.UUID, "to finish")
+			result.Kept++
+			continue
+		}
+
+		log.Infoln("reconcile: Reaping checker pod", p.Namespace+"/"+p.Name, "of run", run.UUID, "that is no longer expected")
+		err = api.deletePod(ctx, p)
+		if err != nil {
+			log.Errorln("reconcile: Failed to reap checker pod", p.Namespace+"/"+p.Name+":", err)
+			continue
+		}
+		result.Reaped++
+	}
+
+	return result, nil
+}
+
+// reconcileRuns adopts and reaps the checker pods left behind by the previous master before checks start
+func (k *Kuberhealthy) reconcileRuns(ctx context.Context) {
+	states, err := khStateClient.KuberhealthyStates(cfg.ListenNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		log.Errorln("reconcile: Failed to list khstates:", err)
+		return
+	}
+	expectedRuns := make(map[string]string)
+	for _, s := range states.Items {
+		expectedRuns[s.Namespace+"/"+s.Name] = s.Spec.CurrentUUID
+	}
+
+	checks := make(map[string]bool)
+	for _, c := range k.Checks {
+		checks[c.CheckNamespace()+"/"+c.Name()] = true
+	}
+
+	result, err := reconcileCheckerPods(ctx, kubernetesClient, cfg.ListenNamespace, expectedRuns, checks, k.runTracker)
+	if err != nil {
+		log.Errorln("reconcile:", err)
+		return
+	}
+	log.Infoln("reconcile: Adopted", result.Adopted, "checker pods, left", result.Kept, "job pods to finish and reaped", result.Reaped, "checker pods")
+}
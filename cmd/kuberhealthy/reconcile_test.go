@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// testCheckerPod makes a checker pod for a run of a check
+func testCheckerPod(name string, checkName string, uuid string, phase v1.PodPhase, deadline time.Time) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "kuberhealthy",
+			Labels:    map[string]string{"kuberhealthy-run-id": uuid, "kuberhealthy-check-name": checkName},
+		},
+		Spec: v1.PodSpec{Containers: []v1.Container{{
+			Name: "main",
+			Env:  []v1.EnvVar{{Name: external.KHDeadline, Value: strconv.FormatInt(deadline.Unix(), 10)}},
+		}}},
+		Status: v1.PodStatus{Phase: phase},
+	}
+}
+
+// TestReconcileCheckerPods ensures expected runs are adopted, job pods are kept and unknown pods are reaped
+func TestReconcileCheckerPods(t *testing.T) {
+	deadline := time.Now().Add(time.Minute).Truncate(time.Second)
+	client := fake.NewSimpleClientset(
+		testCheckerPod("deployment-1", "deployment", "current", v1.PodRunning, deadline),
+		testCheckerPod("deployment-0", "deployment", "previous", v1.PodRunning, deadline),
+		testCheckerPod("overlap-1", "overlap", "overlapping", v1.PodRunning, deadline),
+		testCheckerPod("overlap-0", "overlap", "restored", v1.PodRunning, deadline),
+		testCheckerPod("dns-0", "dns", "old", v1.PodSucceeded, deadline),
+		testCheckerPod("upgrade-1", "upgrade", "job-run", v1.PodPending, deadline),
+		testCheckerPod("removed-1", "removed", "orphan", v1.PodPending, deadline),
+	)
+
+	expectedRuns := map[string]string{
+		"kuberhealthy/deployment": "current",
+		"kuberhealthy/dns":        "new",
+		"kuberhealthy/upgrade":    "job-run",
+		"kuberhealthy/overlap":    "overlapping",
+	}
+	checks := map[string]bool{"kuberhealthy/deployment": true, "kuberhealthy/dns": true, "kuberhealthy/overlap": true}
+	runs := external.NewRunTracker()
+
+	// an earlier run of a check that allows overlapping runs is in flight in the run tracker but not in its khstate
+	runs.Adopt(external.Run{UUID: "restored", CheckName: "overlap", Namespace: "kuberhealthy", Deadline: deadline, State: external.RunRunning})
+
+	result, err := reconcileCheckerPods(context.Background(), client, "", expectedRuns, checks, runs)
+	if err != nil {
+		t.Fatalf("failed to reconcile checker pods: %s", err)
+	}
+	if result != (reconcileResult{Adopted: 3, Kept: 1, Reaped: 2}) {
+		t.Fatalf("unexpected reconcile result: %+v", result)
+	}
+
+	run, ok := runs.Resume("deployment", "kuberhealthy", time.Now())
+	if !ok || run.UUID != "current" || run.PodName != "deployment-1" || !run.Deadline.Equal(deadline) {
+		t.Fatalf("adopted run was not resumable with its deadline: %+v", run)
+	}
+
+	pods, err := client.CoreV1().Pods("kuberhealthy").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list pods: %s", err)
+	}
+	remaining := make(map[string]bool)
+	for _, p := range pods.Items {
+		remaining[p.Name] = true
+	}
+	for name, expected := range map[string]bool{"deployment-1": true, "deployment-0": false, "dns-0": true, "upgrade-1": true, "removed-1": false, "overlap-0": true, "overlap-1": true} {
+		if remaining[name] != expected {
+			t.Fatalf("pod %s remaining was %t but expected %t", name, remaining[name], expected)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"strings"
+	"sync/atomic"
 	"time"
 
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -10,6 +11,8 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	khjobv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khjob/v1"
 	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/health"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
@@ -17,25 +20,46 @@ import (
 
 // StateReflector watches the state of khstate objects and stores them in a local cache.  Then, when the current
 // state of checks is requested, the CurrentStatus func can serve it rapidly from cache.  Needs to run in the
-// background and can be stopped/started by simply calling `Stop()` on it.
+// background and can be stopped/started by simply calling `Stop()` on it.  The khchecks and khjobs are cached as well
+// so that every replica, not just the master, can serve the status page without calling the API on each request.
 type StateReflector struct {
 	reflector        *cache.Reflector
 	reflectorSigChan chan struct{} // the channel that indicates when the cache sync should stop
 	resyncPeriod     time.Duration // the period for full API re-syncs
 	store            cache.Store
+	checkReflector   *cache.Reflector // caches khchecks so that khstates can be told apart from khjob states
+	checkStore       cache.Store
+	jobReflector     *cache.Reflector // caches khjobs so that khstates can be told apart from khcheck states
+	jobStore         cache.Store
+	snapshot         atomic.Pointer[health.State] // the status built from the caches after they last changed
+	snapshotSignal   chan struct{}                // signals that the caches changed and the snapshot must be rebuilt
+	changes          *stateChangeLog              // numbers the changes to khstates for status delta cursors
 }
 
+// snapshotRetryDelay is how long the snapshot waits to be built again when the caches changed before they synced
+const snapshotRetryDelay = time.Millisecond * 250
+
 // NewStateReflector creates a new StateReflector for watching the state of khstate resources on the server
 func NewStateReflector() *StateReflector {
 	sr := StateReflector{}
 	sr.reflectorSigChan = make(chan struct{})
 	sr.resyncPeriod = time.Minute * 5
+	sr.snapshotSignal = make(chan struct{}, 1)
 
 	// structure the reflector and its required elements
 	khStateListWatch := cache.NewListWatchFromClient(khStateClient.RESTClient(), stateCRDResource, cfg.ListenNamespace, fields.Everything())
-	sr.store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	sr.changes = newStateChangeLog()
+	sr.store = &changeLoggingStore{Store: sr.newStore(), changes: sr.changes}
 	sr.reflector = cache.NewReflector(khStateListWatch, &khstatev1.KuberhealthyState{}, sr.store, sr.resyncPeriod)
 
+	khCheckListWatch := cache.NewListWatchFromClient(khCheckClient.RESTClient(), checkCRDResource, cfg.ListenNamespace, fields.Everything())
+	sr.checkStore = sr.newStore()
+	sr.checkReflector = cache.NewReflector(khCheckListWatch, &khcheckv1.KuberhealthyCheck{}, sr.checkStore, sr.resyncPeriod)
+
+	khJobListWatch := cache.NewListWatchFromClient(khJobClient.RESTClient(), jobCRDResource, cfg.ListenNamespace, fields.Everything())
+	sr.jobStore = sr.newStore()
+	sr.jobReflector = cache.NewReflector(khJobListWatch, &khjobv1.KuberhealthyJob{}, sr.jobStore, sr.resyncPeriod)
+
 	return &sr
 }
 
@@ -47,14 +71,173 @@ func (sr *StateReflector) Stop() {
 	}
 }
 
-// Start begins the store and resync operations in the background
+// Start begins the store and resync operations in the background.  The khcheck and khjob caches stop along with
+// the khstate cache.
 func (sr *StateReflector) Start() {
 	log.Infoln("khState reflector starting")
+	workloadSigChan := make(chan struct{})
+	defer close(workloadSigChan)
+	if sr.snapshotSignal != nil {
+		go sr.maintainSnapshot(workloadSigChan)
+	}
+	if sr.checkReflector != nil {
+		go sr.checkReflector.Run(workloadSigChan)
+	}
+	if sr.jobReflector != nil {
+		go sr.jobReflector.Run(workloadSigChan)
+	}
 	sr.reflector.Run(sr.reflectorSigChan)
 }
 
-// CurrentStatus returns the current summary of checks as known by the cache.
+// HasSynced returns true once the khstate cache has been filled from the API.  Until then the status served from
+// the cache would be incomplete.
+func (sr *StateReflector) HasSynced() bool {
+	if sr.reflector == nil || len(sr.reflector.LastSyncResourceVersion()) == 0 {
+		return false
+	}
+	return sr.workloadsSynced()
+}
+
+// workloadsSynced returns true once the khcheck and khjob caches have been filled from the API
+func (sr *StateReflector) workloadsSynced() bool {
+	if sr.checkReflector == nil || sr.jobReflector == nil {
+		return false
+	}
+	return len(sr.checkReflector.LastSyncResourceVersion()) > 0 && len(sr.jobReflector.LastSyncResourceVersion()) > 0
+}
+
+// workloadOf determines whether a khstate belongs to a khcheck or a khjob from the cached khchecks and khjobs.  The
+// API is asked instead until the caches have synced.
+func (sr *StateReflector) workloadOf(name string, namespace string) khstatev1.KHWorkload {
+	if !sr.workloadsSynced() {
+		return determineKHWorkload(name, namespace)
+	}
+
+	key := namespace + "/" + name
+	if _, exists, _ := sr.checkStore.GetByKey(key); exists {
+		return khstatev1.KHCheck
+	}
+	if _, exists, _ := sr.jobStore.GetByKey(key); exists {
+		return khstatev1.KHJob
+	}
+	return ""
+}
+
+// workloadResolver returns a func that determines whether a khstate belongs to a khcheck or a khjob.  Until the
+// khcheck and khjob caches have synced, they are listed from the API once on first use instead of being fetched for
+// each khstate, which keeps status requests served while a replica starts up from making thousands of API calls.
+func (sr *StateReflector) workloadResolver() func(name string, namespace string) khstatev1.KHWorkload {
+	resolve := sr.khWorkloadResolver()
+
+	// self-check khstates have no khcheck behind them, but are shown as checks
+	return func(name string, namespace string) khstatev1.KHWorkload {
+		if isSelfCheckState(name, namespace) {
+			return khstatev1.KHCheck
+		}
+		return resolve(name, namespace)
+	}
+}
+
+// khWorkloadResolver returns a function that looks up whether a khstate belongs to a khcheck or a khjob
+func (sr *StateReflector) khWorkloadResolver() func(name string, namespace string) khstatev1.KHWorkload {
+	if sr.workloadsSynced() {
+		return sr.workloadOf
+	}
+
+	var workloads map[string]khstatev1.KHWorkload
+	var listed bool
+	return func(name string, namespace string) khstatev1.KHWorkload {
+		if !listed {
+			listed = true
+			var err error
+			workloads, err = listKHWorkloads()
+			if err != nil {
+				log.Warningln("khState reflector failed to list khchecks and khjobs, fetching them for each khstate instead:", err)
+			}
+		}
+		if workloads == nil {
+			return determineKHWorkload(name, namespace)
+		}
+		return workloads[namespace+"/"+name]
+	}
+}
+
+// listKHWorkloads lists the khchecks and khjobs from the API and indexes their workload type by namespace/name
+var listKHWorkloads = func() (map[string]khstatev1.KHWorkload, error) {
+	checks, err := khCheckClient.KuberhealthyChecks(cfg.ListenNamespace).List(v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := khJobClient.KuberhealthyJobs(cfg.ListenNamespace).List(v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	workloads := make(map[string]khstatev1.KHWorkload, len(checks.Items)+len(jobs.Items))
+	for _, c := range checks.Items {
+		workloads[c.Namespace+"/"+c.Name] = khstatev1.KHCheck
+	}
+	for _, j := range jobs.Items {
+		workloads[j.Namespace+"/"+j.Name] = khstatev1.KHJob
+	}
+	return workloads, nil
+}
+
+// newStore creates a cache store.  Changes to stores of reflectors that keep a snapshot cause the snapshot to be
+// rebuilt.
+func (sr *StateReflector) newStore() cache.Store {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	if sr.snapshotSignal == nil {
+		return store
+	}
+	return &notifyingStore{Store: store, notify: sr.invalidateSnapshot}
+}
+
+// invalidateSnapshot asks for the snapshot to be rebuilt.  Changes that arrive while a rebuild is already pending are
+// folded into it, so bursts of reports cause one rebuild instead of one per report.
+func (sr *StateReflector) invalidateSnapshot() {
+	select {
+	case sr.snapshotSignal <- struct{}{}:
+	default:
+	}
+}
+
+// maintainSnapshot rebuilds the snapshot each time the caches change until the stop channel is closed.  The snapshot
+// is dropped when the caches stop so that a stale status is never served.
+func (sr *StateReflector) maintainSnapshot(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			sr.snapshot.Store(nil)
+			return
+		case <-sr.snapshotSignal:
+		}
+
+		// khstates can only be told apart from each other once the khcheck and khjob caches are filled
+		if !sr.HasSynced() {
+			time.AfterFunc(snapshotRetryDelay, sr.invalidateSnapshot)
+			continue
+		}
+
+		state := sr.buildStatus()
+		// appending to the errors of the shared snapshot must copy them instead of writing into its array
+		state.Errors = state.Errors[:len(state.Errors):len(state.Errors)]
+		sr.snapshot.Store(&state)
+	}
+}
+
+// CurrentStatus returns the current summary of checks as known by the cache.  Once the caches have synced this is
+// a snapshot that is rebuilt whenever they change, so status requests do not each walk the caches.  The maps of the
+// returned state are shared between callers and must not be modified.
 func (sr *StateReflector) CurrentStatus() health.State {
+	if snapshot := sr.snapshot.Load(); snapshot != nil {
+		return *snapshot
+	}
+	return sr.buildStatus()
+}
+
+// buildStatus builds the current summary of checks from the caches
+func (sr *StateReflector) buildStatus() health.State {
 	log.Infoln("khState reflector fetching current status")
 	state := health.NewState()
 
@@ -66,6 +249,7 @@ func (sr *StateReflector) CurrentStatus() health.State {
 
 	// list all objects from the storage cache
 	khStateList := sr.store.List()
+	workloadOf := sr.workloadResolver()
 	for i, khStateUndefined := range khStateList {
 		log.Debugln("state reflector store item from listing:", i, khStateUndefined)
 		khState, ok := khStateUndefined.(*khstatev1.KuberhealthyState)
@@ -94,12 +278,25 @@ func (sr *StateReflector) CurrentStatus() health.State {
 			state.OK = false
 		}
 
-		khWorkload := determineKHWorkload(khState.Name, khState.Namespace)
+		// warnings are shown apart from errors and leave the global OK state alone
+		for _, w := range khState.Spec.Warnings {
+			if len(strings.TrimSpace(w)) == 0 {
+				log.Warningln("Skipped a warning that was blank when adding check details to current state.")
+				continue
+			}
+			state.AddWarning(w)
+		}
+
+		khWorkload := workloadOf(khState.Name, khState.Namespace)
 		switch khWorkload {
 		case khstatev1.KHCheck:
-			state.CheckDetails[khState.GetNamespace()+"/"+khState.GetName()] = khState.Spec
+			details := withoutArtifacts(khState.Spec)
+			details.State = resultState(details)
+			state.CheckDetails[khState.GetNamespace()+"/"+khState.GetName()] = details
 		case khstatev1.KHJob:
-			state.JobDetails[khState.GetNamespace()+"/"+khState.GetName()] = khState.Spec
+			details := withoutArtifacts(khState.Spec)
+			details.State = resultState(details)
+			state.JobDetails[khState.GetNamespace()+"/"+khState.GetName()] = details
 		}
 	}
 
@@ -107,6 +304,37 @@ func (sr *StateReflector) CurrentStatus() health.State {
 	return state
 }
 
+// States returns the khstate resources currently in the cache
+func (sr *StateReflector) States() []*khstatev1.KuberhealthyState {
+	var states []*khstatev1.KuberhealthyState
+	if sr.store == nil {
+		return states
+	}
+
+	for _, item := range sr.store.List() {
+		khState, ok := item.(*khstatev1.KuberhealthyState)
+		if !ok {
+			log.Warningln("attempted to convert item from state cache reflector to a khstatev1.KuberhealthyState, but the type was invalid")
+			continue
+		}
+		states = append(states, khState)
+	}
+	return states
+}
+
+// State returns the khstate with the supplied namespace and name from the cache
+func (sr *StateReflector) State(namespace string, name string) (*khstatev1.KuberhealthyState, bool) {
+	if sr.store == nil {
+		return nil, false
+	}
+	item, exists, err := sr.store.GetByKey(namespace + "/" + name)
+	if err != nil || !exists {
+		return nil, false
+	}
+	khState, ok := item.(*khstatev1.KuberhealthyState)
+	return khState, ok
+}
+
 // determineKHWorkload uses the name and namespace of the kuberhealthy resource to determine whether its a khjob or khcheck
 // This function is necessary for the CurrentStatus() function as getting the KHWorkload from the state spec returns a blank kh workload.
 func determineKHWorkload(name string, namespace string) khstatev1.KHWorkload {
@@ -135,3 +363,37 @@ func determineKHWorkload(name string, namespace string) khstatev1.KHWorkload {
 	}
 	return khWorkload
 }
+
+// notifyingStore is a cache store that calls notify after every change to its contents
+type notifyingStore struct {
+	cache.Store
+	notify func()
+}
+
+// Add adds an object to the store and notifies of the change
+func (s *notifyingStore) Add(obj interface{}) error {
+	err := s.Store.Add(obj)
+	s.notify()
+	return err
+}
+
+// Update updates an object in the store and notifies of the change
+func (s *notifyingStore) Update(obj interface{}) error {
+	err := s.Store.Update(obj)
+	s.notify()
+	return err
+}
+
+// Delete deletes an object from the store and notifies of the change
+func (s *notifyingStore) Delete(obj interface{}) error {
+	err := s.Store.Delete(obj)
+	s.notify()
+	return err
+}
+
+// Replace replaces the contents of the store and notifies of the change
+func (s *notifyingStore) Replace(list []interface{}, resourceVersion string) error {
+	err := s.Store.Replace(list, resourceVersion)
+	s.notify()
+	return err
+}
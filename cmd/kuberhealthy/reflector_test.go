@@ -10,6 +10,8 @@ import (
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/tools/cache"
 
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	khjobv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khjob/v1"
 	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
 )
 
@@ -91,3 +93,173 @@ func TestStop(t *testing.T) {
 		break
 	}
 }
+
+// TestWorkloadOf ensures khstates are matched to khchecks and khjobs from the caches once they have synced
+func TestWorkloadOf(t *testing.T) {
+	khStateReflector := makeTestStateReflector(watch.NewFake())
+
+	check := khcheckv1.NewKuberhealthyCheck("deployment", "kuberhealthy", khcheckv1.CheckConfig{})
+	checkLW := &testLW{
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &khcheckv1.KuberhealthyCheckList{ListMeta: metav1.ListMeta{ResourceVersion: "1"}, Items: []khcheckv1.KuberhealthyCheck{check}}, nil
+		},
+	}
+	khStateReflector.checkStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	khStateReflector.checkReflector = cache.NewReflector(checkLW, &khcheckv1.KuberhealthyCheck{}, khStateReflector.checkStore, khStateReflector.resyncPeriod)
+
+	job := khjobv1.NewKuberhealthyJob("upgrade", "kuberhealthy", khjobv1.JobConfig{})
+	jobLW := &testLW{
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &khjobv1.KuberhealthyJobList{ListMeta: metav1.ListMeta{ResourceVersion: "1"}, Items: []khjobv1.KuberhealthyJob{job}}, nil
+		},
+	}
+	khStateReflector.jobStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	khStateReflector.jobReflector = cache.NewReflector(jobLW, &khjobv1.KuberhealthyJob{}, khStateReflector.jobStore, khStateReflector.resyncPeriod)
+
+	go khStateReflector.Start()
+	defer close(khStateReflector.reflectorSigChan)
+
+	err := wait.PollImmediate(time.Millisecond*10, wait.ForeverTestTimeout, func() (bool, error) {
+		return khStateReflector.HasSynced(), nil
+	})
+	if err != nil {
+		t.Fatalf("khstate reflector caches did not sync: %s", err)
+	}
+
+	var testCases = []struct {
+		name     string
+		expected khstatev1.KHWorkload
+	}{
+		{"deployment", khstatev1.KHCheck},
+		{"upgrade", khstatev1.KHJob},
+		{"removed", ""},
+	}
+	for _, tc := range testCases {
+		workload := khStateReflector.workloadOf(tc.name, "kuberhealthy")
+		if workload != tc.expected {
+			t.Fatalf("khstate %s was determined to be %q but expected %q", tc.name, workload, tc.expected)
+		}
+	}
+}
+
+// TestCurrentStatusSnapshot ensures the current status is served from a snapshot that follows changes to the caches
+func TestCurrentStatusSnapshot(t *testing.T) {
+	sr := &StateReflector{reflectorSigChan: make(chan struct{}), resyncPeriod: time.Minute * 5}
+	sr.snapshotSignal = make(chan struct{}, 1)
+
+	states := watch.NewFake()
+	sr.store = sr.newStore()
+	sr.reflector = cache.NewReflector(&testLW{
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return states, nil
+		},
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &khstatev1.KuberhealthyStateList{ListMeta: metav1.ListMeta{ResourceVersion: "1"}}, nil
+		},
+	}, &khstatev1.KuberhealthyState{}, sr.store, sr.resyncPeriod)
+
+	check := khcheckv1.NewKuberhealthyCheck("deployment", "kuberhealthy", khcheckv1.CheckConfig{})
+	sr.checkStore = sr.newStore()
+	sr.checkReflector = cache.NewReflector(&testLW{
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &khcheckv1.KuberhealthyCheckList{ListMeta: metav1.ListMeta{ResourceVersion: "1"}, Items: []khcheckv1.KuberhealthyCheck{check}}, nil
+		},
+	}, &khcheckv1.KuberhealthyCheck{}, sr.checkStore, sr.resyncPeriod)
+
+	sr.jobStore = sr.newStore()
+	sr.jobReflector = cache.NewReflector(&testLW{
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &khjobv1.KuberhealthyJobList{ListMeta: metav1.ListMeta{ResourceVersion: "1"}}, nil
+		},
+	}, &khjobv1.KuberhealthyJob{}, sr.jobStore, sr.resyncPeriod)
+
+	go sr.Start()
+
+	err := wait.PollImmediate(time.Millisecond*10, wait.ForeverTestTimeout, func() (bool, error) {
+		return sr.snapshot.Load() != nil, nil
+	})
+	if err != nil {
+		t.Fatal("no snapshot was built once the caches synced")
+	}
+
+	state := khstatev1.NewKuberhealthyState("deployment", khstatev1.WorkloadDetails{
+		OK:               false,
+		Errors:           []string{"deployment failed"},
+		AuthoritativePod: "kuberhealthy-abc",
+	})
+	state.SetNamespace("kuberhealthy")
+	state.SetResourceVersion("2")
+	states.Add(&state)
+	err = wait.PollImmediate(time.Millisecond*10, wait.ForeverTestTimeout, func() (bool, error) {
+		_, ok := sr.CurrentStatus().CheckDetails["kuberhealthy/deployment"]
+		return ok, nil
+	})
+	if err != nil {
+		t.Fatal("the snapshot was not rebuilt when a khstate was added")
+	}
+
+	// callers adding errors to the shared snapshot must not change it
+	current := sr.CurrentStatus()
+	current.AddError("added by a caller")
+	if len(sr.CurrentStatus().Errors) != 1 || sr.CurrentStatus().OK {
+		t.Fatalf("unexpected snapshot: %+v", sr.CurrentStatus())
+	}
+
+	close(sr.reflectorSigChan)
+	err = wait.PollImmediate(time.Millisecond*10, wait.ForeverTestTimeout, func() (bool, error) {
+		return sr.snapshot.Load() == nil, nil
+	})
+	if err != nil {
+		t.Fatal("the snapshot was kept after the caches stopped")
+	}
+}
+
+// TestWorkloadResolver ensures that khstates are matched to khchecks and khjobs from a single listing before the
+// caches have synced
+func TestWorkloadResolver(t *testing.T) {
+	var lists int
+	defer func(original func() (map[string]khstatev1.KHWorkload, error)) {
+		listKHWorkloads = original
+	}(listKHWorkloads)
+	listKHWorkloads = func() (map[string]khstatev1.KHWorkload, error) {
+		lists++
+		return map[string]khstatev1.KHWorkload{
+			"kuberhealthy/deployment": khstatev1.KHCheck,
+			"kuberhealthy/upgrade":    khstatev1.KHJob,
+		}, nil
+	}
+
+	khStateReflector := makeTestStateReflector(watch.NewFake())
+	for _, name := range []string{"deployment", "upgrade", "removed"} {
+		state := khstatev1.NewKuberhealthyState(name, khstatev1.WorkloadDetails{})
+		state.Namespace = "kuberhealthy"
+		state.Spec.AuthoritativePod = "kuberhealthy-1"
+		err := khStateReflector.store.Add(&state)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	status := khStateReflector.CurrentStatus()
+	if lists != 1 {
+		t.Fatalf("expected khchecks and khjobs to be listed once but they were listed %d times", lists)
+	}
+	if _, ok := status.CheckDetails["kuberhealthy/deployment"]; !ok || len(status.CheckDetails) != 1 {
+		t.Fatalf("unexpected check details: %v", status.CheckDetails)
+	}
+	if _, ok := status.JobDetails["kuberhealthy/upgrade"]; !ok || len(status.JobDetails) != 1 {
+		t.Fatalf("unexpected job details: %v", status.JobDetails)
+	}
+}
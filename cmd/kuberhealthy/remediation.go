@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	khremediationv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khremediation/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/duration"
+)
+
+// defaultRemediationCooldown is the minimum time between remediation attempts when a remediation does not set one
+const defaultRemediationCooldown = time.Minute * 30
+
+// defaultRemediationMaxAttempts is the number of remediation attempts made when a remediation does not set a limit
+const defaultRemediationMaxAttempts = 3
+
+// maxRemediationHistory is the number of audit records kept in the status of each remediation
+const maxRemediationHistory = 20
+
+// remediationLabel is the label set on remediation jobs that holds the name of the remediation that created them
+const remediationLabel = "kuberhealthy-remediation"
+
+// remediationCheckRunAnnotation is the annotation set on remediation jobs that holds the UUID of the failed check run
+const remediationCheckRunAnnotation = "comcast.github.io/check-run"
+
+// remediationCooldown returns the cooldown of a remediation, falling back to the default when unset or invalid
+func remediationCooldown(r khremediationv1.KuberhealthyRemediation) time.Duration {
+	cooldown, err := duration.ParseOrDefault(r.Spec.Cooldown, defaultRemediationCooldown)
+	if err != nil {
+		log.Errorln("remediation: invalid cooldown for", r.Namespace+"/"+r.Name+":", err, "Using default cooldown of", defaultRemediationCooldown)
+	}
+	return cooldown
+}
+
+// remediationMaxAttempts returns the max attempts of a remediation, falling back to the default when unset
+func remediationMaxAttempts(r khremediationv1.KuberhealthyRemediation) int {
+	if r.Spec.MaxAttempts <= 0 {
+		return defaultRemediationMaxAttempts
+	}
+	return r.Spec.MaxAttempts
+}
+
+// remediationAction decides what should happen to a remediation given the latest result of its check.  A blank
+// action means nothing should be done.
+func remediationAction(r khremediationv1.KuberhealthyRemediation, checkOK bool, now time.Time) khremediationv1.RemediationAction {
+
+	// a passing check resets the attempts of the remediation so that it can run again next time the check fails
+	if checkOK {
+		if r.Status.Attempts > 0 {
+			return khremediationv1.RemediationReset
+		}
+		return ""
+	}
+
+	if r.Spec.Suspend {
+		return ""
+	}
+
+	// once max attempts are reached, record that the remediation gave up one time only
+	if r.Status.Attempts >= remediationMaxAttempts(r) {
+		if len(r.Status.History) > 0 && r.Status.History[len(r.Status.History)-1].Action == khremediationv1.RemediationExhausted {
+			return ""
+		}
+		return khremediationv1.RemediationExhausted
+	}
+
+	if r.Status.LastAttemptTime != nil && now.Sub(r.Status.LastAttemptTime.Time) < remediationCooldown(r) {
+		return ""
+	}
+
+	return khremediationv1.RemediationTriggered
+}
+
+// recordRemediation applies an audit record to the status of a remediation.  The history is trimmed to the most
+// recent records.
+func recordRemediation(r *khremediationv1.KuberhealthyRemediation, record khremediationv1.RemediationRecord) {
+	switch record.Action {
+	case khremediationv1.RemediationTriggered:
+		r.Status.Attempts++
+		r.Status.LastAttemptTime = record.Time.DeepCopy()
+	case khremediationv1.RemediationFailed:
+		r.Status.LastAttemptTime = record.Time.DeepCopy()
+	case khremediationv1.RemediationReset:
+		r.Status.Attempts = 0
+	}
+
+	r.Status.History = append(r.Status.History, record)
+	if len(r.Status.History) > maxRemediationHistory {
+		r.Status.History = r.Status.History[len(r.Status.History)-maxRemediationHistory:]
+	}
+}
+
+// newRemediationJob creates the job for a remediation attempt from the remediation's job template.  The job is owned
+// by the remediation so that it is cleaned up when the remediation is removed.
+func newRemediationJob(r khremediationv1.KuberhealthyRemediation, checkRun string, now time.Time) *batchv1.Job {
+	template := r.Spec.JobTemplate.DeepCopy()
+
+	name := r.Name
+	suffix := "-" + strconv.FormatInt(now.Unix(), 10)
+	if len(name)+len(suffix) > 63 {
+		name = name[:63-len(suffix)]
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: template.ObjectMeta,
+		Spec:       template.Spec,
+	}
+	job.Name = name + suffix
+	job.Namespace = r.Namespace
+	if job.Labels == nil {
+		job.Labels = make(map[string]string)
+	}
+	job.Labels[remediationLabel] = r.Name
+	if job.Annotations == nil {
+		job.Annotations = make(map[string]string)
+	}
+	job.Annotations[remediationCheckRunAnnotation] = checkRun
+	job.Annotations[KHCheckNameAnnotationKey] = r.Spec.CheckName
+
+	controller := true
+	job.OwnerReferences = append(job.OwnerReferences, metav1.OwnerReference{
+		APIVersion: stateCRDGroup + "/" + stateCRDVersion,
+		Kind:       "KuberhealthyRemediation",
+		Name:       r.Name,
+		UID:        r.UID,
+		Controller: &controller,
+	})
+	if len(job.Spec.Template.Spec.RestartPolicy) == 0 {
+		job.Spec.Template.Spec.RestartPolicy = "Never"
+	}
+	return job
+}
+
+// remediate runs the remediations configured for a check after it stores a new result.  Failing checks trigger a
+// remediation job when the remediation is out of its cooldown and has attempts left.  Passing checks reset the
+// attempts of their remediations.
+func (k *Kuberhealthy) remediate(ctx context.Context, checkName string, namespace string, checkOK bool, checkRun string, checkErrors []string) {
+	if !cfg.EnableRemediation {
+		return
+	}
+
+	remediations, err := khRemediationClient.KuberhealthyRemediations(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		log.Errorln("remediation: failed to list remediations in namespace", namespace+":", err)
+		return
+	}
+
+	for _, r := range remediations.Items {
+		if r.Spec.CheckName != checkName {
+			continue
+		}
+
+		now := time.Now()
+		action := remediationAction(r, checkOK, now)
+		if len(action) == 0 {
+			continue
+		}
+
+		record := runRemediationAction(ctx, kubernetesClient, r, action, checkRun, checkErrors, now)
+		log.Infoln("remediation:", r.Namespace+"/"+r.Name, "for check", checkName, string(record.Action)+":", record.Message)
+
+		recordRemediation(&r, record)
+		_, err = khRemediationClient.KuberhealthyRemediations(r.Namespace).Update(&r)
+		if err != nil {
+			log.Errorln("remediation: failed to record", record.Action, "in the status of", r.Namespace+"/"+r.Name+":", err)
+		}
+	}
+}
+
+// runRemediationAction carries out a remediation action and returns the audit record describing what happened
+func runRemediationAction(ctx context.Context, client kubernetes.Interface, r khremediationv1.KuberhealthyRemediation, action khremediationv1.RemediationAction, checkRun string, checkErrors []string, now time.Time) khremediationv1.RemediationRecord {
+	record := khremediationv1.RemediationRecord{
+		Time:     metav1.NewTime(now),
+		Action:   action,
+		CheckRun: checkRun,
+	}
+
+	switch action {
+	case khremediationv1.RemediationTriggered:
+		record.Errors = checkErrors
+		job := newRemediationJob(r, checkRun, now)
+		_, err := client.BatchV1().Jobs(r.Namespace).Create(ctx, job, metav1.CreateOptions{})
+		if err != nil {
+			record.Action = khremediationv1.RemediationFailed
+			record.Message = fmt.Sprintf("failed to create remediation job %s: %s", job.Name, err)
+			return record
+		}
+		record.JobName = job.Name
+		record.Message = fmt.Sprintf("created remediation job %s (attempt %d of %d)", job.Name, r.Status.Attempts+1, remediationMaxAttempts(r))
+	case khremediationv1.RemediationExhausted:
+		record.Errors = checkErrors
+		record.Message = fmt.Sprintf("reached the maximum of %d attempts. No further jobs will be created until the check passes", remediationMaxAttempts(r))
+	case khremediationv1.RemediationReset:
+		record.Message = "check passed. Attempts were reset"
+	}
+	return record
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	khremediationv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khremediation/v1"
+)
+
+// testRemediation makes a remediation with the supplied attempts and last attempt time
+func testRemediation(attempts int, lastAttempt time.Duration, history ...khremediationv1.RemediationAction) khremediationv1.KuberhealthyRemediation {
+	r := khremediationv1.NewKuberhealthyRemediation("fix-namespace", "kuberhealthy", khremediationv1.RemediationConfig{
+		CheckName:   "namespace-check",
+		Cooldown:    "10m",
+		MaxAttempts: 2,
+	})
+	r.Status.Attempts = attempts
+	if lastAttempt > 0 {
+		t := metav1.NewTime(time.Now().Add(-lastAttempt))
+		r.Status.LastAttemptTime = &t
+	}
+	for _, action := range history {
+		r.Status.History = append(r.Status.History, khremediationv1.RemediationRecord{Action: action})
+	}
+	return r
+}
+
+// TestRemediationAction ensures remediations respect their cooldown, max attempts and suspension
+func TestRemediationAction(t *testing.T) {
+	suspended := testRemediation(0, 0)
+	suspended.Spec.Suspend = true
+
+	var testCases = []struct {
+		description string
+		remediation khremediationv1.KuberhealthyRemediation
+		checkOK     bool
+		expected    khremediationv1.RemediationAction
+	}{
+		{"first failure", testRemediation(0, 0), false, khremediationv1.RemediationTriggered},
+		{"within cooldown", testRemediation(1, time.Minute), false, ""},
+		{"after cooldown", testRemediation(1, time.Hour), false, khremediationv1.RemediationTriggered},
+		{"max attempts reached", testRemediation(2, time.Hour, khremediationv1.RemediationTriggered), false, khremediationv1.RemediationExhausted},
+		{"already exhausted", testRemediation(2, time.Hour, khremediationv1.RemediationExhausted), false, ""},
+		{"suspended", suspended, false, ""},
+		{"check passed after attempts", testRemediation(2, time.Hour), true, khremediationv1.RemediationReset},
+		{"check passing", testRemediation(0, 0), true, ""},
+	}
+
+	for _, tc := range testCases {
+		action := remediationAction(tc.remediation, tc.checkOK, time.Now())
+		if action != tc.expected {
+			t.Fatalf("%s: remediation action was %q but expected %q", tc.description, action, tc.expected)
+		}
+	}
+}
+
+// TestRecordRemediation ensures records update the attempts of a remediation and the history is trimmed
+func TestRecordRemediation(t *testing.T) {
+	r := testRemediation(0, 0)
+	now := metav1.NewTime(time.Now())
+
+	for i := 0; i < maxRemediationHistory+5; i++ {
+		recordRemediation(&r, khremediationv1.RemediationRecord{Time: now, Action: khremediationv1.RemediationTriggered})
+	}
+	if r.Status.Attempts != maxRemediationHistory+5 || r.Status.LastAttemptTime == nil {
+		t.Fatalf("triggered records did not update attempts: %+v", r.Status)
+	}
+	if len(r.Status.History) != maxRemediationHistory {
+		t.Fatalf("history was %d records long but expected %d", len(r.Status.History), maxRemediationHistory)
+	}
+
+	recordRemediation(&r, khremediationv1.RemediationRecord{Time: now, Action: khremediationv1.RemediationReset})
+	if r.Status.Attempts != 0 {
+		t.Fatalf("reset record left attempts at %d", r.Status.Attempts)
+	}
+}
+
+// TestRunRemediationAction ensures remediation jobs are created from the job template and failures are recorded
+func TestRunRemediationAction(t *testing.T) {
+	r := testRemediation(0, 0)
+	r.UID = "remediation-uid"
+	r.Spec.JobTemplate = batchv1.JobTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "fixer"}},
+	}
+	now := time.Now()
+
+	client := fake.NewSimpleClientset()
+	record := runRemediationAction(context.Background(), client, r, khremediationv1.RemediationTriggered, "run-uuid", []string{"namespace stuck"}, now)
+	if record.Action != khremediationv1.RemediationTriggered || len(record.JobName) == 0 {
+		t.Fatalf("remediation job was not created: %+v", record)
+	}
+
+	job, err := client.BatchV1().Jobs("kuberhealthy").Get(context.Background(), record.JobName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get remediation job: %s", err)
+	}
+	if job.Labels["app"] != "fixer" || job.Labels[remediationLabel] != r.Name || job.Annotations[remediationCheckRunAnnotation] != "run-uuid" {
+		t.Fatalf("remediation job metadata was not set correctly: %+v", job.ObjectMeta)
+	}
+	if len(job.OwnerReferences) != 1 || job.OwnerReferences[0].UID != r.UID {
+		t.Fatalf("remediation job is not owned by the remediation: %+v", job.OwnerReferences)
+	}
+	if job.Spec.Template.Spec.RestartPolicy != "Never" {
+		t.Fatalf("remediation job restart policy was %s but expected Never", job.Spec.Template.Spec.RestartPolicy)
+	}
+
+	client.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("quota exceeded")
+	})
+	record = runRemediationAction(context.Background(), client, r, khremediationv1.RemediationTriggered, "run-uuid", nil, now.Add(time.Hour))
+	if record.Action != khremediationv1.RemediationFailed || len(record.Message) == 0 {
+		t.Fatalf("failed job creation was not recorded: %+v", record)
+	}
+}
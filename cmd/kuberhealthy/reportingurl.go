@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// reportingURLPath is the path of the external check reporting endpoint
+const reportingURLPath = "/externalCheckStatus"
+
+// reportingURL builds the reporting URL given to checker pods using the supplied reporting URL mode.  A blank mode
+// falls back to the globally configured mode, which defaults to the kuberhealthy service.
+func reportingURL(mode string) (string, error) {
+	if len(mode) == 0 {
+		mode = cfg.ReportingURLMode
+	}
+
+	switch mode {
+	case "", external.ReportingURLModeService:
+		return cfg.ExternalCheckReportingURL, nil
+
+	case external.ReportingURLModeExternalHostname:
+		host := strings.TrimSuffix(cfg.ExternalReportingHostname, "/")
+		if len(host) == 0 {
+			return "", errors.New("reporting URL mode " + mode + " requires externalReportingHostname to be configured")
+		}
+		if strings.Contains(host, "://") {
+			return host + reportingURLPath, nil
+		}
+		return "http://" + host + reportingURLPath, nil
+
+	case external.ReportingURLModePodIP:
+		ip := net.ParseIP(podIP)
+		if ip == nil {
+			return "", errors.New("reporting URL mode " + mode + " requires the POD_IP environment variable to hold the kuberhealthy pod's IP")
+		}
+		port := "80"
+		_, listenPort, err := net.SplitHostPort(cfg.ListenAddress)
+		if err == nil && len(listenPort) > 0 {
+			port = listenPort
+		}
+		return "http://" + net.JoinHostPort(ip.String(), port) + reportingURLPath, nil
+	}
+
+	return "", errors.New("unknown reporting URL mode " + mode)
+}
+
+// reportingURLOrDefault builds the reporting URL for a workload.  If the URL can not be built, the error is logged
+// and the default reporting URL is used instead.
+func reportingURLOrDefault(mode string, workloadName string) string {
+	url, err := reportingURL(mode)
+	if err != nil {
+		log.Errorln("Failed to build reporting URL for", workloadName, "- using", cfg.ExternalCheckReportingURL, "instead:", err)
+		return cfg.ExternalCheckReportingURL
+	}
+	return url
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// TestReportingURL ensures that reporting URLs are built correctly for every reporting URL mode
+func TestReportingURL(t *testing.T) {
+
+	oldCfg, oldPodIP := cfg, podIP
+	defer func() {
+		cfg, podIP = oldCfg, oldPodIP
+	}()
+
+	serviceURL := "http://kuberhealthy.kuberhealthy.svc.cluster.local/externalCheckStatus"
+	var testCases = []struct {
+		globalMode string
+		checkMode  string
+		hostname   string
+		podIP      string
+		listen     string
+		url        string
+		err        bool
+	}{
+		{"", "", "", "", ":8080", serviceURL, false},
+		{"", external.ReportingURLModeService, "", "", ":8080", serviceURL, false},
+		{"", external.ReportingURLModeExternalHostname, "kuberhealthy.example.com", "", ":8080", "http://kuberhealthy.example.com/externalCheckStatus", false},
+		{external.ReportingURLModeExternalHostname, "", "https://kuberhealthy.example.com/", "", ":8080", "https://kuberhealthy.example.com/externalCheckStatus", false},
+		{external.ReportingURLModeExternalHostname, external.ReportingURLModeService, "kuberhealthy.example.com", "", ":8080", serviceURL, false},
+		{"", external.ReportingURLModeExternalHostname, "", "", ":8080", "", true},
+		{"", external.ReportingURLModePodIP, "", "10.0.0.5", ":8080", "http://10.0.0.5:8080/externalCheckStatus", false},
+		{"", external.ReportingURLModePodIP, "", "fd00:10:244::5", ":8080", "http://[fd00:10:244::5]:8080/externalCheckStatus", false},
+		{"", external.ReportingURLModePodIP, "", "10.0.0.5", "", "http://10.0.0.5:80/externalCheckStatus", false},
+		{"", external.ReportingURLModePodIP, "", "", ":8080", "", true},
+		{"", "carrierPigeon", "", "", ":8080", "", true},
+	}
+
+	for _, tc := range testCases {
+		cfg = &Config{
+			ExternalCheckReportingURL: serviceURL,
+			ReportingURLMode:          tc.globalMode,
+			ExternalReportingHostname: tc.hostname,
+			ListenAddress:             tc.listen,
+		}
+		podIP = tc.podIP
+
+		url, err := reportingURL(tc.checkMode)
+		if (err != nil) != tc.err {
+			t.Fatalf("reportingURL for global mode `%s` and check mode `%s` errored with `%v` but expected error to be %t", tc.globalMode, tc.checkMode, err, tc.err)
+		}
+		if url != tc.url {
+			t.Fatalf("reportingURL for global mode `%s` and check mode `%s` resulted in `%s` but expected `%s`", tc.globalMode, tc.checkMode, url, tc.url)
+		}
+		t.Logf("reportingURL for global mode `%s` and check mode `%s` resulted in `%s` correctly", tc.globalMode, tc.checkMode, url)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestValidateReportVersion ensures v1 and v2 reports are accepted with the fields of their version only
+func TestValidateReportVersion(t *testing.T) {
+	var testCases = []struct {
+		description string
+		report      status.Report
+		expectErr   bool
+	}{
+		{"unversioned report", status.Report{OK: true, Warnings: []string{"expires soon"}}, false},
+		{"v1 report", status.Report{Errors: []string{"failed"}, ReportVersion: status.ReportVersion1}, false},
+		{"v1 report with v2 fields", status.Report{OK: true, Warnings: []string{"expires soon"}, ReportVersion: status.ReportVersion1}, true},
+		{"v2 report", status.Report{OK: true, SubChecks: []status.SubCheck{{Name: "a", OK: true}}, ReportVersion: status.ReportVersion2}, false},
+		{"unknown version", status.Report{OK: true, ReportVersion: 3}, true},
+	}
+	for _, tc := range testCases {
+		err := status.ValidateReportVersion(tc.report)
+		if (err != nil) != tc.expectErr {
+			t.Fatalf("%s: returned error %v but expected an error: %t", tc.description, err, tc.expectErr)
+		}
+	}
+
+	kh := &Kuberhealthy{}
+	code, _, _ := kh.recordReport("test", "test", PodReportInfo{}, status.Report{OK: true, ReportVersion: 3}, false, false)
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected a report of an unknown version to be refused, got code %d", code)
+	}
+}
+
+// TestReportVersionsHeader ensures every answer of the report endpoint tells the client the report versions it accepts
+func TestReportVersionsHeader(t *testing.T) {
+	kh := &Kuberhealthy{}
+	recorder := httptest.NewRecorder()
+	err := kh.externalCheckReportHandler(recorder, httptest.NewRequest(http.MethodHead, "/externalCheckStatus", nil))
+	if err != nil {
+		t.Fatal("Failed to answer report handler probe:", err)
+	}
+	versions := status.ParseReportVersions(recorder.Header().Get(external.KHReportVersionsHeader))
+	if len(versions) != 2 || versions[0] != status.ReportVersion1 || versions[1] != status.ReportVersion2 {
+		t.Fatalf("expected report versions 1 and 2, got header %q", recorder.Header().Get(external.KHReportVersionsHeader))
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// residentCaller looks up the running pod calling a resident endpoint by its source IP
+func (k *Kuberhealthy) residentCaller(ctx context.Context, r *http.Request) (v1.Pod, string, error) {
+	ip, err := remoteIP(r.RemoteAddr)
+	if err != nil {
+		return v1.Pod{}, "", err
+	}
+	pod, err := k.fetchPodBySelector(ctx, podIPSelectorPrefix+ip+",status.phase==Running")
+	return pod, ip, err
+}
+
+// validateResidentRequest validates a request about a run of a resident check.  Resident checkers are long lived,
+// so they are recognized by their registration instead of by the run UUID of their pod.
+func (k *Kuberhealthy) validateResidentRequest(r *http.Request, run external.Run) (PodReportInfo, error) {
+	reportInfo := PodReportInfo{Name: run.CheckName, Namespace: run.Namespace, UUID: run.UUID}
+
+	ip, err := remoteIP(r.RemoteAddr)
+	if err != nil {
+		return reportInfo, err
+	}
+	if !k.residents.IsResident(run.CheckName, run.Namespace, ip, time.Now()) {
+		return reportInfo, fmt.Errorf("%s is not a registered resident checker of check %s/%s", ip, run.Namespace, run.CheckName)
+	}
+
+	whitelisted, err := k.isUUIDWhitelistedForCheck(run.CheckName, run.Namespace, run.UUID)
+	if err != nil {
+		return reportInfo, fmt.Errorf("failed to fetch whitelisted UUID for check with error: %w", err)
+	}
+	if !whitelisted {
+		return reportInfo, k.unlistedRunError(run.CheckName, run.Namespace, run.UUID)
+	}
+	return reportInfo, nil
+}
+
+// residentRegisterHandler registers the calling pod as a resident checker of the khcheck named in the request.  The
+// pod must run in the namespace of the khcheck and carry the check name annotation naming it, so that only pods
+// deployed for the check can take its runs.
+func (k *Kuberhealthy) residentRegisterHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+	requestID := "web: " + getRequestID(r)
+	ctx := r.Context()
+
+	registration := status.ResidentRegistration{}
+	err := json.NewDecoder(r.Body).Decode(&registration)
+	if err != nil || len(registration.Check) == 0 {
+		log.Infoln(requestID, "Failed to decode resident registration from", r.RemoteAddr+":", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+
+	pod, ip, err := k.residentCaller(ctx, r)
+	if err != nil {
+		log.Infoln(requestID, "Failed to look up resident checker pod", r.RemoteAddr+":", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+	if pod.Annotations[KHCheckNameAnnotationKey] != registration.Check {
+		log.Infoln(requestID, "Pod", pod.Namespace+"/"+pod.Name, "tried to register as a resident checker of", registration.Check, "without the", KHCheckNameAnnotationKey, "annotation naming it")
+		w.WriteHeader(http.StatusForbidden)
+		return nil
+	}
+	registration.Namespace = pod.Namespace
+
+	err = k.residents.Register(registration.Check, registration.Namespace, pod.Name, ip, time.Now())
+	if errors.Is(err, external.ErrNotResidentCheck) {
+		log.Infoln(requestID, "Pod", pod.Namespace+"/"+pod.Name, "tried to register for", registration.Namespace+"/"+registration.Check, "which is not a resident check")
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to register resident checker %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+	log.Infoln(requestID, "Registered pod", pod.Namespace+"/"+pod.Name, "as a resident checker of", registration.Namespace+"/"+registration.Check)
+
+	b, err := json.Marshal(registration)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to marshal resident registration: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(b)
+	return err
+}
+
+// residentRunsHandler hands the next run of a resident check to one of its registered resident checkers.  The
+// request is held open for up to external.ResidentPollTimeout while no run is due, and answered with 204 No Content
+// if none came due.  Pods that are not registered are answered with 403 Forbidden so that they register again.
+func (k *Kuberhealthy) residentRunsHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+	checkName := r.URL.Query().Get("check")
+	checkNamespace := r.URL.Query().Get("namespace")
+	if len(checkName) == 0 || len(checkNamespace) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+	ip, err := remoteIP(r.RemoteAddr)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), external.ResidentPollTimeout)
+	defer cancel()
+	run, ok, err := k.residents.Next(ctx, checkName, checkNamespace, ip)
+	if errors.Is(err, external.ErrResidentNotRegistered) {
+		w.WriteHeader(http.StatusForbidden)
+		return nil
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to fetch the next run of resident check %s/%s: %w", checkNamespace, checkName, err)
+	}
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	log.Infoln("Handing run", run.UUID, "of resident check", checkNamespace+"/"+checkName, "to", ip)
+
+	b, err := json.Marshal(run)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to marshal run request: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestResidentRunsHandler ensures runs are only handed to registered resident checkers
+func TestResidentRunsHandler(t *testing.T) {
+	kh := &Kuberhealthy{runTracker: external.NewRunTracker(), residents: external.NewResidentRegistry()}
+	kh.residents.Allow("check", "kuberhealthy")
+	err := kh.residents.Register("check", "kuberhealthy", "resident", "10.0.0.1", time.Now())
+	if err != nil {
+		t.Fatalf("failed to register resident checker: %s", err)
+	}
+	run := status.RunRequest{UUID: "resident-run", Deadline: time.Now().Add(time.Minute).Unix()}
+	err = kh.residents.Offer("check", "kuberhealthy", run)
+	if err != nil {
+		t.Fatalf("failed to offer run: %s", err)
+	}
+
+	var testCases = []struct {
+		name         string
+		method       string
+		query        string
+		remoteAddr   string
+		expectedCode int
+	}{
+		{"wrong method", http.MethodPost, "?check=check&namespace=kuberhealthy", "10.0.0.1:1234", http.StatusMethodNotAllowed},
+		{"missing check", http.MethodGet, "?namespace=kuberhealthy", "10.0.0.1:1234", http.StatusBadRequest},
+		{"unregistered pod", http.MethodGet, "?check=check&namespace=kuberhealthy", "10.0.0.2:1234", http.StatusForbidden},
+		{"registered pod", http.MethodGet, "?check=check&namespace=kuberhealthy", "10.0.0.1:1234", http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		r := httptest.NewRequest(tc.method, "/resident/runs"+tc.query, nil)
+		r.RemoteAddr = tc.remoteAddr
+		w := httptest.NewRecorder()
+		err := kh.residentRunsHandler(w, r)
+		if err != nil {
+			t.Fatalf("%s: handler returned error: %s", tc.name, err)
+		}
+		if w.Code != tc.expectedCode {
+			t.Fatalf("%s: handler returned %d but expected %d", tc.name, w.Code, tc.expectedCode)
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		handed := status.RunRequest{}
+		err = json.NewDecoder(w.Body).Decode(&handed)
+		if err != nil || handed != run {
+			t.Fatalf("%s: handler handed out run %+v (%v) but expected %+v", tc.name, handed, err, run)
+		}
+	}
+}
+
+// TestResidentRegisterHandlerBadRequest ensures malformed registrations are refused before the caller is looked up
+func TestResidentRegisterHandlerBadRequest(t *testing.T) {
+	kh := &Kuberhealthy{runTracker: external.NewRunTracker(), residents: external.NewResidentRegistry()}
+
+	var testCases = []struct {
+		name         string
+		method       string
+		body         []byte
+		expectedCode int
+	}{
+		{"wrong method", http.MethodGet, nil, http.StatusMethodNotAllowed},
+		{"malformed body", http.MethodPost, []byte("{"), http.StatusBadRequest},
+		{"missing check", http.MethodPost, []byte("{}"), http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		r := httptest.NewRequest(tc.method, "/resident/register", bytes.NewReader(tc.body))
+		w := httptest.NewRecorder()
+		err := kh.residentRegisterHandler(w, r)
+		if err != nil {
+			t.Fatalf("%s: handler returned error: %s", tc.name, err)
+		}
+		if w.Code != tc.expectedCode {
+			t.Fatalf("%s: handler returned %d but expected %d", tc.name, w.Code, tc.expectedCode)
+		}
+	}
+}
+
+// TestValidateResidentRequest ensures reports for runs of resident checks are only accepted from their registered
+// resident checkers
+func TestValidateResidentRequest(t *testing.T) {
+	kh := &Kuberhealthy{runTracker: external.NewRunTracker(), residents: external.NewResidentRegistry()}
+	kh.residents.Allow("check", "kuberhealthy")
+	err := kh.residents.Register("check", "kuberhealthy", "resident", "10.0.0.1", time.Now())
+	if err != nil {
+		t.Fatalf("failed to register resident checker: %s", err)
+	}
+	run := external.Run{UUID: "resident-run", CheckName: "check", Namespace: "kuberhealthy"}
+
+	r := httptest.NewRequest(http.MethodPost, "/externalCheckStatus", nil)
+	r.RemoteAddr = "10.0.0.2:1234"
+	_, err = kh.validateResidentRequest(r, run)
+	if err == nil {
+		t.Fatalf("report from a pod that is not a registered resident checker was accepted")
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+)
+
+// lastProbeAnnotation is set on the targets of a khcheck to the result and time of its most recent run, such as
+// pass@2023-06-01T10:00:00Z
+const lastProbeAnnotation = "kuberhealthy.io/last-probe"
+
+// lastProbeCheckAnnotation is set on the targets of a khcheck to the namespace and name of the khcheck whose run is
+// shown in the last probe annotation
+const lastProbeCheckAnnotation = "kuberhealthy.io/last-probe-check"
+
+// exportTarget is a workload that the results of a khcheck are annotated onto
+type exportTarget struct {
+	Namespace string
+	Kind      string
+	Name      string
+}
+
+// String formats an export target as namespace/Kind/name
+func (t exportTarget) String() string {
+	return t.Namespace + "/" + t.Kind + "/" + t.Name
+}
+
+// exportKinds are the kinds of workloads that results can be exported to
+var exportKinds = []string{"Deployment", "StatefulSet", "DaemonSet", "Service", "Ingress", "Pod"}
+
+// parseExportTargets reads the targets annotation of a khcheck.  Targets without a namespace are in the namespace of
+// the khcheck.
+func parseExportTargets(value string, checkNamespace string) ([]exportTarget, error) {
+	var targets []exportTarget
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		parts := strings.Split(entry, "/")
+		var t exportTarget
+		switch len(parts) {
+		case 2:
+			t = exportTarget{Namespace: checkNamespace, Kind: parts[0], Name: parts[1]}
+		case 3:
+			t = exportTarget{Namespace: parts[0], Kind: parts[1], Name: parts[2]}
+		default:
+			return nil, fmt.Errorf("target %q must be Kind/name or namespace/Kind/name", entry)
+		}
+		if len(t.Namespace) == 0 || len(t.Name) == 0 {
+			return nil, fmt.Errorf("target %q must be Kind/name or namespace/Kind/name", entry)
+		}
+		var known bool
+		for _, kind := range exportKinds {
+			if strings.EqualFold(t.Kind, kind) {
+				t.Kind, known = kind, true
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("target %q is of an unsupported kind. The supported kinds are: %s", entry, strings.Join(exportKinds, ", "))
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// lastProbeValue formats the result of a run for the last probe annotation
+func lastProbeValue(ok bool, t time.Time) string {
+	result := "fail"
+	if ok {
+		result = "pass"
+	}
+	return result + "@" + t.UTC().Format(time.RFC3339)
+}
+
+// annotateTarget merges the result of a run into the annotations of a target
+func annotateTarget(ctx context.Context, client kubernetes.Interface, t exportTarget, annotations map[string]string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+
+	opts := metav1.PatchOptions{}
+	switch t.Kind {
+	case "Deployment":
+		_, err = client.AppsV1().Deployments(t.Namespace).Patch(ctx, t.Name, types.MergePatchType, patch, opts)
+	case "StatefulSet":
+		_, err = client.AppsV1().StatefulSets(t.Namespace).Patch(ctx, t.Name, types.MergePatchType, patch, opts)
+	case "DaemonSet":
+		_, err = client.AppsV1().DaemonSets(t.Namespace).Patch(ctx, t.Name, types.MergePatchType, patch, opts)
+	case "Service":
+		_, err = client.CoreV1().Services(t.Namespace).Patch(ctx, t.Name, types.MergePatchType, patch, opts)
+	case "Ingress":
+		_, err = client.NetworkingV1().Ingresses(t.Namespace).Patch(ctx, t.Name, types.MergePatchType, patch, opts)
+	case "Pod":
+		_, err = client.CoreV1().Pods(t.Namespace).Patch(ctx, t.Name, types.MergePatchType, patch, opts)
+	default:
+		return fmt.Errorf("unsupported kind %s", t.Kind)
+	}
+	return err
+}
+
+// exportResult annotates the result of a run of a check onto each of its targets.  Errors are returned for the
+// targets that could not be annotated, while the rest are still annotated.
+func exportResult(ctx context.Context, client kubernetes.Interface, targets []exportTarget, checkName string, checkNamespace string, ok bool, now time.Time) []error {
+	annotations := map[string]string{
+		lastProbeAnnotation:      lastProbeValue(ok, now),
+		lastProbeCheckAnnotation: checkNamespace + "/" + checkName,
+	}
+
+	var errs []error
+	for _, t := range targets {
+		err := annotateTarget(ctx, client, t, annotations)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to annotate %s: %w", t, err))
+		}
+	}
+	return errs
+}
+
+// exportCheckResult annotates the result of a run onto the targets named by the khcheck, when result export is
+// enabled.  Targets that are missing or can't be annotated are logged and skipped.
+func (k *Kuberhealthy) exportCheckResult(ctx context.Context, checkName string, namespace string, ok bool) {
+	if !cfg.EnableResultExport {
+		return
+	}
+
+	check, err := khCheckClient.KuberhealthyChecks(namespace).Get(checkName, metav1.GetOptions{})
+	if err != nil {
+		log.Errorln("result export: failed to get khcheck", namespace+"/"+checkName+":", err)
+		return
+	}
+	value := check.Annotations[khcheckv1.TargetsAnnotation]
+	if len(value) == 0 {
+		return
+	}
+
+	targets, err := parseExportTargets(value, namespace)
+	if err != nil {
+		log.Warningln("result export: invalid", khcheckv1.TargetsAnnotation, "annotation on khcheck", namespace+"/"+checkName+":", err)
+		return
+	}
+	for _, err := range exportResult(ctx, kubernetesClient, targets, checkName, namespace, ok, time.Now()) {
+		log.Warningln("result export: khcheck", namespace+"/"+checkName+":", err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestParseExportTargets ensures that targets default to the namespace of the check and unknown kinds are refused
+func TestParseExportTargets(t *testing.T) {
+	var testCases = []struct {
+		value    string
+		expected []exportTarget
+		err      string
+	}{
+		{
+			value:    "Deployment/api, payments/service/api,",
+			expected: []exportTarget{{"kuberhealthy", "Deployment", "api"}, {"payments", "Service", "api"}},
+		},
+		{
+			value: "api",
+			err:   "must be Kind/name or namespace/Kind/name",
+		},
+		{
+			value: "/Deployment/api",
+			err:   "must be Kind/name or namespace/Kind/name",
+		},
+		{
+			value: "ConfigMap/api",
+			err:   "unsupported kind",
+		},
+	}
+
+	for _, tc := range testCases {
+		targets, err := parseExportTargets(tc.value, "kuberhealthy")
+		if len(tc.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected an error containing %q for %q, got %v", tc.err, tc.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("failed to parse %q: %s", tc.value, err)
+		}
+		if !reflect.DeepEqual(targets, tc.expected) {
+			t.Fatalf("expected targets %v for %q, got %v", tc.expected, tc.value, targets)
+		}
+	}
+}
+
+// TestExportResult ensures that the result of a run is annotated onto existing targets and missing targets are reported
+func TestExportResult(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "payments", Annotations: map[string]string{"owner": "payments-team"}}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "payments"}},
+	)
+	targets := []exportTarget{
+		{"payments", "Deployment", "api"},
+		{"payments", "Service", "api"},
+		{"payments", "Pod", "gone"},
+	}
+	now := time.Date(2023, 6, 1, 10, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+	errs := exportResult(context.Background(), client, targets, "payments-api", "kuberhealthy", false, now)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "payments/Pod/gone") {
+		t.Fatalf("expected an error for the missing pod only, got %v", errs)
+	}
+
+	deployment, err := client.AppsV1().Deployments("payments").Get(context.Background(), "api", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get deployment: %s", err)
+	}
+	expected := map[string]string{
+		"owner":                  "payments-team",
+		lastProbeAnnotation:      "fail@2023-06-01T08:00:00Z",
+		lastProbeCheckAnnotation: "kuberhealthy/payments-api",
+	}
+	if !reflect.DeepEqual(deployment.Annotations, expected) {
+		t.Fatalf("expected deployment annotations %v, got %v", expected, deployment.Annotations)
+	}
+
+	service, err := client.CoreV1().Services("payments").Get(context.Background(), "api", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get service: %s", err)
+	}
+	if service.Annotations[lastProbeAnnotation] != "fail@2023-06-01T08:00:00Z" {
+		t.Fatalf("expected the service to be annotated, got %v", service.Annotations)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/health"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/rules"
+)
+
+// defaultHealthRuleInterval is how often health rules are evaluated
+const defaultHealthRuleInterval = time.Second * 30
+
+// ruleResults holds the most recent results of the health rules along with when they were evaluated
+type ruleResults struct {
+	results []rules.Result
+	time    time.Time
+}
+
+// listNodeZones maps the name of each node to its topology zone.  Nodes are taken from the node cache once it has
+// synced.
+func listNodeZones(ctx context.Context, client kubernetes.Interface, nodes *nodeCache) (map[string]string, error) {
+	zones := make(map[string]string)
+	if nodes.HasSynced() {
+		cached, err := nodes.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range cached {
+			zones[n.Name] = n.Labels[apiv1.LabelTopologyZone]
+		}
+		return zones, nil
+	}
+
+	nodeList, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nodeList.Items {
+		zones[n.Name] = n.Labels[apiv1.LabelTopologyZone]
+	}
+	return zones, nil
+}
+
+// monitorHealthRules evaluates the configured health rules on an interval until the supplied context is canceled
+func (k *Kuberhealthy) monitorHealthRules(ctx context.Context) {
+	log.Infoln("rules: Evaluating health rules every", defaultHealthRuleInterval)
+	ticker := time.NewTicker(defaultHealthRuleInterval)
+	defer ticker.Stop()
+
+	for {
+		k.evaluateHealthRules(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// evaluateHealthRules compiles the configured health rules and evaluates them over the current state.  Rules are
+// compiled on every evaluation so that configuration reloads are picked up.
+func (k *Kuberhealthy) evaluateHealthRules(ctx context.Context) {
+	evaluator, err := rules.Compile(cfg.HealthRules)
+	if err != nil {
+		log.Errorln("rules: invalid health rules:", err)
+		return
+	}
+
+	nodeZones, err := listNodeZones(ctx, kubernetesClient, k.nodeCache)
+	if err != nil {
+		log.Warningln("rules: failed to list nodes, per node results will have no zone:", err)
+	}
+
+	results := evaluator.Evaluate(k.stateReflector.CurrentStatus(), nodeZones)
+	for _, r := range results {
+		if !r.OK {
+			log.Debugln("rules: rule", r.Name, "is failing:", r.Error)
+		}
+	}
+
+	k.ruleMu.Lock()
+	defer k.ruleMu.Unlock()
+	k.ruleResults = &ruleResults{results: results, time: time.Now()}
+}
+
+// currentRuleResults returns the most recent health rule results and whether the rules have been evaluated
+func (k *Kuberhealthy) currentRuleResults() (ruleResults, bool) {
+	k.ruleMu.RLock()
+	defer k.ruleMu.RUnlock()
+	if k.ruleResults == nil {
+		return ruleResults{}, false
+	}
+	return *k.ruleResults, true
+}
+
+// addRuleResults adds health rule results to the supplied state as synthetic checks in the namespace of
+// Kuberhealthy.  Failing rules mark the state as not OK.  When namespaces are requested, the rules are only added if
+// the namespace of Kuberhealthy is one of them.
+func addRuleResults(state health.State, current ruleResults, namespaces []string) health.State {
+	if len(namespaces) != 0 && !containsString(podNamespace, namespaces) {
+		return state
+	}
+
+	lastRun := metav1.NewTime(current.time)
+	for _, r := range current.results {
+		details := khstatev1.WorkloadDetails{
+			OK:               r.OK,
+			Errors:           []string{},
+			Namespace:        podNamespace,
+			LastRun:          &lastRun,
+			AuthoritativePod: podHostname,
+		}
+		if !r.OK {
+			details.Errors = []string{r.Error}
+			state.AddError(r.Error)
+			state.OK = false
+		}
+		state.CheckDetails[podNamespace+"/"+r.CheckName()] = details
+	}
+	return state
+}
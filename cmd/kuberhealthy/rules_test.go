@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/health"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/rules"
+)
+
+// TestListNodeZones ensures nodes are mapped to their topology zone
+func TestListNodeZones(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: map[string]string{corev1.LabelTopologyZone: "zone-a"}}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "b"}},
+	)
+
+	zones, err := listNodeZones(context.Background(), client, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zones["a"] != "zone-a" || zones["b"] != "" || len(zones) != 2 {
+		t.Fatalf("unexpected node zones: %v", zones)
+	}
+}
+
+// TestAddRuleResults ensures rule results show up as synthetic checks and failing rules fail the state
+func TestAddRuleResults(t *testing.T) {
+	current := ruleResults{
+		time: time.Now(),
+		results: []rules.Result{
+			{Name: "passing", OK: true},
+			{Name: "failing", Error: "too many nodes failing"},
+		},
+	}
+
+	state := addRuleResults(health.NewState(), current, nil)
+	if state.OK {
+		t.Fatal("expected the state to fail due to the failing rule")
+	}
+	if len(state.Errors) != 1 || state.Errors[0] != "too many nodes failing" {
+		t.Fatalf("unexpected errors: %v", state.Errors)
+	}
+	passing, ok := state.CheckDetails[podNamespace+"/rule-passing"]
+	if !ok || !passing.OK || passing.LastRun == nil {
+		t.Fatalf("expected a passing synthetic check, got %+v", passing)
+	}
+	if failing := state.CheckDetails[podNamespace+"/rule-failing"]; failing.OK || len(failing.Errors) != 1 {
+		t.Fatalf("expected a failing synthetic check, got %+v", failing)
+	}
+
+	filtered := addRuleResults(health.NewState(), current, []string{"some-other-namespace"})
+	if !filtered.OK || len(filtered.CheckDetails) != 0 {
+		t.Fatal("expected rules to be left out when the kuberhealthy namespace is not requested")
+	}
+}
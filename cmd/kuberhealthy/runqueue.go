@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// runQueueLeaseName is the name of the lease that holds the runs in flight
+const runQueueLeaseName = "kuberhealthy-run-queue"
+
+// runQueueAnnotation is the annotation on the run queue lease that holds the runs in flight as JSON
+const runQueueAnnotation = "comcast.github.io/runs"
+
+// leaseRunStore persists the runs in flight as an annotation on a lease in the Kuberhealthy namespace.  This lets a
+// restarted or newly elected master resume the runs started by the previous master instead of starting duplicate
+// checker pods.
+type leaseRunStore struct {
+	client    kubernetes.Interface
+	namespace string
+}
+
+// newLeaseRunStore creates a run store backed by a lease in the supplied namespace
+func newLeaseRunStore(client kubernetes.Interface, namespace string) *leaseRunStore {
+	return &leaseRunStore{client: client, namespace: namespace}
+}
+
+// Load returns the runs in flight stored on the lease.  No runs are returned when the lease does not exist yet.
+func (s *leaseRunStore) Load() ([]external.Run, error) {
+	lease, err := s.client.CoordinationV1().Leases(s.namespace).Get(context.TODO(), runQueueLeaseName, metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lease %s: %w", runQueueLeaseName, err)
+	}
+
+	raw := lease.Annotations[runQueueAnnotation]
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var runs []external.Run
+	err = json.Unmarshal([]byte(raw), &runs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse runs from lease %s: %w", runQueueLeaseName, err)
+	}
+	return runs, nil
+}
+
+// Save replaces the runs in flight stored on the lease, creating the lease if it does not exist
+func (s *leaseRunStore) Save(runs []external.Run) error {
+	b, err := json.Marshal(runs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal runs: %w", err)
+	}
+
+	leases := s.client.CoordinationV1().Leases(s.namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		lease, err := leases.Get(context.TODO(), runQueueLeaseName, metav1.GetOptions{})
+		if k8sErrors.IsNotFound(err) {
+			lease = &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Name: runQueueLeaseName, Namespace: s.namespace}}
+			lease.Annotations = map[string]string{runQueueAnnotation: string(b)}
+			_, err = leases.Create(context.TODO(), lease, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if lease.Annotations == nil {
+			lease.Annotations = make(map[string]string)
+		}
+		lease.Annotations[runQueueAnnotation] = string(b)
+		_, err = leases.Update(context.TODO(), lease, metav1.UpdateOptions{})
+		return err
+	})
+}
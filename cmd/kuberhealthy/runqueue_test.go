@@ -0,0 +1,38 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// TestLeaseRunStore ensures runs saved to the lease are loaded back the same
+func TestLeaseRunStore(t *testing.T) {
+	store := newLeaseRunStore(fake.NewSimpleClientset(), "kuberhealthy")
+
+	runs, err := store.Load()
+	if err != nil || len(runs) != 0 {
+		t.Fatalf("expected no runs before the lease exists but found %v with error %v", runs, err)
+	}
+
+	started := time.Now().UTC().Truncate(time.Second)
+	saved := []external.Run{{UUID: "uuid", CheckName: "check", Namespace: "kuberhealthy", PodName: "check-1", Started: started, Deadline: started.Add(time.Minute), State: external.RunRunning}}
+	for i := 0; i < 2; i++ {
+		err = store.Save(saved)
+		if err != nil {
+			t.Fatalf("failed to save runs: %s", err)
+		}
+	}
+
+	runs, err = store.Load()
+	if err != nil {
+		t.Fatalf("failed to load runs: %s", err)
+	}
+	if !reflect.DeepEqual(runs, saved) {
+		t.Fatalf("loaded runs %+v did not match saved runs %+v", runs, saved)
+	}
+}
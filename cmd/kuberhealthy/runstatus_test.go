@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestExternalCheckRunStatusHandler ensures that recorded runs can be queried by their UUID
+func TestExternalCheckRunStatusHandler(t *testing.T) {
+
+	kh := &Kuberhealthy{runTracker: external.NewRunTracker()}
+	kh.runTracker.Start("reported", "check", "kuberhealthy", "check-1", time.Now().Add(time.Minute))
+	kh.runTracker.MarkReported("reported", status.NewReport([]string{}), "request-1")
+	kh.runTracker.Start("late", "check", "kuberhealthy", "check-1", time.Now().Add(time.Minute))
+	kh.runTracker.Expire("late")
+	kh.runTracker.MarkLate("late", status.NewReport([]string{"too slow"}), "request-2")
+
+	var testCases = []struct {
+		method   string
+		path     string
+		code     int
+		accepted bool
+		late     bool
+	}{
+		{http.MethodGet, "/externalCheckStatus/reported", http.StatusOK, true, false},
+		{http.MethodGet, "/externalCheckStatus/late", http.StatusOK, false, true},
+		{http.MethodGet, "/externalCheckStatus/unknown", http.StatusNotFound, false, false},
+		{http.MethodGet, "/externalCheckStatus/", http.StatusBadRequest, false, false},
+		{http.MethodPost, "/externalCheckStatus/reported", http.StatusMethodNotAllowed, false, false},
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		err := kh.externalCheckRunStatusHandler(recorder, req)
+		if err != nil {
+			t.Fatalf("%s %s returned an error: %s", tc.method, tc.path, err)
+		}
+		if recorder.Code != tc.code {
+			t.Fatalf("%s %s returned code %d but expected %d", tc.method, tc.path, recorder.Code, tc.code)
+		}
+		if recorder.Code != http.StatusOK {
+			continue
+		}
+
+		runStatus := status.RunStatus{}
+		err = json.Unmarshal(recorder.Body.Bytes(), &runStatus)
+		if err != nil {
+			t.Fatalf("failed to unmarshal run status from %s: %s", tc.path, err)
+		}
+		if runStatus.Accepted != tc.accepted || runStatus.Late != tc.late {
+			t.Fatalf("%s returned accepted %t late %t but expected accepted %t late %t", tc.path, runStatus.Accepted, runStatus.Late, tc.accepted, tc.late)
+		}
+		t.Logf("%s returned %+v correctly", tc.path, runStatus)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// schedulerPath is the path of the endpoint that describes what the scheduler of this replica is doing
+const schedulerPath = "/api/v2/internal/scheduler"
+
+// runSchedule records when each check scheduled by this replica runs next, so that operators can see why a check has
+// not run without reading the logs.  Only the master schedules checks.
+type runSchedule struct {
+	sync.Mutex
+	checks map[string]scheduledCheck // keyed by namespace/name
+}
+
+// scheduledCheck is when a check scheduled by this replica runs next
+type scheduledCheck struct {
+	Check        string
+	Namespace    string
+	Interval     string     // the run interval of the check
+	NextRun      time.Time  // when the next run is due
+	BackoffUntil *time.Time `json:",omitempty"` // when runs resume, if they are backed off after provisioning errors
+}
+
+// planned records when the next run of a check is due
+func (s *runSchedule) planned(c *external.Checker, next time.Time) {
+	s.set(c, scheduledCheck{NextRun: next})
+}
+
+// backedOff records that the runs of a check are backed off until the supplied time
+func (s *runSchedule) backedOff(c *external.Checker, until time.Time) {
+	s.set(c, scheduledCheck{NextRun: until, BackoffUntil: &until})
+}
+
+// set records the schedule of a check
+func (s *runSchedule) set(c *external.Checker, sc scheduledCheck) {
+	sc.Check = c.Name()
+	sc.Namespace = c.CheckNamespace()
+	sc.Interval = c.Interval().String()
+
+	s.Lock()
+	defer s.Unlock()
+	if s.checks == nil {
+		s.checks = make(map[string]scheduledCheck)
+	}
+	s.checks[sc.Namespace+"/"+sc.Check] = sc
+}
+
+// forget drops a check that is no longer scheduled by this replica
+func (s *runSchedule) forget(c *external.Checker) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.checks, c.CheckNamespace()+"/"+c.Name())
+}
+
+// list returns the scheduled checks sorted by namespace and name
+func (s *runSchedule) list() []scheduledCheck {
+	s.Lock()
+	defer s.Unlock()
+	checks := make([]scheduledCheck, 0, len(s.checks))
+	for _, sc := range s.checks {
+		checks = append(checks, sc)
+	}
+	sort.Slice(checks, func(i, j int) bool {
+		if checks[i].Namespace != checks[j].Namespace {
+			return checks[i].Namespace < checks[j].Namespace
+		}
+		return checks[i].Check < checks[j].Check
+	})
+	return checks
+}
+
+// schedulerQueues are the work waiting on this replica
+type schedulerQueues struct {
+	ReportsInFlight      int // check reports being handled right now
+	MaxConcurrentReports int // the most check reports handled at once before reports are refused with a 429
+	PendingResidentRuns  int // runs waiting to be taken by resident checkers
+	QueuedStateWrites    int // khstate writes waiting for the Kubernetes API to return
+}
+
+// inFlightRun is a run that has started and not yet reported or timed out
+type inFlightRun struct {
+	UUID      string
+	Check     string
+	Namespace string
+	Pod       string `json:",omitempty"` // the checker pod of the run, if it spawned one
+	Started   time.Time
+	Deadline  time.Time
+	Canceled  bool `json:",omitempty"` // the run was canceled and its result will not be counted
+}
+
+// schedulerStatus is served by the scheduler endpoint
+type schedulerStatus struct {
+	Leader   string // the replica that is master and schedules the checks
+	Replica  string // the replica that served the request
+	IsLeader bool   // the replica that served the request is the master.  Other replicas schedule nothing.
+	Checks   []scheduledCheck
+	Queues   schedulerQueues
+	InFlight []inFlightRun
+}
+
+// schedulerStatus describes what the scheduler of this replica is doing
+func (k *Kuberhealthy) schedulerStatus() schedulerStatus {
+	leader, err := currentMaster.Get(k.clock().Now())
+	if err != nil {
+		log.Warningln("scheduler: Failed to calculate the current master:", err)
+	}
+
+	s := schedulerStatus{
+		Leader:   leader,
+		Replica:  podHostname,
+		IsLeader: isMaster,
+		Checks:   k.schedule.list(),
+		Queues: schedulerQueues{
+			ReportsInFlight:      int(atomic.LoadInt32(&k.reportsInFlight)),
+			MaxConcurrentReports: maxConcurrentReports(),
+			PendingResidentRuns:  k.residents.Pending(),
+			QueuedStateWrites:    k.degraded.queuedWrites(),
+		},
+		InFlight: []inFlightRun{},
+	}
+	for _, r := range k.runTracker.InFlight() {
+		s.InFlight = append(s.InFlight, inFlightRun{
+			UUID:      r.UUID,
+			Check:     r.CheckName,
+			Namespace: r.Namespace,
+			Pod:       r.PodName,
+			Started:   r.Started,
+			Deadline:  r.Deadline,
+			Canceled:  r.Canceled,
+		})
+	}
+	sort.Slice(s.InFlight, func(i, j int) bool {
+		return s.InFlight[i].Deadline.Before(s.InFlight[j].Deadline)
+	})
+	return s
+}
+
+// schedulerHandler serves which replica is the master, when each check runs next, the queues of this replica and the
+// runs in flight with their deadlines
+func (k *Kuberhealthy) schedulerHandler(w http.ResponseWriter, r *http.Request) error {
+	log.Infoln("Client connected to scheduler endpoint from", r.RemoteAddr, r.UserAgent())
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	b, err := json.MarshalIndent(k.schedulerStatus(), "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to marshal scheduler status: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(b)
+	if err != nil {
+		log.Warningln("Error writing scheduler status to caller:", err)
+	}
+	return err
+}
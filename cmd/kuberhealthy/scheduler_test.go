@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// TestSchedulerHandler ensures the scheduler endpoint serves the master, the next run of each check, the queues and
+// the runs in flight with their deadlines
+func TestSchedulerHandler(t *testing.T) {
+	oldMaster, oldIsMaster, oldHostname := currentMaster, isMaster, podHostname
+	defer func() { currentMaster, isMaster, podHostname = oldMaster, oldIsMaster, oldHostname }()
+	currentMaster = &masterNameCache{calculate: func() (string, error) { return "kuberhealthy-a", nil }}
+	isMaster = true
+	podHostname = "kuberhealthy-a"
+
+	kh := &Kuberhealthy{runTracker: external.NewRunTracker(), residents: external.NewResidentRegistry()}
+	now := time.Now()
+
+	dns := &external.Checker{CheckName: "dns", Namespace: "kuberhealthy", RunInterval: time.Minute}
+	deployment := &external.Checker{CheckName: "deployment", Namespace: "kuberhealthy", RunInterval: time.Minute * 10}
+	removed := &external.Checker{CheckName: "removed", Namespace: "kuberhealthy", RunInterval: time.Minute}
+	kh.schedule.planned(dns, now.Add(time.Minute))
+	kh.schedule.backedOff(deployment, now.Add(time.Hour))
+	kh.schedule.planned(removed, now.Add(time.Minute))
+	kh.schedule.forget(removed)
+
+	kh.runTracker.Start("run-b", "deployment", "kuberhealthy", "deployment-pod", now.Add(time.Minute*5))
+	kh.runTracker.Start("run-a", "dns", "kuberhealthy", "dns-pod", now.Add(time.Minute))
+	kh.degraded.queue("dns", "kuberhealthy", khstatev1.NewWorkloadDetails(khstatev1.KHCheck))
+
+	recorder := httptest.NewRecorder()
+	err := kh.schedulerHandler(recorder, httptest.NewRequest(http.MethodPost, schedulerPath, nil))
+	if err != nil || recorder.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST to the scheduler endpoint returned %d and error %v", recorder.Code, err)
+	}
+
+	recorder = httptest.NewRecorder()
+	err = kh.schedulerHandler(recorder, httptest.NewRequest(http.MethodGet, schedulerPath, nil))
+	if err != nil || recorder.Code != http.StatusOK {
+		t.Fatalf("scheduler endpoint returned %d and error %v", recorder.Code, err)
+	}
+	var s schedulerStatus
+	if err := json.Unmarshal(recorder.Body.Bytes(), &s); err != nil {
+		t.Fatal("failed to decode scheduler status:", err)
+	}
+
+	if s.Leader != "kuberhealthy-a" || s.Replica != "kuberhealthy-a" || !s.IsLeader {
+		t.Fatalf("scheduler status has leader %q, replica %q and is leader %v", s.Leader, s.Replica, s.IsLeader)
+	}
+	if len(s.Checks) != 2 || s.Checks[0].Check != "deployment" || s.Checks[1].Check != "dns" {
+		t.Fatalf("scheduler status has checks %+v", s.Checks)
+	}
+	if s.Checks[0].BackoffUntil == nil || !s.Checks[0].NextRun.Equal(*s.Checks[0].BackoffUntil) || s.Checks[1].BackoffUntil != nil {
+		t.Fatalf("scheduler status has checks %+v", s.Checks)
+	}
+	if s.Checks[1].Interval != "1m0s" || !s.Checks[1].NextRun.Equal(now.Add(time.Minute)) {
+		t.Fatalf("scheduler status has check %+v", s.Checks[1])
+	}
+	if s.Queues.QueuedStateWrites != 1 || s.Queues.MaxConcurrentReports != maxConcurrentReports() {
+		t.Fatalf("scheduler status has queues %+v", s.Queues)
+	}
+	if len(s.InFlight) != 2 || s.InFlight[0].UUID != "run-a" || s.InFlight[0].Pod != "dns-pod" || !s.InFlight[1].Deadline.Equal(now.Add(time.Minute*5)) {
+		t.Fatalf("scheduler status has runs in flight %+v", s.InFlight)
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/masterCalculation"
+)
+
+// selfCheckPrefix starts the names of the khstates that hold the results of the self-checks
+const selfCheckPrefix = "kuberhealthy-self-"
+
+// The self-checks that Kuberhealthy runs against its own internals
+const (
+	// selfCheckReporting spawns a pod that calls back into the reporting endpoint of Kuberhealthy
+	selfCheckReporting = "reporting"
+	// selfCheckStateWrite times writing a khstate
+	selfCheckStateWrite = "khstate-write"
+	// selfCheckRunQueue looks for runs that pile up or are never timed out
+	selfCheckRunQueue = "run-queue"
+	// selfCheckLeaderElection ensures the master calculation agrees that this pod is the master
+	selfCheckLeaderElection = "leader-election"
+)
+
+// defaultSelfCheckInterval is how often the self-checks run by default
+const defaultSelfCheckInterval = time.Minute
+
+// defaultSelfCheckImage is the image of the pod spawned by the reporting self-check.  It must have curl in it.
+const defaultSelfCheckImage = "curlimages/curl:8.5.0"
+
+// defaultSelfCheckMaxWriteLatency is the longest a khstate write can take before the khstate write self-check fails
+const defaultSelfCheckMaxWriteLatency = time.Second * 5
+
+// selfCheckReportingTimeout is how long the pod of the reporting self-check has to call back into Kuberhealthy
+const selfCheckReportingTimeout = time.Minute * 2
+
+// selfCheckStuckRunGrace is how long past its deadline a run can be in flight before the run queue self-check fails
+const selfCheckStuckRunGrace = time.Minute
+
+// selfCheckMasterSettleTime is how long after a master change the leader election self-check waits before failing
+const selfCheckMasterSettleTime = time.Minute
+
+// selfCheckPodLabel labels the pods spawned by the reporting self-check
+const selfCheckPodLabel = "kuberhealthy-self-check"
+
+// selfCheckStateName returns the name of the khstate that holds the result of a self-check
+func selfCheckStateName(name string) string {
+	return selfCheckPrefix + name
+}
+
+// isSelfCheckState determines if a khstate holds the result of a self-check.  Self-check khstates live in the
+// namespace of Kuberhealthy and have no khcheck behind them.
+func isSelfCheckState(name string, namespace string) bool {
+	return namespace == podNamespace && strings.HasPrefix(name, selfCheckPrefix)
+}
+
+// selfCheckInterval returns how often the self-checks run
+func selfCheckInterval() time.Duration {
+	if cfg.SelfCheckInterval.Duration > 0 {
+		return cfg.SelfCheckInterval.Duration
+	}
+	return defaultSelfCheckInterval
+}
+
+// selfCheckImage returns the image of the pod spawned by the reporting self-check
+func selfCheckImage() string {
+	if len(cfg.SelfCheckImage) > 0 {
+		return cfg.SelfCheckImage
+	}
+	return defaultSelfCheckImage
+}
+
+// selfCheckMaxWriteLatency returns the longest a khstate write can take before the khstate write self-check fails
+func selfCheckMaxWriteLatency() time.Duration {
+	if cfg.SelfCheckMaxWriteLatency.Duration > 0 {
+		return cfg.SelfCheckMaxWriteLatency.Duration
+	}
+	return defaultSelfCheckMaxWriteLatency
+}
+
+// selfCheckMaxRunsInFlight returns how many runs can be in flight before the run queue self-check fails.  By
+// default every check can have two runs in flight.
+func selfCheckMaxRunsInFlight(checks int) int {
+	if cfg.SelfCheckMaxRunsInFlight > 0 {
+		return cfg.SelfCheckMaxRunsInFlight
+	}
+	if checks < 1 {
+		checks = 1
+	}
+	return checks * 2
+}
+
+// checkRunQueue ensures the runs in flight are not piling up and that runs past their deadline are timed out
+func checkRunQueue(runs []external.Run, maxRuns int, now time.Time) error {
+	if len(runs) > maxRuns {
+		return fmt.Errorf("%d runs are in flight, which is more than the %d allowed", len(runs), maxRuns)
+	}
+	for _, r := range runs {
+		if now.Sub(r.Deadline) > selfCheckStuckRunGrace {
+			return fmt.Errorf("run %s of %s is still in flight %s after its deadline", r.UUID, r.Namespace+"/"+r.CheckName, now.Sub(r.Deadline).Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// checkLeaderElection ensures this pod, which is running checks as the master, is the pod the master calculation
+// picks.  Disagreements right after a master change are left to settle.
+func checkLeaderElection(master string, err error, self string, lastChange time.Time, now time.Time) error {
+	if err != nil {
+		return fmt.Errorf("failed to calculate the master: %w", err)
+	}
+	if len(master) == 0 {
+		return errors.New("no kuberhealthy pod is eligible to be the master")
+	}
+	if master != self && now.Sub(lastChange) > selfCheckMasterSettleTime {
+		return fmt.Errorf("%s is running checks but %s is calculated to be the master", self, master)
+	}
+	return nil
+}
+
+// selfCheckURL returns the URL the pod of the reporting self-check calls with the run UUID
+func selfCheckURL(reportingURL string, runUUID string) (string, error) {
+	u, err := url.Parse(reportingURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse reporting url %s: %w", reportingURL, err)
+	}
+	u.Path = "/selfCheck/" + runUUID
+	u.RawQuery = ""
+	return u.String(), nil
+}
+
+// newSelfCheckPod makes the pod of the reporting self-check.  It calls the supplied URL once and exits.
+func newSelfCheckPod(name string, namespace string, image string, callbackURL string) *apiv1.Pod {
+	allowPrivilegeEscalation := false
+	runAsNonRoot := true
+	activeDeadlineSeconds := int64(selfCheckReportingTimeout / time.Second)
+	return &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{selfCheckPodLabel: selfCheckReporting},
+		},
+		Spec: apiv1.PodSpec{
+			RestartPolicy:         apiv1.RestartPolicyNever,
+			ActiveDeadlineSeconds: &activeDeadlineSeconds,
+			SecurityContext: &apiv1.PodSecurityContext{
+				RunAsNonRoot:   &runAsNonRoot,
+				SeccompProfile: &apiv1.SeccompProfile{Type: apiv1.SeccompProfileTypeRuntimeDefault},
+			},
+			Containers: []apiv1.Container{{
+				Name:    "main",
+				Image:   image,
+				Command: []string{"curl", "--fail", "--silent", "--show-error", "--retry", "5", "--retry-connrefused", "--request", "POST", callbackURL},
+				SecurityContext: &apiv1.SecurityContext{
+					AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+					Capabilities:             &apiv1.Capabilities{Drop: []apiv1.Capability{"ALL"}},
+				},
+			}},
+		},
+	}
+}
+
+// waitForSelfCheckPod waits for the pod of the reporting self-check to exit.  The pod only succeeds when Kuberhealthy
+// accepted its call.
+func waitForSelfCheckPod(ctx context.Context, client kubernetes.Interface, namespace string, name string, poll time.Duration) error {
+	for {
+		pod, err := client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get self-check pod %s: %w", name, err)
+		}
+		switch pod.Status.Phase {
+		case apiv1.PodSucceeded:
+			return nil
+		case apiv1.PodFailed:
+			return fmt.Errorf("self-check pod %s failed to call the reporting endpoint: %s", name, pod.Status.Message)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("self-check pod %s did not call the reporting endpoint in time, it is %s", name, pod.Status.Phase)
+		case <-time.After(poll):
+		}
+	}
+}
+
+// checkReporting spawns a pod that calls the reporting endpoint of Kuberhealthy through the same URL checker pods
+// report to.  The run UUID is set on the self-check khstate first so that the endpoint can tell the call is expected.
+func (k *Kuberhealthy) checkReporting(ctx context.Context, runUUID string) error {
+	name := selfCheckStateName(selfCheckReporting)
+	err := ensureStateResourceExists(name, podNamespace, khstatev1.KHCheck)
+	if err != nil {
+		return fmt.Errorf("failed to ensure khstate %s exists: %w", name, err)
+	}
+	state, err := khStateClient.KuberhealthyStates(podNamespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get khstate %s: %w", name, err)
+	}
+	state.Spec.CurrentUUID = runUUID
+	_, err = khStateClient.KuberhealthyStates(podNamespace).Update(&state)
+	if err != nil {
+		return fmt.Errorf("failed to set the run uuid on khstate %s: %w", name, err)
+	}
+
+	callbackURL, err := selfCheckURL(reportingURLOrDefault(cfg.ReportingURLMode, name), runUUID)
+	if err != nil {
+		return err
+	}
+	pod := newSelfCheckPod(name+"-"+runUUID[:8], podNamespace, selfCheckImage(), callbackURL)
+	_, err = kubernetesClient.CoreV1().Pods(podNamespace).Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create self-check pod: %w", err)
+	}
+	defer func() {
+		err := kubernetesClient.CoreV1().Pods(podNamespace).Delete(context.Background(), pod.Name, metav1.DeleteOptions{})
+		if err != nil {
+			log.Warningln("selfcheck: Failed to delete self-check pod", pod.Name+":", err)
+		}
+	}()
+
+	waitCtx, cancel := context.WithTimeout(ctx, selfCheckReportingTimeout)
+	defer cancel()
+	return waitForSelfCheckPod(waitCtx, kubernetesClient, podNamespace, pod.Name, time.Second*2)
+}
+
+// checkStateWrite times a write of the khstate of the khstate write self-check
+func (k *Kuberhealthy) checkStateWrite(runUUID string) error {
+	name := selfCheckStateName(selfCheckStateWrite)
+	err := ensureStateResourceExists(name, podNamespace, khstatev1.KHCheck)
+	if err != nil {
+		return fmt.Errorf("failed to ensure khstate %s exists: %w", name, err)
+	}
+
+	details := khstatev1.NewWorkloadDetails(khstatev1.KHCheck)
+	details.OK = true
+	details.Namespace = podNamespace
+	details.CurrentUUID = runUUID
+
+	start := k.clock().Now()
+	err = setCheckStateResource(name, podNamespace, details)
+	latency := k.clock().Since(start)
+	if err != nil {
+		return fmt.Errorf("failed to write khstate: %w", err)
+	}
+	if max := selfCheckMaxWriteLatency(); latency > max {
+		return fmt.Errorf("writing a khstate took %s, which is longer than the %s allowed", latency.Round(time.Millisecond), max)
+	}
+	return nil
+}
+
+// runSelfCheck runs one of the self-checks
+func (k *Kuberhealthy) runSelfCheck(ctx context.Context, name string, runUUID string) error {
+	switch name {
+	case selfCheckReporting:
+		return k.checkReporting(ctx, runUUID)
+	case selfCheckStateWrite:
+		return k.checkStateWrite(runUUID)
+	case selfCheckRunQueue:
+		return checkRunQueue(k.runTracker.InFlight(), selfCheckMaxRunsInFlight(len(k.Checks)), k.clock().Now())
+	case selfCheckLeaderElection:
+		master, err := masterCalculation.CalculateMaster(kubernetesClient)
+		return checkLeaderElection(master, err, podHostname, lastMasterChangeTime, k.clock().Now())
+	}
+	return fmt.Errorf("unknown self-check %s", name)
+}
+
+// storeSelfCheckResult stores the result of a self-check in its khstate so that it shows up like any other check
+func (k *Kuberhealthy) storeSelfCheckResult(name string, runUUID string, duration time.Duration, checkErr error) error {
+	details := khstatev1.NewWorkloadDetails(khstatev1.KHCheck)
+	details.OK = checkErr == nil
+	details.Namespace = podNamespace
+	details.CurrentUUID = runUUID
+	details.RunDuration = duration.String()
+	if checkErr != nil {
+		details.Errors = []string{"Kuberhealthy self-check " + name + " failed: " + checkErr.Error()}
+	}
+	return k.storeCheckState(selfCheckStateName(name), podNamespace, details)
+}
+
+// monitorSelfCheck runs a self-check on the self-check interval until the supplied context is canceled
+func (k *Kuberhealthy) monitorSelfCheck(ctx context.Context, name string) {
+	defer k.wg.Done()
+
+	ticker := k.clock().NewTicker(selfCheckInterval())
+	defer ticker.Stop()
+
+	for {
+		runUUID := uuid.New().String()
+		start := k.clock().Now()
+		err := k.runSelfCheck(ctx, name, runUUID)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Warningln("selfcheck: Self-check", name, "failed:", err)
+		}
+		err = k.storeSelfCheckResult(name, runUUID, k.clock().Since(start), err)
+		if err != nil {
+			log.Errorln("selfcheck: Failed to store the result of self-check", name+":", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// startSelfChecks starts every self-check.  They run on the master along with the checks and stop with them.
+func (k *Kuberhealthy) startSelfChecks(ctx context.Context) {
+	log.Infoln("selfcheck: Running self-checks every", selfCheckInterval())
+	for _, name := range []string{selfCheckReporting, selfCheckStateWrite, selfCheckRunQueue, selfCheckLeaderElection} {
+		k.wg.Add(1)
+		go k.monitorSelfCheck(ctx, name)
+	}
+}
+
+// selfCheckHandler accepts the call from the pod of the reporting self-check.  The call is only accepted when the run
+// UUID in the request path is the one on the khstate of the reporting self-check, which the master sets before
+// spawning the pod.  Any Kuberhealthy pod can accept the call.
+func (k *Kuberhealthy) selfCheckHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+	runUUID := strings.TrimPrefix(r.URL.Path, "/selfCheck/")
+	if len(runUUID) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+
+	name := selfCheckStateName(selfCheckReporting)
+	state, err := khStateClient.KuberhealthyStates(podNamespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to get khstate %s: %w", name, err)
+	}
+	if state.Spec.CurrentUUID != runUUID {
+		log.Infoln("selfcheck: Refused call from", r.RemoteAddr, "with unexpected run uuid", runUUID)
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+	log.Infoln("selfcheck: Accepted call from the reporting self-check pod at", r.RemoteAddr)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// TestCheckRunQueue ensures the run queue self-check fails when runs pile up or are not timed out
+func TestCheckRunQueue(t *testing.T) {
+	now := time.Now()
+	running := external.Run{UUID: "running", CheckName: "check", Namespace: "kuberhealthy", Deadline: now.Add(time.Minute)}
+	late := external.Run{UUID: "late", CheckName: "check", Namespace: "kuberhealthy", Deadline: now.Add(-time.Second * 30)}
+	stuck := external.Run{UUID: "stuck", CheckName: "check", Namespace: "kuberhealthy", Deadline: now.Add(-time.Minute * 5)}
+
+	var testCases = []struct {
+		description string
+		runs        []external.Run
+		maxRuns     int
+		expectErr   bool
+	}{
+		{"no runs", nil, 2, false},
+		{"runs in flight", []external.Run{running, late}, 2, false},
+		{"too many runs", []external.Run{running, late}, 1, true},
+		{"stuck run", []external.Run{running, stuck}, 2, true},
+	}
+	for _, tc := range testCases {
+		err := checkRunQueue(tc.runs, tc.maxRuns, now)
+		if (err != nil) != tc.expectErr {
+			t.Fatalf("%s: returned error %v but expected an error: %t", tc.description, err, tc.expectErr)
+		}
+	}
+}
+
+// TestCheckLeaderElection ensures the leader election self-check fails when another pod is the settled master
+func TestCheckLeaderElection(t *testing.T) {
+	now := time.Now()
+	settled := now.Add(-time.Hour)
+
+	var testCases = []struct {
+		description string
+		master      string
+		err         error
+		lastChange  time.Time
+		expectErr   bool
+	}{
+		{"this pod is master", "kuberhealthy-a", nil, settled, false},
+		{"calculation failed", "", errors.New("forbidden"), settled, true},
+		{"no master", "", nil, settled, true},
+		{"other pod is master", "kuberhealthy-b", nil, settled, true},
+		{"master changing", "kuberhealthy-b", nil, now.Add(-time.Second * 10), false},
+	}
+	for _, tc := range testCases {
+		err := checkLeaderElection(tc.master, tc.err, "kuberhealthy-a", tc.lastChange, now)
+		if (err != nil) != tc.expectErr {
+			t.Fatalf("%s: returned error %v but expected an error: %t", tc.description, err, tc.expectErr)
+		}
+	}
+}
+
+// TestSelfCheckURL ensures the reporting self-check calls the self-check endpoint next to the reporting url
+func TestSelfCheckURL(t *testing.T) {
+	u, err := selfCheckURL("http://kuberhealthy.kuberhealthy.svc.cluster.local/externalCheckStatus?check=a", "run-uuid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u != "http://kuberhealthy.kuberhealthy.svc.cluster.local/selfCheck/run-uuid" {
+		t.Fatalf("unexpected self-check url %s", u)
+	}
+}
+
+// TestWaitForSelfCheckPod ensures the reporting self-check passes only when its pod succeeded
+func TestWaitForSelfCheckPod(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "succeeded", Namespace: "kuberhealthy"}, Status: apiv1.PodStatus{Phase: apiv1.PodSucceeded}},
+		&apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "failed", Namespace: "kuberhealthy"}, Status: apiv1.PodStatus{Phase: apiv1.PodFailed}},
+		&apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "kuberhealthy"}, Status: apiv1.PodStatus{Phase: apiv1.PodPending}},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	if err := waitForSelfCheckPod(ctx, client, "kuberhealthy", "succeeded", time.Millisecond*10); err != nil {
+		t.Fatal("expected a succeeded pod to pass:", err)
+	}
+	if err := waitForSelfCheckPod(ctx, client, "kuberhealthy", "failed", time.Millisecond*10); err == nil {
+		t.Fatal("expected a failed pod to fail")
+	}
+	if err := waitForSelfCheckPod(ctx, client, "kuberhealthy", "pending", time.Millisecond*10); err == nil {
+		t.Fatal("expected a pod that never exits to time out")
+	}
+}
+
+// TestIsSelfCheckState ensures only khstates with the self-check prefix in the kuberhealthy namespace are self-checks
+func TestIsSelfCheckState(t *testing.T) {
+	oldNamespace := podNamespace
+	defer func() {
+		podNamespace = oldNamespace
+	}()
+	podNamespace = "kuberhealthy"
+
+	if !isSelfCheckState(selfCheckStateName(selfCheckReporting), "kuberhealthy") {
+		t.Fatal("expected the reporting self-check khstate to be a self-check")
+	}
+	if isSelfCheckState(selfCheckStateName(selfCheckReporting), "other") {
+		t.Fatal("expected khstates outside of the kuberhealthy namespace not to be self-checks")
+	}
+	if isSelfCheckState("deployment", "kuberhealthy") {
+		t.Fatal("expected regular khstates not to be self-checks")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/clock"
+)
+
+// Shutdown policies decide what happens to the runs in flight when Kuberhealthy shuts down, such as during a rolling
+// update of Kuberhealthy itself
+const (
+	// shutdownPolicyDelete cancels the runs in flight and deletes their checker pods
+	shutdownPolicyDelete = "delete"
+	// shutdownPolicyAdopt leaves the runs in flight and their checker pods for the next master to adopt
+	shutdownPolicyAdopt = "adopt"
+	// shutdownPolicyWait waits for the runs in flight to report before canceling the rest and deleting their pods
+	shutdownPolicyWait = "wait"
+)
+
+// defaultShutdownWaitTimeout is how long the wait shutdown policy waits on the runs in flight by default
+const defaultShutdownWaitTimeout = time.Minute
+
+// shutdownWaitPollInterval is how often the runs in flight are looked at while waiting on them to report
+const shutdownWaitPollInterval = time.Second
+
+// shutdownPolicy returns the configured shutdown policy.  Unknown policies fall back to deleting the runs in flight.
+func shutdownPolicy() string {
+	if cfg == nil || len(cfg.ShutdownPolicy) == 0 {
+		return shutdownPolicyDelete
+	}
+	switch cfg.ShutdownPolicy {
+	case shutdownPolicyDelete, shutdownPolicyAdopt, shutdownPolicyWait:
+		return cfg.ShutdownPolicy
+	}
+	log.Warningln("shutdown: Unknown shutdown policy", cfg.ShutdownPolicy, "- falling back to", shutdownPolicyDelete)
+	return shutdownPolicyDelete
+}
+
+// shutdownWaitTimeout returns how long the wait shutdown policy waits on the runs in flight.  The wait always leaves
+// time within the termination grace period for the remaining runs to be stopped.
+func shutdownWaitTimeout() time.Duration {
+	timeout := defaultShutdownWaitTimeout
+	if cfg != nil && cfg.ShutdownWaitTimeout.Duration > 0 {
+		timeout = cfg.ShutdownWaitTimeout.Duration
+	}
+	if max := terminationGracePeriod / 2; timeout > max {
+		log.Warningln("shutdown: Shutdown wait timeout of", timeout, "is capped to", max, "to fit in the termination grace period")
+		timeout = max
+	}
+	return timeout
+}
+
+// waitForInFlightRuns waits until none of the runs that are in flight now are still running, or until the timeout
+// passes.  Runs started while waiting are not waited on.  Returns the number of runs that were still running.
+func waitForInFlightRuns(runs *external.RunTracker, c clock.Clock, timeout time.Duration) int {
+	waiting := make(map[string]bool)
+	for _, r := range runs.InFlight() {
+		waiting[r.UUID] = true
+	}
+	if len(waiting) == 0 {
+		return 0
+	}
+	log.Infoln("shutdown: Waiting up to", timeout, "for", len(waiting), "runs in flight to report")
+
+	deadline := c.Now().Add(timeout)
+	for {
+		remaining := 0
+		for _, r := range runs.InFlight() {
+			if waiting[r.UUID] {
+				remaining++
+			}
+		}
+		if remaining == 0 || !c.Now().Before(deadline) {
+			return remaining
+		}
+		c.Sleep(shutdownWaitPollInterval)
+	}
+}
+
+// applyShutdownPolicy prepares the runs in flight to be stopped as the shutdown policy says
+func (k *Kuberhealthy) applyShutdownPolicy() {
+	switch shutdownPolicy() {
+	case shutdownPolicyAdopt:
+		log.Infoln("shutdown: Leaving", len(k.runTracker.InFlight()), "runs in flight for the next master to adopt")
+		k.runTracker.Freeze()
+	case shutdownPolicyWait:
+		remaining := waitForInFlightRuns(k.runTracker, k.clock(), shutdownWaitTimeout())
+		if remaining > 0 {
+			log.Warningln("shutdown:", remaining, "runs in flight did not report in time and will be canceled")
+		}
+	}
+}
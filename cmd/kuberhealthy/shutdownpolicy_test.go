@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/duration"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/testutil"
+)
+
+// TestShutdownPolicy ensures unknown shutdown policies fall back to deleting runs and the wait timeout is capped
+func TestShutdownPolicy(t *testing.T) {
+	oldCfg := cfg
+	defer func() {
+		cfg = oldCfg
+	}()
+
+	var testCases = []struct {
+		policy   string
+		expected string
+	}{
+		{"", shutdownPolicyDelete},
+		{"delete", shutdownPolicyDelete},
+		{"adopt", shutdownPolicyAdopt},
+		{"wait", shutdownPolicyWait},
+		{"orphan", shutdownPolicyDelete},
+	}
+	for _, tc := range testCases {
+		cfg = &Config{ShutdownPolicy: tc.policy}
+		policy := shutdownPolicy()
+		if policy != tc.expected {
+			t.Fatalf("shutdown policy %q resulted in %q but expected %q", tc.policy, policy, tc.expected)
+		}
+	}
+
+	cfg = &Config{}
+	if timeout := shutdownWaitTimeout(); timeout != defaultShutdownWaitTimeout {
+		t.Fatalf("expected the default wait timeout of %s but got %s", defaultShutdownWaitTimeout, timeout)
+	}
+	cfg = &Config{ShutdownWaitTimeout: duration.Duration{Duration: terminationGracePeriod}}
+	if timeout := shutdownWaitTimeout(); timeout != terminationGracePeriod/2 {
+		t.Fatalf("expected the wait timeout to be capped to %s but got %s", terminationGracePeriod/2, timeout)
+	}
+}
+
+// TestWaitForInFlightRuns ensures shutdown waits on the runs in flight until they report or the timeout passes
+func TestWaitForInFlightRuns(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	runs := external.NewRunTracker()
+	runs.Start("reports", "check", "kuberhealthy", "check-1", clock.Now().Add(time.Hour))
+	runs.Start("hangs", "other", "kuberhealthy", "other-1", clock.Now().Add(time.Hour))
+
+	remaining := make(chan int)
+	go func() {
+		remaining <- waitForInFlightRuns(runs, clock, time.Second*10)
+	}()
+
+	// one run reports while waiting and the other is still running when the timeout passes
+	clock.BlockUntil(1)
+	runs.End("reports")
+	for i := 0; i < 10; i++ {
+		clock.BlockUntil(1)
+		clock.Step(shutdownWaitPollInterval)
+	}
+
+	select {
+	case r := <-remaining:
+		if r != 1 {
+			t.Fatalf("expected 1 run to still be running after the timeout but got %d", r)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected waiting on runs in flight to stop at the timeout")
+	}
+
+	runs.End("hangs")
+	if r := waitForInFlightRuns(runs, clock, time.Second*10); r != 0 {
+		t.Fatalf("expected no runs to be waited on but got %d", r)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// errUnsignedReport is returned when a report without a signature arrives while signed reports are required
+var errUnsignedReport = errors.New("report is not signed but kuberhealthy requires signed reports")
+
+// errReportSignatureMismatch is returned when the signature of a report was not made with the signing key of its run
+var errReportSignatureMismatch = errors.New("report signature does not match the signing key of its run")
+
+// reportSigningEnabled tells if reports must be signed with the signing key of their run
+func reportSigningEnabled() bool {
+	return cfg != nil && len(cfg.ReportSigningKey) > 0
+}
+
+// verifyReportSignature ensures a report body was signed with the signing key of the run with the supplied UUID.  This
+// keeps pods that learn the reporting URL and a run UUID from spoofing the results of checks.  Every report is
+// accepted when signed reports are not required.
+func verifyReportSignature(r *http.Request, uuid string, body []byte) error {
+	if !reportSigningEnabled() {
+		return nil
+	}
+	signature := r.Header.Get(external.KHSignatureHeader)
+	if len(signature) == 0 {
+		return errUnsignedReport
+	}
+	if !external.VerifyReportSignature(external.RunSigningKey(cfg.ReportSigningKey, uuid), body, signature) {
+		return errReportSignatureMismatch
+	}
+	return nil
+}
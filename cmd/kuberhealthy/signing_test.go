@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// TestVerifyReportSignature ensures reports are only accepted when signed with the signing key of their run once
+// signed reports are required
+func TestVerifyReportSignature(t *testing.T) {
+	oldCfg := cfg
+	defer func() {
+		cfg = oldCfg
+	}()
+
+	body := []byte(`{"OK":true,"Errors":[]}`)
+	signed := external.SignReport(external.RunSigningKey("report signing key", "run-1"), body)
+
+	var testCases = []struct {
+		description string
+		key         string
+		signature   string
+		expected    error
+	}{
+		{"signing not required", "", "", nil},
+		{"signed by the run", "report signing key", signed, nil},
+		{"unsigned", "report signing key", "", errUnsignedReport},
+		{"signed by another run", "report signing key", external.SignReport(external.RunSigningKey("report signing key", "run-2"), body), errReportSignatureMismatch},
+		{"signed with another report signing key", "another key", signed, errReportSignatureMismatch},
+	}
+	for _, tc := range testCases {
+		cfg = &Config{ReportSigningKey: tc.key}
+		r := httptest.NewRequest("POST", "/externalCheckStatus", bytes.NewReader(body))
+		if len(tc.signature) > 0 {
+			r.Header.Set(external.KHSignatureHeader, tc.signature)
+		}
+		err := verifyReportSignature(r, "run-1", body)
+		if !errors.Is(err, tc.expected) {
+			t.Fatalf("%s: expected %v, got %v", tc.description, tc.expected, err)
+		}
+	}
+}
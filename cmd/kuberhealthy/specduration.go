@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/duration"
+)
+
+// parseSpecDuration parses a duration field from a khcheck or khjob spec.  Empty fields use the supplied default.
+// Invalid fields also use the default and return an error naming the field so that it can be surfaced in the status
+// of the check.
+func parseSpecDuration(field string, value string, defaultDuration time.Duration) (time.Duration, error) {
+	d, err := duration.ParseOrDefault(value, defaultDuration)
+	if err != nil {
+		return defaultDuration, fmt.Errorf("spec.%s: %w", field, err)
+	}
+	if d == 0 {
+		return defaultDuration, fmt.Errorf("spec.%s: duration %q must be greater than zero", field, value)
+	}
+	return d, nil
+}
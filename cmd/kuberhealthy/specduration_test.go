@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseSpecDuration ensures spec durations accept duration strings and seconds and that invalid values fall back
+// to the default with an error
+func TestParseSpecDuration(t *testing.T) {
+	var testCases = []struct {
+		value    string
+		expected time.Duration
+		err      bool
+	}{
+		{"", DefaultTimeout, false},
+		{"90s", 90 * time.Second, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"300", 5 * time.Minute, false},
+		{"0s", DefaultTimeout, true},
+		{"five minutes", DefaultTimeout, true},
+		{"-1m", DefaultTimeout, true},
+	}
+
+	for _, tc := range testCases {
+		d, err := parseSpecDuration("timeout", tc.value, DefaultTimeout)
+		if (err != nil) != tc.err {
+			t.Fatalf("parsing %q returned error %v but expected error to be %t", tc.value, err, tc.err)
+		}
+		if d != tc.expected {
+			t.Fatalf("parsing %q returned %s but expected %s", tc.value, d, tc.expected)
+		}
+	}
+}
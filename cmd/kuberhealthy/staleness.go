@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/health"
+)
+
+// defaultStaleResultIntervals is how many run intervals a check can go without recording a result before it is shown
+// as unknown, unless staleResultIntervals says otherwise
+const defaultStaleResultIntervals = 3
+
+// staleResultIntervals returns how many run intervals a check can go without recording a result before it is shown
+// as unknown.  Zero turns stale result detection off.
+func staleResultIntervals() int {
+	if cfg == nil || cfg.StaleResultIntervals == 0 {
+		return defaultStaleResultIntervals
+	}
+	if cfg.StaleResultIntervals < 0 {
+		return 0
+	}
+	return cfg.StaleResultIntervals
+}
+
+// resultState tells if the details of a check or job show it passing, passing with warnings or failing
+func resultState(details khstatev1.WorkloadDetails) khstatev1.ResultState {
+	if !details.OK {
+		return khstatev1.ResultFailed
+	}
+	if len(details.Warnings) > 0 {
+		return khstatev1.ResultWarning
+	}
+	return khstatev1.ResultOK
+}
+
+// staleAfter returns how long a check with the supplied run interval and timeout can go without recording a result
+// before it is shown as unknown.  Runs can legitimately take an interval and a timeout to record a result, so the
+// limit is never shorter than that.
+func staleAfter(intervals int, runInterval time.Duration, timeout time.Duration) time.Duration {
+	limit := time.Duration(intervals) * runInterval
+	if limit < runInterval+timeout {
+		limit = runInterval + timeout
+	}
+	return limit
+}
+
+// isStale indicates that a check has not recorded a result within its limit.  Checks whose runs are backed off after
+// provisioning errors are not stale, as they are expected to skip runs and already show as failed.
+func isStale(details khstatev1.WorkloadDetails, limit time.Duration, now time.Time) bool {
+	if details.LastRun == nil {
+		return false
+	}
+	if details.BackoffUntil != nil && now.Before(details.BackoffUntil.Time) {
+		return false
+	}
+	return now.Sub(details.LastRun.Time) > limit
+}
+
+// checkSchedule returns the run interval and timeout of the khcheck with the supplied namespace and name from the
+// cache.  Nothing is returned until the khcheck cache has synced.
+func (sr *StateReflector) checkSchedule(namespace string, name string) (time.Duration, time.Duration, bool) {
+	if sr == nil || !sr.workloadsSynced() {
+		return 0, 0, false
+	}
+	item, exists, err := sr.checkStore.GetByKey(namespace + "/" + name)
+	if err != nil || !exists {
+		return 0, 0, false
+	}
+	khCheck, ok := item.(*khcheckv1.KuberhealthyCheck)
+	if !ok {
+		return 0, 0, false
+	}
+	runInterval, _ := parseSpecDuration("runInterval", khCheck.Spec.RunInterval, DefaultRunInterval)
+	timeout, _ := parseSpecDuration("timeout", khCheck.Spec.Timeout, DefaultTimeout)
+	return runInterval, timeout, true
+}
+
+// markStaleResults shows checks that have not recorded a result for too long as unknown.  Only the checks that are
+// stale are changed, on a copy of the state, so that the shared snapshot of the reflector is left alone.  Jobs run
+// once rather than on an interval and are never stale.
+func markStaleResults(state health.State, now time.Time, schedule func(namespace string, name string) (time.Duration, time.Duration, bool)) health.State {
+	intervals := staleResultIntervals()
+	if intervals == 0 {
+		return state
+	}
+
+	var stale []string
+	for key, details := range state.CheckDetails {
+		namespace, name, _ := strings.Cut(key, "/")
+		runInterval, timeout, ok := schedule(namespace, name)
+		if !ok {
+			continue
+		}
+		if isStale(details, staleAfter(intervals, runInterval, timeout), now) {
+			stale = append(stale, key)
+		}
+	}
+	if len(stale) == 0 {
+		return state
+	}
+
+	state = state.Copy()
+	for _, key := range stale {
+		details := state.CheckDetails[key]
+		log.Debugln("Status page: Showing check", key, "as unknown because it has not recorded a result since", details.LastRun.Time)
+		details.State = khstatev1.ResultUnknown
+		state.CheckDetails[key] = details
+	}
+	return state
+}
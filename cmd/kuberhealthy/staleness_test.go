@@ -0,0 +1,106 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/health"
+)
+
+// TestStaleAfter ensures checks get at least a run interval and a timeout to record a result
+func TestStaleAfter(t *testing.T) {
+	var testCases = []struct {
+		intervals   int
+		runInterval time.Duration
+		timeout     time.Duration
+		expected    time.Duration
+	}{
+		{3, time.Minute * 10, time.Minute * 5, time.Minute * 30},
+		{3, time.Minute, time.Minute * 5, time.Minute * 6},
+		{1, time.Hour, time.Minute, time.Hour + time.Minute},
+	}
+	for _, tc := range testCases {
+		limit := staleAfter(tc.intervals, tc.runInterval, tc.timeout)
+		if limit != tc.expected {
+			t.Fatalf("%d intervals of %s with a timeout of %s gave a limit of %s but expected %s", tc.intervals, tc.runInterval, tc.timeout, limit, tc.expected)
+		}
+	}
+}
+
+// TestResultState ensures checks that passed with warnings are shown as warning and failed checks as failed even when
+// they also reported warnings
+func TestResultState(t *testing.T) {
+	var testCases = []struct {
+		details  khstatev1.WorkloadDetails
+		expected khstatev1.ResultState
+	}{
+		{khstatev1.WorkloadDetails{OK: true}, khstatev1.ResultOK},
+		{khstatev1.WorkloadDetails{OK: true, Warnings: []string{"certificate expires in 20 days"}}, khstatev1.ResultWarning},
+		{khstatev1.WorkloadDetails{Errors: []string{"certificate expired"}}, khstatev1.ResultFailed},
+		{khstatev1.WorkloadDetails{Errors: []string{"dns failed"}, Warnings: []string{"certificate expires in 20 days"}}, khstatev1.ResultFailed},
+	}
+	for _, tc := range testCases {
+		if state := resultState(tc.details); state != tc.expected {
+			t.Fatalf("details %+v gave state %q but expected %q", tc.details, state, tc.expected)
+		}
+	}
+}
+
+// TestMarkStaleResults ensures only checks that have not recorded a result for too long are shown as unknown and
+// that the shared state is left alone
+func TestMarkStaleResults(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg = &Config{}
+
+	now := time.Now()
+	lastRun := func(ago time.Duration) *metav1.Time {
+		t := metav1.NewTime(now.Add(-ago))
+		return &t
+	}
+	backoffUntil := metav1.NewTime(now.Add(time.Hour))
+
+	state := health.NewState()
+	state.CheckDetails["kuberhealthy/fresh"] = khstatev1.WorkloadDetails{OK: true, State: khstatev1.ResultOK, LastRun: lastRun(time.Minute * 20)}
+	state.CheckDetails["kuberhealthy/stuck"] = khstatev1.WorkloadDetails{OK: true, State: khstatev1.ResultOK, LastRun: lastRun(time.Hour * 2)}
+	state.CheckDetails["kuberhealthy/backed-off"] = khstatev1.WorkloadDetails{State: khstatev1.ResultFailed, LastRun: lastRun(time.Hour * 2), BackoffUntil: &backoffUntil}
+	state.CheckDetails["kuberhealthy/self-check"] = khstatev1.WorkloadDetails{OK: true, State: khstatev1.ResultOK, LastRun: lastRun(time.Hour * 2)}
+	state.JobDetails["kuberhealthy/job"] = khstatev1.WorkloadDetails{OK: true, State: khstatev1.ResultOK, LastRun: lastRun(time.Hour * 24)}
+
+	schedule := func(namespace string, name string) (time.Duration, time.Duration, bool) {
+		if name == "self-check" {
+			return 0, 0, false
+		}
+		return time.Minute * 10, time.Minute * 5, true
+	}
+
+	marked := markStaleResults(state, now, schedule)
+	expected := map[string]khstatev1.ResultState{
+		"kuberhealthy/fresh":      khstatev1.ResultOK,
+		"kuberhealthy/stuck":      khstatev1.ResultUnknown,
+		"kuberhealthy/backed-off": khstatev1.ResultFailed,
+		"kuberhealthy/self-check": khstatev1.ResultOK,
+	}
+	for key, expectedState := range expected {
+		if marked.CheckDetails[key].State != expectedState {
+			t.Fatalf("check %s has state %q but expected %q", key, marked.CheckDetails[key].State, expectedState)
+		}
+	}
+	if !marked.CheckDetails["kuberhealthy/stuck"].OK {
+		t.Fatal("showing a check as unknown changed its last result")
+	}
+	if marked.JobDetails["kuberhealthy/job"].State != khstatev1.ResultOK {
+		t.Fatal("a job was shown as unknown")
+	}
+	if state.CheckDetails["kuberhealthy/stuck"].State != khstatev1.ResultOK {
+		t.Fatal("marking stale results changed the shared state")
+	}
+
+	cfg.StaleResultIntervals = -1
+	if markStaleResults(state, now, schedule).CheckDetails["kuberhealthy/stuck"].State != khstatev1.ResultOK {
+		t.Fatal("a check was shown as unknown with stale result detection turned off")
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/tools/cache"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/health"
+)
+
+// statusDeltaPath is the path of the endpoint that serves the checks that changed since a cursor
+const statusDeltaPath = "/api/v2/status/delta"
+
+// deltaCursor is the position a client last polled the status from.  Either a sequence number issued by the state
+// change log of a replica or a time.
+type deltaCursor struct {
+	epoch    string
+	sequence uint64
+	time     time.Time
+}
+
+// parseDeltaCursor parses the since parameter of a delta request.  Cursors returned by a previous delta are an epoch
+// and a sequence number separated by a dot, and anything else must be an RFC3339 timestamp.  Bare integers are
+// cursors issued by older versions of Kuberhealthy and match every check, as does a blank cursor.
+func parseDeltaCursor(since string) (deltaCursor, error) {
+	if len(since) == 0 {
+		return deltaCursor{}, nil
+	}
+
+	epoch, sequence := "", since
+	if i := strings.LastIndex(since, "."); i > 0 {
+		epoch, sequence = since[:i], since[i+1:]
+	}
+	seq, err := strconv.ParseUint(sequence, 10, 64)
+	if err == nil {
+		return deltaCursor{epoch: epoch, sequence: seq}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return deltaCursor{}, errors.New("since must be the cursor returned by a previous delta or an RFC3339 timestamp")
+	}
+	return deltaCursor{time: t}, nil
+}
+
+// changedSince indicates that a khstate changed after the cursor.  Sequence cursors issued by another replica, or by
+// this one before it restarted, can't be compared with the changes seen by this replica and match every khstate.
+func (c deltaCursor) changedSince(state *khstatev1.KuberhealthyState, changes stateChanges) bool {
+	if len(c.epoch) > 0 && c.epoch == changes.epoch {
+		return changes.changed[state.GetNamespace()+"/"+state.GetName()] > c.sequence
+	}
+	if !c.time.IsZero() {
+		return state.Spec.LastRun == nil || state.Spec.LastRun.Time.After(c.time)
+	}
+	return true
+}
+
+// stateChangeLog numbers the changes to the khstates in the cache of this replica.  Each change to the spec of a
+// khstate is given the next number in the sequence, which is handed to delta clients as their cursor.  The numbers
+// are issued by Kuberhealthy rather than taken from the API, so they only ever grow.  The epoch tells the sequences of
+// replicas, and of restarts of the same replica, apart.
+type stateChangeLog struct {
+	mu       sync.Mutex
+	epoch    string
+	sequence uint64
+	changed  map[string]uint64 // the sequence number of the last change of each khstate by namespace/name
+}
+
+// newStateChangeLog creates a state change log with a new epoch
+func newStateChangeLog() *stateChangeLog {
+	return &stateChangeLog{
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		changed: make(map[string]uint64),
+	}
+}
+
+// record gives the change of the khstate with the supplied key the next sequence number
+func (l *stateChangeLog) record(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sequence++
+	l.changed[key] = l.sequence
+}
+
+// forget drops a khstate that was deleted
+func (l *stateChangeLog) forget(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.changed, key)
+}
+
+// snapshot returns a copy of the changes recorded so far.  The snapshot must be taken before the khstates are read
+// from the cache, so that changes made in between are sent again on the next request rather than missed.
+func (l *stateChangeLog) snapshot() stateChanges {
+	if l == nil {
+		return stateChanges{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	changed := make(map[string]uint64, len(l.changed))
+	for k, v := range l.changed {
+		changed[k] = v
+	}
+	return stateChanges{epoch: l.epoch, sequence: l.sequence, changed: changed}
+}
+
+// stateChanges is a snapshot of a state change log
+type stateChanges struct {
+	epoch    string
+	sequence uint64
+	changed  map[string]uint64
+}
+
+// cursor returns the cursor clients pass back to receive the changes made after the snapshot
+func (c stateChanges) cursor() string {
+	return c.epoch + "." + strconv.FormatUint(c.sequence, 10)
+}
+
+// changeLoggingStore is a cache store that records changes to the spec of the khstates it holds in a state change
+// log.  Changes are recorded after the store is updated.
+type changeLoggingStore struct {
+	cache.Store
+	changes *stateChangeLog
+}
+
+// Add adds a khstate to the store and records it as changed
+func (s *changeLoggingStore) Add(obj interface{}) error {
+	return s.Update(obj)
+}
+
+// Update updates a khstate in the store and records the change when its spec differs from the cached one
+func (s *changeLoggingStore) Update(obj interface{}) error {
+	changed := s.specChanged(obj)
+	err := s.Store.Update(obj)
+	if err == nil && changed {
+		s.recordKey(obj)
+	}
+	return err
+}
+
+// Delete deletes a khstate from the store and forgets its changes
+func (s *changeLoggingStore) Delete(obj interface{}) error {
+	err := s.Store.Delete(obj)
+	if key, keyErr := cache.MetaNamespaceKeyFunc(obj); err == nil && keyErr == nil {
+		s.changes.forget(key)
+	}
+	return err
+}
+
+// Replace replaces the contents of the store and records the khstates whose spec changed along the way
+func (s *changeLoggingStore) Replace(list []interface{}, resourceVersion string) error {
+	var changed []interface{}
+	for _, obj := range list {
+		if s.specChanged(obj) {
+			changed = append(changed, obj)
+		}
+	}
+	err := s.Store.Replace(list, resourceVersion)
+	if err != nil {
+		return err
+	}
+	for _, obj := range changed {
+		s.recordKey(obj)
+	}
+	return nil
+}
+
+// specChanged determines if the spec of a khstate differs from the one in the store, or if it is not stored yet
+func (s *changeLoggingStore) specChanged(obj interface{}) bool {
+	state, ok := obj.(*khstatev1.KuberhealthyState)
+	if !ok {
+		return true
+	}
+	cached, exists, err := s.Store.Get(obj)
+	if err != nil || !exists {
+		return true
+	}
+	cachedState, ok := cached.(*khstatev1.KuberhealthyState)
+	return !ok || !reflect.DeepEqual(cachedState.Spec, state.Spec)
+}
+
+// recordKey records a change of the supplied khstate
+func (s *changeLoggingStore) recordKey(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err == nil {
+		s.changes.record(key)
+	}
+}
+
+// stateDelta works out which of the supplied khstates changed since the cursor.  The returned cursor marks the
+// changes in the supplied snapshot, which clients pass back on their next request.  The workloadOf func is only called
+// for changed khstates.
+func stateDelta(states []*khstatev1.KuberhealthyState, since string, cursor deltaCursor, changes stateChanges, workloadOf func(name string, namespace string) khstatev1.KHWorkload) health.Delta {
+	delta := health.NewDelta(since)
+
+	for _, state := range states {
+		// checks that have never run are hidden, the same as on the status page
+		if len(state.Spec.AuthoritativePod) == 0 {
+			continue
+		}
+
+		key := state.GetNamespace() + "/" + state.GetName()
+		delta.Keys = append(delta.Keys, key)
+		for _, e := range state.Spec.Errors {
+			if len(strings.TrimSpace(e)) > 0 {
+				delta.OK = false
+			}
+		}
+
+		if !cursor.changedSince(state, changes) {
+			continue
+		}
+		details := withoutArtifacts(state.Spec)
+		details.State = resultState(details)
+		switch workloadOf(state.GetName(), state.GetNamespace()) {
+		case khstatev1.KHCheck:
+			delta.CheckDetails[key] = details
+		case khstatev1.KHJob:
+			delta.JobDetails[key] = details
+		}
+	}
+
+	sort.Strings(delta.Keys)
+	if len(changes.epoch) > 0 {
+		delta.Cursor = changes.cursor()
+	}
+	return delta
+}
+
+// statusDeltaHandler serves only the checks and jobs whose state changed since the cursor in the since query
+// parameter.  This keeps the payload small for external dashboards that poll the status frequently.
+func (k *Kuberhealthy) statusDeltaHandler(w http.ResponseWriter, r *http.Request) error {
+	log.Infoln("Client connected to status delta endpoint from", r.RemoteAddr, r.UserAgent())
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	since := r.URL.Query().Get("since")
+	cursor, err := parseDeltaCursor(since)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error()))
+		return nil
+	}
+
+	changes := k.stateReflector.changes.snapshot()
+	delta := stateDelta(k.stateReflector.States(), since, cursor, changes, k.stateReflector.workloadResolver())
+
+	b, err := json.MarshalIndent(delta, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to marshal status delta: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = health.WriteCacheable(w, r, b)
+	if err != nil {
+		log.Warningln("Error writing status delta to caller:", err)
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+)
+
+// testState makes a khstate with the supplied resourceVersion, last run time and errors
+func testState(name string, resourceVersion string, lastRun time.Time, errors ...string) *khstatev1.KuberhealthyState {
+	details := khstatev1.NewWorkloadDetails(khstatev1.KHCheck)
+	details.OK = len(errors) == 0
+	details.Errors = errors
+	details.AuthoritativePod = "kuberhealthy-abc"
+	t := metav1.NewTime(lastRun)
+	details.LastRun = &t
+	state := khstatev1.NewKuberhealthyState(name, details)
+	state.Namespace = "kuberhealthy"
+	state.ResourceVersion = resourceVersion
+	return &state
+}
+
+// TestStateDelta ensures only khstates changed after the cursor are returned
+func TestStateDelta(t *testing.T) {
+	now := time.Now()
+	changes := newStateChangeLog()
+	store := &changeLoggingStore{Store: cache.NewStore(cache.MetaNamespaceKeyFunc), changes: changes}
+
+	old := testState("old", "100", now.Add(-time.Hour))
+	neverRan := testState("never-ran", "101", now)
+	neverRan.Spec.AuthoritativePod = ""
+	err := store.Replace([]interface{}{old, neverRan}, "101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := changes.snapshot().cursor()
+
+	err = store.Add(testState("new", "205", now.Add(-time.Minute), "broken"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Add(testState("newest", "210", now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// writes that leave the spec alone, such as a resync, are not changes
+	unchanged := testState("old", "300", now.Add(-time.Hour))
+	err = store.Update(unchanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := changes.snapshot().cursor()
+
+	var states []*khstatev1.KuberhealthyState
+	for _, item := range store.List() {
+		states = append(states, item.(*khstatev1.KuberhealthyState))
+	}
+
+	workloadOf := func(name string, namespace string) khstatev1.KHWorkload {
+		if name == "newest" {
+			return khstatev1.KHJob
+		}
+		return khstatev1.KHCheck
+	}
+
+	var testCases = []struct {
+		since  string
+		checks []string
+		jobs   []string
+	}{
+		{"", []string{"kuberhealthy/new", "kuberhealthy/old"}, []string{"kuberhealthy/newest"}},
+		{first, []string{"kuberhealthy/new"}, []string{"kuberhealthy/newest"}},
+		{latest, []string{}, []string{}},
+		{"another-replica.3", []string{"kuberhealthy/new", "kuberhealthy/old"}, []string{"kuberhealthy/newest"}},
+		{"120455", []string{"kuberhealthy/new", "kuberhealthy/old"}, []string{"kuberhealthy/newest"}},
+		{now.Add(-time.Minute * 30).Format(time.RFC3339), []string{"kuberhealthy/new"}, []string{"kuberhealthy/newest"}},
+	}
+
+	for _, tc := range testCases {
+		cursor, err := parseDeltaCursor(tc.since)
+		if err != nil {
+			t.Fatalf("failed to parse cursor %q: %s", tc.since, err)
+		}
+		delta := stateDelta(states, tc.since, cursor, changes.snapshot(), workloadOf)
+
+		var checks, jobs []string
+		checks, jobs = []string{}, []string{}
+		for k := range delta.CheckDetails {
+			checks = append(checks, k)
+		}
+		for k := range delta.JobDetails {
+			jobs = append(jobs, k)
+		}
+		sort.Strings(checks)
+		sort.Strings(jobs)
+		if !reflect.DeepEqual(checks, tc.checks) || !reflect.DeepEqual(jobs, tc.jobs) {
+			t.Fatalf("since %q returned checks %v and jobs %v but expected %v and %v", tc.since, checks, jobs, tc.checks, tc.jobs)
+		}
+		if delta.Cursor != latest {
+			t.Fatalf("since %q returned cursor %s but expected %s", tc.since, delta.Cursor, latest)
+		}
+		if delta.OK || len(delta.Keys) != 3 {
+			t.Fatalf("since %q returned ok %t with keys %v but expected false with 3 keys", tc.since, delta.OK, delta.Keys)
+		}
+	}
+}
+
+// TestParseDeltaCursor ensures invalid cursors are rejected
+func TestParseDeltaCursor(t *testing.T) {
+	for _, since := range []string{"yesterday", "-5", "2023-03-01", "epoch.x"} {
+		_, err := parseDeltaCursor(since)
+		if err == nil {
+			t.Fatalf("cursor %q was accepted", since)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// validateSubChecks ensures the sub-checks of a report are valid and agree with its result.  A report can't pass
+// while one of its sub-checks failed.
+func validateSubChecks(state status.Report) error {
+	if len(state.SubChecks) == 0 {
+		return nil
+	}
+	if err := status.ValidateSubChecks(state.SubChecks); err != nil {
+		return err
+	}
+	if !state.OK {
+		return nil
+	}
+	for _, sc := range state.SubChecks {
+		if !sc.OK {
+			return fmt.Errorf("report is OK but its sub-check %s failed", sc.Name)
+		}
+	}
+	return nil
+}
+
+// newSubCheckStatuses turns the sub-checks of a report into the sub-check statuses stored on its khstate, in the
+// order they were reported
+func newSubCheckStatuses(subChecks []status.SubCheck) []khstatev1.SubCheckStatus {
+	if len(subChecks) == 0 {
+		return nil
+	}
+	statuses := make([]khstatev1.SubCheckStatus, 0, len(subChecks))
+	for _, sc := range subChecks {
+		statuses = append(statuses, khstatev1.SubCheckStatus{Name: sc.Name, OK: sc.OK, Errors: sc.Errors})
+	}
+	return statuses
+}
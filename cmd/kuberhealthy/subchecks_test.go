@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestValidateSubChecks ensures sub-checks are only accepted when they are valid and agree with the report result
+func TestValidateSubChecks(t *testing.T) {
+	var testCases = []struct {
+		description string
+		report      status.Report
+		expectErr   bool
+	}{
+		{"no sub-checks", status.NewReport([]string{}), false},
+		{"passing sub-checks", status.NewSubCheckReport([]status.SubCheck{{Name: "a", OK: true}, {Name: "b", OK: true}}), false},
+		{"failing sub-check", status.NewSubCheckReport([]status.SubCheck{{Name: "a", OK: true}, {Name: "b", Errors: []string{"timed out"}}}), false},
+		{"failed sub-check on an OK report", status.Report{OK: true, SubChecks: []status.SubCheck{{Name: "a", Errors: []string{"timed out"}}}}, true},
+		{"failed sub-check without errors", status.Report{Errors: []string{"failed"}, SubChecks: []status.SubCheck{{Name: "a"}}}, true},
+		{"duplicate names", status.Report{OK: true, SubChecks: []status.SubCheck{{Name: "a", OK: true}, {Name: "a", OK: true}}}, true},
+		{"blank name", status.Report{OK: true, SubChecks: []status.SubCheck{{Name: " ", OK: true}}}, true},
+	}
+	for _, tc := range testCases {
+		err := validateSubChecks(tc.report)
+		if (err != nil) != tc.expectErr {
+			t.Fatalf("%s: returned error %v but expected an error: %t", tc.description, err, tc.expectErr)
+		}
+	}
+}
+
+// TestNewSubCheckStatuses ensures the sub-checks of a report are stored in the order they were reported
+func TestNewSubCheckStatuses(t *testing.T) {
+	if statuses := newSubCheckStatuses(nil); statuses != nil {
+		t.Fatalf("expected no sub-check statuses but got %+v", statuses)
+	}
+	statuses := newSubCheckStatuses([]status.SubCheck{{Name: "kube-dns", OK: true}, {Name: "example.com", Errors: []string{"timed out"}}})
+	if len(statuses) != 2 || statuses[0].Name != "kube-dns" || !statuses[0].OK || statuses[1].OK || statuses[1].Errors[0] != "timed out" {
+		t.Fatalf("unexpected sub-check statuses %+v", statuses)
+	}
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/integrii/flaggy"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/health"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/kubeClient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/supportbundle"
+)
+
+// the files collected into a support bundle
+const (
+	bundleStatusFile    = "status.json"
+	bundleSchedulerFile = "scheduler.json"
+	bundleConfigFile    = "config/kuberhealthy.yaml"
+	bundleKHStatesFile  = "resources/khstates.yaml"
+	bundleKHChecksFile  = "resources/khchecks.yaml"
+	bundleKHJobsFile    = "resources/khjobs.yaml"
+	bundleLogsDir       = "logs/"
+)
+
+// supportBundleOptions customize the support-bundle subcommand
+type supportBundleOptions struct {
+	Namespace      string
+	Output         string
+	LogLines       int
+	Inspect        string
+	File           string
+	KubeConfigFile string
+}
+
+// supportBundleCommand is the subcommand that collects and inspects support bundles
+var supportBundleCommand *flaggy.Subcommand
+
+// supportBundleOpts holds the options of the support-bundle subcommand
+var supportBundleOpts = supportBundleOptions{
+	Namespace: "kuberhealthy",
+	LogLines:  500,
+}
+
+// addSupportBundleCommand registers the support-bundle subcommand with flaggy
+func addSupportBundleCommand() {
+	supportBundleCommand = flaggy.NewSubcommand("support-bundle")
+	supportBundleCommand.Description = "Collects the status, check history, configuration, scheduler state and recent logs of Kuberhealthy into a tarball with secrets redacted, or inspects a collected tarball offline."
+	supportBundleCommand.String(&supportBundleOpts.Namespace, "n", "namespace", "Namespace Kuberhealthy runs in.")
+	supportBundleCommand.String(&supportBundleOpts.Output, "o", "output", "File to write the bundle to. Defaults to kuberhealthy-support-<time>.tar.gz.")
+	supportBundleCommand.Int(&supportBundleOpts.LogLines, "", "log-lines", "Number of recent log lines collected from each Kuberhealthy pod.")
+	supportBundleCommand.String(&supportBundleOpts.Inspect, "i", "inspect", "Summarize a bundle instead of collecting one.")
+	supportBundleCommand.String(&supportBundleOpts.File, "f", "file", "With --inspect, print a single file from the bundle instead of the summary.")
+	supportBundleCommand.String(&supportBundleOpts.KubeConfigFile, "k", "kubeconfig", "Kube config file used to collect the bundle when not running in a cluster.")
+	flaggy.AttachSubcommand(supportBundleCommand, 1)
+}
+
+// runSupportBundle collects a bundle from the cluster and writes it to a file, or inspects an existing bundle
+func runSupportBundle(ctx context.Context, o supportBundleOptions, out io.Writer) error {
+	if len(o.Inspect) > 0 {
+		f, err := os.Open(o.Inspect)
+		if err != nil {
+			return fmt.Errorf("failed to open bundle: %w", err)
+		}
+		defer f.Close()
+		return inspectSupportBundle(f, o.File, out)
+	}
+
+	restConfig, err := kubeClient.RestConfig(o.KubeConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to build kubernetes client config: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	dynClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	now := time.Now()
+	bundle := collectSupportBundle(ctx, client, dynClient, o, now)
+
+	output := o.Output
+	if len(output) == 0 {
+		output = "kuberhealthy-support-" + now.UTC().Format("20060102T150405Z") + ".tar.gz"
+	}
+	f, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", output, err)
+	}
+	defer f.Close()
+	err = bundle.Write(f)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	fmt.Fprintln(out, "Wrote", len(bundle.Manifest.Files), "files to", output)
+	for _, e := range bundle.Manifest.Errors {
+		fmt.Fprintln(out, "Could not collect:", e)
+	}
+	return nil
+}
+
+// collectSupportBundle gathers everything that goes into a support bundle.  Anything that can not be collected is
+// recorded in the manifest instead of failing the whole bundle so that a partially broken install can still be
+// reported.
+func collectSupportBundle(ctx context.Context, client kubernetes.Interface, dynClient dynamic.Interface, o supportBundleOptions, now time.Time) *supportbundle.Bundle {
+	bundle := supportbundle.New(o.Namespace, now)
+
+	gvr := func(resource string) schema.GroupVersionResource {
+		return schema.GroupVersionResource{Group: checkCRDGroup, Version: checkCRDVersion, Resource: resource}
+	}
+	states, err := collectResources(ctx, dynClient, gvr(stateCRDResource), bundle, bundleKHStatesFile)
+	if err != nil {
+		bundle.AddError(err)
+	}
+	_, err = collectResources(ctx, dynClient, gvr(checkCRDResource), bundle, bundleKHChecksFile)
+	if err != nil {
+		bundle.AddError(err)
+	}
+	jobs, err := collectResources(ctx, dynClient, gvr(jobCRDResource), bundle, bundleKHJobsFile)
+	if err != nil {
+		bundle.AddError(err)
+	}
+
+	state, err := bundleStatus(states, jobs)
+	if err != nil {
+		bundle.AddError(err)
+	}
+	// errors reported by checks are free text, so redact the status as text
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		bundle.AddError(fmt.Errorf("failed to marshal status: %w", err))
+	}
+	bundle.Add(bundleStatusFile, supportbundle.RedactText(b))
+
+	err = collectBundleConfig(ctx, client, o.Namespace, bundle)
+	if err != nil {
+		bundle.AddError(err)
+	}
+
+	pods, err := client.CoreV1().Pods(o.Namespace).List(ctx, metav1.ListOptions{LabelSelector: "app=kuberhealthy"})
+	if err != nil {
+		bundle.AddError(fmt.Errorf("failed to list kuberhealthy pods: %w", err))
+		pods = &apiv1.PodList{}
+	}
+
+	err = collectBundleScheduler(client, o.Namespace, pods.Items, bundle)
+	if err != nil {
+		bundle.AddError(err)
+	}
+
+	for _, pod := range pods.Items {
+		err = collectBundleLogs(ctx, client, pod, o.LogLines, bundle)
+		if err != nil {
+			bundle.AddError(err)
+		}
+	}
+
+	return bundle
+}
+
+// collectResources lists a kuberhealthy custom resource across all namespaces and adds it to the bundle as YAML with
+// secrets redacted
+func collectResources(ctx context.Context, dynClient dynamic.Interface, gvr schema.GroupVersionResource, bundle *supportbundle.Bundle, name string) ([]unstructured.Unstructured, error) {
+	list, err := dynClient.Resource(gvr).Namespace("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", gvr.Resource, err)
+	}
+
+	items := make([]interface{}, 0, len(list.Items))
+	for _, item := range list.Items {
+		content := item.DeepCopy().UnstructuredContent()
+		// managed fields are noise in a bug report
+		if metadata, ok := content["metadata"].(map[string]interface{}); ok {
+			delete(metadata, "managedFields")
+		}
+		items = append(items, supportbundle.RedactObject(content))
+	}
+
+	b, err := yaml.Marshal(map[string]interface{}{"items": items})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", gvr.Resource, err)
+	}
+	bundle.Add(name, b)
+	return list.Items, nil
+}
+
+// bundleStatus builds the status page output from the listed khstates the same way the status page does.  States
+// named after a khjob are job details and all others are check details.
+func bundleStatus(states []unstructured.Unstructured, jobs []unstructured.Unstructured) (health.State, error) {
+	state := health.NewState()
+
+	jobKeys := make(map[string]bool, len(jobs))
+	for _, j := range jobs {
+		jobKeys[j.GetNamespace()+"/"+j.GetName()] = true
+	}
+
+	var convertErrs []string
+	for _, u := range states {
+		// khstates are decoded from JSON like the typed client does, since the unstructured converter can not skip
+		// the unexported fields of WorkloadDetails
+		var khState khstatev1.KuberhealthyState
+		b, err := u.MarshalJSON()
+		if err == nil {
+			err = json.Unmarshal(b, &khState)
+		}
+		if err != nil {
+			convertErrs = append(convertErrs, u.GetNamespace()+"/"+u.GetName()+": "+err.Error())
+			continue
+		}
+		if len(khState.Spec.AuthoritativePod) == 0 {
+			continue
+		}
+
+		for _, e := range khState.Spec.Errors {
+			if len(strings.TrimSpace(e)) == 0 {
+				continue
+			}
+			state.AddError(e)
+			state.OK = false
+		}
+		for _, w := range khState.Spec.Warnings {
+			if len(strings.TrimSpace(w)) == 0 {
+				continue
+			}
+			state.AddWarning(w)
+		}
+
+		key := khState.GetNamespace() + "/" + khState.GetName()
+		if jobKeys[key] {
+			state.JobDetails[key] = khState.Spec
+			continue
+		}
+		state.CheckDetails[key] = khState.Spec
+	}
+
+	if len(convertErrs) > 0 {
+		return state, fmt.Errorf("failed to read khstates: %s", strings.Join(convertErrs, ", "))
+	}
+	return state, nil
+}
+
+// collectBundleConfig adds the kuberhealthy configuration from its configmap to the bundle with secrets redacted
+func collectBundleConfig(ctx context.Context, client kubernetes.Interface, namespace string, bundle *supportbundle.Bundle) error {
+	cm, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, "kuberhealthy", metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get kuberhealthy configmap: %w", err)
+	}
+	raw, ok := cm.Data["kuberhealthy.yaml"]
+	if !ok {
+		return fmt.Errorf("kuberhealthy configmap has no kuberhealthy.yaml")
+	}
+
+	var config map[string]interface{}
+	err = yaml.Unmarshal([]byte(raw), &config)
+	if err != nil {
+		// keep what we can of configuration that does not parse, since that may well be the problem being reported
+		bundle.Add(bundleConfigFile, supportbundle.RedactText([]byte(raw)))
+		return fmt.Errorf("failed to parse kuberhealthy.yaml: %w", err)
+	}
+	b, err := yaml.Marshal(supportbundle.RedactObject(config))
+	if err != nil {
+		return fmt.Errorf("failed to marshal kuberhealthy.yaml: %w", err)
+	}
+	bundle.Add(bundleConfigFile, b)
+	return nil
+}
+
+// bundlePod is a kuberhealthy pod as recorded in the scheduler state of a bundle
+type bundlePod struct {
+	Name     string    `json:"name"`
+	Node     string    `json:"node"`
+	Phase    string    `json:"phase"`
+	Ready    bool      `json:"ready"`
+	Restarts int32     `json:"restarts"`
+	Created  time.Time `json:"created"`
+}
+
+// bundleScheduler is the scheduler state recorded in a bundle
+type bundleScheduler struct {
+	Pods         []bundlePod    `json:"pods"`         // the kuberhealthy pods, oldest first
+	RunsInFlight []external.Run `json:"runsInFlight"` // the runs that have not reported in yet
+}
+
+// collectBundleScheduler adds the kuberhealthy pods and the runs in flight to the bundle
+func collectBundleScheduler(client kubernetes.Interface, namespace string, pods []apiv1.Pod, bundle *supportbundle.Bundle) error {
+	scheduler := bundleScheduler{Pods: []bundlePod{}}
+	for _, p := range pods {
+		bp := bundlePod{Name: p.Name, Node: p.Spec.NodeName, Phase: string(p.Status.Phase), Created: p.CreationTimestamp.Time}
+		for _, c := range p.Status.Conditions {
+			if c.Type == apiv1.PodReady {
+				bp.Ready = c.Status == apiv1.ConditionTrue
+			}
+		}
+		for _, cs := range p.Status.ContainerStatuses {
+			bp.Restarts += cs.RestartCount
+		}
+		scheduler.Pods = append(scheduler.Pods, bp)
+	}
+	sort.Slice(scheduler.Pods, func(i, j int) bool {
+		return scheduler.Pods[i].Created.Before(scheduler.Pods[j].Created)
+	})
+
+	runs, err := newLeaseRunStore(client, namespace).Load()
+	scheduler.RunsInFlight = runs
+	if scheduler.RunsInFlight == nil {
+		scheduler.RunsInFlight = []external.Run{}
+	}
+	addErr := bundle.AddJSON(bundleSchedulerFile, scheduler)
+	if err != nil {
+		return fmt.Errorf("failed to load runs in flight: %w", err)
+	}
+	return addErr
+}
+
+// collectBundleLogs adds the most recent log lines of each container of a kuberhealthy pod to the bundle with secrets
+// redacted
+func collectBundleLogs(ctx context.Context, client kubernetes.Interface, pod apiv1.Pod, lines int, bundle *supportbundle.Bundle) error {
+	tail := int64(lines)
+	for _, c := range pod.Spec.Containers {
+		b, err := client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &apiv1.PodLogOptions{Container: c.Name, TailLines: &tail}).DoRaw(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get logs of %s/%s: %w", pod.Name, c.Name, err)
+		}
+		bundle.Add(bundleLogsDir+pod.Name+"/"+c.Name+".log", supportbundle.RedactText(b))
+	}
+	return nil
+}
+
+// inspectSupportBundle writes a summary of a bundle to out.  When file is set, only that file of the bundle is
+// written instead.
+func inspectSupportBundle(r io.Reader, file string, out io.Writer) error {
+	bundle, err := supportbundle.Read(r)
+	if err != nil {
+		return err
+	}
+
+	if len(file) > 0 {
+		content, ok := bundle.Files[file]
+		if !ok {
+			return fmt.Errorf("bundle has no file %s", file)
+		}
+		_, err = out.Write(content)
+		return err
+	}
+
+	fmt.Fprintln(out, "Collected:", bundle.Manifest.Created.Format(time.RFC3339))
+	fmt.Fprintln(out, "Namespace:", bundle.Manifest.Namespace)
+	for _, e := range bundle.Manifest.Errors {
+		fmt.Fprintln(out, "Could not collect:", e)
+	}
+
+	var state health.State
+	err = json.Unmarshal(bundle.Files[bundleStatusFile], &state)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", bundleStatusFile, err)
+	}
+	fmt.Fprintln(out, "OK:", state.OK)
+	fmt.Fprintln(out)
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "WORKLOAD\tTYPE\tOK\tLAST RUN\tERRORS")
+	writeDetails := func(kind khstatev1.KHWorkload, details map[string]khstatev1.WorkloadDetails) {
+		keys := make([]string, 0, len(details))
+		for key := range details {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			d := details[key]
+			lastRun := "never"
+			if d.LastRun != nil {
+				lastRun = d.LastRun.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%t\t%s\t%s\n", key, kind, d.OK, lastRun, strings.Join(d.Errors, "; "))
+		}
+	}
+	writeDetails(khstatev1.KHCheck, state.CheckDetails)
+	writeDetails(khstatev1.KHJob, state.JobDetails)
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Files:")
+	for _, name := range bundle.Manifest.Files {
+		fmt.Fprintln(out, " ", name)
+	}
+	return nil
+}
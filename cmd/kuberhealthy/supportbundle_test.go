@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/health"
+)
+
+// testKHResource makes an unstructured kuberhealthy resource of the supplied kind
+func testKHResource(kind string, namespace string, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": checkCRDGroup + "/" + checkCRDVersion,
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"namespace": namespace, "name": name},
+		"spec":       spec,
+	}}
+}
+
+// TestCollectSupportBundle ensures a bundle collects status, resources, configuration, scheduler state and logs with
+// secrets redacted, and that the collected bundle can be inspected
+func TestCollectSupportBundle(t *testing.T) {
+	gvr := func(resource string) schema.GroupVersionResource {
+		return schema.GroupVersionResource{Group: checkCRDGroup, Version: checkCRDVersion, Resource: resource}
+	}
+	dynClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		gvr(stateCRDResource): "KuberhealthyStateList",
+		gvr(checkCRDResource): "KuberhealthyCheckList",
+		gvr(jobCRDResource):   "KuberhealthyJobList",
+	})
+	resources := map[string][]*unstructured.Unstructured{
+		stateCRDResource: {
+			testKHResource("KuberhealthyState", "kuberhealthy", "dns", map[string]interface{}{
+				"OK": false, "Errors": []interface{}{"lookup failed with token=abc123"}, "AuthoritativePod": "kuberhealthy-abc",
+			}),
+			testKHResource("KuberhealthyState", "kuberhealthy", "once", map[string]interface{}{"OK": true, "AuthoritativePod": "kuberhealthy-abc"}),
+		},
+		checkCRDResource: {
+			testKHResource("KuberhealthyCheck", "kuberhealthy", "dns", map[string]interface{}{
+				"podSpec": map[string]interface{}{"containers": []interface{}{map[string]interface{}{
+					"name": "main",
+					"env":  []interface{}{map[string]interface{}{"name": "API_TOKEN", "value": "abc123"}},
+				}}},
+			}),
+		},
+		jobCRDResource: {testKHResource("KuberhealthyJob", "kuberhealthy", "once", map[string]interface{}{})},
+	}
+	for resource, objects := range resources {
+		for _, o := range objects {
+			_, err := dynClient.Resource(gvr(resource)).Namespace(o.GetNamespace()).Create(context.Background(), o, metav1.CreateOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	client := fake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "kuberhealthy", Namespace: "kuberhealthy"},
+			Data:       map[string]string{"kuberhealthy.yaml": "influxPassword: hunter2\nlogLevel: debug\n"},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "kuberhealthy-abc", Namespace: "kuberhealthy", Labels: map[string]string{"app": "kuberhealthy"}},
+			Spec:       corev1.PodSpec{NodeName: "node-1", Containers: []corev1.Container{{Name: "kuberhealthy"}}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning, Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}},
+		},
+	)
+
+	bundle := collectSupportBundle(context.Background(), client, dynClient, supportBundleOptions{Namespace: "kuberhealthy", LogLines: 10}, time.Now())
+	if len(bundle.Manifest.Errors) != 0 {
+		t.Fatalf("unexpected collection errors: %v", bundle.Manifest.Errors)
+	}
+
+	var state health.State
+	err := json.Unmarshal(bundle.Files[bundleStatusFile], &state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.OK || len(state.CheckDetails) != 1 || len(state.JobDetails) != 1 {
+		t.Fatalf("unexpected status: %+v", state)
+	}
+
+	for _, name := range []string{bundleStatusFile, bundleKHStatesFile, bundleKHChecksFile, bundleConfigFile} {
+		content := string(bundle.Files[name])
+		if len(content) == 0 {
+			t.Fatalf("expected %s to be collected", name)
+		}
+		if strings.Contains(content, "abc123") || strings.Contains(content, "hunter2") {
+			t.Fatalf("expected secrets to be redacted from %s:\n%s", name, content)
+		}
+	}
+	if _, ok := bundle.Files[bundleLogsDir+"kuberhealthy-abc/kuberhealthy.log"]; !ok {
+		t.Fatal("expected the logs of the kuberhealthy pod to be collected")
+	}
+	var scheduler bundleScheduler
+	err = json.Unmarshal(bundle.Files[bundleSchedulerFile], &scheduler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scheduler.Pods) != 1 || !scheduler.Pods[0].Ready || scheduler.Pods[0].Node != "node-1" {
+		t.Fatalf("unexpected scheduler state: %+v", scheduler)
+	}
+
+	var buf bytes.Buffer
+	err = bundle.Write(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	err = inspectSupportBundle(bytes.NewReader(buf.Bytes()), "", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"OK: false", "kuberhealthy/dns", "KHJob", bundleConfigFile} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("expected inspect output to contain %q:\n%s", expected, out.String())
+		}
+	}
+
+	out.Reset()
+	err = inspectSupportBundle(bytes.NewReader(buf.Bytes()), bundleConfigFile, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "logLevel: debug") {
+		t.Fatalf("expected the config file to be printed, got:\n%s", out.String())
+	}
+}
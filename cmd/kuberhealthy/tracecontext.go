@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// reportTraceMessage describes how the trace context a report was sent with relates to the trace of its run.  ok is
+// false when the report should have carried the trace of its run but carried no trace or another one.
+func reportTraceMessage(runTraceParent string, reportTraceParent string) (string, bool) {
+	runTraceID := external.TraceID(runTraceParent)
+	reportTraceID := external.TraceID(reportTraceParent)
+	switch {
+	case runTraceID == "" && reportTraceID == "":
+		return "", true
+	case runTraceID == "":
+		return "Report is part of trace " + reportTraceID + " which is not the trace of a known run", true
+	case reportTraceID == "":
+		return "Report was sent without the trace context of run trace " + runTraceID, false
+	case reportTraceID != runTraceID:
+		return "Report is part of trace " + reportTraceID + " but its run is traced as " + runTraceID, false
+	}
+	return "Report is part of trace " + reportTraceID, true
+}
+
+// logReportTrace logs the trace a report of the run with the supplied uuid was sent as part of, so that the log lines
+// of the controller, the checker pod and the report can be joined into one trace.  Reports are never refused because
+// of their trace context.
+func (k *Kuberhealthy) logReportTrace(requestID string, uuid string, r *http.Request) {
+	var runTraceParent string
+	if run, known := k.runTracker.Get(uuid); known {
+		runTraceParent = run.TraceParent
+	}
+	msg, ok := reportTraceMessage(runTraceParent, r.Header.Get(external.TraceParentHeader))
+	if msg == "" {
+		return
+	}
+	if !ok {
+		log.Warningln(requestID, msg)
+		return
+	}
+	k.externalCheckReportHandlerLog(requestID, msg)
+}
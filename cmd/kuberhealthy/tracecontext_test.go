@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// TestReportTraceMessage ensures reports are matched to the trace of their run
+func TestReportTraceMessage(t *testing.T) {
+	runTraceParent := external.NewTraceParent()
+	otherTraceParent := external.NewTraceParent()
+	var testCases = []struct {
+		description       string
+		runTraceParent    string
+		reportTraceParent string
+		expectMessage     bool
+		expectOK          bool
+	}{
+		{"untraced run and report", "", "", false, true},
+		{"matching trace", runTraceParent, runTraceParent, true, true},
+		{"report without trace", runTraceParent, "", true, false},
+		{"report with invalid trace", runTraceParent, "00-zz", true, false},
+		{"report of another trace", runTraceParent, otherTraceParent, true, false},
+		{"traced report of an untraced run", "", runTraceParent, true, true},
+	}
+	for _, tc := range testCases {
+		msg, ok := reportTraceMessage(tc.runTraceParent, tc.reportTraceParent)
+		if (msg != "") != tc.expectMessage || ok != tc.expectOK {
+			t.Fatalf("%s: returned message %q and ok %t", tc.description, msg, ok)
+		}
+	}
+}
@@ -2,11 +2,13 @@ package main
 
 import (
 	"errors"
+	"net"
 	"os"
 	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
 )
 
 // getEnvVar attempts to retrieve and then validates an environmental variable
@@ -69,3 +71,42 @@ func containsString(s string, list []string) bool {
 	}
 	return false
 }
+
+// remoteIP returns the IP address of a remote address in the host:port form used by http.Request.RemoteAddr.
+// IPv6 zones are dropped and IPv4 addresses that were mapped into IPv6 by a dual-stack listener are returned in
+// their IPv4 form so that they match the IPs Kubernetes reports for pods.
+func remoteIP(remoteAddr string) (string, error) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return "", err
+	}
+	if i := strings.Index(host, "%"); i >= 0 {
+		host = host[:i]
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "", errors.New("remote address " + remoteAddr + " does not contain a valid IP")
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String(), nil
+	}
+	return ip.String(), nil
+}
+
+// podHasIP determines if any of a pod's IPs are the supplied IP.  Dual-stack pods have an IPv4 and an IPv6 address.
+func podHasIP(pod v1.Pod, ip string) bool {
+	target := net.ParseIP(ip)
+	if target == nil {
+		return false
+	}
+	podIPs := []string{pod.Status.PodIP}
+	for _, podIP := range pod.Status.PodIPs {
+		podIPs = append(podIPs, podIP.IP)
+	}
+	for _, podIP := range podIPs {
+		if target.Equal(net.ParseIP(podIP)) {
+			return true
+		}
+	}
+	return false
+}
@@ -5,9 +5,11 @@ import (
 	"errors"
 	"io/ioutil"
 	"os"
+	"testing"
 
 	"github.com/ghodss/yaml"
 	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 
 	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
@@ -62,3 +64,59 @@ func loadTestPodSpecFile(path string) (*khcheckv1.KuberhealthyCheck, error) {
 	err = json.Unmarshal(j, &podSpec)
 	return &podSpec, err
 }
+
+// TestRemoteIP ensures that IPv4, IPv6 and IPv4-mapped IPv6 remote addresses are reduced to the IP Kubernetes
+// reports for pods
+func TestRemoteIP(t *testing.T) {
+
+	var testCases = []struct {
+		input string
+		ip    string
+		err   bool
+	}{
+		{"10.0.0.5:43210", "10.0.0.5", false},
+		{"[fd00:10:244::5]:43210", "fd00:10:244::5", false},
+		{"[fd00:10:244:0:0:0:0:5]:43210", "fd00:10:244::5", false},
+		{"[::ffff:10.0.0.5]:43210", "10.0.0.5", false},
+		{"[fe80::1%eth0]:43210", "fe80::1", false},
+		{"10.0.0.5", "", true},
+		{"not-an-ip:43210", "", true},
+	}
+
+	for _, tc := range testCases {
+		ip, err := remoteIP(tc.input)
+		if (err != nil) != tc.err {
+			t.Fatalf("remoteIP(`%s`) error was `%v` but expected error to be %t", tc.input, err, tc.err)
+		}
+		if ip != tc.ip {
+			t.Fatalf("remoteIP(`%s`) resulted in `%s` but expected `%s`", tc.input, ip, tc.ip)
+		}
+		t.Logf("remoteIP(`%s`) resulted in `%s` correctly", tc.input, ip)
+	}
+}
+
+// TestPodHasIP ensures that both the primary and secondary IPs of dual-stack pods are matched
+func TestPodHasIP(t *testing.T) {
+
+	pod := v1.Pod{}
+	pod.Status.PodIP = "10.0.0.5"
+	pod.Status.PodIPs = []v1.PodIP{{IP: "10.0.0.5"}, {IP: "fd00:10:244::5"}}
+
+	var testCases = []struct {
+		ip    string
+		found bool
+	}{
+		{"10.0.0.5", true},
+		{"fd00:10:244::5", true},
+		{"fd00:10:244:0:0:0:0:5", true},
+		{"10.0.0.6", false},
+		{"fd00:10:244::6", false},
+		{"", false},
+	}
+
+	for _, tc := range testCases {
+		if podHasIP(pod, tc.ip) != tc.found {
+			t.Fatalf("podHasIP(`%s`) resulted in %t but expected %t", tc.ip, !tc.found, tc.found)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/health"
 )
 
@@ -147,3 +149,32 @@ func TestWebServerNotOK(t *testing.T) {
 	}
 
 }
+
+// TestGetRequestID ensures that request IDs supplied by clients are only used when they are safe to log
+func TestGetRequestID(t *testing.T) {
+
+	var testCases = []struct {
+		input    string
+		accepted bool
+	}{
+		{"checkclient-9abd3ec0-b82f-44f0-b8a7-fa6709f759cd", true},
+		{"trace:abc_123.4", true},
+		{"", false},
+		{"has spaces", false},
+		{"line\nbreak", false},
+		{strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodPost, "/externalCheckStatus", nil)
+		req.Header.Set(external.KHRequestIDHeader, tc.input)
+		requestID := getRequestID(req)
+		if (requestID == tc.input) != tc.accepted {
+			t.Fatalf("getRequestID for `%s` resulted in `%s` but expected accepted to be %t", tc.input, requestID, tc.accepted)
+		}
+		if len(requestID) == 0 {
+			t.Fatalf("getRequestID for `%s` resulted in a blank request ID", tc.input)
+		}
+		t.Logf("getRequestID for `%s` resulted in `%s` correctly", tc.input, requestID)
+	}
+}
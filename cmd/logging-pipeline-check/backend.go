@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
+)
+
+// Backend searches a logging backend for a log line
+type Backend interface {
+	// Found indicates whether a log line containing the marker was stored at or after the supplied time.  How long
+	// each phase of the search took is returned with it.
+	Found(ctx context.Context, marker string, since time.Time) (bool, probe.Timing, error)
+}
+
+// LokiBackend searches Loki with LogQL
+type LokiBackend struct {
+	URL      string      // the base URL of the Loki API, such as http://loki-gateway.logging
+	Selector string      // the stream selector that the log lines of the checker pod are in, such as {namespace="kuberhealthy"}
+	Headers  http.Header // extra headers sent with every query, such as X-Scope-OrgID
+	Client   *http.Client
+}
+
+// lokiResponse is the part of the response of /loki/api/v1/query_range that the check needs
+type lokiResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		Result []json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// Found searches the streams of the selector for a line containing the marker
+func (l LokiBackend) Found(ctx context.Context, marker string, since time.Time) (bool, probe.Timing, error) {
+	params := url.Values{}
+	params.Set("query", l.Selector+" |= "+strconv.Quote(marker))
+	params.Set("start", strconv.FormatInt(since.UnixNano(), 10))
+	params.Set("end", strconv.FormatInt(time.Now().Add(time.Minute).UnixNano(), 10))
+	params.Set("limit", "1")
+	params.Set("direction", "forward")
+
+	body, timing, err := send(ctx, l.Client, http.MethodGet, l.URL, "/loki/api/v1/query_range?"+params.Encode(), l.Headers, nil)
+	if err != nil {
+		return false, timing, err
+	}
+	result := lokiResponse{}
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return false, timing, fmt.Errorf("failed to parse the response of loki: %w", err)
+	}
+	if result.Status != "success" {
+		return false, timing, fmt.Errorf("loki query ended with status %q", result.Status)
+	}
+	return len(result.Data.Result) > 0, timing, nil
+}
+
+// ElasticsearchBackend searches Elasticsearch or OpenSearch with a phrase query
+type ElasticsearchBackend struct {
+	URL     string      // the base URL of the cluster, such as https://elasticsearch.logging:9200
+	Index   string      // the index pattern that log lines are stored in, such as logs-*
+	Field   string      // the field that holds the log line, such as message or log
+	Headers http.Header // extra headers sent with every search, such as Authorization
+	Client  *http.Client
+}
+
+// elasticsearchResponse is the part of the response of _search that the check needs
+type elasticsearchResponse struct {
+	Hits struct {
+		Hits []json.RawMessage `json:"hits"`
+	} `json:"hits"`
+}
+
+// Found searches the index for a document whose field contains the marker.  Documents are not filtered by time
+// since the marker is unique to the run and index timestamp fields differ between setups.
+func (e ElasticsearchBackend) Found(ctx context.Context, marker string, since time.Time) (bool, probe.Timing, error) {
+	search, err := json.Marshal(map[string]interface{}{
+		"size":  1,
+		"query": map[string]interface{}{"match_phrase": map[string]string{e.Field: marker}},
+	})
+	if err != nil {
+		return false, probe.Timing{}, err
+	}
+
+	headers := e.Headers.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+	headers.Set("Content-Type", "application/json")
+	body, timing, err := send(ctx, e.Client, http.MethodPost, e.URL, "/"+url.PathEscape(e.Index)+"/_search", headers, search)
+	if err != nil {
+		return false, timing, err
+	}
+	result := elasticsearchResponse{}
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return false, timing, fmt.Errorf("failed to parse the response of elasticsearch: %w", err)
+	}
+	return len(result.Hits.Hits) > 0, timing, nil
+}
+
+// CloudWatchBackend searches a CloudWatch Logs log group
+type CloudWatchBackend struct {
+	Client   cloudwatchlogsiface.CloudWatchLogsAPI
+	LogGroup string // the log group that the log lines of the checker pod are shipped to
+}
+
+// Found filters the events of the log group for the marker.  The AWS SDK does not expose the phases of its requests,
+// so only the total time of the search is returned.
+func (c CloudWatchBackend) Found(ctx context.Context, marker string, since time.Time) (bool, probe.Timing, error) {
+	start := time.Now()
+	out, err := c.Client.FilterLogEventsWithContext(ctx, &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:  aws.String(c.LogGroup),
+		FilterPattern: aws.String(strconv.Quote(marker)),
+		StartTime:     aws.Int64(since.UnixMilli()),
+		Limit:         aws.Int64(1),
+	})
+	timing := probe.Timing{Total: time.Since(start)}
+	if err != nil {
+		return false, timing, fmt.Errorf("failed to filter log group %s: %w", c.LogGroup, err)
+	}
+	return len(out.Events) > 0, timing, nil
+}
+
+// send sends a request to an HTTP logging backend and returns the body of a successful response and how long each
+// phase of the request took
+func send(ctx context.Context, client *http.Client, method string, baseURL string, path string, headers http.Header, body []byte) ([]byte, probe.Timing, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(baseURL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, probe.Timing{}, err
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	resp, b, timing, err := probe.HTTP(client, req)
+	if err != nil {
+		return nil, timing, fmt.Errorf("failed to reach %s: %w", baseURL, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, timing, fmt.Errorf("%s responded with status %d: %s", baseURL, resp.StatusCode, truncate(string(b), 200))
+	}
+	return b, timing, nil
+}
+
+// waitForLogLine searches the backend every interval until the marker is found or the context ends.  The time from
+// the supplied start until the line was found is returned.  The timing and error of every search are passed to the
+// supplied callback, and failed searches are retried, since the logging pipeline may be recovering.
+func waitForLogLine(ctx context.Context, b Backend, marker string, start time.Time, interval time.Duration, searched func(probe.Timing, error)) (time.Duration, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		found, timing, err := b.Found(ctx, marker, start)
+		// searches cut short by the end of the run are not passed on
+		if err == nil || ctx.Err() == nil {
+			searched(timing, err)
+		}
+		if found {
+			return time.Since(start), nil
+		}
+		if err != nil && ctx.Err() == nil {
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			waited := time.Since(start).Round(time.Second)
+			if lastErr != nil {
+				return 0, fmt.Errorf("log line was not found after %s, and the last search failed: %w", waited, lastErr)
+			}
+			return 0, fmt.Errorf("log line was not found after %s", waited)
+		case <-ticker.C:
+		}
+	}
+}
+
+// truncate shortens a string to at most n bytes for error messages
+func truncate(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
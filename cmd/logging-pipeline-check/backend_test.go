@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
+)
+
+// stored is the log line that the fake backends have ingested
+const stored = "kuberhealthy-logging-pipeline-probe-abc"
+
+// fakeLoki answers range queries that filter for the stored line with a single stream
+func fakeLoki(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/loki/api/v1/query_range" || r.Header.Get("X-Scope-OrgID") != "kuberhealthy" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	result := "[]"
+	if r.URL.Query().Get("query") == `{namespace="kuberhealthy"} |= "`+stored+`"` {
+		result = `[{"stream":{"namespace":"kuberhealthy"},"values":[["1700000000000000000","` + stored + `"]]}]`
+	}
+	_, _ = io.WriteString(w, `{"status":"success","data":{"resultType":"streams","result":`+result+`}}`)
+}
+
+// fakeElasticsearch answers phrase searches of the message field for the stored line with a single hit
+func fakeElasticsearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/logs-*/_search" {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"error":"no such index"}`)
+		return
+	}
+	search := struct {
+		Query struct {
+			MatchPhrase map[string]string `json:"match_phrase"`
+		} `json:"query"`
+	}{}
+	_ = json.NewDecoder(r.Body).Decode(&search)
+	hits := "[]"
+	if search.Query.MatchPhrase["message"] == stored {
+		hits = `[{"_source":{"message":"` + stored + `"}}]`
+	}
+	_, _ = io.WriteString(w, `{"hits":{"hits":`+hits+`}}`)
+}
+
+// fakeCloudWatch filters the events of a log group for the stored line
+type fakeCloudWatch struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+}
+
+func (f fakeCloudWatch) FilterLogEventsWithContext(ctx aws.Context, in *cloudwatchlogs.FilterLogEventsInput, opts ...request.Option) (*cloudwatchlogs.FilterLogEventsOutput, error) {
+	if aws.StringValue(in.LogGroupName) != "/eks/cluster/containers" {
+		return nil, errors.New("ResourceNotFoundException: The specified log group does not exist.")
+	}
+	out := &cloudwatchlogs.FilterLogEventsOutput{}
+	if aws.StringValue(in.FilterPattern) == `"`+stored+`"` {
+		out.Events = []*cloudwatchlogs.FilteredLogEvent{{Message: aws.String(stored)}}
+	}
+	return out, nil
+}
+
+func TestBackends(t *testing.T) {
+	loki := httptest.NewServer(http.HandlerFunc(fakeLoki))
+	defer loki.Close()
+	elasticsearch := httptest.NewServer(http.HandlerFunc(fakeElasticsearch))
+	defer elasticsearch.Close()
+
+	tenant := http.Header{"X-Scope-Orgid": []string{"kuberhealthy"}}
+	var testCases = []struct {
+		name     string
+		backend  Backend
+		marker   string
+		found    bool
+		expected string // the start of the expected error
+	}{
+		{name: "loki found", backend: LokiBackend{URL: loki.URL, Selector: `{namespace="kuberhealthy"}`, Headers: tenant, Client: loki.Client()}, marker: stored, found: true},
+		{name: "loki not found", backend: LokiBackend{URL: loki.URL, Selector: `{namespace="kuberhealthy"}`, Headers: tenant, Client: loki.Client()}, marker: "other"},
+		{name: "loki wrong tenant", backend: LokiBackend{URL: loki.URL, Selector: `{namespace="kuberhealthy"}`, Client: loki.Client()}, marker: stored, expected: loki.URL + " responded with status 404"},
+		{name: "elasticsearch wrong server", backend: ElasticsearchBackend{URL: loki.URL, Client: loki.Client()}, marker: stored, expected: loki.URL + " responded with status 404"},
+		{name: "elasticsearch found", backend: ElasticsearchBackend{URL: elasticsearch.URL + "/", Index: "logs-*", Field: "message", Client: elasticsearch.Client()}, marker: stored, found: true},
+		{name: "elasticsearch other field", backend: ElasticsearchBackend{URL: elasticsearch.URL, Index: "logs-*", Field: "log", Client: elasticsearch.Client()}, marker: stored},
+		{name: "elasticsearch missing index", backend: ElasticsearchBackend{URL: elasticsearch.URL, Index: "app-*", Field: "message", Client: elasticsearch.Client()}, marker: stored, expected: elasticsearch.URL + ` responded with status 404: {"error":"no such index"}`},
+		{name: "cloudwatch found", backend: CloudWatchBackend{Client: fakeCloudWatch{}, LogGroup: "/eks/cluster/containers"}, marker: stored, found: true},
+		{name: "cloudwatch not found", backend: CloudWatchBackend{Client: fakeCloudWatch{}, LogGroup: "/eks/cluster/containers"}, marker: "other"},
+		{name: "cloudwatch missing log group", backend: CloudWatchBackend{Client: fakeCloudWatch{}, LogGroup: "/eks/other"}, marker: stored, expected: "failed to filter log group /eks/other: ResourceNotFoundException"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			found, timing, err := tc.backend.Found(context.Background(), tc.marker, time.Now().Add(-time.Minute))
+			if len(tc.expected) > 0 {
+				if err == nil || !strings.HasPrefix(err.Error(), tc.expected) {
+					t.Fatalf("expected error starting with %q, got: %v", tc.expected, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if found != tc.found {
+				t.Fatalf("expected found to be %t, got %t", tc.found, found)
+			}
+			if timing.Total <= 0 {
+				t.Fatalf("expected the search to be timed, got %s", timing)
+			}
+		})
+	}
+}
+
+// eventualBackend finds the log line once it was searched for the supplied number of times
+type eventualBackend struct {
+	searches int
+	after    int
+	err      error
+}
+
+func (e *eventualBackend) Found(ctx context.Context, marker string, since time.Time) (bool, probe.Timing, error) {
+	e.searches++
+	return e.err == nil && e.searches > e.after, probe.Timing{Total: time.Millisecond}, e.err
+}
+
+func TestWaitForLogLine(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		backend  *eventualBackend
+		expected string // the start of the expected error
+	}{
+		{name: "found right away", backend: &eventualBackend{}},
+		{name: "found after a few searches", backend: &eventualBackend{after: 3}},
+		{name: "never found", backend: &eventualBackend{after: 1000}, expected: "log line was not found after 0s"},
+		{name: "search error", backend: &eventualBackend{err: errors.New("connection refused")}, expected: "log line was not found after 0s, and the last search failed: connection refused"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			var failures int
+			latency, err := waitForLogLine(ctx, tc.backend, stored, time.Now(), 10*time.Millisecond, func(_ probe.Timing, err error) {
+				if err != nil {
+					failures++
+				}
+			})
+			if len(tc.expected) == 0 {
+				if err != nil {
+					t.Fatalf("expected the log line to be found, got: %s", err)
+				}
+				if latency <= 0 {
+					t.Fatalf("expected a positive latency, got %s", latency)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tc.expected) {
+				t.Fatalf("expected error starting with %q, got: %v", tc.expected, err)
+			}
+			if tc.backend.err != nil && failures == 0 {
+				t.Fatalf("expected failed searches to be passed to the callback")
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Package logging-pipeline-check implements a checker for Kuberhealthy that writes a unique log line and waits until
+// it can be found in the logging backend, measuring the ingestion latency of the logging pipeline
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	awsutil "github.com/kuberhealthy/kuberhealthy/v2/pkg/aws"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	kh "github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/nodeCheck"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
+)
+
+var (
+	// backend is the logging backend that is searched: loki, elasticsearch or cloudwatch
+	backend = os.Getenv("BACKEND")
+
+	// backendURL is the base URL of the loki or elasticsearch API
+	backendURL = os.Getenv("BACKEND_URL")
+
+	// queryHeaders are extra headers sent with every search, one Name: value per line
+	queryHeaders = os.Getenv("QUERY_HEADERS")
+
+	// bearerTokenFile holds a token sent as the Authorization header of every search
+	bearerTokenFile = os.Getenv("BEARER_TOKEN_FILE")
+
+	// lokiSelector is the LogQL stream selector that the log lines of the checker pod are in
+	lokiSelector = os.Getenv("LOKI_SELECTOR")
+
+	// settings of elasticsearch searches
+	elasticsearchIndex = os.Getenv("ELASTICSEARCH_INDEX")
+	elasticsearchField = os.Getenv("ELASTICSEARCH_FIELD")
+
+	// cloudWatchLogGroup is the log group that the log lines of the checker pod are shipped to
+	cloudWatchLogGroup = os.Getenv("CLOUDWATCH_LOG_GROUP")
+
+	// pollInterval is how often the backend is searched for the log line
+	pollInterval = os.Getenv("POLL_INTERVAL")
+
+	// maxIngestionLatency fails the check when the log line takes longer than this to be found
+	maxIngestionLatency = os.Getenv("MAX_INGESTION_LATENCY")
+)
+
+// markerPrefix starts the log line written by every run
+const markerPrefix = "kuberhealthy-logging-pipeline-probe-"
+
+// reportMargin is the time left before the deadline of the run to report the result to Kuberhealthy
+const reportMargin = 10 * time.Second
+
+func init() {
+	// set debug mode for nodeCheck pkg
+	nodeCheck.EnableDebugOutput()
+
+	if len(pollInterval) == 0 {
+		pollInterval = "5s"
+	}
+	if len(elasticsearchIndex) == 0 {
+		elasticsearchIndex = "*"
+	}
+	if len(elasticsearchField) == 0 {
+		elasticsearchField = "message"
+	}
+	if len(lokiSelector) == 0 {
+		namespace := os.Getenv(external.KHPodNamespace)
+		if len(namespace) == 0 {
+			namespace = "kuberhealthy"
+		}
+		lokiSelector = fmt.Sprintf("{namespace=%q}", namespace)
+	}
+}
+
+func main() {
+	// the log line has until shortly before the deadline of the run to show up
+	deadline, err := kh.GetDeadline()
+	if err != nil {
+		log.Warningln("Failed to read the deadline of the run, allowing five minutes:", err)
+		deadline = time.Now().Add(5 * time.Minute)
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline.Add(-reportMargin))
+	defer cancel()
+
+	// hits kuberhealthy endpoint to see if node is ready
+	err = nodeCheck.WaitForKuberhealthy(ctx)
+	if err != nil {
+		log.Errorln("Error waiting for kuberhealthy endpoint to be contactable by checker pod with error:" + err.Error())
+	}
+
+	b, interval, maxLatency, err := parseConfig()
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+
+	// the UUID of the run makes the log line unique, so that lines of earlier runs are never mistaken for this one
+	probeID := os.Getenv(external.KHRunUUID)
+	if len(probeID) == 0 {
+		probeID = uuid.New().String()
+	}
+	marker := markerPrefix + probeID
+
+	// the line is written plainly to stdout so that it is shipped like the logs of any other container
+	start := time.Now()
+	fmt.Println(marker)
+	log.Infoln("Wrote log line", marker, "and waiting for it to be found in", backend)
+
+	latency, err := waitForLogLine(ctx, b, marker, start, interval, func(timing probe.Timing, err error) {
+		if err != nil {
+			log.Warningln("Search failed in", timing, "with error:", err)
+			return
+		}
+		log.Infoln("Searched", backend, "in", timing)
+	})
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+	log.Infoln("Log line was found after", latency.Round(time.Millisecond))
+	if maxLatency > 0 && latency > maxLatency {
+		ReportFailureAndExit(fmt.Errorf("ingestion latency of %s is over the limit of %s", latency.Round(time.Millisecond), maxLatency))
+	}
+
+	err = kh.ReportSuccess()
+	if err != nil {
+		log.Errorln("Error reporting success to Kuberhealthy servers:", err)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported success to Kuberhealthy servers")
+}
+
+// parseConfig reads the backend and timings of the check from the environment
+func parseConfig() (Backend, time.Duration, time.Duration, error) {
+	if backend != "loki" && backend != "elasticsearch" && backend != "cloudwatch" {
+		return nil, 0, 0, fmt.Errorf("BACKEND %q must be one of loki, elasticsearch or cloudwatch", backend)
+	}
+	interval, err := time.ParseDuration(pollInterval)
+	if err != nil || interval <= 0 {
+		return nil, 0, 0, fmt.Errorf("POLL_INTERVAL %q must be a positive duration", pollInterval)
+	}
+	var maxLatency time.Duration
+	if len(maxIngestionLatency) > 0 {
+		maxLatency, err = time.ParseDuration(maxIngestionLatency)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("failed to parse MAX_INGESTION_LATENCY: %w", err)
+		}
+	}
+
+	if backend == "cloudwatch" {
+		if len(cloudWatchLogGroup) == 0 {
+			return nil, 0, 0, errors.New("CLOUDWATCH_LOG_GROUP is required with the cloudwatch backend")
+		}
+		client := cloudwatchlogs.New(awsutil.CreateAWSSession())
+		return CloudWatchBackend{Client: client, LogGroup: cloudWatchLogGroup}, interval, maxLatency, nil
+	}
+
+	if !strings.HasPrefix(backendURL, "http://") && !strings.HasPrefix(backendURL, "https://") {
+		return nil, 0, 0, fmt.Errorf("BACKEND_URL %q must start with http:// or https://", backendURL)
+	}
+	headers, err := probe.ParseHeaders(queryHeaders)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("invalid QUERY_HEADERS: %w", err)
+	}
+	if len(bearerTokenFile) > 0 {
+		token, err := os.ReadFile(bearerTokenFile)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("failed to read BEARER_TOKEN_FILE: %w", err)
+		}
+		headers.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	if backend == "loki" {
+		return LokiBackend{URL: backendURL, Selector: lokiSelector, Headers: headers, Client: client}, interval, maxLatency, nil
+	}
+	return ElasticsearchBackend{URL: backendURL, Index: elasticsearchIndex, Field: elasticsearchField, Headers: headers, Client: client}, interval, maxLatency, nil
+}
+
+// ReportFailureAndExit reports an error to Kuberhealthy and exits the program
+func ReportFailureAndExit(err error) {
+	log.Errorln(err)
+	err2 := kh.ReportFailure([]string{err.Error()})
+	if err2 != nil {
+		log.Errorln("Error reporting failure to Kuberhealthy servers:", err2)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported failure to Kuberhealthy servers")
+	os.Exit(0)
+}
@@ -0,0 +1,200 @@
+// Package metrics-pipeline-check implements a checker for Kuberhealthy that emits a uniquely labeled metric and
+// waits until it can be queried from Prometheus, measuring the ingestion lag of the monitoring pipeline
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	kh "github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/nodeCheck"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
+)
+
+var (
+	// prometheusURL is the base URL of the Prometheus compatible query API, such as Prometheus or Thanos Query
+	prometheusURL = os.Getenv("PROMETHEUS_URL")
+
+	// queryHeaders are extra headers sent with every query, one Name: value per line
+	queryHeaders = os.Getenv("QUERY_HEADERS")
+
+	// bearerTokenFile holds a token sent as the Authorization header of every query
+	bearerTokenFile = os.Getenv("BEARER_TOKEN_FILE")
+
+	// pushgatewayURL makes the check push its sample to a Pushgateway instead of serving it to be scraped
+	pushgatewayURL = os.Getenv("PUSHGATEWAY_URL")
+
+	// listenAddress is where the sample is served to be scraped when it is not pushed
+	listenAddress = os.Getenv("LISTEN_ADDRESS")
+
+	// pollInterval is how often the query API is asked for the sample
+	pollInterval = os.Getenv("POLL_INTERVAL")
+
+	// maxIngestionLag fails the check when the sample takes longer than this to become queryable
+	maxIngestionLag = os.Getenv("MAX_INGESTION_LAG")
+)
+
+// reportMargin is the time left before the deadline of the run to report the result to Kuberhealthy
+const reportMargin = 10 * time.Second
+
+func init() {
+	// set debug mode for nodeCheck pkg
+	nodeCheck.EnableDebugOutput()
+
+	if len(listenAddress) == 0 {
+		listenAddress = ":9102"
+	}
+	if len(pollInterval) == 0 {
+		pollInterval = "5s"
+	}
+}
+
+func main() {
+	// the sample has until shortly before the deadline of the run to show up
+	deadline, err := kh.GetDeadline()
+	if err != nil {
+		log.Warningln("Failed to read the deadline of the run, allowing five minutes:", err)
+		deadline = time.Now().Add(5 * time.Minute)
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline.Add(-reportMargin))
+	defer cancel()
+
+	// hits kuberhealthy endpoint to see if node is ready
+	err = nodeCheck.WaitForKuberhealthy(ctx)
+	if err != nil {
+		log.Errorln("Error waiting for kuberhealthy endpoint to be contactable by checker pod with error:" + err.Error())
+	}
+
+	querier, interval, maxLag, err := parseConfig()
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+
+	// the UUID of the run makes the sample unique, so that samples of earlier runs are never mistaken for this one
+	probeID := os.Getenv(external.KHRunUUID)
+	if len(probeID) == 0 {
+		probeID = uuid.New().String()
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	start := time.Now()
+	sample := formatSample(probeID, start)
+	if len(pushgatewayURL) > 0 {
+		log.Infoln("Pushing sample", sampleQuery(probeID), "to", pushgatewayURL)
+		timing, err := pushSample(ctx, client, pushgatewayURL, probeID, sample)
+		if err != nil {
+			ReportFailureAndExit(fmt.Errorf("failed to push sample: %w", err))
+		}
+		log.Infoln("Pushed sample in", timing)
+		defer func() {
+			_, err := deletePushedSample(context.Background(), client, pushgatewayURL, probeID)
+			if err != nil {
+				log.Warningln("Failed to remove the sample from the pushgateway:", err)
+			}
+		}()
+	} else {
+		listener, err := net.Listen("tcp", listenAddress)
+		if err != nil {
+			ReportFailureAndExit(fmt.Errorf("failed to listen on %s to serve the sample: %w", listenAddress, err))
+		}
+		log.Infoln("Serving sample", sampleQuery(probeID), "to be scraped on", listener.Addr().String()+"/metrics")
+		go func() {
+			err := serveSample(ctx, listener, sample)
+			if err != nil {
+				log.Errorln("Failed to serve the sample:", err)
+			}
+		}()
+	}
+
+	log.Infoln("Waiting for the sample to be queryable from", prometheusURL)
+	lag, err := waitUntilQueryable(ctx, querier, sampleQuery(probeID), start, interval, func(timing probe.Timing, err error) {
+		if err != nil {
+			log.Warningln("Query failed in", timing, "with error:", err)
+			return
+		}
+		log.Infoln("Queried", prometheusURL, "in", timing)
+	})
+	if err != nil {
+		reportFailure(err)
+		return
+	}
+	log.Infoln("Sample became queryable after", lag.Round(time.Millisecond))
+	if maxLag > 0 && lag > maxLag {
+		reportFailure(fmt.Errorf("ingestion lag of %s is over the limit of %s", lag.Round(time.Millisecond), maxLag))
+		return
+	}
+
+	err = kh.ReportSuccess()
+	if err != nil {
+		log.Errorln("Error reporting success to Kuberhealthy servers:", err)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported success to Kuberhealthy servers")
+}
+
+// parseConfig reads the querier and timings of the check from the environment
+func parseConfig() (Querier, time.Duration, time.Duration, error) {
+	if !strings.HasPrefix(prometheusURL, "http://") && !strings.HasPrefix(prometheusURL, "https://") {
+		return Querier{}, 0, 0, fmt.Errorf("PROMETHEUS_URL %q must start with http:// or https://", prometheusURL)
+	}
+
+	headers, err := probe.ParseHeaders(queryHeaders)
+	if err != nil {
+		return Querier{}, 0, 0, fmt.Errorf("invalid QUERY_HEADERS: %w", err)
+	}
+	if len(bearerTokenFile) > 0 {
+		token, err := os.ReadFile(bearerTokenFile)
+		if err != nil {
+			return Querier{}, 0, 0, fmt.Errorf("failed to read BEARER_TOKEN_FILE: %w", err)
+		}
+		headers.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	interval, err := time.ParseDuration(pollInterval)
+	if err != nil || interval <= 0 {
+		return Querier{}, 0, 0, fmt.Errorf("POLL_INTERVAL %q must be a positive duration", pollInterval)
+	}
+	var maxLag time.Duration
+	if len(maxIngestionLag) > 0 {
+		maxLag, err = time.ParseDuration(maxIngestionLag)
+		if err != nil {
+			return Querier{}, 0, 0, fmt.Errorf("failed to parse MAX_INGESTION_LAG: %w", err)
+		}
+	}
+
+	querier := Querier{URL: prometheusURL, Headers: headers, Client: &http.Client{Timeout: 30 * time.Second}}
+	return querier, interval, maxLag, nil
+}
+
+// reportFailure reports an error to Kuberhealthy without exiting, so that deferred cleanup still runs
+func reportFailure(err error) {
+	log.Errorln(err)
+	err = kh.ReportFailure([]string{err.Error()})
+	if err != nil {
+		log.Errorln("Error reporting failure to Kuberhealthy servers:", err)
+		return
+	}
+	log.Infoln("Successfully reported failure to Kuberhealthy servers")
+}
+
+// ReportFailureAndExit reports an error to Kuberhealthy and exits the program
+func ReportFailureAndExit(err error) {
+	log.Errorln(err)
+	err2 := kh.ReportFailure([]string{err.Error()})
+	if err2 != nil {
+		log.Errorln("Error reporting failure to Kuberhealthy servers:", err2)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported failure to Kuberhealthy servers")
+	os.Exit(0)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
+)
+
+// metricName is the name of the metric that the check emits and then looks for
+const metricName = "kuberhealthy_metrics_pipeline_probe"
+
+// probeLabel is the label that makes the metric of every run unique
+const probeLabel = "probe_id"
+
+// pushJob is the job that metrics are pushed to a Pushgateway under
+const pushJob = "kuberhealthy_metrics_pipeline_check"
+
+// formatSample renders the sample of a run in the Prometheus text exposition format.  Its value is the unix time it
+// was emitted at.
+func formatSample(probeID string, emitted time.Time) string {
+	return fmt.Sprintf("# HELP %s Emitted by kuberhealthy to verify that metrics can be queried end to end.\n# TYPE %s gauge\n%s{%s=%q} %d\n",
+		metricName, metricName, metricName, probeLabel, probeID, emitted.Unix())
+}
+
+// sampleQuery is the PromQL query that finds the sample of a run
+func sampleQuery(probeID string) string {
+	return fmt.Sprintf("%s{%s=%q}", metricName, probeLabel, probeID)
+}
+
+// serveSample serves the sample of a run on /metrics of the supplied listener until the context is canceled so
+// that Prometheus can scrape it
+func serveSample(ctx context.Context, listener net.Listener, sample string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_, _ = io.WriteString(w, sample)
+	})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	err := server.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// pushURL is the URL of the group of a run on a Pushgateway
+func pushURL(gateway string, probeID string) (string, error) {
+	u, err := url.Parse(gateway)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("pushgateway URL %s must start with http:// or https://", gateway)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/metrics/job/" + pushJob + "/" + probeLabel + "/" + url.PathEscape(probeID)
+	return u.String(), nil
+}
+
+// pushSample pushes the sample of a run to a Pushgateway, replacing its group
+func pushSample(ctx context.Context, client *http.Client, gateway string, probeID string, sample string) (probe.Timing, error) {
+	return sendPush(ctx, client, http.MethodPut, gateway, probeID, strings.NewReader(sample))
+}
+
+// deletePushedSample removes the group of a run from a Pushgateway so that old runs don't pile up there
+func deletePushedSample(ctx context.Context, client *http.Client, gateway string, probeID string) (probe.Timing, error) {
+	return sendPush(ctx, client, http.MethodDelete, gateway, probeID, nil)
+}
+
+// sendPush sends a request to the group of a run on a Pushgateway and returns how long each phase of it took
+func sendPush(ctx context.Context, client *http.Client, method string, gateway string, probeID string, body io.Reader) (probe.Timing, error) {
+	u, err := pushURL(gateway, probeID)
+	if err != nil {
+		return probe.Timing{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return probe.Timing{}, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	}
+	resp, b, timing, err := probe.HTTP(client, req)
+	if err != nil {
+		return timing, fmt.Errorf("failed to reach the pushgateway: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return timing, fmt.Errorf("pushgateway responded to %s with status %d: %s", method, resp.StatusCode, truncate(string(b), 1024))
+	}
+	return timing, nil
+}
+
+// Querier runs instant queries against the HTTP API of Prometheus or anything compatible with it, such as Thanos
+type Querier struct {
+	URL     string      // the base URL of the API, such as http://prometheus.monitoring:9090
+	Headers http.Header // extra headers sent with every query, such as Authorization or X-Scope-OrgID
+	Client  *http.Client
+}
+
+// queryResponse is the part of the response of /api/v1/query that the check needs
+type queryResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string            `json:"resultType"`
+		Result     []json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// Found runs an instant query and indicates whether it returned any series.  How long each phase of the query took
+// is returned with it.
+func (q Querier) Found(ctx context.Context, query string) (bool, probe.Timing, error) {
+	u, err := url.Parse(q.URL)
+	if err != nil {
+		return false, probe.Timing{}, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v1/query"
+	u.RawQuery = url.Values{"query": []string{query}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return false, probe.Timing{}, err
+	}
+	for name, values := range q.Headers {
+		req.Header[name] = values
+	}
+	resp, body, timing, err := probe.HTTP(q.Client, req)
+	if err != nil {
+		return false, timing, fmt.Errorf("failed to query %s: %w", q.URL, err)
+	}
+
+	result := queryResponse{}
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return false, timing, fmt.Errorf("query API responded with status %d and a body that is not JSON: %s", resp.StatusCode, truncate(string(body), 200))
+	}
+	if result.Status != "success" {
+		return false, timing, fmt.Errorf("query failed with %s: %s", result.ErrorType, result.Error)
+	}
+	if result.Data.ResultType != "vector" {
+		return false, timing, fmt.Errorf("query returned a %s instead of a vector", result.Data.ResultType)
+	}
+	return len(result.Data.Result) > 0, timing, nil
+}
+
+// waitUntilQueryable runs the supplied query every interval until it returns a series or the context ends.  The
+// time from the supplied start until the series was found is returned.  The timing and error of every query are
+// passed to the supplied callback, and failed queries are retried, since the monitoring pipeline may be recovering.
+func waitUntilQueryable(ctx context.Context, q Querier, query string, start time.Time, interval time.Duration, queried func(probe.Timing, error)) (time.Duration, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		found, timing, err := q.Found(ctx, query)
+		// queries cut short by the end of the run are not passed on
+		if err == nil || ctx.Err() == nil {
+			queried(timing, err)
+		}
+		if found {
+			return time.Since(start), nil
+		}
+		if err != nil && ctx.Err() == nil {
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			waited := time.Since(start).Round(time.Second)
+			if lastErr != nil {
+				return 0, fmt.Errorf("sample was not queryable after %s, and the last query failed: %w", waited, lastErr)
+			}
+			return 0, fmt.Errorf("sample was not queryable after %s", waited)
+		case <-ticker.C:
+		}
+	}
+}
+
+// truncate shortens a string to at most n bytes for error messages
+func truncate(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
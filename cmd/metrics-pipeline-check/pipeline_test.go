@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
+)
+
+// fakePrometheus answers instant queries with an empty vector until the sample was queried the supplied number of
+// times, then with a single series
+type fakePrometheus struct {
+	mu       sync.Mutex
+	queries  int
+	emptyFor int
+	body     string // replaces the response when set
+}
+
+func (f *fakePrometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path != "/api/v1/query" || r.Header.Get("X-Scope-OrgID") != "kuberhealthy" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if len(f.body) > 0 {
+		_, _ = io.WriteString(w, f.body)
+		return
+	}
+	f.queries++
+	result := "[]"
+	if f.queries > f.emptyFor {
+		result = fmt.Sprintf(`[{"metric":{"__name__":%q},"value":[1700000000,"1"]}]`, r.URL.Query().Get("query"))
+	}
+	_, _ = io.WriteString(w, `{"status":"success","data":{"resultType":"vector","result":`+result+`}}`)
+}
+
+func TestWaitUntilQueryable(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		prom     *fakePrometheus
+		timeout  time.Duration
+		expected string // the start of the expected error
+	}{
+		{name: "queryable right away", prom: &fakePrometheus{}, timeout: time.Second},
+		{name: "queryable after a few polls", prom: &fakePrometheus{emptyFor: 3}, timeout: time.Second},
+		{name: "never queryable", prom: &fakePrometheus{emptyFor: 1000}, timeout: 100 * time.Millisecond, expected: "sample was not queryable after"},
+		{name: "query error", prom: &fakePrometheus{body: `{"status":"error","errorType":"bad_data","error":"parse error"}`}, timeout: 100 * time.Millisecond, expected: "sample was not queryable after 0s, and the last query failed: query failed with bad_data: parse error"},
+		{name: "not json", prom: &fakePrometheus{body: `<html>login</html>`}, timeout: 100 * time.Millisecond, expected: "sample was not queryable after 0s, and the last query failed: query API responded with status 200 and a body that is not JSON: <html>login</html>"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(tc.prom)
+			defer server.Close()
+
+			q := Querier{URL: server.URL, Headers: http.Header{"X-Scope-Orgid": []string{"kuberhealthy"}}, Client: server.Client()}
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+
+			var failures int
+			var last probe.Timing
+			lag, err := waitUntilQueryable(ctx, q, sampleQuery("abc"), time.Now(), 10*time.Millisecond, func(timing probe.Timing, err error) {
+				last = timing
+				if err != nil {
+					failures++
+				}
+			})
+			if len(tc.expected) == 0 {
+				if err != nil {
+					t.Fatalf("expected the sample to be found, got: %s", err)
+				}
+				if lag <= 0 {
+					t.Fatalf("expected a positive lag, got %s", lag)
+				}
+				if last.Total <= 0 {
+					t.Fatalf("expected the query that found the sample to be timed, got %s", last)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tc.expected) {
+				t.Fatalf("expected error starting with %q, got: %v", tc.expected, err)
+			}
+			if len(tc.prom.body) > 0 && failures == 0 {
+				t.Fatalf("expected failed queries to be passed to the callback")
+			}
+		})
+	}
+}
+
+func TestServeSample(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- serveSample(ctx, listener, formatSample("abc", time.Unix(1700000000, 0))) }()
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatalf("failed to scrape the sample: %s", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `kuberhealthy_metrics_pipeline_probe{probe_id="abc"} 1700000000`+"\n") {
+		t.Fatalf("sample is missing from the scrape:\n%s", body)
+	}
+
+	cancel()
+	err = <-done
+	if err != nil {
+		t.Fatalf("expected the server to stop cleanly, got: %s", err)
+	}
+}
+
+func TestPushSample(t *testing.T) {
+	var requests []string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+strings.TrimSpace(string(body)))
+	}))
+	defer gateway.Close()
+
+	ctx := context.Background()
+	_, err := pushSample(ctx, gateway.Client(), gateway.URL+"/", "abc", "sample")
+	if err != nil {
+		t.Fatalf("failed to push the sample: %s", err)
+	}
+	_, err = deletePushedSample(ctx, gateway.Client(), gateway.URL, "abc")
+	if err != nil {
+		t.Fatalf("failed to delete the sample: %s", err)
+	}
+
+	expected := []string{
+		"PUT /metrics/job/kuberhealthy_metrics_pipeline_check/probe_id/abc sample",
+		"DELETE /metrics/job/kuberhealthy_metrics_pipeline_check/probe_id/abc ",
+	}
+	if strings.Join(requests, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected requests:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(requests, "\n"))
+	}
+
+	_, err = pushURL("pushgateway:9091", "abc")
+	if err == nil {
+		t.Fatalf("expected a pushgateway URL without a scheme to be refused")
+	}
+}
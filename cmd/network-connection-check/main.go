@@ -6,7 +6,6 @@ import (
 	"context"
 	"errors"
 	"io/ioutil"
-	"net"
 	"os"
 	"strconv"
 	"strings"
@@ -22,6 +21,7 @@ import (
 	checkclient "github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/nodeCheck"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/kubeClient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
 )
 
 var (
@@ -142,29 +142,36 @@ func (ncc *Checker) Run(ctx context.Context, cancel context.CancelFunc, client *
 	}
 }
 
-// doChecks does validations on the network connection call to the endpoint
+// doChecks does validations on the network connection call to the endpoint.  Connections that go over the latency
+// budget count as down.
 func (ncc *Checker) doChecks() error {
 
 	network, address := splitAddress(ncc.connectionTarget)
 
-	var localAddr net.Addr
-	if network == "udp" {
-		localAddr = &net.UDPAddr{IP: net.ParseIP(ncc.connectionTarget)}
-	} else {
-		localAddr = &net.TCPAddr{IP: net.ParseIP(ncc.connectionTarget)}
+	budget, err := probe.BudgetFromEnv()
+	if err != nil {
+		return err
 	}
 
-	d := net.Dialer{LocalAddr: localAddr, Timeout: time.Duration(checkTimeout)}
-	conn, err := d.Dial(network, address)
+	dialCtx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	conn, timing, err := probe.Dial(dialCtx, network, address, nil)
 	if err != nil {
 		errorMessage := "Network connection check determined that " + ncc.connectionTarget + " is DOWN: " + err.Error()
 		log.Errorln(errorMessage)
 		return errors.New(errorMessage)
 	}
+	log.Infoln("Connected to", ncc.connectionTarget, "in", timing)
 	err = conn.Close()
 	if err != nil {
 		return errors.New(err.Error())
 	}
+
+	if overBudget := budget.Check(timing); len(overBudget) > 0 {
+		errorMessage := "Network connection check determined that " + ncc.connectionTarget + " is over the latency budget: " + strings.Join(overBudget, ", ")
+		log.Errorln(errorMessage)
+		return errors.New(errorMessage)
+	}
 	return nil
 }
 
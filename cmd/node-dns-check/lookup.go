@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// lookupFunc looks up host through a single nameserver
+type lookupFunc func(ctx context.Context, nameserver string, host string) ([]string, error)
+
+// lookupThrough looks up host by sending the query straight to nameserver rather than through the resolver of the
+// pod, so that every nameserver is tried and not just the first one that answers
+func lookupThrough(ctx context.Context, nameserver string, host string) ([]string, error) {
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			d := net.Dialer{}
+			return d.DialContext(ctx, network, net.JoinHostPort(nameserver, "53"))
+		},
+	}
+	return r.LookupHost(ctx, host)
+}
+
+// probeNameservers looks up host through each nameserver and reports each as a sub-check
+func probeNameservers(ctx context.Context, lookup lookupFunc, nameservers []string, host string, timeout time.Duration) []status.SubCheck {
+	subChecks := make([]status.SubCheck, 0, len(nameservers))
+	for _, nameserver := range nameservers {
+		sc := status.SubCheck{Name: "nameserver " + nameserver}
+		lookupCtx, cancel := context.WithTimeout(ctx, timeout)
+		addrs, err := lookup(lookupCtx, nameserver, host)
+		cancel()
+		switch {
+		case err != nil:
+			sc.Errors = []string{fmt.Sprintf("failed to look up %s: %s", host, err)}
+		case len(addrs) == 0:
+			sc.Errors = []string{fmt.Sprintf("no addresses returned for %s", host)}
+		}
+		sc.OK = len(sc.Errors) == 0
+		subChecks = append(subChecks, sc)
+	}
+	return subChecks
+}
+
+// checkSearchPathLookup looks up a name that is not fully qualified through the resolver of the pod, which only
+// resolves when the search path and ndots of the pod work together
+func checkSearchPathLookup(ctx context.Context, name string, timeout time.Duration) status.SubCheck {
+	sc := status.SubCheck{Name: "search path lookup"}
+	lookupCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	r := &net.Resolver{PreferGo: true}
+	_, err := r.LookupHost(lookupCtx, name)
+	if err != nil {
+		sc.Errors = []string{fmt.Sprintf("failed to look up %s: %s", name, err)}
+	}
+	sc.OK = len(sc.Errors) == 0
+	return sc
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestProbeNameservers(t *testing.T) {
+	lookup := func(ctx context.Context, nameserver string, host string) ([]string, error) {
+		switch nameserver {
+		case "10.96.0.10":
+			return []string{"10.96.0.1"}, nil
+		case "10.96.0.11":
+			return nil, nil
+		}
+		<-ctx.Done()
+		return nil, errors.New("i/o timeout")
+	}
+
+	subChecks := probeNameservers(context.Background(), lookup, []string{"10.96.0.10", "10.96.0.11", "10.96.0.12"}, "kubernetes.default.svc.cluster.local.", time.Millisecond)
+	expectOK := []bool{true, false, false}
+	if len(subChecks) != len(expectOK) {
+		t.Fatalf("expected a sub-check per nameserver but got %+v", subChecks)
+	}
+	for i, sc := range subChecks {
+		if sc.OK != expectOK[i] || sc.OK != (len(sc.Errors) == 0) {
+			t.Fatalf("probed nameserver %+v but expected OK: %t", sc, expectOK[i])
+		}
+	}
+	if subChecks[2].Name != "nameserver 10.96.0.12" {
+		t.Fatalf("expected sub-checks to be named after their nameserver but got %s", subChecks[2].Name)
+	}
+}
@@ -0,0 +1,195 @@
+// Package node-dns-check implements a checker for Kuberhealthy that runs on every node and verifies that pods on the
+// node get the expected DNS configuration, and that the nameservers they are given answer
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	kh "github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/nodeCheck"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// resolvConfPath is where the kubelet writes the DNS configuration of the pod
+const resolvConfPath = "/etc/resolv.conf"
+
+var (
+	// clusterDomain is the DNS domain of the cluster
+	clusterDomain = os.Getenv("CLUSTER_DOMAIN")
+
+	// expectedNdots is the ndots pods are expected to get
+	expectedNdots = os.Getenv("EXPECTED_NDOTS")
+
+	// expectedSearchDomains are the comma separated domains the search path of pods is expected to start with
+	expectedSearchDomains = os.Getenv("EXPECTED_SEARCH_DOMAINS")
+
+	// allowExtraSearchDomains allows domains after the expected ones in the search path, such as those the node
+	// inherits from its cloud provider
+	allowExtraSearchDomains = os.Getenv("ALLOW_EXTRA_SEARCH_DOMAINS")
+
+	// expectedNameservers are the comma separated nameservers pods are expected to get, in order
+	expectedNameservers = os.Getenv("EXPECTED_NAMESERVERS")
+
+	// nameserverLookupHost is the host looked up through each nameserver
+	nameserverLookupHost = os.Getenv("NAMESERVER_LOOKUP_HOST")
+
+	// searchLookupName is the name that is not fully qualified looked up through the search path
+	searchLookupName = os.Getenv("SEARCH_LOOKUP_NAME")
+
+	// lookupTimeout is how long each lookup may take
+	lookupTimeout = os.Getenv("LOOKUP_TIMEOUT")
+)
+
+// Config holds the settings of the check
+type Config struct {
+	Template             Template
+	NameserverLookupHost string
+	SearchLookupName     string
+	LookupTimeout        time.Duration
+}
+
+func init() {
+	// set debug mode for nodeCheck pkg
+	nodeCheck.EnableDebugOutput()
+}
+
+func main() {
+	deadline, err := kh.GetDeadline()
+	if err != nil {
+		log.Warningln("Failed to read the deadline of the run, allowing five minutes:", err)
+		deadline = time.Now().Add(5 * time.Minute)
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline.Add(-5*time.Second))
+	defer cancel()
+
+	// hits kuberhealthy endpoint to see if node is ready
+	err = nodeCheck.WaitForKuberhealthy(ctx)
+	if err != nil {
+		log.Errorln("Error waiting for kuberhealthy endpoint to be contactable by checker pod with error:" + err.Error())
+	}
+
+	cfg, err := parseConfig()
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+
+	f, err := os.Open(resolvConfPath)
+	if err != nil {
+		ReportFailureAndExit(fmt.Errorf("failed to open %s: %w", resolvConfPath, err))
+	}
+	conf, err := parseResolvConf(f)
+	f.Close()
+	if err != nil {
+		ReportFailureAndExit(fmt.Errorf("failed to parse %s: %w", resolvConfPath, err))
+	}
+	log.Infoln("Pod DNS configuration: nameservers", conf.Nameservers, "search", conf.Search, "options", conf.Options)
+
+	subChecks := []status.SubCheck{
+		checkNdots(conf, cfg.Template),
+		checkSearchDomains(conf, cfg.Template),
+		checkNameservers(conf, cfg.Template),
+	}
+	subChecks = append(subChecks, probeNameservers(ctx, lookupThrough, conf.Nameservers, cfg.NameserverLookupHost, cfg.LookupTimeout)...)
+	subChecks = append(subChecks, checkSearchPathLookup(ctx, cfg.SearchLookupName, cfg.LookupTimeout))
+	for _, sc := range subChecks {
+		if sc.OK {
+			log.Infoln(sc.Name + ": OK")
+			continue
+		}
+		log.Errorln(sc.Name+":", sc.Errors)
+	}
+
+	err = kh.ReportSubChecks(subChecks)
+	if err != nil {
+		log.Errorln("Error reporting to Kuberhealthy servers:", err)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported to Kuberhealthy servers")
+}
+
+// parseConfig reads the settings of the check from the environment.  The expected search path defaults to the one
+// the kubelet gives pods in the namespace of the checker pod.
+func parseConfig() (Config, error) {
+	domain := strings.TrimSuffix(clusterDomain, ".")
+	if len(domain) == 0 {
+		domain = "cluster.local"
+	}
+	cfg := Config{
+		Template: Template{
+			Ndots:                   5,
+			AllowExtraSearchDomains: true,
+			Nameservers:             splitList(expectedNameservers),
+		},
+		NameserverLookupHost: nameserverLookupHost,
+		SearchLookupName:     searchLookupName,
+		LookupTimeout:        5 * time.Second,
+	}
+
+	var err error
+	if len(expectedNdots) > 0 {
+		cfg.Template.Ndots, err = strconv.Atoi(expectedNdots)
+		if err != nil || cfg.Template.Ndots < 0 {
+			return Config{}, fmt.Errorf("failed to parse EXPECTED_NDOTS %q: must be a number of at least 0", expectedNdots)
+		}
+	}
+	if len(allowExtraSearchDomains) > 0 {
+		cfg.Template.AllowExtraSearchDomains, err = strconv.ParseBool(allowExtraSearchDomains)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to parse ALLOW_EXTRA_SEARCH_DOMAINS: %w", err)
+		}
+	}
+	if len(lookupTimeout) > 0 {
+		cfg.LookupTimeout, err = time.ParseDuration(lookupTimeout)
+		if err != nil || cfg.LookupTimeout <= 0 {
+			return Config{}, fmt.Errorf("failed to parse LOOKUP_TIMEOUT %q: must be a positive duration", lookupTimeout)
+		}
+	}
+
+	cfg.Template.SearchDomains = splitList(expectedSearchDomains)
+	if len(cfg.Template.SearchDomains) == 0 {
+		namespace := os.Getenv(external.KHPodNamespace)
+		if len(namespace) == 0 {
+			return Config{}, fmt.Errorf("EXPECTED_SEARCH_DOMAINS is not set and the namespace of the pod is unknown")
+		}
+		cfg.Template.SearchDomains = []string{namespace + ".svc." + domain, "svc." + domain, domain}
+	}
+	if len(cfg.NameserverLookupHost) == 0 {
+		cfg.NameserverLookupHost = "kubernetes.default.svc." + domain + "."
+	}
+	if len(cfg.SearchLookupName) == 0 {
+		cfg.SearchLookupName = "kubernetes.default"
+	}
+	return cfg, nil
+}
+
+// splitList splits a comma separated list and drops blank entries
+func splitList(list string) []string {
+	var entries []string
+	for _, e := range strings.Split(list, ",") {
+		if e = strings.TrimSpace(e); len(e) > 0 {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// ReportFailureAndExit reports an error to Kuberhealthy and exits the program
+func ReportFailureAndExit(err error) {
+	log.Errorln(err)
+	err2 := kh.ReportFailure([]string{err.Error()})
+	if err2 != nil {
+		log.Errorln("Error reporting failure to Kuberhealthy servers:", err2)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported failure to Kuberhealthy servers")
+	os.Exit(0)
+}
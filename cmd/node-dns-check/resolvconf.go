@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+const (
+	// defaultNdots is the ndots the resolver uses when resolv.conf doesn't set it
+	defaultNdots = 1
+
+	// maxSearchDomains and maxSearchListChars are the limits the kubelet puts on the search path of pods.  The kubelet
+	// drops the domains past them, which breaks the lookups that depended on them.
+	maxSearchDomains   = 32
+	maxSearchListChars = 2048
+)
+
+// ResolvConf is the DNS configuration of a pod, as read from its resolv.conf
+type ResolvConf struct {
+	Nameservers []string
+	Search      []string
+	Options     map[string]string // the value of each option, or an empty string for options without one
+}
+
+// Template is the DNS configuration pods are expected to get on every node
+type Template struct {
+	Ndots                   int
+	SearchDomains           []string
+	AllowExtraSearchDomains bool
+	Nameservers             []string // any nameservers are accepted when empty
+}
+
+// parseResolvConf reads a resolv.conf the same way the resolver does.  When a file has several search or domain lines
+// the last one wins, and options override those set earlier.
+func parseResolvConf(r io.Reader) (ResolvConf, error) {
+	conf := ResolvConf{Options: make(map[string]string)}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) > 0 && (line[0] == '#' || line[0] == ';') {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			if len(fields) < 2 || net.ParseIP(fields[1]) == nil {
+				return ResolvConf{}, fmt.Errorf("invalid nameserver line %q", line)
+			}
+			conf.Nameservers = append(conf.Nameservers, fields[1])
+		case "search", "domain":
+			conf.Search = fields[1:]
+		case "options":
+			for _, option := range fields[1:] {
+				name, value, _ := strings.Cut(option, ":")
+				conf.Options[name] = value
+			}
+		}
+	}
+	err := scanner.Err()
+	if err != nil {
+		return ResolvConf{}, fmt.Errorf("failed to read resolv.conf: %w", err)
+	}
+	return conf, nil
+}
+
+// Ndots returns the number of dots a name needs to be looked up as is before the search domains are tried
+func (c ResolvConf) Ndots() (int, error) {
+	value, ok := c.Options["ndots"]
+	if !ok {
+		return defaultNdots, nil
+	}
+	ndots, err := strconv.Atoi(value)
+	if err != nil || ndots < 0 {
+		return 0, fmt.Errorf("invalid ndots option %q", value)
+	}
+	return ndots, nil
+}
+
+// normalizeDomain makes domains that only differ by case or a trailing dot compare equal
+func normalizeDomain(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(domain, "."))
+}
+
+// checkNdots makes sure pods are configured with the expected ndots
+func checkNdots(conf ResolvConf, template Template) status.SubCheck {
+	sc := status.SubCheck{Name: "ndots"}
+	ndots, err := conf.Ndots()
+	switch {
+	case err != nil:
+		sc.Errors = []string{err.Error()}
+	case ndots != template.Ndots:
+		sc.Errors = []string{fmt.Sprintf("ndots is %d, expected %d", ndots, template.Ndots)}
+	}
+	sc.OK = len(sc.Errors) == 0
+	return sc
+}
+
+// checkSearchDomains makes sure the search path of pods starts with the expected domains, in order, and stays within
+// the limits of the kubelet
+func checkSearchDomains(conf ResolvConf, template Template) status.SubCheck {
+	sc := status.SubCheck{Name: "search domains"}
+
+	seen := make(map[string]bool, len(conf.Search))
+	for _, domain := range conf.Search {
+		if seen[normalizeDomain(domain)] {
+			sc.Errors = append(sc.Errors, fmt.Sprintf("search domain %s is listed more than once", domain))
+		}
+		seen[normalizeDomain(domain)] = true
+	}
+	if len(conf.Search) > maxSearchDomains {
+		sc.Errors = append(sc.Errors, fmt.Sprintf("search path has %d domains, more than the limit of %d", len(conf.Search), maxSearchDomains))
+	}
+	if n := len(strings.Join(conf.Search, " ")); n > maxSearchListChars {
+		sc.Errors = append(sc.Errors, fmt.Sprintf("search path is %d characters long, more than the limit of %d", n, maxSearchListChars))
+	}
+
+	matches := len(conf.Search) >= len(template.SearchDomains)
+	for i := 0; matches && i < len(template.SearchDomains); i++ {
+		matches = normalizeDomain(conf.Search[i]) == normalizeDomain(template.SearchDomains[i])
+	}
+	switch {
+	case !matches:
+		sc.Errors = append(sc.Errors, fmt.Sprintf("search path is %v, expected it to start with %v", conf.Search, template.SearchDomains))
+	case !template.AllowExtraSearchDomains && len(conf.Search) > len(template.SearchDomains):
+		sc.Errors = append(sc.Errors, fmt.Sprintf("unexpected search domains %v after %v", conf.Search[len(template.SearchDomains):], template.SearchDomains))
+	}
+	sc.OK = len(sc.Errors) == 0
+	return sc
+}
+
+// checkNameservers makes sure pods are configured with nameservers, and with the expected ones in order when the
+// template lists them
+func checkNameservers(conf ResolvConf, template Template) status.SubCheck {
+	sc := status.SubCheck{Name: "nameservers"}
+	switch {
+	case len(conf.Nameservers) == 0:
+		sc.Errors = []string{"no nameservers are configured"}
+	case len(template.Nameservers) > 0 && strings.Join(conf.Nameservers, ",") != strings.Join(template.Nameservers, ","):
+		sc.Errors = []string{fmt.Sprintf("nameservers are %v, expected %v", conf.Nameservers, template.Nameservers)}
+	}
+	sc.OK = len(sc.Errors) == 0
+	return sc
+}
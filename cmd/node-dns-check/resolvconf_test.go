@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseResolvConf(t *testing.T) {
+	conf, err := parseResolvConf(strings.NewReader(`# generated by the kubelet
+search old.example.com
+nameserver 10.96.0.10
+; a comment
+nameserver fd00::a
+search kuberhealthy.svc.cluster.local svc.cluster.local cluster.local
+options ndots:5 edns0
+`))
+	if err != nil {
+		t.Fatal("Failed to parse resolv.conf:", err)
+	}
+	expected := ResolvConf{
+		Nameservers: []string{"10.96.0.10", "fd00::a"},
+		Search:      []string{"kuberhealthy.svc.cluster.local", "svc.cluster.local", "cluster.local"},
+		Options:     map[string]string{"ndots": "5", "edns0": ""},
+	}
+	if !reflect.DeepEqual(conf, expected) {
+		t.Fatalf("parsed %+v but expected %+v", conf, expected)
+	}
+
+	_, err = parseResolvConf(strings.NewReader("nameserver dns.example.com\n"))
+	if err == nil {
+		t.Fatal("expected a nameserver that is not an IP to be refused")
+	}
+
+	conf, err = parseResolvConf(strings.NewReader("nameserver 10.96.0.10\n"))
+	if err != nil {
+		t.Fatal("Failed to parse resolv.conf:", err)
+	}
+	ndots, err := conf.Ndots()
+	if err != nil || ndots != defaultNdots {
+		t.Fatalf("expected ndots to default to %d but got %d and error %v", defaultNdots, ndots, err)
+	}
+}
+
+func TestCheckResolvConf(t *testing.T) {
+	template := Template{
+		Ndots:                   5,
+		SearchDomains:           []string{"kuberhealthy.svc.cluster.local", "svc.cluster.local", "cluster.local"},
+		AllowExtraSearchDomains: true,
+		Nameservers:             []string{"10.96.0.10"},
+	}
+	strict := template
+	strict.AllowExtraSearchDomains = false
+
+	var testCases = []struct {
+		description       string
+		conf              ResolvConf
+		template          Template
+		expectNdots       bool
+		expectSearch      bool
+		expectNameservers bool
+	}{
+		{"matching template", ResolvConf{Nameservers: []string{"10.96.0.10"}, Search: []string{"kuberhealthy.svc.cluster.local", "svc.cluster.local", "cluster.local"}, Options: map[string]string{"ndots": "5"}}, template, true, true, true},
+		{"extra search domain", ResolvConf{Nameservers: []string{"10.96.0.10"}, Search: []string{"kuberhealthy.svc.cluster.local", "svc.cluster.local", "Cluster.Local.", "ec2.internal"}, Options: map[string]string{"ndots": "5"}}, template, true, true, true},
+		{"extra search domain in strict template", ResolvConf{Nameservers: []string{"10.96.0.10"}, Search: []string{"kuberhealthy.svc.cluster.local", "svc.cluster.local", "cluster.local", "ec2.internal"}, Options: map[string]string{"ndots": "5"}}, strict, true, false, true},
+		{"reordered search domains", ResolvConf{Nameservers: []string{"10.96.0.10"}, Search: []string{"svc.cluster.local", "kuberhealthy.svc.cluster.local", "cluster.local"}, Options: map[string]string{"ndots": "5"}}, template, true, false, true},
+		{"duplicate search domain", ResolvConf{Nameservers: []string{"10.96.0.10"}, Search: []string{"kuberhealthy.svc.cluster.local", "svc.cluster.local", "cluster.local", "cluster.local"}, Options: map[string]string{"ndots": "5"}}, template, true, false, true},
+		{"default ndots", ResolvConf{Nameservers: []string{"10.96.0.10"}, Search: []string{"kuberhealthy.svc.cluster.local", "svc.cluster.local", "cluster.local"}}, template, false, true, true},
+		{"invalid ndots", ResolvConf{Nameservers: []string{"10.96.0.10"}, Search: []string{"kuberhealthy.svc.cluster.local", "svc.cluster.local", "cluster.local"}, Options: map[string]string{"ndots": "x"}}, template, false, true, true},
+		{"unexpected nameserver", ResolvConf{Nameservers: []string{"169.254.20.10"}, Search: []string{"kuberhealthy.svc.cluster.local", "svc.cluster.local", "cluster.local"}, Options: map[string]string{"ndots": "5"}}, template, true, true, false},
+		{"no nameservers", ResolvConf{Search: []string{"kuberhealthy.svc.cluster.local", "svc.cluster.local", "cluster.local"}, Options: map[string]string{"ndots": "5"}}, Template{Ndots: 5, SearchDomains: template.SearchDomains}, true, true, false},
+	}
+	for _, tc := range testCases {
+		if sc := checkNdots(tc.conf, tc.template); sc.OK != tc.expectNdots {
+			t.Fatalf("%s: checked ndots %+v but expected OK: %t", tc.description, sc, tc.expectNdots)
+		}
+		if sc := checkSearchDomains(tc.conf, tc.template); sc.OK != tc.expectSearch {
+			t.Fatalf("%s: checked search domains %+v but expected OK: %t", tc.description, sc, tc.expectSearch)
+		}
+		if sc := checkNameservers(tc.conf, tc.template); sc.OK != tc.expectNameservers {
+			t.Fatalf("%s: checked nameservers %+v but expected OK: %t", tc.description, sc, tc.expectNameservers)
+		}
+	}
+}
+
+func TestCheckSearchDomainLimits(t *testing.T) {
+	conf := ResolvConf{}
+	for i := 0; i <= maxSearchDomains; i++ {
+		conf.Search = append(conf.Search, strings.Repeat("a", 70)+strings.Repeat("b", i)+".example.com")
+	}
+	sc := checkSearchDomains(conf, Template{AllowExtraSearchDomains: true})
+	if sc.OK || len(sc.Errors) != 2 {
+		t.Fatalf("expected the search path to exceed both kubelet limits but got %+v", sc)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// adminBindingsSubCheck is the name of the sub-check that reports unexpected cluster admin bindings
+const adminBindingsSubCheck = "cluster admin bindings"
+
+// subjectKey names a subject of a binding the way ALLOWED_ADMIN_SUBJECTS lists them, such as Group:system:masters,
+// User:alice or ServiceAccount:kube-system/admin
+func subjectKey(s rbacv1.Subject) string {
+	if s.Kind == rbacv1.ServiceAccountKind {
+		return s.Kind + ":" + s.Namespace + "/" + s.Name
+	}
+	return s.Kind + ":" + s.Name
+}
+
+// unexpectedAdminBindings returns a problem for each subject that is not allowed but bound to one of the admin
+// cluster roles by one of the supplied cluster role bindings, sorted by binding name
+func unexpectedAdminBindings(bindings []rbacv1.ClusterRoleBinding, adminRoles []string, allowed map[string]bool) []string {
+	isAdmin := make(map[string]bool, len(adminRoles))
+	for _, r := range adminRoles {
+		isAdmin[r] = true
+	}
+	sort.Slice(bindings, func(i, j int) bool { return bindings[i].Name < bindings[j].Name })
+
+	var problems []string
+	for _, b := range bindings {
+		if b.RoleRef.Kind != "ClusterRole" || !isAdmin[b.RoleRef.Name] {
+			continue
+		}
+		for _, s := range b.Subjects {
+			if allowed[subjectKey(s)] {
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("clusterrolebinding %s binds %s to %s, which is not an allowed admin subject", b.Name, b.RoleRef.Name, subjectKey(s)))
+		}
+	}
+	return problems
+}
+
+// checkClusterAdminBindings lists the cluster role bindings and reports the subjects bound to an admin cluster role
+// that are not allowed as a sub-check
+func checkClusterAdminBindings(ctx context.Context, client kubernetes.Interface, adminRoles []string, allowed map[string]bool) status.SubCheck {
+	bindings, err := client.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return status.SubCheck{Name: adminBindingsSubCheck, Errors: []string{fmt.Sprintf("failed to list cluster role bindings: %s", err)}}
+	}
+	problems := unexpectedAdminBindings(bindings.Items, adminRoles, allowed)
+	if len(problems) > 0 {
+		return status.SubCheck{Name: adminBindingsSubCheck, Errors: problems}
+	}
+	return status.SubCheck{Name: adminBindingsSubCheck, OK: true}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// clusterRoleBinding makes a cluster role binding of the supplied cluster role
+func clusterRoleBinding(name string, role string, subjects ...rbacv1.Subject) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: role},
+		Subjects:   subjects,
+	}
+}
+
+func TestCheckClusterAdminBindings(t *testing.T) {
+	masters := rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "system:masters"}
+	alice := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "alice"}
+	ciBot := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "ci-bot", Namespace: "ci"}
+	allowed := map[string]bool{"Group:system:masters": true, "ServiceAccount:ci/deployer": true}
+
+	client := fake.NewSimpleClientset(
+		clusterRoleBinding("cluster-admin", "cluster-admin", masters),
+		clusterRoleBinding("zz-debug", "cluster-admin", alice),
+		clusterRoleBinding("ci", "cluster-admin", rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "deployer", Namespace: "ci"}, ciBot),
+		clusterRoleBinding("view", "view", alice),
+	)
+
+	subCheck := checkClusterAdminBindings(context.Background(), client, []string{"cluster-admin"}, allowed)
+	expected := []string{
+		"clusterrolebinding ci binds cluster-admin to ServiceAccount:ci/ci-bot, which is not an allowed admin subject",
+		"clusterrolebinding zz-debug binds cluster-admin to User:alice, which is not an allowed admin subject",
+	}
+	if subCheck.Name != adminBindingsSubCheck || subCheck.OK || !reflect.DeepEqual(subCheck.Errors, expected) {
+		t.Fatalf("checked cluster admin bindings %+v but expected errors %v", subCheck, expected)
+	}
+
+	// the view role is not an admin role unless configured as one
+	subCheck = checkClusterAdminBindings(context.Background(), fake.NewSimpleClientset(clusterRoleBinding("view", "view", alice)), []string{"cluster-admin"}, allowed)
+	if !subCheck.OK {
+		t.Fatalf("expected bindings of other roles to be ignored but got %+v", subCheck)
+	}
+}
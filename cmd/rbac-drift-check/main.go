@@ -0,0 +1,180 @@
+// Package rbac-drift-check implements a checker for Kuberhealthy that verifies that service accounts can still get
+// tokens and still hold the permissions they are expected to, and that no unexpected subject has been bound to a
+// cluster admin role
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	kh "github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/nodeCheck"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/kubeClient"
+)
+
+var (
+	// kubeConfigFile is used when the check runs outside of a cluster
+	kubeConfigFile = filepath.Join(os.Getenv("HOME"), ".kube", "config")
+
+	// expectedPermissions are the permissions that service accounts are expected to have, or not to have, one per
+	// line or separated by semicolons
+	expectedPermissions = os.Getenv("EXPECTED_PERMISSIONS")
+
+	// checkAdminBindings makes the check fail when a subject that is not allowed is bound to a cluster admin role
+	checkAdminBindings = os.Getenv("CHECK_ADMIN_BINDINGS")
+
+	// adminClusterRoles are the comma separated cluster roles whose cluster role bindings are checked
+	adminClusterRoles = os.Getenv("ADMIN_CLUSTER_ROLES")
+
+	// allowedAdminSubjects are the comma separated subjects that may be bound to a cluster admin role
+	allowedAdminSubjects = os.Getenv("ALLOWED_ADMIN_SUBJECTS")
+)
+
+// Config holds the settings of the check
+type Config struct {
+	Permissions          []Permission
+	CheckAdminBindings   bool
+	AdminClusterRoles    []string
+	AllowedAdminSubjects map[string]bool
+}
+
+func init() {
+	// set debug mode for nodeCheck pkg
+	nodeCheck.EnableDebugOutput()
+}
+
+func main() {
+	deadline, err := kh.GetDeadline()
+	if err != nil {
+		log.Warningln("Failed to read the deadline of the run, allowing five minutes:", err)
+		deadline = time.Now().Add(5 * time.Minute)
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline.Add(-5*time.Second))
+	defer cancel()
+
+	// hits kuberhealthy endpoint to see if node is ready
+	err = nodeCheck.WaitForKuberhealthy(ctx)
+	if err != nil {
+		log.Errorln("Error waiting for kuberhealthy endpoint to be contactable by checker pod with error:" + err.Error())
+	}
+
+	cfg, err := parseConfig()
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+
+	restConfig, err := kubeClient.RestConfig(kubeConfigFile)
+	if err != nil {
+		ReportFailureAndExit(fmt.Errorf("failed to create kubernetes client configuration: %w", err))
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		ReportFailureAndExit(fmt.Errorf("failed to create kubernetes client: %w", err))
+	}
+
+	// permissions are probed with the tokens of the service accounts themselves rather than the credentials of the
+	// checker pod
+	newTokenClient := func(token string) (kubernetes.Interface, error) {
+		return kubernetes.NewForConfig(tokenConfig(restConfig, token))
+	}
+
+	var subChecks []status.SubCheck
+	subChecks = append(subChecks, probePermissions(ctx, client, newTokenClient, cfg.Permissions)...)
+	if cfg.CheckAdminBindings {
+		subChecks = append(subChecks, checkClusterAdminBindings(ctx, client, cfg.AdminClusterRoles, cfg.AllowedAdminSubjects))
+	}
+	for _, sc := range subChecks {
+		if sc.OK {
+			log.Infoln(sc.Name + ": OK")
+			continue
+		}
+		log.Errorln(sc.Name+":", sc.Errors)
+	}
+
+	err = kh.ReportSubChecks(subChecks)
+	if err != nil {
+		log.Errorln("Error reporting to Kuberhealthy servers:", err)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported to Kuberhealthy servers")
+}
+
+// tokenConfig copies the supplied client configuration to authenticate with a bearer token instead of the
+// credentials of the checker pod
+func tokenConfig(config *rest.Config, token string) *rest.Config {
+	c := rest.AnonymousClientConfig(config)
+	c.BearerToken = token
+	return c
+}
+
+// parseConfig reads the settings of the check from the environment
+func parseConfig() (Config, error) {
+	cfg := Config{CheckAdminBindings: true}
+
+	var err error
+	cfg.Permissions, err = parsePermissions(expectedPermissions)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse EXPECTED_PERMISSIONS: %w", err)
+	}
+	if len(checkAdminBindings) > 0 {
+		cfg.CheckAdminBindings, err = strconv.ParseBool(checkAdminBindings)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to parse CHECK_ADMIN_BINDINGS: %w", err)
+		}
+	}
+	if len(cfg.Permissions) == 0 && !cfg.CheckAdminBindings {
+		return Config{}, fmt.Errorf("nothing to check: set EXPECTED_PERMISSIONS or CHECK_ADMIN_BINDINGS")
+	}
+
+	cfg.AdminClusterRoles = splitList(adminClusterRoles)
+	if len(cfg.AdminClusterRoles) == 0 {
+		cfg.AdminClusterRoles = []string{"cluster-admin"}
+	}
+
+	subjects := splitList(allowedAdminSubjects)
+	if len(allowedAdminSubjects) == 0 {
+		subjects = []string{"Group:system:masters"}
+	}
+	cfg.AllowedAdminSubjects = make(map[string]bool, len(subjects))
+	for _, s := range subjects {
+		if _, _, ok := strings.Cut(s, ":"); !ok {
+			return Config{}, fmt.Errorf("ALLOWED_ADMIN_SUBJECTS entry %q must be of the form Kind:name", s)
+		}
+		cfg.AllowedAdminSubjects[s] = true
+	}
+	return cfg, nil
+}
+
+// splitList splits a comma separated list and drops blank entries
+func splitList(list string) []string {
+	var entries []string
+	for _, e := range strings.Split(list, ",") {
+		if e = strings.TrimSpace(e); len(e) > 0 {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// ReportFailureAndExit reports an error to Kuberhealthy and exits the program
+func ReportFailureAndExit(err error) {
+	log.Errorln(err)
+	err2 := kh.ReportFailure([]string{err.Error()})
+	if err2 != nil {
+		log.Errorln("Error reporting failure to Kuberhealthy servers:", err2)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported failure to Kuberhealthy servers")
+	os.Exit(0)
+}
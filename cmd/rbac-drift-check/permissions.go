@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// tokenExpirationSeconds is how long the tokens requested for service accounts are valid.  Ten minutes is the
+// shortest expiration the API server accepts.
+const tokenExpirationSeconds = 600
+
+// Permission is an access that a service account is expected to have, or expected not to have
+type Permission struct {
+	ServiceAccountNamespace string
+	ServiceAccount          string
+	Verb                    string
+	Resource                string
+	Group                   string
+	Subresource             string
+	Namespace               string // the namespace the access is in, or empty for cluster scoped access
+	Allowed                 bool   // false when the service account must not have the access
+}
+
+// String describes the permission, such as kuberhealthy/deployment-sa can create deployments.apps in kuberhealthy
+func (p Permission) String() string {
+	can := "can"
+	if !p.Allowed {
+		can = "cannot"
+	}
+	resource := p.Resource
+	if len(p.Group) > 0 {
+		resource += "." + p.Group
+	}
+	if len(p.Subresource) > 0 {
+		resource += "/" + p.Subresource
+	}
+	s := fmt.Sprintf("%s/%s %s %s %s", p.ServiceAccountNamespace, p.ServiceAccount, can, p.Verb, resource)
+	if len(p.Namespace) > 0 {
+		s += " in " + p.Namespace
+	}
+	return s
+}
+
+// parsePermissions parses expected permissions, one per line or separated by semicolons, of the form
+// <namespace>/<service account> [!]<verb> <resource>[.<group>][/<subresource>] [<namespace>].  A verb starting with
+// ! means the service account must not have the access.  Blank entries and entries starting with # are skipped.
+func parsePermissions(spec string) ([]Permission, error) {
+	var permissions []Permission
+	seen := make(map[string]bool)
+	for _, entry := range strings.FieldsFunc(spec, func(r rune) bool { return r == '\n' || r == ';' }) {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		p, err := parsePermission(entry)
+		if err != nil {
+			return nil, err
+		}
+		if seen[p.String()] {
+			return nil, fmt.Errorf("permission %q appears more than once", entry)
+		}
+		seen[p.String()] = true
+		permissions = append(permissions, p)
+	}
+	return permissions, nil
+}
+
+// parsePermission parses a single expected permission
+func parsePermission(entry string) (Permission, error) {
+	fields := strings.Fields(entry)
+	if len(fields) != 3 && len(fields) != 4 {
+		return Permission{}, fmt.Errorf("permission %q must be of the form <namespace>/<service account> <verb> <resource> [<namespace>]", entry)
+	}
+
+	p := Permission{Allowed: true}
+	var ok bool
+	p.ServiceAccountNamespace, p.ServiceAccount, ok = strings.Cut(fields[0], "/")
+	if !ok || len(p.ServiceAccountNamespace) == 0 || len(p.ServiceAccount) == 0 {
+		return Permission{}, fmt.Errorf("permission %q must name a service account as <namespace>/<name>", entry)
+	}
+
+	p.Verb = fields[1]
+	if strings.HasPrefix(p.Verb, "!") {
+		p.Verb = strings.TrimPrefix(p.Verb, "!")
+		p.Allowed = false
+	}
+	if len(p.Verb) == 0 {
+		return Permission{}, fmt.Errorf("permission %q has no verb", entry)
+	}
+
+	resource := fields[2]
+	resource, p.Subresource, _ = strings.Cut(resource, "/")
+	p.Resource, p.Group, _ = strings.Cut(resource, ".")
+	if len(p.Resource) == 0 {
+		return Permission{}, fmt.Errorf("permission %q has no resource", entry)
+	}
+
+	if len(fields) == 4 {
+		p.Namespace = fields[3]
+	}
+	return p, nil
+}
+
+// probePermissions requests a token for each service account and uses it to ask the API server whether the service
+// account has each of its expected permissions.  Every permission is returned as a sub-check, which fails when the
+// service account could not get a token or its access differs from what was expected.
+func probePermissions(ctx context.Context, client kubernetes.Interface, newTokenClient func(token string) (kubernetes.Interface, error), permissions []Permission) []status.SubCheck {
+	subChecks := make([]status.SubCheck, 0, len(permissions))
+	tokenClients := make(map[string]kubernetes.Interface)
+	tokenErrors := make(map[string]error)
+	for _, p := range permissions {
+		account := p.ServiceAccountNamespace + "/" + p.ServiceAccount
+		if _, requested := tokenClients[account]; !requested {
+			tokenClients[account], tokenErrors[account] = serviceAccountClient(ctx, client, newTokenClient, p.ServiceAccountNamespace, p.ServiceAccount)
+		}
+		if err := tokenErrors[account]; err != nil {
+			subChecks = append(subChecks, status.SubCheck{Name: p.String(), Errors: []string{err.Error()}})
+			continue
+		}
+
+		allowed, reason, err := reviewAccess(ctx, tokenClients[account], p)
+		if err != nil {
+			subChecks = append(subChecks, status.SubCheck{Name: p.String(), Errors: []string{err.Error()}})
+			continue
+		}
+		log.Debugln("Access review of", p.String()+":", "allowed:", allowed, reason)
+		subChecks = append(subChecks, permissionSubCheck(p, allowed, reason))
+	}
+	return subChecks
+}
+
+// permissionSubCheck compares the access a service account was found to have with the access it was expected to have
+func permissionSubCheck(p Permission, allowed bool, reason string) status.SubCheck {
+	if allowed == p.Allowed {
+		return status.SubCheck{Name: p.String(), OK: true}
+	}
+	msg := "expected to be allowed but was denied"
+	if !p.Allowed {
+		msg = "expected to be denied but was allowed"
+	}
+	if len(reason) > 0 {
+		msg += ": " + reason
+	}
+	return status.SubCheck{Name: p.String(), Errors: []string{msg}}
+}
+
+// serviceAccountClient requests a short lived token for a service account and makes a client that authenticates
+// with it
+func serviceAccountClient(ctx context.Context, client kubernetes.Interface, newTokenClient func(token string) (kubernetes.Interface, error), namespace string, name string) (kubernetes.Interface, error) {
+	expiration := int64(tokenExpirationSeconds)
+	request := &authenticationv1.TokenRequest{Spec: authenticationv1.TokenRequestSpec{ExpirationSeconds: &expiration}}
+	tr, err := client.CoreV1().ServiceAccounts(namespace).CreateToken(ctx, name, request, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request a token for service account %s/%s: %w", namespace, name, err)
+	}
+	if len(tr.Status.Token) == 0 {
+		return nil, fmt.Errorf("the token requested for service account %s/%s is empty", namespace, name)
+	}
+	tokenClient, err := newTokenClient(tr.Status.Token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a client for service account %s/%s: %w", namespace, name, err)
+	}
+	return tokenClient, nil
+}
+
+// reviewAccess asks the API server whether the service account the client authenticates as has a permission.  The
+// reason the API server gave for its decision, if any, is returned along with it.
+func reviewAccess(ctx context.Context, client kubernetes.Interface, p Permission) (bool, string, error) {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   p.Namespace,
+				Verb:        p.Verb,
+				Group:       p.Group,
+				Resource:    p.Resource,
+				Subresource: p.Subresource,
+			},
+		},
+	}
+	result, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return false, "", fmt.Errorf("failed to review access as service account %s/%s: %w", p.ServiceAccountNamespace, p.ServiceAccount, err)
+	}
+	if len(result.Status.EvaluationError) > 0 {
+		log.Warningln("Access review of", p.String(), "had an evaluation error:", result.Status.EvaluationError)
+	}
+	return result.Status.Allowed, result.Status.Reason, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+func TestParsePermissions(t *testing.T) {
+	spec := `
+# the deployment check deploys into its own namespace
+kuberhealthy/deployment-sa create deployments.apps kuberhealthy
+kuberhealthy/deployment-sa !delete nodes; kube-system/reader get pods/log default
+`
+	permissions, err := parsePermissions(spec)
+	if err != nil {
+		t.Fatal("failed to parse permissions:", err)
+	}
+	expected := []Permission{
+		{ServiceAccountNamespace: "kuberhealthy", ServiceAccount: "deployment-sa", Verb: "create", Resource: "deployments", Group: "apps", Namespace: "kuberhealthy", Allowed: true},
+		{ServiceAccountNamespace: "kuberhealthy", ServiceAccount: "deployment-sa", Verb: "delete", Resource: "nodes"},
+		{ServiceAccountNamespace: "kube-system", ServiceAccount: "reader", Verb: "get", Resource: "pods", Subresource: "log", Namespace: "default", Allowed: true},
+	}
+	if !reflect.DeepEqual(permissions, expected) {
+		t.Fatalf("parsed permissions %+v but expected %+v", permissions, expected)
+	}
+
+	names := []string{
+		"kuberhealthy/deployment-sa can create deployments.apps in kuberhealthy",
+		"kuberhealthy/deployment-sa cannot delete nodes",
+		"kube-system/reader can get pods/log in default",
+	}
+	for i, p := range permissions {
+		if p.String() != names[i] {
+			t.Fatalf("permission was described as %q but expected %q", p.String(), names[i])
+		}
+	}
+
+	for _, bad := range []string{"deployment-sa create deployments", "kuberhealthy/ create deployments", "kuberhealthy/sa ! pods", "kuberhealthy/sa get", "kuberhealthy/sa get pods; kuberhealthy/sa get pods"} {
+		if _, err := parsePermissions(bad); err == nil {
+			t.Fatalf("expected permissions %q to be refused", bad)
+		}
+	}
+}
+
+// newReviewClient makes a fake client that answers access reviews with the supplied decision
+func newReviewClient(allowed bool) kubernetes.Interface {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status = authorizationv1.SubjectAccessReviewStatus{Allowed: allowed}
+		if allowed {
+			review.Status.Reason = "RBAC: allowed by RoleBinding"
+		}
+		return true, review, nil
+	})
+	return client
+}
+
+func TestProbePermissions(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	var requested []string
+	client.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		name := action.(k8stesting.CreateActionImpl).Name
+		requested = append(requested, action.GetNamespace()+"/"+name)
+		if name == "deleted-sa" {
+			return true, nil, errors.New(`serviceaccounts "deleted-sa" not found`)
+		}
+		return true, &authenticationv1.TokenRequest{Status: authenticationv1.TokenRequestStatus{Token: name + "-token"}}, nil
+	})
+
+	// the writer can do everything, the reader nothing
+	newTokenClient := func(token string) (kubernetes.Interface, error) {
+		return newReviewClient(token == "writer-sa-token"), nil
+	}
+
+	permissions := []Permission{
+		{ServiceAccountNamespace: "kuberhealthy", ServiceAccount: "writer-sa", Verb: "create", Resource: "deployments", Group: "apps", Namespace: "kuberhealthy", Allowed: true},
+		{ServiceAccountNamespace: "kuberhealthy", ServiceAccount: "writer-sa", Verb: "delete", Resource: "nodes"},
+		{ServiceAccountNamespace: "kuberhealthy", ServiceAccount: "reader-sa", Verb: "get", Resource: "pods", Namespace: "kuberhealthy", Allowed: true},
+		{ServiceAccountNamespace: "kuberhealthy", ServiceAccount: "reader-sa", Verb: "delete", Resource: "pods", Namespace: "kuberhealthy"},
+		{ServiceAccountNamespace: "kuberhealthy", ServiceAccount: "deleted-sa", Verb: "get", Resource: "pods", Namespace: "kuberhealthy", Allowed: true},
+	}
+	subChecks := probePermissions(context.Background(), client, newTokenClient, permissions)
+
+	expected := []status.SubCheck{
+		{Name: "kuberhealthy/writer-sa can create deployments.apps in kuberhealthy", OK: true},
+		{Name: "kuberhealthy/writer-sa cannot delete nodes", Errors: []string{"expected to be denied but was allowed: RBAC: allowed by RoleBinding"}},
+		{Name: "kuberhealthy/reader-sa can get pods in kuberhealthy", Errors: []string{"expected to be allowed but was denied"}},
+		{Name: "kuberhealthy/reader-sa cannot delete pods in kuberhealthy", OK: true},
+		{Name: "kuberhealthy/deleted-sa can get pods in kuberhealthy", Errors: []string{`failed to request a token for service account kuberhealthy/deleted-sa: serviceaccounts "deleted-sa" not found`}},
+	}
+	if !reflect.DeepEqual(subChecks, expected) {
+		t.Fatalf("probed permissions %+v but expected %+v", subChecks, expected)
+	}
+	if err := status.ValidateSubChecks(subChecks); err != nil {
+		t.Fatal("probed permissions are not valid sub-checks:", err)
+	}
+
+	// each service account gets a single token per run
+	if !reflect.DeepEqual(requested, []string{"kuberhealthy/writer-sa", "kuberhealthy/reader-sa", "kuberhealthy/deleted-sa"}) {
+		t.Fatalf("requested tokens for %v", requested)
+	}
+}
@@ -6,15 +6,18 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/nodeCheck"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/ssl_util"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/kubeClient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
 	log "github.com/sirupsen/logrus"
 	"k8s.io/client-go/kubernetes"
 )
@@ -130,7 +133,7 @@ func (shc *Checker) runHandshake(ctx context.Context, cancel context.CancelFunc,
 }
 
 func (shc *Checker) doChecks() error {
-	siteURL, err := url.Parse("https://" + domainName + ":" + portNum)
+	siteURL, err := url.Parse("https://" + net.JoinHostPort(domainName, portNum))
 	if err != nil {
 		return err
 	}
@@ -141,7 +144,20 @@ func (shc *Checker) doChecks() error {
 		return fmt.Errorf("error creating cert pool for ssl checks: %w", err)
 	}
 
-	return ssl_util.SSLHandshakeWithCertPool(siteURL, certPool)
+	budget, err := probe.BudgetFromEnv()
+	if err != nil {
+		return err
+	}
+
+	timing, err := ssl_util.TimedSSLHandshakeWithCertPool(siteURL, certPool)
+	if err != nil {
+		return err
+	}
+	log.Infoln("Completed SSL handshake with", siteURL.Host, "in", timing)
+	if overBudget := budget.Check(timing); len(overBudget) > 0 {
+		return fmt.Errorf("SSL handshake with %s was over the latency budget: %s", siteURL.Host, strings.Join(overBudget, ", "))
+	}
+	return nil
 }
 
 // reportKHSuccess reports success to Kuberhealthy servers and verifies the report successfully went through
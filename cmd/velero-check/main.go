@@ -0,0 +1,352 @@
+// Package velero-check implements a checker for Kuberhealthy that verifies velero backups complete and can be
+// restored, catching backups that fail silently
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	kh "github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/nodeCheck"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/kubeClient"
+)
+
+var (
+	// kubeConfigFile is used when the check runs outside of a cluster
+	kubeConfigFile = filepath.Join(os.Getenv("HOME"), ".kube", "config")
+
+	// veleroNamespace is the namespace that velero and its backups live in
+	veleroNamespace = os.Getenv("VELERO_NAMESPACE")
+
+	// schedule is the velero schedule whose most recent backup is verified
+	schedule = os.Getenv("SCHEDULE")
+
+	// triggerBackup makes the check create a small backup of its own instead of verifying the backups of a schedule
+	triggerBackup = os.Getenv("TRIGGER_BACKUP")
+
+	// backupNamespace is the namespace backed up when the check triggers a backup
+	backupNamespace = os.Getenv("BACKUP_NAMESPACE")
+
+	// backupResources are the comma separated resources that triggered backups and test restores include
+	backupResources = os.Getenv("BACKUP_RESOURCES")
+
+	// maxBackupAge fails the check when the most recent backup of the schedule completed longer ago than this
+	maxBackupAge = os.Getenv("MAX_BACKUP_AGE")
+
+	// minItems fails the check when the backup holds fewer items than this
+	minItems = os.Getenv("MIN_ITEMS")
+
+	// verifyRestore makes the check restore part of the backup into a scratch namespace to prove it can be restored
+	verifyRestore = os.Getenv("VERIFY_RESTORE")
+
+	// restoreNamespace is the namespace within the backup that the test restore restores
+	restoreNamespace = os.Getenv("RESTORE_NAMESPACE")
+
+	// restoreTarget is the scratch namespace that test restores restore into.  The check is only allowed to delete this
+	// namespace.
+	restoreTarget = os.Getenv("RESTORE_TARGET_NAMESPACE")
+)
+
+// defaultRestoreTarget is the scratch namespace that test restores restore into when RESTORE_TARGET_NAMESPACE is unset
+const defaultRestoreTarget = "kh-velero-restore"
+
+// Config holds the settings of the check
+type Config struct {
+	VeleroNamespace  string
+	Schedule         string
+	TriggerBackup    bool
+	BackupNamespace  string
+	Resources        []string
+	Requirements     BackupRequirements
+	VerifyRestore    bool
+	RestoreNamespace string
+	RestoreTarget    string
+}
+
+// pollInterval is how often backups and restores created by the check are checked on
+var pollInterval = 5 * time.Second
+
+// reportMargin is the time left before the deadline of the run to clean up and report the result
+const reportMargin = 15 * time.Second
+
+func init() {
+	// set debug mode for nodeCheck pkg
+	nodeCheck.EnableDebugOutput()
+}
+
+func main() {
+	deadline, err := kh.GetDeadline()
+	if err != nil {
+		log.Warningln("Failed to read the deadline of the run, allowing ten minutes:", err)
+		deadline = time.Now().Add(10 * time.Minute)
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline.Add(-reportMargin))
+	defer cancel()
+
+	// hits kuberhealthy endpoint to see if node is ready
+	err = nodeCheck.WaitForKuberhealthy(ctx)
+	if err != nil {
+		log.Errorln("Error waiting for kuberhealthy endpoint to be contactable by checker pod with error:" + err.Error())
+	}
+
+	cfg, err := parseConfig()
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+
+	restConfig, err := kubeClient.RestConfig(kubeConfigFile)
+	if err != nil {
+		ReportFailureAndExit(fmt.Errorf("failed to create kubernetes client configuration: %w", err))
+	}
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		ReportFailureAndExit(fmt.Errorf("failed to create kubernetes dynamic client: %w", err))
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		ReportFailureAndExit(fmt.Errorf("failed to create kubernetes client: %w", err))
+	}
+
+	problems, err := runCheck(ctx, cfg, dynamicClient, client)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	if len(problems) > 0 {
+		log.Errorln("Backups are not healthy:", problems)
+		err = kh.ReportFailure(problems)
+		if err != nil {
+			log.Errorln("Error reporting failure to Kuberhealthy servers:", err)
+			os.Exit(1)
+		}
+		log.Infoln("Successfully reported failure to Kuberhealthy servers")
+		return
+	}
+
+	err = kh.ReportSuccess()
+	if err != nil {
+		log.Errorln("Error reporting success to Kuberhealthy servers:", err)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported success to Kuberhealthy servers")
+}
+
+// runCheck finds or triggers the backup to verify, verifies it and optionally restores it.  The problems found with
+// the backup are returned, along with an error when the check could not get that far.
+func runCheck(ctx context.Context, cfg Config, dynamicClient dynamic.Interface, client kubernetes.Interface) ([]string, error) {
+	suffix := uuid.New().String()[:8]
+
+	var backup BackupInfo
+	if cfg.TriggerBackup {
+		name := "kh-velero-check-" + suffix
+		log.Infoln("Creating backup", name, "of", cfg.Resources, "in namespace", cfg.BackupNamespace)
+		spec := newBackup(cfg.VeleroNamespace, name, cfg.BackupNamespace, cfg.Resources, time.Hour)
+		_, err := dynamicClient.Resource(backupResource).Namespace(cfg.VeleroNamespace).Create(ctx, spec, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create backup %s: %w", name, err)
+		}
+		u, err := waitForPhase(ctx, dynamicClient, backupResource, cfg.VeleroNamespace, name, pollInterval)
+		if err != nil {
+			return nil, err
+		}
+		backup = backupInfo(u)
+	} else {
+		var err error
+		backup, err = latestBackup(ctx, dynamicClient, cfg.VeleroNamespace, cfg.Schedule)
+		if err != nil {
+			return nil, err
+		}
+	}
+	log.Infoln("Backup", backup)
+
+	problems := verifyBackup(backup, cfg.Requirements, time.Now())
+	if len(problems) > 0 || !cfg.VerifyRestore || backup.Phase != phaseCompleted {
+		return problems, nil
+	}
+	return verifyRestorable(ctx, cfg, dynamicClient, client, backup.Name, suffix)
+}
+
+// verifyRestorable restores the resources of one namespace in a backup into the scratch namespace, then removes the
+// scratch namespace and the restore again.  A scratch namespace left behind by an earlier run is removed first, since
+// restores do not replace objects that already exist.
+func verifyRestorable(ctx context.Context, cfg Config, dynamicClient dynamic.Interface, client kubernetes.Interface, backup string, suffix string) ([]string, error) {
+	name := "kh-velero-check-" + suffix
+	target := cfg.RestoreTarget
+
+	err := deleteNamespace(ctx, client, target, pollInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infoln("Restoring", cfg.Resources, "of namespace", cfg.RestoreNamespace, "in backup", backup, "into namespace", target)
+	spec := newRestore(cfg.VeleroNamespace, name, backup, cfg.RestoreNamespace, target, cfg.Resources)
+	_, err = dynamicClient.Resource(restoreResource).Namespace(cfg.VeleroNamespace).Create(ctx, spec, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create test restore %s: %w", name, err)
+	}
+	defer cleanUpRestore(dynamicClient, client, cfg.VeleroNamespace, name, target)
+
+	u, err := waitForPhase(ctx, dynamicClient, restoreResource, cfg.VeleroNamespace, name, pollInterval)
+	if err != nil {
+		return nil, err
+	}
+	problems := restoreProblems(u)
+	if len(problems) == 0 {
+		log.Infoln("Test restore", name, "completed")
+	}
+	return problems, nil
+}
+
+// deleteNamespace deletes a namespace and polls every interval until it is gone, or the context ends
+func deleteNamespace(ctx context.Context, client kubernetes.Interface, name string, interval time.Duration) error {
+	propagation := metav1.DeletePropagationBackground
+	err := client.CoreV1().Namespaces().Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete scratch namespace %s: %w", name, err)
+	}
+	log.Infoln("Waiting for scratch namespace", name, "of an earlier run to be deleted")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, err = client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil && ctx.Err() == nil {
+			return fmt.Errorf("failed to get scratch namespace %s: %w", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("scratch namespace %s of an earlier run was not deleted in time", name)
+		case <-ticker.C:
+		}
+	}
+}
+
+// cleanUpRestore deletes a test restore and the scratch namespace it restored into.  Errors are only logged since the
+// result of the check does not depend on them.  A scratch namespace left behind is removed by the next run.
+func cleanUpRestore(dynamicClient dynamic.Interface, client kubernetes.Interface, veleroNamespace string, name string, target string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := deleteIfExists(ctx, dynamicClient, restoreResource, veleroNamespace, name)
+	if err != nil {
+		log.Warningln("Failed to delete test restore", name+":", err)
+	}
+	propagation := metav1.DeletePropagationBackground
+	err = client.CoreV1().Namespaces().Delete(ctx, target, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Warningln("Failed to delete scratch namespace", target+":", err)
+	}
+}
+
+// parseConfig reads the settings of the check from the environment
+func parseConfig() (Config, error) {
+	cfg := Config{VeleroNamespace: veleroNamespace, Schedule: schedule, BackupNamespace: backupNamespace, RestoreNamespace: restoreNamespace, RestoreTarget: restoreTarget}
+	if len(cfg.VeleroNamespace) == 0 {
+		cfg.VeleroNamespace = "velero"
+	}
+	if len(cfg.RestoreTarget) == 0 {
+		cfg.RestoreTarget = defaultRestoreTarget
+	}
+	if len(cfg.BackupNamespace) == 0 {
+		cfg.BackupNamespace = os.Getenv(external.KHPodNamespace)
+	}
+
+	var err error
+	cfg.TriggerBackup, err = parseBool("TRIGGER_BACKUP", triggerBackup)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.VerifyRestore, err = parseBool("VERIFY_RESTORE", verifyRestore)
+	if err != nil {
+		return Config{}, err
+	}
+	if cfg.TriggerBackup == (len(cfg.Schedule) > 0) {
+		return Config{}, errors.New("set either SCHEDULE to verify the backups of a schedule or TRIGGER_BACKUP to create one")
+	}
+	if cfg.TriggerBackup && len(cfg.BackupNamespace) == 0 {
+		return Config{}, errors.New("BACKUP_NAMESPACE is required to trigger a backup")
+	}
+	if cfg.VerifyRestore && len(cfg.RestoreNamespace) == 0 {
+		if !cfg.TriggerBackup {
+			return Config{}, errors.New("RESTORE_NAMESPACE is required to verify the backups of a schedule can be restored")
+		}
+		cfg.RestoreNamespace = cfg.BackupNamespace
+	}
+	if cfg.VerifyRestore && cfg.RestoreTarget == cfg.RestoreNamespace {
+		return Config{}, errors.New("RESTORE_TARGET_NAMESPACE must differ from RESTORE_NAMESPACE since the scratch namespace is deleted")
+	}
+
+	cfg.Resources = []string{"configmaps"}
+	if len(backupResources) > 0 {
+		cfg.Resources = nil
+		for _, r := range strings.Split(backupResources, ",") {
+			if r = strings.TrimSpace(r); len(r) > 0 {
+				cfg.Resources = append(cfg.Resources, r)
+			}
+		}
+	}
+
+	// backups of a schedule are expected at least daily unless told otherwise
+	if !cfg.TriggerBackup {
+		cfg.Requirements.MaxAge = 25 * time.Hour
+	}
+	if len(maxBackupAge) > 0 {
+		cfg.Requirements.MaxAge, err = time.ParseDuration(maxBackupAge)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to parse MAX_BACKUP_AGE: %w", err)
+		}
+	}
+	cfg.Requirements.MinItems = 1
+	if len(minItems) > 0 {
+		cfg.Requirements.MinItems, err = strconv.ParseInt(minItems, 10, 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to parse MIN_ITEMS: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// parseBool parses a boolean environment variable, which is false when empty
+func parseBool(name string, value string) (bool, error) {
+	if len(value) == 0 {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return b, nil
+}
+
+// ReportFailureAndExit reports an error to Kuberhealthy and exits the program
+func ReportFailureAndExit(err error) {
+	log.Errorln(err)
+	err2 := kh.ReportFailure([]string{err.Error()})
+	if err2 != nil {
+		log.Errorln("Error reporting failure to Kuberhealthy servers:", err2)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported failure to Kuberhealthy servers")
+	os.Exit(0)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// the velero resources that the check reads and creates
+var (
+	backupResource  = schema.GroupVersionResource{Group: "velero.io", Version: "v1", Resource: "backups"}
+	restoreResource = schema.GroupVersionResource{Group: "velero.io", Version: "v1", Resource: "restores"}
+)
+
+// scheduleLabel is the label velero sets on the backups created by a schedule
+const scheduleLabel = "velero.io/schedule-name"
+
+// checkLabel is set on the backups and restores created by the check so that they can be told apart
+const checkLabel = "comcast.github.io/velero-check"
+
+// velero phases that backups and restores end in.  Anything else is still in progress.
+const (
+	phaseCompleted       = "Completed"
+	phasePartiallyFailed = "PartiallyFailed"
+	phaseFailed          = "Failed"
+	phaseFailedValidate  = "FailedValidation"
+)
+
+// BackupInfo is what the check reads from the status of a velero backup
+type BackupInfo struct {
+	Name                string
+	Phase               string
+	Started             time.Time
+	Completed           time.Time
+	Expiration          time.Time
+	ItemsBackedUp       int64
+	TotalItems          int64
+	Errors              int64
+	Warnings            int64
+	SnapshotsAttempted  int64
+	SnapshotsCompleted  int64
+	FailureReason       string
+	ValidationErrors    []string
+	StorageLocationName string
+}
+
+// String summarizes a backup for the logs
+func (b BackupInfo) String() string {
+	s := fmt.Sprintf("%s: phase=%s items=%d/%d errors=%d warnings=%d", b.Name, b.Phase, b.ItemsBackedUp, b.TotalItems, b.Errors, b.Warnings)
+	if b.SnapshotsAttempted > 0 {
+		s += fmt.Sprintf(" snapshots=%d/%d", b.SnapshotsCompleted, b.SnapshotsAttempted)
+	}
+	if !b.Completed.IsZero() {
+		s += " completed=" + b.Completed.UTC().Format(time.RFC3339)
+	}
+	if len(b.StorageLocationName) > 0 {
+		s += " location=" + b.StorageLocationName
+	}
+	return s
+}
+
+// backupInfo reads the status of a velero backup
+func backupInfo(u *unstructured.Unstructured) BackupInfo {
+	info := BackupInfo{Name: u.GetName()}
+	info.Phase, _, _ = unstructured.NestedString(u.Object, "status", "phase")
+	info.Started = startedAt(u)
+	info.Completed = nestedTime(u, "status", "completionTimestamp")
+	info.Expiration = nestedTime(u, "status", "expiration")
+	info.ItemsBackedUp, _, _ = unstructured.NestedInt64(u.Object, "status", "progress", "itemsBackedUp")
+	info.TotalItems, _, _ = unstructured.NestedInt64(u.Object, "status", "progress", "totalItems")
+	info.Errors, _, _ = unstructured.NestedInt64(u.Object, "status", "errors")
+	info.Warnings, _, _ = unstructured.NestedInt64(u.Object, "status", "warnings")
+	info.SnapshotsAttempted, _, _ = unstructured.NestedInt64(u.Object, "status", "volumeSnapshotsAttempted")
+	info.SnapshotsCompleted, _, _ = unstructured.NestedInt64(u.Object, "status", "volumeSnapshotsCompleted")
+	info.FailureReason, _, _ = unstructured.NestedString(u.Object, "status", "failureReason")
+	info.ValidationErrors, _, _ = unstructured.NestedStringSlice(u.Object, "status", "validationErrors")
+	info.StorageLocationName, _, _ = unstructured.NestedString(u.Object, "spec", "storageLocation")
+	return info
+}
+
+// nestedTime reads an RFC3339 timestamp from an object.  The zero time is returned when it is missing.
+func nestedTime(u *unstructured.Unstructured, fields ...string) time.Time {
+	s, _, _ := unstructured.NestedString(u.Object, fields...)
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// latestBackup finds the most recently started backup of a schedule.  Backups that have not started yet are
+// ordered by when they were created.
+func latestBackup(ctx context.Context, client dynamic.Interface, namespace string, schedule string) (BackupInfo, error) {
+	list, err := client.Resource(backupResource).Namespace(namespace).List(ctx, metav1.ListOptions{LabelSelector: scheduleLabel + "=" + schedule})
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("failed to list the backups of schedule %s: %w", schedule, err)
+	}
+	if len(list.Items) == 0 {
+		return BackupInfo{}, fmt.Errorf("schedule %s has no backups", schedule)
+	}
+
+	sort.Slice(list.Items, func(i, j int) bool {
+		return startedAt(&list.Items[i]).After(startedAt(&list.Items[j]))
+	})
+	return backupInfo(&list.Items[0]), nil
+}
+
+// startedAt is when a backup started, or when it was created if it has not started yet
+func startedAt(u *unstructured.Unstructured) time.Time {
+	started := nestedTime(u, "status", "startTimestamp")
+	if started.IsZero() {
+		return u.GetCreationTimestamp().Time
+	}
+	return started
+}
+
+// BackupRequirements are what a backup must meet to pass
+type BackupRequirements struct {
+	MaxAge   time.Duration // how long ago the backup may have completed.  Unlimited when zero.
+	MinItems int64         // the least items the backup must hold
+}
+
+// verifyBackup lists the ways that a backup falls short of the requirements.  Backups still in progress are judged by
+// the time they started, so that a backup stuck in progress fails once it is too old.
+func verifyBackup(b BackupInfo, req BackupRequirements, now time.Time) []string {
+	var problems []string
+	switch b.Phase {
+	case phaseCompleted:
+	case phasePartiallyFailed:
+		problems = append(problems, fmt.Sprintf("backup %s partially failed with %d errors", b.Name, b.Errors))
+	case phaseFailed:
+		problems = append(problems, fmt.Sprintf("backup %s failed: %s", b.Name, b.FailureReason))
+	case phaseFailedValidate:
+		problems = append(problems, fmt.Sprintf("backup %s failed validation: %s", b.Name, strings.Join(b.ValidationErrors, "; ")))
+	default:
+		if req.MaxAge > 0 && !b.Started.IsZero() && now.Sub(b.Started) > req.MaxAge {
+			problems = append(problems, fmt.Sprintf("backup %s has been %s since %s", b.Name, describePhase(b.Phase), b.Started.UTC().Format(time.RFC3339)))
+		}
+		return problems
+	}
+
+	if req.MaxAge > 0 && !b.Completed.IsZero() && now.Sub(b.Completed) > req.MaxAge {
+		problems = append(problems, fmt.Sprintf("latest backup %s completed %s ago, which is older than %s", b.Name, now.Sub(b.Completed).Round(time.Minute), req.MaxAge))
+	}
+	if b.Phase == phaseCompleted && b.ItemsBackedUp < req.MinItems {
+		problems = append(problems, fmt.Sprintf("backup %s holds %d items, which is less than %d", b.Name, b.ItemsBackedUp, req.MinItems))
+	}
+	if b.SnapshotsCompleted < b.SnapshotsAttempted {
+		problems = append(problems, fmt.Sprintf("backup %s completed %d of %d volume snapshots", b.Name, b.SnapshotsCompleted, b.SnapshotsAttempted))
+	}
+	return problems
+}
+
+// describePhase describes the phase of a backup that is still in progress
+func describePhase(phase string) string {
+	switch phase {
+	case "", "New":
+		return "waiting to be picked up by velero"
+	case "InProgress":
+		return "in progress"
+	}
+	return phase
+}
+
+// newBackup builds a small backup of the resources of a namespace.  It expires after the supplied TTL so that velero
+// removes it and its data again.
+func newBackup(namespace string, name string, includedNamespace string, resources []string, ttl time.Duration) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "velero.io/v1",
+		"kind":       "Backup",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": namespace,
+			"labels":    map[string]interface{}{checkLabel: "true"},
+		},
+		"spec": map[string]interface{}{
+			"includedNamespaces": []interface{}{includedNamespace},
+			"includedResources":  toInterfaces(resources),
+			"snapshotVolumes":    false,
+			"ttl":                ttl.String(),
+		},
+	}}
+}
+
+// newRestore builds a restore of the resources of one namespace in a backup into another namespace, so that the
+// restore does not touch the original
+func newRestore(namespace string, name string, backup string, sourceNamespace string, targetNamespace string, resources []string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "velero.io/v1",
+		"kind":       "Restore",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": namespace,
+			"labels":    map[string]interface{}{checkLabel: "true"},
+		},
+		"spec": map[string]interface{}{
+			"backupName":         backup,
+			"includedNamespaces": []interface{}{sourceNamespace},
+			"includedResources":  toInterfaces(resources),
+			"namespaceMapping":   map[string]interface{}{sourceNamespace: targetNamespace},
+			"restorePVs":         false,
+		},
+	}}
+}
+
+// toInterfaces converts strings for use in an unstructured object
+func toInterfaces(s []string) []interface{} {
+	out := make([]interface{}, 0, len(s))
+	for _, v := range s {
+		out = append(out, v)
+	}
+	return out
+}
+
+// waitForPhase polls a velero object every interval until it reaches a phase that it ends in, or the context ends
+func waitForPhase(ctx context.Context, client dynamic.Interface, resource schema.GroupVersionResource, namespace string, name string, interval time.Duration) (*unstructured.Unstructured, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		u, err := client.Resource(resource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil && ctx.Err() == nil {
+			return nil, fmt.Errorf("failed to get %s %s: %w", resource.Resource, name, err)
+		}
+		if err == nil {
+			phase, _, _ := unstructured.NestedString(u.Object, "status", "phase")
+			switch phase {
+			case phaseCompleted, phasePartiallyFailed, phaseFailed, phaseFailedValidate:
+				return u, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%s %s did not finish in time", strings.TrimSuffix(resource.Resource, "s"), name)
+		case <-ticker.C:
+		}
+	}
+}
+
+// deleteIfExists deletes an object and does not mind when it is already gone
+func deleteIfExists(ctx context.Context, client dynamic.Interface, resource schema.GroupVersionResource, namespace string, name string) error {
+	err := client.Resource(resource).Namespace(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// restoreProblems lists the ways that a finished restore failed
+func restoreProblems(u *unstructured.Unstructured) []string {
+	phase, _, _ := unstructured.NestedString(u.Object, "status", "phase")
+	if phase == phaseCompleted {
+		return nil
+	}
+	restoreErrors, _, _ := unstructured.NestedInt64(u.Object, "status", "errors")
+	reason, _, _ := unstructured.NestedString(u.Object, "status", "failureReason")
+	validation, _, _ := unstructured.NestedStringSlice(u.Object, "status", "validationErrors")
+
+	problem := fmt.Sprintf("test restore %s ended %s with %d errors", u.GetName(), phase, restoreErrors)
+	if len(reason) > 0 {
+		problem += ": " + reason
+	}
+	if len(validation) > 0 {
+		problem += ": " + strings.Join(validation, "; ")
+	}
+	return []string{problem}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newDynamicClient makes a fake dynamic client that knows the velero resources
+func newDynamicClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	listKinds := map[schema.GroupVersionResource]string{
+		backupResource:  "BackupList",
+		restoreResource: "RestoreList",
+	}
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objects...)
+}
+
+// scheduledBackup makes a backup of a schedule with the supplied status
+func scheduledBackup(name string, schedule string, status map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "velero.io/v1",
+		"kind":       "Backup",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "velero",
+			"labels":    map[string]interface{}{scheduleLabel: schedule},
+		},
+		"status": status,
+	}}
+}
+
+func TestVerifyBackup(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	req := BackupRequirements{MaxAge: 25 * time.Hour, MinItems: 1}
+
+	var testCases = []struct {
+		name     string
+		backup   BackupInfo
+		expected []string
+	}{
+		{
+			name:   "completed recently",
+			backup: BackupInfo{Name: "b", Phase: phaseCompleted, Started: now.Add(-time.Hour), Completed: now.Add(-50 * time.Minute), ItemsBackedUp: 20},
+		},
+		{
+			name:     "completed too long ago",
+			backup:   BackupInfo{Name: "b", Phase: phaseCompleted, Started: now.Add(-49 * time.Hour), Completed: now.Add(-48 * time.Hour), ItemsBackedUp: 20},
+			expected: []string{"latest backup b completed 48h0m0s ago, which is older than 25h0m0s"},
+		},
+		{
+			name:     "partially failed",
+			backup:   BackupInfo{Name: "b", Phase: phasePartiallyFailed, Completed: now, ItemsBackedUp: 20, Errors: 3},
+			expected: []string{"backup b partially failed with 3 errors"},
+		},
+		{
+			name:     "failed",
+			backup:   BackupInfo{Name: "b", Phase: phaseFailed, FailureReason: "object storage unavailable"},
+			expected: []string{"backup b failed: object storage unavailable"},
+		},
+		{
+			name:     "failed validation",
+			backup:   BackupInfo{Name: "b", Phase: phaseFailedValidate, ValidationErrors: []string{"bad ttl", "unknown location"}},
+			expected: []string{"backup b failed validation: bad ttl; unknown location"},
+		},
+		{
+			name:   "in progress",
+			backup: BackupInfo{Name: "b", Phase: "InProgress", Started: now.Add(-time.Hour)},
+		},
+		{
+			name:     "stuck in progress",
+			backup:   BackupInfo{Name: "b", Phase: "InProgress", Started: now.Add(-26 * time.Hour)},
+			expected: []string{"backup b has been in progress since 2023-05-31T10:00:00Z"},
+		},
+		{
+			name:     "never picked up",
+			backup:   BackupInfo{Name: "b", Phase: "New", Started: now.Add(-26 * time.Hour)},
+			expected: []string{"backup b has been waiting to be picked up by velero since 2023-05-31T10:00:00Z"},
+		},
+		{
+			name:     "empty",
+			backup:   BackupInfo{Name: "b", Phase: phaseCompleted, Completed: now},
+			expected: []string{"backup b holds 0 items, which is less than 1"},
+		},
+		{
+			name:     "missing snapshots",
+			backup:   BackupInfo{Name: "b", Phase: phaseCompleted, Completed: now, ItemsBackedUp: 20, SnapshotsAttempted: 3, SnapshotsCompleted: 2},
+			expected: []string{"backup b completed 2 of 3 volume snapshots"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			problems := verifyBackup(tc.backup, req, now)
+			if !reflect.DeepEqual(problems, tc.expected) {
+				t.Fatalf("expected problems %q, got %q", tc.expected, problems)
+			}
+		})
+	}
+}
+
+func TestLatestBackup(t *testing.T) {
+	client := newDynamicClient(
+		scheduledBackup("daily-1", "daily", map[string]interface{}{"phase": "Completed", "startTimestamp": "2023-05-30T01:00:00Z"}),
+		scheduledBackup("daily-3", "daily", map[string]interface{}{"phase": "InProgress", "startTimestamp": "2023-06-01T01:00:00Z", "progress": map[string]interface{}{"itemsBackedUp": int64(5), "totalItems": int64(9)}}),
+		scheduledBackup("daily-2", "daily", map[string]interface{}{"phase": "Completed", "startTimestamp": "2023-05-31T01:00:00Z"}),
+		scheduledBackup("hourly-1", "hourly", map[string]interface{}{"phase": "Completed", "startTimestamp": "2023-06-01T11:00:00Z"}),
+	)
+
+	backup, err := latestBackup(context.Background(), client, "velero", "daily")
+	if err != nil {
+		t.Fatalf("failed to find the latest backup: %s", err)
+	}
+	if backup.Name != "daily-3" || backup.Phase != "InProgress" || backup.ItemsBackedUp != 5 || backup.TotalItems != 9 {
+		t.Fatalf("expected the in progress daily-3 backup, got %s", backup)
+	}
+
+	_, err = latestBackup(context.Background(), client, "velero", "weekly")
+	if err == nil || err.Error() != "schedule weekly has no backups" {
+		t.Fatalf("expected a schedule without backups to be an error, got: %v", err)
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+	pollInterval = 10 * time.Millisecond
+
+	var testCases = []struct {
+		name         string
+		backupPhase  string
+		restorePhase string
+		expected     []string
+	}{
+		{name: "backup and restore complete", backupPhase: phaseCompleted, restorePhase: phaseCompleted},
+		{name: "backup fails", backupPhase: phaseFailed, expected: []string{"backup kh-velero-check-"}},
+		{name: "restore fails", backupPhase: phaseCompleted, restorePhase: phasePartiallyFailed, expected: []string{"test restore kh-velero-check-"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// velero is played by reactors that finish backups and restores as soon as they are created
+			dynamicClient := newDynamicClient()
+			finish := func(phase string) k8stesting.ReactionFunc {
+				return func(action k8stesting.Action) (bool, runtime.Object, error) {
+					u := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured)
+					status := map[string]interface{}{"phase": phase, "completionTimestamp": time.Now().UTC().Format(time.RFC3339)}
+					if u.GetKind() == "Backup" {
+						status["progress"] = map[string]interface{}{"itemsBackedUp": int64(4), "totalItems": int64(4)}
+					}
+					u.Object["status"] = status
+					return false, nil, nil
+				}
+			}
+			dynamicClient.PrependReactor("create", "backups", finish(tc.backupPhase))
+			dynamicClient.PrependReactor("create", "restores", finish(tc.restorePhase))
+			// a scratch namespace left behind by an earlier run is removed before the restore
+			client := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: defaultRestoreTarget}})
+
+			cfg := Config{
+				VeleroNamespace:  "velero",
+				TriggerBackup:    true,
+				BackupNamespace:  "kuberhealthy",
+				Resources:        []string{"configmaps"},
+				Requirements:     BackupRequirements{MinItems: 1},
+				VerifyRestore:    true,
+				RestoreNamespace: "kuberhealthy",
+				RestoreTarget:    defaultRestoreTarget,
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			problems, err := runCheck(ctx, cfg, dynamicClient, client)
+			if err != nil {
+				t.Fatalf("failed to run the check: %s", err)
+			}
+			if len(problems) != len(tc.expected) {
+				t.Fatalf("expected %d problems, got %q", len(tc.expected), problems)
+			}
+			for i := range problems {
+				if !strings.HasPrefix(problems[i], tc.expected[i]) {
+					t.Fatalf("expected a problem starting with %q, got %q", tc.expected[i], problems[i])
+				}
+			}
+
+			// the test restore is removed again while the triggered backup is left to expire
+			restores, err := dynamicClient.Resource(restoreResource).Namespace("velero").List(ctx, metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("failed to list restores: %s", err)
+			}
+			if len(restores.Items) != 0 {
+				t.Fatalf("expected the test restore to be deleted, found %d restores", len(restores.Items))
+			}
+			_, err = client.CoreV1().Namespaces().Get(ctx, defaultRestoreTarget, metav1.GetOptions{})
+			if tc.backupPhase == phaseCompleted && !apierrors.IsNotFound(err) {
+				t.Fatalf("expected the scratch namespace to be deleted, got: %v", err)
+			}
+			backups, err := dynamicClient.Resource(backupResource).Namespace("velero").List(ctx, metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("failed to list backups: %s", err)
+			}
+			if len(backups.Items) != 1 {
+				t.Fatalf("expected the triggered backup to remain, found %d backups", len(backups.Items))
+			}
+			ttl, _, _ := unstructured.NestedString(backups.Items[0].Object, "spec", "ttl")
+			if ttl != "1h0m0s" {
+				t.Fatalf("expected the triggered backup to expire after an hour, got ttl %q", ttl)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// Package websocket-check implements a checker for Kuberhealthy that verifies WebSocket endpoints accept
+// connections and exchange messages
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	kh "github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/nodeCheck"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
+)
+
+var (
+	// targets are the comma separated ws:// or wss:// URLs that are probed
+	targets = os.Getenv("TARGETS")
+
+	// settings of the handshake
+	origin      = os.Getenv("ORIGIN")
+	subprotocol = os.Getenv("SUBPROTOCOL")
+	headers     = os.Getenv("HEADERS")
+
+	// the messages exchanged once connected
+	sendMessage   = os.Getenv("SEND_MESSAGE")
+	expectMessage = os.Getenv("EXPECT_MESSAGE")
+
+	// timeout is the time limit of the handshake and of the message exchange with each target
+	timeout = os.Getenv("TIMEOUT")
+
+	// maxHandshakeLatency fails targets whose handshake takes longer than this
+	maxHandshakeLatency = os.Getenv("MAX_HANDSHAKE_LATENCY")
+
+	// insecureSkipVerify skips verifying the certificates of wss targets
+	insecureSkipVerify = os.Getenv("INSECURE_SKIP_VERIFY")
+)
+
+func init() {
+	// set debug mode for nodeCheck pkg
+	nodeCheck.EnableDebugOutput()
+
+	if len(timeout) == 0 {
+		timeout = "10s"
+	}
+}
+
+func main() {
+	// create context
+	checkTimeLimit := time.Minute * 1
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeLimit)
+	defer cancel()
+
+	// hits kuberhealthy endpoint to see if node is ready
+	err := nodeCheck.WaitForKuberhealthy(ctx)
+	if err != nil {
+		log.Errorln("Error waiting for kuberhealthy endpoint to be contactable by checker pod with error:" + err.Error())
+	}
+
+	p, targetList, err := parseConfig()
+	if err != nil {
+		ReportFailureAndExit(err)
+	}
+
+	log.Infoln("Probing", len(targetList), "WebSocket targets:", targetList)
+	errs := probeTargets(targetList, p, func(target string, timing probe.Timing) {
+		log.Infoln("Connected to", target, "in", timing)
+	})
+	if len(errs) > 0 {
+		log.Errorln("Targets failed:", errs)
+		err = kh.ReportFailure(errs)
+		if err != nil {
+			log.Errorln("Error reporting failure to Kuberhealthy servers:", err)
+			os.Exit(1)
+		}
+		log.Infoln("Successfully reported failure to Kuberhealthy servers")
+		return
+	}
+
+	log.Infoln("Every target passed")
+	err = kh.ReportSuccess()
+	if err != nil {
+		log.Errorln("Error reporting success to Kuberhealthy servers:", err)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported success to Kuberhealthy servers")
+}
+
+// parseConfig reads the probe and its targets from the environment
+func parseConfig() (Probe, []string, error) {
+	targetList, err := parseTargets(targets)
+	if err != nil {
+		return Probe{}, nil, fmt.Errorf("invalid TARGETS: %w", err)
+	}
+
+	p := Probe{Origin: origin, Subprotocol: subprotocol, SendMessage: sendMessage, ExpectMessage: expectMessage}
+	p.Headers, err = probe.ParseHeaders(headers)
+	if err != nil {
+		return Probe{}, nil, fmt.Errorf("invalid HEADERS: %w", err)
+	}
+	p.Timeout, err = time.ParseDuration(timeout)
+	if err != nil {
+		return Probe{}, nil, fmt.Errorf("failed to parse TIMEOUT: %w", err)
+	}
+	if len(maxHandshakeLatency) > 0 {
+		p.MaxHandshakeLatency, err = time.ParseDuration(maxHandshakeLatency)
+		if err != nil {
+			return Probe{}, nil, fmt.Errorf("failed to parse MAX_HANDSHAKE_LATENCY: %w", err)
+		}
+	}
+	if len(insecureSkipVerify) > 0 {
+		p.InsecureSkipVerify, err = strconv.ParseBool(insecureSkipVerify)
+		if err != nil {
+			return Probe{}, nil, fmt.Errorf("failed to parse INSECURE_SKIP_VERIFY: %w", err)
+		}
+	}
+	p.Budget, err = probe.BudgetFromEnv()
+	if err != nil {
+		return Probe{}, nil, err
+	}
+	return p, targetList, nil
+}
+
+// ReportFailureAndExit reports an error to Kuberhealthy and exits the program
+func ReportFailureAndExit(err error) {
+	log.Errorln(err)
+	err2 := kh.ReportFailure([]string{err.Error()})
+	if err2 != nil {
+		log.Errorln("Error reporting failure to Kuberhealthy servers:", err2)
+		os.Exit(1)
+	}
+	log.Infoln("Successfully reported failure to Kuberhealthy servers")
+	os.Exit(0)
+}
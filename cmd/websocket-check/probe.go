@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
+)
+
+// maxMessageSize is the largest message read while waiting for the expected message
+const maxMessageSize = 1 << 20
+
+// Probe configures how each WebSocket endpoint is probed
+type Probe struct {
+	Origin              string        // the Origin header of the handshake, derived from the target when empty
+	Subprotocol         string        // the subprotocol requested in the handshake, if any
+	Headers             http.Header   // extra headers sent with the handshake, such as Authorization
+	SendMessage         string        // a text message sent once the connection is up, if any
+	ExpectMessage       string        // a string that a received message must contain, if any
+	Timeout             time.Duration // the time limit of the handshake and of the message exchange
+	MaxHandshakeLatency time.Duration // fails targets whose handshake takes longer than this, when set
+	InsecureSkipVerify  bool          // skips verifying the certificates of wss targets
+	Budget              probe.Budget  // the latency budget of each phase of the connection
+}
+
+// parseTargets splits a comma separated list of ws:// and wss:// URLs
+func parseTargets(s string) ([]string, error) {
+	var targets []string
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if len(t) == 0 {
+			continue
+		}
+		u, err := url.Parse(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target %s: %w", t, err)
+		}
+		if u.Scheme != "ws" && u.Scheme != "wss" {
+			return nil, fmt.Errorf("target %s is not a ws:// or wss:// URL", t)
+		}
+		targets = append(targets, t)
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("no targets configured")
+	}
+	return targets, nil
+}
+
+// originOf derives the Origin of a handshake from its target, such as https://chat.example.com for
+// wss://chat.example.com/socket
+func originOf(target string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	scheme := "http"
+	if u.Scheme == "wss" {
+		scheme = "https"
+	}
+	return scheme + "://" + u.Host, nil
+}
+
+// probeTarget connects to a WebSocket endpoint, exchanges the configured messages and returns how long each phase
+// of the connection took.  The time to first byte is the WebSocket handshake, and the total is everything up to the
+// end of the handshake.
+func probeTarget(target string, p Probe) (probe.Timing, error) {
+	origin := p.Origin
+	if len(origin) == 0 {
+		var err error
+		origin, err = originOf(target)
+		if err != nil {
+			return probe.Timing{}, err
+		}
+	}
+	config, err := websocket.NewConfig(target, origin)
+	if err != nil {
+		return probe.Timing{}, fmt.Errorf("invalid target: %w", err)
+	}
+	if len(p.Subprotocol) > 0 {
+		config.Protocol = []string{p.Subprotocol}
+	}
+	for name, values := range p.Headers {
+		for _, v := range values {
+			config.Header.Add(name, v)
+		}
+	}
+
+	var tlsConfig *tls.Config
+	if config.Location.Scheme == "wss" {
+		tlsConfig = &tls.Config{InsecureSkipVerify: p.InsecureSkipVerify, MinVersion: tls.VersionTLS12}
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+	rawConn, timing, err := probe.Dial(ctx, "tcp", hostPort(config.Location), tlsConfig)
+	if err != nil {
+		return timing, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer rawConn.Close()
+	err = rawConn.SetDeadline(start.Add(p.Timeout))
+	if err != nil {
+		return timing, err
+	}
+
+	handshakeStart := time.Now()
+	conn, err := websocket.NewClient(config, rawConn)
+	timing.TTFB = time.Since(handshakeStart)
+	timing.Total = time.Since(start)
+	if err != nil {
+		return timing, fmt.Errorf("handshake failed: %w", err)
+	}
+	defer conn.Close()
+
+	if p.MaxHandshakeLatency > 0 && timing.Total > p.MaxHandshakeLatency {
+		return timing, fmt.Errorf("handshake took %s, which is longer than the limit of %s", timing.Total.Round(time.Millisecond), p.MaxHandshakeLatency)
+	}
+	if overBudget := p.Budget.Check(timing); len(overBudget) > 0 {
+		return timing, fmt.Errorf("handshake was over the latency budget: %s", strings.Join(overBudget, ", "))
+	}
+
+	err = conn.SetDeadline(time.Now().Add(p.Timeout))
+	if err != nil {
+		return timing, err
+	}
+	if len(p.SendMessage) > 0 {
+		err = websocket.Message.Send(conn, p.SendMessage)
+		if err != nil {
+			return timing, fmt.Errorf("failed to send message: %w", err)
+		}
+	}
+	if len(p.ExpectMessage) > 0 {
+		err = awaitMessage(conn, p.ExpectMessage)
+		if err != nil {
+			return timing, err
+		}
+	}
+	return timing, nil
+}
+
+// hostPort returns the address a WebSocket URL is dialed at, filling in the default port of its scheme
+func hostPort(u *url.URL) string {
+	if len(u.Port()) > 0 {
+		return u.Host
+	}
+	if u.Scheme == "wss" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+// awaitMessage reads messages until one contains the expected string or the deadline of the connection passes.
+// Endpoints often send greetings or heartbeats first, so other messages are skipped.
+func awaitMessage(conn *websocket.Conn, expected string) error {
+	conn.MaxPayloadBytes = maxMessageSize
+	for {
+		var msg []byte
+		err := websocket.Message.Receive(conn, &msg)
+		if err != nil {
+			return fmt.Errorf("did not receive a message containing %q: %w", expected, err)
+		}
+		if strings.Contains(string(msg), expected) {
+			return nil
+		}
+	}
+}
+
+// probeTargets probes every target at once and returns the errors of the ones that failed, in the order of the
+// targets.  The timing of each target that connected is passed to the supplied function.
+func probeTargets(targets []string, p Probe, measured func(target string, timing probe.Timing)) []string {
+	errs := make([]error, len(targets))
+	timings := make([]probe.Timing, len(targets))
+	wg := sync.WaitGroup{}
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			timings[i], errs[i] = probeTarget(target, p)
+		}(i, target)
+	}
+	wg.Wait()
+
+	var failures []string
+	for i, err := range errs {
+		if timings[i].Connect > 0 {
+			measured(targets[i], timings[i])
+		}
+		if err != nil {
+			failures = append(failures, targets[i]+": "+err.Error())
+		}
+	}
+	return failures
+}
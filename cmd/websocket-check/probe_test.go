@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
+)
+
+// newEchoServer serves a WebSocket endpoint that greets each client and then echoes its messages.  Clients without
+// the expected token are refused.
+func newEchoServer() *httptest.Server {
+	echo := websocket.Handler(func(conn *websocket.Conn) {
+		_ = websocket.Message.Send(conn, "welcome")
+		for {
+			var msg string
+			err := websocket.Message.Receive(conn, &msg)
+			if err != nil {
+				return
+			}
+			_ = websocket.Message.Send(conn, "echo: "+msg)
+		}
+	})
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		echo.ServeHTTP(w, r)
+	}))
+}
+
+// TestProbeTargets ensures targets pass only when the handshake and the expected message exchange succeed
+func TestProbeTargets(t *testing.T) {
+	server := newEchoServer()
+	defer server.Close()
+	target := "ws" + strings.TrimPrefix(server.URL, "http") + "/socket"
+	auth := http.Header{"Authorization": []string{"Bearer token"}}
+
+	var testCases = []struct {
+		description string
+		probe       Probe
+		expected    string // the start of the expected error, if any
+	}{
+		{
+			description: "handshake only",
+			probe:       Probe{Headers: auth},
+		},
+		{
+			description: "echoed message",
+			probe:       Probe{Headers: auth, SendMessage: "ping", ExpectMessage: "echo: ping"},
+		},
+		{
+			description: "refused handshake",
+			expected:    target + ": handshake failed",
+		},
+		{
+			description: "message never arrives",
+			probe:       Probe{Headers: auth, ExpectMessage: "pong"},
+			expected:    target + `: did not receive a message containing "pong"`,
+		},
+		{
+			description: "handshake over the latency budget",
+			probe:       Probe{Headers: auth, Budget: probe.Budget{TTFB: time.Nanosecond}},
+			expected:    target + ": handshake was over the latency budget: ttfb took",
+		},
+		{
+			description: "slow handshake",
+			probe:       Probe{Headers: auth, MaxHandshakeLatency: time.Nanosecond},
+			expected:    target + ": handshake took",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc.probe.Timeout = time.Millisecond * 500
+		measured := 0
+		errs := probeTargets([]string{target}, tc.probe, func(string, probe.Timing) { measured++ })
+		if len(tc.expected) == 0 && len(errs) > 0 {
+			t.Fatalf("%s: probe failed: %v", tc.description, errs)
+		}
+		if len(tc.expected) > 0 && (len(errs) != 1 || !strings.HasPrefix(errs[0], tc.expected)) {
+			t.Fatalf("%s: got errors %v but expected one starting with %q", tc.description, errs, tc.expected)
+		}
+		if measured != 1 {
+			t.Fatalf("%s: handshake latency was measured %d times", tc.description, measured)
+		}
+	}
+}
+
+// TestParseConfig ensures targets must be WebSocket URLs and that the origin of a handshake is derived from its target
+func TestParseConfig(t *testing.T) {
+	if _, err := parseTargets("wss://chat.example.com/socket, ws://10.0.0.1:8080"); err != nil {
+		t.Fatalf("valid targets were refused: %s", err)
+	}
+	for _, s := range []string{"", "https://chat.example.com"} {
+		if _, err := parseTargets(s); err == nil {
+			t.Fatalf("targets %q were accepted", s)
+		}
+	}
+
+	origin, err := originOf("wss://chat.example.com/socket")
+	if err != nil || origin != "https://chat.example.com" {
+		t.Fatalf("origin was %q with error %v", origin, err)
+	}
+}
@@ -0,0 +1,13 @@
+// Package deploy holds the Kubernetes manifests used to install Kuberhealthy.  The custom resource definitions are
+// embedded so that the kuberhealthy binary can install them without Helm.
+package deploy // import "github.com/kuberhealthy/kuberhealthy/v2/deploy"
+
+import "embed"
+
+// CRDs holds the khcheck, khjob and khstate custom resource definitions from the Helm chart
+//
+//go:embed helm/kuberhealthy/crds/*.yaml
+var CRDs embed.FS
+
+// CRDDir is the directory of the custom resource definitions within CRDs
+const CRDDir = "helm/kuberhealthy/crds"
@@ -20,6 +20,7 @@
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -27,6 +28,11 @@ import (
 func (in *CheckConfig) DeepCopyInto(out *CheckConfig) {
 	*out = *in
 	in.PodSpec.DeepCopyInto(&out.PodSpec)
+	if in.PodTemplate != nil {
+		in, out := &in.PodTemplate, &out.PodTemplate
+		*out = new(corev1.PodTemplateSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.ExtraAnnotations != nil {
 		in, out := &in.ExtraAnnotations, &out.ExtraAnnotations
 		*out = make(map[string]string, len(*in))
@@ -41,6 +47,30 @@ func (in *CheckConfig) DeepCopyInto(out *CheckConfig) {
 			(*out)[key] = val
 		}
 	}
+	if in.ServiceAccountTokens != nil {
+		in, out := &in.ServiceAccountTokens, &out.ServiceAccountTokens
+		*out = make([]ServiceAccountToken, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.IsolatedNamespace != nil {
+		in, out := &in.IsolatedNamespace, &out.IsolatedNamespace
+		*out = new(IsolatedNamespace)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Aggregation != nil {
+		in, out := &in.Aggregation, &out.Aggregation
+		*out = new(Aggregation)
+		**out = **in
+	}
 	return
 }
 
@@ -54,6 +84,50 @@ func (in *CheckConfig) DeepCopy() *CheckConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IsolatedNamespace) DeepCopyInto(out *IsolatedNamespace) {
+	*out = *in
+	if in.Quota != nil {
+		in, out := &in.Quota, &out.Quota
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IsolatedNamespace.
+func (in *IsolatedNamespace) DeepCopy() *IsolatedNamespace {
+	if in == nil {
+		return nil
+	}
+	out := new(IsolatedNamespace)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceAccountToken) DeepCopyInto(out *ServiceAccountToken) {
+	*out = *in
+	if in.ExpirationSeconds != nil {
+		in, out := &in.ExpirationSeconds, &out.ExpirationSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceAccountToken.
+func (in *ServiceAccountToken) DeepCopy() *ServiceAccountToken {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceAccountToken)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KuberhealthyCheck) DeepCopyInto(out *KuberhealthyCheck) {
 	*out = *in
@@ -125,3 +199,40 @@ func NewKuberhealthyCheck(name string, namespace string, spec CheckConfig) Kuber
 	check.ObjectMeta.Namespace = namespace
 	return check
 }
+
+// EffectivePodSpec returns the pod spec checker pods are created from.  This is the spec of the pod template when one
+// is set and the pod spec otherwise.
+func (c *CheckConfig) EffectivePodSpec() *corev1.PodSpec {
+	if c.PodTemplate != nil {
+		return &c.PodTemplate.Spec
+	}
+	return &c.PodSpec
+}
+
+// EffectivePodLabels returns the labels of the pod template with the extra labels applied over them
+func (c *CheckConfig) EffectivePodLabels() map[string]string {
+	if c.PodTemplate == nil {
+		return c.ExtraLabels
+	}
+	return mergeStringMaps(c.PodTemplate.Labels, c.ExtraLabels)
+}
+
+// EffectivePodAnnotations returns the annotations of the pod template with the extra annotations applied over them
+func (c *CheckConfig) EffectivePodAnnotations() map[string]string {
+	if c.PodTemplate == nil {
+		return c.ExtraAnnotations
+	}
+	return mergeStringMaps(c.PodTemplate.Annotations, c.ExtraAnnotations)
+}
+
+// mergeStringMaps returns a new map with the entries of overrides applied over those of base
+func mergeStringMaps(base map[string]string, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(overrides))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
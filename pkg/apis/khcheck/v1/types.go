@@ -30,13 +30,115 @@ type KuberhealthyCheck struct {
 // endpoint.
 // +k8s:openapi-gen=true
 type CheckConfig struct {
-	RunInterval string        `json:"runInterval" yaml:"runInterval"` // the interval at which the check runs
-	Timeout     string        `json:"timeout" yaml:"timeout"`         // the maximum time the pod is allowed to run before a failure is assumed
-	PodSpec     apiv1.PodSpec `json:"podSpec" yaml:"podSpec"`         // a spec for the external checker
+	// +kubebuilder:validation:Pattern=`^([0-9]+|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)?$`
+	RunInterval string `json:"runInterval" yaml:"runInterval"` // the interval at which the check runs
+	// +kubebuilder:validation:Pattern=`^([0-9]+|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)?$`
+	Timeout string        `json:"timeout" yaml:"timeout"` // the maximum time the pod is allowed to run before a failure is assumed
+	PodSpec apiv1.PodSpec `json:"podSpec" yaml:"podSpec"` // a spec for the external checker, unused by resident checks
+	// +optional
+	PodTemplate *apiv1.PodTemplateSpec `json:"podTemplate,omitempty" yaml:"podTemplate,omitempty"` // a template for the checker pod with its labels and annotations, used instead of podSpec when set
 	// +optional
 	ExtraAnnotations map[string]string `json:"extraAnnotations" yaml:"extraAnnotations"` // a map of extra annotations that will be applied to the pod
 	// +optional
 	ExtraLabels map[string]string `json:"extraLabels" yaml:"extraLabels"` // a map of extra labels that will be applied to the pod
+	// +optional
+	// +kubebuilder:validation:Enum=service;externalHostname;podIP
+	ReportingURLMode string `json:"reportingURLMode,omitempty" yaml:"reportingURLMode,omitempty"` // overrides how the reporting URL given to checker pods is built
+	// +optional
+	// +kubebuilder:validation:Enum=exclude;quit;tolerate
+	SidecarHandling string `json:"sidecarHandling,omitempty" yaml:"sidecarHandling,omitempty"` // how service mesh sidecars in checker pods are handled
+	// +optional
+	// +kubebuilder:validation:Enum=linux;windows
+	OS string `json:"os,omitempty" yaml:"os,omitempty"` // the operating system of the nodes checker pods are scheduled on
+	// +optional
+	Arch string `json:"arch,omitempty" yaml:"arch,omitempty"` // the architecture of the nodes checker pods are scheduled on, such as amd64 or arm64
+	// +optional
+	// +kubebuilder:validation:Enum=restricted;none
+	SecurityContextPolicy string `json:"securityContextPolicy,omitempty" yaml:"securityContextPolicy,omitempty"` // the security context defaults applied to checker pods
+	// +optional
+	// +kubebuilder:validation:Enum=none;preferred;required
+	KuberhealthyAntiAffinity string `json:"kuberhealthyAntiAffinity,omitempty" yaml:"kuberhealthyAntiAffinity,omitempty"` // keeps checker pods off of the node the Kuberhealthy master runs on
+	// +optional
+	ServiceAccountTokens []ServiceAccountToken `json:"serviceAccountTokens,omitempty" yaml:"serviceAccountTokens,omitempty"` // bound service account tokens projected into checker pods
+	// +optional
+	IsolatedNamespace *IsolatedNamespace `json:"isolatedNamespace,omitempty" yaml:"isolatedNamespace,omitempty"` // creates an ephemeral namespace for the test resources of each run
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([0-9]+|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)?$`
+	MaxDeadlineExtension string `json:"maxDeadlineExtension,omitempty" yaml:"maxDeadlineExtension,omitempty"` // the most a run's deadline can be extended by at the request of its checker pod
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([0-9]+|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)?$`
+	StartTimeout string `json:"startTimeout,omitempty" yaml:"startTimeout,omitempty"` // the maximum time the checker pod is allowed to take to start, including pulling its image
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([0-9]+|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)?$`
+	HeartbeatTimeout string `json:"heartbeatTimeout,omitempty" yaml:"heartbeatTimeout,omitempty"` // how long a run may go without a heartbeat from its checker pod before it times out
+	// +optional
+	// +kubebuilder:validation:Enum=Allow;Forbid;Replace
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty" yaml:"concurrencyPolicy,omitempty"` // how a run that is still going when the next run is due is handled
+	// +optional
+	Resident bool `json:"resident,omitempty" yaml:"resident,omitempty"` // runs are handed to long lived checkers that register with Kuberhealthy instead of spawning a pod for each run
+	// +optional
+	RunOnAllNodes bool `json:"runOnAllNodes,omitempty" yaml:"runOnAllNodes,omitempty"` // each run spawns one checker pod on every matching node and aggregates their results
+	// +optional
+	RunPerZone bool `json:"runPerZone,omitempty" yaml:"runPerZone,omitempty"` // each run spawns one checker pod in every topology zone and aggregates their results
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty" yaml:"nodeSelector,omitempty"` // the labels of the nodes runOnAllNodes and runPerZone spawn checker pods on, all nodes when empty
+	// +optional
+	Aggregation *Aggregation `json:"aggregation,omitempty" yaml:"aggregation,omitempty"` // how the results of the nodes or zones of runOnAllNodes and runPerZone decide the state of the check
+}
+
+// AggregationPolicy describes how the results of the nodes or zones a run fanned out to are combined into the state
+// of the check
+type AggregationPolicy string
+
+const (
+	// AggregateAll passes a run only when every node or zone passed.  This is the default.
+	AggregateAll AggregationPolicy = "all"
+	// AggregateQuorum passes a run when more than half of its nodes or zones passed
+	AggregateQuorum AggregationPolicy = "quorum"
+	// AggregatePercentage passes a run when at least the configured percentage of its nodes or zones passed
+	AggregatePercentage AggregationPolicy = "percentage"
+)
+
+// Aggregation configures how the results of the nodes or zones of a fanned out run decide the state of the check
+type Aggregation struct {
+	// +kubebuilder:validation:Enum=all;quorum;percentage
+	Policy AggregationPolicy `json:"policy" yaml:"policy"` // how the results of the nodes or zones are combined
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	Percentage int `json:"percentage,omitempty" yaml:"percentage,omitempty"` // the percentage of nodes or zones that must pass under the percentage policy
+}
+
+// ConcurrencyPolicy describes how a run that is still going when the next run of a check is due is handled.  The
+// policies mirror those of CronJobs.
+type ConcurrencyPolicy string
+
+const (
+	// AllowConcurrent starts the next run alongside the one still going
+	AllowConcurrent ConcurrencyPolicy = "Allow"
+	// ForbidConcurrent skips the next run while one is still going.  This is the default.
+	ForbidConcurrent ConcurrencyPolicy = "Forbid"
+	// ReplaceConcurrent cancels the run still going and starts the next run in its place
+	ReplaceConcurrent ConcurrencyPolicy = "Replace"
+)
+
+// IsolatedNamespace configures the ephemeral namespace Kuberhealthy creates for each run.  The namespace is handed to
+// checker pods in the KH_RUN_NAMESPACE environment variable and is deleted along with everything in it when the run ends.
+type IsolatedNamespace struct {
+	Enabled bool `json:"enabled" yaml:"enabled"` // creates an ephemeral namespace for each run
+	// +optional
+	Quota apiv1.ResourceList `json:"quota,omitempty" yaml:"quota,omitempty"` // the hard limits of the resource quota of the namespace
+	// +optional
+	ClusterRole string `json:"clusterRole,omitempty" yaml:"clusterRole,omitempty"` // the cluster role granted to the service account of checker pods within the namespace
+}
+
+// ServiceAccountToken describes a bound service account token projected into checker pods for calling services that
+// trust tokens issued by the cluster
+type ServiceAccountToken struct {
+	Audience string `json:"audience" yaml:"audience"` // the audience the token is issued for
+	// +optional
+	// +kubebuilder:validation:Minimum=600
+	ExpirationSeconds *int64 `json:"expirationSeconds,omitempty" yaml:"expirationSeconds,omitempty"` // the requested lifetime of the token
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -48,3 +150,7 @@ type KuberhealthyCheckList struct {
 
 	Items []KuberhealthyCheck `json:"items" yaml:"items"`
 }
+
+// TargetsAnnotation lists the workloads a khcheck probes as comma separated Kind/name or namespace/Kind/name.  When
+// result export is enabled the result of each run is annotated onto these workloads.
+const TargetsAnnotation = "kuberhealthy.io/targets"
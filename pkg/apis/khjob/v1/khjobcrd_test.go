@@ -0,0 +1,70 @@
+package v1
+
+import (
+	"reflect"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestEffectivePod ensures job pods are created from the pod template when one is set, and that the extra labels
+// and annotations of the job take precedence over those of the template
+func TestEffectivePod(t *testing.T) {
+	podSpec := apiv1.PodSpec{Containers: []apiv1.Container{{Name: "pod-spec"}}}
+	template := &apiv1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      map[string]string{"app": "template", "team": "sre"},
+			Annotations: map[string]string{"sidecar.istio.io/inject": "true", "owner": "template"},
+		},
+		Spec: apiv1.PodSpec{Containers: []apiv1.Container{{Name: "template"}}},
+	}
+
+	var testCases = []struct {
+		description         string
+		config              JobConfig
+		expectedContainer   string
+		expectedLabels      map[string]string
+		expectedAnnotations map[string]string
+	}{
+		{
+			description:         "pod spec only",
+			config:              JobConfig{PodSpec: podSpec, ExtraLabels: map[string]string{"app": "extra"}, ExtraAnnotations: map[string]string{"owner": "extra"}},
+			expectedContainer:   "pod-spec",
+			expectedLabels:      map[string]string{"app": "extra"},
+			expectedAnnotations: map[string]string{"owner": "extra"},
+		},
+		{
+			description:         "pod template without extras",
+			config:              JobConfig{PodSpec: podSpec, PodTemplate: template},
+			expectedContainer:   "template",
+			expectedLabels:      map[string]string{"app": "template", "team": "sre"},
+			expectedAnnotations: map[string]string{"sidecar.istio.io/inject": "true", "owner": "template"},
+		},
+		{
+			description:         "extras override the pod template",
+			config:              JobConfig{PodTemplate: template, ExtraLabels: map[string]string{"app": "extra", "tier": "checks"}, ExtraAnnotations: map[string]string{"owner": "extra"}},
+			expectedContainer:   "template",
+			expectedLabels:      map[string]string{"app": "extra", "team": "sre", "tier": "checks"},
+			expectedAnnotations: map[string]string{"sidecar.istio.io/inject": "true", "owner": "extra"},
+		},
+	}
+
+	for _, tc := range testCases {
+		spec := tc.config.EffectivePodSpec()
+		if len(spec.Containers) != 1 || spec.Containers[0].Name != tc.expectedContainer {
+			t.Fatalf("%s: expected the pod spec with container %s but got %+v", tc.description, tc.expectedContainer, spec.Containers)
+		}
+		if labels := tc.config.EffectivePodLabels(); !reflect.DeepEqual(labels, tc.expectedLabels) {
+			t.Fatalf("%s: expected labels %v but got %v", tc.description, tc.expectedLabels, labels)
+		}
+		if annotations := tc.config.EffectivePodAnnotations(); !reflect.DeepEqual(annotations, tc.expectedAnnotations) {
+			t.Fatalf("%s: expected annotations %v but got %v", tc.description, tc.expectedAnnotations, annotations)
+		}
+	}
+
+	// merging must not write the extras into the template the job was decoded with
+	if template.Labels["app"] != "template" || template.Annotations["owner"] != "template" {
+		t.Fatalf("expected the pod template to be left alone but got labels %v and annotations %v", template.Labels, template.Annotations)
+	}
+}
@@ -31,13 +31,62 @@ type KuberhealthyJob struct {
 // +k8s:openapi-gen=true
 type JobConfig struct {
 	// +optional
-	Phase   JobPhase      `json:"phase" yaml:"phase"`     // the state or phase of the job
+	Phase JobPhase `json:"phase" yaml:"phase"` // the state or phase of the job
+	// +kubebuilder:validation:Pattern=`^([0-9]+|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)?$`
 	Timeout string        `json:"timeout" yaml:"timeout"` // the maximum time the pod is allowed to run before a failure is assumed
 	PodSpec apiv1.PodSpec `json:"podSpec" yaml:"podSpec"` // a spec for the external job
 	// +optional
+	PodTemplate *apiv1.PodTemplateSpec `json:"podTemplate,omitempty" yaml:"podTemplate,omitempty"` // a template for the job pod with its labels and annotations, used instead of podSpec when set
+	// +optional
 	ExtraAnnotations map[string]string `json:"extraAnnotations" yaml:"extraAnnotations"` // a map of extra annotations that will be applied to the pod
 	// +optional
 	ExtraLabels map[string]string `json:"extraLabels" yaml:"extraLabels"` // a map of extra labels that will be applied to the pod
+	// +optional
+	// +kubebuilder:validation:Enum=service;externalHostname;podIP
+	ReportingURLMode string `json:"reportingURLMode,omitempty" yaml:"reportingURLMode,omitempty"` // overrides how the reporting URL given to job pods is built
+	// +optional
+	// +kubebuilder:validation:Enum=exclude;quit;tolerate
+	SidecarHandling string `json:"sidecarHandling,omitempty" yaml:"sidecarHandling,omitempty"` // how service mesh sidecars in job pods are handled
+	// +optional
+	// +kubebuilder:validation:Enum=linux;windows
+	OS string `json:"os,omitempty" yaml:"os,omitempty"` // the operating system of the nodes job pods are scheduled on
+	// +optional
+	Arch string `json:"arch,omitempty" yaml:"arch,omitempty"` // the architecture of the nodes job pods are scheduled on, such as amd64 or arm64
+	// +optional
+	// +kubebuilder:validation:Enum=restricted;none
+	SecurityContextPolicy string `json:"securityContextPolicy,omitempty" yaml:"securityContextPolicy,omitempty"` // the security context defaults applied to job pods
+	// +optional
+	ServiceAccountTokens []ServiceAccountToken `json:"serviceAccountTokens,omitempty" yaml:"serviceAccountTokens,omitempty"` // bound service account tokens projected into job pods
+	// +optional
+	IsolatedNamespace *IsolatedNamespace `json:"isolatedNamespace,omitempty" yaml:"isolatedNamespace,omitempty"` // creates an ephemeral namespace for the test resources of each run
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([0-9]+|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)?$`
+	MaxDeadlineExtension string `json:"maxDeadlineExtension,omitempty" yaml:"maxDeadlineExtension,omitempty"` // the most a run's deadline can be extended by at the request of its job pod
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([0-9]+|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)?$`
+	StartTimeout string `json:"startTimeout,omitempty" yaml:"startTimeout,omitempty"` // the maximum time the job pod is allowed to take to start, including pulling its image
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([0-9]+|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)?$`
+	HeartbeatTimeout string `json:"heartbeatTimeout,omitempty" yaml:"heartbeatTimeout,omitempty"` // how long a run may go without a heartbeat from its job pod before it times out
+}
+
+// IsolatedNamespace configures the ephemeral namespace Kuberhealthy creates for each run.  The namespace is handed to
+// job pods in the KH_RUN_NAMESPACE environment variable and is deleted along with everything in it when the run ends.
+type IsolatedNamespace struct {
+	Enabled bool `json:"enabled" yaml:"enabled"` // creates an ephemeral namespace for each run
+	// +optional
+	Quota apiv1.ResourceList `json:"quota,omitempty" yaml:"quota,omitempty"` // the hard limits of the resource quota of the namespace
+	// +optional
+	ClusterRole string `json:"clusterRole,omitempty" yaml:"clusterRole,omitempty"` // the cluster role granted to the service account of job pods within the namespace
+}
+
+// ServiceAccountToken describes a bound service account token projected into job pods for calling services that
+// trust tokens issued by the cluster
+type ServiceAccountToken struct {
+	Audience string `json:"audience" yaml:"audience"` // the audience the token is issued for
+	// +optional
+	// +kubebuilder:validation:Minimum=600
+	ExpirationSeconds *int64 `json:"expirationSeconds,omitempty" yaml:"expirationSeconds,omitempty"` // the requested lifetime of the token
 }
 
 // JobPhase is a label for the condition of the job at the current time.
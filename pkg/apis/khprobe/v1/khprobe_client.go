@@ -0,0 +1,126 @@
+/*
+ Copyright 2020 The Knative Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+type KHProbeV1Interface interface {
+	RESTClient() rest.Interface
+	KuberhealthyProbesGetter
+}
+
+// KHProbeV1Client is used to interact with features provided by the khprobe group.
+type KHProbeV1Client struct {
+	restClient rest.Interface
+}
+
+func (c *KHProbeV1Client) KuberhealthyProbes(namespace string) KuberhealthyProbeInterface {
+	return newKuberhealthyProbes(c, namespace)
+}
+
+func Client(kubeConfigFile string) (*KHProbeV1Client, error) {
+
+	// make a new crd probe client
+	c, err := rest.InClusterConfig()
+	if err != nil {
+		c, err = clientcmd.BuildConfigFromFlags("", kubeConfigFile)
+	}
+
+	client, err := NewForConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	return client, err
+}
+
+// NewForConfig creates a new KHProbeV1Client for the given config.
+func NewForConfig(c *rest.Config) (*KHProbeV1Client, error) {
+	config := *c
+	if err := setConfigDefaults(&config); err != nil {
+		return nil, err
+	}
+	httpClient, err := rest.HTTPClientFor(&config)
+	if err != nil {
+		return nil, err
+	}
+	return NewForConfigAndClient(&config, httpClient)
+}
+
+// NewForConfigAndClient creates a new KHProbeV1Client for the given config and http client.
+// Note the http client provided takes precedence over the configured transport values.
+func NewForConfigAndClient(c *rest.Config, h *http.Client) (*KHProbeV1Client, error) {
+	config := *c
+	if err := setConfigDefaults(&config); err != nil {
+		return nil, err
+	}
+	client, err := rest.RESTClientForConfigAndClient(&config, h)
+	if err != nil {
+		return nil, err
+	}
+	return &KHProbeV1Client{client}, nil
+}
+
+// NewForConfigOrDie creates a new KHProbeV1Client for the given config and
+// panics if there is an error in the config.
+func NewForConfigOrDie(c *rest.Config) *KHProbeV1Client {
+	client, err := NewForConfig(c)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+// New creates a new KHProbeV1Client for the given RESTClient.
+func New(c rest.Interface) *KHProbeV1Client {
+	return &KHProbeV1Client{c}
+}
+
+func setConfigDefaults(config *rest.Config) error {
+
+	err := ConfigureScheme("comcast.github.io", "v1")
+	if err != nil {
+		return err
+	}
+
+	gv := SchemeGroupVersion
+	config.GroupVersion = &gv
+	config.APIPath = "/apis"
+	config.NegotiatedSerializer = serializer.WithoutConversionCodecFactory{CodecFactory: scheme.Codecs}
+
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+
+	return nil
+}
+
+// RESTClient returns a RESTClient that is used to communicate
+// with API server by this client implementation.
+func (c *KHProbeV1Client) RESTClient() rest.Interface {
+	if c == nil {
+		return nil
+	}
+	return c.restClient
+}
@@ -0,0 +1,172 @@
+/*
+ Copyright 2020 The Knative Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// KuberhealthyProbesGetter has a method to return a KuberhealthyProbeInterface.
+// A group's client should implement this interface.
+type KuberhealthyProbesGetter interface {
+	KuberhealthyProbes(namespace string) KuberhealthyProbeInterface
+}
+
+// KuberhealthyProbeInterface has methods to work with KuberhealthyProbe resources.
+type KuberhealthyProbeInterface interface {
+	Create(*KuberhealthyProbe) (KuberhealthyProbe, error)
+	Update(*KuberhealthyProbe) (KuberhealthyProbe, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	DeleteCollection(options *metav1.DeleteOptions, listOptions metav1.ListOptions) error
+	Get(name string, options metav1.GetOptions) (KuberhealthyProbe, error)
+	List(opts metav1.ListOptions) (KuberhealthyProbeList, error)
+	Watch(opts metav1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result KuberhealthyProbe, err error)
+}
+
+// kuberhealthyProbes implements KuberhealthyProbeInterface
+type kuberhealthyProbes struct {
+	client rest.Interface
+	ns     string
+}
+
+// newKuberhealthyProbes returns a KuberhealthyProbes
+func newKuberhealthyProbes(c *KHProbeV1Client, namespace string) *kuberhealthyProbes {
+	return &kuberhealthyProbes{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the kuberhealthyProbe, and returns the corresponding kuberhealthyProbe object, and an error if there is any.
+func (c *kuberhealthyProbes) Get(name string, options metav1.GetOptions) (result KuberhealthyProbe, err error) {
+	result = KuberhealthyProbe{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("khprobes").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(context.TODO()).
+		Into(&result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of KuberhealthyProbes that match those selectors.
+func (c *kuberhealthyProbes) List(opts metav1.ListOptions) (result KuberhealthyProbeList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = KuberhealthyProbeList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("khprobes").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(context.TODO()).
+		Into(&result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested kuberhealthyProbes.
+func (c *kuberhealthyProbes) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("khprobes").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(context.TODO())
+}
+
+// Create takes the representation of a kuberhealthyProbe and creates it.  Returns the server's representation of the kuberhealthyProbe, and an error, if there is any.
+func (c *kuberhealthyProbes) Create(kuberhealthyProbe *KuberhealthyProbe) (result KuberhealthyProbe, err error) {
+	result = KuberhealthyProbe{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("khprobes").
+		Body(kuberhealthyProbe).
+		Do(context.TODO()).
+		Into(&result)
+	return
+}
+
+// Update takes the representation of a kuberhealthyProbe and updates it. Returns the server's representation of the kuberhealthyProbe, and an error, if there is any.
+func (c *kuberhealthyProbes) Update(kuberhealthyProbe *KuberhealthyProbe) (result KuberhealthyProbe, err error) {
+	result = KuberhealthyProbe{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("khprobes").
+		Name(kuberhealthyProbe.Name).
+		Body(kuberhealthyProbe).
+		Do(context.TODO()).
+		Into(&result)
+	return
+}
+
+// Delete takes name of the kuberhealthyProbe and deletes it. Returns an error if one occurs.
+func (c *kuberhealthyProbes) Delete(name string, options *metav1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("khprobes").
+		Name(name).
+		Body(options).
+		Do(context.TODO()).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *kuberhealthyProbes) DeleteCollection(options *metav1.DeleteOptions, listOptions metav1.ListOptions) error {
+	var timeout time.Duration
+	if listOptions.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOptions.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("khprobes").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(options).
+		Do(context.TODO()).
+		Error()
+}
+
+// Patch applies the patch and returns the patched kuberhealthyProbe.
+func (c *kuberhealthyProbes) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result KuberhealthyProbe, err error) {
+	result = KuberhealthyProbe{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("khprobes").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do(context.TODO()).
+		Into(&result)
+	return
+}
@@ -0,0 +1,85 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KuberhealthyProbe represents the data in the CRD for probing the services
+// or pods that match a label selector.  Kuberhealthy expands it into a
+// khcheck for each matching target.
+// +k8s:openapi-gen=true
+// +kubebuilder:resource:path="khprobes"
+// +kubebuilder:resource:singular="khprobe"
+// +kubebuilder:resource:shortName="khp"
+type KuberhealthyProbe struct {
+	metav1.TypeMeta `json:",inline" yaml:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+
+	// Spec holds the desired state of the KuberhealthyProbe (from the client).
+	// +optional
+	Spec ProbeConfig `json:"spec,omitempty" yaml:"spec,omitempty"`
+
+	// Status holds the targets currently probed by Kuberhealthy.
+	// +optional
+	Status ProbeStatus `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+// ProbeConfig represents a configuration for a kuberhealthy probe. This
+// includes the targets selected and how each of them is probed.
+// +k8s:openapi-gen=true
+type ProbeConfig struct {
+	Selector metav1.LabelSelector `json:"selector" yaml:"selector"` // selects the targets in the namespace of the probe
+	// +optional
+	// +kubebuilder:validation:Enum=Service;Pod
+	TargetKind TargetKind `json:"targetKind,omitempty" yaml:"targetKind,omitempty"` // the kind of the targets. Defaults to Service.
+	// +optional
+	Port intstr.IntOrString `json:"port,omitempty" yaml:"port,omitempty"` // the name or number of the port probed. Defaults to the first port of the target.
+	// +optional
+	// +kubebuilder:validation:Enum=http;tcp
+	Type string `json:"type,omitempty" yaml:"type,omitempty"` // http or tcp. Defaults to http.
+	// +optional
+	Path string `json:"path,omitempty" yaml:"path,omitempty"` // the path requested by http probes. Defaults to /.
+	// +optional
+	// +kubebuilder:validation:Enum=http;https
+	Scheme string `json:"scheme,omitempty" yaml:"scheme,omitempty"` // the scheme of http probes
+	// +optional
+	ExpectedStatus int `json:"expectedStatus,omitempty" yaml:"expectedStatus,omitempty"` // the status code http probes expect. Defaults to 200.
+	// +optional
+	RunInterval string `json:"runInterval,omitempty" yaml:"runInterval,omitempty"` // the run interval of the khcheck of each target
+	// +optional
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"` // the timeout of the khcheck of each target
+}
+
+// TargetKind is the kind of object a probe targets
+type TargetKind string
+
+// These are the kinds of objects a probe can target.
+const (
+	TargetService TargetKind = "Service" // targets are probed through the cluster DNS name of the service
+	TargetPod     TargetKind = "Pod"     // targets are probed through the IP of each running pod
+)
+
+// ProbeStatus holds the targets Kuberhealthy currently probes
+type ProbeStatus struct {
+	// +optional
+	TargetCount int `json:"targetCount" yaml:"targetCount"` // the number of targets probed
+	// +optional
+	Targets []string `json:"targets,omitempty" yaml:"targets,omitempty"` // the names of the targets probed
+	// +optional
+	Errors []string `json:"errors,omitempty" yaml:"errors,omitempty"` // why matching targets could not be probed
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KuberhealthyProbeList is a list of KuberhealthyProbe resources
+type KuberhealthyProbeList struct {
+	metav1.TypeMeta `json:",inline" yaml:",inline"`
+	metav1.ListMeta `json:"metadata" yaml:"metadata"`
+
+	Items []KuberhealthyProbe `json:"items" yaml:"items"`
+}
@@ -0,0 +1,172 @@
+/*
+ Copyright 2020 The Knative Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// KuberhealthyRemediationsGetter has a method to return a KuberhealthyRemediationInterface.
+// A group's client should implement this interface.
+type KuberhealthyRemediationsGetter interface {
+	KuberhealthyRemediations(namespace string) KuberhealthyRemediationInterface
+}
+
+// KuberhealthyRemediationInterface has methods to work with KuberhealthyRemediation resources.
+type KuberhealthyRemediationInterface interface {
+	Create(*KuberhealthyRemediation) (KuberhealthyRemediation, error)
+	Update(*KuberhealthyRemediation) (KuberhealthyRemediation, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	DeleteCollection(options *metav1.DeleteOptions, listOptions metav1.ListOptions) error
+	Get(name string, options metav1.GetOptions) (KuberhealthyRemediation, error)
+	List(opts metav1.ListOptions) (KuberhealthyRemediationList, error)
+	Watch(opts metav1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result KuberhealthyRemediation, err error)
+}
+
+// kuberhealthyRemediations implements KuberhealthyRemediationInterface
+type kuberhealthyRemediations struct {
+	client rest.Interface
+	ns     string
+}
+
+// newKuberhealthyRemediations returns a KuberhealthyRemediations
+func newKuberhealthyRemediations(c *KHRemediationV1Client, namespace string) *kuberhealthyRemediations {
+	return &kuberhealthyRemediations{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the kuberhealthyRemediation, and returns the corresponding kuberhealthyRemediation object, and an error if there is any.
+func (c *kuberhealthyRemediations) Get(name string, options metav1.GetOptions) (result KuberhealthyRemediation, err error) {
+	result = KuberhealthyRemediation{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("khremediations").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(context.TODO()).
+		Into(&result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of KuberhealthyRemediations that match those selectors.
+func (c *kuberhealthyRemediations) List(opts metav1.ListOptions) (result KuberhealthyRemediationList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = KuberhealthyRemediationList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("khremediations").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(context.TODO()).
+		Into(&result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested kuberhealthyRemediations.
+func (c *kuberhealthyRemediations) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("khremediations").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(context.TODO())
+}
+
+// Create takes the representation of a kuberhealthyRemediation and creates it.  Returns the server's representation of the kuberhealthyRemediation, and an error, if there is any.
+func (c *kuberhealthyRemediations) Create(kuberhealthyRemediation *KuberhealthyRemediation) (result KuberhealthyRemediation, err error) {
+	result = KuberhealthyRemediation{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("khremediations").
+		Body(kuberhealthyRemediation).
+		Do(context.TODO()).
+		Into(&result)
+	return
+}
+
+// Update takes the representation of a kuberhealthyRemediation and updates it. Returns the server's representation of the kuberhealthyRemediation, and an error, if there is any.
+func (c *kuberhealthyRemediations) Update(kuberhealthyRemediation *KuberhealthyRemediation) (result KuberhealthyRemediation, err error) {
+	result = KuberhealthyRemediation{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("khremediations").
+		Name(kuberhealthyRemediation.Name).
+		Body(kuberhealthyRemediation).
+		Do(context.TODO()).
+		Into(&result)
+	return
+}
+
+// Delete takes name of the kuberhealthyRemediation and deletes it. Returns an error if one occurs.
+func (c *kuberhealthyRemediations) Delete(name string, options *metav1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("khremediations").
+		Name(name).
+		Body(options).
+		Do(context.TODO()).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *kuberhealthyRemediations) DeleteCollection(options *metav1.DeleteOptions, listOptions metav1.ListOptions) error {
+	var timeout time.Duration
+	if listOptions.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOptions.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("khremediations").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(options).
+		Do(context.TODO()).
+		Error()
+}
+
+// Patch applies the patch and returns the patched kuberhealthyRemediation.
+func (c *kuberhealthyRemediations) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result KuberhealthyRemediation, err error) {
+	result = KuberhealthyRemediation{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("khremediations").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do(context.TODO()).
+		Into(&result)
+	return
+}
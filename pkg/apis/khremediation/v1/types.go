@@ -0,0 +1,88 @@
+package v1
+
+import (
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KuberhealthyRemediation represents the data in the CRD for configuring a
+// remediation job that Kuberhealthy runs when a check fails
+// +k8s:openapi-gen=true
+// +kubebuilder:resource:path="khremediations"
+// +kubebuilder:resource:singular="khremediation"
+// +kubebuilder:resource:shortName="khr"
+type KuberhealthyRemediation struct {
+	metav1.TypeMeta `json:",inline" yaml:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+
+	// Spec holds the desired state of the KuberhealthyRemediation (from the client).
+	// +optional
+	Spec RemediationConfig `json:"spec,omitempty" yaml:"spec,omitempty"`
+
+	// Status holds the remediation attempts made by Kuberhealthy.
+	// +optional
+	Status RemediationStatus `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+// RemediationConfig represents a configuration for a kuberhealthy
+// remediation. This includes the khcheck that triggers it and the job
+// that is run to remediate the failure.
+// +k8s:openapi-gen=true
+type RemediationConfig struct {
+	CheckName string `json:"checkName" yaml:"checkName"` // the name of the khcheck in the same namespace that triggers this remediation
+	// +optional
+	Cooldown string `json:"cooldown,omitempty" yaml:"cooldown,omitempty"` // the minimum time between remediation attempts
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxAttempts int `json:"maxAttempts,omitempty" yaml:"maxAttempts,omitempty"` // the number of attempts made before giving up until the check passes again
+	// +optional
+	Suspend bool `json:"suspend,omitempty" yaml:"suspend,omitempty"` // stops new remediation attempts while leaving the remediation installed
+	// +kubebuilder:pruning:PreserveUnknownFields
+	JobTemplate batchv1.JobTemplateSpec `json:"jobTemplate" yaml:"jobTemplate"` // the job created for each remediation attempt
+}
+
+// RemediationStatus holds the remediation attempts made since the check last
+// passed along with an audit record of recent attempts
+type RemediationStatus struct {
+	// +optional
+	Attempts int `json:"attempts" yaml:"attempts"` // the number of attempts made since the check last passed
+	// +optional
+	LastAttemptTime *metav1.Time `json:"lastAttemptTime,omitempty" yaml:"lastAttemptTime,omitempty"` // when the last attempt was made
+	// +optional
+	History []RemediationRecord `json:"history,omitempty" yaml:"history,omitempty"` // audit records of recent attempts, newest last
+}
+
+// RemediationRecord is an audit record of a single remediation event
+type RemediationRecord struct {
+	Time     metav1.Time       `json:"time" yaml:"time"`                             // when the event happened
+	Action   RemediationAction `json:"action" yaml:"action"`                         // what Kuberhealthy did
+	CheckRun string            `json:"checkRun,omitempty" yaml:"checkRun,omitempty"` // the UUID of the check run that triggered the event
+	JobName  string            `json:"jobName,omitempty" yaml:"jobName,omitempty"`   // the name of the remediation job created
+	Errors   []string          `json:"errors,omitempty" yaml:"errors,omitempty"`     // the errors of the failed check run
+	Message  string            `json:"message,omitempty" yaml:"message,omitempty"`   // a description of the event
+}
+
+// RemediationAction describes what Kuberhealthy did in response to a check result
+type RemediationAction string
+
+// These are the actions recorded in the remediation history.
+const (
+	RemediationTriggered RemediationAction = "Triggered" // a remediation job was created
+	RemediationFailed    RemediationAction = "Failed"    // creating the remediation job failed
+	RemediationExhausted RemediationAction = "Exhausted" // max attempts were reached and no further jobs are created
+	RemediationReset     RemediationAction = "Reset"     // the check passed and the attempt count was reset
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KuberhealthyRemediationList is a list of KuberhealthyRemediation resources
+type KuberhealthyRemediationList struct {
+	metav1.TypeMeta `json:",inline" yaml:",inline"`
+	metav1.ListMeta `json:"metadata" yaml:"metadata"`
+
+	Items []KuberhealthyRemediation `json:"items" yaml:"items"`
+}
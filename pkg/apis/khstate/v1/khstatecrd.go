@@ -23,6 +23,7 @@ package v1
 import (
 	"log"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -95,9 +96,221 @@ func (in *WorkloadDetails) DeepCopyInto(out *WorkloadDetails) {
 		copy(*out, *in)
 	}
 	in.LastRun.DeepCopyInto(out.LastRun)
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]RunHistoryEntry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.BackoffUntil != nil {
+		in, out := &in.BackoffUntil, &out.BackoffUntil
+		*out = (*in).DeepCopy()
+	}
+	if in.NodeStatuses != nil {
+		in, out := &in.NodeStatuses, &out.NodeStatuses
+		*out = make([]NodeStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ZoneStatuses != nil {
+		in, out := &in.ZoneStatuses, &out.ZoneStatuses
+		*out = make([]ZoneStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SubChecks != nil {
+		in, out := &in.SubChecks, &out.SubChecks
+		*out = make([]SubCheckStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Warnings != nil {
+		in, out := &in.Warnings, &out.Warnings
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = make(map[string]float64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ErrorDetails != nil {
+		in, out := &in.ErrorDetails, &out.ErrorDetails
+		*out = make([]ErrorDetail, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Artifacts != nil {
+		in, out := &in.Artifacts, &out.Artifacts
+		*out = make([]Artifact, len(*in))
+		copy(*out, *in)
+	}
+	if in.Correlations != nil {
+		in, out := &in.Correlations, &out.Correlations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Progress != nil {
+		in, out := &in.Progress, &out.Progress
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ErrorDetail) DeepCopyInto(out *ErrorDetail) {
+	*out = *in
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ErrorDetail.
+func (in *ErrorDetail) DeepCopy() *ErrorDetail {
+	if in == nil {
+		return nil
+	}
+	out := new(ErrorDetail)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunProgress) DeepCopyInto(out *RunProgress) {
+	*out = *in
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunProgress.
+func (in *RunProgress) DeepCopy() *RunProgress {
+	if in == nil {
+		return nil
+	}
+	out := new(RunProgress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZoneStatus) DeepCopyInto(out *ZoneStatus) {
+	*out = *in
+	if in.Errors != nil {
+		in, out := &in.Errors, &out.Errors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZoneStatus.
+func (in *ZoneStatus) DeepCopy() *ZoneStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ZoneStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubCheckStatus) DeepCopyInto(out *SubCheckStatus) {
+	*out = *in
+	if in.Errors != nil {
+		in, out := &in.Errors, &out.Errors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubCheckStatus.
+func (in *SubCheckStatus) DeepCopy() *SubCheckStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SubCheckStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeStatus) DeepCopyInto(out *NodeStatus) {
+	*out = *in
+	if in.Errors != nil {
+		in, out := &in.Errors, &out.Errors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeStatus.
+func (in *NodeStatus) DeepCopy() *NodeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunHistoryEntry) DeepCopyInto(out *RunHistoryEntry) {
+	*out = *in
+	if in.Errors != nil {
+		in, out := &in.Errors, &out.Errors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunHistoryEntry.
+func (in *RunHistoryEntry) DeepCopy() *RunHistoryEntry {
+	if in == nil {
+		return nil
+	}
+	out := new(RunHistoryEntry)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadDetails.
 func (in *WorkloadDetails) DeepCopy() *WorkloadDetails {
 	if in == nil {
@@ -128,6 +341,37 @@ func NewWorkloadDetails(workloadType KHWorkload) WorkloadDetails {
 	}
 }
 
+// AppendHistory adds a run to the history of the khWorkload.  When the history grows beyond max entries, the
+// oldest entries are dropped.  A max of zero or less keeps the whole history.
+func (wd *WorkloadDetails) AppendHistory(entry RunHistoryEntry, max int) {
+	wd.History = append(wd.History, entry)
+	if max > 0 && len(wd.History) > max {
+		wd.History = wd.History[len(wd.History)-max:]
+	}
+}
+
+// NewRunHistoryEntry creates a history entry for a run.  If the run reported after its deadline has passed,
+// the result is recorded as a late success or late failure.
+func NewRunHistoryEntry(uuid string, ok bool, errors []string, late bool) RunHistoryEntry {
+	result := RunSuccess
+	if !ok {
+		result = RunFailure
+	}
+	if late {
+		result = RunLateSuccess
+		if !ok {
+			result = RunLateFailure
+		}
+	}
+	now := metav1.Now()
+	return RunHistoryEntry{
+		UUID:   uuid,
+		Result: result,
+		Errors: errors,
+		Time:   &now,
+	}
+}
+
 // GetKHWorkload returns the workload for the WorkloadDetails struct
 func (wd *WorkloadDetails) GetKHWorkload() KHWorkload {
 	// failsafe if the workload is empty
@@ -39,10 +39,165 @@ type WorkloadDetails struct {
 	LastRun          *metav1.Time `json:"LastRun,omitempty" yaml:"LastRun,omitempty"` // the time the khWorkload was last run
 	AuthoritativePod string       `json:"AuthoritativePod" yaml:"AuthoritativePod"`   // the main kuberhealthy pod creating and updating the khstate
 	CurrentUUID      string       `json:"uuid" yaml:"uuid"`                           // the UUID that is authorized to report statuses into the kuberhealthy endpoint
+	// +optional
+	// +kubebuilder:validation:Enum=TimedOut;StartTimedOut;PodSchedulingFailed;ReportedErrors;ProvisioningError;AdmissionRejected;Cancelled
+	Reason FailureReason `json:"reason,omitempty" yaml:"reason,omitempty"` // why the last run failed, if it did
+	// +optional
+	ReportRequestID string `json:"reportRequestID,omitempty" yaml:"reportRequestID,omitempty"` // the request ID of the report that set the current state
+	// +optional
+	History []RunHistoryEntry `json:"History,omitempty" yaml:"History,omitempty"` // the most recent runs of the khWorkload, oldest first
+	// +optional
+	ProvisioningFailures int `json:"provisioningFailures,omitempty" yaml:"provisioningFailures,omitempty"` // the number of runs in a row whose checker pod failed to start
+	// +nullable
+	BackoffUntil *metav1.Time `json:"backoffUntil,omitempty" yaml:"backoffUntil,omitempty"` // when the next run happens while runs are backed off after provisioning errors
+	// +optional
+	NodeStatuses []NodeStatus `json:"nodeStatuses,omitempty" yaml:"nodeStatuses,omitempty"` // the result on each node of checks that run on all nodes, sorted by node name
+	// +optional
+	ZoneStatuses []ZoneStatus `json:"zoneStatuses,omitempty" yaml:"zoneStatuses,omitempty"` // the result in each zone of checks that run per zone, sorted by zone name
+	// +optional
+	SubChecks []SubCheckStatus `json:"subChecks,omitempty" yaml:"subChecks,omitempty"` // the results of the named parts of the last run, when the khWorkload reported them
+	// +optional
+	Metadata map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"` // details about the last run reported by the khWorkload, such as counts of what it checked
+	// +optional
+	Warnings []string `json:"warnings,omitempty" yaml:"warnings,omitempty"` // signs of degradation reported by the last run that did not fail it, such as a certificate that expires soon
+	// +optional
+	Metrics map[string]float64 `json:"metrics,omitempty" yaml:"metrics,omitempty"` // measurements taken by the last run reported by the khWorkload, such as latencies
+	// +optional
+	ErrorDetails []ErrorDetail `json:"errorDetails,omitempty" yaml:"errorDetails,omitempty"` // structured details of the errors of the last run, when the khWorkload reported them
+	// +optional
+	Artifacts []Artifact `json:"artifacts,omitempty" yaml:"artifacts,omitempty"` // log excerpts and diagnostic output attached to the failure of the last run, if any
+	// +optional
+	Correlations map[string]string `json:"correlations,omitempty" yaml:"correlations,omitempty"` // the correlation IDs configured in Kuberhealthy, such as a deploy SHA, when the state was stored
+	// +optional
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"` // the labels of the khWorkload that Kuberhealthy is configured to propagate, such as team or tier
+	// +optional
+	// +kubebuilder:validation:Enum=ok;warning;failed;unknown
+	State ResultState `json:"state,omitempty" yaml:"state,omitempty"` // ok, warning, failed or unknown when the khWorkload has not recorded a result for too long.  Set on the status page, not stored.
+	// +nullable
+	Progress *RunProgress `json:"progress,omitempty" yaml:"progress,omitempty"` // the latest progress reported by the run that is still going, if any
 	// +nullable
 	khWorkload *KHWorkload `json:"khWorkload,omitempty" yaml:"khWorkload,omitempty"`
 }
 
+// RunHistoryEntry records the outcome of a single khWorkload run
+// +k8s:openapi-gen=true
+type RunHistoryEntry struct {
+	UUID   string    `json:"uuid" yaml:"uuid"`                         // the run UUID that produced this result
+	Result RunResult `json:"result" yaml:"result"`                     // the outcome of the run
+	Errors []string  `json:"errors,omitempty" yaml:"errors,omitempty"` // the errors reported by the run, if any
+	// +optional
+	RequestID string `json:"requestID,omitempty" yaml:"requestID,omitempty"` // the request ID of the report, if any
+	// +optional
+	// +kubebuilder:validation:Enum=TimedOut;StartTimedOut;PodSchedulingFailed;ReportedErrors;ProvisioningError;AdmissionRejected;Cancelled
+	Reason FailureReason `json:"reason,omitempty" yaml:"reason,omitempty"` // why the run failed, if it did
+	// +nullable
+	Time *metav1.Time `json:"time,omitempty" yaml:"time,omitempty"` // the time the result was recorded
+}
+
+// NodeStatus records the result of a check that runs on all nodes on one of the nodes
+// +k8s:openapi-gen=true
+type NodeStatus struct {
+	Node   string   `json:"node" yaml:"node"`                         // the name of the node
+	OK     bool     `json:"OK" yaml:"OK"`                             // whether the checker pod on the node reported success
+	Errors []string `json:"errors,omitempty" yaml:"errors,omitempty"` // the errors reported from the node, if any
+}
+
+// ZoneStatus records the result of a check that runs per zone in one of the zones
+// +k8s:openapi-gen=true
+type ZoneStatus struct {
+	Zone   string   `json:"zone" yaml:"zone"`                         // the topology zone
+	OK     bool     `json:"OK" yaml:"OK"`                             // whether the checker pod in the zone reported success
+	Errors []string `json:"errors,omitempty" yaml:"errors,omitempty"` // the errors reported from the zone, if any
+}
+
+// SubCheckStatus records the result of a named part of a khWorkload run, such as a single endpoint of a DNS check
+// +k8s:openapi-gen=true
+type SubCheckStatus struct {
+	Name   string   `json:"name" yaml:"name"`                         // the name the khWorkload reported the sub-check under
+	OK     bool     `json:"OK" yaml:"OK"`                             // whether the sub-check passed
+	Errors []string `json:"errors,omitempty" yaml:"errors,omitempty"` // why the sub-check failed, if it did
+}
+
+// ErrorDetail records the severity, code and details of an error reported by a khWorkload run
+// +k8s:openapi-gen=true
+type ErrorDetail struct {
+	Message  string `json:"message" yaml:"message"`   // the error as it appears in the errors of the khstate
+	Severity string `json:"severity" yaml:"severity"` // critical, warning or info
+	// +optional
+	Code string `json:"code,omitempty" yaml:"code,omitempty"` // the machine-readable code of the error, if any
+	// +optional
+	Metadata map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"` // details about the error, if any
+}
+
+// Artifact records a log excerpt or other diagnostic output attached by a khWorkload run to its failure report
+// +k8s:openapi-gen=true
+type Artifact struct {
+	Name string `json:"name" yaml:"name"` // what the artifact is, such as checker.log
+	// +optional
+	ContentType string `json:"contentType,omitempty" yaml:"contentType,omitempty"` // the media type of the content, if reported
+	Content     string `json:"content" yaml:"content"`                             // the log excerpt or diagnostic output
+	// +optional
+	Truncated bool `json:"truncated,omitempty" yaml:"truncated,omitempty"` // true when only the end of the content was kept
+}
+
+// RunProgress records the latest progress reported by a khWorkload run before its final result
+// +k8s:openapi-gen=true
+type RunProgress struct {
+	UUID    string `json:"uuid" yaml:"uuid"`                           // the run UUID that reported the progress
+	Message string `json:"message,omitempty" yaml:"message,omitempty"` // what the run is working on
+	Percent int    `json:"percent" yaml:"percent"`                     // how far along the run is, from 0 to 100
+	// +nullable
+	Time *metav1.Time `json:"time,omitempty" yaml:"time,omitempty"` // the time the progress was reported
+}
+
+// ResultState tells how the latest result of a khWorkload is shown on the status page
+type ResultState string
+
+// Checks that passed but reported warnings are shown as warning, without failing the cluster.  Checks that have not
+// recorded a result for several run intervals are unknown rather than ok or failed, as their last result may no longer
+// reflect the cluster.  This happens when runs stop being scheduled or checker pods never report.
+const (
+	ResultOK      ResultState = "ok"
+	ResultWarning ResultState = "warning"
+	ResultFailed  ResultState = "failed"
+	ResultUnknown ResultState = "unknown"
+)
+
+// RunResult describes the outcome of a khWorkload run as recorded in its history
+type RunResult string
+
+// Runs either report in before their deadline (success or failure) or after Kuberhealthy has already
+// recorded a timeout for them (late success or late failure).  Late results are kept for visibility
+// but do not change the current state of the khWorkload.
+const (
+	RunSuccess     RunResult = "success"
+	RunFailure     RunResult = "failure"
+	RunLateSuccess RunResult = "late success"
+	RunLateFailure RunResult = "late failure"
+)
+
+// RunProvisioningError is recorded for runs whose checker pod could not be created or never got to run
+const RunProvisioningError RunResult = "provisioning error"
+
+// RunCanceled is recorded for runs that were canceled before they finished, such as when a newer run replaced them
+const RunCanceled RunResult = "canceled"
+
+// FailureReason tells why a khWorkload run failed so that automation can act on the kind of failure without parsing
+// its errors.  Runs that passed have no reason.
+type FailureReason string
+
+// The reasons a run can fail for.  Failures that fit none of them, such as a checker pod that was deleted while it
+// ran, are recorded without a reason.
+const (
+	ReasonTimedOut            FailureReason = "TimedOut"            // the checker pod started but did not report or finish before the run deadline
+	ReasonStartTimedOut       FailureReason = "StartTimedOut"       // the checker pod was scheduled but did not start in time, such as when its image is slow to pull
+	ReasonPodSchedulingFailed FailureReason = "PodSchedulingFailed" // the checker pod could not be scheduled onto a node in time
+	ReasonReportedErrors      FailureReason = "ReportedErrors"      // the checker pod reported errors
+	ReasonProvisioningError   FailureReason = "ProvisioningError"   // the checker pod could not be created or never got to run
+	ReasonAdmissionRejected   FailureReason = "AdmissionRejected"   // admission control, such as pod security, a resource quota or a webhook, rejected the checker pod
+	ReasonCancelled           FailureReason = "Cancelled"           // the run was canceled before it finished
+)
+
 // KHWorkload is used to describe the different types of kuberhealthy workloads: KhCheck or KHJob
 type KHWorkload string
 
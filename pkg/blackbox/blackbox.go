@@ -0,0 +1,298 @@
+// Package blackbox converts Prometheus blackbox-exporter module configs into equivalent Kuberhealthy khchecks.  This
+// eases moving synthetic probes from the blackbox-exporter into Kuberhealthy.  HTTP modules become http-check
+// khchecks, TCP modules become network-connection-check khchecks and DNS modules become dns-resolution-check
+// khchecks.  Settings that the Kuberhealthy checks can not express are returned as warnings.
+package blackbox
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	ghodssyaml "github.com/ghodss/yaml"
+	"gopkg.in/yaml.v2"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+)
+
+// Default images used for converted checks
+const (
+	DefaultHTTPCheckImage = "kuberhealthy/http-check:v1.5.0"
+	DefaultTCPCheckImage  = "kuberhealthy/network-connection-check:v0.2.0"
+	DefaultDNSCheckImage  = "kuberhealthy/dns-resolution-check:v1.5.0"
+)
+
+// Defaults for converted checks
+const (
+	DefaultNamespace    = "kuberhealthy"
+	DefaultRunInterval  = time.Minute * 5
+	defaultProbeTimeout = time.Second * 5
+)
+
+// Prober names used by the blackbox-exporter
+const (
+	proberHTTP = "http"
+	proberTCP  = "tcp"
+	proberDNS  = "dns"
+)
+
+// Config is the subset of a blackbox-exporter config file that can be converted
+type Config struct {
+	Modules map[string]Module `yaml:"modules"`
+}
+
+// Module is a single blackbox-exporter module
+type Module struct {
+	Prober  string        `yaml:"prober"`
+	Timeout time.Duration `yaml:"timeout"`
+	HTTP    HTTPProbe     `yaml:"http"`
+	TCP     TCPProbe      `yaml:"tcp"`
+	DNS     DNSProbe      `yaml:"dns"`
+}
+
+// HTTPProbe holds the settings of a blackbox-exporter http module
+type HTTPProbe struct {
+	Method           string            `yaml:"method"`
+	ValidStatusCodes []int             `yaml:"valid_status_codes"`
+	Headers          map[string]string `yaml:"headers"`
+	Body             string            `yaml:"body"`
+	FailIfSSL        bool              `yaml:"fail_if_ssl"`
+	FailIfNotSSL     bool              `yaml:"fail_if_not_ssl"`
+}
+
+// TCPProbe holds the settings of a blackbox-exporter tcp module
+type TCPProbe struct {
+	QueryResponse []map[string]string `yaml:"query_response"`
+	TLS           bool                `yaml:"tls"`
+}
+
+// DNSProbe holds the settings of a blackbox-exporter dns module
+type DNSProbe struct {
+	QueryName string `yaml:"query_name"`
+	QueryType string `yaml:"query_type"`
+}
+
+// Target pairs a blackbox-exporter module with a target it probes, as set up in a Prometheus scrape config
+type Target struct {
+	Module string
+	Target string
+}
+
+// Options control the khchecks produced by Convert
+type Options struct {
+	Namespace      string
+	RunInterval    time.Duration
+	HTTPCheckImage string
+	TCPCheckImage  string
+	DNSCheckImage  string
+}
+
+// ParseConfig parses a blackbox-exporter config file
+func ParseConfig(b []byte) (Config, error) {
+	c := Config{}
+	err := yaml.Unmarshal(b, &c)
+	if err != nil {
+		return c, fmt.Errorf("failed to parse blackbox-exporter config: %w", err)
+	}
+	if len(c.Modules) == 0 {
+		return c, errors.New("blackbox-exporter config has no modules")
+	}
+	return c, nil
+}
+
+// ParseTarget parses a target in the form module=target
+func ParseTarget(s string) (Target, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return Target{}, errors.New("target " + s + " must be in the form module=target")
+	}
+	return Target{Module: parts[0], Target: parts[1]}, nil
+}
+
+// Convert turns each target into a khcheck using the settings of its blackbox-exporter module.  Targets using
+// modules that can not be converted cause an error.  Settings of a module that are not carried over are returned as
+// warnings.
+func Convert(c Config, targets []Target, o Options) ([]khcheckv1.KuberhealthyCheck, []string, error) {
+	o = o.withDefaults()
+
+	var checks []khcheckv1.KuberhealthyCheck
+	var warnings []string
+	names := make(map[string]bool)
+	for _, t := range targets {
+		m, ok := c.Modules[t.Module]
+		if !ok {
+			return nil, warnings, errors.New("target " + t.Target + " uses unknown module " + t.Module)
+		}
+
+		container, moduleWarnings, err := convertModule(t, m, o)
+		if err != nil {
+			return nil, warnings, err
+		}
+		warnings = append(warnings, moduleWarnings...)
+
+		name := checkName(t)
+		if names[name] {
+			return nil, warnings, errors.New("targets convert to the same check name " + name)
+		}
+		names[name] = true
+
+		timeout := m.Timeout
+		if timeout == 0 {
+			timeout = defaultProbeTimeout
+		}
+
+		checks = append(checks, khcheckv1.KuberhealthyCheck{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "comcast.github.io/v1",
+				Kind:       "KuberhealthyCheck",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: o.Namespace,
+			},
+			Spec: khcheckv1.CheckConfig{
+				RunInterval: o.RunInterval.String(),
+				// leave the checker pod time to start on top of the probe timeout
+				Timeout: (timeout + time.Minute*5).String(),
+				PodSpec: apiv1.PodSpec{
+					Containers: []apiv1.Container{container},
+				},
+			},
+		})
+	}
+	return checks, warnings, nil
+}
+
+// Marshal renders khchecks as a multi-document YAML stream ready to be applied to a cluster
+func Marshal(checks []khcheckv1.KuberhealthyCheck) ([]byte, error) {
+	var docs []string
+	for _, c := range checks {
+		b, err := ghodssyaml.Marshal(c)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal khcheck %s: %w", c.Name, err)
+		}
+		docs = append(docs, string(b))
+	}
+	return []byte(strings.Join(docs, "---\n")), nil
+}
+
+// convertModule builds the checker container that probes a target the way the module does
+func convertModule(t Target, m Module, o Options) (apiv1.Container, []string, error) {
+	var warnings []string
+	warn := func(s string) {
+		warnings = append(warnings, "module "+t.Module+": "+s)
+	}
+
+	container := apiv1.Container{Name: "main"}
+	switch m.Prober {
+	case proberHTTP:
+		container.Image = o.HTTPCheckImage
+		container.Env = append(container.Env, apiv1.EnvVar{Name: "CHECK_URL", Value: httpURL(t.Target)})
+		if len(m.HTTP.Method) > 0 {
+			container.Env = append(container.Env, apiv1.EnvVar{Name: "REQUEST_TYPE", Value: strings.ToUpper(m.HTTP.Method)})
+		}
+		if len(m.HTTP.Body) > 0 {
+			container.Env = append(container.Env, apiv1.EnvVar{Name: "REQUEST_BODY", Value: m.HTTP.Body})
+		}
+		if len(m.HTTP.ValidStatusCodes) > 0 {
+			container.Env = append(container.Env, apiv1.EnvVar{Name: "EXPECTED_STATUS_CODE", Value: strconv.Itoa(m.HTTP.ValidStatusCodes[0])})
+		}
+		if len(m.HTTP.ValidStatusCodes) > 1 {
+			warn("only the first of valid_status_codes is checked")
+		}
+		if len(m.HTTP.Headers) > 0 {
+			warn("headers are not supported by http-check")
+		}
+		if m.HTTP.FailIfSSL || m.HTTP.FailIfNotSSL {
+			warn("fail_if_ssl and fail_if_not_ssl are not supported by http-check")
+		}
+	case proberTCP:
+		container.Image = o.TCPCheckImage
+		container.Env = append(container.Env, apiv1.EnvVar{Name: "CONNECTION_TARGET", Value: "tcp://" + t.Target})
+		if m.Timeout > 0 {
+			container.Env = append(container.Env, apiv1.EnvVar{Name: "CONNECTION_TIMEOUT", Value: m.Timeout.String()})
+		}
+		if len(m.TCP.QueryResponse) > 0 {
+			warn("query_response is not supported by network-connection-check")
+		}
+		if m.TCP.TLS {
+			warn("tls is not supported by network-connection-check, only the connection is checked")
+		}
+	case proberDNS:
+		if len(m.DNS.QueryName) == 0 {
+			return container, warnings, errors.New("module " + t.Module + " has no query_name")
+		}
+		container.Image = o.DNSCheckImage
+		container.Env = append(container.Env,
+			apiv1.EnvVar{Name: "HOSTNAME", Value: m.DNS.QueryName},
+			apiv1.EnvVar{Name: "NODE_NAME", ValueFrom: &apiv1.EnvVarSource{FieldRef: &apiv1.ObjectFieldSelector{FieldPath: "spec.nodeName"}}},
+		)
+		warn("dns-resolution-check resolves " + m.DNS.QueryName + " with the cluster DNS instead of " + t.Target)
+		if len(m.DNS.QueryType) > 0 && m.DNS.QueryType != "A" && m.DNS.QueryType != "AAAA" {
+			warn("query_type " + m.DNS.QueryType + " is not supported by dns-resolution-check")
+		}
+	default:
+		return container, warnings, errors.New("module " + t.Module + " uses the " + m.Prober + " prober, which can not be converted")
+	}
+
+	return container, warnings, nil
+}
+
+// httpURL adds the scheme the blackbox-exporter assumes to targets without one
+func httpURL(target string) string {
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		return target
+	}
+	return "http://" + target
+}
+
+// checkName builds a valid khcheck name from a module and target
+func checkName(t Target) string {
+	target := t.Target
+	for _, prefix := range []string{"http://", "https://", "tcp://"} {
+		target = strings.TrimPrefix(target, prefix)
+	}
+
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, t.Module+"-"+target)
+
+	// collapse repeated dashes and keep the name within the limit for pod names
+	for strings.Contains(name, "--") {
+		name = strings.ReplaceAll(name, "--", "-")
+	}
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.Trim(name, "-")
+}
+
+// withDefaults fills in unset options
+func (o Options) withDefaults() Options {
+	if len(o.Namespace) == 0 {
+		o.Namespace = DefaultNamespace
+	}
+	if o.RunInterval == 0 {
+		o.RunInterval = DefaultRunInterval
+	}
+	if len(o.HTTPCheckImage) == 0 {
+		o.HTTPCheckImage = DefaultHTTPCheckImage
+	}
+	if len(o.TCPCheckImage) == 0 {
+		o.TCPCheckImage = DefaultTCPCheckImage
+	}
+	if len(o.DNSCheckImage) == 0 {
+		o.DNSCheckImage = DefaultDNSCheckImage
+	}
+	return o
+}
@@ -0,0 +1,125 @@
+package blackbox
+
+import (
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+const testConfig = `
+modules:
+  http_2xx:
+    prober: http
+    timeout: 10s
+    http:
+      method: post
+      body: '{"ping": true}'
+      valid_status_codes: [201, 202]
+      headers:
+        Authorization: secret
+  tcp_connect:
+    prober: tcp
+    timeout: 3s
+  dns_example:
+    prober: dns
+    dns:
+      query_name: example.com
+      query_type: MX
+  icmp:
+    prober: icmp
+`
+
+// envValue returns the value of an environment variable of a container
+func envValue(c apiv1.Container, name string) string {
+	for _, e := range c.Env {
+		if e.Name == name {
+			return e.Value
+		}
+	}
+	return ""
+}
+
+// TestConvert ensures that blackbox-exporter modules are converted into khchecks using the matching check image
+func TestConvert(t *testing.T) {
+
+	c, err := ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("failed to parse config: %s", err)
+	}
+
+	var testCases = []struct {
+		target        string
+		expectName    string
+		expectImage   string
+		expectEnv     map[string]string
+		expectWarning bool
+		expectError   bool
+	}{
+		{"http_2xx=https://Example.com/health", "http-2xx-example-com-health", DefaultHTTPCheckImage, map[string]string{"CHECK_URL": "https://Example.com/health", "REQUEST_TYPE": "POST", "REQUEST_BODY": `{"ping": true}`, "EXPECTED_STATUS_CODE": "201"}, true, false},
+		{"tcp_connect=example.com:443", "tcp-connect-example-com-443", DefaultTCPCheckImage, map[string]string{"CONNECTION_TARGET": "tcp://example.com:443", "CONNECTION_TIMEOUT": "3s"}, false, false},
+		{"dns_example=8.8.8.8", "dns-example-8-8-8-8", DefaultDNSCheckImage, map[string]string{"HOSTNAME": "example.com"}, true, false},
+		{"icmp=example.com", "", "", nil, false, true},
+		{"missing=example.com", "", "", nil, false, true},
+	}
+
+	for _, tc := range testCases {
+		target, err := ParseTarget(tc.target)
+		if err != nil {
+			t.Fatalf("failed to parse target %s: %s", tc.target, err)
+		}
+
+		checks, warnings, err := Convert(c, []Target{target}, Options{})
+		t.Logf("%s warnings: %v", tc.target, warnings)
+		if tc.expectError {
+			if err == nil {
+				t.Fatalf("%s: expected an error but got none", tc.target)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: failed to convert: %s", tc.target, err)
+		}
+		if tc.expectWarning != (len(warnings) > 0) {
+			t.Fatalf("%s: warnings were %v but expected warnings to be %t", tc.target, warnings, tc.expectWarning)
+		}
+
+		check := checks[0]
+		if check.Name != tc.expectName || check.Namespace != DefaultNamespace {
+			t.Fatalf("%s: check was named %s/%s but expected %s/%s", tc.target, check.Namespace, check.Name, DefaultNamespace, tc.expectName)
+		}
+		container := check.Spec.PodSpec.Containers[0]
+		if container.Image != tc.expectImage {
+			t.Fatalf("%s: image was %s but expected %s", tc.target, container.Image, tc.expectImage)
+		}
+		for k, v := range tc.expectEnv {
+			if envValue(container, k) != v {
+				t.Fatalf("%s: env %s was %q but expected %q", tc.target, k, envValue(container, k), v)
+			}
+		}
+	}
+}
+
+// TestMarshal ensures that converted khchecks are written as a multi-document YAML stream
+func TestMarshal(t *testing.T) {
+
+	c, err := ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("failed to parse config: %s", err)
+	}
+	checks, _, err := Convert(c, []Target{{"tcp_connect", "a.example.com:443"}, {"tcp_connect", "b.example.com:443"}}, Options{Namespace: "probes"})
+	if err != nil {
+		t.Fatalf("failed to convert: %s", err)
+	}
+
+	out, err := Marshal(checks)
+	if err != nil {
+		t.Fatalf("failed to marshal: %s", err)
+	}
+	if strings.Count(string(out), "kind: KuberhealthyCheck") != 2 || strings.Count(string(out), "---\n") != 1 {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	if !strings.Contains(string(out), "namespace: probes") {
+		t.Fatalf("namespace option was not applied:\n%s", out)
+	}
+}
@@ -0,0 +1,47 @@
+package external
+
+import (
+	"context"
+
+	apiv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// validatePodAdmission creates the checker pod of the current run as a server side dry run, so that admission control
+// rejecting the pod, such as pod security admission, a resource quota, a limit range or a validating webhook, fails
+// the run straight away with the message of the rejection.
+func (ext *Checker) validatePodAdmission(ctx context.Context) error {
+	p, err := ext.buildPod()
+	if err != nil {
+		return ext.newError("failed to prepare checker pod: " + err.Error())
+	}
+	err = dryRunPod(ctx, ext.KubeClient, p)
+	if err != nil {
+		return &ProvisioningError{Err: ext.newError("checker pod was rejected: " + err.Error()), AdmissionRejected: true}
+	}
+	return nil
+}
+
+// dryRunPod creates a pod as a server side dry run and returns the error of a rejected pod.  Other errors, such as a
+// webhook with side effects that can not be called in a dry run, are only logged because they don't mean the pod
+// would be rejected.
+func dryRunPod(ctx context.Context, client kubernetes.Interface, p *apiv1.Pod) error {
+	_, err := client.CoreV1().Pods(p.Namespace).Create(ctx, p, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+	if err == nil {
+		return nil
+	}
+	if admissionRejected(err) {
+		return err
+	}
+	log.Warningln("Failed to create checker pod", p.Name, "as a dry run:", err)
+	return nil
+}
+
+// admissionRejected tells if an error creating a pod means the pod was rejected by validation or admission control
+func admissionRejected(err error) bool {
+	return k8sErrors.IsForbidden(err) || k8sErrors.IsInvalid(err)
+}
@@ -0,0 +1,44 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// TestDryRunPod ensures that only rejections by admission control are returned from dry runs
+func TestDryRunPod(t *testing.T) {
+	podsResource := schema.GroupResource{Resource: "pods"}
+	var testCases = []struct {
+		description string
+		createErr   error
+		expectErr   bool
+	}{
+		{"admitted", nil, false},
+		{"pod security", k8sErrors.NewForbidden(podsResource, "checker", errors.New(`violates PodSecurity "restricted:latest"`)), true},
+		{"invalid", k8sErrors.NewInvalid(schema.GroupKind{Kind: "Pod"}, "checker", nil), true},
+		{"webhook without dry run support", k8sErrors.NewBadRequest("admission webhook has side effects and does not support dry run"), false},
+		{"api unavailable", k8sErrors.NewServiceUnavailable("etcdserver: leader changed"), false},
+	}
+
+	for _, tc := range testCases {
+		client := fake.NewSimpleClientset()
+		client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, tc.createErr
+		})
+
+		pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "checker", Namespace: "kuberhealthy"}}
+		err := dryRunPod(context.Background(), client, pod)
+		if (err != nil) != tc.expectErr {
+			t.Fatalf("%s: returned error %v but expected an error: %t", tc.description, err, tc.expectErr)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package external
+
+import (
+	"fmt"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+)
+
+// checkAggregation returns the aggregation of a khcheck spec.  Checks that do not set one require every node or zone
+// to pass.
+func checkAggregation(a *khcheckv1.Aggregation) khcheckv1.Aggregation {
+	if a == nil || len(a.Policy) == 0 {
+		return khcheckv1.Aggregation{Policy: khcheckv1.AggregateAll}
+	}
+	return *a
+}
+
+// ValidateAggregation checks that an aggregation names a known policy and that percentage policies have a
+// percentage between 1 and 100
+func ValidateAggregation(a khcheckv1.Aggregation) error {
+	switch a.Policy {
+	case khcheckv1.AggregateAll, khcheckv1.AggregateQuorum:
+		return nil
+	case khcheckv1.AggregatePercentage:
+		if a.Percentage < 1 || a.Percentage > 100 {
+			return fmt.Errorf("aggregation percentage must be between 1 and 100, but was %d", a.Percentage)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown aggregation policy %q", a.Policy)
+}
+
+// aggregationPassed indicates that enough of the targets of a fanned out run passed to satisfy its aggregation.  Runs
+// without an aggregation policy require every target to pass.
+func aggregationPassed(a khcheckv1.Aggregation, passed int, total int) bool {
+	switch a.Policy {
+	case khcheckv1.AggregateQuorum:
+		return passed*2 > total
+	case khcheckv1.AggregatePercentage:
+		return passed*100 >= a.Percentage*total
+	}
+	return passed == total
+}
+
+// aggregationShortfall describes how a fanned out run fell short of an aggregation that does not require every
+// target to pass.  Nothing is returned for runs that require every target, because the errors of the failed targets
+// already say it all.
+func aggregationShortfall(a khcheckv1.Aggregation, fanOut FanOut, passed int, total int) string {
+	switch a.Policy {
+	case khcheckv1.AggregateQuorum:
+		return fmt.Sprintf("%d of %d %ss passed, which is not a quorum", passed, total, fanOut)
+	case khcheckv1.AggregatePercentage:
+		return fmt.Sprintf("%d of %d %ss passed, which is less than the required %d%%", passed, total, fanOut, a.Percentage)
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+package external
+
+import (
+	"reflect"
+	"testing"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestValidateAggregation ensures that only known policies and sensible percentages are accepted
+func TestValidateAggregation(t *testing.T) {
+	var testCases = []struct {
+		aggregation khcheckv1.Aggregation
+		valid       bool
+	}{
+		{aggregation: checkAggregation(nil), valid: true},
+		{aggregation: khcheckv1.Aggregation{Policy: khcheckv1.AggregateQuorum}, valid: true},
+		{aggregation: khcheckv1.Aggregation{Policy: khcheckv1.AggregatePercentage, Percentage: 75}, valid: true},
+		{aggregation: khcheckv1.Aggregation{Policy: khcheckv1.AggregatePercentage}, valid: false},
+		{aggregation: khcheckv1.Aggregation{Policy: khcheckv1.AggregatePercentage, Percentage: 101}, valid: false},
+		{aggregation: khcheckv1.Aggregation{Policy: "majority"}, valid: false},
+	}
+
+	for _, tc := range testCases {
+		err := ValidateAggregation(tc.aggregation)
+		if (err == nil) != tc.valid {
+			t.Fatalf("validating %+v returned %v, but expected it to be valid: %t", tc.aggregation, err, tc.valid)
+		}
+	}
+}
+
+// TestAggregationPolicies ensures that each aggregation policy decides the report of a fanned out run
+func TestAggregationPolicies(t *testing.T) {
+	reports := map[string]status.Report{
+		"zone-a": status.NewReport(nil),
+		"zone-b": status.NewReport(nil),
+		"zone-c": status.NewReport([]string{"dns failed"}),
+	}
+	targets := []string{"zone-a", "zone-b", "zone-c", "zone-d"}
+
+	var testCases = []struct {
+		description string
+		aggregation khcheckv1.Aggregation
+		missing     string
+		expected    status.Report
+	}{
+		{
+			description: "every target must pass by default",
+			expected:    status.NewReport([]string{"zone zone-c: dns failed", "zone zone-d: did not report"}),
+		},
+		{
+			description: "half of the targets are not a quorum",
+			aggregation: khcheckv1.Aggregation{Policy: khcheckv1.AggregateQuorum},
+			expected:    status.NewReport([]string{"2 of 4 zones passed, which is not a quorum", "zone zone-c: dns failed", "zone zone-d: did not report"}),
+		},
+		{
+			description: "half of the targets meet a percentage of 50",
+			aggregation: khcheckv1.Aggregation{Policy: khcheckv1.AggregatePercentage, Percentage: 50},
+			expected:    status.NewReport([]string{}),
+		},
+		{
+			description: "half of the targets fall short of a percentage of 51",
+			aggregation: khcheckv1.Aggregation{Policy: khcheckv1.AggregatePercentage, Percentage: 51},
+			expected:    status.NewReport([]string{"2 of 4 zones passed, which is less than the required 51%", "zone zone-c: dns failed", "zone zone-d: did not report"}),
+		},
+	}
+
+	for _, tc := range testCases {
+		run := Run{FanOut: FanOutZones, Targets: targets, TargetReports: reports, Aggregation: tc.aggregation}
+		report := run.AggregateReport("did not report")
+		if !reflect.DeepEqual(report, tc.expected) {
+			t.Fatalf("%s: report was %+v but expected %+v", tc.description, report, tc.expected)
+		}
+	}
+
+	// a quorum is reached once the last zone reports in
+	reports["zone-d"] = status.NewReport(nil)
+	run := Run{FanOut: FanOutZones, Targets: targets, TargetReports: reports, Aggregation: khcheckv1.Aggregation{Policy: khcheckv1.AggregateQuorum}}
+	if report := run.AggregateReport(""); !report.OK {
+		t.Fatalf("3 of 4 zones passing was not a quorum: %+v", report)
+	}
+	if zones := run.ZoneStatuses(""); len(zones) != 4 || zones[2].OK {
+		t.Fatalf("zone statuses of a run that passed by quorum did not keep the failed zone: %+v", zones)
+	}
+}
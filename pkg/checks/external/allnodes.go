@@ -0,0 +1,44 @@
+package external
+
+import (
+	"context"
+	"sort"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NodeAnnotation is the annotation set on the checker pods of runs on all nodes that holds the node they were
+// spawned for
+const NodeAnnotation = "comcast.github.io/node"
+
+// selectRunNodes lists the nodes that a run on all nodes spawns checker pods on.  Nodes are selected by their labels
+// and cordoned nodes and nodes that are not ready are left out.
+func selectRunNodes(ctx context.Context, client kubernetes.Interface, cache NodeCache, selector map[string]string) ([]string, error) {
+	nodes, err := schedulableNodes(ctx, client, cache, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, n := range nodes {
+		names = append(names, n.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// pinToNode requires a pod spec to be scheduled on the supplied node
+func pinToNode(spec *apiv1.PodSpec, node string) {
+	requireNodes(spec, apiv1.NodeSelectorRequirement{
+		Key:      metav1.ObjectNameField,
+		Operator: apiv1.NodeSelectorOpIn,
+		Values:   []string{node},
+	}, true)
+}
+
+// runOnAllNodes spawns a checker pod on every matching node and waits until all of them have reported
+func (ext *Checker) runOnAllNodes(ctx context.Context) error {
+	return ext.runFanOut(ctx, FanOutNodes)
+}
@@ -0,0 +1,53 @@
+package external
+
+import (
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// Kuberhealthy anti-affinity policies keep checker pods off of the node the Kuberhealthy master runs on, so that a
+// problem local to that node can't take out both a check and the server it reports to without anyone noticing
+const (
+	// AntiAffinityNone lets checker pods be scheduled onto any node
+	AntiAffinityNone = "none"
+	// AntiAffinityPreferred asks the scheduler to avoid the node of the Kuberhealthy master when it can
+	AntiAffinityPreferred = "preferred"
+	// AntiAffinityRequired never schedules checker pods onto the node of the Kuberhealthy master
+	AntiAffinityRequired = "required"
+)
+
+// nodeNameField is the node field that node affinity matches node names with
+const nodeNameField = "metadata.name"
+
+// kuberhealthyAntiAffinityWeight is the weight of the preferred scheduling term that avoids the Kuberhealthy node
+const kuberhealthyAntiAffinityWeight = 100
+
+// configureKuberhealthyAntiAffinity adds node affinity to the pod spec that keeps checker pods off of the node the
+// Kuberhealthy master runs on.  Runs on all nodes are left alone because one of their pods has to run there.
+func (ext *Checker) configureKuberhealthyAntiAffinity() {
+	if len(ext.KuberhealthyNode) == 0 || ext.RunOnAllNodes {
+		return
+	}
+
+	avoidNode := apiv1.NodeSelectorRequirement{
+		Key:      nodeNameField,
+		Operator: apiv1.NodeSelectorOpNotIn,
+		Values:   []string{ext.KuberhealthyNode},
+	}
+
+	switch ext.KuberhealthyAntiAffinity {
+	case AntiAffinityRequired:
+		requireNodes(&ext.PodSpec, avoidNode, true)
+	case AntiAffinityPreferred:
+		if ext.PodSpec.Affinity == nil {
+			ext.PodSpec.Affinity = &apiv1.Affinity{}
+		}
+		if ext.PodSpec.Affinity.NodeAffinity == nil {
+			ext.PodSpec.Affinity.NodeAffinity = &apiv1.NodeAffinity{}
+		}
+		nodeAffinity := ext.PodSpec.Affinity.NodeAffinity
+		nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution, apiv1.PreferredSchedulingTerm{
+			Weight:     kuberhealthyAntiAffinityWeight,
+			Preference: apiv1.NodeSelectorTerm{MatchFields: []apiv1.NodeSelectorRequirement{avoidNode}},
+		})
+	}
+}
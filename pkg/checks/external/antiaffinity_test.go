@@ -0,0 +1,64 @@
+package external
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// TestConfigureKuberhealthyAntiAffinity ensures that checker pods are kept off of the node of the Kuberhealthy master
+// as the anti-affinity policy says, without modifying the original pod spec
+func TestConfigureKuberhealthyAntiAffinity(t *testing.T) {
+	userTerm := apiv1.NodeSelectorTerm{MatchExpressions: []apiv1.NodeSelectorRequirement{{Key: "pool", Operator: apiv1.NodeSelectorOpIn, Values: []string{"a"}}}}
+
+	var testCases = []struct {
+		description     string
+		policy          string
+		node            string
+		runOnAllNodes   bool
+		expectRequired  bool
+		expectPreferred bool
+	}{
+		{"no policy", "", "node-1", false, false, false},
+		{"none", AntiAffinityNone, "node-1", false, false, false},
+		{"preferred", AntiAffinityPreferred, "node-1", false, false, true},
+		{"required", AntiAffinityRequired, "node-1", false, true, false},
+		{"unknown node", AntiAffinityRequired, "", false, false, false},
+		{"run on all nodes", AntiAffinityRequired, "node-1", true, false, false},
+	}
+
+	for _, tc := range testCases {
+		original := apiv1.PodSpec{Affinity: &apiv1.Affinity{NodeAffinity: &apiv1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &apiv1.NodeSelector{NodeSelectorTerms: []apiv1.NodeSelectorTerm{userTerm}},
+		}}}
+		ext := &Checker{KuberhealthyAntiAffinity: tc.policy, KuberhealthyNode: tc.node, RunOnAllNodes: tc.runOnAllNodes}
+		ext.PodSpec = *original.DeepCopy()
+		ext.configureKuberhealthyAntiAffinity()
+
+		nodeAffinity := ext.PodSpec.Affinity.NodeAffinity
+		terms := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+		if len(terms) != 1 || len(terms[0].MatchExpressions) != 1 {
+			t.Fatalf("%s: expected the user's node affinity to be kept but got %+v", tc.description, terms)
+		}
+		required := len(terms[0].MatchFields) == 1 && terms[0].MatchFields[0].Key == nodeNameField &&
+			terms[0].MatchFields[0].Operator == apiv1.NodeSelectorOpNotIn && terms[0].MatchFields[0].Values[0] == tc.node
+		if required != tc.expectRequired {
+			t.Fatalf("%s: required anti-affinity was %t but expected %t: %+v", tc.description, required, tc.expectRequired, terms)
+		}
+
+		preferred := len(nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution) == 1
+		if preferred != tc.expectPreferred {
+			t.Fatalf("%s: preferred anti-affinity was %t but expected %t", tc.description, preferred, tc.expectPreferred)
+		}
+		if preferred {
+			fields := nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].Preference.MatchFields
+			if len(fields) != 1 || fields[0].Values[0] != tc.node {
+				t.Fatalf("%s: expected the preferred term to avoid %s but got %+v", tc.description, tc.node, fields)
+			}
+		}
+
+		if len(original.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchFields) != 0 {
+			t.Fatalf("%s: the original pod spec was modified", tc.description)
+		}
+	}
+}
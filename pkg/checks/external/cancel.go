@@ -0,0 +1,47 @@
+package external
+
+import (
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/util"
+)
+
+// checkStoppedReason is the reason given to checker pods whose run was canceled because its check was removed,
+// changed or stopped along with Kuberhealthy
+const checkStoppedReason = "the check was stopped"
+
+// defaultTerminationGracePeriod is how many seconds checker pods that don't set a termination grace period are given
+// to clean up after themselves when their run is canceled
+const defaultTerminationGracePeriod = 30
+
+// cancelInFlightRun cancels the run in progress in the run tracker so that its checker pod learns that its result is
+// no longer wanted.  Returns false when no run is in progress.
+func (ext *Checker) cancelInFlightRun(reason string) bool {
+	run, ok := ext.Runs.Get(ext.currentCheckUUID)
+	if !ok || run.Ended || run.State != RunRunning {
+		return false
+	}
+	ext.log("canceling run", run.UUID+":", reason)
+	ext.Runs.Cancel(run.UUID, reason)
+	return true
+}
+
+// terminationGracePeriod returns how many seconds the checker pod is given to shut down after it is sent SIGTERM
+func (ext *Checker) terminationGracePeriod() int64 {
+	if ext.PodSpec.TerminationGracePeriodSeconds != nil {
+		return *ext.PodSpec.TerminationGracePeriodSeconds
+	}
+	return defaultTerminationGracePeriod
+}
+
+// terminateCheckerPod deletes the checker pod of the current run with its termination grace period, so that it is
+// sent SIGTERM and can clean up any test resources it created before it is killed
+func (ext *Checker) terminateCheckerPod() {
+	exists, err := util.PodNameExists(ext.KubeClient, ext.podName(), ext.Namespace)
+	if err != nil || !exists {
+		return
+	}
+	ext.log("sending SIGTERM to checker pod", ext.podName())
+	err = util.PodKill(ext.KubeClient, ext.podName(), ext.Namespace, ext.terminationGracePeriod())
+	if err != nil {
+		ext.log("error terminating checker pod", ext.podName()+":", err)
+	}
+}
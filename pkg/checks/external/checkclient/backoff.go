@@ -0,0 +1,89 @@
+package checkclient
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff"
+)
+
+// BackoffConfig configures how failed reports are retried.  Delays between attempts grow exponentially from
+// InitialInterval by Multiplier up to MaxInterval, each randomized by RandomizationFactor, until MaxElapsedTime has
+// passed since the first attempt.  Fields left at zero keep their defaults.
+type BackoffConfig struct {
+	InitialInterval     time.Duration
+	MaxInterval         time.Duration
+	Multiplier          float64
+	RandomizationFactor float64
+	MaxElapsedTime      time.Duration
+}
+
+// DefaultBackoffConfig returns the retry settings used unless SetBackoffConfig is called
+func DefaultBackoffConfig() BackoffConfig {
+	return BackoffConfig{
+		InitialInterval:     backoff.DefaultInitialInterval,
+		MaxInterval:         backoff.DefaultMaxInterval,
+		Multiplier:          backoff.DefaultMultiplier,
+		RandomizationFactor: backoff.DefaultRandomizationFactor,
+		MaxElapsedTime:      maxElapsedTime,
+	}
+}
+
+// backoffConfig holds the retry settings set by SetBackoffConfig
+var backoffConfig = DefaultBackoffConfig()
+var backoffConfigMu sync.Mutex
+
+// SetBackoffConfig changes how failed reports are retried.  Checks with short deadlines can lower MaxElapsedTime to
+// fail fast and checks with long deadlines can raise it to keep retrying.  Delays Kuberhealthy asks for when it is
+// overloaded are still honored until the run deadline.
+func SetBackoffConfig(c BackoffConfig) error {
+	defaults := DefaultBackoffConfig()
+	if c.InitialInterval == 0 {
+		c.InitialInterval = defaults.InitialInterval
+	}
+	if c.MaxInterval == 0 {
+		c.MaxInterval = defaults.MaxInterval
+	}
+	if c.Multiplier == 0 {
+		c.Multiplier = defaults.Multiplier
+	}
+	if c.RandomizationFactor == 0 {
+		c.RandomizationFactor = defaults.RandomizationFactor
+	}
+	if c.MaxElapsedTime == 0 {
+		c.MaxElapsedTime = defaults.MaxElapsedTime
+	}
+
+	switch {
+	case c.InitialInterval < 0 || c.MaxInterval < 0 || c.MaxElapsedTime < 0:
+		return fmt.Errorf("backoff intervals and max elapsed time must not be negative")
+	case c.MaxInterval < c.InitialInterval:
+		return fmt.Errorf("backoff max interval %s is shorter than its initial interval %s", c.MaxInterval, c.InitialInterval)
+	case c.Multiplier < 1:
+		return fmt.Errorf("backoff multiplier must be at least 1, got %v", c.Multiplier)
+	case c.RandomizationFactor < 0 || c.RandomizationFactor > 1:
+		return fmt.Errorf("backoff randomization factor must be between 0 and 1, got %v", c.RandomizationFactor)
+	}
+
+	backoffConfigMu.Lock()
+	defer backoffConfigMu.Unlock()
+	backoffConfig = c
+	return nil
+}
+
+// newExponentialBackOff makes the backoff used to retry a report from the current retry settings
+func newExponentialBackOff() *backoff.ExponentialBackOff {
+	backoffConfigMu.Lock()
+	c := backoffConfig
+	backoffConfigMu.Unlock()
+
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = c.InitialInterval
+	b.MaxInterval = c.MaxInterval
+	b.Multiplier = c.Multiplier
+	b.RandomizationFactor = c.RandomizationFactor
+	b.MaxElapsedTime = c.MaxElapsedTime
+	b.Reset()
+	return b
+}
@@ -0,0 +1,72 @@
+package checkclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// TestSetBackoffConfig ensures invalid retry settings are refused and zero fields keep their defaults
+func TestSetBackoffConfig(t *testing.T) {
+	defer SetBackoffConfig(DefaultBackoffConfig())
+
+	var testCases = []struct {
+		description string
+		config      BackoffConfig
+		expectErr   bool
+	}{
+		{"defaults", BackoffConfig{}, false},
+		{"fail fast", BackoffConfig{MaxElapsedTime: time.Second * 5}, false},
+		{"negative interval", BackoffConfig{InitialInterval: -time.Second}, true},
+		{"max interval below initial interval", BackoffConfig{InitialInterval: time.Minute * 2}, true},
+		{"shrinking multiplier", BackoffConfig{Multiplier: 0.5}, true},
+		{"randomization factor above 1", BackoffConfig{RandomizationFactor: 2}, true},
+	}
+	for _, tc := range testCases {
+		err := SetBackoffConfig(tc.config)
+		if (err != nil) != tc.expectErr {
+			t.Fatalf("%s: returned error %v but expected an error: %t", tc.description, err, tc.expectErr)
+		}
+	}
+
+	err := SetBackoffConfig(BackoffConfig{MaxElapsedTime: time.Second * 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := newExponentialBackOff()
+	if b.MaxElapsedTime != time.Second*5 || b.InitialInterval != DefaultBackoffConfig().InitialInterval {
+		t.Fatalf("unexpected backoff %+v", b)
+	}
+}
+
+// TestReportWithShortBackoff ensures reports stop retrying once the configured max elapsed time has passed
+func TestReportWithShortBackoff(t *testing.T) {
+	defer SetBackoffConfig(DefaultBackoffConfig())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "backoff-run-uuid")
+	os.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+	err := SetBackoffConfig(BackoffConfig{InitialInterval: time.Millisecond * 50, MaxInterval: time.Millisecond * 100, MaxElapsedTime: time.Millisecond * 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	err = ReportSuccess()
+	if err == nil {
+		t.Fatal("expected reporting to a failing server to fail")
+	}
+	if time.Since(start) > time.Second*3 {
+		t.Fatal("reporting kept retrying for", time.Since(start), "past the max elapsed time")
+	}
+}
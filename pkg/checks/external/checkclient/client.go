@@ -0,0 +1,82 @@
+package checkclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// HTTPClient is the client used for every request to Kuberhealthy.  Set it before reporting when reports must go
+// through an egress proxy, present a client certificate or trust a custom CA.  http.DefaultClient is used when it is
+// nil.  Resident checkers wait on Kuberhealthy for their next run, so a client timeout must leave room for that wait.
+var HTTPClient *http.Client
+
+// ClientOptions configures an HTTP client made with NewHTTPClient
+type ClientOptions struct {
+	// CAFile is a PEM bundle of the CAs that are trusted to serve Kuberhealthy, in addition to the system CAs
+	CAFile string
+	// CertFile and KeyFile are a PEM client certificate and key presented to Kuberhealthy, such as by a mesh that
+	// requires mTLS
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify disables verification of the certificate served for Kuberhealthy
+	InsecureSkipVerify bool
+	// ProxyURL is the proxy requests are sent through.  The HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+	// are used when it is empty.
+	ProxyURL string
+	// Timeout bounds each request, including reading its response.  Requests are not bounded when it is zero.
+	Timeout time.Duration
+}
+
+// NewHTTPClient makes an HTTP client for talking to Kuberhealthy with the supplied TLS, proxy and timeout settings.
+// Assign it to HTTPClient to use it.
+func NewHTTPClient(o ClientOptions) (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+
+	if len(o.CAFile) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		b, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file %s: %w", o.CAFile, err)
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", o.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(o.CertFile) > 0 || len(o.KeyFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if len(o.ProxyURL) > 0 {
+		proxyURL, err := url.Parse(o.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse proxy url %s: %w", o.ProxyURL, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &http.Client{Transport: transport, Timeout: o.Timeout}, nil
+}
+
+// httpClient returns the client used for requests to Kuberhealthy
+func httpClient() *http.Client {
+	if HTTPClient != nil {
+		return HTTPClient
+	}
+	return http.DefaultClient
+}
@@ -0,0 +1,74 @@
+package checkclient
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// TestNewHTTPClient ensures that reports can be sent to a Kuberhealthy served with a certificate from a custom CA
+func TestNewHTTPClient(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "tls-run-uuid")
+	os.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+	defer func() { HTTPClient = nil }()
+
+	// the default client does not trust the test server
+	HTTPClient = &http.Client{Timeout: time.Second}
+	_, err := GetReportStatus()
+	if err == nil {
+		t.Fatal("expected a client without the test CA to fail")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	err = os.WriteFile(caFile, ca, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	HTTPClient, err = NewHTTPClient(ClientOptions{CAFile: caFile, Timeout: time.Second * 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ReportSuccess()
+	if err != nil {
+		t.Fatal("expected a report through a client trusting the test CA to succeed:", err)
+	}
+}
+
+// TestNewHTTPClientErrors ensures that bad TLS and proxy settings are rejected
+func TestNewHTTPClientErrors(t *testing.T) {
+	emptyFile := filepath.Join(t.TempDir(), "empty.pem")
+	err := os.WriteFile(emptyFile, []byte{}, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		description string
+		options     ClientOptions
+	}{
+		{"missing CA file", ClientOptions{CAFile: filepath.Join(t.TempDir(), "missing.pem")}},
+		{"CA file without certificates", ClientOptions{CAFile: emptyFile}},
+		{"client certificate without key", ClientOptions{CertFile: emptyFile}},
+		{"bad proxy url", ClientOptions{ProxyURL: "http://[::1"}},
+	}
+	for _, tc := range testCases {
+		_, err := NewHTTPClient(tc.options)
+		if err == nil {
+			t.Fatalf("%s: expected an error", tc.description)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package checkclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+)
+
+// minCompressedReportSize is the smallest report body that is compressed when CompressReports is set.  Smaller bodies
+// are not worth the overhead.
+const minCompressedReportSize = 1024
+
+// encodeReportBody compresses a report body with gzip when CompressReports is set and the body is large enough.  The
+// content encoding to send the body with is returned, which is blank when the body was left alone.
+func encodeReportBody(b []byte) ([]byte, string, error) {
+	if !CompressReports || len(b) < minCompressedReportSize {
+		return b, "", nil
+	}
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, err := gw.Write(b)
+	if err != nil {
+		return nil, "", fmt.Errorf("error compressing report: %w", err)
+	}
+	err = gw.Close()
+	if err != nil {
+		return nil, "", fmt.Errorf("error compressing report: %w", err)
+	}
+	logDebug("Compressed report from ", len(b), " to ", buf.Len(), " bytes")
+	return buf.Bytes(), "gzip", nil
+}
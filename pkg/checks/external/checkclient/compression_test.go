@@ -0,0 +1,73 @@
+package checkclient
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestReportFailureCompressed ensures large reports are sent gzip compressed when CompressReports is set and small
+// ones are sent as they are
+func TestReportFailureCompressed(t *testing.T) {
+	CompressReports = true
+	defer func() { CompressReports = false }()
+
+	var received status.Report
+	var encoding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		encoding = r.Header.Get("Content-Encoding")
+		body := r.Body
+		if encoding == "gzip" {
+			gr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = gr
+		}
+		received = status.Report{}
+		err := json.NewDecoder(body).Decode(&received)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "compressed-run-uuid")
+	os.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+	var errs []string
+	for i := 0; i < 200; i++ {
+		errs = append(errs, fmt.Sprintf("node-%d is not ready", i))
+	}
+	err := ReportFailure(errs)
+	if err != nil {
+		t.Fatal("Failed to report a large failure:", err)
+	}
+	if encoding != "gzip" || len(received.Errors) != 200 {
+		t.Fatalf("server received %d errors with encoding %q", len(received.Errors), encoding)
+	}
+
+	err = ReportFailure([]string{"one node is not ready"})
+	if err != nil {
+		t.Fatal("Failed to report a small failure:", err)
+	}
+	if encoding != "" || len(received.Errors) != 1 {
+		t.Fatalf("server received %d errors with encoding %q", len(received.Errors), encoding)
+	}
+}
@@ -0,0 +1,46 @@
+package checkclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultDeadlineMargin is how long before the run deadline contexts from ContextWithDeadline are done unless
+// SetDeadlineMargin says otherwise
+const DefaultDeadlineMargin = time.Second * 5
+
+// deadlineMargin holds the margin set by SetDeadlineMargin
+var deadlineMargin = DefaultDeadlineMargin
+var deadlineMarginMu sync.Mutex
+
+// SetDeadlineMargin changes how long before the run deadline contexts from ContextWithDeadline are done.  The margin
+// leaves the check time to clean up and report before Kuberhealthy times out the run, so checks that clean up a lot
+// can raise it.  Zero makes the contexts done right at the deadline.
+func SetDeadlineMargin(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("deadline margin must not be negative, got %s", d)
+	}
+	deadlineMarginMu.Lock()
+	defer deadlineMarginMu.Unlock()
+	deadlineMargin = d
+	return nil
+}
+
+// ContextWithDeadline returns a context that is done the deadline margin before the run deadline, along with the
+// function that cancels it.  The deadline is read once, so later extensions and heartbeats don't move the context's
+// deadline.  An error is returned when the run deadline can't be read from the environment.
+func ContextWithDeadline() (context.Context, context.CancelFunc, error) {
+	deadline, err := GetDeadline()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	deadlineMarginMu.Lock()
+	margin := deadlineMargin
+	deadlineMarginMu.Unlock()
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline.Add(-margin))
+	return ctx, cancel, nil
+}
@@ -0,0 +1,66 @@
+package checkclient
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// TestContextWithDeadline ensures contexts end the deadline margin before the run deadline
+func TestContextWithDeadline(t *testing.T) {
+	defer SetDeadlineMargin(DefaultDeadlineMargin)
+
+	deadline := time.Unix(time.Now().Add(time.Minute).Unix(), 0)
+	os.Setenv(external.KHDeadline, strconv.FormatInt(deadline.Unix(), 10))
+
+	var testCases = []struct {
+		description string
+		margin      time.Duration
+	}{
+		{"default margin", DefaultDeadlineMargin},
+		{"custom margin", time.Second * 20},
+		{"no margin", 0},
+	}
+	for _, tc := range testCases {
+		err := SetDeadlineMargin(tc.margin)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.description, err)
+		}
+		ctx, cancel, err := ContextWithDeadline()
+		if err != nil {
+			t.Fatalf("%s: %v", tc.description, err)
+		}
+		ctxDeadline, ok := ctx.Deadline()
+		cancel()
+		if !ok || !ctxDeadline.Equal(deadline.Add(-tc.margin)) {
+			t.Fatalf("%s: context deadline %v but expected %v", tc.description, ctxDeadline, deadline.Add(-tc.margin))
+		}
+	}
+
+	// the context is already done when the margin reaches past the deadline
+	err := SetDeadlineMargin(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel, err := ContextWithDeadline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	if ctx.Err() == nil {
+		t.Fatal("context should be done when the margin is longer than the time left")
+	}
+
+	if SetDeadlineMargin(-time.Second) == nil {
+		t.Fatal("negative margins should be refused")
+	}
+
+	os.Setenv(external.KHDeadline, "")
+	_, _, err = ContextWithDeadline()
+	if err == nil {
+		t.Fatal("expected an error without a run deadline")
+	}
+}
@@ -0,0 +1,133 @@
+// Package fake provides a fake Kuberhealthy for unit testing checks that report with the checkclient
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// RunUUID is the run UUID the fake hands to the checkclient
+const RunUUID = "00000000-0000-0000-0000-000000000000"
+
+// Server is a fake Kuberhealthy that records the reports sent to it in memory.  It answers reports and run
+// cancellation requests the way Kuberhealthy does for a run that is in progress.
+type Server struct {
+	URL string // the reporting URL of the fake
+
+	server *httptest.Server
+
+	mu           sync.Mutex
+	reports      []status.Report
+	statusCode   int
+	cancelReason string
+	canceled     bool
+}
+
+// NewServer starts a fake Kuberhealthy and points the checkclient at it by setting the environment variables
+// Kuberhealthy sets in checker pods.  The run deadline is a minute away.  The environment is restored and the fake
+// is stopped when the test ends.  Like t.Setenv, it can't be used in parallel tests.
+func NewServer(t testing.TB) *Server {
+	s := &Server{statusCode: http.StatusOK}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/externalCheckStatus", s.handleReport)
+	mux.HandleFunc("/cancel/", s.handleCancel)
+	s.server = httptest.NewServer(mux)
+	s.URL = s.server.URL + "/externalCheckStatus"
+	t.Cleanup(s.server.Close)
+
+	t.Setenv(external.KHReportingURL, s.URL)
+	t.Setenv(external.KHRunUUID, RunUUID)
+	t.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+	return s
+}
+
+// Reports returns the reports received so far, oldest first
+func (s *Server) Reports() []status.Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]status.Report{}, s.reports...)
+}
+
+// LastReport returns the latest report received.  False is returned when nothing was reported.
+func (s *Server) LastReport() (status.Report, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.reports) == 0 {
+		return status.Report{}, false
+	}
+	return s.reports[len(s.reports)-1], true
+}
+
+// SetStatusCode makes the fake answer reports with the supplied status code, such as
+// http.StatusInternalServerError to test how a check handles Kuberhealthy being unavailable.  Reports answered with
+// anything but http.StatusOK are not recorded.  The checkclient retries failed reports, so tests usually lower its
+// retries with checkclient.SetBackoffConfig.
+func (s *Server) SetStatusCode(code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statusCode = code
+}
+
+// Cancel makes the fake tell the checkclient that the run was canceled for the supplied reason
+func (s *Server) Cancel(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.canceled = true
+	s.cancelReason = reason
+}
+
+// handleReport records a report sent to /externalCheckStatus and answers the reachability probes of
+// checkclient.Preflight
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("kh-run-uuid") != RunUUID {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var report status.Report
+	err := json.NewDecoder(r.Body).Decode(&report)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.statusCode == http.StatusOK {
+		s.reports = append(s.reports, report)
+	}
+	w.WriteHeader(s.statusCode)
+}
+
+// handleCancel answers /cancel/{uuid} with whether the run was canceled
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	if strings.TrimPrefix(r.URL.Path, "/cancel/") != RunUUID {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	cancellation := status.Cancellation{Canceled: s.canceled, Reason: s.cancelReason}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cancellation)
+}
@@ -0,0 +1,62 @@
+package fake_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/checkclient/fake"
+)
+
+// TestServer ensures reports and cancellations go through the fake the way they would through kuberhealthy
+func TestServer(t *testing.T) {
+	s := fake.NewServer(t)
+
+	_, ok := s.LastReport()
+	if ok {
+		t.Fatal("expected no report before the check reported")
+	}
+
+	err := checkclient.ReportFailure([]string{"deployment never became ready"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = checkclient.ReportSuccess()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports := s.Reports()
+	if len(reports) != 2 || reports[0].OK || reports[0].Errors[0] != "deployment never became ready" || !reports[1].OK {
+		t.Fatalf("unexpected reports %+v", reports)
+	}
+
+	canceled, err := checkclient.Canceled()
+	if err != nil || canceled {
+		t.Fatalf("expected the run not to be canceled but got %t with error %v", canceled, err)
+	}
+	s.Cancel("replaced by a newer run")
+	canceled, err = checkclient.Canceled()
+	if err != nil || !canceled {
+		t.Fatalf("expected the run to be canceled but got %t with error %v", canceled, err)
+	}
+}
+
+// TestServerStatusCode ensures reports answered with an error status code fail and are not recorded
+func TestServerStatusCode(t *testing.T) {
+	defer checkclient.SetBackoffConfig(checkclient.DefaultBackoffConfig())
+	err := checkclient.SetBackoffConfig(checkclient.BackoffConfig{InitialInterval: time.Millisecond * 10, MaxInterval: time.Millisecond * 10, MaxElapsedTime: time.Millisecond * 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := fake.NewServer(t)
+	s.SetStatusCode(http.StatusInternalServerError)
+	err = checkclient.ReportSuccess()
+	if err == nil {
+		t.Fatal("expected the report to fail")
+	}
+	if len(s.Reports()) != 0 {
+		t.Fatalf("expected no reports to be recorded but got %+v", s.Reports())
+	}
+}
@@ -0,0 +1,233 @@
+package checkclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/reportpb"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// ReportOverGRPC makes reports be sent to the gRPC reporting service of Kuberhealthy instead of being posted as JSON
+// to the reporting URL.  Reports are still posted to the reporting URL when Kuberhealthy did not give the pod the
+// address of its gRPC reporting service.
+var ReportOverGRPC bool
+
+// GRPCDialOptions are the options used to connect to the gRPC reporting service, such as transport credentials when
+// the service is behind TLS.  Connections are made without TLS when it is empty.
+var GRPCDialOptions []grpc.DialOption
+
+// ErrGRPCNotOffered is returned by OpenProgressStream when Kuberhealthy did not give the pod the address of its gRPC
+// reporting service
+var ErrGRPCNotOffered = errors.New("kuberhealthy did not give this pod the address of its gRPC reporting service")
+
+// getGRPCReportingAddress fetches the address of the gRPC reporting service from the environment variable set by
+// Kuberhealthy, if it serves one
+func getGRPCReportingAddress() (string, bool) {
+	address := os.Getenv(external.KHGRPCReportingAddress)
+	return address, len(address) > 0
+}
+
+// dialGRPC connects to the gRPC reporting service at the supplied address
+func dialGRPC(address string) (*grpc.ClientConn, error) {
+	options := GRPCDialOptions
+	if len(options) == 0 {
+		options = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	conn, err := grpc.Dial(address, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the kuberhealthy grpc reporting service at %s: %w", address, err)
+	}
+	return conn, nil
+}
+
+// sendReportGRPC sends the report for a run to the gRPC reporting service at the supplied address, retrying until it
+// is delivered the same way postReport does.  The report is signed with the signing key of the run, if it has one, and
+// carries the trace context of the run in the traceparent metadata key, if it has one.
+func sendReportGRPC(ctx context.Context, s status.Report, uuid string, signingKey string, traceParent string, deadline time.Time, address string) error {
+
+	logDebug("Sending report over grpc with error length of:", len(s.Errors))
+	logDebug("Sending report over grpc with ok state of:", s.OK)
+
+	// reports say which version of the report schema they were written for, the same as reports posted as JSON
+	if s.ReportVersion == 0 {
+		s.ReportVersion = status.CurrentReportVersion
+	}
+
+	report := reportpb.FromStatus(s)
+	var signature string
+	if len(signingKey) > 0 {
+		b, err := reportpb.SigningBytes(report)
+		if err != nil {
+			return fmt.Errorf("error encoding report for its signature: %w", err)
+		}
+		signature = signReport(signingKey, b)
+	}
+
+	conn, err := dialGRPC(address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	client := reportpb.NewReportingClient(conn)
+	logInfo("Using kuberhealthy grpc reporting address: ", address)
+	logInfo("Using kuberhealthy run UUID: ", uuid)
+
+	// every attempt to deliver this report uses the same request ID so that it can be traced in kuberhealthy's logs
+	requestID := newRequestID()
+	logInfo("Using request ID: ", requestID)
+	md := metadata.Pairs("kh-run-uuid", uuid, external.KHRequestIDHeader, requestID)
+	if len(signature) > 0 {
+		md.Set(external.KHSignatureHeader, signature)
+	}
+	if len(external.TraceID(traceParent)) > 0 {
+		md.Set(external.TraceParentHeader, traceParent)
+	}
+	callCtx := metadata.NewOutgoingContext(ctx, md)
+
+	// when kuberhealthy is overloaded, it tells us how long to wait in the retry-after trailer
+	retryBackOff := &retryAfterBackOff{BackOff: newExponentialBackOff(), deadline: deadline}
+
+	err = backoff.Retry(func() error {
+		var header, trailer metadata.MD
+		_, err := client.SendReport(callCtx, report, grpc.Header(&header), grpc.Trailer(&trailer))
+		switch grpcstatus.Code(err) {
+		case codes.OK:
+			return nil
+		case codes.ResourceExhausted:
+			var retryAfter string
+			if values := trailer.Get("retry-after"); len(values) > 0 {
+				retryAfter = values[0]
+			}
+			delay, ok := parseRetryAfter(retryAfter, time.Now())
+			if !ok {
+				delay = defaultRetryAfter
+			}
+			logWarning("kuberhealthy asked us to back off. Retrying in ", delay)
+			retryBackOff.retryAfter = delay
+			return err
+		case codes.Unauthenticated:
+			logError("kuberhealthy refused the signature of the report")
+			return backoff.Permanent(ErrReportSignatureRejected)
+		case codes.FailedPrecondition:
+			logError("kuberhealthy reports that this run already timed out")
+			return backoff.Permanent(ErrReportLate)
+		case codes.InvalidArgument:
+			// kuberhealthy can't read this version of the report and will refuse it every time
+			var versions string
+			if values := header.Get(external.KHReportVersionsHeader); len(values) > 0 {
+				versions = values[0]
+			}
+			if len(versions) > 0 && !acceptsReportVersion(versions, s.ReportVersion) {
+				logError("kuberhealthy does not accept report version ", s.ReportVersion, ", only versions ", versions)
+				return backoff.Permanent(fmt.Errorf("%w: report version %d, kuberhealthy accepts versions %s", ErrReportVersionUnsupported, s.ReportVersion, versions))
+			}
+			logError("kuberhealthy refused the report:", err)
+			return nil
+		case codes.PermissionDenied:
+			// kuberhealthy will refuse this report however often it is sent, the same as reports answered with a 400
+			logError("kuberhealthy refused the report:", err)
+			return nil
+		}
+		logError("got a bad status from kuberhealthy:", err)
+		return err
+	}, backoff.WithContext(retryBackOff, ctx))
+	if err != nil && ctx.Err() != nil {
+		logError("stopped sending report over grpc to kuberhealthy with request ID ", requestID, ": ", ctx.Err())
+		return fmt.Errorf("stopped sending report to kuberhealthy with request id %s: %w (last error: %v)", requestID, ctx.Err(), err)
+	}
+	if err != nil {
+		logError("got an error sending report over grpc to kuberhealthy with request ID ", requestID, ": ", err)
+		return fmt.Errorf("bad grpc report to kuberhealthy with request id %s: %w", requestID, err)
+	}
+
+	logInfo("Kuberhealthy accepted the report sent over grpc to ", address, " for request ID ", requestID)
+	return nil
+}
+
+// ProgressStream sends how far along this run is to the gRPC reporting service of Kuberhealthy over a single stream.
+// It suits checks that report progress often, as progress sent too soon after the last recorded progress is dropped
+// by Kuberhealthy instead of being refused.  It is not safe for concurrent use.
+type ProgressStream struct {
+	conn   *grpc.ClientConn
+	stream reportpb.Reporting_StreamProgressClient
+}
+
+// OpenProgressStream opens a stream to send the progress of this run over.  The stream must be closed with Close once
+// the run is done reporting progress.  ErrGRPCNotOffered is returned when Kuberhealthy does not serve the gRPC
+// reporting service, in which case progress can be sent with ReportProgress instead.
+func OpenProgressStream(ctx context.Context) (*ProgressStream, error) {
+	address, ok := getGRPCReportingAddress()
+	if !ok {
+		return nil, ErrGRPCNotOffered
+	}
+	uuid, err := getKuberhealthyRunUUID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the kuberhealthy run uuid: %w", err)
+	}
+
+	conn, err := dialGRPC(address)
+	if err != nil {
+		return nil, err
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, "kh-run-uuid", uuid, external.KHRequestIDHeader, newRequestID())
+	stream, err := reportpb.NewReportingClient(conn).StreamProgress(ctx)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error opening progress stream to kuberhealthy: %w", err)
+	}
+	logDebug("Opened progress stream to ", address)
+	return &ProgressStream{conn: conn, stream: stream}, nil
+}
+
+// Send tells Kuberhealthy how far along this run is.  The percent must be from 0 to 100.  When Kuberhealthy ended the
+// stream, such as because the run timed out, the reason is returned and the stream should be closed.
+func (p *ProgressStream) Send(message string, percent int) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("progress percent must be between 0 and 100, got %d", percent)
+	}
+	logDebug("Streaming progress of ", percent, "% to kuberhealthy")
+	err := p.stream.Send(&reportpb.Progress{Message: message, Percent: int32(percent)})
+	if err == io.EOF {
+		// the server ended the stream, and the reason is only known once it is read
+		_, err = p.stream.CloseAndRecv()
+		return progressStreamError(err)
+	}
+	if err != nil {
+		return fmt.Errorf("error streaming progress to kuberhealthy: %w", err)
+	}
+	return nil
+}
+
+// Close ends the stream and returns how many of the progress updates sent over it Kuberhealthy recorded and dropped
+func (p *ProgressStream) Close() (int, int, error) {
+	defer p.conn.Close()
+	summary, err := p.stream.CloseAndRecv()
+	if err != nil {
+		return 0, 0, progressStreamError(err)
+	}
+	return int(summary.GetRecorded()), int(summary.GetDropped()), nil
+}
+
+// progressStreamError wraps the status a progress stream ended with
+func progressStreamError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if grpcstatus.Code(err) == codes.FailedPrecondition {
+		return fmt.Errorf("%w: %s", ErrProgressRefused, err)
+	}
+	return fmt.Errorf("progress stream to kuberhealthy ended: %w", err)
+}
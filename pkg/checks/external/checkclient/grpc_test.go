@@ -0,0 +1,210 @@
+package checkclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/reportpb"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// fakeReportingServer records what is sent to it and refuses the first report as overloaded
+type fakeReportingServer struct {
+	reportpb.UnimplementedReportingServer
+	mu        sync.Mutex
+	attempts  int
+	report    *reportpb.Report
+	md        metadata.MD
+	progress  []*reportpb.Progress
+	endStream error  // ends progress streams with this status after the first update, if set
+	versions  string // refuses reports of other versions than these, if set
+}
+
+func (f *fakeReportingServer) SendReport(ctx context.Context, in *reportpb.Report) (*reportpb.ReportResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts++
+	if len(f.versions) > 0 {
+		grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(external.KHReportVersionsHeader), f.versions))
+		if !acceptsReportVersion(f.versions, int(in.GetReportVersion())) {
+			return nil, grpcstatus.Error(codes.InvalidArgument, "unsupported report version")
+		}
+	}
+	if f.attempts == 1 {
+		grpc.SetTrailer(ctx, metadata.Pairs("retry-after", "1"))
+		return nil, grpcstatus.Error(codes.ResourceExhausted, "overloaded")
+	}
+	f.report = in
+	f.md, _ = metadata.FromIncomingContext(ctx)
+	return &reportpb.ReportResponse{RequestId: f.md.Get(external.KHRequestIDHeader)[0]}, nil
+}
+
+func (f *fakeReportingServer) StreamProgress(stream reportpb.Reporting_StreamProgressServer) error {
+	summary := &reportpb.ProgressSummary{}
+	for {
+		p, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(summary)
+		}
+		if err != nil {
+			return err
+		}
+		f.mu.Lock()
+		f.progress = append(f.progress, p)
+		f.mu.Unlock()
+		if f.endStream != nil {
+			return f.endStream
+		}
+		if p.GetPercent() == 50 {
+			summary.Dropped++
+			continue
+		}
+		summary.Recorded++
+	}
+}
+
+// startFakeReportingServer serves a fake gRPC reporting service and points the checkclient at it
+func startFakeReportingServer(t *testing.T, f *fakeReportingServer) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	server := grpc.NewServer()
+	reportpb.RegisterReportingServer(server, f)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	t.Setenv(external.KHGRPCReportingAddress, listener.Addr().String())
+	t.Setenv(external.KHRunUUID, "grpc-run-uuid")
+	t.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+}
+
+// TestReportFailureOverGRPC ensures reports are sent to the gRPC reporting service when it is offered and enabled,
+// that delays asked for in the retry-after trailer are honored, that reports are signed over their protobuf encoding and
+// that they carry the trace context of the run
+func TestReportFailureOverGRPC(t *testing.T) {
+	f := &fakeReportingServer{}
+	startFakeReportingServer(t, f)
+	t.Setenv(external.KHReportingURL, "http://127.0.0.1:1/externalCheckStatus")
+	t.Setenv(external.KHReportSigningKey, "run signing key")
+	traceParent := external.NewTraceParent()
+	t.Setenv(external.KHTraceParent, traceParent)
+
+	ReportOverGRPC = true
+	defer func() { ReportOverGRPC = false }()
+
+	err := ReportFailureWithMetadata([]string{"dns lookup timed out"}, map[string]string{"resolver": "10.0.0.10"})
+	if err != nil {
+		t.Fatalf("failed to report over grpc: %s", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.attempts != 2 {
+		t.Fatalf("expected the report to be retried once after the service was overloaded, got %d attempts", f.attempts)
+	}
+	if f.report.GetOk() || len(f.report.GetErrors()) != 1 || f.report.GetMetadata()["resolver"] != "10.0.0.10" {
+		t.Fatalf("expected the failure with its metadata, got %v", f.report)
+	}
+	if got := f.md.Get("kh-run-uuid"); len(got) != 1 || got[0] != "grpc-run-uuid" {
+		t.Fatalf("expected the run uuid in the call metadata, got %v", got)
+	}
+	b, err := reportpb.SigningBytes(f.report)
+	if err != nil {
+		t.Fatalf("failed to encode received report: %s", err)
+	}
+	signature := f.md.Get(external.KHSignatureHeader)
+	if len(signature) != 1 || !external.VerifyReportSignature("run signing key", b, signature[0]) {
+		t.Fatalf("expected the report to be signed over its protobuf encoding, got signature %v", signature)
+	}
+	if got := f.md.Get(external.TraceParentHeader); len(got) != 1 || got[0] != traceParent {
+		t.Fatalf("expected the trace context of the run in the call metadata, got %v", got)
+	}
+	if f.report.GetReportVersion() != status.CurrentReportVersion {
+		t.Fatalf("expected the report to carry version %d, got %d", status.CurrentReportVersion, f.report.GetReportVersion())
+	}
+}
+
+// TestReportVersionOverGRPC ensures reports sent over gRPC are not retried when Kuberhealthy does not accept their
+// version
+func TestReportVersionOverGRPC(t *testing.T) {
+	f := &fakeReportingServer{versions: "1"}
+	startFakeReportingServer(t, f)
+
+	ReportOverGRPC = true
+	defer func() { ReportOverGRPC = false }()
+
+	err := ReportSuccess()
+	if !errors.Is(err, ErrReportVersionUnsupported) || f.attempts != 1 {
+		t.Fatalf("expected the unsupported report version to not be retried, got %v after %d attempts", err, f.attempts)
+	}
+}
+
+// TestProgressStream ensures progress is streamed to the gRPC reporting service and that the stream summarizes what
+// was recorded and dropped
+func TestProgressStream(t *testing.T) {
+	f := &fakeReportingServer{}
+	startFakeReportingServer(t, f)
+
+	stream, err := OpenProgressStream(context.Background())
+	if err != nil {
+		t.Fatalf("failed to open progress stream: %s", err)
+	}
+	for _, percent := range []int{10, 50, 90} {
+		err = stream.Send("restoring snapshot", percent)
+		if err != nil {
+			t.Fatalf("failed to send progress: %s", err)
+		}
+	}
+	if stream.Send("restoring snapshot", 101) == nil {
+		t.Fatal("expected progress over 100 percent to be refused")
+	}
+	recorded, dropped, err := stream.Close()
+	if err != nil {
+		t.Fatalf("failed to close progress stream: %s", err)
+	}
+	if recorded != 2 || dropped != 1 {
+		t.Fatalf("expected 2 recorded and 1 dropped progress updates, got %d and %d", recorded, dropped)
+	}
+}
+
+// TestProgressStreamEnded ensures a stream ended by Kuberhealthy because the run timed out is reported as refused
+// progress, and that streams can not be opened when the gRPC reporting service is not offered
+func TestProgressStreamEnded(t *testing.T) {
+	f := &fakeReportingServer{endStream: grpcstatus.Error(codes.FailedPrecondition, "Gone")}
+	startFakeReportingServer(t, f)
+
+	stream, err := OpenProgressStream(context.Background())
+	if err != nil {
+		t.Fatalf("failed to open progress stream: %s", err)
+	}
+	err = stream.Send("restoring snapshot", 10)
+	for i := 0; err == nil && i < 50; i++ {
+		time.Sleep(time.Millisecond * 20)
+		err = stream.Send("restoring snapshot", 20)
+	}
+	if !errors.Is(err, ErrProgressRefused) {
+		t.Fatalf("expected the ended stream to refuse progress, got %v", err)
+	}
+	stream.Close()
+
+	os.Unsetenv(external.KHGRPCReportingAddress)
+	_, err = OpenProgressStream(context.Background())
+	if !errors.Is(err, ErrGRPCNotOffered) {
+		t.Fatalf("expected streams to need the grpc reporting address, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package checkclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// heartbeatsPerTimeout is how many heartbeats StartHeartbeats sends within each heartbeat timeout, so that a few can
+// be lost without the run timing out
+const heartbeatsPerTimeout = 3
+
+// GetHeartbeatTimeout returns how long this run may go without a heartbeat, as set by the heartbeatTimeout of the
+// khcheck or khjob.  ErrHeartbeatsNotExpected is returned when it is not set.
+func GetHeartbeatTimeout() (time.Duration, error) {
+	value := os.Getenv(external.KHHeartbeatTimeout)
+	if len(value) == 0 {
+		return 0, ErrHeartbeatsNotExpected
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return 0, fmt.Errorf("unable to parse %s %q", external.KHHeartbeatTimeout, value)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// SendHeartbeat tells Kuberhealthy that this run is still making progress.  The khcheck or khjob must set
+// heartbeatTimeout.  Each heartbeat pushes the deadline of the run back to the heartbeat timeout from now, up to
+// maxDeadlineExtension in total when it is set.  Returns the deadline of the run, which GetDeadline also returns
+// from then on.
+func SendHeartbeat() (time.Time, error) {
+	return SendHeartbeatWithContext(context.Background())
+}
+
+// SendHeartbeatWithContext is SendHeartbeat with a context that bounds the request to Kuberhealthy
+func SendHeartbeatWithContext(ctx context.Context) (time.Time, error) {
+	reportingURL, err := getKuberhealthyURL()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to fetch the kuberhealthy url: %w", err)
+	}
+	heartbeatURL, err := endpointURL(reportingURL, "heartbeat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	uuid, err := getKuberhealthyRunUUID()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to fetch the kuberhealthy run uuid: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, heartbeatURL, nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error creating http request: %w", err)
+	}
+	req.Header.Set("kh-run-uuid", uuid)
+
+	logDebug("Sending heartbeat to ", heartbeatURL)
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error sending heartbeat to kuberhealthy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusGone {
+		return time.Time{}, fmt.Errorf("%w: [%d] %s", ErrHeartbeatRefused, resp.StatusCode, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("bad status code from kuberhealthy heartbeat url: [%d] %s", resp.StatusCode, resp.Status)
+	}
+
+	heartbeat := status.Heartbeat{}
+	err = json.NewDecoder(resp.Body).Decode(&heartbeat)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error decoding heartbeat from kuberhealthy: %w", err)
+	}
+
+	// later calls to GetDeadline return the new deadline
+	err = os.Setenv(external.KHDeadline, strconv.FormatInt(heartbeat.Deadline, 10))
+	if err != nil {
+		logError("unable to update", external.KHDeadline+": "+err.Error())
+	}
+	return time.Unix(heartbeat.Deadline, 0), nil
+}
+
+// StartHeartbeats sends heartbeats in the background a few times per heartbeat timeout until the supplied context is
+// canceled.  Heartbeats that fail are logged and sent again at the next interval.  ErrHeartbeatsNotExpected is
+// returned when the khcheck or khjob does not set heartbeatTimeout.
+func StartHeartbeats(ctx context.Context) error {
+	timeout, err := GetHeartbeatTimeout()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(timeout / heartbeatsPerTimeout)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			_, err := SendHeartbeatWithContext(ctx)
+			if err != nil && ctx.Err() == nil {
+				logWarning("Failed to send heartbeat to kuberhealthy: ", err)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,76 @@
+package checkclient
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestSendHeartbeat ensures heartbeats move the deadline returned by GetDeadline and refused heartbeats are reported
+func TestSendHeartbeat(t *testing.T) {
+	deadline := time.Now().Add(time.Minute * 5).Unix()
+	refuse := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/heartbeat" || r.Header.Get("kh-run-uuid") != "heartbeat-run-uuid" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if refuse {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		json.NewEncoder(w).Encode(status.Heartbeat{Deadline: deadline})
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "heartbeat-run-uuid")
+	os.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+	newDeadline, err := SendHeartbeat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newDeadline.Unix() != deadline {
+		t.Fatalf("heartbeat returned deadline %v but expected %v", newDeadline, time.Unix(deadline, 0))
+	}
+	current, err := GetDeadline()
+	if err != nil || current.Unix() != deadline {
+		t.Fatalf("GetDeadline returned %v and error %v after the heartbeat but expected %v", current, err, time.Unix(deadline, 0))
+	}
+
+	refuse = true
+	_, err = SendHeartbeat()
+	if !errors.Is(err, ErrHeartbeatRefused) {
+		t.Fatalf("expected the heartbeat to be refused but got %v", err)
+	}
+}
+
+// TestGetHeartbeatTimeout ensures the heartbeat timeout is read from the environment
+func TestGetHeartbeatTimeout(t *testing.T) {
+	t.Setenv(external.KHHeartbeatTimeout, "")
+	_, err := GetHeartbeatTimeout()
+	if !errors.Is(err, ErrHeartbeatsNotExpected) {
+		t.Fatalf("expected heartbeats not to be expected but got %v", err)
+	}
+
+	t.Setenv(external.KHHeartbeatTimeout, "60")
+	timeout, err := GetHeartbeatTimeout()
+	if err != nil || timeout != time.Minute {
+		t.Fatalf("returned %s and error %v but expected 1m", timeout, err)
+	}
+
+	t.Setenv(external.KHHeartbeatTimeout, "soon")
+	_, err = GetHeartbeatTimeout()
+	if err == nil {
+		t.Fatal("expected an invalid heartbeat timeout to fail")
+	}
+}
@@ -0,0 +1,82 @@
+package checkclient
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Logger receives the log output of the checkclient.  Arguments are joined like fmt.Sprint.  The loggers of logrus
+// and zap's SugaredLogger satisfy it, so checks can route checkclient output through their own structured logging.
+type Logger interface {
+	Debug(args ...interface{})
+	Info(args ...interface{})
+	Warn(args ...interface{})
+	Error(args ...interface{})
+}
+
+// clientLogger holds the logger set by SetLogger
+var clientLogger Logger
+var clientLoggerMu sync.Mutex
+
+// SetLogger sends the log output of the checkclient to the supplied logger.  Output is discarded by default, unless
+// Debug is set.  Passing nil restores the default.
+func SetLogger(l Logger) {
+	clientLoggerMu.Lock()
+	defer clientLoggerMu.Unlock()
+	clientLogger = l
+}
+
+// currentLogger returns the logger set by SetLogger.  Without one, output is written with the standard library
+// logger when Debug is set and discarded otherwise.
+func currentLogger() Logger {
+	clientLoggerMu.Lock()
+	defer clientLoggerMu.Unlock()
+	if clientLogger != nil {
+		return clientLogger
+	}
+	if Debug {
+		return stdLogger{}
+	}
+	return noopLogger{}
+}
+
+// logDebug logs detail about what the checkclient is doing
+func logDebug(i ...interface{}) {
+	currentLogger().Debug(i...)
+}
+
+// logInfo logs noteworthy events, such as the run being canceled
+func logInfo(i ...interface{}) {
+	currentLogger().Info(i...)
+}
+
+// logWarning logs problems the checkclient worked around
+func logWarning(i ...interface{}) {
+	currentLogger().Warn(i...)
+}
+
+// logError logs problems that make a call fail
+func logError(i ...interface{}) {
+	currentLogger().Error(i...)
+}
+
+// noopLogger discards all output
+type noopLogger struct{}
+
+func (noopLogger) Debug(args ...interface{}) {}
+func (noopLogger) Info(args ...interface{})  {}
+func (noopLogger) Warn(args ...interface{})  {}
+func (noopLogger) Error(args ...interface{}) {}
+
+// stdLogger writes output with the standard library logger, the way the checkclient has when Debug is set
+type stdLogger struct{}
+
+func (stdLogger) Debug(args ...interface{}) { stdLog("DEBUG: ", args) }
+func (stdLogger) Info(args ...interface{})  { stdLog("INFO: ", args) }
+func (stdLogger) Warn(args ...interface{})  { stdLog("WARNING: ", args) }
+func (stdLogger) Error(args ...interface{}) { stdLog("ERROR: ", args) }
+
+func stdLog(level string, args []interface{}) {
+	log.Println("checkClient:", level+fmt.Sprint(args...))
+}
@@ -0,0 +1,57 @@
+package checkclient
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// logrus loggers can be set as the checkclient logger
+var _ Logger = logrus.New()
+var _ Logger = logrus.NewEntry(logrus.New())
+
+// recordingLogger keeps the messages it is given by level
+type recordingLogger struct {
+	messages map[string][]string
+}
+
+func (l *recordingLogger) record(level string, args []interface{}) {
+	l.messages[level] = append(l.messages[level], fmt.Sprint(args...))
+}
+
+func (l *recordingLogger) Debug(args ...interface{}) { l.record("debug", args) }
+func (l *recordingLogger) Info(args ...interface{})  { l.record("info", args) }
+func (l *recordingLogger) Warn(args ...interface{})  { l.record("warn", args) }
+func (l *recordingLogger) Error(args ...interface{}) { l.record("error", args) }
+
+// TestSetLogger ensures checkclient output goes to the logger that was set and that the default depends on Debug
+func TestSetLogger(t *testing.T) {
+	defer SetLogger(nil)
+	defer func(debug bool) { Debug = debug }(Debug)
+
+	Debug = false
+	if _, ok := currentLogger().(noopLogger); !ok {
+		t.Fatalf("expected output to be discarded by default but got logger %T", currentLogger())
+	}
+	Debug = true
+	if _, ok := currentLogger().(stdLogger); !ok {
+		t.Fatalf("expected the standard library logger when Debug is set but got logger %T", currentLogger())
+	}
+
+	l := &recordingLogger{messages: make(map[string][]string)}
+	SetLogger(l)
+	logDebug("Sending report with ok state of: ", true)
+	logError("kuberhealthy reporting URL was blank")
+	if len(l.messages["debug"]) != 1 || l.messages["debug"][0] != "Sending report with ok state of: true" {
+		t.Fatalf("unexpected debug messages %v", l.messages["debug"])
+	}
+	if len(l.messages["error"]) != 1 {
+		t.Fatalf("unexpected error messages %v", l.messages["error"])
+	}
+
+	SetLogger(nil)
+	if _, ok := currentLogger().(stdLogger); !ok {
+		t.Fatalf("expected the default logger to be restored but got logger %T", currentLogger())
+	}
+}
@@ -6,39 +6,173 @@ package checkclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/cenkalti/backoff"
+	guuid "github.com/google/uuid"
 
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
 )
 
 var (
-	// Debug can be used to enable output logging from the checkClient
+	// Debug can be used to enable output logging from the checkClient with the standard library logger.  It has no
+	// effect once a logger is set with SetLogger.
 	Debug bool
+
+	// CompressReports makes reports and bulk reports larger than a kilobyte be sent gzip compressed, so that checks
+	// reporting hundreds of errors stay under the request body limits of ingresses in front of Kuberhealthy.  Only
+	// enable it when reporting to a Kuberhealthy release that decompresses reports.
+	CompressReports bool
+
+	// ErrReportLate is returned when Kuberhealthy had already timed out the run before the report arrived.  The
+	// report is kept in the check's run history, but does not change the check's current state.
+	ErrReportLate = errors.New("kuberhealthy received the report after the run had already timed out")
+
+	// ErrRunNotFound is returned by GetReportStatus when Kuberhealthy has no record of the run
+	ErrRunNotFound = errors.New("kuberhealthy has no record of this run")
+
+	// ErrExtensionRefused is returned by RequestExtension when the check does not allow deadline extensions, the run
+	// has already been extended by the most allowed or the run is no longer running
+	ErrExtensionRefused = errors.New("kuberhealthy refused to extend the run deadline")
+
+	// ErrProgressRefused is returned by ReportProgress when the run is no longer running or progress was reported again
+	// too soon.  Progress can be reported once every few seconds.
+	ErrProgressRefused = errors.New("kuberhealthy refused the progress report")
+
+	// ErrHeartbeatRefused is returned by SendHeartbeat when the check does not expect heartbeats, the run has already
+	// been extended by the most allowed or the run is no longer running
+	ErrHeartbeatRefused = errors.New("kuberhealthy refused the heartbeat")
+
+	// ErrHeartbeatsNotExpected is returned by StartHeartbeats when the khcheck or khjob does not set a heartbeatTimeout
+	ErrHeartbeatsNotExpected = errors.New("the check does not expect heartbeats")
+
+	// ErrAlertNotDelivered is returned by GetAlertDelivery when no alert of the run has reached Kuberhealthy yet
+	ErrAlertNotDelivered = errors.New("no alert of this run has been delivered to kuberhealthy")
+
+	// ErrPreflightFailed is returned by Preflight when the pod is missing what it needs to report to Kuberhealthy
+	ErrPreflightFailed = errors.New("kuberhealthy preflight failed")
+
+	// ErrReportSignatureRejected is returned when Kuberhealthy requires signed reports and refused the signature of
+	// the report, such as because the pod was not given the signing key of its run
+	ErrReportSignatureRejected = errors.New("kuberhealthy refused the signature of the report")
+
+	// ErrReportVersionUnsupported is returned when Kuberhealthy can't read the version of the report schema the report
+	// was written for, such as when a check built with a newer checker client reports to an older Kuberhealthy
+	ErrReportVersionUnsupported = errors.New("kuberhealthy does not accept the version of the report")
+
+	// ErrNoCheckErrors is returned by ReportFailureDetailed and ReportFailureWithArtifacts when they are given no errors
+	// to report
+	ErrNoCheckErrors = errors.New("a failure report needs at least one check error")
+
+	// ErrNoWarnings is returned by ReportWarning when it is given no warnings to report
+	ErrNoWarnings = errors.New("a warning report needs at least one warning")
+
+	// ErrNoSubChecks is returned by ReportSubChecks when it is given no sub-checks to report
+	ErrNoSubChecks = errors.New("a sub-check report needs at least one sub-check")
 )
 
-// Use exponential backoff for retries
+// maxElapsedTime is how long failed reports are retried for unless SetBackoffConfig says otherwise
 const maxElapsedTime = time.Second * 30
 
+// sidecarQuitURLs are the local endpoints used to shut down known service mesh sidecars
+var sidecarQuitURLs = []string{
+	"http://127.0.0.1:15020/quitquitquit", // istio
+	"http://127.0.0.1:4191/shutdown",      // linkerd
+}
+
+// sidecarQuitTimeout is how long to wait on each sidecar to accept a shut down request
+const sidecarQuitTimeout = time.Second * 2
+
+// cancellationPollInterval is how often CancellationChannel asks kuberhealthy whether the run was canceled
+var cancellationPollInterval = time.Second * 10
+
+// defaultRetryAfter is how long to wait when kuberhealthy asks us to back off without saying for how long
+const defaultRetryAfter = time.Second * 5
+
+// retryAfterBackOff wraps a BackOff so that delays requested by kuberhealthy through the Retry-After header are used
+// instead of the next exponential delay.  Requested delays are honored until the run deadline, if one is known.
+type retryAfterBackOff struct {
+	backoff.BackOff
+	retryAfter time.Duration
+	deadline   time.Time
+}
+
+// NextBackOff returns the delay requested by kuberhealthy if there is one, or the next delay of the wrapped BackOff
+func (b *retryAfterBackOff) NextBackOff() time.Duration {
+	next := b.BackOff.NextBackOff()
+	if b.retryAfter <= 0 {
+		return next
+	}
+
+	delay := b.retryAfter
+	b.retryAfter = 0
+	if b.deadline.IsZero() {
+		if next == backoff.Stop {
+			return backoff.Stop
+		}
+		return delay
+	}
+	if time.Now().Add(delay).After(b.deadline) {
+		return backoff.Stop
+	}
+	return delay
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either a number of seconds or an HTTP date
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	if len(header) == 0 {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(header)
+	if err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	t, err := http.ParseTime(header)
+	if err != nil {
+		return 0, false
+	}
+	if t.Before(now) {
+		return 0, true
+	}
+	return t.Sub(now), true
+}
+
 // ReportSuccess reports a successful check run to the Kuberhealthy service. We
 // do not return an error here because failures will cause the managing
 // instance of Kuberhealthy to time out and show an error.
 func ReportSuccess() error {
-	writeLog("DEBUG: Reporting SUCCESS")
+	return ReportSuccessWithContext(context.Background())
+}
+
+// ReportSuccessWithContext reports a successful check run to the Kuberhealthy service.  Retries of the report stop
+// when the context is done, in which case the returned error wraps the context's error, or when the next retry would
+// be after the context's deadline.
+func ReportSuccessWithContext(ctx context.Context) error {
+	logDebug("Reporting SUCCESS")
 
 	// make a new report without errors
 	newReport := status.NewReport([]string{})
 
 	// send the payload
-	return sendReport(newReport)
+	return sendReport(ctx, newReport)
 }
 
 // ReportFailure reports that the external checker has found problems.  You may
@@ -47,89 +181,384 @@ func ReportSuccess() error {
 // because the managing instance of Kuberhealthy for this check will detect the
 // failure to report-in and raise an error upstream.
 func ReportFailure(errorMessages []string) error {
-	writeLog("DEBUG: Reporting FAILURE")
+	return ReportFailureWithContext(context.Background(), errorMessages)
+}
 
-	// make a new report without errors
+// ReportFailureWithContext reports that the external checker has found the supplied problems.  Retries of the report
+// stop when the context is done, in which case the returned error wraps the context's error, or when the next retry
+// would be after the context's deadline.
+func ReportFailureWithContext(ctx context.Context, errorMessages []string) error {
+	logDebug("Reporting FAILURE")
+
+	// make a new report with the errors
 	newReport := status.NewReport(errorMessages)
 
 	// send it
-	return sendReport(newReport)
+	return sendReport(ctx, newReport)
+}
+
+// ReportWarning reports that the check run passed but found signs of degradation, such as a certificate that expires
+// in 20 days.  The check is shown in a warning state on the status page and in its Prometheus metrics, but it is not
+// failed and does not make the cluster unhealthy.
+func ReportWarning(warnings []string) error {
+	return ReportWarningWithContext(context.Background(), warnings)
 }
 
-// writeLog writes a log entry if debugging is enabled
-func writeLog(i ...interface{}) {
-	if Debug {
-		log.Println("checkClient:", fmt.Sprint(i...))
+// ReportWarningWithContext reports the supplied warnings like ReportWarning.  Retries of the report stop when the
+// context is done.
+func ReportWarningWithContext(ctx context.Context, warnings []string) error {
+	logDebug("Reporting WARNING")
+
+	if len(warnings) == 0 {
+		return ErrNoWarnings
+	}
+	if err := status.ValidateWarnings(warnings); err != nil {
+		return fmt.Errorf("invalid warnings: %w", err)
 	}
+	return sendReport(ctx, status.NewWarningReport(warnings))
+}
+
+// ReportSubChecks reports the results of the named parts of a check run, such as each endpoint a DNS check resolved.
+// The run fails when any sub-check failed.  The sub-checks are stored in the khstate of the check and shown
+// individually under it on the status page and in its Prometheus metrics.  Failed sub-checks must say why they failed.
+func ReportSubChecks(subChecks []status.SubCheck) error {
+	return ReportSubChecksWithContext(context.Background(), subChecks)
+}
+
+// ReportSubChecksWithContext reports the supplied sub-checks like ReportSubChecks.  Retries of the report stop when
+// the context is done.
+func ReportSubChecksWithContext(ctx context.Context, subChecks []status.SubCheck) error {
+	logDebug("Reporting sub-checks")
+
+	if len(subChecks) == 0 {
+		return ErrNoSubChecks
+	}
+	if err := status.ValidateSubChecks(subChecks); err != nil {
+		return fmt.Errorf("invalid sub-checks: %w", err)
+	}
+	return sendReport(ctx, status.NewSubCheckReport(subChecks))
+}
+
+// ReportSuccessWithMetadata reports a successful check run along with details about the run, such as counts of
+// what was checked.  The metadata is shown with the state of the check on the status page and in its khstate.
+func ReportSuccessWithMetadata(metadata map[string]string) error {
+	logDebug("Reporting SUCCESS with metadata")
+
+	newReport := status.NewReport([]string{})
+	newReport.Metadata = metadata
+	return sendReport(context.Background(), newReport)
+}
+
+// ReportFailureWithMetadata reports that the external checker has found problems along with details about the run,
+// such as counts of what was checked.  The metadata is shown with the state of the check on the status page and in
+// its khstate.
+func ReportFailureWithMetadata(errorMessages []string, metadata map[string]string) error {
+	logDebug("Reporting FAILURE with metadata")
+
+	newReport := status.NewReport(errorMessages)
+	newReport.Metadata = metadata
+	return sendReport(context.Background(), newReport)
+}
+
+// ReportSuccessWithMetrics reports a successful check run along with measurements taken by the run, such as
+// latencies or object counts.  The metrics are stored in the khstate of the check and exposed as Prometheus gauges.
+// Metric names must start with a letter or underscore and contain only letters, digits and underscores.
+func ReportSuccessWithMetrics(metrics map[string]float64) error {
+	logDebug("Reporting SUCCESS with metrics")
+
+	if err := status.ValidateMetrics(metrics); err != nil {
+		return fmt.Errorf("invalid metrics: %w", err)
+	}
+	newReport := status.NewReport([]string{})
+	newReport.Metrics = metrics
+	return sendReport(context.Background(), newReport)
+}
+
+// ReportFailureWithMetrics reports that the external checker has found problems along with measurements taken by
+// the run, such as latencies or object counts.  The metrics are stored in the khstate of the check and exposed as
+// Prometheus gauges.
+func ReportFailureWithMetrics(errorMessages []string, metrics map[string]float64) error {
+	logDebug("Reporting FAILURE with metrics")
+
+	if err := status.ValidateMetrics(metrics); err != nil {
+		return fmt.Errorf("invalid metrics: %w", err)
+	}
+	newReport := status.NewReport(errorMessages)
+	newReport.Metrics = metrics
+	return sendReport(context.Background(), newReport)
+}
+
+// ReportFailureWithArtifacts reports that the external checker has found problems along with log excerpts or other
+// diagnostic output that explain them.  The artifacts are stored in the khstate of the check and served by the check
+// detail API, so that the cause of the failure can be seen without running the check again.  Content longer than
+// status.MaxArtifactBytes is cut down to its end before it is sent.
+func ReportFailureWithArtifacts(errorMessages []string, artifacts []status.Artifact) error {
+	logDebug("Reporting FAILURE with artifacts")
+
+	if len(errorMessages) == 0 {
+		return ErrNoCheckErrors
+	}
+	if err := status.ValidateArtifacts(artifacts); err != nil {
+		return fmt.Errorf("invalid artifacts: %w", err)
+	}
+	newReport := status.NewReport(errorMessages)
+	for _, a := range artifacts {
+		newReport.Artifacts = append(newReport.Artifacts, status.TruncateArtifact(a))
+	}
+	return sendReport(context.Background(), newReport)
+}
+
+// ReportFailureDetailed reports that the external checker has found the supplied problems along with the severity,
+// code and details of each of them.  The errors are shown with the state of the check on the status page and in its
+// khstate.  Errors without a severity are reported as critical.
+func ReportFailureDetailed(checkErrors []status.CheckError) error {
+	return ReportFailureDetailedWithContext(context.Background(), checkErrors)
+}
+
+// ReportFailureDetailedWithContext reports the supplied problems like ReportFailureDetailed.  Retries of the report
+// stop when the context is done.
+func ReportFailureDetailedWithContext(ctx context.Context, checkErrors []status.CheckError) error {
+	logDebug("Reporting FAILURE with error details")
+
+	if len(checkErrors) == 0 {
+		return ErrNoCheckErrors
+	}
+	for _, e := range checkErrors {
+		if err := e.Validate(); err != nil {
+			return fmt.Errorf("invalid check error: %w", err)
+		}
+	}
+	return sendReport(ctx, status.NewDetailedReport(checkErrors))
 }
 
 // sendReport marshals the report and sends it to the kuberhealthy endpoint
 // as shown in the environment variables.
-func sendReport(s status.Report) error {
+func sendReport(ctx context.Context, s status.Report) error {
+
+	// fetch the kh run UUID
+	uuid, err := getKuberhealthyRunUUID()
+	if err != nil {
+		return fmt.Errorf("failed to fetch the kuberhealthy run uuid: %w", err)
+	}
+
+	// reports are retried until the run deadline, if one is known
+	deadline, _ := GetDeadline()
+	traceParent, _ := GetTraceParent()
+	if address, ok := getGRPCReportingAddress(); ReportOverGRPC && ok {
+		err = sendReportGRPC(ctx, s, uuid, os.Getenv(external.KHReportSigningKey), traceParent, deadline, address)
+	} else {
+		err = postReport(ctx, s, uuid, os.Getenv(external.KHReportSigningKey), traceParent, deadline)
+	}
+	if err != nil {
+		return err
+	}
 
-	writeLog("DEBUG: Sending report with error length of:", len(s.Errors))
-	writeLog("DEBUG: Sending report with ok state of:", s.OK)
+	// the result is the last thing a check reports, so sidecars can be shut down once it has been delivered.  They
+	// are left running when it was not, so that the check can still retry over the network they provide.
+	quitSidecars()
+	return nil
+}
+
+// postReport sends the report for a run to the kuberhealthy reporting URL, retrying until it is delivered.  Retries
+// requested by kuberhealthy may go on until the supplied deadline, unless it is zero.  Retrying stops when the context
+// is done or its deadline is too close for another attempt.  The report is signed with the signing key of the run, if
+// it has one, and carries the trace context of the run, if it has one.
+func postReport(ctx context.Context, s status.Report, uuid string, signingKey string, traceParent string, deadline time.Time) error {
+
+	logDebug("Sending report with error length of:", len(s.Errors))
+	logDebug("Sending report with ok state of:", s.OK)
+
+	// reports say which version of the report schema they were written for, so that kuberhealthy can refuse the ones
+	// it can't read instead of dropping the fields it does not know
+	if s.ReportVersion == 0 {
+		s.ReportVersion = status.CurrentReportVersion
+	}
 
 	// marshal the request body
 	b, err := json.Marshal(s)
 	if err != nil {
-		writeLog("ERROR: Failed to marshal status JSON:", err)
+		logError("Failed to marshal status JSON:", err)
 		return fmt.Errorf("error mashaling status report json: %w", err)
 	}
+	signature := signReport(signingKey, b)
+	b, encoding, err := encodeReportBody(b)
+	if err != nil {
+		return err
+	}
 
 	// fetch the server url
 	url, err := getKuberhealthyURL()
 	if err != nil {
 		return fmt.Errorf("failed to fetch the kuberhealthy url: %w", err)
 	}
-	writeLog("INFO: Using kuberhealthy reporting URL: ", url)
+	logInfo("Using kuberhealthy reporting URL: ", url)
+	logInfo("Using kuberhealthy run UUID: ", uuid)
 
-	// fetch the kh run UUID
-	uuid, err := getKuberhealthyRunUUID()
-	if err != nil {
-		return fmt.Errorf("failed to fetch the kuberhealthy run uuid: %w", err)
-	}
-	writeLog("INFO: Using kuberhealthy run UUID: ", uuid)
+	// every attempt to deliver this report uses the same request ID so that it can be traced in kuberhealthy's logs
+	requestID := newRequestID()
+	logInfo("Using request ID: ", requestID)
 
-	// create the Kuberhealthy post request with the kh-run-uuid header
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(b))
-	if err != nil {
-		return fmt.Errorf("error creating http request: %w", err)
-	}
-	req.Header.Set("kh-run-uuid", uuid)
-	req.Header.Set("Content-Type", "application/json")
+	exponentialBackOff := newExponentialBackOff()
 
-	exponentialBackOff := backoff.NewExponentialBackOff()
-	exponentialBackOff.MaxElapsedTime = maxElapsedTime
+	// when kuberhealthy is overloaded, it tells us how long to wait.  Those waits may go beyond the usual maximum
+	// elapsed time, but not past the run deadline.
+	retryBackOff := &retryAfterBackOff{BackOff: exponentialBackOff, deadline: deadline}
 
-	client := &http.Client{}
+	client := httpClient()
 	// send to the server
 	var resp *http.Response
 	err = backoff.Retry(func() error {
-		var err error
-		writeLog("DEBUG: Making POST request to kuberhealthy:")
+		// create the Kuberhealthy post request with the kh-run-uuid header.  The request is rebuilt on every attempt
+		// because its body is consumed when sent.  Kuberhealthy ignores duplicate reports for the same run, so
+		// retrying a report that was received but whose response was lost is safe.
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(b))
+		if err != nil {
+			return backoff.Permanent(fmt.Errorf("error creating http request: %w", err))
+		}
+		req.Header.Set("kh-run-uuid", uuid)
+		req.Header.Set(external.KHRequestIDHeader, requestID)
+		req.Header.Set("Content-Type", "application/json")
+		if len(encoding) > 0 {
+			req.Header.Set("Content-Encoding", encoding)
+		}
+		if len(signature) > 0 {
+			req.Header.Set(external.KHSignatureHeader, signature)
+		}
+		if len(external.TraceID(traceParent)) > 0 {
+			req.Header.Set(external.TraceParentHeader, traceParent)
+		}
+
+		logDebug("Making POST request to kuberhealthy:")
 		resp, err = client.Do(req)
 		// retry on any errors
 		if err != nil {
 			return err
 		}
+		resp.Body.Close()
+		// kuberhealthy is overloaded and asked us to back off
+		if resp.StatusCode == http.StatusTooManyRequests {
+			delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			if !ok {
+				delay = defaultRetryAfter
+			}
+			logWarning("kuberhealthy asked us to back off. Retrying in ", delay)
+			retryBackOff.retryAfter = delay
+			return fmt.Errorf("kuberhealthy is overloaded: [%d] %s", resp.StatusCode, resp.Status)
+		}
+		// kuberhealthy requires signed reports and this one will never be accepted
+		if resp.StatusCode == http.StatusUnauthorized {
+			logError("kuberhealthy refused the signature of the report")
+			return backoff.Permanent(ErrReportSignatureRejected)
+		}
+		// kuberhealthy already timed out this run, so retrying will not help
+		if resp.StatusCode == http.StatusGone {
+			logError("kuberhealthy reports that this run already timed out")
+			return backoff.Permanent(ErrReportLate)
+		}
+		// kuberhealthy can't read this version of the report and will refuse it every time
+		versions := resp.Header.Get(external.KHReportVersionsHeader)
+		if resp.StatusCode == http.StatusBadRequest && len(versions) > 0 && !acceptsReportVersion(versions, s.ReportVersion) {
+			logError("kuberhealthy does not accept report version ", s.ReportVersion, ", only versions ", versions)
+			return backoff.Permanent(fmt.Errorf("%w: report version %d, kuberhealthy accepts versions %s", ErrReportVersionUnsupported, s.ReportVersion, versions))
+		}
+		// a kuberhealthy that predates versioned reports accepts newer reports, but drops the fields it does not know
+		if resp.StatusCode == http.StatusOK && len(versions) == 0 && s.ReportVersion > status.ReportVersion1 {
+			logWarning("kuberhealthy did not say which report versions it accepts. It may have dropped the fields added to reports after version ", status.ReportVersion1)
+		}
 		// retry on status codes that do not return a 200 or 400
 		if !(resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusBadRequest) {
-			writeLog("ERROR: got a bad status code from kuberhealthy:", resp.StatusCode, resp.Status)
+			logError("got a bad status code from kuberhealthy:", resp.StatusCode, resp.Status)
 			return fmt.Errorf("bad status code from kuberhealthy status reporting url: [%d] %s ", resp.StatusCode, resp.Status)
 		}
 		return nil
-	}, exponentialBackOff)
+	}, backoff.WithContext(retryBackOff, ctx))
+	if err != nil && ctx.Err() != nil {
+		logError("stopped sending POST to kuberhealthy with request ID ", requestID, ": ", ctx.Err())
+		return fmt.Errorf("stopped sending report to kuberhealthy with request id %s: %w (last error: %v)", requestID, ctx.Err(), err)
+	}
 	if err != nil {
-		writeLog("ERROR: got an error sending POST to kuberhealthy:", err)
-		return fmt.Errorf("bad POST request to kuberhealthy status reporting url: %w", err)
+		logError("got an error sending POST to kuberhealthy with request ID ", requestID, ": ", err)
+		return fmt.Errorf("bad POST request to kuberhealthy status reporting url with request id %s: %w", requestID, err)
 	}
 
-	writeLog("INFO: Got a good http return status code from kuberhealthy URL:", url)
+	logInfo("Got a good http return status code from kuberhealthy URL:", url, " for request ID ", requestID)
 
 	return err
 }
 
+// acceptsReportVersion tells if the report versions kuberhealthy answered with include the supplied version
+func acceptsReportVersion(versions string, version int) bool {
+	for _, v := range status.ParseReportVersions(versions) {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// GetReportStatus asks Kuberhealthy what it has recorded for this check run.  This can be used to verify that a
+// report was delivered and accepted.
+func GetReportStatus() (status.RunStatus, error) {
+	runStatus := status.RunStatus{}
+
+	url, err := getKuberhealthyURL()
+	if err != nil {
+		return runStatus, fmt.Errorf("failed to fetch the kuberhealthy url: %w", err)
+	}
+
+	uuid, err := getKuberhealthyRunUUID()
+	if err != nil {
+		return runStatus, fmt.Errorf("failed to fetch the kuberhealthy run uuid: %w", err)
+	}
+
+	statusURL := strings.TrimSuffix(url, "/") + "/" + uuid
+	logDebug("Fetching run status from kuberhealthy: ", statusURL)
+	resp, err := httpClient().Get(statusURL)
+	if err != nil {
+		return runStatus, fmt.Errorf("error fetching run status from kuberhealthy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return runStatus, ErrRunNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return runStatus, fmt.Errorf("bad status code from kuberhealthy run status url: [%d] %s", resp.StatusCode, resp.Status)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&runStatus)
+	if err != nil {
+		return runStatus, fmt.Errorf("error decoding run status from kuberhealthy: %w", err)
+	}
+	return runStatus, nil
+}
+
+// quitSidecars shuts down service mesh sidecars in the checker pod when kuberhealthy asks for it.  Sidecars keep
+// running after the check exits, which keeps the checker pod from completing.  Errors are only logged because
+// most pods will not be running every kind of sidecar.
+func quitSidecars() {
+	if os.Getenv(external.KHSidecarQuit) != "true" {
+		return
+	}
+
+	client := &http.Client{Timeout: sidecarQuitTimeout}
+	for _, quitURL := range sidecarQuitURLs {
+		logDebug("Asking sidecar to shut down at ", quitURL)
+		resp, err := client.Post(quitURL, "", nil)
+		if err != nil {
+			logDebug("Failed to shut down sidecar at ", quitURL, ": ", err)
+			continue
+		}
+		resp.Body.Close()
+		logInfo("Sidecar at ", quitURL, " responded to shut down request with ", resp.Status)
+	}
+}
+
+// newRequestID makes a new ID for tracing a report through kuberhealthy
+func newRequestID() string {
+	return "checkclient-" + guuid.New().String()
+}
+
 // getKuberhealthyURL fetches the URL that we need to send our external checker
 // status report to from the environment variables
 func getKuberhealthyURL() (string, error) {
@@ -138,7 +567,7 @@ func getKuberhealthyURL() (string, error) {
 
 	// check the length of the reporting url to make sure we pulled one properly
 	if len(reportingURL) < 1 {
-		writeLog("ERROR: kuberhealthy reporting URL from environment variable", external.KHReportingURL, "was blank")
+		logError("kuberhealthy reporting URL from environment variable", external.KHReportingURL, "was blank")
 		return "", fmt.Errorf("fetched %s environment variable but it was blank", external.KHReportingURL)
 	}
 
@@ -153,7 +582,7 @@ func getKuberhealthyRunUUID() (string, error) {
 
 	// check the length of the UUID to make sure we pulled one properly
 	if len(khRunUUID) < 1 {
-		writeLog("ERROR: kuberhealthy run UUID from environment variable", external.KHRunUUID, "was blank")
+		logError("kuberhealthy run UUID from environment variable", external.KHRunUUID, "was blank")
 		return "", fmt.Errorf("fetched %s environment variable but it was blank", external.KHRunUUID)
 	}
 
@@ -166,15 +595,511 @@ func GetDeadline() (time.Time, error) {
 	unixDeadline := os.Getenv(external.KHDeadline)
 
 	if len(unixDeadline) < 1 {
-		writeLog("ERROR: kuberhealthy check deadline from environment variable", external.KHDeadline, "was blank")
+		logError("kuberhealthy check deadline from environment variable", external.KHDeadline, "was blank")
 		return time.Time{}, fmt.Errorf("fetched %s environment variable but it was blank", external.KHDeadline)
 	}
 
 	unixDeadlineInt, err := strconv.Atoi(unixDeadline)
 	if err != nil {
-		writeLog("ERROR: unable to parse", external.KHDeadline+": "+err.Error())
+		logError("unable to parse", external.KHDeadline+": "+err.Error())
 		return time.Time{}, fmt.Errorf("unable to parse %s: %s", external.KHDeadline, err.Error())
 	}
 
 	return time.Unix(int64(unixDeadlineInt), 0), nil
 }
+
+// GetTraceParent fetches the W3C trace context that Kuberhealthy started for this run from the KH_TRACEPARENT
+// environment variable.  Checks instrumented with tracing can use it as the parent of their own spans so that the run
+// shows up as a single trace.  Reports are sent with it without any changes to the check.  Returns false when
+// Kuberhealthy gave the run no trace context or it is not a valid traceparent.
+func GetTraceParent() (string, bool) {
+	traceParent := os.Getenv(external.KHTraceParent)
+	if len(external.TraceID(traceParent)) == 0 {
+		return "", false
+	}
+	return traceParent, true
+}
+
+// GetServiceAccountToken returns the bound service account token that Kuberhealthy projected into the checker pod for
+// the supplied audience.  The audience must be listed in the serviceAccountTokens of the khcheck or khjob.  The
+// kubelet rotates tokens before they expire, so the token should be fetched again for each request instead of being
+// cached.
+func GetServiceAccountToken(audience string) (string, error) {
+	dir := os.Getenv(external.KHServiceAccountTokenDir)
+	if len(dir) < 1 {
+		return "", fmt.Errorf("fetched %s environment variable but it was blank", external.KHServiceAccountTokenDir)
+	}
+
+	tokenPath := filepath.Join(dir, external.ServiceAccountTokenFile(audience))
+	token, err := ioutil.ReadFile(tokenPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read service account token for audience %s: %w", audience, err)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// GetRunNamespace returns the ephemeral namespace Kuberhealthy created for this run when the khcheck or khjob sets
+// isolatedNamespace.  Test resources created in it are deleted along with the namespace once the run is over.
+func GetRunNamespace() (string, error) {
+	namespace := os.Getenv(external.KHRunNamespace)
+	if len(namespace) < 1 {
+		return "", fmt.Errorf("fetched %s environment variable but it was blank. Is isolatedNamespace enabled on the check?", external.KHRunNamespace)
+	}
+	return namespace, nil
+}
+
+// endpointURL returns the URL of a kuberhealthy endpoint that sits next to the reporting URL
+func endpointURL(reportingURL string, endpoint ...string) (string, error) {
+	u, err := url.Parse(reportingURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse kuberhealthy reporting url %s: %w", reportingURL, err)
+	}
+	u.Path = path.Join(append([]string{"/", path.Dir(strings.TrimSuffix(u.Path, "/"))}, endpoint...)...)
+	return u.String(), nil
+}
+
+// RequestExtension asks Kuberhealthy to push back the deadline of this run by the supplied duration.  The khcheck or
+// khjob must allow extensions with maxDeadlineExtension, and a run can't be extended by more than that in total, so
+// less than requested may be granted.  Returns the new deadline, which GetDeadline also returns from then on.
+func RequestExtension(d time.Duration) (time.Time, error) {
+	reportingURL, err := getKuberhealthyURL()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to fetch the kuberhealthy url: %w", err)
+	}
+	extendURL, err := endpointURL(reportingURL, "extendDeadline")
+	if err != nil {
+		return time.Time{}, err
+	}
+	uuid, err := getKuberhealthyRunUUID()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to fetch the kuberhealthy run uuid: %w", err)
+	}
+
+	b, err := json.Marshal(status.ExtensionRequest{Seconds: int64((d + time.Second - 1) / time.Second)})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error marshaling extension request json: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, extendURL, bytes.NewBuffer(b))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error creating http request: %w", err)
+	}
+	req.Header.Set("kh-run-uuid", uuid)
+	req.Header.Set("Content-Type", "application/json")
+
+	logDebug("Requesting a deadline extension of ", d, " from ", extendURL)
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error requesting deadline extension from kuberhealthy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusGone {
+		return time.Time{}, fmt.Errorf("%w: [%d] %s", ErrExtensionRefused, resp.StatusCode, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("bad status code from kuberhealthy deadline extension url: [%d] %s", resp.StatusCode, resp.Status)
+	}
+
+	extension := status.Extension{}
+	err = json.NewDecoder(resp.Body).Decode(&extension)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error decoding deadline extension from kuberhealthy: %w", err)
+	}
+
+	// later calls to GetDeadline return the new deadline
+	err = os.Setenv(external.KHDeadline, strconv.FormatInt(extension.Deadline, 10))
+	if err != nil {
+		logError("unable to update", external.KHDeadline+": "+err.Error())
+	}
+	logInfo("Kuberhealthy extended the run deadline by ", extension.Granted, " seconds")
+	return time.Unix(extension.Deadline, 0), nil
+}
+
+// ReportProgress tells Kuberhealthy how far along this run is before its result is reported.  The message and percent,
+// which must be from 0 to 100, are shown on the status page under the check until the run reports its result.  Progress
+// is meant for checks that run for minutes, and is refused when reported more than once every few seconds.
+func ReportProgress(message string, percent int) error {
+	return ReportProgressWithContext(context.Background(), message, percent)
+}
+
+// ReportProgressWithContext is ReportProgress with a context that bounds the request to Kuberhealthy
+func ReportProgressWithContext(ctx context.Context, message string, percent int) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("progress percent must be between 0 and 100, got %d", percent)
+	}
+	reportingURL, err := getKuberhealthyURL()
+	if err != nil {
+		return fmt.Errorf("failed to fetch the kuberhealthy url: %w", err)
+	}
+	progressURL, err := endpointURL(reportingURL, "reportProgress")
+	if err != nil {
+		return err
+	}
+	uuid, err := getKuberhealthyRunUUID()
+	if err != nil {
+		return fmt.Errorf("failed to fetch the kuberhealthy run uuid: %w", err)
+	}
+
+	b, err := json.Marshal(status.ProgressReport{Message: message, Percent: percent})
+	if err != nil {
+		return fmt.Errorf("error marshaling progress report json: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, progressURL, bytes.NewBuffer(b))
+	if err != nil {
+		return fmt.Errorf("error creating http request: %w", err)
+	}
+	req.Header.Set("kh-run-uuid", uuid)
+	req.Header.Set("Content-Type", "application/json")
+
+	logDebug("Reporting progress of ", percent, "% to ", progressURL)
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("error reporting progress to kuberhealthy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusGone {
+		return fmt.Errorf("%w: [%d] %s", ErrProgressRefused, resp.StatusCode, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad status code from kuberhealthy progress url: [%d] %s", resp.StatusCode, resp.Status)
+	}
+	return nil
+}
+
+// GetCancellation asks Kuberhealthy whether it still wants the result of this run.  Runs are canceled when a newer run
+// replaces them, when their check is removed or stopped, or when they time out.  Checks that run for a long time or
+// create test resources can poll this to clean up and exit early instead of finishing work that will be discarded.
+func GetCancellation() (status.Cancellation, error) {
+	cancellation := status.Cancellation{}
+
+	reportingURL, err := getKuberhealthyURL()
+	if err != nil {
+		return cancellation, fmt.Errorf("failed to fetch the kuberhealthy url: %w", err)
+	}
+	uuid, err := getKuberhealthyRunUUID()
+	if err != nil {
+		return cancellation, fmt.Errorf("failed to fetch the kuberhealthy run uuid: %w", err)
+	}
+	cancelURL, err := endpointURL(reportingURL, "cancel", uuid)
+	if err != nil {
+		return cancellation, err
+	}
+
+	logDebug("Fetching run cancellation from kuberhealthy: ", cancelURL)
+	resp, err := httpClient().Get(cancelURL)
+	if err != nil {
+		return cancellation, fmt.Errorf("error fetching run cancellation from kuberhealthy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return cancellation, ErrRunNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return cancellation, fmt.Errorf("bad status code from kuberhealthy run cancellation url: [%d] %s", resp.StatusCode, resp.Status)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&cancellation)
+	if err != nil {
+		return cancellation, fmt.Errorf("error decoding run cancellation from kuberhealthy: %w", err)
+	}
+	return cancellation, nil
+}
+
+// GetAlertDelivery asks Kuberhealthy whether an alert carrying the UUID of this run in its kuberhealthy_run_uuid label
+// has been delivered to its Alertmanager webhook receiver at /alertReceiver.  Checks of the alerting pipeline fire
+// such an alert and poll this until it arrives.
+func GetAlertDelivery() (status.AlertDelivery, error) {
+	delivery := status.AlertDelivery{}
+
+	reportingURL, err := getKuberhealthyURL()
+	if err != nil {
+		return delivery, fmt.Errorf("failed to fetch the kuberhealthy url: %w", err)
+	}
+	uuid, err := getKuberhealthyRunUUID()
+	if err != nil {
+		return delivery, fmt.Errorf("failed to fetch the kuberhealthy run uuid: %w", err)
+	}
+	deliveryURL, err := endpointURL(reportingURL, "alertReceiver", uuid)
+	if err != nil {
+		return delivery, err
+	}
+
+	logDebug("Fetching alert delivery from kuberhealthy: ", deliveryURL)
+	resp, err := httpClient().Get(deliveryURL)
+	if err != nil {
+		return delivery, fmt.Errorf("error fetching alert delivery from kuberhealthy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return delivery, ErrAlertNotDelivered
+	}
+	if resp.StatusCode != http.StatusOK {
+		return delivery, fmt.Errorf("bad status code from kuberhealthy alert delivery url: [%d] %s", resp.StatusCode, resp.Status)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&delivery)
+	if err != nil {
+		return delivery, fmt.Errorf("error decoding alert delivery from kuberhealthy: %w", err)
+	}
+	return delivery, nil
+}
+
+// Canceled indicates that Kuberhealthy no longer wants the result of this run.  See GetCancellation.
+func Canceled() (bool, error) {
+	cancellation, err := GetCancellation()
+	if err != nil {
+		return false, err
+	}
+	if cancellation.Canceled {
+		logInfo("Kuberhealthy canceled this run: ", cancellation.Reason)
+	}
+	return cancellation.Canceled, nil
+}
+
+// CancellationChannel returns a channel that is closed once Kuberhealthy no longer wants the result of this run, so
+// that long running checks can select on it and stop early.  The channel is closed when GetCancellation reports the
+// run canceled, when the run deadline passes or when the pod receives SIGTERM.  The first SIGTERM only closes the
+// channel, so the check must exit on its own once it has cleaned up.  Watching stops when the supplied context is
+// canceled.  An error is returned when the pod was not started by Kuberhealthy.
+func CancellationChannel(ctx context.Context) (<-chan struct{}, error) {
+	_, err := getKuberhealthyURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the kuberhealthy url: %w", err)
+	}
+	_, err = getKuberhealthyRunUUID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the kuberhealthy run uuid: %w", err)
+	}
+
+	canceled := make(chan struct{})
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGTERM)
+	ticker := time.NewTicker(cancellationPollInterval)
+
+	go func() {
+		defer signal.Stop(sigChan)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigChan:
+				logInfo("Received SIGTERM. Kuberhealthy canceled this run")
+				close(canceled)
+				return
+			case <-ticker.C:
+			}
+
+			// the deadline is read again each time because it moves when the run is extended
+			deadline, err := GetDeadline()
+			if err == nil && time.Now().After(deadline) {
+				logInfo("The deadline of this run has passed")
+				close(canceled)
+				return
+			}
+
+			// kuberhealthy may be briefly unreachable, so errors are only logged and polling carries on
+			isCanceled, err := Canceled()
+			if err != nil {
+				logDebug("Failed to fetch run cancellation from kuberhealthy: ", err)
+				continue
+			}
+			if isCanceled {
+				close(canceled)
+				return
+			}
+		}
+	}()
+
+	return canceled, nil
+}
+
+// ReportBulk sends a batch of reports for many checks in one request.  This is meant for agent style checkers that
+// evaluate many checks each cycle.  Each report must carry the run UUID its check currently expects.  Returns how
+// each report was handled.  Reports answered with http.StatusTooManyRequests were not recorded and should be sent
+// again later.
+func ReportBulk(reports []status.BulkReport) ([]status.BulkReportResult, error) {
+	reportingURL, err := getKuberhealthyURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the kuberhealthy url: %w", err)
+	}
+	bulkURL, err := endpointURL(reportingURL, "bulkCheckStatus")
+	if err != nil {
+		return nil, err
+	}
+
+	versioned := make([]status.BulkReport, len(reports))
+	for i, report := range reports {
+		if report.Report.ReportVersion == 0 {
+			report.Report.ReportVersion = status.CurrentReportVersion
+		}
+		versioned[i] = report
+	}
+	b, err := json.Marshal(versioned)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling bulk reports json: %w", err)
+	}
+	signature := signReport(os.Getenv(external.KHReportSigningKey), b)
+	b, encoding, err := encodeReportBody(b)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, bulkURL, bytes.NewBuffer(b))
+	if err != nil {
+		return nil, fmt.Errorf("error creating http request: %w", err)
+	}
+	// the run UUID of this pod identifies it to kuberhealthy when it has one
+	uuid, err := getKuberhealthyRunUUID()
+	if err == nil {
+		req.Header.Set("kh-run-uuid", uuid)
+	}
+	req.Header.Set(external.KHRequestIDHeader, newRequestID())
+	req.Header.Set("Content-Type", "application/json")
+	if len(encoding) > 0 {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	if len(signature) > 0 {
+		req.Header.Set(external.KHSignatureHeader, signature)
+	}
+
+	logDebug("Sending ", len(reports), " reports to ", bulkURL)
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending bulk reports to kuberhealthy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("%w: [%d] %s", ErrReportSignatureRejected, resp.StatusCode, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status code from kuberhealthy bulk report url: [%d] %s", resp.StatusCode, resp.Status)
+	}
+
+	var results []status.BulkReportResult
+	err = json.NewDecoder(resp.Body).Decode(&results)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding bulk report results from kuberhealthy: %w", err)
+	}
+	return results, nil
+}
+
+// Resident is a registration of this pod as a resident checker of a resident check.  Resident checkers keep running
+// between runs, taking each run from Kuberhealthy with NextRun and reporting it with ReportSuccess or ReportFailure.
+type Resident struct {
+	Check     string
+	Namespace string
+}
+
+// RegisterResident registers this pod with Kuberhealthy as a resident checker of the named khcheck.  The pod must run
+// in the namespace of the khcheck and carry the comcast.github.io/check-name annotation naming it.
+func RegisterResident(check string) (*Resident, error) {
+	reportingURL, err := getKuberhealthyURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the kuberhealthy url: %w", err)
+	}
+	registerURL, err := endpointURL(reportingURL, "resident", "register")
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(status.ResidentRegistration{Check: check})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling resident registration json: %w", err)
+	}
+	logDebug("Registering as a resident checker of ", check, " with ", registerURL)
+	resp, err := httpClient().Post(registerURL, "application/json", bytes.NewBuffer(b))
+	if err != nil {
+		return nil, fmt.Errorf("error registering resident checker with kuberhealthy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status code from kuberhealthy resident register url: [%d] %s", resp.StatusCode, resp.Status)
+	}
+
+	registration := status.ResidentRegistration{}
+	err = json.NewDecoder(resp.Body).Decode(&registration)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding resident registration from kuberhealthy: %w", err)
+	}
+	return &Resident{Check: registration.Check, Namespace: registration.Namespace}, nil
+}
+
+// NextRun waits for Kuberhealthy to hand this resident checker its next run.  The registration is renewed if
+// Kuberhealthy has forgotten it, such as after a restart.  Waiting stops with an error when the context is done.
+func (r *Resident) NextRun(ctx context.Context) (status.RunRequest, error) {
+	run := status.RunRequest{}
+
+	reportingURL, err := getKuberhealthyURL()
+	if err != nil {
+		return run, fmt.Errorf("failed to fetch the kuberhealthy url: %w", err)
+	}
+	runsURL, err := endpointURL(reportingURL, "resident", "runs")
+	if err != nil {
+		return run, err
+	}
+	runsURL += "?" + url.Values{"check": {r.Check}, "namespace": {r.Namespace}}.Encode()
+
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, runsURL, nil)
+		if err != nil {
+			return run, fmt.Errorf("error creating http request: %w", err)
+		}
+		resp, err := httpClient().Do(req)
+		if err != nil {
+			return run, fmt.Errorf("error fetching the next run from kuberhealthy: %w", err)
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			err = json.NewDecoder(resp.Body).Decode(&run)
+			resp.Body.Close()
+			if err != nil {
+				return run, fmt.Errorf("error decoding the next run from kuberhealthy: %w", err)
+			}
+			return run, nil
+		case http.StatusNoContent:
+			resp.Body.Close()
+		case http.StatusForbidden, http.StatusNotFound:
+			resp.Body.Close()
+			logDebug("Kuberhealthy no longer knows this resident checker, registering again")
+			registration, err := RegisterResident(r.Check)
+			if err != nil {
+				return run, err
+			}
+			*r = *registration
+		default:
+			resp.Body.Close()
+			return run, fmt.Errorf("bad status code from kuberhealthy resident runs url: [%d] %s", resp.StatusCode, resp.Status)
+		}
+
+		if ctx.Err() != nil {
+			return run, ctx.Err()
+		}
+	}
+}
+
+// ReportSuccess reports that a run handed to this resident checker succeeded
+func (r *Resident) ReportSuccess(run status.RunRequest) error {
+	logDebug("Reporting SUCCESS for run ", run.UUID)
+	return postReport(context.Background(), status.NewReport([]string{}), run.UUID, run.SigningKey, run.TraceParent, runDeadline(run))
+}
+
+// ReportFailure reports that a run handed to this resident checker found the supplied problems
+func (r *Resident) ReportFailure(run status.RunRequest, errorMessages []string) error {
+	logDebug("Reporting FAILURE for run ", run.UUID)
+	return postReport(context.Background(), status.NewReport(errorMessages), run.UUID, run.SigningKey, run.TraceParent, runDeadline(run))
+}
+
+// runDeadline returns the deadline of a run handed to a resident checker, or the zero time if it has none
+func runDeadline(run status.RunRequest) time.Time {
+	if run.Deadline == 0 {
+		return time.Time{}
+	}
+	return time.Unix(run.Deadline, 0)
+}
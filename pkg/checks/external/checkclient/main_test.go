@@ -1,11 +1,26 @@
 package checkclient
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/cenkalti/backoff"
+
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
 )
 
 // TestGetKuberhealthyURL ensures that KH_REPORTING_URL env var can be fetched
@@ -103,4 +118,833 @@ func TestGetDeadline(t *testing.T) {
 	}
 }
 
+// TestParseRetryAfter ensures that both forms of the Retry-After header can be parsed
+func TestParseRetryAfter(t *testing.T) {
+
+	now := time.Date(2021, time.April, 13, 12, 0, 0, 0, time.UTC)
+	var testCases = []struct {
+		input string
+		delay time.Duration
+		ok    bool
+	}{
+		{"5", time.Second * 5, true},
+		{"0", 0, true},
+		{"Tue, 13 Apr 2021 12:00:30 GMT", time.Second * 30, true},
+		{"Tue, 13 Apr 2021 11:59:00 GMT", 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tc := range testCases {
+		delay, ok := parseRetryAfter(tc.input, now)
+		if ok != tc.ok || delay != tc.delay {
+			t.Fatalf("parseRetryAfter(`%s`) resulted in %s %t but expected %s %t", tc.input, delay, ok, tc.delay, tc.ok)
+		}
+		t.Logf("parseRetryAfter(`%s`) resulted in %s correctly", tc.input, delay)
+	}
+}
+
+// TestRetryAfterBackOff ensures that delays requested by kuberhealthy are used until the run deadline
+func TestRetryAfterBackOff(t *testing.T) {
+
+	// without a requested delay, the wrapped backoff is used
+	b := &retryAfterBackOff{BackOff: &backoff.ConstantBackOff{Interval: time.Second}}
+	if next := b.NextBackOff(); next != time.Second {
+		t.Fatalf("NextBackOff resulted in %s but expected %s", next, time.Second)
+	}
+
+	// a requested delay is used once
+	b.retryAfter = time.Second * 10
+	if next := b.NextBackOff(); next != time.Second*10 {
+		t.Fatalf("NextBackOff resulted in %s but expected %s", next, time.Second*10)
+	}
+	if next := b.NextBackOff(); next != time.Second {
+		t.Fatalf("NextBackOff resulted in %s after the requested delay but expected %s", next, time.Second)
+	}
+
+	// a requested delay is used even after the wrapped backoff gives up, as long as the deadline allows it
+	b = &retryAfterBackOff{BackOff: &backoff.StopBackOff{}, deadline: time.Now().Add(time.Minute)}
+	b.retryAfter = time.Second * 10
+	if next := b.NextBackOff(); next != time.Second*10 {
+		t.Fatalf("NextBackOff resulted in %s before the deadline but expected %s", next, time.Second*10)
+	}
+	b.retryAfter = time.Minute * 2
+	if next := b.NextBackOff(); next != backoff.Stop {
+		t.Fatalf("NextBackOff resulted in %s past the deadline but expected to stop", next)
+	}
+}
+
 //TODO: TestSendReport
+
+// TestReportSuccessIPv6 ensures that reports can be sent to a kuberhealthy reporting URL with an IPv6 address
+func TestReportSuccessIPv6(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 is not available:", err)
+	}
+
+	var received status.Report
+	var receivedUUID string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedUUID = r.Header.Get("kh-run-uuid")
+		err := json.NewDecoder(r.Body).Decode(&received)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "ipv6-run-uuid")
+	os.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+	t.Log("Reporting to", server.URL)
+
+	err = ReportSuccess()
+	if err != nil {
+		t.Fatal("Failed to report success to IPv6 server:", err)
+	}
+	if receivedUUID != "ipv6-run-uuid" || !received.OK {
+		t.Fatalf("IPv6 server received uuid `%s` and report %+v", receivedUUID, received)
+	}
+}
+
+// TestReportTraceParent ensures reports carry the trace context of the run when it is valid
+func TestReportTraceParent(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get(external.TraceParentHeader))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	t.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	t.Setenv(external.KHRunUUID, "trace-run-uuid")
+	t.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+	traceParent := external.NewTraceParent()
+	for _, tp := range []string{traceParent, "not a traceparent", ""} {
+		t.Setenv(external.KHTraceParent, tp)
+		if err := ReportSuccess(); err != nil {
+			t.Fatal("Failed to report success:", err)
+		}
+	}
+	if !reflect.DeepEqual(received, []string{traceParent, "", ""}) {
+		t.Fatalf("server received traceparent headers %q", received)
+	}
+	if _, ok := GetTraceParent(); ok {
+		t.Fatal("expected no trace context without KH_TRACEPARENT")
+	}
+}
+
+// TestReportVersion ensures reports say which version they were written for and are not retried when Kuberhealthy
+// does not accept that version
+func TestReportVersion(t *testing.T) {
+	var requests int
+	var received status.Report
+	versions := "1,2"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set(external.KHReportVersionsHeader, versions)
+		err := json.NewDecoder(r.Body).Decode(&received)
+		if err != nil || !acceptsReportVersion(versions, received.ReportVersion) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	t.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	t.Setenv(external.KHRunUUID, "versioned-run-uuid")
+	t.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+	err := ReportSuccess()
+	if err != nil || received.ReportVersion != status.CurrentReportVersion {
+		t.Fatalf("expected the report to carry version %d, got %d and error %v", status.CurrentReportVersion, received.ReportVersion, err)
+	}
+
+	versions = "1"
+	requests = 0
+	err = ReportSuccess()
+	if !errors.Is(err, ErrReportVersionUnsupported) || requests != 1 {
+		t.Fatalf("expected the unsupported report version to not be retried, got %v after %d requests", err, requests)
+	}
+}
+
+// TestReportFailureWithMetadata ensures that the metadata of a run is sent along with its errors
+func TestReportFailureWithMetadata(t *testing.T) {
+	var received status.Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewDecoder(r.Body).Decode(&received)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "metadata-run-uuid")
+	os.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+	err := ReportFailureWithMetadata([]string{"2 images have critical vulnerabilities"}, map[string]string{"imagesScanned": "14"})
+	if err != nil {
+		t.Fatal("Failed to report failure with metadata:", err)
+	}
+	if received.OK || len(received.Errors) != 1 || received.Metadata["imagesScanned"] != "14" {
+		t.Fatalf("server received report %+v", received)
+	}
+}
+
+// TestReportSuccessWithMetrics ensures that valid metrics are sent with a report and invalid ones are refused
+func TestReportSuccessWithMetrics(t *testing.T) {
+	var received status.Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewDecoder(r.Body).Decode(&received)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "metrics-run-uuid")
+	os.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+	if err := ReportSuccessWithMetrics(map[string]float64{"latency-ms": 12}); err == nil {
+		t.Fatal("expected a metric name with a dash to be refused")
+	}
+	if err := ReportSuccessWithMetrics(map[string]float64{"latency_ms": math.Inf(1)}); err == nil {
+		t.Fatal("expected an infinite metric value to be refused")
+	}
+
+	err := ReportSuccessWithMetrics(map[string]float64{"latency_ms": 12.5, "pods": 3})
+	if err != nil {
+		t.Fatal("Failed to report success with metrics:", err)
+	}
+	if !received.OK || received.Metrics["latency_ms"] != 12.5 || received.Metrics["pods"] != 3 {
+		t.Fatalf("server received report %+v", received)
+	}
+}
+
+// TestReportWarning ensures that warnings are validated and sent with a passing report
+func TestReportWarning(t *testing.T) {
+	var received status.Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewDecoder(r.Body).Decode(&received)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "warning-run-uuid")
+	os.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+	if err := ReportWarning(nil); !errors.Is(err, ErrNoWarnings) {
+		t.Fatal("expected a warning report without warnings to be refused, got:", err)
+	}
+	if err := ReportWarning([]string{" "}); err == nil {
+		t.Fatal("expected a blank warning to be refused")
+	}
+
+	err := ReportWarning([]string{"certificate expires in 20 days"})
+	if err != nil {
+		t.Fatal("Failed to report warning:", err)
+	}
+	if !received.OK || len(received.Errors) != 0 || !reflect.DeepEqual(received.Warnings, []string{"certificate expires in 20 days"}) {
+		t.Fatalf("server received report %+v", received)
+	}
+}
+
+// TestReportSubChecks ensures that sub-checks are validated and sent along with the errors of failed sub-checks
+func TestReportSubChecks(t *testing.T) {
+	var received status.Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewDecoder(r.Body).Decode(&received)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "subcheck-run-uuid")
+	os.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+	if err := ReportSubChecks(nil); !errors.Is(err, ErrNoSubChecks) {
+		t.Fatal("expected a sub-check report without sub-checks to be refused, got:", err)
+	}
+	if err := ReportSubChecks([]status.SubCheck{{Name: "kube-dns", OK: true}, {Name: "kube-dns", OK: true}}); err == nil {
+		t.Fatal("expected duplicate sub-check names to be refused")
+	}
+	if err := ReportSubChecks([]status.SubCheck{{Name: "kube-dns"}}); err == nil {
+		t.Fatal("expected a failed sub-check without errors to be refused")
+	}
+
+	err := ReportSubChecks([]status.SubCheck{
+		{Name: "kube-dns", OK: true},
+		{Name: "example.com", Errors: []string{"lookup timed out"}},
+	})
+	if err != nil {
+		t.Fatal("Failed to report sub-checks:", err)
+	}
+	if received.OK || !reflect.DeepEqual(received.Errors, []string{"example.com: lookup timed out"}) || len(received.SubChecks) != 2 {
+		t.Fatalf("server received report %+v", received)
+	}
+	if !received.SubChecks[0].OK || received.SubChecks[1].Name != "example.com" || received.SubChecks[1].OK {
+		t.Fatalf("server received sub-checks %+v", received.SubChecks)
+	}
+}
+
+// TestReportFailureDetailed ensures that structured errors are validated and sent along with their plain messages
+func TestReportFailureDetailed(t *testing.T) {
+	var received status.Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewDecoder(r.Body).Decode(&received)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "detailed-run-uuid")
+	os.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+	if err := ReportFailureDetailed(nil); !errors.Is(err, ErrNoCheckErrors) {
+		t.Fatal("expected reporting no errors to fail with ErrNoCheckErrors, got:", err)
+	}
+	if err := ReportFailureDetailed([]status.CheckError{{Message: "dns failed", Severity: "fatal"}}); err == nil {
+		t.Fatal("expected an unknown severity to be refused")
+	}
+
+	err := ReportFailureDetailed([]status.CheckError{
+		{Message: "dns lookup timed out", Code: "DNS_TIMEOUT", Metadata: map[string]string{"host": "example.com"}},
+		{Message: "dns lookup was slow", Severity: status.SeverityWarning},
+	})
+	if err != nil {
+		t.Fatal("Failed to report detailed failure:", err)
+	}
+	if received.OK || len(received.Errors) != 2 || len(received.ErrorDetails) != 2 {
+		t.Fatalf("server received report %+v", received)
+	}
+	first := received.ErrorDetails[0]
+	if first.Severity != status.SeverityCritical || first.Code != "DNS_TIMEOUT" || first.Metadata["host"] != "example.com" || received.Errors[0] != first.Message {
+		t.Fatalf("server received error detail %+v", first)
+	}
+	if received.ErrorDetails[1].Severity != status.SeverityWarning {
+		t.Fatalf("server received error detail %+v", received.ErrorDetails[1])
+	}
+}
+
+// TestReportFailureWithArtifacts ensures artifacts are sent with failure reports and that over-long content is cut
+// down to its end
+func TestReportFailureWithArtifacts(t *testing.T) {
+	var received status.Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewDecoder(r.Body).Decode(&received)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "artifacts-run-uuid")
+	os.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+	if err := ReportFailureWithArtifacts(nil, nil); !errors.Is(err, ErrNoCheckErrors) {
+		t.Fatal("expected reporting no errors to fail with ErrNoCheckErrors, got:", err)
+	}
+	duplicated := []status.Artifact{{Name: "checker.log", Content: "a"}, {Name: "checker.log", Content: "b"}}
+	if err := ReportFailureWithArtifacts([]string{"dns failed"}, duplicated); err == nil {
+		t.Fatal("expected artifacts with the same name to be refused")
+	}
+
+	longLog := strings.Repeat("x", status.MaxArtifactBytes) + "lookup of example.com timed out"
+	err := ReportFailureWithArtifacts([]string{"dns failed"}, []status.Artifact{
+		{Name: "checker.log", ContentType: "text/plain", Content: longLog},
+		{Name: "resolv.conf", Content: "nameserver 10.0.0.10"},
+	})
+	if err != nil {
+		t.Fatal("Failed to report failure with artifacts:", err)
+	}
+	if received.OK || len(received.Artifacts) != 2 {
+		t.Fatalf("server received report %+v", received)
+	}
+	checkerLog := received.Artifacts[0]
+	if !checkerLog.Truncated || len(checkerLog.Content) != status.MaxArtifactBytes || !strings.HasSuffix(checkerLog.Content, "timed out") || checkerLog.ContentType != "text/plain" {
+		t.Fatalf("server received artifact %s of %d bytes, truncated: %v", checkerLog.Name, len(checkerLog.Content), checkerLog.Truncated)
+	}
+	if received.Artifacts[1].Truncated || received.Artifacts[1].Content != "nameserver 10.0.0.10" {
+		t.Fatalf("server received artifact %+v", received.Artifacts[1])
+	}
+}
+
+// TestReportFailureWithContext ensures that retrying a report stops when its context is done
+func TestReportFailureWithContext(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "context-run-uuid")
+	os.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond*500, cancel)
+	start := time.Now()
+	err := ReportFailureWithContext(ctx, []string{"failed"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("expected the report to stop when the context was canceled, got:", err)
+	}
+	if time.Since(start) > time.Second*5 {
+		t.Fatal("reporting took", time.Since(start), "after the context was canceled")
+	}
+	if atomic.LoadInt32(&attempts) == 0 {
+		t.Fatal("expected the report to be sent at least once")
+	}
+
+	// retrying also stops when the next attempt would be after the deadline of the context
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+	start = time.Now()
+	err = ReportSuccessWithContext(ctx)
+	if err == nil {
+		t.Fatal("expected reporting to a failing server to fail")
+	}
+	if time.Since(start) > time.Second*3 {
+		t.Fatal("reporting took", time.Since(start), "with a context deadline of 2s")
+	}
+}
+
+// TestQuitSidecars ensures that sidecars are only asked to shut down when kuberhealthy requests it
+func TestQuitSidecars(t *testing.T) {
+
+	var quitRequests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			quitRequests++
+		}
+	}))
+	defer server.Close()
+
+	oldURLs := sidecarQuitURLs
+	defer func() {
+		sidecarQuitURLs = oldURLs
+		os.Unsetenv(external.KHSidecarQuit)
+	}()
+	sidecarQuitURLs = []string{server.URL + "/quitquitquit", "http://127.0.0.1:1/shutdown"}
+
+	os.Unsetenv(external.KHSidecarQuit)
+	quitSidecars()
+	if quitRequests != 0 {
+		t.Fatalf("sidecars were asked to shut down %d times without being requested", quitRequests)
+	}
+
+	os.Setenv(external.KHSidecarQuit, "true")
+	quitSidecars()
+	if quitRequests != 1 {
+		t.Fatalf("sidecars were asked to shut down %d times but expected 1", quitRequests)
+	}
+
+	// sidecars are only shut down once the result of the check was delivered
+	var reportCode int32 = http.StatusInternalServerError
+	reportServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&reportCode)))
+	}))
+	defer reportServer.Close()
+	t.Setenv(external.KHReportingURL, reportServer.URL+"/externalCheckStatus")
+	t.Setenv(external.KHRunUUID, "sidecar-run-uuid")
+	t.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+	quitRequests = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := ReportSuccessWithContext(ctx); err == nil {
+		t.Fatal("expected the report to fail while kuberhealthy answers with errors")
+	}
+	if quitRequests != 0 {
+		t.Fatalf("sidecars were asked to shut down %d times after the report failed", quitRequests)
+	}
+
+	atomic.StoreInt32(&reportCode, http.StatusOK)
+	if err := ReportSuccess(); err != nil {
+		t.Fatal("Failed to report success:", err)
+	}
+	if quitRequests != 1 {
+		t.Fatalf("sidecars were asked to shut down %d times after the report was delivered but expected 1", quitRequests)
+	}
+}
+
+// TestGetServiceAccountToken ensures that projected service account tokens are read from the token directory
+func TestGetServiceAccountToken(t *testing.T) {
+
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, external.ServiceAccountTokenFile("https://vault.example.com")), []byte("token\n"), 0600)
+	if err != nil {
+		t.Fatalf("failed to write token file: %s", err)
+	}
+	os.Setenv(external.KHServiceAccountTokenDir, dir)
+	defer os.Unsetenv(external.KHServiceAccountTokenDir)
+
+	token, err := GetServiceAccountToken("https://vault.example.com")
+	if err != nil {
+		t.Fatalf("failed to get service account token: %s", err)
+	}
+	if token != "token" {
+		t.Fatalf("service account token was %q but expected %q", token, "token")
+	}
+
+	_, err = GetServiceAccountToken("sts.amazonaws.com")
+	if err == nil {
+		t.Fatal("expected an error for an audience without a projected token")
+	}
+}
+
+// TestRequestExtension ensures extensions are requested next to the reporting url and update the deadline
+func TestRequestExtension(t *testing.T) {
+
+	newDeadline := time.Now().Add(5 * time.Minute).Unix()
+	var requested status.ExtensionRequest
+	var requestedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		err := json.NewDecoder(r.Body).Decode(&requested)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if requested.Seconds > 120 {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(status.Extension{Deadline: newDeadline, Granted: requested.Seconds})
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "extension-run-uuid")
+	os.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+	deadline, err := RequestExtension(90 * time.Second)
+	if err != nil {
+		t.Fatal("Failed to request deadline extension:", err)
+	}
+	if requestedPath != "/extendDeadline" || requested.Seconds != 90 {
+		t.Fatalf("extension of %d seconds was requested from %s", requested.Seconds, requestedPath)
+	}
+	if deadline.Unix() != newDeadline {
+		t.Fatalf("new deadline was %d but expected %d", deadline.Unix(), newDeadline)
+	}
+	current, err := GetDeadline()
+	if err != nil || current.Unix() != newDeadline {
+		t.Fatalf("GetDeadline returned %s (%v) after the extension but expected %d", current, err, newDeadline)
+	}
+
+	_, err = RequestExtension(time.Hour)
+	if !errors.Is(err, ErrExtensionRefused) {
+		t.Fatalf("refused extension returned error %v but expected %v", err, ErrExtensionRefused)
+	}
+}
+
+// TestReportProgress ensures progress is sent next to the reporting url and refused progress is reported as such
+func TestReportProgress(t *testing.T) {
+
+	var reported []status.ProgressReport
+	var requestedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		if r.Header.Get("kh-run-uuid") != "progress-run-uuid" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		p := status.ProgressReport{}
+		err := json.NewDecoder(r.Body).Decode(&p)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if len(reported) > 0 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		reported = append(reported, p)
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "progress-run-uuid")
+
+	err := ReportProgress("waiting on the volume snapshot", 40)
+	if err != nil {
+		t.Fatal("Failed to report progress:", err)
+	}
+	if requestedPath != "/reportProgress" || len(reported) != 1 || reported[0].Percent != 40 || reported[0].Message != "waiting on the volume snapshot" {
+		t.Fatalf("unexpected progress %+v reported to %s", reported, requestedPath)
+	}
+
+	err = ReportProgress("restoring the snapshot", 80)
+	if !errors.Is(err, ErrProgressRefused) {
+		t.Fatalf("progress reported too soon returned error %v but expected %v", err, ErrProgressRefused)
+	}
+	err = ReportProgress("done", 101)
+	if err == nil || len(reported) != 1 {
+		t.Fatal("expected progress over 100 percent to be rejected without being sent")
+	}
+}
+
+// TestCanceled ensures the cancellation of a run is fetched from next to the reporting url
+func TestCanceled(t *testing.T) {
+
+	var requestedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		if r.URL.Path != "/cancel/canceled-run-uuid" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(status.Cancellation{Canceled: true, Reason: "replaced by a newer run"})
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "canceled-run-uuid")
+
+	canceled, err := Canceled()
+	if err != nil {
+		t.Fatal("Failed to fetch run cancellation:", err)
+	}
+	if !canceled {
+		t.Fatalf("run was not seen as canceled after requesting %s", requestedPath)
+	}
+
+	os.Setenv(external.KHRunUUID, "unknown-run-uuid")
+	_, err = Canceled()
+	if err != ErrRunNotFound {
+		t.Fatalf("unknown run returned error %v but expected %v", err, ErrRunNotFound)
+	}
+}
+
+// TestGetAlertDelivery ensures the alert delivery of a run is fetched from next to the reporting url
+func TestGetAlertDelivery(t *testing.T) {
+
+	received := time.Now().Add(-time.Second).UTC().Truncate(time.Millisecond)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/alertReceiver/delivered-run-uuid" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(status.AlertDelivery{Received: received, Status: "firing", Receiver: "kuberhealthy"})
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "delivered-run-uuid")
+
+	delivery, err := GetAlertDelivery()
+	if err != nil {
+		t.Fatal("Failed to fetch alert delivery:", err)
+	}
+	if !delivery.Received.Equal(received) || delivery.Status != "firing" {
+		t.Fatalf("alert delivery was %+v but expected firing at %s", delivery, received)
+	}
+
+	os.Setenv(external.KHRunUUID, "pending-run-uuid")
+	_, err = GetAlertDelivery()
+	if err != ErrAlertNotDelivered {
+		t.Fatalf("undelivered alert returned error %v but expected %v", err, ErrAlertNotDelivered)
+	}
+}
+
+// TestCancellationChannel ensures the channel is closed once kuberhealthy cancels the run or the deadline passes, and
+// left open when watching stops
+func TestCancellationChannel(t *testing.T) {
+
+	oldInterval := cancellationPollInterval
+	defer func() { cancellationPollInterval = oldInterval }()
+	cancellationPollInterval = time.Millisecond * 10
+
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&polls, 1)
+		json.NewEncoder(w).Encode(status.Cancellation{Canceled: n >= 3, Reason: "replaced by a newer run"})
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "watched-run-uuid")
+	os.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+	canceled, err := CancellationChannel(context.Background())
+	if err != nil {
+		t.Fatal("Failed to watch run cancellation:", err)
+	}
+	select {
+	case <-canceled:
+		if n := atomic.LoadInt32(&polls); n < 3 {
+			t.Fatalf("channel was closed after %d polls but the run was canceled on the third", n)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("channel was not closed after the run was canceled")
+	}
+
+	os.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(-time.Second).Unix(), 10))
+	atomic.StoreInt32(&polls, -100)
+	canceled, err = CancellationChannel(context.Background())
+	if err != nil {
+		t.Fatal("Failed to watch run cancellation:", err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("channel was not closed after the deadline passed")
+	}
+
+	os.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled, err = CancellationChannel(ctx)
+	if err != nil {
+		t.Fatal("Failed to watch run cancellation:", err)
+	}
+	cancel()
+	select {
+	case <-canceled:
+		t.Fatalf("channel was closed after watching stopped")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	os.Unsetenv(external.KHRunUUID)
+	_, err = CancellationChannel(context.Background())
+	if err == nil {
+		t.Fatalf("watching the cancellation of a pod without a run uuid did not return an error")
+	}
+}
+
+// TestReportBulk ensures batches are sent next to the reporting url and their results returned
+func TestReportBulk(t *testing.T) {
+
+	var received []status.BulkReport
+	var requestedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		err := json.NewDecoder(r.Body).Decode(&received)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		results := []status.BulkReportResult{}
+		for _, report := range received {
+			results = append(results, status.BulkReportResult{Check: report.Check, Namespace: "kuberhealthy", UUID: report.UUID, Code: http.StatusOK})
+		}
+		json.NewEncoder(w).Encode(results)
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "agent-run-uuid")
+
+	results, err := ReportBulk([]status.BulkReport{
+		{Check: "dns", UUID: "1234", Report: status.NewReport(nil)},
+		{Check: "ntp", UUID: "5678", Report: status.NewReport([]string{"clock skew"})},
+	})
+	if err != nil {
+		t.Fatal("Failed to send bulk reports:", err)
+	}
+	if requestedPath != "/bulkCheckStatus" || len(received) != 2 || received[1].Report.OK {
+		t.Fatalf("bulk reports %+v were sent to %s", received, requestedPath)
+	}
+	if len(results) != 2 || results[1].Check != "ntp" || results[1].Code != http.StatusOK {
+		t.Fatalf("bulk report results were %+v", results)
+	}
+}
+
+func TestResident(t *testing.T) {
+
+	var registrations, polls int32
+	reportedUUID := make(chan string, 1)
+	deadline := time.Now().Add(time.Minute).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/resident/register":
+			atomic.AddInt32(&registrations, 1)
+			registration := status.ResidentRegistration{}
+			json.NewDecoder(r.Body).Decode(&registration)
+			registration.Namespace = "kuberhealthy"
+			json.NewEncoder(w).Encode(registration)
+		case "/resident/runs":
+			if r.URL.Query().Get("check") != "dns" || r.URL.Query().Get("namespace") != "kuberhealthy" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			// the first poll finds the registration forgotten and the second finds no run due
+			switch atomic.AddInt32(&polls, 1) {
+			case 1:
+				w.WriteHeader(http.StatusForbidden)
+			case 2:
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				json.NewEncoder(w).Encode(status.RunRequest{UUID: "resident-run-uuid", Deadline: deadline})
+			}
+		case "/externalCheckStatus":
+			reportedUUID <- r.Header.Get("kh-run-uuid")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+
+	resident, err := RegisterResident("dns")
+	if err != nil {
+		t.Fatal("Failed to register resident checker:", err)
+	}
+	if resident.Check != "dns" || resident.Namespace != "kuberhealthy" {
+		t.Fatalf("resident checker was registered as %+v", resident)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	run, err := resident.NextRun(ctx)
+	if err != nil {
+		t.Fatal("Failed to fetch the next run:", err)
+	}
+	if run.UUID != "resident-run-uuid" || run.Deadline != deadline {
+		t.Fatalf("resident checker was handed run %+v", run)
+	}
+	if atomic.LoadInt32(&registrations) != 2 || atomic.LoadInt32(&polls) != 3 {
+		t.Fatalf("resident checker registered %d times and polled %d times but expected 2 and 3", registrations, polls)
+	}
+
+	err = resident.ReportSuccess(run)
+	if err != nil {
+		t.Fatal("Failed to report run:", err)
+	}
+	if uuid := <-reportedUUID; uuid != run.UUID {
+		t.Fatalf("report was sent for run %s but expected %s", uuid, run.UUID)
+	}
+}
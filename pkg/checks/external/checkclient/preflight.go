@@ -0,0 +1,84 @@
+package checkclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// preflightTimeout is how long Preflight waits on Kuberhealthy to answer
+const preflightTimeout = time.Second * 10
+
+// Preflight verifies that the reporting URL, run UUID and deadline Kuberhealthy sets in checker pods are present and
+// valid and that Kuberhealthy answers at the reporting URL.  Checks can call it before doing anything expensive so
+// that a pod that can't report fails right away instead of being timed out by Kuberhealthy.  Errors wrap
+// ErrPreflightFailed and list every problem found.
+func Preflight() error {
+	return PreflightWithContext(context.Background())
+}
+
+// PreflightWithContext is Preflight with a context that bounds the request to Kuberhealthy
+func PreflightWithContext(ctx context.Context) error {
+	var problems []string
+
+	reportingURL := os.Getenv(external.KHReportingURL)
+	if len(reportingURL) == 0 {
+		problems = append(problems, external.KHReportingURL+" is not set")
+	} else if u, err := url.Parse(reportingURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		problems = append(problems, fmt.Sprintf("%s %q is not an http or https URL", external.KHReportingURL, reportingURL))
+	}
+
+	if len(os.Getenv(external.KHRunUUID)) == 0 {
+		problems = append(problems, external.KHRunUUID+" is not set")
+	}
+
+	unixDeadline := os.Getenv(external.KHDeadline)
+	if len(unixDeadline) == 0 {
+		problems = append(problems, external.KHDeadline+" is not set")
+	} else if deadline, err := strconv.ParseInt(unixDeadline, 10, 64); err != nil {
+		problems = append(problems, fmt.Sprintf("%s %q is not a unix time", external.KHDeadline, unixDeadline))
+	} else if !time.Now().Before(time.Unix(deadline, 0)) {
+		problems = append(problems, fmt.Sprintf("%s %s has already passed", external.KHDeadline, time.Unix(deadline, 0).UTC()))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrPreflightFailed, strings.Join(problems, "; "))
+	}
+
+	err := pingKuberhealthy(ctx, reportingURL)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPreflightFailed, err)
+	}
+	logDebug("Preflight passed. Kuberhealthy is reachable at ", reportingURL)
+	return nil
+}
+
+// pingKuberhealthy sends a HEAD request to the reporting URL.  Any answer from Kuberhealthy means it can be reached,
+// but a gateway error means a proxy or service mesh in between could not reach it.
+func pingKuberhealthy(ctx context.Context, reportingURL string) error {
+	ctx, cancel := context.WithTimeout(ctx, preflightTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, reportingURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request to %s: %w", reportingURL, err)
+	}
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("kuberhealthy could not be reached at %s: %w", reportingURL, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return fmt.Errorf("kuberhealthy could not be reached at %s: [%d] %s", reportingURL, resp.StatusCode, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package checkclient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// TestPreflight ensures missing or invalid environment variables and an unreachable kuberhealthy fail the preflight
+func TestPreflight(t *testing.T) {
+	var method string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer gateway.Close()
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+
+	future := strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+
+	var testCases = []struct {
+		description  string
+		reportingURL string
+		uuid         string
+		deadline     string
+		expectError  []string
+	}{
+		{"ready", server.URL + "/externalCheckStatus", "run-uuid", future, nil},
+		{"nothing set", "", "", "", []string{external.KHReportingURL + " is not set", external.KHRunUUID + " is not set", external.KHDeadline + " is not set"}},
+		{"invalid url", "kuberhealthy:80", "run-uuid", future, []string{"is not an http or https URL"}},
+		{"invalid deadline", server.URL + "/externalCheckStatus", "run-uuid", "soon", []string{"is not a unix time"}},
+		{"passed deadline", server.URL + "/externalCheckStatus", "run-uuid", past, []string{"has already passed"}},
+		{"gateway error", gateway.URL + "/externalCheckStatus", "run-uuid", future, []string{"[503]"}},
+		{"unreachable", closed.URL + "/externalCheckStatus", "run-uuid", future, []string{"could not be reached"}},
+	}
+	for _, tc := range testCases {
+		os.Setenv(external.KHReportingURL, tc.reportingURL)
+		os.Setenv(external.KHRunUUID, tc.uuid)
+		os.Setenv(external.KHDeadline, tc.deadline)
+
+		err := Preflight()
+		if len(tc.expectError) == 0 {
+			if err != nil {
+				t.Fatalf("%s: %v", tc.description, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrPreflightFailed) {
+			t.Fatalf("%s: expected ErrPreflightFailed, got %v", tc.description, err)
+		}
+		for _, expected := range tc.expectError {
+			if !strings.Contains(err.Error(), expected) {
+				t.Fatalf("%s: error %q does not mention %q", tc.description, err, expected)
+			}
+		}
+	}
+	if method != http.MethodHead {
+		t.Fatalf("expected kuberhealthy to be reached with a HEAD request, got %s", method)
+	}
+}
@@ -0,0 +1,71 @@
+package checkclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// Run runs a check and reports its result to Kuberhealthy.  Preflight is run first, and the check is not run when it
+// fails because its result could not be reported.  The check is given a context that is done once the run deadline
+// passes.  When the khcheck or khjob sets heartbeatTimeout, heartbeats are sent while the check runs and the context
+// is not bounded by the deadline, since the heartbeats keep pushing it back.  A nil error is reported as a success
+// and any other error is reported as a failure with the error's message.  A panic in the check is recovered and reported as a failure.  The error returned is the error
+// from sending the report, so a check's main function can usually be:
+//
+//	err := checkclient.Run(check)
+//	if err != nil {
+//		log.Fatalln(err)
+//	}
+func Run(check func(ctx context.Context) error) error {
+	err := Preflight()
+	if err != nil {
+		logError("Not running the check:", err)
+		return err
+	}
+
+	ctx, cancel := runContext()
+	defer cancel()
+
+	err = StartHeartbeats(ctx)
+	if err != nil && !errors.Is(err, ErrHeartbeatsNotExpected) {
+		logWarning("Failed to start sending heartbeats: ", err)
+	}
+
+	checkErr := runRecovered(ctx, check)
+
+	// the check context may have passed its deadline already, so the report is sent with its own context
+	if checkErr != nil {
+		logError("Check run failed:", checkErr)
+		return ReportFailureWithContext(context.Background(), []string{checkErr.Error()})
+	}
+	return ReportSuccessWithContext(context.Background())
+}
+
+// runContext returns a context that is done at the run deadline.  The context has no deadline when the deadline
+// of the run is unknown or moved by heartbeats.
+func runContext() (context.Context, context.CancelFunc) {
+	_, err := GetHeartbeatTimeout()
+	if err == nil {
+		return context.WithCancel(context.Background())
+	}
+	deadline, err := GetDeadline()
+	if err != nil {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithDeadline(context.Background(), deadline)
+}
+
+// runRecovered calls the check and turns a panic in it into an error.
+func runRecovered(ctx context.Context, check func(ctx context.Context) error) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		logError("Check panicked:", r, "\n"+string(debug.Stack()))
+		err = fmt.Errorf("check panicked: %v", r)
+	}()
+	return check(ctx)
+}
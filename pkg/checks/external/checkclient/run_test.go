@@ -0,0 +1,74 @@
+package checkclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestRun ensures the result of a check run, including a panic, is reported to kuberhealthy
+func TestRun(t *testing.T) {
+	var reported status.Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		err := json.NewDecoder(r.Body).Decode(&reported)
+		if err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	deadline := time.Now().Add(time.Minute)
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "run-uuid")
+	os.Setenv(external.KHDeadline, strconv.FormatInt(deadline.Unix(), 10))
+
+	var testCases = []struct {
+		description string
+		check       func(ctx context.Context) error
+		expectOK    bool
+		expectError string
+	}{
+		{"success", func(ctx context.Context) error { return nil }, true, ""},
+		{"failure", func(ctx context.Context) error { return errors.New("pod never became ready") }, false, "pod never became ready"},
+		{"panic", func(ctx context.Context) error { panic("nil map") }, false, "check panicked: nil map"},
+	}
+	for _, tc := range testCases {
+		reported = status.Report{}
+		err := Run(tc.check)
+		if err != nil {
+			t.Fatalf("%s: failed to report: %v", tc.description, err)
+		}
+		if reported.OK != tc.expectOK {
+			t.Fatalf("%s: reported OK %t but expected %t", tc.description, reported.OK, tc.expectOK)
+		}
+		if tc.expectError != "" && (len(reported.Errors) != 1 || !strings.Contains(reported.Errors[0], tc.expectError)) {
+			t.Fatalf("%s: reported errors %v but expected %q", tc.description, reported.Errors, tc.expectError)
+		}
+	}
+
+	// the check context ends at the run deadline
+	err := Run(func(ctx context.Context) error {
+		ctxDeadline, ok := ctx.Deadline()
+		if !ok || !ctxDeadline.Equal(time.Unix(deadline.Unix(), 0)) {
+			t.Errorf("check context deadline %v does not match the run deadline", ctxDeadline)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,13 @@
+package checkclient
+
+import "github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+
+// signReport returns the signature of a report body made with the signing key of the run, or nothing when the run has
+// no signing key because Kuberhealthy does not require signed reports.  The body is signed before it is compressed.
+func signReport(signingKey string, body []byte) string {
+	if len(signingKey) == 0 {
+		return ""
+	}
+	logDebug("Signing report with the signing key of the run")
+	return external.SignReport(signingKey, body)
+}
@@ -0,0 +1,56 @@
+package checkclient
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external"
+)
+
+// TestReportSigned ensures reports are signed with the signing key of the run and that a refused signature is not
+// retried
+func TestReportSigned(t *testing.T) {
+	runKey := external.RunSigningKey("report signing key", "signed-run-uuid")
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		requests++
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !external.VerifyReportSignature(runKey, body, r.Header.Get(external.KHSignatureHeader)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	os.Setenv(external.KHReportingURL, server.URL+"/externalCheckStatus")
+	os.Setenv(external.KHRunUUID, "signed-run-uuid")
+	os.Setenv(external.KHDeadline, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+	os.Setenv(external.KHReportSigningKey, runKey)
+	defer os.Unsetenv(external.KHReportSigningKey)
+
+	err := ReportSuccess()
+	if err != nil {
+		t.Fatal("Failed to send a signed report:", err)
+	}
+
+	os.Setenv(external.KHReportSigningKey, external.RunSigningKey("report signing key", "another-run-uuid"))
+	requests = 0
+	err = ReportSuccess()
+	if !errors.Is(err, ErrReportSignatureRejected) || requests != 1 {
+		t.Fatalf("expected the refused signature to not be retried, got %v after %d requests", err, requests)
+	}
+}
@@ -0,0 +1,90 @@
+package external
+
+import (
+	"context"
+	"errors"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+)
+
+// ErrRunCanceled is returned by runs that were canceled before they finished, such as when a newer run replaces them
+var ErrRunCanceled = errors.New("run was canceled before it finished")
+
+// Overlaps indicates that runs of this check may overlap, so a run must only touch its own checker pod
+func (ext *Checker) Overlaps() bool {
+	return ext.ConcurrencyPolicy == khcheckv1.AllowConcurrent
+}
+
+// beginRun creates the context of a new run.  Canceling the run cancels this context.
+func (ext *Checker) beginRun(ctx context.Context) {
+	ext.runMu.Lock()
+	defer ext.runMu.Unlock()
+	ext.shutdownCTX, ext.shutdownCTXFunc = context.WithCancel(ctx)
+	ext.canceled = false
+}
+
+// CancelRun cancels the run in progress for the supplied reason.  The checker pod is told the run was canceled through
+// the /cancel endpoint, then the run stops waiting on it, evicts it and returns ErrRunCanceled.
+func (ext *Checker) CancelRun(reason string) {
+	ext.runMu.Lock()
+	defer ext.runMu.Unlock()
+	ext.canceled = true
+	ext.Runs.Cancel(ext.currentCheckUUID, reason)
+	if ext.shutdownCTXFunc != nil {
+		ext.log("canceling run", ext.currentCheckUUID)
+		ext.shutdownCTXFunc()
+	}
+}
+
+// runCanceled indicates that the current run was canceled with CancelRun
+func (ext *Checker) runCanceled() bool {
+	ext.runMu.Lock()
+	defer ext.runMu.Unlock()
+	return ext.canceled
+}
+
+// Copy returns a checker with the same configuration as this one, but none of its run state.  Overlapping runs are
+// each given their own copy so that they don't trample each other's UUID and checker pod.
+func (ext *Checker) Copy() *Checker {
+	return &Checker{
+		CheckName:                ext.CheckName,
+		Namespace:                ext.Namespace,
+		RunInterval:              ext.RunInterval,
+		RunTimeout:               ext.RunTimeout,
+		KubeClient:               ext.KubeClient,
+		KHJobClient:              ext.KHJobClient,
+		KHCheckClient:            ext.KHCheckClient,
+		KHStateClient:            ext.KHStateClient,
+		PodSpec:                  ext.PodSpec,
+		OriginalPodSpec:          ext.OriginalPodSpec,
+		KuberhealthyReportingURL: ext.KuberhealthyReportingURL,
+		GRPCReportingAddress:     ext.GRPCReportingAddress,
+		ReportSigningKey:         ext.ReportSigningKey,
+		ExtraAnnotations:         ext.ExtraAnnotations,
+		ExtraLabels:              ext.ExtraLabels,
+		PropagatedLabels:         ext.PropagatedLabels,
+		Debug:                    ext.Debug,
+		hostname:                 ext.hostname,
+		KHWorkload:               ext.KHWorkload,
+		Runs:                     ext.Runs,
+		SidecarHandling:          ext.SidecarHandling,
+		OS:                       ext.OS,
+		Arch:                     ext.Arch,
+		SecurityContextPolicy:    ext.SecurityContextPolicy,
+		KuberhealthyAntiAffinity: ext.KuberhealthyAntiAffinity,
+		KuberhealthyNode:         ext.KuberhealthyNode,
+		ServiceAccountTokens:     ext.ServiceAccountTokens,
+		IsolatedNamespace:        ext.IsolatedNamespace,
+		ConcurrencyPolicy:        ext.ConcurrencyPolicy,
+		MaxDeadlineExtension:     ext.MaxDeadlineExtension,
+		StartTimeout:             ext.StartTimeout,
+		HeartbeatTimeout:         ext.HeartbeatTimeout,
+		Resident:                 ext.Resident,
+		Residents:                ext.Residents,
+		RunOnAllNodes:            ext.RunOnAllNodes,
+		RunPerZone:               ext.RunPerZone,
+		NodeSelector:             ext.NodeSelector,
+		Aggregation:              ext.Aggregation,
+		SpecErrors:               ext.SpecErrors,
+	}
+}
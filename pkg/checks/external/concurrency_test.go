@@ -0,0 +1,45 @@
+package external
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+)
+
+// TestCopyAndCancelRun ensures copies keep the configuration of a checker but not its run, and that canceling a
+// run cancels its context
+func TestCopyAndCancelRun(t *testing.T) {
+	c := &Checker{CheckName: "slow", Namespace: "kuberhealthy", ConcurrencyPolicy: khcheckv1.AllowConcurrent, Runs: NewRunTracker(), ReportSigningKey: "key", GRPCReportingAddress: "kuberhealthy:9090", PropagatedLabels: map[string]string{"team": "network"}}
+	c.currentCheckUUID = "1234"
+	c.beginRun(context.Background())
+
+	copied := c.Copy()
+	if copied.CheckName != "slow" || !copied.Overlaps() || copied.Runs != c.Runs || copied.ReportSigningKey != "key" || copied.GRPCReportingAddress != "kuberhealthy:9090" || copied.PropagatedLabels["team"] != "network" {
+		t.Fatalf("copy did not keep the configuration of the checker: %+v", copied)
+	}
+	if copied.CurrentUUID() != "" || copied.shutdownCTX != nil {
+		t.Fatalf("copy kept the run state of the checker")
+	}
+
+	c.Runs.Start("1234", "slow", "kuberhealthy", "slow-1", time.Now().Add(time.Minute))
+	c.CancelRun("replaced by a newer run")
+	if !c.runCanceled() {
+		t.Fatalf("run was not marked canceled")
+	}
+	run, _ := c.Runs.Get("1234")
+	if !run.Canceled || run.CancelReason != "replaced by a newer run" {
+		t.Fatalf("run tracker did not record the cancellation: %+v", run)
+	}
+	select {
+	case <-c.shutdownCTX.Done():
+	default:
+		t.Fatalf("canceling the run did not cancel its context")
+	}
+
+	c.beginRun(context.Background())
+	if c.runCanceled() {
+		t.Fatalf("a new run started out canceled")
+	}
+}
@@ -0,0 +1,44 @@
+package external
+
+import (
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/clock"
+)
+
+// clock returns the clock runs of the check are timed with
+func (ext *Checker) clock() clock.Clock {
+	return clock.OrReal(ext.Clock)
+}
+
+// deadlineReached returns a channel that is closed once the deadline of a run has passed.  Checker pods can ask for
+// their deadline to be extended while they run, so the deadline recorded in the run tracker is checked again
+// before the channel is closed.  The channel is left open when the run is canceled or shut down.
+func (ext *Checker) deadlineReached(uuid string, deadline time.Time) <-chan struct{} {
+	reached := make(chan struct{})
+	done := ext.shutdownCTX.Done()
+
+	go func() {
+		timer := ext.clock().NewTimer(ext.clock().Until(deadline))
+		defer timer.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-timer.C():
+			}
+
+			// follow any extension granted since the timer was set
+			if run, ok := ext.Runs.Get(uuid); ok && run.Deadline.After(deadline) {
+				ext.log("deadline of run", uuid, "was extended to", run.Deadline)
+				deadline = run.Deadline
+				timer.Reset(ext.clock().Until(deadline))
+				continue
+			}
+			close(reached)
+			return
+		}
+	}()
+
+	return reached
+}
@@ -0,0 +1,68 @@
+package external
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/testutil"
+)
+
+// TestDeadlineReached ensures the deadline of a run is reached on the clock of the check and follows extensions
+func TestDeadlineReached(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	ext := &Checker{Runs: NewRunTracker(), Clock: clock}
+	ext.Runs.SetClock(clock)
+	ext.beginRun(context.Background())
+	defer ext.shutdownCTXFunc()
+
+	deadline := clock.Now().Add(time.Minute)
+	ext.Runs.Start("run", "check", "kuberhealthy", "pod", deadline)
+	ext.Runs.AllowExtension("run", time.Minute)
+	reached := ext.deadlineReached("run", deadline)
+
+	clock.BlockUntil(1)
+	clock.Step(time.Second * 30)
+	_, _, err := ext.Runs.Extend("run", time.Second*30, clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the timer fires at the original deadline, then waits again for the extended one
+	clock.Step(time.Second * 30)
+	clock.BlockUntil(1)
+	select {
+	case <-reached:
+		t.Fatal("deadline was reached before its extension ran out")
+	default:
+	}
+
+	clock.Step(time.Second * 30)
+	select {
+	case <-reached:
+	case <-time.After(time.Second * 5):
+		t.Fatal("deadline was not reached once its extension ran out")
+	}
+}
+
+// TestDeadlineReachedShutdown ensures waiting for a deadline stops when the run shuts down
+func TestDeadlineReachedShutdown(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Now())
+	ext := &Checker{Runs: NewRunTracker(), Clock: clock}
+	ext.beginRun(context.Background())
+
+	reached := ext.deadlineReached("run", clock.Now().Add(time.Minute))
+	clock.BlockUntil(1)
+	ext.shutdownCTXFunc()
+
+	// the timer is stopped once the shutdown is seen
+	for clock.Waiters() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Step(time.Hour)
+	select {
+	case <-reached:
+		t.Fatal("deadline was reached after the run shut down")
+	default:
+	}
+}
@@ -0,0 +1,408 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// FanOut describes how a run spawns one checker pod per target instead of a single checker pod
+type FanOut string
+
+// Runs can fan out to every matching node or to every topology zone of the matching nodes
+const (
+	FanOutNodes FanOut = "node"
+	FanOutZones FanOut = "zone"
+)
+
+// targetNotReportedError is the error given to nodes or zones that did not report before a fanned out run timed out
+const targetNotReportedError = "checker pod did not report before the run timed out"
+
+// FanOutError is returned by fanned out runs that did not hear back from every node or zone.  It carries the result
+// on each of them so that they can be recorded along with the error.  Runs whose aggregation is still met without
+// the targets that did not report have passed.
+type FanOutError struct {
+	Err          error
+	NodeStatuses []khstatev1.NodeStatus
+	ZoneStatuses []khstatev1.ZoneStatus
+	Passed       bool
+}
+
+// Error returns the message of the underlying error
+func (e *FanOutError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *FanOutError) Unwrap() error {
+	return e.Err
+}
+
+// targetStatus is the result of a fanned out run on one of its nodes or zones
+type targetStatus struct {
+	target   string
+	ok       bool
+	errors   []string
+	warnings []string
+}
+
+// targetStatuses returns the result of a fanned out run on each of its targets, sorted by name.  Targets that have
+// not reported are given the supplied error.
+func (r Run) targetStatuses(missing string) []targetStatus {
+	statuses := []targetStatus{}
+	for _, target := range r.Targets {
+		report, ok := r.TargetReports[target]
+		if !ok {
+			statuses = append(statuses, targetStatus{target: target, errors: []string{missing}})
+			continue
+		}
+		statuses = append(statuses, targetStatus{target: target, ok: report.OK, errors: report.Errors, warnings: report.Warnings})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].target < statuses[j].target
+	})
+	return statuses
+}
+
+// NodeStatuses returns the result of a run on all nodes on each of its nodes, sorted by node name.  Nodes that have
+// not reported are given the supplied error.  Runs that did not fan out to nodes have none.
+func (r Run) NodeStatuses(missing string) []khstatev1.NodeStatus {
+	if r.FanOut != FanOutNodes {
+		return nil
+	}
+	statuses := []khstatev1.NodeStatus{}
+	for _, s := range r.targetStatuses(missing) {
+		statuses = append(statuses, khstatev1.NodeStatus{Node: s.target, OK: s.ok, Errors: s.errors})
+	}
+	return statuses
+}
+
+// ZoneStatuses returns the result of a run per zone in each of its zones, sorted by zone name.  Zones that have not
+// reported are given the supplied error.  Runs that did not fan out to zones have none.
+func (r Run) ZoneStatuses(missing string) []khstatev1.ZoneStatus {
+	if r.FanOut != FanOutZones {
+		return nil
+	}
+	statuses := []khstatev1.ZoneStatus{}
+	for _, s := range r.targetStatuses(missing) {
+		statuses = append(statuses, khstatev1.ZoneStatus{Zone: s.target, OK: s.ok, Errors: s.errors})
+	}
+	return statuses
+}
+
+// AggregateReport combines the reports of every node or zone of a fanned out run into the report of the whole run.
+// The aggregation of the run decides whether enough targets passed for the run to be OK.  Runs that are not OK carry
+// the errors of each failed target prefixed with its name.  The warnings of every target are kept the same way.  Targets
+// that have not reported are given the supplied error.
+func (r Run) AggregateReport(missing string) status.Report {
+	statuses := r.targetStatuses(missing)
+	passed := 0
+	errs := []string{}
+	var warnings []string
+	for _, s := range statuses {
+		for _, w := range s.warnings {
+			warnings = append(warnings, string(r.FanOut)+" "+s.target+": "+w)
+		}
+		if s.ok {
+			passed++
+			continue
+		}
+		for _, e := range s.errors {
+			errs = append(errs, string(r.FanOut)+" "+s.target+": "+e)
+		}
+	}
+	if aggregationPassed(r.Aggregation, passed, len(statuses)) {
+		report := status.NewReport([]string{})
+		report.Warnings = warnings
+		return report
+	}
+	if shortfall := aggregationShortfall(r.Aggregation, r.FanOut, passed, len(statuses)); len(shortfall) > 0 {
+		errs = append([]string{shortfall}, errs...)
+	}
+	report := status.NewReport(errs)
+	report.Warnings = warnings
+	return report
+}
+
+// fanOutError builds the error of a fanned out run that did not hear back from every target
+func (ext *Checker) fanOutError(err error) error {
+	run, _ := ext.Runs.Get(ext.currentCheckUUID)
+	return &FanOutError{
+		Err:          err,
+		NodeStatuses: run.NodeStatuses(targetNotReportedError),
+		ZoneStatuses: run.ZoneStatuses(targetNotReportedError),
+		Passed:       run.AggregateReport(targetNotReportedError).OK,
+	}
+}
+
+// NodeCache is a shared cache of the nodes of the cluster.  Fanned out runs select their nodes from it instead of
+// listing every node from the API on each run.
+type NodeCache interface {
+	HasSynced() bool
+	List(selector labels.Selector) ([]*apiv1.Node, error)
+}
+
+// listNodes lists the nodes that match a label selector from the node cache once it has synced, or from the API
+func listNodes(ctx context.Context, client kubernetes.Interface, cache NodeCache, selector map[string]string) ([]apiv1.Node, error) {
+	if cache != nil && cache.HasSynced() {
+		cached, err := cache.List(labels.SelectorFromSet(selector))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list nodes from cache: %w", err)
+		}
+		nodes := make([]apiv1.Node, 0, len(cached))
+		for _, n := range cached {
+			nodes = append(nodes, *n)
+		}
+		return nodes, nil
+	}
+
+	nodeList, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(selector).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	return nodeList.Items, nil
+}
+
+// schedulableNodes lists the nodes that match a label selector and can take checker pods.  Cordoned nodes and nodes
+// that are not ready are left out, because checker pods can't be scheduled on them.
+func schedulableNodes(ctx context.Context, client kubernetes.Interface, cache NodeCache, selector map[string]string) ([]apiv1.Node, error) {
+	nodeList, err := listNodes(ctx, client, cache, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []apiv1.Node
+	for _, n := range nodeList {
+		if n.Spec.Unschedulable || !nodeReady(n) {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// nodeReady indicates that the Ready condition of a node is true
+func nodeReady(node apiv1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == apiv1.NodeReady {
+			return c.Status == apiv1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// targetPodName returns the name of the checker pod spawned for a node or zone.  Node names can be much longer than
+// pod names allow, so the target is represented by a hash of its name.
+func targetPodName(podName string, target string) string {
+	h := fnv.New32a()
+	h.Write([]byte(target))
+	return fmt.Sprintf("%s-%08x", podName, h.Sum32())
+}
+
+// requireNodes requires a pod spec to be scheduled on nodes that meet the supplied requirement.  The requirement is
+// added to any node affinity already in the spec, so that taints, tolerations and the rest of the scheduling rules
+// still apply.  Requirements on the name of the node have to be field requirements.
+func requireNodes(spec *apiv1.PodSpec, requirement apiv1.NodeSelectorRequirement, field bool) {
+	if spec.Affinity == nil {
+		spec.Affinity = &apiv1.Affinity{}
+	}
+	if spec.Affinity.NodeAffinity == nil {
+		spec.Affinity.NodeAffinity = &apiv1.NodeAffinity{}
+	}
+	required := spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if required == nil || len(required.NodeSelectorTerms) == 0 {
+		required = &apiv1.NodeSelector{NodeSelectorTerms: []apiv1.NodeSelectorTerm{{}}}
+		spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = required
+	}
+
+	// terms are ORed together, so the requirement has to be added to every one of them
+	for i := range required.NodeSelectorTerms {
+		if field {
+			required.NodeSelectorTerms[i].MatchFields = append(required.NodeSelectorTerms[i].MatchFields, requirement)
+			continue
+		}
+		required.NodeSelectorTerms[i].MatchExpressions = append(required.NodeSelectorTerms[i].MatchExpressions, requirement)
+	}
+}
+
+// createTargetPod creates the checker pod of the current run for one node or zone
+func (ext *Checker) createTargetPod(ctx context.Context, fanOut FanOut, target string) (*apiv1.Pod, error) {
+	p := &apiv1.Pod{}
+	p.Namespace = ext.Namespace
+	p.Name = targetPodName(ext.podName(), target)
+	p.Spec = *ext.PodSpec.DeepCopy()
+
+	// enforce various labels and annotations on all checker pods created
+	ext.addKuberhealthyLabels(p)
+
+	switch fanOut {
+	case FanOutNodes:
+		pinToNode(&p.Spec, target)
+		p.Annotations[NodeAnnotation] = target
+	case FanOutZones:
+		pinToZone(&p.Spec, target)
+		p.Annotations[ZoneAnnotation] = target
+	}
+
+	err := ext.setOwnerReference(p)
+	if err != nil {
+		return nil, err
+	}
+
+	ext.log("Creating external checker pod named", p.Name, "for", fanOut, target)
+	return ext.KubeClient.CoreV1().Pods(ext.Namespace).Create(ctx, p, metav1.CreateOptions{})
+}
+
+// runFanOut spawns a checker pod for every node or zone and waits until all of them have reported.  The report
+// handler combines the reports of the targets into the state of the check once the last one reports.
+func (ext *Checker) runFanOut(ctx context.Context, fanOut FanOut) error {
+
+	// create a context for this run
+	ext.beginRun(ctx)
+	defer ext.shutdownCTXFunc()
+	defer ext.cleanup(ctx)
+
+	// regenerate the checker pod name with a new timestamp.  The pods of each target are named after it.
+	ext.regeneratePodName()
+
+	// report problems found in the spec before anything is scheduled
+	if len(ext.SpecErrors) > 0 {
+		return errors.New("invalid check spec: " + strings.Join(ext.SpecErrors, "; "))
+	}
+
+	// validate the pod spec
+	ext.log("Validating pod spec of external check")
+	err := ext.validatePodSpec()
+	if err != nil {
+		return err
+	}
+
+	// find the nodes or zones to run in
+	var targets []string
+	switch fanOut {
+	case FanOutNodes:
+		targets, err = selectRunNodes(ctx, ext.KubeClient, ext.Nodes, ext.NodeSelector)
+	case FanOutZones:
+		targets, err = selectRunZones(ctx, ext.KubeClient, ext.Nodes, ext.NodeSelector)
+	}
+	if err != nil {
+		return ext.newError(err.Error())
+	}
+	if len(targets) == 0 {
+		return ext.newError("no ready nodes match the node selector of the check")
+	}
+	ext.log("Running on", len(targets), fanOut+"s:", targets)
+
+	// register the validity window of this run along with the targets that have to report
+	ext.log("Timeout set to", ext.RunTimeout.String())
+	deadline := ext.clock().Now().Add(ext.RunTimeout)
+	ext.Runs.Start(ext.currentCheckUUID, ext.CheckName, ext.Namespace, "", deadline)
+	ext.Runs.ExpectTargets(ext.currentCheckUUID, fanOut, targets, ext.Aggregation)
+	if ext.MaxDeadlineExtension > 0 {
+		ext.Runs.AllowExtension(ext.currentCheckUUID, ext.MaxDeadlineExtension)
+	}
+	if ext.HeartbeatTimeout > 0 {
+		ext.Runs.ExpectHeartbeats(ext.currentCheckUUID, ext.HeartbeatTimeout)
+	}
+	timeoutChan := ext.deadlineReached(ext.currentCheckUUID, deadline)
+
+	// name the ephemeral namespace of this run so that it can be handed to the checker pods
+	if ext.IsolatedNamespace != nil {
+		ext.runNamespace = RunNamespaceName(ext.CheckName, ext.currentCheckUUID)
+	}
+
+	// condition the spec with the required labels and environment variables
+	ext.log("Configuring spec of external check")
+	err = ext.configureUserPodSpec(deadline)
+	if err != nil {
+		return ext.newError("failed to configure pod spec for Kubernetes from user specified pod spec: " + err.Error())
+	}
+
+	// sanity check our settings
+	ext.log("Running sanity check on check parameters")
+	err = ext.sanityCheck()
+	if err != nil {
+		return err
+	}
+
+	// fail early if pod security admission would reject the checker pods
+	err = ext.validatePodSecurity(ctx)
+	if err != nil {
+		return ext.newError(err.Error())
+	}
+	err = ext.validatePodSpecReferences(ctx)
+	if err != nil {
+		return ext.newError(err.Error())
+	}
+
+	// the test resources of every target share the ephemeral namespace of the run
+	err = ext.setupRunNamespace(ctx, deadline)
+	if err != nil {
+		ext.runNamespace = ""
+		return &ProvisioningError{Err: ext.newError(err.Error())}
+	}
+	defer ext.teardownRunNamespace(ctx)
+
+	// spawn a checker pod for every target
+	var mu sync.Mutex
+	createErrors := make(map[string]error)
+	wg := sync.WaitGroup{}
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			_, err := ext.createTargetPod(ctx, fanOut, target)
+			if err != nil {
+				ext.log("error creating pod for", fanOut, target+":", err)
+				mu.Lock()
+				createErrors[target] = err
+				mu.Unlock()
+			}
+		}(target)
+	}
+	wg.Wait()
+	if len(createErrors) == len(targets) {
+		ext.Runs.Expire(ext.currentCheckUUID)
+		return &ProvisioningError{Err: ext.newError(fmt.Sprintf("failed to create a checker pod for any %s", fanOut))}
+	}
+
+	// targets whose pod can't be created are failed straight away.  If every other target has already reported,
+	// there is no report left to complete the run, so it is failed here.
+	for target, err := range createErrors {
+		_, complete, _ := ext.Runs.ReportTarget(ext.currentCheckUUID, target, status.NewReport([]string{"failed to create checker pod: " + err.Error()}))
+		if complete {
+			ext.Runs.Expire(ext.currentCheckUUID)
+			return ext.fanOutError(ext.newError(fmt.Sprintf("failed to create checker pods for %d of %d %ss", len(createErrors), len(targets), fanOut)))
+		}
+	}
+
+	// wait for every target to report
+	ext.log("Waiting for checker pods of", len(targets), fanOut+"s to report")
+	select {
+	case <-timeoutChan:
+		ext.log("timed out waiting for every", fanOut, "to report in")
+		ext.Runs.Expire(ext.currentCheckUUID)
+		run, _ := ext.Runs.Get(ext.currentCheckUUID)
+		missing := len(run.Targets) - len(run.TargetReports)
+		return ext.fanOutError(&TimeoutError{Err: ext.newError(fmt.Sprintf("timed out waiting for checker pods of %d of %d %ss to report in", missing, len(targets), fanOut))})
+	case <-ext.runReported(ext.currentCheckUUID):
+		ext.log("Checker pods of all", fanOut+"s have reported status for run", ext.currentCheckUUID)
+	case <-ext.shutdownCTX.Done():
+		ext.log("shutting down check. aborting wait for", fanOut+"s to report in")
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package external
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// testNode makes a node with the supplied labels, readiness and cordon state
+func testNode(name string, labels map[string]string, ready bool, cordoned bool) *apiv1.Node {
+	readyStatus := apiv1.ConditionFalse
+	if ready {
+		readyStatus = apiv1.ConditionTrue
+	}
+	return &apiv1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec:       apiv1.NodeSpec{Unschedulable: cordoned},
+		Status: apiv1.NodeStatus{Conditions: []apiv1.NodeCondition{
+			{Type: apiv1.NodeReady, Status: readyStatus},
+		}},
+	}
+}
+
+// testNodes makes a cluster of nodes spread over two zones, with some that can't take checker pods
+func testNodes() *fake.Clientset {
+	return fake.NewSimpleClientset(
+		testNode("worker-b", map[string]string{"pool": "workers", apiv1.LabelTopologyZone: "us-east-1b"}, true, false),
+		testNode("worker-a", map[string]string{"pool": "workers", apiv1.LabelTopologyZone: "us-east-1a"}, true, false),
+		testNode("worker-a2", map[string]string{"pool": "workers", apiv1.LabelTopologyZone: "us-east-1a"}, true, false),
+		testNode("worker-not-ready", map[string]string{"pool": "workers", apiv1.LabelTopologyZone: "us-east-1c"}, false, false),
+		testNode("worker-cordoned", map[string]string{"pool": "workers", apiv1.LabelTopologyZone: "us-east-1d"}, true, true),
+		testNode("infra", map[string]string{"pool": "infra"}, true, false),
+	)
+}
+
+// TestSelectRunNodes ensures runs on all nodes only spawn pods on ready, uncordoned nodes that match the selector
+func TestSelectRunNodes(t *testing.T) {
+	client := testNodes()
+
+	var testCases = []struct {
+		selector map[string]string
+		expected []string
+	}{
+		{nil, []string{"infra", "worker-a", "worker-a2", "worker-b"}},
+		{map[string]string{"pool": "workers"}, []string{"worker-a", "worker-a2", "worker-b"}},
+		{map[string]string{"pool": "gpu"}, nil},
+	}
+
+	for _, tc := range testCases {
+		nodes, err := selectRunNodes(context.Background(), client, nil, tc.selector)
+		if err != nil {
+			t.Fatalf("failed to select nodes with selector %v: %s", tc.selector, err)
+		}
+		if !reflect.DeepEqual(nodes, tc.expected) {
+			t.Fatalf("selector %v selected nodes %v but expected %v", tc.selector, nodes, tc.expected)
+		}
+	}
+}
+
+// TestSelectRunZones ensures runs per zone spawn one pod in every zone that has a node able to take it
+func TestSelectRunZones(t *testing.T) {
+	client := testNodes()
+
+	var testCases = []struct {
+		selector map[string]string
+		expected []string
+	}{
+		{nil, []string{"us-east-1a", "us-east-1b"}},
+		{map[string]string{"pool": "infra"}, nil},
+	}
+
+	for _, tc := range testCases {
+		zones, err := selectRunZones(context.Background(), client, nil, tc.selector)
+		if err != nil {
+			t.Fatalf("failed to select zones with selector %v: %s", tc.selector, err)
+		}
+		if !reflect.DeepEqual(zones, tc.expected) {
+			t.Fatalf("selector %v selected zones %v but expected %v", tc.selector, zones, tc.expected)
+		}
+	}
+}
+
+// testNodeCache is a NodeCache over a fixed list of nodes
+type testNodeCache struct {
+	synced bool
+	nodes  []*apiv1.Node
+}
+
+func (c testNodeCache) HasSynced() bool {
+	return c.synced
+}
+
+func (c testNodeCache) List(selector labels.Selector) ([]*apiv1.Node, error) {
+	var nodes []*apiv1.Node
+	for _, n := range c.nodes {
+		if selector.Matches(labels.Set(n.Labels)) {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, nil
+}
+
+// TestSelectRunNodesFromCache ensures nodes are selected from the node cache once it has synced instead of the API
+func TestSelectRunNodesFromCache(t *testing.T) {
+	api := testNodes()
+	cache := testNodeCache{nodes: []*apiv1.Node{
+		testNode("cached-b", map[string]string{"pool": "workers"}, true, false),
+		testNode("cached-a", map[string]string{"pool": "workers"}, true, false),
+		testNode("cached-cordoned", map[string]string{"pool": "workers"}, true, true),
+	}}
+
+	nodes, err := selectRunNodes(context.Background(), api, cache, map[string]string{"pool": "workers"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(nodes, []string{"worker-a", "worker-a2", "worker-b"}) {
+		t.Fatalf("expected nodes to be listed from the API before the cache synced, got %v", nodes)
+	}
+
+	cache.synced = true
+	nodes, err = selectRunNodes(context.Background(), api, cache, map[string]string{"pool": "workers"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(nodes, []string{"cached-a", "cached-b"}) {
+		t.Fatalf("expected nodes to be selected from the synced cache, got %v", nodes)
+	}
+}
+
+// TestPinToTarget ensures pods are pinned to their node or zone on top of the node affinity already in their spec
+func TestPinToTarget(t *testing.T) {
+	spec := apiv1.PodSpec{}
+	pinToNode(&spec, "node-a")
+	terms := spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 1 || len(terms[0].MatchFields) != 1 || terms[0].MatchFields[0].Values[0] != "node-a" {
+		t.Fatalf("pod without node affinity was pinned with terms %+v", terms)
+	}
+
+	spec = apiv1.PodSpec{}
+	pinToZone(&spec, "us-east-1a")
+	terms = spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 1 || len(terms[0].MatchExpressions) != 1 || terms[0].MatchExpressions[0].Key != apiv1.LabelTopologyZone {
+		t.Fatalf("pod without node affinity was pinned to a zone with terms %+v", terms)
+	}
+
+	spec = apiv1.PodSpec{Affinity: &apiv1.Affinity{NodeAffinity: &apiv1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &apiv1.NodeSelector{NodeSelectorTerms: []apiv1.NodeSelectorTerm{
+			{MatchExpressions: []apiv1.NodeSelectorRequirement{{Key: "pool", Operator: apiv1.NodeSelectorOpIn, Values: []string{"a"}}}},
+			{MatchExpressions: []apiv1.NodeSelectorRequirement{{Key: "pool", Operator: apiv1.NodeSelectorOpIn, Values: []string{"b"}}}},
+		}},
+	}}}
+	pinToNode(&spec, "node-a")
+	for _, term := range spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		if len(term.MatchExpressions) != 1 || len(term.MatchFields) != 1 || term.MatchFields[0].Key != metav1.ObjectNameField {
+			t.Fatalf("node was not required by every existing term: %+v", term)
+		}
+	}
+}
+
+// TestTargetPodName ensures every node or zone gets its own pod name of a bounded length
+func TestTargetPodName(t *testing.T) {
+	a := targetPodName("check-1700000000", "ip-10-0-0-1.ec2.internal")
+	b := targetPodName("check-1700000000", "ip-10-0-0-2.ec2.internal")
+	if a == b {
+		t.Fatalf("pods on different nodes were both named %s", a)
+	}
+	if len(a) != len("check-1700000000")+9 {
+		t.Fatalf("target pod name %s was not the pod name followed by a hash of the target", a)
+	}
+}
+
+// TestAggregateReport ensures the result of each target is combined into one report for the run
+func TestAggregateReport(t *testing.T) {
+	run := Run{
+		FanOut:  FanOutNodes,
+		Targets: []string{"node-b", "node-a", "node-c"},
+		TargetReports: map[string]status.Report{
+			"node-a": status.NewReport(nil),
+			"node-b": status.NewReport([]string{"dns failed"}),
+		},
+	}
+
+	statuses := run.NodeStatuses("did not report")
+	expected := []khstatev1.NodeStatus{
+		{Node: "node-a", OK: true},
+		{Node: "node-b", Errors: []string{"dns failed"}},
+		{Node: "node-c", Errors: []string{"did not report"}},
+	}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Fatalf("node statuses were %+v but expected %+v", statuses, expected)
+	}
+	if run.ZoneStatuses("did not report") != nil {
+		t.Fatalf("run on all nodes had zone statuses")
+	}
+
+	report := run.AggregateReport("did not report")
+	if report.OK || !reflect.DeepEqual(report.Errors, []string{"node node-b: dns failed", "node node-c: did not report"}) {
+		t.Fatalf("aggregated report was %+v", report)
+	}
+
+	run = Run{FanOut: FanOutZones, Targets: []string{"us-east-1a"}, TargetReports: map[string]status.Report{"us-east-1a": status.NewReport(nil)}}
+	if report = run.AggregateReport(""); !report.OK {
+		t.Fatalf("report of zones that were all OK was not OK: %+v", report)
+	}
+	if zones := run.ZoneStatuses(""); len(zones) != 1 || zones[0].Zone != "us-east-1a" || !zones[0].OK {
+		t.Fatalf("zone statuses were %+v", zones)
+	}
+
+	// warnings of every target are kept with the name of the target, whether or not the run passed
+	run.TargetReports["us-east-1a"] = status.NewWarningReport([]string{"certificate expires in 20 days"})
+	if report = run.AggregateReport(""); !report.OK || !reflect.DeepEqual(report.Warnings, []string{"zone us-east-1a: certificate expires in 20 days"}) {
+		t.Fatalf("aggregated report of a zone with warnings was %+v", report)
+	}
+}
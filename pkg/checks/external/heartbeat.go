@@ -0,0 +1,11 @@
+package external
+
+import "time"
+
+// KHHeartbeatTimeout is the environment variable that tells checker pods how many seconds their run may go without a
+// heartbeat.  It is only set for checks that expect heartbeats.
+const KHHeartbeatTimeout = "KH_HEARTBEAT_TIMEOUT"
+
+// MinHeartbeatTimeout is the shortest heartbeat timeout a check can set.  Shorter timeouts would time runs out over a
+// single slow or retried heartbeat.
+const MinHeartbeatTimeout = time.Second * 10
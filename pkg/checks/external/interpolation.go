@@ -0,0 +1,78 @@
+package external
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// Fields that can be referenced as $(FIELD) in the environment variable values of a checker pod.  They are named
+// after the environment variables Kuberhealthy sets in the pod, with the name and namespace of the check added.
+const (
+	KHCheckName      = "KH_CHECK_NAME"
+	KHCheckNamespace = "KH_CHECK_NAMESPACE"
+)
+
+// interpolationFields returns the values of the fields that environment variables of the current run can reference
+func (ext *Checker) interpolationFields(deadline time.Time) map[string]string {
+	fields := map[string]string{
+		KHCheckName:      ext.CheckName,
+		KHCheckNamespace: ext.CheckNamespace(),
+		KHRunUUID:        ext.currentCheckUUID,
+		KHReportingURL:   ext.KuberhealthyReportingURL,
+		KHDeadline:       strconv.FormatInt(deadline.Unix(), 10),
+	}
+	if len(ext.runNamespace) > 0 {
+		fields[KHRunNamespace] = ext.runNamespace
+	}
+	return fields
+}
+
+// interpolateEnvVars replaces references to fields in the environment variable values of containers
+func interpolateEnvVars(containers []apiv1.Container, fields map[string]string) {
+	for i := range containers {
+		for j := range containers[i].Env {
+			containers[i].Env[j].Value = interpolate(containers[i].Env[j].Value, fields)
+		}
+	}
+}
+
+// interpolate replaces $(FIELD) references to known fields in s.  References to anything else are left alone for
+// Kubernetes to expand as references to other environment variables of the container, and $$ escapes a reference
+// the same way it does in Kubernetes.
+func interpolate(s string, fields map[string]string) string {
+	if !strings.Contains(s, "$(") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		// keep escaped references as they are so that Kubernetes unescapes them
+		if s[i+1] == '$' {
+			b.WriteString("$$")
+			i++
+			continue
+		}
+
+		if s[i+1] == '(' {
+			end := strings.IndexByte(s[i+2:], ')')
+			if end >= 0 {
+				value, ok := fields[s[i+2:i+2+end]]
+				if ok {
+					b.WriteString(value)
+					i += end + 2
+					continue
+				}
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
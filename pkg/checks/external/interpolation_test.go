@@ -0,0 +1,60 @@
+package external
+
+import (
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// TestInterpolate ensures references to known fields are replaced while other references and escapes are left for
+// Kubernetes to expand
+func TestInterpolate(t *testing.T) {
+	fields := map[string]string{KHCheckName: "dns-internal", KHCheckNamespace: "kuberhealthy"}
+
+	var testCases = []struct {
+		input    string
+		expected string
+	}{
+		{"plain", "plain"},
+		{"$(KH_CHECK_NAME)", "dns-internal"},
+		{"http://$(KH_CHECK_NAME).$(KH_CHECK_NAMESPACE).svc:8080/", "http://dns-internal.kuberhealthy.svc:8080/"},
+		{"$(MY_VAR)-$(KH_CHECK_NAME)", "$(MY_VAR)-dns-internal"},
+		{"$$(KH_CHECK_NAME)", "$$(KH_CHECK_NAME)"},
+		{"$$$(KH_CHECK_NAME)", "$$dns-internal"},
+		{"$(KH_CHECK_NAME", "$(KH_CHECK_NAME"},
+		{"costs $5", "costs $5"},
+		{"$", "$"},
+	}
+	for _, tc := range testCases {
+		result := interpolate(tc.input, fields)
+		if result != tc.expected {
+			t.Fatalf("interpolating %q returned %q but expected %q", tc.input, result, tc.expected)
+		}
+	}
+}
+
+// TestConfigureUserPodSpecInterpolation ensures environment variables of checker pods can reference the check and
+// the run without changing the check's own pod spec
+func TestConfigureUserPodSpecInterpolation(t *testing.T) {
+	original := apiv1.PodSpec{
+		InitContainers: []apiv1.Container{{Name: "init", Image: "busybox", Env: []apiv1.EnvVar{{Name: "RUN", Value: "$(KH_RUN_UUID)"}}}},
+		Containers:     []apiv1.Container{{Name: "check", Image: "kuberhealthy/test-check", Env: []apiv1.EnvVar{{Name: "TARGET", Value: "$(KH_CHECK_NAMESPACE)/$(KH_CHECK_NAME)"}}}},
+	}
+
+	ext := &Checker{CheckName: "deployment", Namespace: "kuberhealthy", currentCheckUUID: "8f14e45f", OriginalPodSpec: original, PodSpec: original}
+	err := ext.configureUserPodSpec(time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("failed to configure pod spec: %s", err)
+	}
+
+	if value := ext.PodSpec.Containers[0].Env[0].Value; value != "kuberhealthy/deployment" {
+		t.Fatalf("TARGET was %q but expected kuberhealthy/deployment", value)
+	}
+	if value := ext.PodSpec.InitContainers[0].Env[0].Value; value != "8f14e45f" {
+		t.Fatalf("RUN was %q but expected the run UUID", value)
+	}
+	if value := ext.OriginalPodSpec.Containers[0].Env[0].Value; value != "$(KH_CHECK_NAMESPACE)/$(KH_CHECK_NAME)" {
+		t.Fatalf("the check's pod spec was changed to %q", value)
+	}
+}
@@ -28,11 +28,16 @@ import (
 	khjobv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khjob/v1"
 	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/util"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/clock"
 )
 
 // KHReportingURL is the environment variable used to tell external checks where to send their status updates
 const KHReportingURL = "KH_REPORTING_URL"
 
+// KHGRPCReportingAddress is the environment variable used to tell external checks the address of the gRPC reporting
+// service, when Kuberhealthy serves one
+const KHGRPCReportingAddress = "KH_GRPC_REPORTING_ADDRESS"
+
 // KHRunUUID is the environment variable used to tell external checks their check's UUID so that they
 // can be de-duplicated on the server side.
 const KHRunUUID = "KH_RUN_UUID"
@@ -40,6 +45,14 @@ const KHRunUUID = "KH_RUN_UUID"
 // KHDeadline is the environment variable name for when checks must finish their runs by in unixtime
 const KHDeadline = "KH_CHECK_RUN_DEADLINE"
 
+// KHRequestIDHeader is the HTTP header used to trace a single status report through Kuberhealthy.  Checks may set it
+// on their reports and Kuberhealthy always returns it in the response.
+const KHRequestIDHeader = "X-Request-ID"
+
+// KHReportVersionsHeader is the HTTP header Kuberhealthy answers reports with to tell checks the report versions it
+// accepts
+const KHReportVersionsHeader = "X-Kuberhealthy-Report-Versions"
+
 // KHCheckNameAnnotationKey is the annotation which holds the check's name for later validation when the pod calls in
 const KHCheckNameAnnotationKey = "comcast.github.io/check-name"
 
@@ -51,6 +64,16 @@ const KHPodNamespace = "KH_POD_NAMESPACE"
 // are expected to report into.
 const DefaultKuberhealthyReportingURL = "http://kuberhealthy.kuberhealthy.svc.cluster.local/externalCheckStatus"
 
+// Reporting URL modes select how the reporting URL given to checker pods is built.  The service mode uses the
+// kuberhealthy service DNS name, the external hostname mode uses a configured hostname for clusters where the
+// service is not reachable from checker pods, and the pod IP mode uses the IP of the kuberhealthy pod for checks
+// that run with hostNetwork.
+const (
+	ReportingURLModeService          = "service"
+	ReportingURLModeExternalHostname = "externalHostname"
+	ReportingURLModePodIP            = "podIP"
+)
+
 // kuberhealthyRunIDLabel is the pod label for the kuberhealthy run id value
 const kuberhealthyRunIDLabel = "kuberhealthy-run-id"
 
@@ -72,6 +95,40 @@ var ErrPodRemovedUnexpectedly = errors.New("pod deleted unexpectedly")
 // ErrPodDeletedBeforeRunning is a constant for the error when a pod is deleted before the check pod running
 var ErrPodDeletedBeforeRunning = errors.New("the khcheck check pod is deleted, waiting for start failed")
 
+// ProvisioningError is returned by runs whose checker pod could not be created or never got to run, such as when its
+// image can not be pulled or an init container fails.  Kuberhealthy backs off checks that keep failing this way.
+type ProvisioningError struct {
+	Err               error
+	Unschedulable     bool // the checker pod could not be scheduled onto a node
+	StartTimedOut     bool // the checker pod did not start in time
+	AdmissionRejected bool // admission control rejected the checker pod
+}
+
+// Error returns the message of the underlying error
+func (e *ProvisioningError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *ProvisioningError) Unwrap() error {
+	return e.Err
+}
+
+// IsProvisioningError indicates that a run failed because its checker pod could not be provisioned
+func IsProvisioningError(err error) bool {
+	var pe *ProvisioningError
+	return errors.As(err, &pe)
+}
+
+// provisioningWaitingReasons are the reasons a checker container waits with that mean it will never start
+var provisioningWaitingReasons = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
 // DefaultName is used when no check name is supplied
 var DefaultName = "external-check"
 
@@ -96,10 +153,14 @@ type Checker struct {
 	OriginalPodSpec          apiv1.PodSpec // the user-provided spec of the pod
 	RunID                    string        // the uuid of the current run
 	KuberhealthyReportingURL string        // the URL that the check should want to report results back to
+	GRPCReportingAddress     string        // the address of the gRPC reporting service. Not given to checker pods when empty.
+	ReportSigningKey         string        // the key the signing keys of runs are derived from. Reports are not signed when empty.
 	ExtraAnnotations         map[string]string
 	ExtraLabels              map[string]string
+	PropagatedLabels         map[string]string  // labels of the khcheck or khjob carried onto its checker pods.  extraLabels win over them.
 	Node                     string             // the node the checker pod runs on
 	currentCheckUUID         string             // the UUID of the current external checker running
+	currentTraceParent       string             // the W3C trace context of the current run, handed to its checker pods
 	Debug                    bool               // indicates we should run in debug mode - run once and stop
 	shutdownCTXFunc          context.CancelFunc // used to cancel things in-flight when shutting down gracefully
 	shutdownCTX              context.Context    // a context used for shutting down the check gracefully
@@ -107,6 +168,31 @@ type Checker struct {
 	hostname                 string             // hostname cache
 	checkPodName             string             // the current unique checker pod name
 	KHWorkload               khstatev1.KHWorkload
+	Runs                     *RunTracker                 // tracks the validity window of run UUIDs for the report handler
+	SidecarHandling          string                      // how service mesh sidecars in checker pods are handled
+	OS                       string                      // the operating system of the nodes checker pods run on
+	Arch                     string                      // the architecture of the nodes checker pods run on
+	SecurityContextPolicy    string                      // the security context defaults applied to checker pods
+	KuberhealthyAntiAffinity string                      // how checker pods are kept off of the node the Kuberhealthy master runs on
+	KuberhealthyNode         string                      // the node the Kuberhealthy master runs on
+	ServiceAccountTokens     []ServiceAccountToken       // bound service account tokens projected into checker pods
+	IsolatedNamespace        *IsolatedNamespace          // creates an ephemeral namespace for the test resources of each run when set
+	runNamespace             string                      // the ephemeral namespace of the current run, if any
+	ConcurrencyPolicy        khcheckv1.ConcurrencyPolicy // how a run that is still going when the next run is due is handled
+	MaxDeadlineExtension     time.Duration               // the most a run's deadline can be extended by at the request of its checker pod
+	StartTimeout             time.Duration               // the most the checker pod can take to start.  Starting is only bounded by the run timeout when zero
+	HeartbeatTimeout         time.Duration               // how long a run may go without a heartbeat from its checker pod near its deadline.  Heartbeats are not expected when zero
+	Resident                 bool                        // runs are handed to registered resident checkers instead of spawning checker pods
+	Residents                *ResidentRegistry           // the resident checkers registered for resident checks
+	RunOnAllNodes            bool                        // each run spawns a checker pod on every matching node
+	RunPerZone               bool                        // each run spawns a checker pod in every zone of the matching nodes
+	NodeSelector             map[string]string           // the labels of the nodes fanned out runs spawn checker pods on
+	Nodes                    NodeCache                   // the shared node cache fanned out runs select nodes from. Nodes are listed from the API when nil.
+	Aggregation              khcheckv1.Aggregation       // how the results of the nodes or zones of fanned out runs decide the result of the run
+	runMu                    sync.Mutex                  // guards the run context and canceled flag
+	canceled                 bool                        // the current run was canceled
+	SpecErrors               []string                    // problems found in the spec of the check, reported on every run
+	Clock                    clock.Clock                 // the clock runs are timed with. The real clock is used when nil.
 }
 
 func init() {
@@ -139,10 +225,23 @@ func NewCheck(client *kubernetes.Clientset, checkConfig *khcheckv1.KuberhealthyC
 		RunTimeout:               defaultTimeout,
 		ExtraAnnotations:         make(map[string]string),
 		ExtraLabels:              make(map[string]string),
-		OriginalPodSpec:          checkConfig.Spec.PodSpec,
-		PodSpec:                  checkConfig.Spec.PodSpec,
+		OriginalPodSpec:          *checkConfig.Spec.EffectivePodSpec(),
+		PodSpec:                  *checkConfig.Spec.EffectivePodSpec(),
 		KubeClient:               client,
 		KHWorkload:               khstatev1.KHCheck,
+		SidecarHandling:          checkConfig.Spec.SidecarHandling,
+		OS:                       checkConfig.Spec.OS,
+		Arch:                     checkConfig.Spec.Arch,
+		SecurityContextPolicy:    checkConfig.Spec.SecurityContextPolicy,
+		KuberhealthyAntiAffinity: checkConfig.Spec.KuberhealthyAntiAffinity,
+		ServiceAccountTokens:     checkServiceAccountTokens(checkConfig.Spec.ServiceAccountTokens),
+		IsolatedNamespace:        checkIsolatedNamespace(checkConfig.Spec.IsolatedNamespace),
+		ConcurrencyPolicy:        checkConfig.Spec.ConcurrencyPolicy,
+		Resident:                 checkConfig.Spec.Resident,
+		RunOnAllNodes:            checkConfig.Spec.RunOnAllNodes,
+		RunPerZone:               checkConfig.Spec.RunPerZone,
+		NodeSelector:             checkConfig.Spec.NodeSelector,
+		Aggregation:              checkAggregation(checkConfig.Spec.Aggregation),
 	}
 }
 
@@ -162,10 +261,16 @@ func NewJob(client *kubernetes.Clientset, jobConfig *khjobv1.KuberhealthyJob, kh
 		KuberhealthyReportingURL: reportingURL,
 		ExtraAnnotations:         make(map[string]string),
 		ExtraLabels:              make(map[string]string),
-		OriginalPodSpec:          jobConfig.Spec.PodSpec,
-		PodSpec:                  jobConfig.Spec.PodSpec,
+		OriginalPodSpec:          *jobConfig.Spec.EffectivePodSpec(),
+		PodSpec:                  *jobConfig.Spec.EffectivePodSpec(),
 		KubeClient:               client,
 		KHWorkload:               khstatev1.KHJob,
+		SidecarHandling:          jobConfig.Spec.SidecarHandling,
+		OS:                       jobConfig.Spec.OS,
+		Arch:                     jobConfig.Spec.Arch,
+		SecurityContextPolicy:    jobConfig.Spec.SecurityContextPolicy,
+		ServiceAccountTokens:     jobServiceAccountTokens(jobConfig.Spec.ServiceAccountTokens),
+		IsolatedNamespace:        jobIsolatedNamespace(jobConfig.Spec.IsolatedNamespace),
 	}
 }
 
@@ -224,6 +329,11 @@ func (ext *Checker) Name() string {
 	return ext.CheckName
 }
 
+// CurrentUUID returns the UUID of the current or most recent run of this checker
+func (ext *Checker) CurrentUUID() string {
+	return ext.currentCheckUUID
+}
+
 // CheckNamespace returns the namespace of this checker
 func (ext *Checker) CheckNamespace() string {
 	return ext.Namespace
@@ -246,6 +356,15 @@ func (ext *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error
 	// store the client in the checker
 	ext.KubeClient = client
 
+	// pick up a run that was in flight when Kuberhealthy restarted instead of starting a duplicate checker pod
+	if run, ok := ext.Runs.Resume(ext.CheckName, ext.Namespace, ext.clock().Now()); ok {
+		err := ext.resumeRun(ctx, run)
+		ext.Runs.End(run.UUID)
+		if err != errRunNotResumed {
+			return err
+		}
+	}
+
 	// generate a new UUID for each run
 	err := ext.setNewCheckUUID()
 	if err != nil {
@@ -254,7 +373,36 @@ func (ext *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error
 
 	// run a check iteration
 	ext.log("Running external check iteration")
-	err = ext.RunOnce(ctx)
+	switch {
+	case ext.Resident:
+		err = ext.runResident(ctx)
+	case ext.RunOnAllNodes:
+		err = ext.runOnAllNodes(ctx)
+	case ext.RunPerZone:
+		err = ext.runPerZone(ctx)
+	default:
+		err = ext.RunOnce(ctx)
+	}
+
+	// runs left for the next master to adopt are neither canceled nor reported on by this instance
+	if ctx.Err() != nil && ext.Runs.Frozen() {
+		ext.log("run", ext.currentCheckUUID, "was left for the next master")
+		return ErrRunCanceled
+	}
+
+	// runs stopped along with the check are canceled so that their checker pod learns to stop if it is still going
+	if ctx.Err() != nil {
+		ext.Runs.Cancel(ext.currentCheckUUID, checkStoppedReason)
+	}
+
+	// reports from a canceled run arrive after Kuberhealthy stopped waiting on it, so they are seen as late
+	if ext.runCanceled() {
+		ext.Runs.Expire(ext.currentCheckUUID)
+		ext.Runs.End(ext.currentCheckUUID)
+		ext.log("run", ext.currentCheckUUID, "was canceled")
+		return ErrRunCanceled
+	}
+	ext.Runs.End(ext.currentCheckUUID)
 
 	// if the pod was removed, we skip this run gracefully
 	if err != nil && err.Error() == ErrPodRemovedExpectedly.Error() {
@@ -287,11 +435,21 @@ func (ext *Checker) getCheck() (*khcheckv1.KuberhealthyCheck, error) {
 // cleanup cleans up any running, pending, or unknown checker pods by evicting them. Succeeded or Failed pods are left alone for records
 // if eviction fails, cleanup will attempt to forcefully kill the pod.
 func (ext *Checker) cleanup(ctx context.Context) {
+	// the checker pods of runs left for the next master to adopt keep running
+	if ext.Runs.Frozen() {
+		ext.log("Leaving pods with name", ext.podName(), "for the next master")
+		return
+	}
+
 	ext.log("Evicting up any running pods with name", ext.podName())
 	podClient := ext.KubeClient.CoreV1().Pods(ext.Namespace)
 
-	// find all pods that are running still so we can evict them (not delete - for records)
+	// find all pods that are running still so we can evict them (not delete - for records).  Overlapping runs only
+	// clean up their own pod so that they leave the pods of other runs alone.
 	checkLabelSelector := kuberhealthyCheckNameLabel + " = " + ext.CheckName
+	if ext.Overlaps() {
+		checkLabelSelector += "," + kuberhealthyRunIDLabel + " = " + ext.currentCheckUUID
+	}
 	ext.log("eviction: looking for pods with the label", checkLabelSelector)
 	podList, err := podClient.List(ctx, metav1.ListOptions{
 		LabelSelector: checkLabelSelector,
@@ -384,8 +542,9 @@ func (ext *Checker) setUUID(uuid string) error {
 		return nil
 	}
 
-	// assign the new uuid to the fetched checkState
+	// assign the new uuid to the fetched checkState and drop the progress of the previous run
 	checkState.Spec.CurrentUUID = uuid
+	checkState.Spec.Progress = nil
 	ext.log("Updating khstate to CurrentUUID:", checkState.Spec.CurrentUUID)
 	_, err = ext.KHStateClient.KuberhealthyStates(ext.CheckNamespace()).Update(&checkState)
 	if err != nil {
@@ -425,6 +584,7 @@ func (ext *Checker) setUUID(uuid string) error {
 			log.Errorln("failed to fetch khstate for check", checkState.Namespace, checkState.Name, "with error:", err)
 		}
 		checkState.Spec.CurrentUUID = uuid
+		checkState.Spec.Progress = nil
 		_, err = ext.KHStateClient.KuberhealthyStates(ext.CheckNamespace()).Update(&checkState)
 		if err != nil {
 			log.Errorln("failed to update khstate CurrentUUID for check", checkState.Namespace, checkState.Name, "with error:", err)
@@ -544,7 +704,7 @@ func (ext *Checker) newError(s string) error {
 func (ext *Checker) RunOnce(ctx context.Context) error {
 
 	// create a context for this run
-	ext.shutdownCTX, ext.shutdownCTXFunc = context.WithCancel(ctx)
+	ext.beginRun(ctx)
 	defer ext.shutdownCTXFunc()
 	defer ext.cleanup(ctx)
 
@@ -558,6 +718,11 @@ func (ext *Checker) RunOnce(ctx context.Context) error {
 		return err
 	}
 
+	// report problems found in the spec before anything is scheduled
+	if len(ext.SpecErrors) > 0 {
+		return errors.New("invalid check spec: " + strings.Join(ext.SpecErrors, "; "))
+	}
+
 	// validate the pod spec
 	ext.log("Validating pod spec of external check")
 	err = ext.validatePodSpec()
@@ -567,8 +732,23 @@ func (ext *Checker) RunOnce(ctx context.Context) error {
 
 	// init a timeout for this whole check
 	ext.log("Timeout set to", ext.RunTimeout.String())
-	deadline := time.Now().Add(ext.RunTimeout)
-	timeoutChan := time.After(ext.RunTimeout)
+	deadline := ext.clock().Now().Add(ext.RunTimeout)
+
+	// register the validity window of this run so that reports arriving after the deadline are seen as late
+	ext.Runs.Start(ext.currentCheckUUID, ext.CheckName, ext.Namespace, ext.podName(), deadline)
+	if ext.MaxDeadlineExtension > 0 {
+		ext.Runs.AllowExtension(ext.currentCheckUUID, ext.MaxDeadlineExtension)
+	}
+	if ext.HeartbeatTimeout > 0 {
+		ext.Runs.ExpectHeartbeats(ext.currentCheckUUID, ext.HeartbeatTimeout)
+	}
+	ext.startTrace()
+	timeoutChan := ext.deadlineReached(ext.currentCheckUUID, deadline)
+
+	// name the ephemeral namespace of this run so that it can be handed to the checker pod
+	if ext.IsolatedNamespace != nil {
+		ext.runNamespace = RunNamespaceName(ext.CheckName, ext.currentCheckUUID)
+	}
 
 	// condition the spec with the required labels and environment variables
 	ext.log("Configuring spec of external check")
@@ -584,11 +764,26 @@ func (ext *Checker) RunOnce(ctx context.Context) error {
 		return err
 	}
 
+	// fail early if pod security admission would reject the checker pod
+	ext.log("Validating checker pod against the pod security standard of namespace", ext.Namespace)
+	err = ext.validatePodSecurity(ctx)
+	if err != nil {
+		return ext.newError(err.Error())
+	}
+
+	// fail early if the priority class or runtime class of the checker pod does not exist
+	ext.log("Validating priority class and runtime class of checker pod")
+	err = ext.validatePodSpecReferences(ctx)
+	if err != nil {
+		return ext.newError(err.Error())
+	}
+
 	// waiting until all checker pods are gone...
 	ext.log("Waiting for all existing pods to clean up")
 	select {
 	case <-timeoutChan:
 		ext.log("timed out waiting for all existing pods to clean up")
+		ext.Runs.Expire(ext.currentCheckUUID)
 		errorMessage := "failed to see pod cleanup within timeout"
 		return ext.newError(errorMessage)
 	case err = <-ext.waitForAllPodsToClear(ctx):
@@ -603,6 +798,23 @@ func (ext *Checker) RunOnce(ctx context.Context) error {
 	}
 	ext.log("No checker pods exist.")
 
+	// fail early if admission control would reject the checker pod.  This is done once the old checker pods are
+	// gone so that they don't count against a resource quota.
+	ext.log("Validating checker pod with a dry run")
+	err = ext.validatePodAdmission(ctx)
+	if err != nil {
+		return err
+	}
+
+	// create the ephemeral namespace for the test resources of this run.  It is deleted with everything in it
+	// once the run is over.
+	err = ext.setupRunNamespace(ctx, deadline)
+	if err != nil {
+		ext.runNamespace = ""
+		return &ProvisioningError{Err: ext.newError(err.Error())}
+	}
+	defer ext.teardownRunNamespace(ctx)
+
 	// Spawn a waiter to see if the pod is deleted.  If this happens, we consider this check aborted cleanly
 	// and continue on to the next interval because deletes normally occur from admin intervention.  We create
 	// a unique context here because we want to cancel this watch before the check times out, but before
@@ -617,7 +829,7 @@ func (ext *Checker) RunOnce(ctx context.Context) error {
 	createdPod, err := ext.createPod(ctx)
 	if err != nil {
 		ext.log("error creating pod")
-		return ext.newError("failed to create pod for checker: " + err.Error())
+		return &ProvisioningError{Err: ext.newError("failed to create pod for checker: " + err.Error()), AdmissionRejected: admissionRejected(err)}
 	}
 	ext.log("Check", ext.Name(), "created pod", createdPod.Name, "in namespace", createdPod.Namespace)
 
@@ -625,7 +837,12 @@ func (ext *Checker) RunOnce(ctx context.Context) error {
 	select {
 	case <-timeoutChan: // were out of time
 		ext.log("timed out waiting for pod to startup")
-		return ext.newError("failed to see pod running within timeout")
+		ext.Runs.Expire(ext.currentCheckUUID)
+		return ext.startTimeoutError(ctx, "failed to see pod running within timeout")
+	case <-ext.startTimeoutReached(): // the pod took too long to start
+		ext.log("pod did not start within its start timeout of", ext.StartTimeout)
+		ext.Runs.Expire(ext.currentCheckUUID)
+		return ext.startTimeoutError(ctx, "failed to see pod running within start timeout of "+ext.StartTimeout.String())
 	case err := <-podDeletedChan: // pod removed unexpectedly
 		if err != nil {
 			ext.log("error from pod shutdown watcher when watching for checker pod to start:", err.Error())
@@ -639,7 +856,10 @@ func (ext *Checker) RunOnce(ctx context.Context) error {
 			ext.cleanup(ctx)
 			errorMessage := "error when waiting for pod to start: " + err.Error()
 			ext.log(errorMessage)
-			return ext.newError(errorMessage)
+			if err == ErrPodDeletedBeforeRunning {
+				return ext.newError(errorMessage)
+			}
+			return &ProvisioningError{Err: ext.newError(errorMessage)}
 		}
 		// flag the pod as running until this run ends
 		ext.log("External check pod is running:", ext.podName())
@@ -653,9 +873,10 @@ func (ext *Checker) RunOnce(ctx context.Context) error {
 	select {
 	case <-timeoutChan: // out of time
 		ext.log("timed out waiting for pod status to be reported")
+		ext.Runs.Expire(ext.currentCheckUUID)
 		errorMessage := "timed out waiting for checker pod to report in"
 		ext.log(errorMessage)
-		return ext.newError(errorMessage)
+		return &TimeoutError{Err: ext.newError(errorMessage)}
 	case err := <-podDeletedChan: // pod was removed
 		if err != nil {
 			ext.log("error from pod shutdown watcher when watching for checker pod to report results:", err.Error())
@@ -684,7 +905,7 @@ func (ext *Checker) RunOnce(ctx context.Context) error {
 	case <-timeoutChan: // out of time
 		errorMessage := "timed out waiting for pod to exit"
 		ext.log(errorMessage)
-		return ext.newError(errorMessage)
+		return &TimeoutError{Err: ext.newError(errorMessage)}
 	case err = <-ext.waitForPodExit(ctx): // pod stopped running
 		ext.log("External check pod is done running:", ext.podName())
 		if err != nil {
@@ -701,6 +922,84 @@ func (ext *Checker) RunOnce(ctx context.Context) error {
 	return nil
 }
 
+// errRunNotResumed indicates that a restored run could not be resumed and a new run should be started instead
+var errRunNotResumed = errors.New("restored run could not be resumed")
+
+// resumeRun waits on a run that was in flight when Kuberhealthy restarted.  The checker pod of the run was created by
+// the previous Kuberhealthy master and still holds the run's UUID, so its report is waited on until the original
+// deadline instead of starting a duplicate checker pod.
+func (ext *Checker) resumeRun(ctx context.Context, run Run) error {
+
+	// create a context for this run
+	ext.beginRun(ctx)
+	defer ext.shutdownCTXFunc()
+
+	ext.currentCheckUUID = run.UUID
+	ext.currentTraceParent = run.TraceParent
+	ext.checkPodName = run.PodName
+
+	// only resume the run if its checker pod is still around and the khstate still expects its UUID
+	exists, err := util.PodNameExists(ext.KubeClient, run.PodName, ext.Namespace)
+	if err != nil || !exists {
+		ext.log("checker pod", run.PodName, "of restored run no longer exists. starting a new run")
+		return errRunNotResumed
+	}
+	state, err := ext.getKHState()
+	if err != nil || state.Spec.CurrentUUID != run.UUID {
+		ext.log("khstate no longer expects restored run", run.UUID+". starting a new run")
+		return errRunNotResumed
+	}
+	defer ext.cleanup(ctx)
+
+	// the ephemeral namespace of the run is named after it, so it can be found and removed once the run is over
+	if ext.IsolatedNamespace != nil {
+		ext.runNamespace = RunNamespaceName(ext.CheckName, run.UUID)
+		defer ext.teardownRunNamespace(ctx)
+	}
+
+	ext.log("Resuming run with checker pod", run.PodName, "started at", run.Started)
+	timeoutChan := ext.deadlineReached(run.UUID, run.Deadline)
+
+	// wait for the pod to report in since the run started
+	select {
+	case <-timeoutChan:
+		ext.log("timed out waiting for pod status to be reported")
+		ext.Runs.Expire(run.UUID)
+		return &TimeoutError{Err: ext.newError("timed out waiting for checker pod to report in")}
+	case err = <-ext.waitForPodStatusUpdate(metav1.NewTime(run.Started)):
+		if err != nil {
+			errorMessage := "found an error when waiting for pod status to update: " + err.Error()
+			ext.log(errorMessage)
+			return ext.newError(errorMessage)
+		}
+		ext.log("External check pod has reported status for this check iteration:", ext.podName())
+	case <-ext.shutdownCTX.Done():
+		ext.log("shutting down check. aborting wait for pod status to update")
+		return nil
+	}
+
+	// wait for the pod to exit
+	select {
+	case <-timeoutChan:
+		errorMessage := "timed out waiting for pod to exit"
+		ext.log(errorMessage)
+		return &TimeoutError{Err: ext.newError(errorMessage)}
+	case err = <-ext.waitForPodExit(ctx):
+		if err != nil {
+			errorMessage := "found an error when waiting for pod to exit: " + err.Error()
+			ext.log(errorMessage)
+			return ext.newError(errorMessage)
+		}
+		ext.log("External check pod is done running:", ext.podName())
+	case <-ext.shutdownCTX.Done():
+		ext.log("shutting down check. aborting wait for pod to be done running")
+		return nil
+	}
+
+	ext.log("Resumed run completed!")
+	return nil
+}
+
 // log writes a normal InfoLn message output prefixed with this checker's name on it
 func (ext *Checker) log(s ...interface{}) {
 	log.Infoln(ext.currentCheckUUID+" "+ext.Namespace+"/"+ext.CheckName+":", s)
@@ -798,6 +1097,12 @@ func (ext *Checker) waitForPodStatusUpdate(lastUpdateTime metav1.Time) chan erro
 
 // podHasReportedInAfterTime indicates if a pod has reported a state since the supplied timestamp
 func (ext *Checker) podHasReportedInAfterTime(t metav1.Time) (bool, error) {
+	// a run overtaken by an overlapping run only has its report recorded in the run history, so the run tracker is
+	// asked first
+	if run, ok := ext.Runs.Get(ext.currentCheckUUID); ok && run.State == RunReported {
+		return true, nil
+	}
+
 	// fetch the lastUpdateTime from the khstate as of right now
 	currentUpdateTime, err := ext.getCheckLastUpdateTime()
 	if err != nil {
@@ -903,6 +1208,13 @@ func (ext *Checker) waitForPodExit(ctx context.Context) chan error {
 			var podExists bool
 			for _, p := range pods.Items {
 
+				// pods with sidecars keep running after the checker containers exit.  If we tolerate sidecars, the
+				// pod is done once the checker containers have exited.  The pod is evicted when the run is cleaned up.
+				if p.Status.Phase == apiv1.PodRunning && ext.toleratesSidecars() && checkerContainersExited(p, ext.PodSpec) {
+					ext.log("checker containers exited in pod", p.Name, "- ignoring remaining sidecar containers")
+					continue
+				}
+
 				// if the pod is running or pending, we consider it to "exist"
 				if p.Status.Phase == apiv1.PodRunning || p.Status.Phase == apiv1.PodPending {
 					podExists = true
@@ -995,17 +1307,13 @@ func (ext *Checker) waitForPodStart(ctx context.Context) chan error {
 					continue
 				}
 
-				// catch when the pod has an error image pull and return it as an error #201
-				for _, containerStat := range p.Status.ContainerStatuses {
-					if containerStat.State.Waiting == nil {
-						continue
-					}
-					if containerStat.State.Waiting.Reason == "ErrImagePull" {
-						ext.log("pod had an error image pull")
-						outChan <- errors.New(containerStat.State.Waiting.Reason)
-						watcher.Stop()
-						return
-					}
+				// catch when the pod can never start, such as an error image pull, and return it as an error #201
+				err = podProvisioningError(p)
+				if err != nil {
+					ext.log("pod failed to provision:", err)
+					outChan <- err
+					watcher.Stop()
+					return
 				}
 				// read the status of this pod (its ours)
 				ext.log("pod state is now:", string(p.Status.Phase))
@@ -1033,6 +1341,36 @@ func (ext *Checker) waitForPodStart(ctx context.Context) chan error {
 	return outChan
 }
 
+// podProvisioningError returns an error when a checker pod will never get to run its checker containers.  This is
+// the case when a container waits on an image that can not be pulled or a config that can not be created, or when
+// an init container fails.
+func podProvisioningError(p *apiv1.Pod) error {
+	statuses := append(append([]apiv1.ContainerStatus{}, p.Status.InitContainerStatuses...), p.Status.ContainerStatuses...)
+	for _, containerStat := range statuses {
+		if containerStat.State.Waiting != nil && provisioningWaitingReasons[containerStat.State.Waiting.Reason] {
+			return errors.New(containerStat.State.Waiting.Reason)
+		}
+	}
+
+	// init containers that fail in a pod that is not restarted, such as when they run out of memory, fail the pod
+	if p.Status.Phase != apiv1.PodFailed {
+		return nil
+	}
+	for _, containerStat := range p.Status.InitContainerStatuses {
+		terminated := containerStat.State.Terminated
+		if terminated != nil && terminated.ExitCode != 0 {
+			return fmt.Errorf("init container %s failed: %s", containerStat.Name, terminated.Reason)
+		}
+	}
+	return nil
+}
+
+// ValidatePodSpec validates the pod spec of the check the same way every run does, so that a khcheck can be
+// validated before it is applied
+func (ext *Checker) ValidatePodSpec() error {
+	return ext.validatePodSpec()
+}
+
 // validatePodSpec validates the user specified pod spec to ensure it looks like it
 // has all the default configuration required
 func (ext *Checker) validatePodSpec() error {
@@ -1054,12 +1392,28 @@ func (ext *Checker) validatePodSpec() error {
 		}
 	}
 
-	return nil
+	// ensure that the requested service account tokens can be projected
+	err := validateServiceAccountTokens(ext.ServiceAccountTokens)
+	if err != nil {
+		return err
+	}
+
+	// ensure that the scheduling and networking fields passed through to the pod are valid
+	return validateSchedulingFields(ext.PodSpec)
 }
 
 // createPod prepares and creates the checker pod using the kubernetes API
 func (ext *Checker) createPod(ctx context.Context) (*apiv1.Pod, error) {
 	ext.log("Creating external checker pod named", ext.podName())
+	p, err := ext.buildPod()
+	if err != nil {
+		return nil, err
+	}
+	return ext.KubeClient.CoreV1().Pods(ext.Namespace).Create(ctx, p, metav1.CreateOptions{})
+}
+
+// buildPod prepares the checker pod of the current run
+func (ext *Checker) buildPod() (*apiv1.Pod, error) {
 	p := &apiv1.Pod{}
 	p.Annotations = make(map[string]string)
 	p.Labels = make(map[string]string)
@@ -1070,21 +1424,31 @@ func (ext *Checker) createPod(ctx context.Context) (*apiv1.Pod, error) {
 	// enforce various labels and annotations on all checker pods created
 	ext.addKuberhealthyLabels(p)
 
+	err := ext.setOwnerReference(p)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// setOwnerReference makes the Kuberhealthy deployment the owner of checker pods in the kuberhealthy namespace
+func (ext *Checker) setOwnerReference(p *apiv1.Pod) error {
+
 	// only set ownerReference for pods in the kuberhealthy namespace
 	// as cross-namespace owner references are disabled by design
-	if p.Namespace == kuberhealthyNamespace {
-
-		// Get ownerReference for the kuberhealthy pod
-		ownerRef, err := util.GetOwnerRef(ext.KubeClient, kuberhealthyNamespace)
-		if err != nil {
-			return nil, errors.New("Failed to getOwnerReference for pod: " + p.Name + ", err: " + err.Error())
-		}
+	if p.Namespace != kuberhealthyNamespace {
+		return nil
+	}
 
-		// Set ownerReference on checker pods in kuberhealthy namespace
-		p.OwnerReferences = ownerRef
+	// Get ownerReference for the kuberhealthy pod
+	ownerRef, err := util.GetOwnerRef(ext.KubeClient, kuberhealthyNamespace)
+	if err != nil {
+		return errors.New("Failed to getOwnerReference for pod: " + p.Name + ", err: " + err.Error())
 	}
 
-	return ext.KubeClient.CoreV1().Pods(ext.Namespace).Create(ctx, p, metav1.CreateOptions{})
+	// Set ownerReference on checker pods in kuberhealthy namespace
+	p.OwnerReferences = ownerRef
+	return nil
 }
 
 // configureUserPodSpec configures a user-specified pod spec with
@@ -1093,8 +1457,9 @@ func (ext *Checker) createPod(ctx context.Context) (*apiv1.Pod, error) {
 // overwrite user-specified values.
 func (ext *Checker) configureUserPodSpec(deadline time.Time) error {
 
-	// start with a fresh spec each time we regenerate the spec
-	ext.PodSpec = ext.OriginalPodSpec
+	// start with a fresh spec each time we regenerate the spec.  the spec is deep copied so that defaults applied
+	// to containers are never written back into the original spec.
+	ext.PodSpec = *ext.OriginalPodSpec.DeepCopy()
 
 	// specify environment variables that need applied.  We apply environment
 	// variables that set the report-in URL of kuberhealthy along with
@@ -1122,12 +1487,77 @@ func (ext *Checker) configureUserPodSpec(deadline time.Time) error {
 		},
 	}
 
+	// tell the checker pod which ephemeral namespace to create its test resources in
+	if len(ext.runNamespace) > 0 {
+		overwriteEnvVars = append(overwriteEnvVars, apiv1.EnvVar{
+			Name:  KHRunNamespace,
+			Value: ext.runNamespace,
+		})
+	}
+
+	// tell the checker client how often it has to send heartbeats
+	if ext.HeartbeatTimeout > 0 {
+		overwriteEnvVars = append(overwriteEnvVars, apiv1.EnvVar{
+			Name:  KHHeartbeatTimeout,
+			Value: strconv.FormatInt(int64(ext.HeartbeatTimeout/time.Second), 10),
+		})
+	}
+
+	// tell the checker client where the gRPC reporting service is
+	if len(ext.GRPCReportingAddress) > 0 {
+		overwriteEnvVars = append(overwriteEnvVars, apiv1.EnvVar{
+			Name:  KHGRPCReportingAddress,
+			Value: ext.GRPCReportingAddress,
+		})
+	}
+
+	// give the checker client the key to sign its reports with
+	if len(ext.ReportSigningKey) > 0 {
+		overwriteEnvVars = append(overwriteEnvVars, apiv1.EnvVar{
+			Name:  KHReportSigningKey,
+			Value: RunSigningKey(ext.ReportSigningKey, ext.currentCheckUUID),
+		})
+	}
+
+	// hand the trace context of the run to the checker client, which sends it back with its report
+	if len(ext.currentTraceParent) > 0 {
+		overwriteEnvVars = append(overwriteEnvVars, apiv1.EnvVar{
+			Name:  KHTraceParent,
+			Value: ext.currentTraceParent,
+		})
+	}
+
+	// tell the checker client to shut down sidecars once it has reported
+	if ext.SidecarHandling == SidecarHandlingQuit {
+		overwriteEnvVars = append(overwriteEnvVars, apiv1.EnvVar{
+			Name:  KHSidecarQuit,
+			Value: "true",
+		})
+	}
+
 	// apply overwrite env vars on every container in the pod
 	for i := range ext.PodSpec.Containers {
-		ext.PodSpec.Containers[i].Env = resetInjectedContainerEnvVars(ext.PodSpec.Containers[i].Env, []string{KHReportingURL, KHRunUUID, KHPodNamespace, KHDeadline})
+		ext.PodSpec.Containers[i].Env = resetInjectedContainerEnvVars(ext.PodSpec.Containers[i].Env, []string{KHReportingURL, KHRunUUID, KHPodNamespace, KHDeadline, KHSidecarQuit, KHRunNamespace, KHHeartbeatTimeout, KHReportSigningKey, KHGRPCReportingAddress, KHTraceParent})
 		ext.PodSpec.Containers[i].Env = append(ext.PodSpec.Containers[i].Env, overwriteEnvVars...)
 	}
 
+	// fill in references to the check and this run in the environment variables of the pod
+	fields := ext.interpolationFields(deadline)
+	interpolateEnvVars(ext.PodSpec.InitContainers, fields)
+	interpolateEnvVars(ext.PodSpec.Containers, fields)
+
+	// project any requested service account tokens into the pod
+	ext.configureServiceAccountTokens()
+
+	// schedule the pod onto nodes of the configured operating system and architecture
+	ext.configurePlatform()
+
+	// keep the pod off of the node the kuberhealthy master runs on
+	ext.configureKuberhealthyAntiAffinity()
+
+	// fill in unset security context settings so that checker pods meet the restricted pod security standard
+	ext.applySecurityContextDefaults()
+
 	// enforce restart policy of never
 	ext.PodSpec.RestartPolicy = apiv1.RestartPolicyNever
 
@@ -1148,6 +1578,11 @@ func (ext *Checker) addKuberhealthyLabels(pod *apiv1.Pod) {
 		pod.ObjectMeta.Labels = make(map[string]string)
 	}
 
+	// apply the labels propagated from the khcheck or khjob itself
+	for k, v := range ext.PropagatedLabels {
+		pod.ObjectMeta.Labels[k] = v
+	}
+
 	// apply all extra labels to pod as specified by khcheck spec
 	for k, v := range ext.ExtraLabels {
 		pod.ObjectMeta.Labels[k] = v
@@ -1163,6 +1598,13 @@ func (ext *Checker) addKuberhealthyLabels(pod *apiv1.Pod) {
 		pod.ObjectMeta.Annotations = make(map[string]string)
 	}
 
+	// keep service meshes from injecting sidecars if requested
+	if ext.SidecarHandling == SidecarHandlingExclude {
+		for k, v := range sidecarExclusionAnnotations {
+			pod.ObjectMeta.Annotations[k] = v
+		}
+	}
+
 	// ensure all extra annotations are applied as specified in the khcheck
 	for k, v := range ext.ExtraAnnotations {
 		pod.ObjectMeta.Annotations[k] = v
@@ -1220,15 +1662,33 @@ func (ext *Checker) waitForShutdown(ctx context.Context) chan error {
 	return doneChan
 }
 
-// Shutdown signals the checker to begin a shutdown and cleanup
+// Shutdown signals the checker to begin a shutdown and cleanup.  When the run tracker is frozen, the run in progress
+// and its checker pod are left for the next master instead.
 func (ext *Checker) Shutdown() error {
 
+	// runs left for the next master to adopt keep their checker pod, so only this checker is stopped
+	if ext.Runs.Frozen() {
+		ext.log("leaving the run in progress for the next master")
+		if ext.shutdownCTXFunc != nil {
+			ext.shutdownCTXFunc()
+		}
+		return nil
+	}
+
+	// tell the checker pod of the run in progress that its result is no longer wanted
+	inFlight := ext.cancelInFlightRun(checkStoppedReason)
+
 	// cancel the context for this checker run
 	if ext.shutdownCTXFunc != nil {
 		ext.log("aborting context for this check due to shutdown call")
 		ext.shutdownCTXFunc()
 	}
 
+	// deleting the checker pod sends it SIGTERM and gives it its termination grace period to clean up after itself
+	if inFlight {
+		ext.terminateCheckerPod()
+	}
+
 	// make a context to track pod removal and cleanup
 	ctx, ctxCancel := context.WithTimeout(context.Background(), ext.Timeout())
 	defer ctxCancel()
@@ -3,6 +3,8 @@ package external
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -125,3 +127,62 @@ func TestShutdown(t *testing.T) {
 		t.Log("Check shutdown properly and without error")
 	}
 }
+
+// TestPodProvisioningError ensures checker pods that will never start are recognized
+func TestPodProvisioningError(t *testing.T) {
+	waiting := func(reason string) apiv1.ContainerStatus {
+		return apiv1.ContainerStatus{State: apiv1.ContainerState{Waiting: &apiv1.ContainerStateWaiting{Reason: reason}}}
+	}
+	var testCases = []struct {
+		description string
+		pod         apiv1.Pod
+		expectError bool
+	}{
+		{"pending pod", apiv1.Pod{Status: apiv1.PodStatus{Phase: apiv1.PodPending, ContainerStatuses: []apiv1.ContainerStatus{waiting("ContainerCreating")}}}, false},
+		{"image pull backoff", apiv1.Pod{Status: apiv1.PodStatus{Phase: apiv1.PodPending, ContainerStatuses: []apiv1.ContainerStatus{waiting("ImagePullBackOff")}}}, true},
+		{"init container image error", apiv1.Pod{Status: apiv1.PodStatus{Phase: apiv1.PodPending, InitContainerStatuses: []apiv1.ContainerStatus{waiting("ErrImagePull")}}}, true},
+		{"init container killed", apiv1.Pod{Status: apiv1.PodStatus{Phase: apiv1.PodFailed, InitContainerStatuses: []apiv1.ContainerStatus{
+			{Name: "init", State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"}}},
+		}}}, true},
+		{"init container completed", apiv1.Pod{Status: apiv1.PodStatus{Phase: apiv1.PodFailed, InitContainerStatuses: []apiv1.ContainerStatus{
+			{Name: "init", State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 0, Reason: "Completed"}}},
+		}}}, false},
+	}
+
+	for _, tc := range testCases {
+		err := podProvisioningError(&tc.pod)
+		if (err != nil) != tc.expectError {
+			t.Fatalf("%s: expected error %t but got %v", tc.description, tc.expectError, err)
+		}
+	}
+
+	if !IsProvisioningError(fmt.Errorf("run failed: %w", &ProvisioningError{Err: errors.New("ErrImagePull")})) {
+		t.Fatalf("wrapped provisioning error was not recognized")
+	}
+}
+
+// TestAddKuberhealthyLabels ensures the labels propagated from a khcheck are added to its checker pods, that
+// extraLabels win over them and that the labels kuberhealthy relies on can not be overridden
+func TestAddKuberhealthyLabels(t *testing.T) {
+	ext := &Checker{
+		CheckName:        "dns",
+		currentCheckUUID: "run-1",
+		PropagatedLabels: map[string]string{"team": "network", "tier": "critical", "app": "dns-check"},
+		ExtraLabels:      map[string]string{"tier": "best-effort"},
+	}
+	pod := &apiv1.Pod{}
+	ext.addKuberhealthyLabels(pod)
+
+	expected := map[string]string{
+		"team":                     "network",
+		"tier":                     "best-effort",
+		"app":                      "kuberhealthy-check",
+		kuberhealthyRunIDLabel:     "run-1",
+		kuberhealthyCheckNameLabel: "dns",
+	}
+	for key, value := range expected {
+		if pod.Labels[key] != value {
+			t.Fatalf("expected label %s to be %q but got %q", key, value, pod.Labels[key])
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package external
+
+import (
+	"context"
+	"sort"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ZoneAnnotation is the annotation set on the checker pods of runs per zone that holds the zone they were spawned
+// for
+const ZoneAnnotation = "comcast.github.io/zone"
+
+// selectRunZones lists the topology zones that a run per zone spawns checker pods in.  Only zones with a ready,
+// uncordoned node that matches the selector are used, and nodes without a zone label are left out.
+func selectRunZones(ctx context.Context, client kubernetes.Interface, cache NodeCache, selector map[string]string) ([]string, error) {
+	nodes, err := schedulableNodes(ctx, client, cache, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var zones []string
+	for _, n := range nodes {
+		zone := n.Labels[apiv1.LabelTopologyZone]
+		if len(zone) == 0 || seen[zone] {
+			continue
+		}
+		seen[zone] = true
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	return zones, nil
+}
+
+// pinToZone requires a pod spec to be scheduled on a node in the supplied zone
+func pinToZone(spec *apiv1.PodSpec, zone string) {
+	requireNodes(spec, apiv1.NodeSelectorRequirement{
+		Key:      apiv1.LabelTopologyZone,
+		Operator: apiv1.NodeSelectorOpIn,
+		Values:   []string{zone},
+	}, false)
+}
+
+// runPerZone spawns a checker pod in every zone of the matching nodes and waits until all of them have reported
+func (ext *Checker) runPerZone(ctx context.Context) error {
+	return ext.runFanOut(ctx, FanOutZones)
+}
@@ -0,0 +1,66 @@
+package external
+
+import (
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// Node labels set by the kubelet that describe the operating system and architecture of a node
+const (
+	nodeOSLabel   = "kubernetes.io/os"
+	nodeArchLabel = "kubernetes.io/arch"
+)
+
+// windowsToleration tolerates the taint commonly placed on Windows node pools to keep Linux pods off of them
+var windowsToleration = apiv1.Toleration{
+	Key:      "os",
+	Operator: apiv1.TolerationOpEqual,
+	Value:    "windows",
+	Effect:   apiv1.TaintEffectNoSchedule,
+}
+
+// configurePlatform schedules checker pods onto nodes with the operating system and architecture configured for the
+// check.  Node selectors already present in the check's pod spec are left alone.  Pods for Windows also tolerate the
+// taint commonly placed on Windows node pools and have their pod OS set.
+func (ext *Checker) configurePlatform() {
+	if len(ext.OS) == 0 && len(ext.Arch) == 0 {
+		return
+	}
+
+	// copy the node selectors and tolerations so that the original pod spec is not modified
+	nodeSelector := make(map[string]string)
+	for k, v := range ext.PodSpec.NodeSelector {
+		nodeSelector[k] = v
+	}
+	tolerations := append([]apiv1.Toleration{}, ext.PodSpec.Tolerations...)
+
+	if len(ext.OS) > 0 {
+		if _, exists := nodeSelector[nodeOSLabel]; !exists {
+			nodeSelector[nodeOSLabel] = ext.OS
+		}
+		if ext.PodSpec.OS == nil {
+			ext.PodSpec.OS = &apiv1.PodOS{Name: apiv1.OSName(ext.OS)}
+		}
+	}
+	if len(ext.Arch) > 0 {
+		if _, exists := nodeSelector[nodeArchLabel]; !exists {
+			nodeSelector[nodeArchLabel] = ext.Arch
+		}
+	}
+
+	if apiv1.OSName(ext.OS) == apiv1.Windows && !hasToleration(tolerations, windowsToleration) {
+		tolerations = append(tolerations, windowsToleration)
+	}
+
+	ext.PodSpec.NodeSelector = nodeSelector
+	ext.PodSpec.Tolerations = tolerations
+}
+
+// hasToleration determines if a list of tolerations already contains a toleration
+func hasToleration(tolerations []apiv1.Toleration, toleration apiv1.Toleration) bool {
+	for _, t := range tolerations {
+		if t.MatchToleration(&toleration) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package external
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// TestConfigurePlatform ensures that node selectors, tolerations and the pod OS are set for the configured platform
+// without modifying the original pod spec
+func TestConfigurePlatform(t *testing.T) {
+
+	var testCases = []struct {
+		description      string
+		os               string
+		arch             string
+		nodeSelector     map[string]string
+		tolerations      []apiv1.Toleration
+		expectSelector   map[string]string
+		expectToleration bool
+	}{
+		{"no platform", "", "", nil, nil, nil, false},
+		{"linux arm64", "linux", "arm64", nil, nil, map[string]string{nodeOSLabel: "linux", nodeArchLabel: "arm64"}, false},
+		{"windows", "windows", "", map[string]string{"pool": "win"}, nil, map[string]string{nodeOSLabel: "windows", "pool": "win"}, true},
+		{"windows already tolerated", "windows", "amd64", nil, []apiv1.Toleration{windowsToleration}, map[string]string{nodeOSLabel: "windows", nodeArchLabel: "amd64"}, true},
+		{"user selector kept", "linux", "", map[string]string{nodeOSLabel: "windows"}, nil, map[string]string{nodeOSLabel: "windows"}, false},
+	}
+
+	for _, tc := range testCases {
+		original := apiv1.PodSpec{NodeSelector: tc.nodeSelector, Tolerations: tc.tolerations}
+		ext := &Checker{OS: tc.os, Arch: tc.arch, OriginalPodSpec: original, PodSpec: original}
+		ext.configurePlatform()
+
+		if len(ext.PodSpec.NodeSelector) != len(tc.expectSelector) {
+			t.Fatalf("%s: node selector was %v but expected %v", tc.description, ext.PodSpec.NodeSelector, tc.expectSelector)
+		}
+		for k, v := range tc.expectSelector {
+			if ext.PodSpec.NodeSelector[k] != v {
+				t.Fatalf("%s: node selector was %v but expected %v", tc.description, ext.PodSpec.NodeSelector, tc.expectSelector)
+			}
+		}
+
+		var windowsTolerations int
+		for _, toleration := range ext.PodSpec.Tolerations {
+			if toleration.MatchToleration(&windowsToleration) {
+				windowsTolerations++
+			}
+		}
+		if tc.expectToleration && windowsTolerations != 1 {
+			t.Fatalf("%s: found %d windows tolerations but expected 1", tc.description, windowsTolerations)
+		}
+		if !tc.expectToleration && windowsTolerations != 0 {
+			t.Fatalf("%s: found %d windows tolerations but expected none", tc.description, windowsTolerations)
+		}
+
+		if len(tc.os) > 0 && (ext.PodSpec.OS == nil || string(ext.PodSpec.OS.Name) != tc.os) {
+			t.Fatalf("%s: pod OS was %v but expected %s", tc.description, ext.PodSpec.OS, tc.os)
+		}
+
+		if len(ext.OriginalPodSpec.NodeSelector) != len(tc.nodeSelector) || len(ext.OriginalPodSpec.Tolerations) != len(tc.tolerations) {
+			t.Fatalf("%s: original pod spec was modified", tc.description)
+		}
+		t.Logf("%s: platform configured correctly", tc.description)
+	}
+}
@@ -0,0 +1,236 @@
+package external
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Security context policies control the security context defaults applied to checker pods.  The restricted policy
+// fills in any security context settings the check left unset so that checker pods meet the restricted Pod Security
+// Standard.  The none policy leaves the check's pod spec alone.  A blank policy is treated as none rather than
+// restricted: existing checks that run as root or add capabilities such as NET_RAW would otherwise start failing on
+// upgrade without any change to their khcheck, so checks and clusters opt in to the restricted defaults instead.
+const (
+	SecurityContextPolicyRestricted = "restricted"
+	SecurityContextPolicyNone       = "none"
+)
+
+// podSecurityEnforceLabel is the namespace label that sets the Pod Security Standard enforced by pod security admission
+const podSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+
+// Pod Security Standard levels
+const (
+	podSecurityPrivileged = "privileged"
+	podSecurityBaseline   = "baseline"
+	podSecurityRestricted = "restricted"
+)
+
+// defaultNonRootUser is the user checker pods run as when the restricted policy applies and the check does not
+// choose a user.  Images that set a user by name can not be verified as non-root by the kubelet, so a user ID is
+// always set.
+const defaultNonRootUser int64 = 65534
+
+// baselineCapabilities are the capabilities that may be added under the baseline Pod Security Standard
+var baselineCapabilities = map[apiv1.Capability]bool{
+	"AUDIT_WRITE": true, "CHOWN": true, "DAC_OVERRIDE": true, "FOWNER": true, "FSETID": true, "KILL": true,
+	"MKNOD": true, "NET_BIND_SERVICE": true, "SETFCAP": true, "SETGID": true, "SETPCAP": true, "SETUID": true,
+	"SYS_CHROOT": true,
+}
+
+// applySecurityContextDefaults fills in security context settings left unset by the check so that checker pods meet
+// the restricted Pod Security Standard when the check opts in to the restricted policy.  Settings chosen by the check
+// are never changed.  Windows pods are left alone because these settings only apply to Linux.
+func (ext *Checker) applySecurityContextDefaults() {
+	if ext.SecurityContextPolicy != SecurityContextPolicyRestricted || apiv1.OSName(ext.OS) == apiv1.Windows {
+		return
+	}
+
+	if ext.PodSpec.SecurityContext == nil {
+		ext.PodSpec.SecurityContext = &apiv1.PodSecurityContext{}
+	}
+	psc := ext.PodSpec.SecurityContext
+	if psc.RunAsNonRoot == nil {
+		runAsNonRoot := true
+		psc.RunAsNonRoot = &runAsNonRoot
+	}
+	if psc.RunAsUser == nil && !containersSetRunAsUser(ext.PodSpec) {
+		runAsUser := defaultNonRootUser
+		psc.RunAsUser = &runAsUser
+	}
+	if psc.SeccompProfile == nil {
+		psc.SeccompProfile = &apiv1.SeccompProfile{Type: apiv1.SeccompProfileTypeRuntimeDefault}
+	}
+
+	for _, containers := range [][]apiv1.Container{ext.PodSpec.InitContainers, ext.PodSpec.Containers} {
+		for i := range containers {
+			if containers[i].SecurityContext == nil {
+				containers[i].SecurityContext = &apiv1.SecurityContext{}
+			}
+			sc := containers[i].SecurityContext
+			if sc.AllowPrivilegeEscalation == nil && (sc.Privileged == nil || !*sc.Privileged) {
+				allowPrivilegeEscalation := false
+				sc.AllowPrivilegeEscalation = &allowPrivilegeEscalation
+			}
+			if sc.Capabilities == nil {
+				sc.Capabilities = &apiv1.Capabilities{Drop: []apiv1.Capability{"ALL"}}
+			}
+		}
+	}
+}
+
+// containersSetRunAsUser determines if any container in the pod spec chooses its own user
+func containersSetRunAsUser(spec apiv1.PodSpec) bool {
+	for _, containers := range [][]apiv1.Container{spec.InitContainers, spec.Containers} {
+		for _, c := range containers {
+			if c.SecurityContext != nil && c.SecurityContext.RunAsUser != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validatePodSecurity checks the checker pod spec against the Pod Security Standard enforced in the checker pod's
+// namespace.  This lets checks that would be rejected by pod security admission fail early with a clear message
+// instead of timing out waiting on a pod that was never created.
+func (ext *Checker) validatePodSecurity(ctx context.Context) error {
+	return validateNamespacePodSecurity(ctx, ext.KubeClient, ext.Namespace, ext.PodSpec)
+}
+
+// validateNamespacePodSecurity checks a pod spec against the Pod Security Standard enforced in the supplied namespace.
+// Validation is skipped when Kuberhealthy is not allowed to get the namespace or it does not exist, and pod security
+// admission is left to refuse the pod.  Other errors fetching the namespace are returned.
+func validateNamespacePodSecurity(ctx context.Context, client kubernetes.Interface, namespace string, spec apiv1.PodSpec) error {
+	ns, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if k8sErrors.IsForbidden(err) || k8sErrors.IsNotFound(err) {
+		log.Warningln("Failed to fetch namespace", namespace, "to validate pod security. Skipping validation:", err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch namespace %s to validate pod security: %w", namespace, err)
+	}
+
+	level := ns.Labels[podSecurityEnforceLabel]
+	violations := podSecurityViolations(level, spec)
+	if len(violations) > 0 {
+		return fmt.Errorf("checker pod violates the %s pod security standard enforced in namespace %s: %s", level, namespace, strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// podSecurityViolations returns the ways a pod spec violates a Pod Security Standard level.  This covers the
+// controls of the baseline and restricted levels that apply to pod specs.
+func podSecurityViolations(level string, spec apiv1.PodSpec) []string {
+	if level != podSecurityBaseline && level != podSecurityRestricted {
+		return nil
+	}
+	restricted := level == podSecurityRestricted
+	isWindows := spec.OS != nil && spec.OS.Name == apiv1.Windows
+
+	var violations []string
+	if spec.HostNetwork || spec.HostPID || spec.HostIPC {
+		violations = append(violations, "host namespaces must not be used")
+	}
+	for _, v := range spec.Volumes {
+		if v.HostPath != nil {
+			violations = append(violations, "volume "+v.Name+" must not be a hostPath volume")
+		}
+		if restricted && !restrictedVolumeSource(v.VolumeSource) {
+			violations = append(violations, "volume "+v.Name+" must be a configMap, csi, downwardAPI, emptyDir, ephemeral, persistentVolumeClaim, projected or secret volume")
+		}
+	}
+
+	psc := spec.SecurityContext
+	if psc == nil {
+		psc = &apiv1.PodSecurityContext{}
+	}
+	if psc.SeccompProfile != nil && psc.SeccompProfile.Type == apiv1.SeccompProfileTypeUnconfined {
+		violations = append(violations, "pod seccomp profile must not be Unconfined")
+	}
+	if restricted && !isWindows && psc.RunAsUser != nil && *psc.RunAsUser == 0 {
+		violations = append(violations, "pod must not run as user 0")
+	}
+
+	for _, containers := range [][]apiv1.Container{spec.InitContainers, spec.Containers} {
+		for _, c := range containers {
+			sc := c.SecurityContext
+			if sc == nil {
+				sc = &apiv1.SecurityContext{}
+			}
+			for _, p := range c.Ports {
+				if p.HostPort != 0 {
+					violations = append(violations, "container "+c.Name+" must not use host ports")
+				}
+			}
+			if sc.Privileged != nil && *sc.Privileged {
+				violations = append(violations, "container "+c.Name+" must not be privileged")
+			}
+			if sc.SeccompProfile != nil && sc.SeccompProfile.Type == apiv1.SeccompProfileTypeUnconfined {
+				violations = append(violations, "container "+c.Name+" seccomp profile must not be Unconfined")
+			}
+			if sc.Capabilities != nil {
+				for _, capability := range sc.Capabilities.Add {
+					if restricted && capability != "NET_BIND_SERVICE" {
+						violations = append(violations, "container "+c.Name+" must not add capability "+string(capability))
+					} else if !baselineCapabilities[capability] {
+						violations = append(violations, "container "+c.Name+" must not add capability "+string(capability))
+					}
+				}
+			}
+
+			if !restricted || isWindows {
+				continue
+			}
+			if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+				violations = append(violations, "container "+c.Name+" must set allowPrivilegeEscalation to false")
+			}
+			runAsNonRoot := psc.RunAsNonRoot
+			if sc.RunAsNonRoot != nil {
+				runAsNonRoot = sc.RunAsNonRoot
+			}
+			if runAsNonRoot == nil || !*runAsNonRoot {
+				violations = append(violations, "container "+c.Name+" must set runAsNonRoot to true")
+			}
+			if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+				violations = append(violations, "container "+c.Name+" must not run as user 0")
+			}
+			if !dropsAllCapabilities(sc.Capabilities) {
+				violations = append(violations, "container "+c.Name+" must drop ALL capabilities")
+			}
+			seccomp := psc.SeccompProfile
+			if sc.SeccompProfile != nil {
+				seccomp = sc.SeccompProfile
+			}
+			if seccomp == nil || (seccomp.Type != apiv1.SeccompProfileTypeRuntimeDefault && seccomp.Type != apiv1.SeccompProfileTypeLocalhost) {
+				violations = append(violations, "container "+c.Name+" must use the RuntimeDefault or Localhost seccomp profile")
+			}
+		}
+	}
+	return violations
+}
+
+// restrictedVolumeSource determines if a volume type is allowed by the restricted Pod Security Standard
+func restrictedVolumeSource(vs apiv1.VolumeSource) bool {
+	return vs.ConfigMap != nil || vs.CSI != nil || vs.DownwardAPI != nil || vs.EmptyDir != nil || vs.Ephemeral != nil ||
+		vs.PersistentVolumeClaim != nil || vs.Projected != nil || vs.Secret != nil
+}
+
+// dropsAllCapabilities determines if a container's capabilities drop ALL
+func dropsAllCapabilities(capabilities *apiv1.Capabilities) bool {
+	if capabilities == nil {
+		return false
+	}
+	for _, capability := range capabilities.Drop {
+		if capability == "ALL" {
+			return true
+		}
+	}
+	return false
+}
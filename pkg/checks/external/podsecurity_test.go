@@ -0,0 +1,124 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// TestApplySecurityContextDefaults ensures that the restricted defaults make a plain checker pod meet the restricted
+// pod security standard while leaving settings chosen by the check alone
+func TestApplySecurityContextDefaults(t *testing.T) {
+
+	runAsUser := int64(1000)
+	plain := apiv1.PodSpec{Containers: []apiv1.Container{{Name: "check"}}}
+	userChosen := apiv1.PodSpec{
+		SecurityContext: &apiv1.PodSecurityContext{RunAsUser: &runAsUser},
+		Containers: []apiv1.Container{{
+			Name:            "check",
+			SecurityContext: &apiv1.SecurityContext{Capabilities: &apiv1.Capabilities{Drop: []apiv1.Capability{"ALL"}, Add: []apiv1.Capability{"NET_BIND_SERVICE"}}},
+		}},
+	}
+
+	var testCases = []struct {
+		description      string
+		policy           string
+		os               string
+		spec             apiv1.PodSpec
+		expectViolations bool
+	}{
+		{"none by default", "", "", plain, true},
+		{"restricted", SecurityContextPolicyRestricted, "", plain, false},
+		{"restricted keeps user settings", SecurityContextPolicyRestricted, "", userChosen, false},
+		{"none", SecurityContextPolicyNone, "", plain, true},
+		{"windows", SecurityContextPolicyRestricted, "windows", plain, true},
+	}
+
+	for _, tc := range testCases {
+		ext := &Checker{SecurityContextPolicy: tc.policy, OS: tc.os, OriginalPodSpec: tc.spec, PodSpec: *tc.spec.DeepCopy()}
+		ext.applySecurityContextDefaults()
+
+		violations := podSecurityViolations(podSecurityRestricted, ext.PodSpec)
+		if tc.expectViolations != (len(violations) > 0) {
+			t.Fatalf("%s: found violations %v but expected violations to be %t", tc.description, violations, tc.expectViolations)
+		}
+		if ext.PodSpec.SecurityContext != nil && ext.PodSpec.SecurityContext.RunAsUser != nil && tc.spec.SecurityContext != nil &&
+			*ext.PodSpec.SecurityContext.RunAsUser != *tc.spec.SecurityContext.RunAsUser {
+			t.Fatalf("%s: run as user chosen by the check was changed to %d", tc.description, *ext.PodSpec.SecurityContext.RunAsUser)
+		}
+		if tc.spec.Containers[0].SecurityContext == nil && ext.OriginalPodSpec.Containers[0].SecurityContext != nil {
+			t.Fatalf("%s: original pod spec was modified", tc.description)
+		}
+	}
+}
+
+// TestPodSecurityViolations ensures that pod specs are checked against the controls of each pod security level
+func TestPodSecurityViolations(t *testing.T) {
+
+	privileged := true
+	hostNetwork := apiv1.PodSpec{HostNetwork: true, Containers: []apiv1.Container{{Name: "check"}}}
+	privilegedContainer := apiv1.PodSpec{Containers: []apiv1.Container{{Name: "check", SecurityContext: &apiv1.SecurityContext{Privileged: &privileged}}}}
+	addedCapability := apiv1.PodSpec{Containers: []apiv1.Container{{Name: "check", SecurityContext: &apiv1.SecurityContext{Capabilities: &apiv1.Capabilities{Add: []apiv1.Capability{"NET_RAW"}}}}}}
+	hostPath := apiv1.PodSpec{Volumes: []apiv1.Volume{{Name: "host", VolumeSource: apiv1.VolumeSource{HostPath: &apiv1.HostPathVolumeSource{Path: "/"}}}}}
+	plain := apiv1.PodSpec{Containers: []apiv1.Container{{Name: "check"}}}
+
+	var testCases = []struct {
+		description      string
+		level            string
+		spec             apiv1.PodSpec
+		expectViolations bool
+	}{
+		{"unlabeled namespace", "", hostNetwork, false},
+		{"privileged namespace", podSecurityPrivileged, privilegedContainer, false},
+		{"baseline host network", podSecurityBaseline, hostNetwork, true},
+		{"baseline privileged container", podSecurityBaseline, privilegedContainer, true},
+		{"baseline added capability", podSecurityBaseline, addedCapability, true},
+		{"baseline host path", podSecurityBaseline, hostPath, true},
+		{"baseline plain pod", podSecurityBaseline, plain, false},
+		{"restricted plain pod", podSecurityRestricted, plain, true},
+	}
+
+	for _, tc := range testCases {
+		violations := podSecurityViolations(tc.level, tc.spec)
+		t.Logf("%s: %v", tc.description, violations)
+		if tc.expectViolations != (len(violations) > 0) {
+			t.Fatalf("%s: found violations %v but expected violations to be %t", tc.description, violations, tc.expectViolations)
+		}
+	}
+}
+
+// TestValidateNamespacePodSecurity ensures pods are validated against the level enforced in their namespace, that
+// validation is skipped when the namespace is missing or can't be read, and that other errors are returned
+func TestValidateNamespacePodSecurity(t *testing.T) {
+	privileged := true
+	spec := apiv1.PodSpec{Containers: []apiv1.Container{{Name: "check", SecurityContext: &apiv1.SecurityContext{Privileged: &privileged}}}}
+	client := fake.NewSimpleClientset(&apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "baseline", Labels: map[string]string{podSecurityEnforceLabel: podSecurityBaseline}}})
+
+	if err := validateNamespacePodSecurity(context.Background(), client, "baseline", spec); err == nil {
+		t.Fatal("expected a privileged pod to violate the baseline standard")
+	}
+	if err := validateNamespacePodSecurity(context.Background(), client, "missing", spec); err != nil {
+		t.Fatal("expected validation to be skipped when the namespace does not exist, got:", err)
+	}
+
+	var getErr error
+	client.PrependReactor("get", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, getErr
+	})
+	getErr = k8sErrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "baseline", errors.New("not allowed"))
+	if err := validateNamespacePodSecurity(context.Background(), client, "baseline", spec); err != nil {
+		t.Fatal("expected validation to be skipped when the namespace can't be read, got:", err)
+	}
+	getErr = k8sErrors.NewServiceUnavailable("etcd is unavailable")
+	if err := validateNamespacePodSecurity(context.Background(), client, "baseline", spec); err == nil {
+		t.Fatal("expected an error when the namespace could not be fetched for another reason")
+	}
+}
@@ -0,0 +1,101 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	apiv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Limits the API server places on the DNS config of a pod
+const (
+	maxDNSNameservers = 3
+	maxDNSSearches    = 32
+)
+
+// validateSchedulingFields validates the topology spread constraints, DNS config and host aliases of a checker pod
+// spec.  These are passed through to the checker pod untouched, so mistakes in them would otherwise only show up as
+// a pod the API server refuses to create.
+func validateSchedulingFields(spec apiv1.PodSpec) error {
+
+	for i, c := range spec.TopologySpreadConstraints {
+		if c.MaxSkew <= 0 {
+			return errors.New("topologySpreadConstraints[" + strconv.Itoa(i) + "] maxSkew must be greater than zero")
+		}
+		if len(c.TopologyKey) == 0 {
+			return errors.New("topologySpreadConstraints[" + strconv.Itoa(i) + "] topologyKey can not be empty")
+		}
+		if c.WhenUnsatisfiable != apiv1.DoNotSchedule && c.WhenUnsatisfiable != apiv1.ScheduleAnyway {
+			return errors.New("topologySpreadConstraints[" + strconv.Itoa(i) + "] whenUnsatisfiable must be DoNotSchedule or ScheduleAnyway")
+		}
+		if c.MinDomains != nil && (*c.MinDomains <= 0 || c.WhenUnsatisfiable != apiv1.DoNotSchedule) {
+			return errors.New("topologySpreadConstraints[" + strconv.Itoa(i) + "] minDomains must be greater than zero and can only be used with DoNotSchedule")
+		}
+	}
+
+	if spec.DNSPolicy == apiv1.DNSNone && (spec.DNSConfig == nil || len(spec.DNSConfig.Nameservers) == 0) {
+		return errors.New("dnsConfig must list at least one nameserver when dnsPolicy is None")
+	}
+	if spec.DNSConfig != nil {
+		if len(spec.DNSConfig.Nameservers) > maxDNSNameservers {
+			return fmt.Errorf("dnsConfig can not list more than %d nameservers", maxDNSNameservers)
+		}
+		for _, ns := range spec.DNSConfig.Nameservers {
+			if net.ParseIP(ns) == nil {
+				return errors.New("dnsConfig nameserver " + ns + " is not a valid IP address")
+			}
+		}
+		if len(spec.DNSConfig.Searches) > maxDNSSearches {
+			return fmt.Errorf("dnsConfig can not list more than %d search domains", maxDNSSearches)
+		}
+		for _, o := range spec.DNSConfig.Options {
+			if len(o.Name) == 0 {
+				return errors.New("dnsConfig options must have a name")
+			}
+		}
+	}
+
+	for _, ha := range spec.HostAliases {
+		if net.ParseIP(ha.IP) == nil {
+			return errors.New("hostAliases IP " + ha.IP + " is not a valid IP address")
+		}
+		if len(ha.Hostnames) == 0 {
+			return errors.New("hostAliases entry for " + ha.IP + " must list at least one hostname")
+		}
+	}
+
+	return nil
+}
+
+// validatePodSpecReferences ensures that the priority class and runtime class named in the checker pod spec exist.
+// Pods referencing a missing class are rejected when they are created, so this fails the check early with a clear
+// message instead.
+func (ext *Checker) validatePodSpecReferences(ctx context.Context) error {
+
+	if len(ext.PodSpec.PriorityClassName) > 0 {
+		_, err := ext.KubeClient.SchedulingV1().PriorityClasses().Get(ctx, ext.PodSpec.PriorityClassName, metav1.GetOptions{})
+		if k8sErrors.IsNotFound(err) {
+			return errors.New("priority class " + ext.PodSpec.PriorityClassName + " does not exist")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch priority class %s: %w", ext.PodSpec.PriorityClassName, err)
+		}
+	}
+
+	if ext.PodSpec.RuntimeClassName != nil && len(*ext.PodSpec.RuntimeClassName) > 0 {
+		_, err := ext.KubeClient.NodeV1().RuntimeClasses().Get(ctx, *ext.PodSpec.RuntimeClassName, metav1.GetOptions{})
+		if k8sErrors.IsNotFound(err) {
+			return errors.New("runtime class " + *ext.PodSpec.RuntimeClassName + " does not exist")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch runtime class %s: %w", *ext.PodSpec.RuntimeClassName, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package external
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// TestValidateSchedulingFields ensures that invalid topology spread constraints, DNS config and host aliases are
+// rejected before a checker pod is created
+func TestValidateSchedulingFields(t *testing.T) {
+
+	zeroDomains := int32(0)
+	var testCases = []struct {
+		description string
+		spec        apiv1.PodSpec
+		expectError bool
+	}{
+		{"empty spec", apiv1.PodSpec{}, false},
+		{"valid spread", apiv1.PodSpec{TopologySpreadConstraints: []apiv1.TopologySpreadConstraint{{MaxSkew: 1, TopologyKey: "topology.kubernetes.io/zone", WhenUnsatisfiable: apiv1.ScheduleAnyway}}}, false},
+		{"zero skew", apiv1.PodSpec{TopologySpreadConstraints: []apiv1.TopologySpreadConstraint{{TopologyKey: "topology.kubernetes.io/zone", WhenUnsatisfiable: apiv1.ScheduleAnyway}}}, true},
+		{"missing topology key", apiv1.PodSpec{TopologySpreadConstraints: []apiv1.TopologySpreadConstraint{{MaxSkew: 1, WhenUnsatisfiable: apiv1.DoNotSchedule}}}, true},
+		{"missing when unsatisfiable", apiv1.PodSpec{TopologySpreadConstraints: []apiv1.TopologySpreadConstraint{{MaxSkew: 1, TopologyKey: "kubernetes.io/hostname"}}}, true},
+		{"zero min domains", apiv1.PodSpec{TopologySpreadConstraints: []apiv1.TopologySpreadConstraint{{MaxSkew: 1, TopologyKey: "kubernetes.io/hostname", WhenUnsatisfiable: apiv1.DoNotSchedule, MinDomains: &zeroDomains}}}, true},
+		{"dns policy none without nameservers", apiv1.PodSpec{DNSPolicy: apiv1.DNSNone}, true},
+		{"dns policy none with nameserver", apiv1.PodSpec{DNSPolicy: apiv1.DNSNone, DNSConfig: &apiv1.PodDNSConfig{Nameservers: []string{"fd00::10"}}}, false},
+		{"too many nameservers", apiv1.PodSpec{DNSConfig: &apiv1.PodDNSConfig{Nameservers: []string{"1.1.1.1", "8.8.8.8", "9.9.9.9", "8.8.4.4"}}}, true},
+		{"invalid nameserver", apiv1.PodSpec{DNSConfig: &apiv1.PodDNSConfig{Nameservers: []string{"dns.example.com"}}}, true},
+		{"valid host alias", apiv1.PodSpec{HostAliases: []apiv1.HostAlias{{IP: "10.0.0.1", Hostnames: []string{"example.internal"}}}}, false},
+		{"invalid host alias IP", apiv1.PodSpec{HostAliases: []apiv1.HostAlias{{IP: "example", Hostnames: []string{"example.internal"}}}}, true},
+		{"host alias without hostnames", apiv1.PodSpec{HostAliases: []apiv1.HostAlias{{IP: "10.0.0.1"}}}, true},
+	}
+
+	for _, tc := range testCases {
+		err := validateSchedulingFields(tc.spec)
+		if tc.expectError != (err != nil) {
+			t.Fatalf("%s: validation error was %v but expected an error to be %t", tc.description, err, tc.expectError)
+		}
+	}
+}
+
+// TestConfigureUserPodSpecPassthrough ensures that scheduling and networking fields of the check's pod spec make it
+// into the checker pod spec unchanged
+func TestConfigureUserPodSpecPassthrough(t *testing.T) {
+
+	runtimeClass := "gvisor"
+	original := apiv1.PodSpec{
+		Containers:                []apiv1.Container{{Name: "check", Image: "kuberhealthy/test-check"}},
+		RuntimeClassName:          &runtimeClass,
+		PriorityClassName:         "system-cluster-critical",
+		TopologySpreadConstraints: []apiv1.TopologySpreadConstraint{{MaxSkew: 1, TopologyKey: "topology.kubernetes.io/zone", WhenUnsatisfiable: apiv1.ScheduleAnyway}},
+		DNSPolicy:                 apiv1.DNSNone,
+		DNSConfig:                 &apiv1.PodDNSConfig{Nameservers: []string{"10.0.0.10"}, Searches: []string{"svc.cluster.local"}},
+		HostAliases:               []apiv1.HostAlias{{IP: "10.0.0.1", Hostnames: []string{"example.internal"}}},
+	}
+
+	ext := &Checker{Namespace: "kuberhealthy", OriginalPodSpec: original, PodSpec: original}
+	err := ext.configureUserPodSpec(time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("failed to configure pod spec: %s", err)
+	}
+
+	if ext.PodSpec.RuntimeClassName == nil || *ext.PodSpec.RuntimeClassName != runtimeClass {
+		t.Fatalf("runtime class name was %v but expected %s", ext.PodSpec.RuntimeClassName, runtimeClass)
+	}
+	if ext.PodSpec.PriorityClassName != original.PriorityClassName {
+		t.Fatalf("priority class name was %s but expected %s", ext.PodSpec.PriorityClassName, original.PriorityClassName)
+	}
+	if !reflect.DeepEqual(ext.PodSpec.TopologySpreadConstraints, original.TopologySpreadConstraints) {
+		t.Fatalf("topology spread constraints were %+v but expected %+v", ext.PodSpec.TopologySpreadConstraints, original.TopologySpreadConstraints)
+	}
+	if ext.PodSpec.DNSPolicy != original.DNSPolicy || !reflect.DeepEqual(ext.PodSpec.DNSConfig, original.DNSConfig) {
+		t.Fatalf("dns policy %s and config %+v did not match the check's pod spec", ext.PodSpec.DNSPolicy, ext.PodSpec.DNSConfig)
+	}
+	if !reflect.DeepEqual(ext.PodSpec.HostAliases, original.HostAliases) {
+		t.Fatalf("host aliases were %+v but expected %+v", ext.PodSpec.HostAliases, original.HostAliases)
+	}
+}
@@ -0,0 +1,106 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+)
+
+// TimeoutError is returned by runs that did not report or finish before their deadline
+type TimeoutError struct {
+	Err error
+}
+
+// Error returns the message of the underlying error
+func (e *TimeoutError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// FailureReason returns why a run failed with the supplied error.  Errors that fit none of the reasons have no
+// reason.
+func FailureReason(err error) khstatev1.FailureReason {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, ErrRunCanceled) {
+		return khstatev1.ReasonCancelled
+	}
+	var pe *ProvisioningError
+	if errors.As(err, &pe) {
+		if pe.Unschedulable {
+			return khstatev1.ReasonPodSchedulingFailed
+		}
+		if pe.StartTimedOut {
+			return khstatev1.ReasonStartTimedOut
+		}
+		if pe.AdmissionRejected {
+			return khstatev1.ReasonAdmissionRejected
+		}
+		return khstatev1.ReasonProvisioningError
+	}
+	var te *TimeoutError
+	if errors.As(err, &te) {
+		return khstatev1.ReasonTimedOut
+	}
+	return ""
+}
+
+// podUnschedulable tells if the scheduler has found no node for a pod
+func podUnschedulable(p *apiv1.Pod) bool {
+	for _, c := range p.Status.Conditions {
+		if c.Type == apiv1.PodScheduled && c.Status == apiv1.ConditionFalse && c.Reason == apiv1.PodReasonUnschedulable {
+			return true
+		}
+	}
+	return false
+}
+
+// startTimeoutReached fires once the checker pod has had its start timeout to start.  It never fires when starting
+// is only bounded by the run timeout.
+func (ext *Checker) startTimeoutReached() <-chan time.Time {
+	if ext.StartTimeout <= 0 {
+		return nil
+	}
+	return ext.clock().After(ext.StartTimeout)
+}
+
+// startTimeoutError builds the error of a run whose checker pod did not start in time.  Pods that are waiting on a
+// node they can be scheduled onto are told apart from pods that were scheduled but are slow to start, such as when
+// their image takes long to pull.
+func (ext *Checker) startTimeoutError(ctx context.Context, message string) error {
+	unschedulable, err := runPodUnschedulable(ctx, ext.KubeClient, ext.Namespace, ext.currentCheckUUID)
+	if err != nil {
+		ext.log("failed to look up checker pod scheduling:", err)
+	}
+	if unschedulable {
+		return &ProvisioningError{Err: ext.newError(message + ": pod could not be scheduled"), Unschedulable: true}
+	}
+	return &ProvisioningError{Err: ext.newError(message), StartTimedOut: true}
+}
+
+// runPodUnschedulable tells if the checker pod of a run is waiting on a node it can be scheduled onto
+func runPodUnschedulable(ctx context.Context, client kubernetes.Interface, namespace string, uuid string) (bool, error) {
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: kuberhealthyRunIDLabel + "=" + uuid,
+	})
+	if err != nil {
+		return false, err
+	}
+	for i := range pods.Items {
+		if podUnschedulable(&pods.Items[i]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
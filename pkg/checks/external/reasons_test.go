@@ -0,0 +1,102 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+)
+
+// TestFailureReason ensures the errors of runs are classified into failure reasons
+func TestFailureReason(t *testing.T) {
+	timeout := &TimeoutError{Err: errors.New("timed out waiting for checker pod to report in")}
+
+	var testCases = []struct {
+		description string
+		err         error
+		expected    khstatev1.FailureReason
+	}{
+		{"no error", nil, ""},
+		{"canceled", ErrRunCanceled, khstatev1.ReasonCancelled},
+		{"timed out", timeout, khstatev1.ReasonTimedOut},
+		{"fanned out run timed out", &FanOutError{Err: timeout}, khstatev1.ReasonTimedOut},
+		{"provisioning error", &ProvisioningError{Err: errors.New("image pull failed")}, khstatev1.ReasonProvisioningError},
+		{"unschedulable", &ProvisioningError{Err: errors.New("not running"), Unschedulable: true}, khstatev1.ReasonPodSchedulingFailed},
+		{"slow to start", &ProvisioningError{Err: errors.New("not running"), StartTimedOut: true}, khstatev1.ReasonStartTimedOut},
+		{"rejected by admission", &ProvisioningError{Err: errors.New("exceeded quota"), AdmissionRejected: true}, khstatev1.ReasonAdmissionRejected},
+		{"wrapped provisioning error", fmt.Errorf("run failed: %w", &ProvisioningError{Err: errors.New("image pull failed")}), khstatev1.ReasonProvisioningError},
+		{"other error", ErrPodRemovedUnexpectedly, ""},
+	}
+	for _, tc := range testCases {
+		reason := FailureReason(tc.err)
+		if reason != tc.expected {
+			t.Fatalf("%s: expected reason %q but got %q", tc.description, tc.expected, reason)
+		}
+	}
+}
+
+// TestPodUnschedulable ensures pods are only unschedulable when the scheduler found no node for them
+func TestPodUnschedulable(t *testing.T) {
+	unschedulable := &apiv1.Pod{Status: apiv1.PodStatus{Conditions: []apiv1.PodCondition{
+		{Type: apiv1.PodScheduled, Status: apiv1.ConditionFalse, Reason: apiv1.PodReasonUnschedulable},
+	}}}
+	scheduled := &apiv1.Pod{Status: apiv1.PodStatus{Conditions: []apiv1.PodCondition{
+		{Type: apiv1.PodScheduled, Status: apiv1.ConditionTrue},
+	}}}
+
+	if !podUnschedulable(unschedulable) {
+		t.Fatal("expected a pod the scheduler found no node for to be unschedulable")
+	}
+	if podUnschedulable(scheduled) || podUnschedulable(&apiv1.Pod{}) {
+		t.Fatal("expected scheduled and new pods not to be unschedulable")
+	}
+}
+
+// TestRunPodUnschedulable ensures checker pods that are slow to start are told apart from pods that can't be scheduled
+func TestRunPodUnschedulable(t *testing.T) {
+	pending := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      "slow",
+		Namespace: "kuberhealthy",
+		Labels:    map[string]string{kuberhealthyRunIDLabel: "slow-run"},
+	}}
+	unschedulable := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "unschedulable",
+			Namespace: "kuberhealthy",
+			Labels:    map[string]string{kuberhealthyRunIDLabel: "unschedulable-run"},
+		},
+		Status: apiv1.PodStatus{Conditions: []apiv1.PodCondition{
+			{Type: apiv1.PodScheduled, Status: apiv1.ConditionFalse, Reason: apiv1.PodReasonUnschedulable},
+		}},
+	}
+	client := fake.NewSimpleClientset(pending, unschedulable)
+	ctx := context.Background()
+
+	if found, err := runPodUnschedulable(ctx, client, "kuberhealthy", "slow-run"); err != nil || found {
+		t.Fatalf("expected a scheduled pod that did not start to be schedulable, got %t %v", found, err)
+	}
+	if found, err := runPodUnschedulable(ctx, client, "kuberhealthy", "unschedulable-run"); err != nil || !found {
+		t.Fatalf("expected the pod the scheduler found no node for to be unschedulable, got %t %v", found, err)
+	}
+}
+
+// TestStartTimeoutReached ensures the start timeout only fires when starting is bounded separately from the run
+func TestStartTimeoutReached(t *testing.T) {
+	ext := &Checker{}
+	if ext.startTimeoutReached() != nil {
+		t.Fatal("expected no start timeout when starting is only bounded by the run timeout")
+	}
+	ext.StartTimeout = time.Millisecond
+	select {
+	case <-ext.startTimeoutReached():
+	case <-time.After(time.Second):
+		t.Fatal("expected the start timeout to be reached")
+	}
+}
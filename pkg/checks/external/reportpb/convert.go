@@ -0,0 +1,62 @@
+package reportpb
+
+import (
+	"google.golang.org/protobuf/proto"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// FromStatus converts a report as built by the checkclient into its protobuf message
+func FromStatus(s status.Report) *Report {
+	r := &Report{
+		Errors:        s.Errors,
+		Ok:            s.OK,
+		Metadata:      s.Metadata,
+		Metrics:       s.Metrics,
+		Warnings:      s.Warnings,
+		ReportVersion: int32(s.ReportVersion),
+	}
+	for _, e := range s.ErrorDetails {
+		r.ErrorDetails = append(r.ErrorDetails, &CheckError{Message: e.Message, Severity: e.Severity, Code: e.Code, Metadata: e.Metadata})
+	}
+	for _, a := range s.Artifacts {
+		r.Artifacts = append(r.Artifacts, &Artifact{Name: a.Name, ContentType: a.ContentType, Content: a.Content, Truncated: a.Truncated})
+	}
+	for _, sc := range s.SubChecks {
+		r.SubChecks = append(r.SubChecks, &SubCheck{Name: sc.Name, Ok: sc.OK, Errors: sc.Errors})
+	}
+	return r
+}
+
+// Status converts the protobuf message of a report into the report Kuberhealthy records.  Reports without errors get
+// an empty list of errors, the same as reports decoded from JSON.
+func (r *Report) Status() status.Report {
+	s := status.Report{
+		Errors:        r.GetErrors(),
+		OK:            r.GetOk(),
+		Metadata:      r.GetMetadata(),
+		Metrics:       r.GetMetrics(),
+		Warnings:      r.GetWarnings(),
+		ReportVersion: int(r.GetReportVersion()),
+	}
+	if s.Errors == nil {
+		s.Errors = []string{}
+	}
+	for _, e := range r.GetErrorDetails() {
+		s.ErrorDetails = append(s.ErrorDetails, status.CheckError{Message: e.GetMessage(), Severity: e.GetSeverity(), Code: e.GetCode(), Metadata: e.GetMetadata()})
+	}
+	for _, a := range r.GetArtifacts() {
+		s.Artifacts = append(s.Artifacts, status.Artifact{Name: a.GetName(), ContentType: a.GetContentType(), Content: a.GetContent(), Truncated: a.GetTruncated()})
+	}
+	for _, sc := range r.GetSubChecks() {
+		s.SubChecks = append(s.SubChecks, status.SubCheck{Name: sc.GetName(), OK: sc.GetOk(), Errors: sc.GetErrors()})
+	}
+	return s
+}
+
+// SigningBytes returns the bytes of a report that its signature is made over.  Reports sent over gRPC are signed over
+// their deterministic protobuf encoding rather than a JSON body, which Kuberhealthy encodes again on arrival to verify
+// the signature.
+func SigningBytes(r *Report) ([]byte, error) {
+	return proto.MarshalOptions{Deterministic: true}.Marshal(r)
+}
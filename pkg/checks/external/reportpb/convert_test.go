@@ -0,0 +1,65 @@
+package reportpb
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestStatusRoundTrip ensures a report converted to its protobuf message and back is unchanged, and that reports
+// without errors come back with an empty list of errors like reports decoded from JSON
+func TestStatusRoundTrip(t *testing.T) {
+	reports := []status.Report{
+		{Errors: []string{}, OK: true, Metrics: map[string]float64{"latency_seconds": 0.25}, Warnings: []string{"certificate expires in 20 days"}, ReportVersion: status.ReportVersion2},
+		{
+			Errors:       []string{"dns lookup timed out"},
+			Metadata:     map[string]string{"resolver": "10.0.0.10"},
+			ErrorDetails: []status.CheckError{{Message: "dns lookup timed out", Severity: status.SeverityCritical, Code: "DNS_TIMEOUT", Metadata: map[string]string{"host": "example.com"}}},
+			Artifacts:    []status.Artifact{{Name: "checker.log", ContentType: "text/plain", Content: "lookup example.com: i/o timeout", Truncated: true}},
+			SubChecks:    []status.SubCheck{{Name: "kube-dns", OK: true}, {Name: "example.com", Errors: []string{"lookup timed out"}}},
+		},
+	}
+	for _, s := range reports {
+		got := FromStatus(s).Status()
+		if !reflect.DeepEqual(got, s) {
+			t.Fatalf("expected report %+v after the round trip, got %+v", s, got)
+		}
+	}
+	if got := (&Report{Ok: true}).Status(); got.Errors == nil {
+		t.Fatal("expected a report without errors to get an empty list of errors")
+	}
+}
+
+// TestSigningBytes ensures the signing bytes of a report survive being sent and decoded again, so that a signature
+// made by the checker pod verifies against the report Kuberhealthy decoded
+func TestSigningBytes(t *testing.T) {
+	sent := FromStatus(status.Report{
+		Errors:   []string{"a", "b"},
+		Metadata: map[string]string{"z": "1", "a": "2", "m": "3"},
+		Metrics:  map[string]float64{"y": 1, "b": 2},
+	})
+	signed, err := SigningBytes(sent)
+	if err != nil {
+		t.Fatalf("failed to encode report: %s", err)
+	}
+
+	wire, err := proto.Marshal(sent)
+	if err != nil {
+		t.Fatalf("failed to marshal report: %s", err)
+	}
+	received := &Report{}
+	err = proto.Unmarshal(wire, received)
+	if err != nil {
+		t.Fatalf("failed to unmarshal report: %s", err)
+	}
+	verified, err := SigningBytes(received)
+	if err != nil {
+		t.Fatalf("failed to encode received report: %s", err)
+	}
+	if string(signed) != string(verified) {
+		t.Fatal("expected the received report to encode to the signed bytes")
+	}
+}
@@ -0,0 +1,5 @@
+// Package reportpb holds the protobuf definitions of check reports and the gRPC service checker pods can send them to
+// instead of posting them as JSON to the reporting URL.  The Go code is generated from report.proto.
+package reportpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative report.proto
@@ -0,0 +1,185 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: report.proto
+
+package reportpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Reporting_SendReport_FullMethodName     = "/kuberhealthy.report.v1.Reporting/SendReport"
+	Reporting_StreamProgress_FullMethodName = "/kuberhealthy.report.v1.Reporting/StreamProgress"
+)
+
+// ReportingClient is the client API for Reporting service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ReportingClient interface {
+	// SendReport records the result of a run
+	SendReport(ctx context.Context, in *Report, opts ...grpc.CallOption) (*ReportResponse, error)
+	// StreamProgress records how far along a run is until the checker pod closes the stream
+	StreamProgress(ctx context.Context, opts ...grpc.CallOption) (Reporting_StreamProgressClient, error)
+}
+
+type reportingClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewReportingClient(cc grpc.ClientConnInterface) ReportingClient {
+	return &reportingClient{cc}
+}
+
+func (c *reportingClient) SendReport(ctx context.Context, in *Report, opts ...grpc.CallOption) (*ReportResponse, error) {
+	out := new(ReportResponse)
+	err := c.cc.Invoke(ctx, Reporting_SendReport_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *reportingClient) StreamProgress(ctx context.Context, opts ...grpc.CallOption) (Reporting_StreamProgressClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Reporting_ServiceDesc.Streams[0], Reporting_StreamProgress_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &reportingStreamProgressClient{stream}
+	return x, nil
+}
+
+type Reporting_StreamProgressClient interface {
+	Send(*Progress) error
+	CloseAndRecv() (*ProgressSummary, error)
+	grpc.ClientStream
+}
+
+type reportingStreamProgressClient struct {
+	grpc.ClientStream
+}
+
+func (x *reportingStreamProgressClient) Send(m *Progress) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *reportingStreamProgressClient) CloseAndRecv() (*ProgressSummary, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ProgressSummary)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ReportingServer is the server API for Reporting service.
+// All implementations must embed UnimplementedReportingServer
+// for forward compatibility
+type ReportingServer interface {
+	// SendReport records the result of a run
+	SendReport(context.Context, *Report) (*ReportResponse, error)
+	// StreamProgress records how far along a run is until the checker pod closes the stream
+	StreamProgress(Reporting_StreamProgressServer) error
+	mustEmbedUnimplementedReportingServer()
+}
+
+// UnimplementedReportingServer must be embedded to have forward compatible implementations.
+type UnimplementedReportingServer struct {
+}
+
+func (UnimplementedReportingServer) SendReport(context.Context, *Report) (*ReportResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendReport not implemented")
+}
+func (UnimplementedReportingServer) StreamProgress(Reporting_StreamProgressServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamProgress not implemented")
+}
+func (UnimplementedReportingServer) mustEmbedUnimplementedReportingServer() {}
+
+// UnsafeReportingServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ReportingServer will
+// result in compilation errors.
+type UnsafeReportingServer interface {
+	mustEmbedUnimplementedReportingServer()
+}
+
+func RegisterReportingServer(s grpc.ServiceRegistrar, srv ReportingServer) {
+	s.RegisterService(&Reporting_ServiceDesc, srv)
+}
+
+func _Reporting_SendReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Report)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReportingServer).SendReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Reporting_SendReport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReportingServer).SendReport(ctx, req.(*Report))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Reporting_StreamProgress_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ReportingServer).StreamProgress(&reportingStreamProgressServer{stream})
+}
+
+type Reporting_StreamProgressServer interface {
+	SendAndClose(*ProgressSummary) error
+	Recv() (*Progress, error)
+	grpc.ServerStream
+}
+
+type reportingStreamProgressServer struct {
+	grpc.ServerStream
+}
+
+func (x *reportingStreamProgressServer) SendAndClose(m *ProgressSummary) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *reportingStreamProgressServer) Recv() (*Progress, error) {
+	m := new(Progress)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Reporting_ServiceDesc is the grpc.ServiceDesc for Reporting service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Reporting_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kuberhealthy.report.v1.Reporting",
+	HandlerType: (*ReportingServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SendReport",
+			Handler:    _Reporting_SendReport_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamProgress",
+			Handler:       _Reporting_StreamProgress_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "report.proto",
+}
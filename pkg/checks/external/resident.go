@@ -0,0 +1,292 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// ResidentPollTimeout is how long a resident checker's poll for its next run is held open when no run is due
+const ResidentPollTimeout = time.Second * 30
+
+// defaultResidentTTL is how long a resident checker stays registered without polling for runs
+const defaultResidentTTL = time.Minute * 2
+
+// maxPendingResidentRuns is how many runs of a resident check can wait to be taken by its resident checkers
+const maxPendingResidentRuns = 10
+
+// ErrNotResidentCheck is returned when registering for a check that is not configured as a resident check
+var ErrNotResidentCheck = errors.New("check is not a resident check")
+
+// ErrResidentNotRegistered is returned when a pod that is not registered as a resident checker of a check asks for
+// its runs
+var ErrResidentNotRegistered = errors.New("pod is not registered as a resident checker of the check")
+
+// ErrNoResident is returned by runs of resident checks when no resident checker is registered to take the run
+var ErrNoResident = errors.New("no resident checker is registered to take the run")
+
+// ErrResidentBacklog is returned by runs of resident checks when too many runs are already waiting to be taken
+var ErrResidentBacklog = errors.New("too many runs are waiting to be taken by resident checkers")
+
+// Resident is a long lived checker pod registered to take the runs of a resident check
+type Resident struct {
+	PodName  string
+	PodIP    string
+	LastSeen time.Time
+}
+
+// residentCheck holds the registered resident checkers of a check and the runs waiting to be taken by them
+type residentCheck struct {
+	residents map[string]Resident // keyed by pod IP
+	runs      chan status.RunRequest
+}
+
+// ResidentRegistry hands the runs of resident checks to the long lived checker pods registered for them, instead of
+// spawning a checker pod for every run.  It is safe for concurrent use.
+type ResidentRegistry struct {
+	sync.Mutex
+	checks map[string]*residentCheck
+	ttl    time.Duration
+}
+
+// NewResidentRegistry creates an empty resident registry
+func NewResidentRegistry() *ResidentRegistry {
+	return &ResidentRegistry{
+		checks: make(map[string]*residentCheck),
+		ttl:    defaultResidentTTL,
+	}
+}
+
+// residentKey returns the key a check is registered under
+func residentKey(checkName string, namespace string) string {
+	return namespace + "/" + checkName
+}
+
+// Allow lets resident checkers register for a check
+func (rr *ResidentRegistry) Allow(checkName string, namespace string) {
+	if rr == nil {
+		return
+	}
+	rr.Lock()
+	defer rr.Unlock()
+	key := residentKey(checkName, namespace)
+	if _, ok := rr.checks[key]; !ok {
+		rr.checks[key] = &residentCheck{
+			residents: make(map[string]Resident),
+			runs:      make(chan status.RunRequest, maxPendingResidentRuns),
+		}
+	}
+}
+
+// IsResidentCheck indicates that a check hands its runs to resident checkers
+func (rr *ResidentRegistry) IsResidentCheck(checkName string, namespace string) bool {
+	if rr == nil {
+		return false
+	}
+	rr.Lock()
+	defer rr.Unlock()
+	_, ok := rr.checks[residentKey(checkName, namespace)]
+	return ok
+}
+
+// Register records a pod as a resident checker of a check
+func (rr *ResidentRegistry) Register(checkName string, namespace string, podName string, podIP string, now time.Time) error {
+	if rr == nil {
+		return ErrNotResidentCheck
+	}
+	rr.Lock()
+	defer rr.Unlock()
+	rc, ok := rr.checks[residentKey(checkName, namespace)]
+	if !ok {
+		return ErrNotResidentCheck
+	}
+	rc.residents[podIP] = Resident{PodName: podName, PodIP: podIP, LastSeen: now}
+	return nil
+}
+
+// IsResident indicates that the pod with the supplied IP is a registered resident checker of a check
+func (rr *ResidentRegistry) IsResident(checkName string, namespace string, podIP string, now time.Time) bool {
+	if rr == nil {
+		return false
+	}
+	rr.Lock()
+	defer rr.Unlock()
+	return rr.isResident(checkName, namespace, podIP, now)
+}
+
+// isResident is IsResident for callers that already hold the lock
+func (rr *ResidentRegistry) isResident(checkName string, namespace string, podIP string, now time.Time) bool {
+	rc, ok := rr.checks[residentKey(checkName, namespace)]
+	if !ok {
+		return false
+	}
+	r, ok := rc.residents[podIP]
+	if !ok {
+		return false
+	}
+	if now.Sub(r.LastSeen) > rr.ttl {
+		delete(rc.residents, podIP)
+		return false
+	}
+	return true
+}
+
+// Registered indicates that at least one resident checker that has polled recently is registered for a check
+func (rr *ResidentRegistry) Registered(checkName string, namespace string, now time.Time) bool {
+	if rr == nil {
+		return false
+	}
+	rr.Lock()
+	defer rr.Unlock()
+	rc, ok := rr.checks[residentKey(checkName, namespace)]
+	if !ok {
+		return false
+	}
+	for ip := range rc.residents {
+		if rr.isResident(checkName, namespace, ip, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// Offer queues a run of a check to be taken by one of its resident checkers
+func (rr *ResidentRegistry) Offer(checkName string, namespace string, run status.RunRequest) error {
+	if rr == nil {
+		return ErrNotResidentCheck
+	}
+	rr.Lock()
+	rc, ok := rr.checks[residentKey(checkName, namespace)]
+	rr.Unlock()
+	if !ok {
+		return ErrNotResidentCheck
+	}
+	select {
+	case rc.runs <- run:
+		return nil
+	default:
+		return ErrResidentBacklog
+	}
+}
+
+// Pending returns how many runs of resident checks are waiting to be taken by their resident checkers
+func (rr *ResidentRegistry) Pending() int {
+	if rr == nil {
+		return 0
+	}
+	rr.Lock()
+	defer rr.Unlock()
+	var pending int
+	for _, rc := range rr.checks {
+		pending += len(rc.runs)
+	}
+	return pending
+}
+
+// Next waits for the next run of a check on behalf of one of its resident checkers.  Runs whose deadline has already
+// passed are skipped.  False is returned when no run was due before the context was done.
+func (rr *ResidentRegistry) Next(ctx context.Context, checkName string, namespace string, podIP string) (status.RunRequest, bool, error) {
+	if rr == nil {
+		return status.RunRequest{}, false, ErrNotResidentCheck
+	}
+	rr.Lock()
+	rc, ok := rr.checks[residentKey(checkName, namespace)]
+	if !ok || !rr.isResident(checkName, namespace, podIP, time.Now()) {
+		rr.Unlock()
+		return status.RunRequest{}, false, ErrResidentNotRegistered
+	}
+	// polling for runs keeps a resident checker registered
+	r := rc.residents[podIP]
+	r.LastSeen = time.Now()
+	rc.residents[podIP] = r
+	rr.Unlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return status.RunRequest{}, false, nil
+		case run := <-rc.runs:
+			if time.Now().After(time.Unix(run.Deadline, 0)) {
+				continue
+			}
+			return run, true, nil
+		}
+	}
+}
+
+// runResident hands a run to the resident checkers of the check instead of creating a checker pod, then waits for
+// the run to be reported
+func (ext *Checker) runResident(ctx context.Context) error {
+
+	// create a context for this run
+	ext.beginRun(ctx)
+	defer ext.shutdownCTXFunc()
+
+	// report problems found in the spec before anything is handed out
+	if len(ext.SpecErrors) > 0 {
+		return errors.New("invalid check spec: " + strings.Join(ext.SpecErrors, "; "))
+	}
+
+	deadline := ext.clock().Now().Add(ext.RunTimeout)
+	ext.Runs.Start(ext.currentCheckUUID, ext.CheckName, ext.Namespace, "", deadline)
+	if ext.MaxDeadlineExtension > 0 {
+		ext.Runs.AllowExtension(ext.currentCheckUUID, ext.MaxDeadlineExtension)
+	}
+	ext.startTrace()
+	timeoutChan := ext.deadlineReached(ext.currentCheckUUID, deadline)
+
+	if !ext.Residents.Registered(ext.CheckName, ext.Namespace, ext.clock().Now()) {
+		ext.Runs.Expire(ext.currentCheckUUID)
+		return ext.newError(ErrNoResident.Error())
+	}
+	ext.log("Handing run", ext.currentCheckUUID, "to resident checkers")
+	run := status.RunRequest{UUID: ext.currentCheckUUID, Deadline: deadline.Unix(), TraceParent: ext.currentTraceParent}
+	if len(ext.ReportSigningKey) > 0 {
+		run.SigningKey = RunSigningKey(ext.ReportSigningKey, ext.currentCheckUUID)
+	}
+	err := ext.Residents.Offer(ext.CheckName, ext.Namespace, run)
+	if err != nil {
+		ext.Runs.Expire(ext.currentCheckUUID)
+		return ext.newError(err.Error())
+	}
+
+	select {
+	case <-timeoutChan:
+		ext.log("timed out waiting for resident checker to report in")
+		ext.Runs.Expire(ext.currentCheckUUID)
+		return &TimeoutError{Err: ext.newError("timed out waiting for resident checker to report in")}
+	case <-ext.runReported(ext.currentCheckUUID):
+		ext.log("Resident checker has reported status for run", ext.currentCheckUUID)
+	case <-ext.shutdownCTX.Done():
+		ext.log("shutting down check. aborting wait for resident checker to report in")
+	}
+	return nil
+}
+
+// runReported returns a channel that is closed once a report for the run is recorded in the run tracker
+func (ext *Checker) runReported(uuid string) <-chan struct{} {
+	reported := make(chan struct{})
+	done := ext.shutdownCTX.Done()
+
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if run, ok := ext.Runs.Get(uuid); ok && run.State == RunReported {
+				close(reported)
+				return
+			}
+		}
+	}()
+
+	return reported
+}
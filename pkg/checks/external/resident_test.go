@@ -0,0 +1,114 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestResidentRegistryRegister ensures only resident checks can be registered for and that registrations expire
+// when resident checkers stop polling
+func TestResidentRegistryRegister(t *testing.T) {
+	now := time.Now()
+	rr := NewResidentRegistry()
+
+	err := rr.Register("check", "ns", "pod", "10.0.0.1", now)
+	if !errors.Is(err, ErrNotResidentCheck) {
+		t.Fatalf("registering for a check that is not resident returned %v but expected %v", err, ErrNotResidentCheck)
+	}
+
+	rr.Allow("check", "ns")
+	if !rr.IsResidentCheck("check", "ns") || rr.IsResidentCheck("other", "ns") {
+		t.Fatalf("only the allowed check should be a resident check")
+	}
+	if rr.Registered("check", "ns", now) {
+		t.Fatalf("check had a resident checker before any registered")
+	}
+
+	err = rr.Register("check", "ns", "pod", "10.0.0.1", now)
+	if err != nil {
+		t.Fatalf("failed to register resident checker: %s", err)
+	}
+	if !rr.IsResident("check", "ns", "10.0.0.1", now) || rr.IsResident("check", "ns", "10.0.0.2", now) {
+		t.Fatalf("only the registered pod should be a resident checker")
+	}
+	if !rr.Registered("check", "ns", now) {
+		t.Fatalf("check had no resident checker after one registered")
+	}
+
+	later := now.Add(defaultResidentTTL + time.Second)
+	if rr.IsResident("check", "ns", "10.0.0.1", later) || rr.Registered("check", "ns", later) {
+		t.Fatalf("resident checker stayed registered past its ttl")
+	}
+}
+
+// TestResidentRegistryNext ensures runs are handed to registered resident checkers in order, skipping runs that are
+// already past their deadline
+func TestResidentRegistryNext(t *testing.T) {
+	rr := NewResidentRegistry()
+	rr.Allow("check", "ns")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	_, _, err := rr.Next(ctx, "check", "ns", "10.0.0.1")
+	if !errors.Is(err, ErrResidentNotRegistered) {
+		t.Fatalf("unregistered pod polling for runs returned %v but expected %v", err, ErrResidentNotRegistered)
+	}
+
+	err = rr.Register("check", "ns", "pod", "10.0.0.1", time.Now())
+	if err != nil {
+		t.Fatalf("failed to register resident checker: %s", err)
+	}
+
+	_, ok, err := rr.Next(ctx, "check", "ns", "10.0.0.1")
+	if err != nil || ok {
+		t.Fatalf("polling without a run due returned ok %t and error %v", ok, err)
+	}
+
+	deadline := time.Now().Add(time.Minute).Unix()
+	for _, run := range []status.RunRequest{
+		{UUID: "expired", Deadline: time.Now().Add(-time.Minute).Unix()},
+		{UUID: "current", Deadline: deadline},
+	} {
+		err = rr.Offer("check", "ns", run)
+		if err != nil {
+			t.Fatalf("failed to offer run %s: %s", run.UUID, err)
+		}
+	}
+
+	run, ok, err := rr.Next(context.Background(), "check", "ns", "10.0.0.1")
+	if err != nil || !ok {
+		t.Fatalf("polling with a run due returned ok %t and error %v", ok, err)
+	}
+	if run.UUID != "current" || run.Deadline != deadline {
+		t.Fatalf("resident checker was handed run %+v but expected the current run", run)
+	}
+}
+
+// TestResidentRegistryBacklog ensures runs are refused once too many wait to be taken
+func TestResidentRegistryBacklog(t *testing.T) {
+	rr := NewResidentRegistry()
+
+	err := rr.Offer("check", "ns", status.RunRequest{UUID: "run"})
+	if !errors.Is(err, ErrNotResidentCheck) {
+		t.Fatalf("offering a run of a check that is not resident returned %v but expected %v", err, ErrNotResidentCheck)
+	}
+
+	rr.Allow("check", "ns")
+	for i := 0; i < maxPendingResidentRuns; i++ {
+		err = rr.Offer("check", "ns", status.RunRequest{UUID: "run"})
+		if err != nil {
+			t.Fatalf("failed to offer run %d: %s", i, err)
+		}
+	}
+	err = rr.Offer("check", "ns", status.RunRequest{UUID: "run"})
+	if !errors.Is(err, ErrResidentBacklog) {
+		t.Fatalf("offering a run over the backlog returned %v but expected %v", err, ErrResidentBacklog)
+	}
+	if rr.Pending() != maxPendingResidentRuns {
+		t.Fatalf("%d runs are pending but expected %d", rr.Pending(), maxPendingResidentRuns)
+	}
+}
@@ -0,0 +1,217 @@
+package external
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	khjobv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khjob/v1"
+)
+
+// KHRunNamespace is the environment variable that tells checker pods the ephemeral namespace created for their run
+const KHRunNamespace = "KH_RUN_NAMESPACE"
+
+// RunNamespaceLabel is the label set on ephemeral run namespaces.  Its value is the namespace of the check.
+const RunNamespaceLabel = "comcast.github.io/run-namespace-of"
+
+// RunNamespaceDeadlineAnnotation is the annotation set on ephemeral run namespaces that holds the deadline of their
+// run in RFC3339 format.  Namespaces left behind past their deadline are deleted by the reaper.
+const RunNamespaceDeadlineAnnotation = "comcast.github.io/run-deadline"
+
+// runNamespacePrefix starts the name of every ephemeral run namespace
+const runNamespacePrefix = "kh-run-"
+
+// DefaultRunNamespaceClusterRole is the cluster role granted to checker pods within their run namespace when the
+// check does not set one
+const DefaultRunNamespaceClusterRole = "edit"
+
+// runNamespaceObjectName is the name of the quota, limit range and role binding created in run namespaces
+const runNamespaceObjectName = "kuberhealthy"
+
+// defaultRunNamespaceQuota is the hard limit of the resource quota of run namespaces when the check does not set one
+var defaultRunNamespaceQuota = apiv1.ResourceList{
+	apiv1.ResourcePods:                   resource.MustParse("10"),
+	apiv1.ResourceServices:               resource.MustParse("10"),
+	apiv1.ResourcePersistentVolumeClaims: resource.MustParse("5"),
+	apiv1.ResourceRequestsCPU:            resource.MustParse("2"),
+	apiv1.ResourceRequestsMemory:         resource.MustParse("4Gi"),
+	apiv1.ResourceRequestsStorage:        resource.MustParse("20Gi"),
+}
+
+// defaultRunNamespaceRequests are the requests given to containers created in run namespaces that do not set any,
+// so that they are admitted by the resource quota
+var defaultRunNamespaceRequests = apiv1.ResourceList{
+	apiv1.ResourceCPU:    resource.MustParse("50m"),
+	apiv1.ResourceMemory: resource.MustParse("64Mi"),
+}
+
+// IsolatedNamespace configures the ephemeral namespace created for each run of a check
+type IsolatedNamespace struct {
+	Quota       apiv1.ResourceList
+	ClusterRole string
+}
+
+// checkIsolatedNamespace converts the isolated namespace settings of a khcheck spec.  Nil is returned when the
+// check does not enable them.
+func checkIsolatedNamespace(i *khcheckv1.IsolatedNamespace) *IsolatedNamespace {
+	if i == nil || !i.Enabled {
+		return nil
+	}
+	return &IsolatedNamespace{Quota: i.Quota, ClusterRole: i.ClusterRole}
+}
+
+// jobIsolatedNamespace converts the isolated namespace settings of a khjob spec.  Nil is returned when the job does
+// not enable them.
+func jobIsolatedNamespace(i *khjobv1.IsolatedNamespace) *IsolatedNamespace {
+	if i == nil || !i.Enabled {
+		return nil
+	}
+	return &IsolatedNamespace{Quota: i.Quota, ClusterRole: i.ClusterRole}
+}
+
+// RunNamespaceName returns the name of the ephemeral namespace of a run.  The name is derived from the check name and
+// run UUID so that it can be found again when a run is resumed and never collides between overlapping runs.
+func RunNamespaceName(checkName string, runUUID string) string {
+	suffix := runUUID
+	if len(suffix) > 8 {
+		suffix = suffix[:8]
+	}
+	name := checkName
+	if max := 63 - len(runNamespacePrefix) - len(suffix) - 1; len(name) > max {
+		name = strings.TrimRight(name[:max], "-")
+	}
+	return strings.ToLower(runNamespacePrefix + name + "-" + suffix)
+}
+
+// RunNamespaceExpired indicates that a namespace is an ephemeral run namespace whose run deadline has passed.
+// Namespaces without a readable deadline are never considered expired.
+func RunNamespaceExpired(ns apiv1.Namespace, now time.Time) bool {
+	if _, ok := ns.Labels[RunNamespaceLabel]; !ok {
+		return false
+	}
+	deadline, err := time.Parse(time.RFC3339, ns.Annotations[RunNamespaceDeadlineAnnotation])
+	if err != nil {
+		return false
+	}
+	return now.After(deadline)
+}
+
+// newRunNamespaceObjects builds the namespace of a run along with the resource quota and limit range that bound it
+// and the role binding that lets the checker pod's service account manage resources within it
+func newRunNamespaceObjects(name string, iso IsolatedNamespace, checkName string, checkNamespace string, serviceAccount string, deadline time.Time) (*apiv1.Namespace, *apiv1.ResourceQuota, *apiv1.LimitRange, *rbacv1.RoleBinding) {
+	ns := &apiv1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				RunNamespaceLabel:          checkNamespace,
+				kuberhealthyCheckNameLabel: checkName,
+			},
+			Annotations: map[string]string{
+				RunNamespaceDeadlineAnnotation: deadline.UTC().Format(time.RFC3339),
+			},
+		},
+	}
+
+	hard := iso.Quota
+	if len(hard) == 0 {
+		hard = defaultRunNamespaceQuota
+	}
+	quota := &apiv1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: runNamespaceObjectName, Namespace: name},
+		Spec:       apiv1.ResourceQuotaSpec{Hard: hard.DeepCopy()},
+	}
+
+	limits := &apiv1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: runNamespaceObjectName, Namespace: name},
+		Spec: apiv1.LimitRangeSpec{Limits: []apiv1.LimitRangeItem{
+			{Type: apiv1.LimitTypeContainer, DefaultRequest: defaultRunNamespaceRequests.DeepCopy()},
+		}},
+	}
+
+	clusterRole := iso.ClusterRole
+	if len(clusterRole) == 0 {
+		clusterRole = DefaultRunNamespaceClusterRole
+	}
+	if len(serviceAccount) == 0 {
+		serviceAccount = "default"
+	}
+	binding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: runNamespaceObjectName, Namespace: name},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: clusterRole},
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.ServiceAccountKind, Name: serviceAccount, Namespace: checkNamespace},
+		},
+	}
+
+	return ns, quota, limits, binding
+}
+
+// createRunNamespace creates the ephemeral namespace of a run and everything that bounds it.  The namespace is
+// removed again if any part of it can not be created.
+func createRunNamespace(ctx context.Context, client kubernetes.Interface, name string, iso IsolatedNamespace, checkName string, checkNamespace string, serviceAccount string, deadline time.Time) error {
+	ns, quota, limits, binding := newRunNamespaceObjects(name, iso, checkName, checkNamespace, serviceAccount, deadline)
+
+	_, err := client.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create run namespace %s: %w", name, err)
+	}
+
+	_, err = client.CoreV1().ResourceQuotas(name).Create(ctx, quota, metav1.CreateOptions{})
+	if err == nil {
+		_, err = client.CoreV1().LimitRanges(name).Create(ctx, limits, metav1.CreateOptions{})
+	}
+	if err == nil {
+		_, err = client.RbacV1().RoleBindings(name).Create(ctx, binding, metav1.CreateOptions{})
+	}
+	if err != nil {
+		deleteErr := deleteRunNamespace(ctx, client, name)
+		if deleteErr != nil {
+			return fmt.Errorf("failed to set up run namespace %s: %w (and failed to remove it: %s)", name, err, deleteErr)
+		}
+		return fmt.Errorf("failed to set up run namespace %s: %w", name, err)
+	}
+	return nil
+}
+
+// deleteRunNamespace deletes the ephemeral namespace of a run along with everything in it.  Namespaces that are
+// already gone are not an error.
+func deleteRunNamespace(ctx context.Context, client kubernetes.Interface, name string) error {
+	propagation := metav1.DeletePropagationBackground
+	err := client.CoreV1().Namespaces().Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete run namespace %s: %w", name, err)
+	}
+	return nil
+}
+
+// setupRunNamespace creates the ephemeral namespace of the current run when the check isolates its test resources
+func (ext *Checker) setupRunNamespace(ctx context.Context, deadline time.Time) error {
+	if ext.IsolatedNamespace == nil {
+		return nil
+	}
+	ext.log("Creating run namespace", ext.runNamespace)
+	return createRunNamespace(ctx, ext.KubeClient, ext.runNamespace, *ext.IsolatedNamespace, ext.CheckName, ext.Namespace, ext.OriginalPodSpec.ServiceAccountName, deadline)
+}
+
+// teardownRunNamespace deletes the ephemeral namespace of the current run, if any.  Namespaces that can not be
+// deleted here are removed by the reaper once their deadline passes.
+func (ext *Checker) teardownRunNamespace(ctx context.Context) {
+	if len(ext.runNamespace) == 0 {
+		return
+	}
+	ext.log("Deleting run namespace", ext.runNamespace)
+	err := deleteRunNamespace(ctx, ext.KubeClient, ext.runNamespace)
+	if err != nil {
+		ext.log(err.Error())
+	}
+	ext.runNamespace = ""
+}
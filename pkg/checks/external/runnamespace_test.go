@@ -0,0 +1,111 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// TestRunNamespaceName ensures run namespaces are unique per run and always valid namespace names
+func TestRunNamespaceName(t *testing.T) {
+	var testCases = []struct {
+		checkName string
+		runUUID   string
+		expected  string
+	}{
+		{"deployment", "1b4e28ba-2fa1-11d2-883f-0016d3cca427", "kh-run-deployment-1b4e28ba"},
+		{"DNS-Internal", "abc", "kh-run-dns-internal-abc"},
+		{strings.Repeat("a", 60) + "-b", "1b4e28ba-2fa1", "kh-run-" + strings.Repeat("a", 47) + "-1b4e28ba"},
+	}
+
+	for _, tc := range testCases {
+		name := RunNamespaceName(tc.checkName, tc.runUUID)
+		if name != tc.expected {
+			t.Fatalf("run namespace of %s was %s but expected %s", tc.checkName, name, tc.expected)
+		}
+		if len(name) > 63 {
+			t.Fatalf("run namespace %s is longer than 63 characters", name)
+		}
+	}
+}
+
+// TestRunNamespaceExpired ensures only run namespaces past their deadline are expired
+func TestRunNamespaceExpired(t *testing.T) {
+	now := time.Now()
+	runNamespace := func(labeled bool, deadline string) apiv1.Namespace {
+		ns := apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{RunNamespaceDeadlineAnnotation: deadline}}}
+		if labeled {
+			ns.Labels = map[string]string{RunNamespaceLabel: "kuberhealthy"}
+		}
+		return ns
+	}
+
+	var testCases = []struct {
+		description string
+		namespace   apiv1.Namespace
+		expected    bool
+	}{
+		{"past deadline", runNamespace(true, now.Add(-time.Minute).Format(time.RFC3339)), true},
+		{"before deadline", runNamespace(true, now.Add(time.Minute).Format(time.RFC3339)), false},
+		{"unreadable deadline", runNamespace(true, "soon"), false},
+		{"not a run namespace", runNamespace(false, now.Add(-time.Minute).Format(time.RFC3339)), false},
+	}
+
+	for _, tc := range testCases {
+		if RunNamespaceExpired(tc.namespace, now) != tc.expected {
+			t.Fatalf("%s: expected expired to be %t", tc.description, tc.expected)
+		}
+	}
+}
+
+// TestCreateRunNamespace ensures run namespaces are created with their quota, limit range and role binding and are
+// removed again when they can not be set up
+func TestCreateRunNamespace(t *testing.T) {
+	ctx := context.Background()
+	deadline := time.Now().Add(time.Minute)
+
+	client := fake.NewSimpleClientset()
+	iso := IsolatedNamespace{Quota: apiv1.ResourceList{apiv1.ResourcePods: resource.MustParse("3")}}
+	err := createRunNamespace(ctx, client, "kh-run-test-abc", iso, "test", "kuberhealthy", "", deadline)
+	if err != nil {
+		t.Fatalf("failed to create run namespace: %s", err)
+	}
+	ns, err := client.CoreV1().Namespaces().Get(ctx, "kh-run-test-abc", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("run namespace was not created: %s", err)
+	}
+	if ns.Labels[RunNamespaceLabel] != "kuberhealthy" || ns.Annotations[RunNamespaceDeadlineAnnotation] != deadline.UTC().Format(time.RFC3339) {
+		t.Fatalf("run namespace was not labeled with its check and deadline: %+v", ns.ObjectMeta)
+	}
+	quota, err := client.CoreV1().ResourceQuotas("kh-run-test-abc").Get(ctx, runNamespaceObjectName, metav1.GetOptions{})
+	if err != nil || len(quota.Spec.Hard) != 1 || quota.Spec.Hard.Pods().Value() != 3 {
+		t.Fatalf("run namespace quota was not created from the check: %v %+v", err, quota)
+	}
+	binding, err := client.RbacV1().RoleBindings("kh-run-test-abc").Get(ctx, runNamespaceObjectName, metav1.GetOptions{})
+	if err != nil || binding.RoleRef.Name != DefaultRunNamespaceClusterRole || binding.Subjects[0].Name != "default" || binding.Subjects[0].Namespace != "kuberhealthy" {
+		t.Fatalf("run namespace role binding was not created for the default service account: %v %+v", err, binding)
+	}
+
+	// a role binding that can not be created removes the namespace again
+	client = fake.NewSimpleClientset()
+	client.PrependReactor("create", "rolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("forbidden")
+	})
+	err = createRunNamespace(ctx, client, "kh-run-test-def", IsolatedNamespace{}, "test", "kuberhealthy", "checker", deadline)
+	if err == nil {
+		t.Fatalf("expected an error when the role binding could not be created")
+	}
+	_, err = client.CoreV1().Namespaces().Get(ctx, "kh-run-test-def", metav1.GetOptions{})
+	if err == nil {
+		t.Fatalf("run namespace was left behind after failing to set it up")
+	}
+}
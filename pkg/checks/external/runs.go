@@ -0,0 +1,667 @@
+package external
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/clock"
+)
+
+// defaultRunRetention is how long a run is remembered after its deadline has passed.  Reports for runs older than
+// this are treated the same as reports from unknown runs.
+const defaultRunRetention = time.Hour
+
+// RunState describes where a run is in its lifecycle
+type RunState string
+
+// Runs start out Running.  They become Reported when the checker pod reports in before the deadline and Expired when
+// Kuberhealthy gives up waiting on them.
+const (
+	RunRunning  RunState = "Running"
+	RunReported RunState = "Reported"
+	RunExpired  RunState = "Expired"
+)
+
+// Run holds the validity window of a single run UUID handed out to a checker pod
+type Run struct {
+	UUID          string                   `json:"uuid"`
+	CheckName     string                   `json:"check"`
+	Namespace     string                   `json:"namespace"`
+	PodName       string                   `json:"pod"` // the checker pod created for the run
+	Started       time.Time                `json:"started"`
+	Deadline      time.Time                `json:"deadline"`
+	MaxExtension  time.Duration            `json:"maxExtension,omitempty"` // the most the deadline can be extended by at the request of the checker pod
+	Extended      time.Duration            `json:"extended,omitempty"`     // how much the deadline has been extended by so far
+	Heartbeat     time.Duration            `json:"heartbeat,omitempty"`    // how long the run may go without a heartbeat once its deadline is near, if heartbeats are expected
+	State         RunState                 `json:"state"`
+	Canceled      bool                     `json:"canceled,omitempty"`     // Kuberhealthy no longer wants the result of the run
+	CancelReason  string                   `json:"cancelReason,omitempty"` // why the run was canceled
+	FanOut        FanOut                   `json:"fanOut,omitempty"`       // how the run spawned a checker pod per node or zone, if it did
+	Targets       []string                 `json:"targets,omitempty"`      // the nodes or zones a fanned out run spawned checker pods on
+	Aggregation   khcheckv1.Aggregation    `json:"aggregation,omitempty"`  // how the results of the targets of a fanned out run decide its result
+	TraceParent   string                   `json:"traceParent,omitempty"`  // the W3C trace context handed to the checker pods of the run
+	TargetReports map[string]status.Report `json:"-"`                      // the reports received so far from the targets of a fanned out run
+	Progressed    time.Time                `json:"-"`                      // when the checker pod last reported progress, if it has
+	Report        *status.Report           `json:"-"`                      // the report received for this run, if any
+	RequestID     string                   `json:"-"`                      // the request ID of the report received for this run, if any
+	Ended         bool                     `json:"-"`                      // the checker has stopped waiting on the run
+	restored      bool                     // the run was loaded from the run store and has not been resumed yet
+}
+
+// RunStore persists the runs that are in flight so that a restarted Kuberhealthy can pick them back up instead of
+// starting duplicate checker pods
+type RunStore interface {
+	Load() ([]Run, error)
+	Save(runs []Run) error
+}
+
+// IsLate indicates that a report received at the supplied time is too late to count towards the run's state.  This
+// is the case when the run has already been expired or when its deadline has passed.
+func (r Run) IsLate(t time.Time) bool {
+	return r.State == RunExpired || t.After(r.Deadline)
+}
+
+// Status summarizes the run for the /externalCheckStatus/{uuid} endpoint
+func (r Run) Status() status.RunStatus {
+	rs := status.RunStatus{
+		UUID:      r.UUID,
+		CheckName: r.CheckName,
+		Namespace: r.Namespace,
+		State:     string(r.State),
+		RequestID: r.RequestID,
+	}
+	if r.Report != nil {
+		rs.Accepted = r.State == RunReported
+		rs.Late = r.State == RunExpired
+		rs.OK = r.Report.OK
+		rs.Errors = r.Report.Errors
+	}
+	return rs
+}
+
+// Cancellation tells the checker pod of the run whether Kuberhealthy still wants its result.  Runs that timed out
+// are seen as canceled too, because their result no longer counts.
+func (r Run) Cancellation(t time.Time) status.Cancellation {
+	switch {
+	case r.Canceled:
+		return status.Cancellation{Canceled: true, Reason: r.CancelReason}
+	case r.IsLate(t):
+		return status.Cancellation{Canceled: true, Reason: "the run timed out"}
+	}
+	return status.Cancellation{}
+}
+
+// RunTracker tracks the validity window of every run UUID given out to checker pods.  This lets the report handler
+// tell the difference between a report that arrived on time and one that arrived after the run was already timed
+// out.  It is safe for concurrent use.
+type RunTracker struct {
+	sync.Mutex
+	runs      map[string]*Run
+	retention time.Duration
+	store     RunStore    // persists in flight runs when set
+	saveMu    sync.Mutex  // keeps snapshots of in flight runs from being saved out of order
+	clock     clock.Clock // the clock run start times are taken from
+	frozen    bool        // runs are no longer canceled, ended or expired so that the next master can adopt them
+}
+
+// NewRunTracker creates a new RunTracker that forgets runs after the default retention
+func NewRunTracker() *RunTracker {
+	return &RunTracker{
+		runs:      make(map[string]*Run),
+		retention: defaultRunRetention,
+		clock:     clock.Real,
+	}
+}
+
+// SetClock sets the clock run start times are taken from
+func (rt *RunTracker) SetClock(c clock.Clock) {
+	rt.Lock()
+	defer rt.Unlock()
+	rt.clock = clock.OrReal(c)
+}
+
+// SetStore sets the store that in flight runs are persisted to
+func (rt *RunTracker) SetStore(store RunStore) {
+	rt.Lock()
+	defer rt.Unlock()
+	rt.store = store
+}
+
+// Restore loads the in flight runs from the run store.  Restored runs can be resumed once with Resume.
+func (rt *RunTracker) Restore() error {
+	if rt == nil || rt.store == nil {
+		return nil
+	}
+	runs, err := rt.store.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load in flight runs: %w", err)
+	}
+
+	for _, r := range runs {
+		rt.Adopt(r)
+	}
+	return nil
+}
+
+// Adopt adds a run that was started by another Kuberhealthy instance so that it can be resumed once with Resume.  Runs
+// that are already known are left alone.
+func (rt *RunTracker) Adopt(run Run) {
+	if rt == nil {
+		return
+	}
+	rt.Lock()
+	defer rt.Unlock()
+
+	if _, ok := rt.runs[run.UUID]; ok {
+		return
+	}
+	run.restored = true
+	rt.runs[run.UUID] = &run
+}
+
+// RunFromPod describes the run of a checker pod from its labels and environment.  Pods that were not created by a
+// checker are not runs.
+func RunFromPod(pod apiv1.Pod) (Run, bool) {
+	uuid := pod.Labels[kuberhealthyRunIDLabel]
+	checkName := pod.Labels[kuberhealthyCheckNameLabel]
+	if len(uuid) == 0 || len(checkName) == 0 {
+		return Run{}, false
+	}
+
+	run := Run{
+		UUID:      uuid,
+		CheckName: checkName,
+		Namespace: pod.Namespace,
+		PodName:   pod.Name,
+		Started:   pod.CreationTimestamp.Time,
+		Deadline:  pod.CreationTimestamp.Add(defaultTimeout),
+		State:     RunRunning,
+	}
+
+	// use the deadline and heartbeat timeout the checker pod was given when it was created
+	for _, c := range pod.Spec.Containers {
+		for _, e := range c.Env {
+			switch e.Name {
+			case KHDeadline:
+				deadline, err := strconv.ParseInt(e.Value, 10, 64)
+				if err == nil {
+					run.Deadline = time.Unix(deadline, 0)
+				}
+			case KHHeartbeatTimeout:
+				seconds, err := strconv.ParseInt(e.Value, 10, 64)
+				if err == nil {
+					run.Heartbeat = time.Duration(seconds) * time.Second
+				}
+			}
+		}
+	}
+	return run, true
+}
+
+// Resume returns a restored run of the supplied check that is still within its deadline.  Each restored run is only
+// returned once.  Restored runs of the check that are past their deadline are ended.
+func (rt *RunTracker) Resume(checkName string, namespace string, now time.Time) (Run, bool) {
+	if rt == nil {
+		return Run{}, false
+	}
+	rt.Lock()
+
+	var resumed *Run
+	var ended bool
+	for _, r := range rt.runs {
+		if !r.restored || r.CheckName != checkName || r.Namespace != namespace {
+			continue
+		}
+		r.restored = false
+		if r.State != RunRunning || r.Canceled || now.After(r.Deadline) || resumed != nil {
+			r.Ended = true
+			ended = true
+			continue
+		}
+		resumed = r
+	}
+
+	var run Run
+	if resumed != nil {
+		run = *resumed
+	}
+	rt.Unlock()
+
+	if ended {
+		rt.persist()
+	}
+	return run, resumed != nil
+}
+
+// Start records that a run with the supplied UUID has begun and must report in before the deadline
+func (rt *RunTracker) Start(uuid string, checkName string, namespace string, podName string, deadline time.Time) {
+	if rt == nil {
+		return
+	}
+	rt.Lock()
+	now := rt.clock.Now()
+	rt.prune(now)
+	rt.runs[uuid] = &Run{
+		UUID:      uuid,
+		CheckName: checkName,
+		Namespace: namespace,
+		PodName:   podName,
+		Started:   now,
+		Deadline:  deadline,
+		State:     RunRunning,
+	}
+	rt.Unlock()
+
+	rt.persist()
+}
+
+// ErrExtensionNotAllowed is returned when a run asks for a deadline extension that its check does not allow
+var ErrExtensionNotAllowed = errors.New("deadline extensions are not allowed for this check")
+
+// ErrExtensionExhausted is returned when a run has already been extended by the most its check allows
+var ErrExtensionExhausted = errors.New("run has already been extended by the maximum allowed")
+
+// ErrRunNotExtendable is returned when a run asks for a deadline extension after it stopped running
+var ErrRunNotExtendable = errors.New("run is no longer running")
+
+// AllowExtension sets the most the deadline of a run can be extended by at the request of its checker pod
+func (rt *RunTracker) AllowExtension(uuid string, max time.Duration) {
+	if rt == nil {
+		return
+	}
+	rt.Lock()
+	r, ok := rt.runs[uuid]
+	if ok {
+		r.MaxExtension = max
+	}
+	rt.Unlock()
+
+	rt.persist()
+}
+
+// Extend pushes back the deadline of a running run by the requested duration.  Runs can only be extended by up to
+// the maximum extension of their check in total, so less than requested is granted when the run is near that limit.
+// Returns the new deadline and the extension granted.
+func (rt *RunTracker) Extend(uuid string, d time.Duration, now time.Time) (time.Time, time.Duration, error) {
+	if rt == nil {
+		return time.Time{}, 0, ErrRunNotExtendable
+	}
+	rt.Lock()
+	r, ok := rt.runs[uuid]
+	if !ok || r.State != RunRunning || r.Ended || r.IsLate(now) {
+		rt.Unlock()
+		return time.Time{}, 0, ErrRunNotExtendable
+	}
+	if r.MaxExtension <= 0 {
+		rt.Unlock()
+		return r.Deadline, 0, ErrExtensionNotAllowed
+	}
+	granted := d
+	if remaining := r.MaxExtension - r.Extended; granted > remaining {
+		granted = remaining
+	}
+	if granted <= 0 {
+		rt.Unlock()
+		return r.Deadline, 0, ErrExtensionExhausted
+	}
+	r.Deadline = r.Deadline.Add(granted)
+	r.Extended += granted
+	deadline := r.Deadline
+	rt.Unlock()
+
+	rt.persist()
+	return deadline, granted, nil
+}
+
+// SetTraceParent records the W3C trace context that the checker pods of a run were handed
+func (rt *RunTracker) SetTraceParent(uuid string, traceParent string) {
+	if rt == nil {
+		return
+	}
+	rt.Lock()
+	r, ok := rt.runs[uuid]
+	if ok {
+		r.TraceParent = traceParent
+	}
+	rt.Unlock()
+
+	rt.persist()
+}
+
+// ErrHeartbeatsNotExpected is returned when a run sends a heartbeat that its check does not expect
+var ErrHeartbeatsNotExpected = errors.New("heartbeats are not expected for this check")
+
+// ExpectHeartbeats sets how long a run may go without sending a heartbeat before it times out.  Heartbeats push the
+// deadline of the run back to that long after they arrive, so a run that keeps sending them is not timed out.
+func (rt *RunTracker) ExpectHeartbeats(uuid string, timeout time.Duration) {
+	if rt == nil {
+		return
+	}
+	rt.Lock()
+	r, ok := rt.runs[uuid]
+	if ok {
+		r.Heartbeat = timeout
+	}
+	rt.Unlock()
+
+	rt.persist()
+}
+
+// RecordHeartbeat notes that a running run sent a heartbeat at the supplied time and pushes its deadline back to the
+// heartbeat timeout of its check from then.  The deadline is never moved earlier.  When the check sets a maximum
+// extension, heartbeats count towards it like extensions requested by the checker pod.  Returns the deadline of the
+// run.
+func (rt *RunTracker) RecordHeartbeat(uuid string, now time.Time) (time.Time, error) {
+	if rt == nil {
+		return time.Time{}, ErrRunNotRunning
+	}
+	rt.Lock()
+	r, ok := rt.runs[uuid]
+	if !ok || r.State != RunRunning || r.Ended || r.IsLate(now) {
+		rt.Unlock()
+		return time.Time{}, ErrRunNotRunning
+	}
+	if r.Heartbeat <= 0 {
+		rt.Unlock()
+		return r.Deadline, ErrHeartbeatsNotExpected
+	}
+	extension := now.Add(r.Heartbeat).Sub(r.Deadline)
+	if extension <= 0 {
+		deadline := r.Deadline
+		rt.Unlock()
+		return deadline, nil
+	}
+	if r.MaxExtension > 0 {
+		if remaining := r.MaxExtension - r.Extended; extension > remaining {
+			extension = remaining
+		}
+		if extension <= 0 {
+			deadline := r.Deadline
+			rt.Unlock()
+			return deadline, ErrExtensionExhausted
+		}
+	}
+	r.Deadline = r.Deadline.Add(extension)
+	r.Extended += extension
+	deadline := r.Deadline
+	rt.Unlock()
+
+	rt.persist()
+	return deadline, nil
+}
+
+// MinProgressInterval is the shortest time allowed between progress reports of a run
+const MinProgressInterval = time.Second * 5
+
+// ErrProgressTooSoon is returned when a run reports progress again before MinProgressInterval has passed
+var ErrProgressTooSoon = errors.New("progress was reported too recently")
+
+// ErrRunNotRunning is returned when progress is reported for a run that is no longer running
+var ErrRunNotRunning = errors.New("run is no longer running")
+
+// RecordProgress notes that a run reported progress at the supplied time.  Progress can only be reported by runs that
+// are still running, and no more than once every MinProgressInterval.  Returns how long to wait before reporting
+// progress again when it was reported too soon.
+func (rt *RunTracker) RecordProgress(uuid string, now time.Time) (time.Duration, error) {
+	if rt == nil {
+		return 0, ErrRunNotRunning
+	}
+	rt.Lock()
+	defer rt.Unlock()
+	r, ok := rt.runs[uuid]
+	if !ok || r.State != RunRunning || r.Ended || r.IsLate(now) {
+		return 0, ErrRunNotRunning
+	}
+	if wait := r.Progressed.Add(MinProgressInterval).Sub(now); !r.Progressed.IsZero() && wait > 0 {
+		return wait, ErrProgressTooSoon
+	}
+	r.Progressed = now
+	return 0, nil
+}
+
+// ErrUnexpectedTarget is returned when a node or zone reports for a fanned out run that did not spawn a checker pod
+// on it
+var ErrUnexpectedTarget = errors.New("run did not spawn a checker pod on the node or zone")
+
+// ExpectTargets records the nodes or zones a fanned out run spawned checker pods on along with how their results are
+// combined.  The run is only reported once every one of them has reported.
+func (rt *RunTracker) ExpectTargets(uuid string, fanOut FanOut, targets []string, aggregation khcheckv1.Aggregation) {
+	if rt == nil {
+		return
+	}
+	rt.Lock()
+	r, ok := rt.runs[uuid]
+	if ok {
+		r.FanOut = fanOut
+		r.Targets = append([]string{}, targets...)
+		r.Aggregation = aggregation
+		r.TargetReports = make(map[string]status.Report)
+	}
+	rt.Unlock()
+
+	rt.persist()
+}
+
+// ReportTarget records the report of one node or zone of a fanned out run.  A target that reports again replaces its
+// earlier report.  Returns a copy of the run along with whether every target of the run has now reported.
+func (rt *RunTracker) ReportTarget(uuid string, target string, report status.Report) (Run, bool, error) {
+	if rt == nil {
+		return Run{}, false, ErrUnexpectedTarget
+	}
+	rt.Lock()
+	defer rt.Unlock()
+
+	r, ok := rt.runs[uuid]
+	if !ok {
+		return Run{}, false, ErrUnexpectedTarget
+	}
+	expected := false
+	for _, t := range r.Targets {
+		expected = expected || t == target
+	}
+	if !expected {
+		return Run{}, false, ErrUnexpectedTarget
+	}
+	r.TargetReports[target] = report
+
+	run := r.copy()
+	return run, len(run.TargetReports) == len(run.Targets), nil
+}
+
+// Freeze stops runs from being canceled, ended or expired, so that the runs in flight stay persisted as they are for
+// the next master to adopt along with their checker pods.  This is used when Kuberhealthy shuts down and leaves its
+// runs behind instead of stopping them.
+func (rt *RunTracker) Freeze() {
+	if rt == nil {
+		return
+	}
+	rt.Lock()
+	defer rt.Unlock()
+	rt.frozen = true
+}
+
+// Frozen indicates that the runs are being left for the next master to adopt
+func (rt *RunTracker) Frozen() bool {
+	if rt == nil {
+		return false
+	}
+	rt.Lock()
+	defer rt.Unlock()
+	return rt.frozen
+}
+
+// Cancel records that Kuberhealthy no longer wants the result of a running run so that its checker pod can learn
+// to stop and clean up after itself
+func (rt *RunTracker) Cancel(uuid string, reason string) {
+	if rt == nil {
+		return
+	}
+	rt.Lock()
+	r, ok := rt.runs[uuid]
+	if !ok || r.Ended || r.State != RunRunning || rt.frozen {
+		rt.Unlock()
+		return
+	}
+	r.Canceled = true
+	r.CancelReason = reason
+	rt.Unlock()
+
+	rt.persist()
+}
+
+// End records that the checker stopped waiting on a run, whatever its outcome.  Ended runs are no longer persisted.
+func (rt *RunTracker) End(uuid string) {
+	if rt == nil {
+		return
+	}
+	rt.Lock()
+	r, ok := rt.runs[uuid]
+	if !ok || r.Ended || rt.frozen {
+		rt.Unlock()
+		return
+	}
+	r.Ended = true
+	rt.Unlock()
+
+	rt.persist()
+}
+
+// Expire marks a run as timed out.  Any report received for it afterwards is late.  Runs that already reported
+// are left alone.
+func (rt *RunTracker) Expire(uuid string) {
+	if rt == nil {
+		return
+	}
+	rt.Lock()
+	defer rt.Unlock()
+
+	r, ok := rt.runs[uuid]
+	if !ok || r.State == RunReported || rt.frozen {
+		return
+	}
+	r.State = RunExpired
+}
+
+// MarkReported records the report a run sent in on time along with the ID of the request that delivered it
+func (rt *RunTracker) MarkReported(uuid string, report status.Report, requestID string) {
+	rt.setReport(uuid, RunReported, report, requestID)
+}
+
+// MarkLate records the report a run sent in after it was timed out along with the ID of the request that delivered it
+func (rt *RunTracker) MarkLate(uuid string, report status.Report, requestID string) {
+	rt.setReport(uuid, RunExpired, report, requestID)
+}
+
+// setReport sets the state and report of a known run.  Unknown runs are ignored.
+func (rt *RunTracker) setReport(uuid string, state RunState, report status.Report, requestID string) {
+	if rt == nil {
+		return
+	}
+	rt.Lock()
+	defer rt.Unlock()
+
+	r, ok := rt.runs[uuid]
+	if !ok {
+		return
+	}
+	r.State = state
+	r.Report = &report
+	r.RequestID = requestID
+}
+
+// IsDuplicate indicates that the supplied report has already been recorded for the run.  Clients that retry a
+// report after losing the response will send the same report twice.
+func (rt *RunTracker) IsDuplicate(uuid string, report status.Report) bool {
+	run, ok := rt.Get(uuid)
+	if !ok || run.Report == nil {
+		return false
+	}
+	return run.Report.Equal(report)
+}
+
+// Get returns a copy of the run with the supplied UUID and whether it was found
+func (rt *RunTracker) Get(uuid string) (Run, bool) {
+	if rt == nil {
+		return Run{}, false
+	}
+	rt.Lock()
+	defer rt.Unlock()
+
+	r, ok := rt.runs[uuid]
+	if !ok {
+		return Run{}, false
+	}
+	return r.copy(), true
+}
+
+// copy returns a copy of the run that shares none of its target reports
+func (r *Run) copy() Run {
+	run := *r
+	if r.TargetReports != nil {
+		run.TargetReports = make(map[string]status.Report, len(r.TargetReports))
+		for target, report := range r.TargetReports {
+			run.TargetReports[target] = report
+		}
+	}
+	return run
+}
+
+// InFlight returns the runs that are still running and have not been ended, sorted by UUID
+func (rt *RunTracker) InFlight() []Run {
+	if rt == nil {
+		return nil
+	}
+	rt.Lock()
+	defer rt.Unlock()
+
+	runs := []Run{}
+	for _, r := range rt.runs {
+		if r.State == RunRunning && !r.Ended {
+			runs = append(runs, *r)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].UUID < runs[j].UUID
+	})
+	return runs
+}
+
+// persist saves the in flight runs to the run store.  Failures are logged because the runs are still tracked in
+// memory.
+func (rt *RunTracker) persist() {
+	if rt == nil {
+		return
+	}
+	rt.saveMu.Lock()
+	defer rt.saveMu.Unlock()
+
+	rt.Lock()
+	store := rt.store
+	rt.Unlock()
+	if store == nil {
+		return
+	}
+
+	err := store.Save(rt.InFlight())
+	if err != nil {
+		log.Errorln("failed to persist in flight runs:", err)
+	}
+}
+
+// prune removes runs whose deadline passed longer ago than the retention period.  Must be called with the lock held.
+func (rt *RunTracker) prune(now time.Time) {
+	for uuid, r := range rt.runs {
+		if now.Sub(r.Deadline) > rt.retention {
+			delete(rt.runs, uuid)
+		}
+	}
+}
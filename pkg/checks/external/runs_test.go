@@ -0,0 +1,338 @@
+package external
+
+import (
+	"testing"
+	"time"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/checks/external/status"
+)
+
+// TestRunTrackerLateReports ensures that runs are seen as late once they expire or pass their deadline
+func TestRunTrackerLateReports(t *testing.T) {
+
+	rt := NewRunTracker()
+	now := time.Now()
+
+	rt.Start("on-time", "check", "kuberhealthy", "check-1", now.Add(time.Minute))
+	rt.Start("expired", "check", "kuberhealthy", "check-1", now.Add(time.Minute))
+	rt.Start("past-deadline", "check", "kuberhealthy", "check-1", now.Add(-time.Second))
+	rt.Expire("expired")
+
+	var testCases = []struct {
+		uuid  string
+		known bool
+		late  bool
+	}{
+		{"on-time", true, false},
+		{"expired", true, true},
+		{"past-deadline", true, true},
+		{"unknown", false, false},
+	}
+
+	for _, tc := range testCases {
+		run, known := rt.Get(tc.uuid)
+		if known != tc.known {
+			t.Fatalf("run %s known was %t but expected %t", tc.uuid, known, tc.known)
+		}
+		if !known {
+			continue
+		}
+		if run.IsLate(now) != tc.late {
+			t.Fatalf("run %s late was %t but expected %t", tc.uuid, run.IsLate(now), tc.late)
+		}
+	}
+}
+
+// TestRunTrackerPrune ensures that runs are forgotten after the retention period
+func TestRunTrackerPrune(t *testing.T) {
+
+	rt := NewRunTracker()
+	rt.Start("old", "check", "kuberhealthy", "check-1", time.Now().Add(-rt.retention-time.Minute))
+	rt.Start("new", "check", "kuberhealthy", "check-1", time.Now().Add(time.Minute))
+
+	if _, known := rt.Get("old"); known {
+		t.Fatal("run older than the retention period was not pruned")
+	}
+	if _, known := rt.Get("new"); !known {
+		t.Fatal("run within the retention period was pruned")
+	}
+}
+
+// TestRunTrackerNil ensures that a nil RunTracker can be used safely by checkers that are not tracking runs
+func TestRunTrackerNil(t *testing.T) {
+	var rt *RunTracker
+	rt.Start("uuid", "check", "kuberhealthy", "check-1", time.Now())
+	rt.Expire("uuid")
+	rt.MarkReported("uuid", status.NewReport([]string{}), "request")
+	if _, known := rt.Get("uuid"); known {
+		t.Fatal("nil run tracker returned a run")
+	}
+}
+
+// TestRunTrackerDuplicateReports ensures that a report is only seen as a duplicate when the same result was already
+// recorded for the run
+func TestRunTrackerDuplicateReports(t *testing.T) {
+
+	rt := NewRunTracker()
+	rt.Start("uuid", "check", "kuberhealthy", "check-1", time.Now().Add(time.Minute))
+
+	failure := status.NewReport([]string{"something broke"})
+	if rt.IsDuplicate("uuid", failure) {
+		t.Fatal("report was a duplicate before any report was recorded")
+	}
+
+	rt.MarkReported("uuid", failure, "request")
+	var testCases = []struct {
+		report    status.Report
+		duplicate bool
+	}{
+		{status.NewReport([]string{"something broke"}), true},
+		{status.NewReport([]string{"something else broke"}), false},
+		{status.NewReport([]string{}), false},
+	}
+
+	for _, tc := range testCases {
+		if rt.IsDuplicate("uuid", tc.report) != tc.duplicate {
+			t.Fatalf("report %+v duplicate was %t but expected %t", tc.report, !tc.duplicate, tc.duplicate)
+		}
+	}
+
+	// a run that already reported should not be expired by a late timeout
+	rt.Expire("uuid")
+	run, _ := rt.Get("uuid")
+	runStatus := run.Status()
+	if !runStatus.Accepted || runStatus.Late || runStatus.State != string(RunReported) {
+		t.Fatalf("reported run was changed by expiry: %+v", runStatus)
+	}
+}
+
+// memoryRunStore is a RunStore that keeps the last saved runs in memory
+type memoryRunStore struct {
+	runs []Run
+}
+
+func (m *memoryRunStore) Load() ([]Run, error) {
+	return m.runs, nil
+}
+
+func (m *memoryRunStore) Save(runs []Run) error {
+	m.runs = runs
+	return nil
+}
+
+// TestRunTrackerPersistence ensures in flight runs are persisted and can be resumed once by a new tracker
+func TestRunTrackerPersistence(t *testing.T) {
+	store := &memoryRunStore{}
+	now := time.Now()
+
+	rt := NewRunTracker()
+	rt.SetStore(store)
+	rt.Start("in-flight", "check", "kuberhealthy", "check-1", now.Add(time.Minute))
+	rt.Start("finished", "other", "kuberhealthy", "other-1", now.Add(time.Minute))
+	rt.Start("stale", "stale", "kuberhealthy", "stale-1", now.Add(-time.Second))
+	rt.End("finished")
+	if len(store.runs) != 2 {
+		t.Fatalf("expected 2 persisted runs but found %d: %+v", len(store.runs), store.runs)
+	}
+
+	// a new tracker, as after a restart, resumes the runs still within their deadline
+	restarted := NewRunTracker()
+	restarted.SetStore(store)
+	err := restarted.Restore()
+	if err != nil {
+		t.Fatalf("failed to restore runs: %s", err)
+	}
+
+	var testCases = []struct {
+		check   string
+		uuid    string
+		resumed bool
+	}{
+		{"check", "in-flight", true},
+		{"check", "", false},
+		{"other", "", false},
+		{"stale", "", false},
+	}
+	for _, tc := range testCases {
+		run, ok := restarted.Resume(tc.check, "kuberhealthy", now)
+		if ok != tc.resumed || run.UUID != tc.uuid {
+			t.Fatalf("resuming %s returned %q %t but expected %q %t", tc.check, run.UUID, ok, tc.uuid, tc.resumed)
+		}
+	}
+	if run, _ := restarted.Get("in-flight"); run.PodName != "check-1" {
+		t.Fatalf("resumed run lost its pod name: %+v", run)
+	}
+
+	restarted.End("in-flight")
+	if len(store.runs) != 0 {
+		t.Fatalf("expected no persisted runs once all runs ended but found %+v", store.runs)
+	}
+}
+
+// TestRunTrackerExtend ensures deadline extensions are refused when not allowed and clamped to the allowed maximum
+func TestRunTrackerExtend(t *testing.T) {
+	rt := NewRunTracker()
+	now := time.Now()
+	deadline := now.Add(time.Minute)
+
+	rt.Start("not-allowed", "check", "kuberhealthy", "check-1", deadline)
+	rt.Start("allowed", "check", "kuberhealthy", "check-1", deadline)
+	rt.AllowExtension("allowed", 3*time.Minute)
+
+	var testCases = []struct {
+		uuid     string
+		request  time.Duration
+		granted  time.Duration
+		deadline time.Time
+		err      error
+	}{
+		{"not-allowed", time.Minute, 0, deadline, ErrExtensionNotAllowed},
+		{"unknown", time.Minute, 0, time.Time{}, ErrRunNotExtendable},
+		{"allowed", 2 * time.Minute, 2 * time.Minute, deadline.Add(2 * time.Minute), nil},
+		{"allowed", 2 * time.Minute, time.Minute, deadline.Add(3 * time.Minute), nil},
+		{"allowed", time.Minute, 0, deadline.Add(3 * time.Minute), ErrExtensionExhausted},
+	}
+
+	for _, tc := range testCases {
+		newDeadline, granted, err := rt.Extend(tc.uuid, tc.request, now)
+		if err != tc.err {
+			t.Fatalf("extending run %s returned error %v but expected %v", tc.uuid, err, tc.err)
+		}
+		if granted != tc.granted || !newDeadline.Equal(tc.deadline) {
+			t.Fatalf("extending run %s granted %s until %s but expected %s until %s", tc.uuid, granted, newDeadline, tc.granted, tc.deadline)
+		}
+	}
+
+	run, _ := rt.Get("allowed")
+	if !run.Deadline.Equal(deadline.Add(3*time.Minute)) || run.Extended != 3*time.Minute {
+		t.Fatalf("run deadline was %s extended by %s but expected %s extended by 3m", run.Deadline, run.Extended, deadline.Add(3*time.Minute))
+	}
+
+	rt.Expire("allowed")
+	_, _, err := rt.Extend("allowed", time.Minute, now)
+	if err != ErrRunNotExtendable {
+		t.Fatalf("extending an expired run returned error %v but expected %v", err, ErrRunNotExtendable)
+	}
+}
+
+// TestRunTrackerRecordProgress ensures progress is only accepted from running runs and no more often than allowed
+func TestRunTrackerRecordProgress(t *testing.T) {
+	rt := NewRunTracker()
+	now := time.Now()
+	rt.Start("running", "check", "kuberhealthy", "check-1", now.Add(time.Minute))
+
+	var testCases = []struct {
+		uuid string
+		at   time.Time
+		wait time.Duration
+		err  error
+	}{
+		{"unknown", now, 0, ErrRunNotRunning},
+		{"running", now, 0, nil},
+		{"running", now.Add(time.Second * 2), time.Second * 3, ErrProgressTooSoon},
+		{"running", now.Add(MinProgressInterval), 0, nil},
+		{"running", now.Add(time.Minute * 2), 0, ErrRunNotRunning},
+	}
+	for _, tc := range testCases {
+		wait, err := rt.RecordProgress(tc.uuid, tc.at)
+		if err != tc.err || wait != tc.wait {
+			t.Fatalf("progress of run %s at %s returned %s %v but expected %s %v", tc.uuid, tc.at, wait, err, tc.wait, tc.err)
+		}
+	}
+
+	rt.End("running")
+	_, err := rt.RecordProgress("running", now.Add(time.Minute/2))
+	if err != ErrRunNotRunning {
+		t.Fatalf("progress of an ended run returned error %v but expected %v", err, ErrRunNotRunning)
+	}
+}
+
+// TestRunTrackerCancel ensures canceled and timed out runs tell their checker pod to stop and are not resumed
+func TestRunTrackerCancel(t *testing.T) {
+	rt := NewRunTracker()
+	now := time.Now()
+
+	rt.Start("canceled", "check", "kuberhealthy", "check-1", now.Add(time.Minute))
+	rt.Start("running", "check", "kuberhealthy", "check-1", now.Add(time.Minute))
+	rt.Start("timed-out", "check", "kuberhealthy", "check-1", now.Add(time.Minute))
+	rt.Start("reported", "check", "kuberhealthy", "check-1", now.Add(time.Minute))
+	rt.Cancel("canceled", "replaced by a newer run")
+	rt.Expire("timed-out")
+	rt.MarkReported("reported", status.Report{OK: true}, "")
+	rt.Cancel("reported", "the check was stopped")
+
+	var testCases = []struct {
+		uuid     string
+		canceled bool
+		reason   string
+	}{
+		{"canceled", true, "replaced by a newer run"},
+		{"running", false, ""},
+		{"timed-out", true, "the run timed out"},
+		{"reported", false, ""},
+	}
+
+	for _, tc := range testCases {
+		run, _ := rt.Get(tc.uuid)
+		c := run.Cancellation(now)
+		if c.Canceled != tc.canceled || c.Reason != tc.reason {
+			t.Fatalf("cancellation of run %s was %+v but expected canceled %t with reason `%s`", tc.uuid, c, tc.canceled, tc.reason)
+		}
+	}
+}
+
+// TestRunTrackerFreeze ensures that frozen runs stay in flight and persisted for the next master to adopt
+func TestRunTrackerFreeze(t *testing.T) {
+	store := &memoryRunStore{}
+	rt := NewRunTracker()
+	rt.SetStore(store)
+	rt.Start("in-flight", "check", "kuberhealthy", "check-1", time.Now().Add(time.Minute))
+
+	rt.Freeze()
+	if !rt.Frozen() {
+		t.Fatal("expected the run tracker to be frozen")
+	}
+	rt.Cancel("in-flight", "the check was stopped")
+	rt.Expire("in-flight")
+	rt.End("in-flight")
+
+	run, _ := rt.Get("in-flight")
+	if run.Canceled || run.Ended || run.State != RunRunning {
+		t.Fatalf("expected the frozen run to be left running but it was %+v", run)
+	}
+	if len(store.runs) != 1 || store.runs[0].UUID != "in-flight" {
+		t.Fatalf("expected the frozen run to stay persisted but found %+v", store.runs)
+	}
+}
+
+// TestRunTrackerReportTarget ensures fanned out runs are only complete once every target has reported
+func TestRunTrackerReportTarget(t *testing.T) {
+	rt := NewRunTracker()
+	rt.Start("run", "check", "kuberhealthy", "", time.Now().Add(time.Minute))
+	rt.ExpectTargets("run", FanOutNodes, []string{"node-a", "node-b"}, khcheckv1.Aggregation{})
+
+	_, _, err := rt.ReportTarget("run", "node-c", status.Report{OK: true})
+	if err != ErrUnexpectedTarget {
+		t.Fatalf("report from a node the run did not spawn a pod on returned %v but expected %v", err, ErrUnexpectedTarget)
+	}
+
+	run, complete, err := rt.ReportTarget("run", "node-a", status.Report{OK: true})
+	if err != nil || complete || len(run.TargetReports) != 1 {
+		t.Fatalf("first node report returned %d reports, complete %t and error %v", len(run.TargetReports), complete, err)
+	}
+
+	// targets that report again replace their earlier report
+	_, complete, _ = rt.ReportTarget("run", "node-a", status.Report{Errors: []string{"dns failed"}})
+	if complete {
+		t.Fatalf("run was complete before every node reported")
+	}
+
+	run, complete, err = rt.ReportTarget("run", "node-b", status.Report{OK: true})
+	if err != nil || !complete {
+		t.Fatalf("last node report returned complete %t and error %v", complete, err)
+	}
+	if run.TargetReports["node-a"].OK || run.FanOut != FanOutNodes {
+		t.Fatalf("node report was not replaced by the node reporting again")
+	}
+}
@@ -0,0 +1,52 @@
+package external
+
+import (
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// Sidecar handling modes control how checker pods with service mesh sidecars (such as istio or linkerd proxies) are
+// run.  Sidecars keep running after the checker container exits, so without special handling the checker pod never
+// completes and the run appears hung until it times out.
+//
+// The exclude mode annotates checker pods so that service meshes do not inject sidecars at all.  The quit mode tells
+// the checker client to shut down known sidecars after reporting and treats the pod as done once the checker
+// containers have exited.  The tolerate mode only treats the pod as done once the checker containers have exited.
+const (
+	SidecarHandlingExclude  = "exclude"
+	SidecarHandlingQuit     = "quit"
+	SidecarHandlingTolerate = "tolerate"
+)
+
+// KHSidecarQuit is the environment variable used to tell external checks to shut down their sidecars after reporting
+const KHSidecarQuit = "KH_SIDECAR_QUIT"
+
+// sidecarExclusionAnnotations are the pod annotations that prevent service meshes from injecting sidecars
+var sidecarExclusionAnnotations = map[string]string{
+	"sidecar.istio.io/inject": "false",
+	"linkerd.io/inject":       "disabled",
+}
+
+// checkerContainersExited determines if every container from the check's pod spec has terminated.  Containers that
+// are not in the check's pod spec were injected into the pod, usually as sidecars, and are ignored.
+func checkerContainersExited(pod apiv1.Pod, podSpec apiv1.PodSpec) bool {
+	if len(podSpec.Containers) == 0 {
+		return false
+	}
+
+	terminated := make(map[string]bool)
+	for _, cs := range pod.Status.ContainerStatuses {
+		terminated[cs.Name] = cs.State.Terminated != nil
+	}
+
+	for _, c := range podSpec.Containers {
+		if !terminated[c.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// toleratesSidecars indicates that a checker pod is considered done once its checker containers exit
+func (ext *Checker) toleratesSidecars() bool {
+	return ext.SidecarHandling == SidecarHandlingQuit || ext.SidecarHandling == SidecarHandlingTolerate
+}
@@ -0,0 +1,38 @@
+package external
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// TestCheckerContainersExited ensures that injected sidecar containers are ignored when deciding if a checker pod
+// is done
+func TestCheckerContainersExited(t *testing.T) {
+
+	podSpec := apiv1.PodSpec{Containers: []apiv1.Container{{Name: "check"}}}
+	running := apiv1.ContainerState{Running: &apiv1.ContainerStateRunning{}}
+	terminated := apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{}}
+
+	var testCases = []struct {
+		description string
+		statuses    []apiv1.ContainerStatus
+		podSpec     apiv1.PodSpec
+		exited      bool
+	}{
+		{"check running", []apiv1.ContainerStatus{{Name: "check", State: running}, {Name: "istio-proxy", State: running}}, podSpec, false},
+		{"check exited with sidecar running", []apiv1.ContainerStatus{{Name: "check", State: terminated}, {Name: "istio-proxy", State: running}}, podSpec, true},
+		{"check exited without sidecar", []apiv1.ContainerStatus{{Name: "check", State: terminated}}, podSpec, true},
+		{"no container statuses yet", []apiv1.ContainerStatus{}, podSpec, false},
+		{"empty pod spec", []apiv1.ContainerStatus{{Name: "check", State: terminated}}, apiv1.PodSpec{}, false},
+	}
+
+	for _, tc := range testCases {
+		pod := apiv1.Pod{}
+		pod.Status.ContainerStatuses = tc.statuses
+		if checkerContainersExited(pod, tc.podSpec) != tc.exited {
+			t.Fatalf("%s: checkerContainersExited resulted in %t but expected %t", tc.description, !tc.exited, tc.exited)
+		}
+		t.Logf("%s: checkerContainersExited resulted in %t correctly", tc.description, tc.exited)
+	}
+}
@@ -0,0 +1,44 @@
+package external
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// KHReportSigningKey is the environment variable that gives checker pods the key to sign their reports with.  It is
+// only set when Kuberhealthy is configured with a report signing key.
+const KHReportSigningKey = "KH_REPORT_SIGNING_KEY"
+
+// KHSignatureHeader is the HTTP header that carries the signature of a report body
+const KHSignatureHeader = "X-Kuberhealthy-Signature"
+
+// signaturePrefix names the algorithm of a report signature
+const signaturePrefix = "sha256="
+
+// RunSigningKey derives the key a run signs its reports with from the report signing key of Kuberhealthy.  Every run
+// gets its own key, so that a key leaked from one run can't be used to spoof the results of another.  Every
+// Kuberhealthy replica derives the same key for a run without having to share any state.
+func RunSigningKey(key string, uuid string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(uuid))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignReport returns the signature of a report body for the KHSignatureHeader header.  The body is signed with
+// HMAC-SHA256 using the signing key of the run.
+func SignReport(runKey string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(runKey))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyReportSignature checks that a signature from the KHSignatureHeader header was made over the body with the
+// signing key of the run
+func VerifyReportSignature(runKey string, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	return hmac.Equal([]byte(SignReport(runKey, body)), []byte(signature))
+}
@@ -0,0 +1,73 @@
+package external
+
+import (
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// TestReportSignatures ensures reports verify only against the key of their own run and their exact body
+func TestReportSignatures(t *testing.T) {
+	key := "0d1f9b4c6a2e8f7d3b5a9c1e4f6d8b2a"
+	runKey := RunSigningKey(key, "run-1")
+	if runKey != RunSigningKey(key, "run-1") {
+		t.Fatal("expected the same run to always get the same key")
+	}
+	if runKey == RunSigningKey(key, "run-2") || runKey == RunSigningKey("another key", "run-1") {
+		t.Fatal("expected each run and report signing key to get its own run key")
+	}
+
+	body := []byte(`{"OK":true,"Errors":[]}`)
+	signature := SignReport(runKey, body)
+
+	var testCases = []struct {
+		description string
+		runKey      string
+		body        []byte
+		signature   string
+		valid       bool
+	}{
+		{"signed by the run", runKey, body, signature, true},
+		{"signed by another run", RunSigningKey(key, "run-2"), body, signature, false},
+		{"changed body", runKey, []byte(`{"OK":false,"Errors":[]}`), signature, false},
+		{"unsigned", runKey, body, "", false},
+		{"missing algorithm", runKey, body, signature[len("sha256="):], false},
+	}
+	for _, tc := range testCases {
+		if VerifyReportSignature(tc.runKey, tc.body, tc.signature) != tc.valid {
+			t.Fatalf("%s: expected the signature to be valid: %t", tc.description, tc.valid)
+		}
+	}
+}
+
+// TestConfigureUserPodSpecSigningKey ensures checker pods are only given the signing key of their run when a report
+// signing key is configured, and that a signing key set in the check's pod spec is never used
+func TestConfigureUserPodSpecSigningKey(t *testing.T) {
+	original := apiv1.PodSpec{Containers: []apiv1.Container{{
+		Name:  "check",
+		Image: "kuberhealthy/test-check",
+		Env:   []apiv1.EnvVar{{Name: KHReportSigningKey, Value: "chosen by the check"}},
+	}}}
+
+	for _, key := range []string{"", "report signing key"} {
+		ext := &Checker{Namespace: "kuberhealthy", OriginalPodSpec: original, PodSpec: original, ReportSigningKey: key, currentCheckUUID: "run-1"}
+		err := ext.configureUserPodSpec(time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatalf("failed to configure pod spec: %s", err)
+		}
+
+		var found []string
+		for _, e := range ext.PodSpec.Containers[0].Env {
+			if e.Name == KHReportSigningKey {
+				found = append(found, e.Value)
+			}
+		}
+		if len(key) == 0 && len(found) != 0 {
+			t.Fatalf("expected no signing key without a report signing key, got %v", found)
+		}
+		if len(key) > 0 && (len(found) != 1 || found[0] != RunSigningKey(key, "run-1")) {
+			t.Fatalf("expected only the signing key of the run, got %v", found)
+		}
+	}
+}
@@ -5,6 +5,7 @@
 package ssl_util
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
@@ -16,6 +17,8 @@ import (
 	"time"
 
 	log "github.com/sirupsen/logrus"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/probe"
 )
 
 var TimeoutSeconds = 10
@@ -126,35 +129,34 @@ func CreatePool() (*x509.CertPool, error) {
 // SSLHandshakeWithCertPool does an SSL handshake with the specified cert pool instead of
 // the default system certificate pool
 func SSLHandshakeWithCertPool(url *url.URL, certPool *x509.CertPool) error {
+	_, err := TimedSSLHandshakeWithCertPool(url, certPool)
+	return err
+}
+
+// TimedSSLHandshakeWithCertPool does an SSL handshake with the specified cert pool and returns how long resolving,
+// connecting and the handshake took
+func TimedSSLHandshakeWithCertPool(url *url.URL, certPool *x509.CertPool) (probe.Timing, error) {
 
 	// ensure an https url was passed
 	if url.Scheme != "https" {
-		return fmt.Errorf("error doing SSL handshake.  The url specified %s was not an https URL", url.String())
+		return probe.Timing{}, fmt.Errorf("error doing SSL handshake.  The url specified %s was not an https URL", url.String())
 	}
 
-	// create our dialer
-	d := &net.Dialer{
-		Timeout: time.Duration(TimeoutSeconds) * time.Second,
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(TimeoutSeconds)*time.Second)
+	defer cancel()
 
-	// dial to the TCP endpoint
-	conn, err := tls.DialWithDialer(d, "tcp", url.Hostname()+":"+url.Port(), &tls.Config{
+	// dial to the TCP endpoint and do the SSL handshake
+	conn, timing, err := probe.Dial(ctx, "tcp", net.JoinHostPort(url.Hostname(), url.Port()), &tls.Config{
 		InsecureSkipVerify: false,
 		MinVersion:         tls.VersionTLS12,
 		RootCAs:            certPool,
 	})
 	if err != nil {
-		return fmt.Errorf("error making connection to perform TLS handshake: %w", err)
+		return timing, fmt.Errorf("unable to perform TLS handshake: %w", err)
 	}
 	defer conn.Close()
 
-	// do the SSL handshake
-	err = conn.Handshake()
-	if err != nil {
-		return fmt.Errorf("unable to perform TLS handshake: %w", err)
-	}
-
-	return nil
+	return timing, nil
 }
 
 // SSLHandshake does an https handshake and returns any errors encountered
@@ -180,7 +182,7 @@ func CertExpiry(host, port, days string, overrideTLS bool) (bool, bool, error) {
 	}
 
 	// InsecureSkipVerify should be false unless checking a self-signed certificate
-	conn, err := tls.DialWithDialer(d, "tcp", host+":"+port, &tls.Config{
+	conn, err := tls.DialWithDialer(d, "tcp", net.JoinHostPort(host, port), &tls.Config{
 		InsecureSkipVerify: overrideTLS,
 		MinVersion:         tls.VersionTLS12,
 	})
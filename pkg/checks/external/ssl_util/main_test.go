@@ -1,6 +1,10 @@
 package ssl_util
 
 import (
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 )
@@ -23,3 +27,31 @@ func TestCertPull(t *testing.T) {
 	}
 
 }
+
+// TestSSLHandshakeIPv6 ensures that handshakes work against IPv6 addresses
+func TestSSLHandshakeIPv6(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 is not available:", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Listener.Close()
+	server.Listener = listener
+	server.StartTLS()
+	defer server.Close()
+
+	certPool := x509.NewCertPool()
+	certPool.AddCert(server.Certificate())
+
+	testURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("Testing handshake with", testURL)
+
+	err = SSLHandshakeWithCertPool(testURL, certPool)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
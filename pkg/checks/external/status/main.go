@@ -3,10 +3,143 @@
 // status reporting endpoint.
 package status
 
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // Report is the format expected by the /externalCheckStatus endpoint
 type Report struct {
-	Errors []string
-	OK     bool
+	Errors       []string
+	OK           bool
+	Metadata     map[string]string  // optional details about the run, such as counts of what was checked
+	ErrorDetails []CheckError       // optional structured details of the errors, in the same order as Errors
+	Metrics      map[string]float64 // optional measurements taken by the run, such as latencies or object counts
+	Artifacts    []Artifact         // optional log excerpts and diagnostic output that explain a failure
+	Warnings     []string           // optional signs of degradation that do not fail the run, such as a certificate that expires soon
+	SubChecks    []SubCheck         // optional named results of the parts of the run, such as each endpoint a DNS check resolved
+
+	// ReportVersion is the version of the report schema the report was written for.  It is 0 in reports from clients
+	// that predate versioned reports.
+	ReportVersion int
+}
+
+// MaxMetrics is the most metrics a single report can carry
+const MaxMetrics = 50
+
+// metricNamePattern is the form metric names must take.  They are used as Prometheus label values and must be
+// readable as identifiers by the tooling consuming them.
+var metricNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ValidateMetrics ensures that the metrics of a report have valid names, finite values and are not too many
+func ValidateMetrics(metrics map[string]float64) error {
+	if len(metrics) > MaxMetrics {
+		return fmt.Errorf("report has %d metrics but at most %d are allowed", len(metrics), MaxMetrics)
+	}
+	for name, value := range metrics {
+		if !metricNamePattern.MatchString(name) {
+			return fmt.Errorf("metric name %q must start with a letter or underscore and contain only letters, digits and underscores", name)
+		}
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Errorf("metric %s has value %v, which is not a finite number", name, value)
+		}
+	}
+	return nil
+}
+
+// MaxArtifacts is the most artifacts a single report can carry
+const MaxArtifacts = 10
+
+// MaxArtifactBytes is the most content a single artifact can carry.  Longer content is cut down to its last
+// MaxArtifactBytes bytes, which is where the lines explaining a failure usually are.
+const MaxArtifactBytes = 8 * 1024
+
+// Artifact is a log excerpt or other diagnostic output attached to a failure report, so that the cause of a failure
+// can be seen without running the check again
+type Artifact struct {
+	Name        string // what the artifact is, such as checker.log
+	ContentType string // optional media type of the content, such as text/plain or application/json
+	Content     string
+	Truncated   bool // true when the content was cut down to MaxArtifactBytes
+}
+
+// TruncateArtifact cuts the content of an artifact down to its last MaxArtifactBytes bytes and marks it truncated
+func TruncateArtifact(a Artifact) Artifact {
+	if len(a.Content) <= MaxArtifactBytes {
+		return a
+	}
+	a.Content = a.Content[len(a.Content)-MaxArtifactBytes:]
+	a.Truncated = true
+	return a
+}
+
+// ValidateArtifacts ensures that the artifacts of a report have unique names and are not too many.  Content longer
+// than MaxArtifactBytes is not an error, as it is truncated when the report is stored.
+func ValidateArtifacts(artifacts []Artifact) error {
+	if len(artifacts) > MaxArtifacts {
+		return fmt.Errorf("report has %d artifacts but at most %d are allowed", len(artifacts), MaxArtifacts)
+	}
+	names := make(map[string]bool, len(artifacts))
+	for _, a := range artifacts {
+		if len(a.Name) == 0 {
+			return errors.New("artifact has no name")
+		}
+		if names[a.Name] {
+			return fmt.Errorf("artifact %s appears more than once", a.Name)
+		}
+		names[a.Name] = true
+	}
+	return nil
+}
+
+// Severities of a CheckError.  Errors without a severity are critical.
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// CheckError is a failure found by a check along with details that let tooling act on it
+type CheckError struct {
+	Message  string
+	Severity string            // critical, warning or info
+	Code     string            // optional machine-readable code of the error, such as DNS_TIMEOUT
+	Metadata map[string]string // optional details about the error, such as the host that failed
+}
+
+// Validate ensures the error has a message and a known severity
+func (e CheckError) Validate() error {
+	if len(e.Message) == 0 {
+		return errors.New("check error has no message")
+	}
+	switch e.Severity {
+	case "", SeverityCritical, SeverityWarning, SeverityInfo:
+		return nil
+	}
+	return fmt.Errorf("check error %q has unknown severity %q", e.Message, e.Severity)
+}
+
+// NewDetailedReport creates a failure report to be sent to the server from structured errors.  The messages of the
+// errors are also sent as the plain errors of the report, so the report reads the same to servers and tooling that
+// don't know about error details.  Errors without a severity are marked critical.
+func NewDetailedReport(checkErrors []CheckError) Report {
+	messages := make([]string, 0, len(checkErrors))
+	details := make([]CheckError, 0, len(checkErrors))
+	for _, e := range checkErrors {
+		if len(e.Severity) == 0 {
+			e.Severity = SeverityCritical
+		}
+		messages = append(messages, e.Message)
+		details = append(details, e)
+	}
+	report := NewReport(messages)
+	report.ErrorDetails = details
+	return report
 }
 
 // NewReport creates a new error report to be sent to the server.  If
@@ -24,3 +157,236 @@ func NewReport(errorMessages []string) Report {
 		OK:     ok,
 	}
 }
+
+// NewWarningReport creates a report of a run that passed but found signs of degradation, such as a certificate that
+// expires soon.  Warnings are shown apart from failures and do not make the cluster unhealthy.
+func NewWarningReport(warnings []string) Report {
+	report := NewReport([]string{})
+	report.Warnings = warnings
+	return report
+}
+
+// ValidateWarnings ensures that none of the warnings of a report are blank
+func ValidateWarnings(warnings []string) error {
+	for _, w := range warnings {
+		if len(strings.TrimSpace(w)) == 0 {
+			return errors.New("report has a blank warning")
+		}
+	}
+	return nil
+}
+
+// MaxSubChecks is the most sub-checks a single report can carry
+const MaxSubChecks = 100
+
+// SubCheck is the result of a named part of a check run, such as a single endpoint of a DNS check.  Sub-checks are
+// stored and shown individually under the check that reported them.
+type SubCheck struct {
+	Name   string
+	OK     bool
+	Errors []string // why the sub-check failed
+}
+
+// NewSubCheckReport creates a report from the results of the parts of a run.  The report fails when any of its
+// sub-checks failed, and the errors of failed sub-checks are also sent as the plain errors of the report, prefixed with
+// the name of their sub-check, so the report reads the same to servers and tooling that don't know about sub-checks.
+func NewSubCheckReport(subChecks []SubCheck) Report {
+	var errorMessages []string
+	for _, sc := range subChecks {
+		for _, e := range sc.Errors {
+			errorMessages = append(errorMessages, sc.Name+": "+e)
+		}
+	}
+	report := NewReport(errorMessages)
+	report.SubChecks = subChecks
+	return report
+}
+
+// ValidateSubChecks ensures that the sub-checks of a report have unique names, that failed sub-checks say why they
+// failed and that there are not too many of them
+func ValidateSubChecks(subChecks []SubCheck) error {
+	if len(subChecks) > MaxSubChecks {
+		return fmt.Errorf("report has %d sub-checks but at most %d are allowed", len(subChecks), MaxSubChecks)
+	}
+	names := make(map[string]bool, len(subChecks))
+	for _, sc := range subChecks {
+		if len(strings.TrimSpace(sc.Name)) == 0 {
+			return errors.New("sub-check has no name")
+		}
+		if names[sc.Name] {
+			return fmt.Errorf("sub-check %s appears more than once", sc.Name)
+		}
+		names[sc.Name] = true
+		if !sc.OK && len(sc.Errors) == 0 {
+			return fmt.Errorf("sub-check %s failed without an error", sc.Name)
+		}
+	}
+	return nil
+}
+
+const (
+	// ReportVersion1 is the original report schema, which only carries OK and Errors
+	ReportVersion1 = 1
+
+	// ReportVersion2 adds Metadata, ErrorDetails, Metrics, Artifacts, Warnings and SubChecks to ReportVersion1
+	ReportVersion2 = 2
+
+	// CurrentReportVersion is the version of the reports this package writes
+	CurrentReportVersion = ReportVersion2
+)
+
+// SupportedReportVersions are the report versions the /externalCheckStatus endpoint accepts, oldest first
+var SupportedReportVersions = []int{ReportVersion1, ReportVersion2}
+
+// ValidateReportVersion ensures that a report was written for a supported version of the report schema and only
+// carries the fields of that version.  Reports that don't set a version are accepted as they are.
+func ValidateReportVersion(r Report) error {
+	switch r.ReportVersion {
+	case 0, ReportVersion2:
+		return nil
+	case ReportVersion1:
+		if len(r.Metadata) > 0 || len(r.ErrorDetails) > 0 || len(r.Metrics) > 0 || len(r.Artifacts) > 0 || len(r.Warnings) > 0 || len(r.SubChecks) > 0 {
+			return fmt.Errorf("version %d reports can only carry OK and Errors, but the report carries fields of version %d", ReportVersion1, ReportVersion2)
+		}
+		return nil
+	}
+	return fmt.Errorf("report version %d is not supported, only versions %s are", r.ReportVersion, FormatReportVersions(SupportedReportVersions))
+}
+
+// FormatReportVersions formats report versions as the comma separated list sent in the X-Kuberhealthy-Report-Versions
+// header
+func FormatReportVersions(versions []int) string {
+	formatted := make([]string, 0, len(versions))
+	for _, v := range versions {
+		formatted = append(formatted, strconv.Itoa(v))
+	}
+	return strings.Join(formatted, ",")
+}
+
+// ParseReportVersions reads the comma separated list of report versions sent in the X-Kuberhealthy-Report-Versions
+// header.  Entries that are not versions are skipped.
+func ParseReportVersions(header string) []int {
+	var versions []int
+	for _, field := range strings.Split(header, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(field))
+		if err == nil && v > 0 {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+// Equal indicates that two reports carry the same result.  Metadata is not part of the result.
+func (r Report) Equal(other Report) bool {
+	if r.OK != other.OK || len(r.Errors) != len(other.Errors) || len(r.Warnings) != len(other.Warnings) {
+		return false
+	}
+	for i := range r.Errors {
+		if r.Errors[i] != other.Errors[i] {
+			return false
+		}
+	}
+	for i := range r.Warnings {
+		if r.Warnings[i] != other.Warnings[i] {
+			return false
+		}
+	}
+	if len(r.SubChecks) != len(other.SubChecks) {
+		return false
+	}
+	for i := range r.SubChecks {
+		a, b := r.SubChecks[i], other.SubChecks[i]
+		if a.Name != b.Name || a.OK != b.OK || len(a.Errors) != len(b.Errors) {
+			return false
+		}
+		for j := range a.Errors {
+			if a.Errors[j] != b.Errors[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// RunStatus is returned by the /externalCheckStatus/{uuid} endpoint.  It describes what Kuberhealthy has recorded
+// for a single check run so that checks can verify that their report was delivered.
+type RunStatus struct {
+	UUID      string
+	CheckName string
+	Namespace string
+	State     string   // Running, Reported or Expired
+	Accepted  bool     // true when a report was received and counted towards the check's current state
+	Late      bool     // true when a report was received after the run had already timed out
+	OK        bool     // the result that was reported, if any
+	Errors    []string // the errors that were reported, if any
+	RequestID string   // the request ID of the report, if any
+}
+
+// BulkReport is one report in the batch accepted by the /bulkCheckStatus endpoint
+type BulkReport struct {
+	Check     string // the name of the khcheck or khjob the report is for
+	Namespace string // the namespace of the khcheck or khjob, which defaults to the namespace of the reporting pod
+	UUID      string // the run UUID the report is for
+	Report    Report
+}
+
+// BulkReportResult tells how one report in a batch sent to the /bulkCheckStatus endpoint was handled
+type BulkReportResult struct {
+	Check     string
+	Namespace string
+	UUID      string
+	Code      int    // the status code /externalCheckStatus would have answered the report with
+	Error     string // why the report was refused, if it was
+}
+
+// ResidentRegistration is sent to the /resident/register endpoint by resident checkers and returned once they are
+// registered
+type ResidentRegistration struct {
+	Check     string // the name of the resident khcheck
+	Namespace string // the namespace of the khcheck, which is always the namespace of the resident checker pod
+}
+
+// RunRequest is handed to resident checkers by the /resident/runs endpoint when their check is due to run
+type RunRequest struct {
+	UUID        string // the run UUID to report with
+	Deadline    int64  // the deadline of the run in unixtime
+	SigningKey  string // the key to sign the reports of the run with, blank unless Kuberhealthy requires signed reports
+	TraceParent string // the W3C trace context of the run, to send back with its reports
+}
+
+// ExtensionRequest is the format expected by the /extendDeadline endpoint
+type ExtensionRequest struct {
+	Seconds int64 // how much longer the run needs
+}
+
+// ProgressReport is the format expected by the /reportProgress endpoint
+type ProgressReport struct {
+	Message string // what the run is working on
+	Percent int    // how far along the run is, from 0 to 100
+}
+
+// Cancellation is returned by the /cancel/{uuid} endpoint to tell a checker pod whether Kuberhealthy still wants
+// the result of its run
+type Cancellation struct {
+	Canceled bool   // true when the run was canceled or timed out and its result will not be counted
+	Reason   string // why the run was canceled, if it was
+}
+
+// Extension is returned by the /extendDeadline endpoint when a deadline extension is granted
+type Extension struct {
+	Deadline int64 // the new deadline of the run in unixtime
+	Granted  int64 // the number of seconds granted, which is less than requested when the run is near its limit
+}
+
+// Heartbeat is returned by the /heartbeat endpoint when a heartbeat is accepted
+type Heartbeat struct {
+	Deadline int64 // the deadline of the run in unixtime, pushed back by the heartbeat if it was near
+}
+
+// AlertDelivery is returned by the /alertReceiver/{uuid} endpoint.  It describes the delivery of an alert carrying
+// the run UUID in its kuberhealthy_run_uuid label through Alertmanager to the Kuberhealthy webhook receiver.
+type AlertDelivery struct {
+	Received time.Time // when the alert was first delivered
+	Status   string    // the status of the alert in its latest delivery, firing or resolved
+	Receiver string    // the Alertmanager receiver that delivered the alert
+}
@@ -0,0 +1,124 @@
+package external
+
+import (
+	"errors"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	khjobv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khjob/v1"
+)
+
+// KHServiceAccountTokenDir is the environment variable that tells checker pods which directory their projected
+// service account tokens are mounted in
+const KHServiceAccountTokenDir = "KH_SA_TOKEN_DIR"
+
+// DefaultServiceAccountTokenDir is the directory projected service account tokens are mounted in
+const DefaultServiceAccountTokenDir = "/var/run/secrets/kuberhealthy/tokens"
+
+// serviceAccountTokenVolume is the name of the projected volume that holds service account tokens
+const serviceAccountTokenVolume = "kuberhealthy-sa-tokens"
+
+// defaultTokenExpirationSeconds is the lifetime requested for tokens that do not set one.  The kubelet refreshes
+// tokens once 80% of their lifetime has passed.
+const defaultTokenExpirationSeconds int64 = 3600
+
+// minTokenExpirationSeconds is the shortest token lifetime the API server will issue
+const minTokenExpirationSeconds int64 = 600
+
+// ServiceAccountToken is a bound service account token projected into checker pods for the supplied audience
+type ServiceAccountToken struct {
+	Audience          string
+	ExpirationSeconds *int64
+}
+
+// ServiceAccountTokenFile returns the name of the file the token for an audience is written to within the token
+// directory.  Audiences are often URLs, so characters that are not safe in file names are replaced.
+func ServiceAccountTokenFile(audience string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '.' || r == '_' {
+			return r
+		}
+		return '_'
+	}, audience)
+}
+
+// checkServiceAccountTokens converts the service account tokens of a khcheck spec
+func checkServiceAccountTokens(tokens []khcheckv1.ServiceAccountToken) []ServiceAccountToken {
+	var out []ServiceAccountToken
+	for _, t := range tokens {
+		out = append(out, ServiceAccountToken{Audience: t.Audience, ExpirationSeconds: t.ExpirationSeconds})
+	}
+	return out
+}
+
+// jobServiceAccountTokens converts the service account tokens of a khjob spec
+func jobServiceAccountTokens(tokens []khjobv1.ServiceAccountToken) []ServiceAccountToken {
+	var out []ServiceAccountToken
+	for _, t := range tokens {
+		out = append(out, ServiceAccountToken{Audience: t.Audience, ExpirationSeconds: t.ExpirationSeconds})
+	}
+	return out
+}
+
+// validateServiceAccountTokens ensures that every token has an audience, a lifetime the API server will issue and
+// a file name that no other token uses
+func validateServiceAccountTokens(tokens []ServiceAccountToken) error {
+	files := make(map[string]string)
+	for _, t := range tokens {
+		if len(t.Audience) == 0 {
+			return errors.New("serviceAccountTokens audience can not be empty")
+		}
+		if t.ExpirationSeconds != nil && *t.ExpirationSeconds < minTokenExpirationSeconds {
+			return errors.New("serviceAccountTokens expirationSeconds for audience " + t.Audience + " must be at least 600")
+		}
+		file := ServiceAccountTokenFile(t.Audience)
+		if other, exists := files[file]; exists {
+			return errors.New("serviceAccountTokens audiences " + other + " and " + t.Audience + " would be written to the same file")
+		}
+		files[file] = t.Audience
+	}
+	return nil
+}
+
+// configureServiceAccountTokens projects the requested service account tokens into a volume mounted in every
+// container of the checker pod and tells the containers where to find them
+func (ext *Checker) configureServiceAccountTokens() {
+	if len(ext.ServiceAccountTokens) == 0 {
+		return
+	}
+
+	var sources []apiv1.VolumeProjection
+	for _, t := range ext.ServiceAccountTokens {
+		expirationSeconds := defaultTokenExpirationSeconds
+		if t.ExpirationSeconds != nil {
+			expirationSeconds = *t.ExpirationSeconds
+		}
+		sources = append(sources, apiv1.VolumeProjection{
+			ServiceAccountToken: &apiv1.ServiceAccountTokenProjection{
+				Audience:          t.Audience,
+				ExpirationSeconds: &expirationSeconds,
+				Path:              ServiceAccountTokenFile(t.Audience),
+			},
+		})
+	}
+
+	ext.PodSpec.Volumes = append(ext.PodSpec.Volumes, apiv1.Volume{
+		Name:         serviceAccountTokenVolume,
+		VolumeSource: apiv1.VolumeSource{Projected: &apiv1.ProjectedVolumeSource{Sources: sources}},
+	})
+
+	for i := range ext.PodSpec.Containers {
+		ext.PodSpec.Containers[i].VolumeMounts = append(ext.PodSpec.Containers[i].VolumeMounts, apiv1.VolumeMount{
+			Name:      serviceAccountTokenVolume,
+			MountPath: DefaultServiceAccountTokenDir,
+			ReadOnly:  true,
+		})
+		ext.PodSpec.Containers[i].Env = resetInjectedContainerEnvVars(ext.PodSpec.Containers[i].Env, []string{KHServiceAccountTokenDir})
+		ext.PodSpec.Containers[i].Env = append(ext.PodSpec.Containers[i].Env, apiv1.EnvVar{
+			Name:  KHServiceAccountTokenDir,
+			Value: DefaultServiceAccountTokenDir,
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package external
+
+import (
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// TestValidateServiceAccountTokens ensures that tokens without an audience, with too short a lifetime or that would
+// share a file are rejected
+func TestValidateServiceAccountTokens(t *testing.T) {
+
+	short := int64(60)
+	hour := int64(3600)
+	var testCases = []struct {
+		description string
+		tokens      []ServiceAccountToken
+		expectError bool
+	}{
+		{"no tokens", nil, false},
+		{"valid tokens", []ServiceAccountToken{{Audience: "sts.amazonaws.com"}, {Audience: "https://vault.example.com", ExpirationSeconds: &hour}}, false},
+		{"empty audience", []ServiceAccountToken{{}}, true},
+		{"short lifetime", []ServiceAccountToken{{Audience: "vault", ExpirationSeconds: &short}}, true},
+		{"same file", []ServiceAccountToken{{Audience: "https://vault"}, {Audience: "https:__vault"}}, true},
+	}
+
+	for _, tc := range testCases {
+		err := validateServiceAccountTokens(tc.tokens)
+		if tc.expectError != (err != nil) {
+			t.Fatalf("%s: validation error was %v but expected an error to be %t", tc.description, err, tc.expectError)
+		}
+	}
+}
+
+// TestConfigureServiceAccountTokens ensures that requested tokens are projected and mounted into every container
+func TestConfigureServiceAccountTokens(t *testing.T) {
+
+	original := apiv1.PodSpec{Containers: []apiv1.Container{{Name: "check"}, {Name: "helper"}}}
+	ext := &Checker{
+		Namespace:             "kuberhealthy",
+		SecurityContextPolicy: SecurityContextPolicyNone,
+		ServiceAccountTokens:  []ServiceAccountToken{{Audience: "https://vault.example.com"}},
+		OriginalPodSpec:       original,
+		PodSpec:               original,
+	}
+
+	// configure twice to ensure the volume is not added again on each run
+	for i := 0; i < 2; i++ {
+		err := ext.configureUserPodSpec(time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatalf("failed to configure pod spec: %s", err)
+		}
+	}
+
+	if len(ext.PodSpec.Volumes) != 1 || ext.PodSpec.Volumes[0].Projected == nil {
+		t.Fatalf("expected one projected volume but found %+v", ext.PodSpec.Volumes)
+	}
+	projection := ext.PodSpec.Volumes[0].Projected.Sources[0].ServiceAccountToken
+	if projection.Audience != "https://vault.example.com" || projection.Path != "https___vault.example.com" || *projection.ExpirationSeconds != defaultTokenExpirationSeconds {
+		t.Fatalf("unexpected token projection: %+v", projection)
+	}
+
+	for _, c := range ext.PodSpec.Containers {
+		if len(c.VolumeMounts) != 1 || c.VolumeMounts[0].MountPath != DefaultServiceAccountTokenDir {
+			t.Fatalf("container %s volume mounts were %+v", c.Name, c.VolumeMounts)
+		}
+	}
+	if len(ext.OriginalPodSpec.Volumes) != 0 {
+		t.Fatal("original pod spec was modified")
+	}
+}
@@ -0,0 +1,48 @@
+package external
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// KHTraceParent is the environment variable that gives checker pods the W3C trace context of their run, so that the
+// run can be followed as a single trace from Kuberhealthy through the checker pod to the report handler
+const KHTraceParent = "KH_TRACEPARENT"
+
+// TraceParentHeader is the W3C trace context header that checker pods send the trace context of their run back in
+const TraceParentHeader = "traceparent"
+
+// traceParentPattern matches version 00 of the W3C traceparent header: the trace ID, the ID of the parent span and
+// the trace flags
+var traceParentPattern = regexp.MustCompile(`^00-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$`)
+
+// NewTraceParent starts a new sampled trace for a run and returns its traceparent.  The parent span is the run as
+// seen by Kuberhealthy, which the checker pod and the report handler continue.
+func NewTraceParent() string {
+	ids := make([]byte, 24)
+	_, err := rand.Read(ids)
+	if err != nil {
+		return ""
+	}
+	return "00-" + hex.EncodeToString(ids[:16]) + "-" + hex.EncodeToString(ids[16:]) + "-01"
+}
+
+// TraceID returns the trace ID of a traceparent, or an empty string when it is not a valid traceparent.  Trace and
+// span IDs of all zeros are not valid.
+func TraceID(traceParent string) string {
+	m := traceParentPattern.FindStringSubmatch(traceParent)
+	if m == nil || strings.Trim(m[1], "0") == "" || strings.Trim(m[2], "0") == "" {
+		return ""
+	}
+	return m[1]
+}
+
+// startTrace starts a new trace for the current run and records it with the run, so that the report handler can tell
+// which trace a report belongs to
+func (ext *Checker) startTrace() {
+	ext.currentTraceParent = NewTraceParent()
+	ext.Runs.SetTraceParent(ext.currentCheckUUID, ext.currentTraceParent)
+	ext.log("Tracing run", ext.currentCheckUUID, "as trace", TraceID(ext.currentTraceParent))
+}
@@ -0,0 +1,68 @@
+package external
+
+import (
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// TestTraceID ensures only valid traceparents have a trace ID
+func TestTraceID(t *testing.T) {
+	var testCases = []struct {
+		traceParent string
+		traceID     string
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", ""},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", ""},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", ""},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", ""},
+		{"", ""},
+	}
+	for _, tc := range testCases {
+		if traceID := TraceID(tc.traceParent); traceID != tc.traceID {
+			t.Fatalf("TraceID(%q) returned %q but expected %q", tc.traceParent, traceID, tc.traceID)
+		}
+	}
+
+	traceParent := NewTraceParent()
+	if len(TraceID(traceParent)) == 0 || traceParent[len(traceParent)-2:] != "01" {
+		t.Fatalf("expected a new valid and sampled traceparent, got %q", traceParent)
+	}
+	if TraceID(NewTraceParent()) == TraceID(traceParent) {
+		t.Fatal("expected every new traceparent to start a new trace")
+	}
+}
+
+// TestConfigureUserPodSpecTraceParent ensures checker pods are given the trace context of their run and that a trace
+// context set in the check's pod spec is never used
+func TestConfigureUserPodSpecTraceParent(t *testing.T) {
+	original := apiv1.PodSpec{Containers: []apiv1.Container{{
+		Name:  "check",
+		Image: "kuberhealthy/test-check",
+		Env:   []apiv1.EnvVar{{Name: KHTraceParent, Value: "chosen by the check"}},
+	}}}
+
+	for _, traceParent := range []string{"", NewTraceParent()} {
+		ext := &Checker{Namespace: "kuberhealthy", OriginalPodSpec: original, PodSpec: original, currentCheckUUID: "run-1", currentTraceParent: traceParent}
+		err := ext.configureUserPodSpec(time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatalf("failed to configure pod spec: %s", err)
+		}
+
+		var found []string
+		for _, e := range ext.PodSpec.Containers[0].Env {
+			if e.Name == KHTraceParent {
+				found = append(found, e.Value)
+			}
+		}
+		if len(traceParent) == 0 && len(found) != 0 {
+			t.Fatalf("expected no trace context for a run without one, got %v", found)
+		}
+		if len(traceParent) > 0 && (len(found) != 1 || found[0] != traceParent) {
+			t.Fatalf("expected only the trace context of the run, got %v", found)
+		}
+	}
+}
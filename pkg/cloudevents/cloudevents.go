@@ -0,0 +1,224 @@
+// Package cloudevents publishes Kuberhealthy check results as CloudEvents.  Events are sent in structured mode over
+// HTTP so that Kubernetes native receivers such as Knative Eventing brokers and Argo Events webhook sources can
+// consume them without a custom adapter.  An event is sent every time a check or job run completes and every time a
+// check changes between an OK and a failing state.
+package cloudevents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// SpecVersion is the version of the CloudEvents specification that events are written in
+const SpecVersion = "1.0"
+
+// ContentType is the content type of CloudEvents sent in structured mode
+const ContentType = "application/cloudevents+json"
+
+// The types of events sent by Kuberhealthy
+const (
+	TypeRunCompleted = "com.github.kuberhealthy.run.completed"
+	TypeStateChanged = "com.github.kuberhealthy.state.changed"
+)
+
+// DefaultSource is the source attribute of events when none is configured
+const DefaultSource = "kuberhealthy"
+
+// defaultSendTimeout is how long a single delivery attempt may take
+const defaultSendTimeout = time.Second * 10
+
+// defaultMaxRetries is how many times a failed delivery is retried before the event is dropped
+const defaultMaxRetries = 3
+
+// Event is a CloudEvent in structured mode
+type Event struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject,omitempty"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype,omitempty"`
+	Data            Result    `json:"data"`
+}
+
+// Result is the data of every event sent by Kuberhealthy.  It describes the state of a check or job after a run.
+type Result struct {
+	Name         string            `json:"name"`
+	Namespace    string            `json:"namespace"`
+	Workload     string            `json:"workload"` // KHCheck or KHJob
+	OK           bool              `json:"ok"`
+	Errors       []string          `json:"errors"`
+	RunDuration  string            `json:"runDuration,omitempty"`
+	UUID         string            `json:"uuid,omitempty"`
+	Node         string            `json:"node,omitempty"`
+	PreviousOK   *bool             `json:"previousOK,omitempty"`   // set on state change events only
+	Correlations map[string]string `json:"correlations,omitempty"` // the correlation IDs configured in Kuberhealthy, such as a deploy SHA
+	Labels       map[string]string `json:"labels,omitempty"`       // the labels of the khcheck or khjob configured to be propagated
+}
+
+// Key returns the namespace/name key of the check or job the result is for
+func (r Result) Key() string {
+	return r.Namespace + "/" + r.Name
+}
+
+// Sender sends CloudEvents to a sink, such as a Knative broker URL.  It remembers the last state seen for each check
+// so that it can tell when a check changes state.  A nil Sender does nothing, which lets callers publish results
+// without checking if CloudEvents are enabled.  It is safe for concurrent use.
+type Sender struct {
+	sync.Mutex
+	Sink       string
+	Source     string
+	Client     *http.Client
+	MaxRetries uint64
+	lastOK     map[string]bool
+}
+
+// NewSender creates a new Sender that sends events to the supplied sink URL with the supplied source attribute
+func NewSender(sink string, source string) *Sender {
+	if len(source) == 0 {
+		source = DefaultSource
+	}
+	return &Sender{
+		Sink:       sink,
+		Source:     source,
+		Client:     &http.Client{Timeout: defaultSendTimeout},
+		MaxRetries: defaultMaxRetries,
+		lastOK:     make(map[string]bool),
+	}
+}
+
+// Known indicates that the sender has already seen a state for the check with the supplied namespace/name key
+func (s *Sender) Known(key string) bool {
+	if s == nil {
+		return false
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	_, ok := s.lastOK[key]
+	return ok
+}
+
+// Seed sets the last known state of a check that the sender has not seen yet.  This lets the first result seen for
+// a check after Kuberhealthy starts be compared against the state stored in the cluster.
+func (s *Sender) Seed(key string, ok bool) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	if _, known := s.lastOK[key]; !known {
+		s.lastOK[key] = ok
+	}
+}
+
+// RunCompleted sends a run completed event for the supplied result in the background, followed by a state changed
+// event if the check changed state.
+func (s *Sender) RunCompleted(r Result) {
+	if s == nil {
+		return
+	}
+	s.sendAsync(s.NewEvent(TypeRunCompleted, r))
+	s.StateObserved(r)
+}
+
+// StateObserved sends a state changed event in the background when the supplied result differs from the last state
+// seen for the check.  Checks seen for the first time only have their state recorded.
+func (s *Sender) StateObserved(r Result) {
+	if s == nil {
+		return
+	}
+
+	previous, changed := s.observe(r.Key(), r.OK)
+	if !changed {
+		return
+	}
+	r.PreviousOK = &previous
+	s.sendAsync(s.NewEvent(TypeStateChanged, r))
+}
+
+// observe records the state of a check and returns the previous state along with whether it changed
+func (s *Sender) observe(key string, ok bool) (bool, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	previous, known := s.lastOK[key]
+	s.lastOK[key] = ok
+	return previous, known && previous != ok
+}
+
+// NewEvent creates a new event of the supplied type for a result
+func (s *Sender) NewEvent(eventType string, r Result) Event {
+	if r.Errors == nil {
+		r.Errors = []string{}
+	}
+	return Event{
+		SpecVersion:     SpecVersion,
+		ID:              uuid.New().String(),
+		Source:          s.Source,
+		Type:            eventType,
+		Subject:         r.Key(),
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data:            r,
+	}
+}
+
+// sendAsync sends an event in the background, retrying failed deliveries
+func (s *Sender) sendAsync(e Event) {
+	go func() {
+		err := s.SendWithRetry(context.Background(), e)
+		if err != nil {
+			log.Errorln("cloudevents: failed to send", e.Type, "event for", e.Subject+":", err)
+		}
+	}()
+}
+
+// SendWithRetry sends an event, retrying failed deliveries with an exponential backoff
+func (s *Sender) SendWithRetry(ctx context.Context, e Event) error {
+	b := backoff.WithContext(backoff.WithMaxRetries(backoff.NewExponentialBackOff(), s.MaxRetries), ctx)
+	return backoff.Retry(func() error {
+		return s.Send(ctx, e)
+	}, b)
+}
+
+// Send delivers a single event to the sink in structured mode
+func (s *Sender) Send(ctx context.Context, e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return backoff.Permanent(fmt.Errorf("failed to marshal event: %w", err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Sink, bytes.NewReader(b))
+	if err != nil {
+		return backoff.Permanent(fmt.Errorf("failed to create request for sink %s: %w", s.Sink, err))
+	}
+	req.Header.Set("Content-Type", ContentType)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send event to sink %s: %w", s.Sink, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = fmt.Errorf("sink %s responded with status code %d", s.Sink, resp.StatusCode)
+		// client errors other than throttling will not succeed on retry
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return backoff.Permanent(err)
+		}
+		return err
+	}
+	return nil
+}
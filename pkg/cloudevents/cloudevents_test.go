@@ -0,0 +1,123 @@
+package cloudevents
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestSend ensures events are sent to the sink in structured mode
+func TestSend(t *testing.T) {
+	received := make(chan Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != ContentType {
+			t.Errorf("event sent with content type %s but expected %s", r.Header.Get("Content-Type"), ContentType)
+		}
+		e := Event{}
+		err := json.NewDecoder(r.Body).Decode(&e)
+		if err != nil {
+			t.Errorf("failed to decode event: %s", err)
+		}
+		received <- e
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	s := NewSender(server.URL, "")
+	err := s.Send(context.Background(), s.NewEvent(TypeRunCompleted, Result{Name: "check", Namespace: "kuberhealthy", OK: true}))
+	if err != nil {
+		t.Fatalf("failed to send event: %s", err)
+	}
+
+	e := <-received
+	if e.SpecVersion != SpecVersion || e.Type != TypeRunCompleted || e.Source != DefaultSource || len(e.ID) == 0 {
+		t.Fatalf("event attributes were not set correctly: %+v", e)
+	}
+	if e.Subject != "kuberhealthy/check" || e.Data.Name != "check" || !e.Data.OK || e.Data.Errors == nil {
+		t.Fatalf("event data was not set correctly: %+v", e)
+	}
+}
+
+// TestSendWithRetry ensures failed deliveries are retried unless the sink rejects the event
+func TestSendWithRetry(t *testing.T) {
+	var testCases = []struct {
+		statusCode int
+		attempts   int
+		err        bool
+	}{
+		{http.StatusOK, 1, false},
+		{http.StatusServiceUnavailable, 3, true},
+		{http.StatusBadRequest, 1, true},
+	}
+
+	for _, tc := range testCases {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			w.WriteHeader(tc.statusCode)
+		}))
+
+		s := NewSender(server.URL, "test")
+		s.MaxRetries = 2
+		err := s.SendWithRetry(context.Background(), s.NewEvent(TypeRunCompleted, Result{Name: "check"}))
+		server.Close()
+		if (err != nil) != tc.err {
+			t.Fatalf("status code %d returned error %v but expected error to be %t", tc.statusCode, err, tc.err)
+		}
+		if attempts != tc.attempts {
+			t.Fatalf("status code %d was attempted %d times but expected %d", tc.statusCode, attempts, tc.attempts)
+		}
+	}
+}
+
+// TestStateObserved ensures state changed events are only sent when a check changes between OK and failing
+func TestStateObserved(t *testing.T) {
+	received := make(chan Event, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e := Event{}
+		_ = json.NewDecoder(r.Body).Decode(&e)
+		received <- e
+	}))
+	defer server.Close()
+
+	s := NewSender(server.URL, "test")
+	s.Seed("kuberhealthy/check", true)
+	s.Seed("kuberhealthy/check", false) // seeding a known check does nothing
+
+	var testCases = []struct {
+		ok      bool
+		changed bool
+	}{
+		{true, false},
+		{false, true},
+		{false, false},
+		{true, true},
+	}
+
+	for _, tc := range testCases {
+		s.StateObserved(Result{Name: "check", Namespace: "kuberhealthy", OK: tc.ok})
+		select {
+		case e := <-received:
+			if !tc.changed {
+				t.Fatalf("state change event sent when check stayed at ok %t", tc.ok)
+			}
+			if e.Type != TypeStateChanged || e.Data.OK != tc.ok || e.Data.PreviousOK == nil || *e.Data.PreviousOK == tc.ok {
+				t.Fatalf("state change event was not set correctly: %+v", e)
+			}
+		case <-time.After(time.Millisecond * 200):
+			if tc.changed {
+				t.Fatalf("no state change event sent when check changed to ok %t", tc.ok)
+			}
+		}
+	}
+
+	// a nil sender does nothing
+	var nilSender *Sender
+	nilSender.RunCompleted(Result{Name: "check"})
+	if nilSender.Known("kuberhealthy/check") {
+		t.Fatal("nil sender knew about a check")
+	}
+}
@@ -0,0 +1,187 @@
+// Package coverage reports which namespaces and workloads are covered by Kuberhealthy checks.  Coverage is worked
+// out from a label convention:
+//
+//   - a namespace is covered when a khcheck lives in it or when a khcheck is labeled with
+//     comcast.github.io/covers-namespace=<namespace>
+//   - a workload (deployment, statefulset or daemonset) is covered when it is labeled with
+//     comcast.github.io/khcheck=<check name> and that khcheck covers the workload's namespace
+//
+// Namespaces labeled comcast.github.io/coverage=ignore are left out of the report along with their workloads.
+package coverage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+)
+
+// CoversNamespaceLabel is the label set on a khcheck to mark it as covering a namespace other than its own
+const CoversNamespaceLabel = "comcast.github.io/covers-namespace"
+
+// WorkloadCheckLabel is the label set on a workload that names the khcheck covering it
+const WorkloadCheckLabel = "comcast.github.io/khcheck"
+
+// IgnoreLabel is the label set on a namespace with the value IgnoreValue to leave it out of coverage reports
+const IgnoreLabel = "comcast.github.io/coverage"
+
+// IgnoreValue is the value of IgnoreLabel that leaves a namespace out of coverage reports
+const IgnoreValue = "ignore"
+
+// Workload is a deployment, statefulset or daemonset considered for coverage
+type Workload struct {
+	Namespace string
+	Kind      string
+	Name      string
+	Labels    map[string]string
+}
+
+// Key returns the namespace/kind/name key of the workload
+func (w Workload) Key() string {
+	return w.Namespace + "/" + w.Kind + "/" + w.Name
+}
+
+// Item is the coverage of a single namespace or workload
+type Item struct {
+	Name    string   `json:"name"`             // the namespace or namespace/kind/name of the workload
+	Covered bool     `json:"covered"`          // indicates that at least one check covers the item
+	Checks  []string `json:"checks,omitempty"` // the namespace/name keys of the checks covering the item
+}
+
+// Summary is the coverage of all namespaces or all workloads
+type Summary struct {
+	Total   int     `json:"total"`
+	Covered int     `json:"covered"`
+	Ratio   float64 `json:"ratio"` // the share of items covered, or 1 when there are no items
+	Items   []Item  `json:"items"`
+}
+
+// Uncovered returns the names of the items that are not covered by any check
+func (s Summary) Uncovered() []string {
+	uncovered := []string{}
+	for _, i := range s.Items {
+		if !i.Covered {
+			uncovered = append(uncovered, i.Name)
+		}
+	}
+	return uncovered
+}
+
+// Report is the coverage of the cluster at a point in time
+type Report struct {
+	Generated  time.Time `json:"generated"`
+	Namespaces Summary   `json:"namespaces"`
+	Workloads  Summary   `json:"workloads"`
+}
+
+// Options configure how coverage is computed
+type Options struct {
+	ExcludeNamespaces []string // namespaces left out of the report along with their workloads
+}
+
+// Compute works out the coverage of the supplied namespaces and workloads by the supplied checks
+func Compute(namespaces []corev1.Namespace, checks []khcheckv1.KuberhealthyCheck, workloads []Workload, opts Options) Report {
+	excluded := make(map[string]bool)
+	for _, ns := range opts.ExcludeNamespaces {
+		excluded[ns] = true
+	}
+	for _, ns := range namespaces {
+		if ns.Labels[IgnoreLabel] == IgnoreValue {
+			excluded[ns.Name] = true
+		}
+	}
+
+	// map each namespace to the checks covering it by check name
+	covering := make(map[string]map[string]string)
+	cover := func(namespace string, c khcheckv1.KuberhealthyCheck) {
+		if covering[namespace] == nil {
+			covering[namespace] = make(map[string]string)
+		}
+		covering[namespace][c.Name] = c.Namespace + "/" + c.Name
+	}
+	for _, c := range checks {
+		cover(c.Namespace, c)
+		if ns, ok := c.Labels[CoversNamespaceLabel]; ok && len(ns) > 0 {
+			cover(ns, c)
+		}
+	}
+
+	report := Report{Generated: time.Now()}
+
+	var namespaceItems []Item
+	for _, ns := range namespaces {
+		if excluded[ns.Name] {
+			continue
+		}
+		item := Item{Name: ns.Name}
+		for _, key := range covering[ns.Name] {
+			item.Checks = append(item.Checks, key)
+		}
+		sort.Strings(item.Checks)
+		item.Covered = len(item.Checks) > 0
+		namespaceItems = append(namespaceItems, item)
+	}
+	report.Namespaces = summarize(namespaceItems)
+
+	var workloadItems []Item
+	for _, w := range workloads {
+		if excluded[w.Namespace] {
+			continue
+		}
+		item := Item{Name: w.Key()}
+		if key, ok := covering[w.Namespace][w.Labels[WorkloadCheckLabel]]; ok {
+			item.Checks = []string{key}
+			item.Covered = true
+		}
+		workloadItems = append(workloadItems, item)
+	}
+	report.Workloads = summarize(workloadItems)
+
+	return report
+}
+
+// summarize counts the covered items and sorts them by name
+func summarize(items []Item) Summary {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+
+	s := Summary{Total: len(items), Ratio: 1, Items: items}
+	if s.Items == nil {
+		s.Items = []Item{}
+	}
+	for _, i := range items {
+		if i.Covered {
+			s.Covered++
+		}
+	}
+	if s.Total > 0 {
+		s.Ratio = float64(s.Covered) / float64(s.Total)
+	}
+	return s
+}
+
+// PrometheusMetrics formats the report as Prometheus metrics
+func PrometheusMetrics(report Report) string {
+	output := "# HELP kuberhealthy_coverage_ratio Shows the share of namespaces or workloads covered by at least one khcheck\n"
+	output += "# TYPE kuberhealthy_coverage_ratio gauge\n"
+	output += fmt.Sprintf("kuberhealthy_coverage_ratio{kind=\"namespace\"} %f\n", report.Namespaces.Ratio)
+	output += fmt.Sprintf("kuberhealthy_coverage_ratio{kind=\"workload\"} %f\n", report.Workloads.Ratio)
+	output += "# HELP kuberhealthy_coverage_uncovered Shows the number of namespaces or workloads not covered by any khcheck\n"
+	output += "# TYPE kuberhealthy_coverage_uncovered gauge\n"
+	output += fmt.Sprintf("kuberhealthy_coverage_uncovered{kind=\"namespace\"} %d\n", report.Namespaces.Total-report.Namespaces.Covered)
+	output += fmt.Sprintf("kuberhealthy_coverage_uncovered{kind=\"workload\"} %d\n", report.Workloads.Total-report.Workloads.Covered)
+	output += "# HELP kuberhealthy_namespace_covered Shows if a namespace is covered by at least one khcheck\n"
+	output += "# TYPE kuberhealthy_namespace_covered gauge\n"
+	for _, i := range report.Namespaces.Items {
+		covered := 0
+		if i.Covered {
+			covered = 1
+		}
+		output += fmt.Sprintf("kuberhealthy_namespace_covered{namespace=\"%s\"} %d\n", i.Name, covered)
+	}
+	return output
+}
@@ -0,0 +1,80 @@
+package coverage
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+)
+
+// testNamespace makes a namespace with the supplied labels
+func testNamespace(name string, labels map[string]string) corev1.Namespace {
+	return corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+// testCheck makes a khcheck with the supplied labels
+func testCheck(name string, namespace string, labels map[string]string) khcheckv1.KuberhealthyCheck {
+	c := khcheckv1.NewKuberhealthyCheck(name, namespace, khcheckv1.CheckConfig{})
+	c.Labels = labels
+	return c
+}
+
+// TestCompute ensures namespaces and workloads are covered according to the label convention
+func TestCompute(t *testing.T) {
+	namespaces := []corev1.Namespace{
+		testNamespace("kuberhealthy", nil),
+		testNamespace("payments", nil),
+		testNamespace("search", nil),
+		testNamespace("sandbox", map[string]string{IgnoreLabel: IgnoreValue}),
+		testNamespace("kube-system", nil),
+	}
+	checks := []khcheckv1.KuberhealthyCheck{
+		testCheck("deployment", "kuberhealthy", nil),
+		testCheck("payments-api", "kuberhealthy", map[string]string{CoversNamespaceLabel: "payments"}),
+	}
+	workloads := []Workload{
+		{Namespace: "payments", Kind: "Deployment", Name: "api", Labels: map[string]string{WorkloadCheckLabel: "payments-api"}},
+		{Namespace: "payments", Kind: "Deployment", Name: "worker", Labels: map[string]string{WorkloadCheckLabel: "missing"}},
+		{Namespace: "search", Kind: "StatefulSet", Name: "index", Labels: map[string]string{WorkloadCheckLabel: "payments-api"}},
+		{Namespace: "sandbox", Kind: "Deployment", Name: "toy"},
+	}
+
+	report := Compute(namespaces, checks, workloads, Options{ExcludeNamespaces: []string{"kube-system"}})
+
+	if report.Namespaces.Total != 3 || report.Namespaces.Covered != 2 {
+		t.Fatalf("namespace coverage was %d of %d but expected 2 of 3", report.Namespaces.Covered, report.Namespaces.Total)
+	}
+	if !reflect.DeepEqual(report.Namespaces.Uncovered(), []string{"search"}) {
+		t.Fatalf("uncovered namespaces were %v but expected [search]", report.Namespaces.Uncovered())
+	}
+	expected := []string{"payments/Deployment/worker", "search/StatefulSet/index"}
+	if !reflect.DeepEqual(report.Workloads.Uncovered(), expected) {
+		t.Fatalf("uncovered workloads were %v but expected %v", report.Workloads.Uncovered(), expected)
+	}
+	if report.Workloads.Items[0].Name != "payments/Deployment/api" || !reflect.DeepEqual(report.Workloads.Items[0].Checks, []string{"kuberhealthy/payments-api"}) {
+		t.Fatalf("covered workload did not list its check: %+v", report.Workloads.Items[0])
+	}
+}
+
+// TestPrometheusMetrics ensures coverage is published as ratios, uncovered counts and per namespace gauges
+func TestPrometheusMetrics(t *testing.T) {
+	report := Compute([]corev1.Namespace{testNamespace("a", nil), testNamespace("b", nil)},
+		[]khcheckv1.KuberhealthyCheck{testCheck("check", "a", nil)}, nil, Options{})
+	m := PrometheusMetrics(report)
+
+	for _, line := range []string{
+		`kuberhealthy_coverage_ratio{kind="namespace"} 0.500000`,
+		`kuberhealthy_coverage_ratio{kind="workload"} 1.000000`,
+		`kuberhealthy_coverage_uncovered{kind="namespace"} 1`,
+		`kuberhealthy_namespace_covered{namespace="a"} 1`,
+		`kuberhealthy_namespace_covered{namespace="b"} 0`,
+	} {
+		if !strings.Contains(m, line+"\n") {
+			t.Fatalf("metrics did not contain %s:\n%s", line, m)
+		}
+	}
+}
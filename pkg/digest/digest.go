@@ -0,0 +1,313 @@
+// Package digest summarizes the run history of Kuberhealthy checks over a day or a week so that teams get a regular
+// picture of how their checks are doing, rather than only hearing about them when they fail.  A digest lists the
+// checks that failed the most, the checks that flipped between passing and failing the most, the checks created
+// during the period and the uptime of each suite of checks.  Digests are sent to a Slack incoming webhook, by email
+// or both.
+package digest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+)
+
+// The schedules digests can be sent on
+const (
+	ScheduleDaily  = "daily"
+	ScheduleWeekly = "weekly"
+)
+
+// DefaultSuiteLabel is the khcheck label that groups checks into suites unless SuiteLabel says otherwise.  Checks
+// without the label are grouped by their namespace.
+const DefaultSuiteLabel = "kuberhealthy.io/suite"
+
+// DefaultTopChecks is how many checks are listed as failing the most and as the flakiest unless TopChecks says
+// otherwise
+const DefaultTopChecks = 5
+
+// Config configures digests.  Digests are sent when a schedule and at least one destination are set.
+type Config struct {
+	Schedule        string      `yaml:"schedule,omitempty"`        // daily or weekly
+	SuiteLabel      string      `yaml:"suiteLabel,omitempty"`      // the khcheck label that groups checks into suites
+	TopChecks       int         `yaml:"topChecks,omitempty"`       // how many checks are listed as failing the most and as the flakiest
+	SlackWebhookURL string      `yaml:"slackWebhookURL,omitempty"` // the Slack incoming webhook that digests are posted to
+	Email           EmailConfig `yaml:"email,omitempty"`           // the email digests are sent by
+}
+
+// EmailConfig configures the email digests are sent by
+type EmailConfig struct {
+	SMTPAddress string   `yaml:"smtpAddress,omitempty"` // host:port of the SMTP server
+	Username    string   `yaml:"username,omitempty"`    // username to authenticate to the SMTP server with, if any
+	Password    string   `yaml:"password,omitempty"`    // password to authenticate to the SMTP server with
+	From        string   `yaml:"from,omitempty"`        // the sender address
+	To          []string `yaml:"to,omitempty"`          // the recipient addresses
+}
+
+// Enabled tells if digests are configured to be sent
+func (c Config) Enabled() bool {
+	return len(c.Schedule) > 0
+}
+
+// Validate ensures the schedule is known and that digests have somewhere to go
+func (c Config) Validate() error {
+	if c.Schedule != ScheduleDaily && c.Schedule != ScheduleWeekly {
+		return fmt.Errorf("digest schedule must be %s or %s, got %q", ScheduleDaily, ScheduleWeekly, c.Schedule)
+	}
+	if c.TopChecks < 0 {
+		return fmt.Errorf("digest topChecks must not be negative, got %d", c.TopChecks)
+	}
+	if len(c.SlackWebhookURL) == 0 && len(c.Email.SMTPAddress) == 0 {
+		return fmt.Errorf("digests need a slackWebhookURL or an email smtpAddress to be sent to")
+	}
+	if len(c.Email.SMTPAddress) > 0 && (len(c.Email.From) == 0 || len(c.Email.To) == 0) {
+		return fmt.Errorf("email digests need a from address and at least one to address")
+	}
+	return nil
+}
+
+// suiteLabel returns the label that groups checks into suites
+func (c Config) suiteLabel() string {
+	if len(c.SuiteLabel) > 0 {
+		return c.SuiteLabel
+	}
+	return DefaultSuiteLabel
+}
+
+// topChecks returns how many checks are listed in the top failing and flakiest lists
+func (c Config) topChecks() int {
+	if c.TopChecks > 0 {
+		return c.TopChecks
+	}
+	return DefaultTopChecks
+}
+
+// Period returns the length of time a digest on the schedule covers
+func Period(schedule string) time.Duration {
+	if schedule == ScheduleWeekly {
+		return time.Hour * 24 * 7
+	}
+	return time.Hour * 24
+}
+
+// NextSend returns when the next digest on the schedule is due after now.  Daily digests are sent at midnight UTC
+// and weekly digests at midnight UTC on Mondays.
+func NextSend(schedule string, now time.Time) time.Time {
+	now = now.UTC()
+	next := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	if schedule == ScheduleWeekly {
+		for next.Weekday() != time.Monday {
+			next = next.AddDate(0, 0, 1)
+		}
+	}
+	return next
+}
+
+// Check is a khcheck along with its run history
+type Check struct {
+	Name      string
+	Namespace string
+	Labels    map[string]string
+	Created   time.Time
+	History   []khstatev1.RunHistoryEntry
+}
+
+// Key returns the namespace/name key of the check
+func (c Check) Key() string {
+	return c.Namespace + "/" + c.Name
+}
+
+// CheckSummary is how a check did over the period of a digest
+type CheckSummary struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Suite     string `json:"suite"`
+	Runs      int    `json:"runs"`     // runs that passed or failed during the period
+	Failures  int    `json:"failures"` // runs that failed during the period
+	Flips     int    `json:"flips"`    // times the check changed between passing and failing during the period
+	New       bool   `json:"new"`      // indicates that the check was created during the period
+}
+
+// Key returns the namespace/name key of the check
+func (s CheckSummary) Key() string {
+	return s.Namespace + "/" + s.Name
+}
+
+// SuiteUptime is how a suite of checks did over the period of a digest
+type SuiteUptime struct {
+	Suite     string  `json:"suite"`
+	Checks    int     `json:"checks"`
+	Runs      int     `json:"runs"`
+	Successes int     `json:"successes"`
+	Uptime    float64 `json:"uptime"` // the share of runs that passed, or 1 when no runs finished
+}
+
+// Digest summarizes the checks over a period of time
+type Digest struct {
+	Schedule   string         `json:"schedule"`
+	Start      time.Time      `json:"start"`
+	End        time.Time      `json:"end"`
+	TopFailing []CheckSummary `json:"topFailing"`
+	Flakiest   []CheckSummary `json:"flakiest"`
+	NewChecks  []CheckSummary `json:"newChecks"`
+	Suites     []SuiteUptime  `json:"suites"`
+}
+
+// Build summarizes the runs of the checks recorded in the period of the schedule ending at end.  Only the runs kept
+// in the history of each khstate are seen, so maxRunHistory should be large enough to hold a period of runs of the
+// most frequent checks.  Canceled runs and runs without a recorded time are left out.
+func Build(c Config, checks []Check, end time.Time) Digest {
+	d := Digest{Schedule: c.Schedule, Start: end.Add(-Period(c.Schedule)), End: end}
+
+	var summaries []CheckSummary
+	suites := make(map[string]*SuiteUptime)
+	for _, check := range checks {
+		s := summarize(check, c.suiteLabel(), d.Start, d.End)
+		summaries = append(summaries, s)
+
+		suite, ok := suites[s.Suite]
+		if !ok {
+			suite = &SuiteUptime{Suite: s.Suite}
+			suites[s.Suite] = suite
+		}
+		suite.Checks++
+		suite.Runs += s.Runs
+		suite.Successes += s.Runs - s.Failures
+	}
+
+	for _, s := range summaries {
+		if s.Failures > 0 {
+			d.TopFailing = append(d.TopFailing, s)
+		}
+		if s.Flips > 0 {
+			d.Flakiest = append(d.Flakiest, s)
+		}
+		if s.New {
+			d.NewChecks = append(d.NewChecks, s)
+		}
+	}
+	sort.SliceStable(d.TopFailing, func(i, j int) bool {
+		if d.TopFailing[i].Failures != d.TopFailing[j].Failures {
+			return d.TopFailing[i].Failures > d.TopFailing[j].Failures
+		}
+		return d.TopFailing[i].Key() < d.TopFailing[j].Key()
+	})
+	sort.SliceStable(d.Flakiest, func(i, j int) bool {
+		if d.Flakiest[i].Flips != d.Flakiest[j].Flips {
+			return d.Flakiest[i].Flips > d.Flakiest[j].Flips
+		}
+		return d.Flakiest[i].Key() < d.Flakiest[j].Key()
+	})
+	sort.Slice(d.NewChecks, func(i, j int) bool {
+		return d.NewChecks[i].Key() < d.NewChecks[j].Key()
+	})
+	if len(d.TopFailing) > c.topChecks() {
+		d.TopFailing = d.TopFailing[:c.topChecks()]
+	}
+	if len(d.Flakiest) > c.topChecks() {
+		d.Flakiest = d.Flakiest[:c.topChecks()]
+	}
+
+	for _, suite := range suites {
+		suite.Uptime = 1
+		if suite.Runs > 0 {
+			suite.Uptime = float64(suite.Successes) / float64(suite.Runs)
+		}
+		d.Suites = append(d.Suites, *suite)
+	}
+	sort.Slice(d.Suites, func(i, j int) bool {
+		return d.Suites[i].Suite < d.Suites[j].Suite
+	})
+	return d
+}
+
+// summarize works out how a check did between start and end
+func summarize(check Check, suiteLabel string, start time.Time, end time.Time) CheckSummary {
+	s := CheckSummary{
+		Name:      check.Name,
+		Namespace: check.Namespace,
+		Suite:     check.Labels[suiteLabel],
+		New:       !check.Created.Before(start) && check.Created.Before(end),
+	}
+	if len(s.Suite) == 0 {
+		s.Suite = check.Namespace
+	}
+
+	var seen, lastOK bool
+	for _, entry := range check.History {
+		if entry.Time == nil || entry.Time.Time.Before(start) || !entry.Time.Time.Before(end) {
+			continue
+		}
+		ok, finished := passed(entry.Result)
+		if !finished {
+			continue
+		}
+		s.Runs++
+		if !ok {
+			s.Failures++
+		}
+		if seen && ok != lastOK {
+			s.Flips++
+		}
+		seen = true
+		lastOK = ok
+	}
+	return s
+}
+
+// passed tells if a run result is a pass, and if it is a pass or a failure at all
+func passed(result khstatev1.RunResult) (ok bool, finished bool) {
+	switch result {
+	case khstatev1.RunSuccess, khstatev1.RunLateSuccess:
+		return true, true
+	case khstatev1.RunFailure, khstatev1.RunLateFailure, khstatev1.RunProvisioningError:
+		return false, true
+	}
+	return false, false
+}
+
+// Title returns the title of the digest, used as the email subject
+func (d Digest) Title() string {
+	return fmt.Sprintf("Kuberhealthy %s digest for %s to %s", d.Schedule, d.Start.UTC().Format("2006-01-02"), d.End.UTC().Format("2006-01-02"))
+}
+
+// Text renders the digest as plain text
+func (d Digest) Text() string {
+	var b strings.Builder
+	b.WriteString(d.Title() + "\n")
+
+	b.WriteString("\nTop failing checks:\n")
+	for _, s := range d.TopFailing {
+		fmt.Fprintf(&b, "  %s: %d of %d runs failed\n", s.Key(), s.Failures, s.Runs)
+	}
+	if len(d.TopFailing) == 0 {
+		b.WriteString("  None\n")
+	}
+
+	b.WriteString("\nFlakiest checks:\n")
+	for _, s := range d.Flakiest {
+		fmt.Fprintf(&b, "  %s: changed state %d times in %d runs\n", s.Key(), s.Flips, s.Runs)
+	}
+	if len(d.Flakiest) == 0 {
+		b.WriteString("  None\n")
+	}
+
+	b.WriteString("\nNew checks:\n")
+	for _, s := range d.NewChecks {
+		fmt.Fprintf(&b, "  %s\n", s.Key())
+	}
+	if len(d.NewChecks) == 0 {
+		b.WriteString("  None\n")
+	}
+
+	b.WriteString("\nUptime per suite:\n")
+	for _, s := range d.Suites {
+		fmt.Fprintf(&b, "  %s: %.2f%% of %d runs passed across %d checks\n", s.Suite, s.Uptime*100, s.Runs, s.Checks)
+	}
+	if len(d.Suites) == 0 {
+		b.WriteString("  None\n")
+	}
+	return b.String()
+}
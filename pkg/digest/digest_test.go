@@ -0,0 +1,148 @@
+package digest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+)
+
+// testHistory makes a run history from a string of results, one per hour ending an hour before end.  s is a
+// success, f a failure and c a canceled run.
+func testHistory(results string, end time.Time) []khstatev1.RunHistoryEntry {
+	var history []khstatev1.RunHistoryEntry
+	for i, r := range results {
+		t := metav1.NewTime(end.Add(-time.Hour * time.Duration(len(results)-i)))
+		entry := khstatev1.RunHistoryEntry{Time: &t}
+		switch r {
+		case 's':
+			entry.Result = khstatev1.RunSuccess
+		case 'f':
+			entry.Result = khstatev1.RunFailure
+		case 'c':
+			entry.Result = khstatev1.RunCanceled
+		}
+		history = append(history, entry)
+	}
+	return history
+}
+
+// TestBuild ensures digests pick the failing, flaky and new checks and work out the uptime of each suite
+func TestBuild(t *testing.T) {
+	end := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	old := end.AddDate(0, -1, 0)
+	suite := map[string]string{DefaultSuiteLabel: "core"}
+
+	// runs from before the period are left out
+	outside := testHistory("ffff", end.Add(-time.Hour*24))
+
+	checks := []Check{
+		{Name: "dns", Namespace: "kuberhealthy", Labels: suite, Created: old, History: testHistory("ssssssss", end)},
+		{Name: "deployment", Namespace: "kuberhealthy", Labels: suite, Created: old, History: testHistory("sfsfsfss", end)},
+		{Name: "pod-restarts", Namespace: "kuberhealthy", Labels: suite, Created: old, History: append(outside, testHistory("ffffffcf", end)...)},
+		{Name: "api", Namespace: "payments", Created: end.Add(-time.Hour * 3), History: testHistory("fss", end)},
+		{Name: "idle", Namespace: "search", Created: old},
+	}
+
+	d := Build(Config{Schedule: ScheduleDaily, TopChecks: 2}, checks, end)
+	if !d.Start.Equal(end.Add(-time.Hour*24)) || !d.End.Equal(end) {
+		t.Fatalf("digest covers %v to %v", d.Start, d.End)
+	}
+
+	var topFailing []string
+	for _, s := range d.TopFailing {
+		topFailing = append(topFailing, s.Key())
+	}
+	if strings.Join(topFailing, ",") != "kuberhealthy/pod-restarts,kuberhealthy/deployment" {
+		t.Fatalf("top failing checks %v", topFailing)
+	}
+	if d.TopFailing[0].Failures != 7 || d.TopFailing[0].Runs != 7 {
+		t.Fatalf("pod-restarts should have failed 7 of 7 runs, got %+v", d.TopFailing[0])
+	}
+
+	var flakiest []string
+	for _, s := range d.Flakiest {
+		flakiest = append(flakiest, s.Key())
+	}
+	if strings.Join(flakiest, ",") != "kuberhealthy/deployment,payments/api" {
+		t.Fatalf("flakiest checks %v", flakiest)
+	}
+	if d.Flakiest[0].Flips != 6 {
+		t.Fatalf("deployment should have changed state 6 times, got %d", d.Flakiest[0].Flips)
+	}
+
+	if len(d.NewChecks) != 1 || d.NewChecks[0].Key() != "payments/api" {
+		t.Fatalf("new checks %+v", d.NewChecks)
+	}
+
+	expectedSuites := []SuiteUptime{
+		{Suite: "core", Checks: 3, Runs: 23, Successes: 13, Uptime: 13.0 / 23.0},
+		{Suite: "payments", Checks: 1, Runs: 3, Successes: 2, Uptime: 2.0 / 3.0},
+		{Suite: "search", Checks: 1, Runs: 0, Successes: 0, Uptime: 1},
+	}
+	if len(d.Suites) != len(expectedSuites) {
+		t.Fatalf("suites %+v", d.Suites)
+	}
+	for i := range expectedSuites {
+		if d.Suites[i] != expectedSuites[i] {
+			t.Fatalf("suite %+v but expected %+v", d.Suites[i], expectedSuites[i])
+		}
+	}
+
+	text := d.Text()
+	for _, expected := range []string{
+		"Kuberhealthy daily digest for 2026-10-15 to 2026-10-16",
+		"kuberhealthy/pod-restarts: 7 of 7 runs failed",
+		"kuberhealthy/deployment: changed state 6 times in 8 runs",
+		"  payments/api\n",
+		"core: 56.52% of 23 runs passed across 3 checks",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Fatalf("digest text is missing %q:\n%s", expected, text)
+		}
+	}
+}
+
+// TestNextSend ensures daily digests are due at midnight UTC and weekly digests at midnight UTC on Mondays
+func TestNextSend(t *testing.T) {
+	var testCases = []struct {
+		schedule string
+		now      time.Time
+		expected time.Time
+	}{
+		{ScheduleDaily, time.Date(2026, 10, 16, 13, 30, 0, 0, time.UTC), time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)},
+		{ScheduleDaily, time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)},
+		{ScheduleWeekly, time.Date(2026, 10, 16, 13, 30, 0, 0, time.UTC), time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{ScheduleWeekly, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 26, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range testCases {
+		next := NextSend(tc.schedule, tc.now)
+		if !next.Equal(tc.expected) {
+			t.Fatalf("next %s digest after %v is %v but expected %v", tc.schedule, tc.now, next, tc.expected)
+		}
+	}
+}
+
+// TestValidate ensures digest configs need a known schedule and a destination
+func TestValidate(t *testing.T) {
+	var testCases = []struct {
+		description string
+		config      Config
+		expectError bool
+	}{
+		{"slack", Config{Schedule: ScheduleDaily, SlackWebhookURL: "https://hooks.slack.com/services/x"}, false},
+		{"email", Config{Schedule: ScheduleWeekly, Email: EmailConfig{SMTPAddress: "smtp.example.com:587", From: "kh@example.com", To: []string{"team@example.com"}}}, false},
+		{"unknown schedule", Config{Schedule: "hourly", SlackWebhookURL: "https://hooks.slack.com/services/x"}, true},
+		{"no destination", Config{Schedule: ScheduleDaily}, true},
+		{"email without recipients", Config{Schedule: ScheduleDaily, Email: EmailConfig{SMTPAddress: "smtp.example.com:587", From: "kh@example.com"}}, true},
+	}
+	for _, tc := range testCases {
+		err := tc.config.Validate()
+		if (err != nil) != tc.expectError {
+			t.Fatalf("%s: got error %v", tc.description, err)
+		}
+	}
+}
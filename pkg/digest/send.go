@@ -0,0 +1,101 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// sendTimeout is how long delivering a digest to a single destination may take
+const sendTimeout = time.Second * 30
+
+// sendMail sends email.  It is swapped out in tests.
+var sendMail = smtp.SendMail
+
+// slackMessage is the payload posted to Slack incoming webhooks
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// Send delivers the digest to every destination in the config.  The errors from destinations that could not be
+// reached are returned, so a failing destination does not keep the digest from the others.
+func Send(ctx context.Context, c Config, d Digest) []error {
+	var errs []error
+	if len(c.SlackWebhookURL) > 0 {
+		err := sendSlack(ctx, http.DefaultClient, c.SlackWebhookURL, d)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(c.Email.SMTPAddress) > 0 {
+		err := sendEmail(c.Email, d)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// sendSlack posts the digest to a Slack incoming webhook
+func sendSlack(ctx context.Context, client *http.Client, webhookURL string, d Digest) error {
+	b, err := json.Marshal(slackMessage{Text: "```" + d.Text() + "```"})
+	if err != nil {
+		return fmt.Errorf("failed to marshal slack digest: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to create slack digest request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post digest to slack: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack answered the digest with status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sendEmail sends the digest by email.  The SMTP server is authenticated to when a username is set.
+func sendEmail(c EmailConfig, d Digest) error {
+	var auth smtp.Auth
+	if len(c.Username) > 0 {
+		host, _, err := net.SplitHostPort(c.SMTPAddress)
+		if err != nil {
+			return fmt.Errorf("email smtpAddress %q must look like host:port: %w", c.SMTPAddress, err)
+		}
+		auth = smtp.PlainAuth("", c.Username, c.Password, host)
+	}
+
+	err := sendMail(c.SMTPAddress, auth, c.From, c.To, emailMessage(c, d))
+	if err != nil {
+		return fmt.Errorf("failed to email digest: %w", err)
+	}
+	return nil
+}
+
+// emailMessage writes the digest as a plain text email
+func emailMessage(c EmailConfig, d Digest) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", c.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(c.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", d.Title())
+	fmt.Fprintf(&b, "Date: %s\r\n", d.End.UTC().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(d.Text(), "\n", "\r\n"))
+	return b.Bytes()
+}
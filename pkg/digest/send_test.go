@@ -0,0 +1,64 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSend ensures digests are posted to slack and emailed, and that a failing destination does not keep the
+// digest from the others
+func TestSend(t *testing.T) {
+	var posted slackMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewDecoder(r.Body).Decode(&posted)
+		if err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var mailedTo []string
+	var mailed string
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		if addr == "down.example.com:25" {
+			return errors.New("connection refused")
+		}
+		mailedTo = to
+		mailed = string(msg)
+		return nil
+	}
+	defer func() { sendMail = smtp.SendMail }()
+
+	d := Digest{Schedule: ScheduleWeekly, Start: time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), End: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)}
+	c := Config{
+		Schedule:        ScheduleWeekly,
+		SlackWebhookURL: server.URL,
+		Email:           EmailConfig{SMTPAddress: "smtp.example.com:587", Username: "kh", Password: "secret", From: "kh@example.com", To: []string{"a@example.com", "b@example.com"}},
+	}
+	errs := Send(context.Background(), c, d)
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if !strings.Contains(posted.Text, "Kuberhealthy weekly digest for 2026-10-12 to 2026-10-19") {
+		t.Fatalf("slack got %q", posted.Text)
+	}
+	if len(mailedTo) != 2 || !strings.Contains(mailed, "Subject: Kuberhealthy weekly digest for 2026-10-12 to 2026-10-19\r\n") || !strings.Contains(mailed, "To: a@example.com, b@example.com\r\n") {
+		t.Fatalf("mailed to %v:\n%s", mailedTo, mailed)
+	}
+
+	// slack still gets the digest when email fails
+	posted = slackMessage{}
+	c.Email.SMTPAddress = "down.example.com:25"
+	errs = Send(context.Background(), c, d)
+	if len(errs) != 1 || len(posted.Text) == 0 {
+		t.Fatalf("expected only the email to fail, got %v", errs)
+	}
+}
@@ -0,0 +1,333 @@
+// Package discovery generates khchecks from annotations on services and ingresses, so that app teams can opt into
+// synthetic probing without writing khcheck manifests.  A service or ingress is probed when it has either of these
+// annotations:
+//
+//   - kuberhealthy.io/probe-path: the path of an HTTP probe, such as /healthz
+//   - kuberhealthy.io/probe-type: http or tcp
+//
+// The khcheck of an annotated object is named after it and lives in its namespace.  It is owned by the object, so it
+// is garbage collected along with it.  Khprobes are expanded the same way into a khcheck for each service or pod that
+// their selector matches.
+package discovery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/duration"
+)
+
+// The annotations that opt a service or ingress into probing and configure the probe
+const (
+	ProbePathAnnotation           = "kuberhealthy.io/probe-path"            // the path of an HTTP probe
+	ProbeTypeAnnotation           = "kuberhealthy.io/probe-type"            // http or tcp, http when a path is set
+	ProbePortAnnotation           = "kuberhealthy.io/probe-port"            // the service port to probe, by name or number
+	ProbeSchemeAnnotation         = "kuberhealthy.io/probe-scheme"          // http or https
+	ProbeIntervalAnnotation       = "kuberhealthy.io/probe-interval"        // the run interval of the khcheck
+	ProbeTimeoutAnnotation        = "kuberhealthy.io/probe-timeout"         // the timeout of the khcheck
+	ProbeExpectedStatusAnnotation = "kuberhealthy.io/probe-expected-status" // the status code an HTTP probe expects
+)
+
+// DiscoveredLabel is set to "true" on every khcheck generated from annotations
+const DiscoveredLabel = "kuberhealthy.io/discovered"
+
+// SourceAnnotation is set on generated khchecks to the kind and name of the object they were generated from.  The
+// object is also named in the targets annotation of the khcheck, so results can be exported to it.
+const SourceAnnotation = "kuberhealthy.io/discovered-from"
+
+// SpecHashAnnotation is set on generated khchecks to the hash of their spec, so that changes are detected without
+// comparing specs that the API server may have filled in
+const SpecHashAnnotation = "kuberhealthy.io/discovery-hash"
+
+// The kinds of probes
+const (
+	ProbeHTTP = "http"
+	ProbeTCP  = "tcp"
+)
+
+// maxNameLength keeps the names of generated khchecks short enough for the names and labels of their checker pods
+const maxNameLength = 50
+
+// Options are the defaults of generated khchecks
+type Options struct {
+	HTTPImage       string // the image of HTTP probes
+	TCPImage        string // the image of TCP probes
+	DefaultInterval string // the run interval of probes that do not set one
+	DefaultTimeout  string // the timeout of probes that do not set one
+}
+
+// DefaultOptions returns the options used when none are configured
+func DefaultOptions() Options {
+	return Options{
+		HTTPImage:       "kuberhealthy/http-check:v1.5.0",
+		TCPImage:        "kuberhealthy/network-connection-check:v0.2.0",
+		DefaultInterval: "5m",
+		DefaultTimeout:  "2m",
+	}
+}
+
+// probe is what the annotations of an object ask for
+type probe struct {
+	kind           string
+	path           string
+	port           string
+	scheme         string
+	interval       string
+	timeout        string
+	expectedStatus string
+}
+
+// Annotated tells if an object has opted into probing
+func Annotated(annotations map[string]string) bool {
+	_, path := annotations[ProbePathAnnotation]
+	_, kind := annotations[ProbeTypeAnnotation]
+	return path || kind
+}
+
+// parseProbe reads and validates the probe annotations of an object
+func parseProbe(annotations map[string]string, opts Options) (probe, error) {
+	p := probe{
+		path:           annotations[ProbePathAnnotation],
+		kind:           strings.ToLower(annotations[ProbeTypeAnnotation]),
+		port:           annotations[ProbePortAnnotation],
+		scheme:         strings.ToLower(annotations[ProbeSchemeAnnotation]),
+		interval:       annotations[ProbeIntervalAnnotation],
+		timeout:        annotations[ProbeTimeoutAnnotation],
+		expectedStatus: annotations[ProbeExpectedStatusAnnotation],
+	}
+	return p.complete(opts)
+}
+
+// complete fills in the defaults of a probe and validates it
+func (p probe) complete(opts Options) (probe, error) {
+	if len(p.kind) == 0 {
+		p.kind = ProbeHTTP
+	}
+	switch p.kind {
+	case ProbeHTTP:
+		if len(p.path) == 0 {
+			p.path = "/"
+		}
+		if !strings.HasPrefix(p.path, "/") {
+			return probe{}, fmt.Errorf("probe path %q must start with /", p.path)
+		}
+	case ProbeTCP:
+		if len(p.path) > 0 {
+			return probe{}, fmt.Errorf("probe path can not be set on a tcp probe")
+		}
+	default:
+		return probe{}, fmt.Errorf("probe type %q must be http or tcp", p.kind)
+	}
+	if len(p.scheme) > 0 && p.scheme != "http" && p.scheme != "https" {
+		return probe{}, fmt.Errorf("probe scheme %q must be http or https", p.scheme)
+	}
+	if len(p.expectedStatus) > 0 {
+		code, err := strconv.Atoi(p.expectedStatus)
+		if err != nil || code < 100 || code > 599 {
+			return probe{}, fmt.Errorf("expected status %q must be an HTTP status code", p.expectedStatus)
+		}
+	}
+
+	if len(p.interval) == 0 {
+		p.interval = opts.DefaultInterval
+	}
+	if len(p.timeout) == 0 {
+		p.timeout = opts.DefaultTimeout
+	}
+	for name, value := range map[string]string{"probe interval": p.interval, "probe timeout": p.timeout} {
+		d, err := duration.Parse(value)
+		if err != nil || d <= 0 {
+			return probe{}, fmt.Errorf("%s %q must be a positive duration", name, value)
+		}
+	}
+	return p, nil
+}
+
+// ForService generates the khcheck that an annotated service asks for.  Nil is returned for services that are not
+// annotated.
+func ForService(svc corev1.Service, opts Options) (*khcheckv1.KuberhealthyCheck, error) {
+	if !Annotated(svc.Annotations) {
+		return nil, nil
+	}
+	p, err := parseProbe(svc.Annotations, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := serviceAddress(svc, &p)
+	if err != nil {
+		return nil, err
+	}
+	owner := ownerReference(corev1.SchemeGroupVersion.String(), "Service", svc.ObjectMeta, false)
+	labels := map[string]string{DiscoveredLabel: "true"}
+	return newCheck(CheckName("svc", svc.Name), svc.Namespace, labels, "Service/"+svc.Name, owner, p, address, opts), nil
+}
+
+// serviceAddress is the cluster DNS name and port of a service that a probe connects to.  The scheme of http probes
+// that do not set one is picked from the name or number of the port.
+func serviceAddress(svc corev1.Service, p *probe) (string, error) {
+	if len(svc.Spec.Ports) == 0 {
+		return "", fmt.Errorf("service has no ports to probe")
+	}
+
+	port := svc.Spec.Ports[0]
+	if len(p.port) > 0 {
+		var found bool
+		for _, sp := range svc.Spec.Ports {
+			if sp.Name == p.port || strconv.Itoa(int(sp.Port)) == p.port {
+				port, found = sp, true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("service has no port %s", p.port)
+		}
+	}
+	if p.kind == ProbeHTTP && len(p.scheme) == 0 {
+		p.scheme = "http"
+		if port.Port == 443 || port.Name == "https" {
+			p.scheme = "https"
+		}
+	}
+
+	host := svc.Name + "." + svc.Namespace + ".svc"
+	return net.JoinHostPort(host, strconv.Itoa(int(port.Port))), nil
+}
+
+// ForIngress generates the khcheck that an annotated ingress asks for.  The first host of the ingress is probed over
+// HTTPS when the ingress terminates TLS for it.  Nil is returned for ingresses that are not annotated.
+func ForIngress(ing networkingv1.Ingress, opts Options) (*khcheckv1.KuberhealthyCheck, error) {
+	if !Annotated(ing.Annotations) {
+		return nil, nil
+	}
+	p, err := parseProbe(ing.Annotations, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var host string
+	for _, rule := range ing.Spec.Rules {
+		if len(rule.Host) > 0 && !strings.HasPrefix(rule.Host, "*") {
+			host = rule.Host
+			break
+		}
+	}
+	if len(host) == 0 {
+		return nil, fmt.Errorf("ingress has no host to probe")
+	}
+
+	tls := false
+	for _, t := range ing.Spec.TLS {
+		for _, h := range t.Hosts {
+			if h == host {
+				tls = true
+			}
+		}
+	}
+	if len(p.scheme) == 0 {
+		p.scheme = "http"
+		if tls {
+			p.scheme = "https"
+		}
+	}
+
+	port := p.port
+	if len(port) == 0 {
+		port = "80"
+		if p.scheme == "https" {
+			port = "443"
+		}
+	}
+	if _, err := strconv.Atoi(port); err != nil {
+		return nil, fmt.Errorf("probe port %q must be a number on an ingress", port)
+	}
+	owner := ownerReference(networkingv1.SchemeGroupVersion.String(), "Ingress", ing.ObjectMeta, false)
+	labels := map[string]string{DiscoveredLabel: "true"}
+	return newCheck(CheckName("ing", ing.Name), ing.Namespace, labels, "Ingress/"+ing.Name, owner, p, net.JoinHostPort(host, port), opts), nil
+}
+
+// newCheck builds the khcheck of a probe of the supplied address.  The source names the object probed.
+func newCheck(name string, namespace string, labels map[string]string, source string, owner metav1.OwnerReference, p probe, address string, opts Options) *khcheckv1.KuberhealthyCheck {
+	container := corev1.Container{
+		Name:            "main",
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("10m"),
+			corev1.ResourceMemory: resource.MustParse("20Mi"),
+		}},
+	}
+	switch p.kind {
+	case ProbeHTTP:
+		container.Image = opts.HTTPImage
+		container.Env = []corev1.EnvVar{{Name: "CHECK_URL", Value: p.scheme + "://" + address + p.path}}
+		if len(p.expectedStatus) > 0 {
+			container.Env = append(container.Env, corev1.EnvVar{Name: "EXPECTED_STATUS_CODE", Value: p.expectedStatus})
+		}
+	case ProbeTCP:
+		container.Image = opts.TCPImage
+		container.Env = []corev1.EnvVar{
+			{Name: "CONNECTION_TARGET", Value: "tcp://" + address},
+			{Name: "CONNECTION_TARGET_UNREACHABLE", Value: "false"},
+		}
+	}
+
+	spec := khcheckv1.CheckConfig{
+		RunInterval: p.interval,
+		Timeout:     p.timeout,
+		PodSpec: corev1.PodSpec{
+			Containers:    []corev1.Container{container},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+
+	check := khcheckv1.NewKuberhealthyCheck(name, namespace, spec)
+	check.Labels = labels
+	check.Annotations = map[string]string{
+		SourceAnnotation:            source,
+		SpecHashAnnotation:          SpecHash(spec),
+		khcheckv1.TargetsAnnotation: source,
+	}
+	check.OwnerReferences = []metav1.OwnerReference{owner}
+	return &check
+}
+
+// ownerReference refers to the object that a khcheck is generated for
+func ownerReference(apiVersion string, kind string, owner metav1.ObjectMeta, controller bool) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Name:       owner.Name,
+		UID:        owner.UID,
+		Controller: &controller,
+	}
+}
+
+// CheckName is the name of the khcheck generated from an object.  Long names are shortened and made unique with a
+// hash of the full name.
+func CheckName(prefix string, name string) string {
+	full := prefix + "-" + name + "-probe"
+	if len(full) <= maxNameLength {
+		return full
+	}
+	sum := sha256.Sum256([]byte(full))
+	short := strings.TrimRight(full[:maxNameLength-9], "-.")
+	return short + "-" + hex.EncodeToString(sum[:])[:8]
+}
+
+// SpecHash is the hash of a khcheck spec
+func SpecHash(spec khcheckv1.CheckConfig) string {
+	b, _ := json.Marshal(spec)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])[:16]
+}
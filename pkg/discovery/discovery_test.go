@@ -0,0 +1,226 @@
+package discovery
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+)
+
+// env returns the environment variables of the container of a khcheck
+func env(check *khcheckv1.KuberhealthyCheck) map[string]string {
+	vars := make(map[string]string)
+	for _, e := range check.Spec.PodSpec.Containers[0].Env {
+		vars[e.Name] = e.Value
+	}
+	return vars
+}
+
+func TestForService(t *testing.T) {
+	service := func(annotations map[string]string, ports ...corev1.ServicePort) corev1.Service {
+		return corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "uid-1", Annotations: annotations},
+			Spec:       corev1.ServiceSpec{Ports: ports},
+		}
+	}
+	http := corev1.ServicePort{Name: "http", Port: 8080}
+	https := corev1.ServicePort{Name: "https", Port: 443}
+
+	var testCases = []struct {
+		name     string
+		svc      corev1.Service
+		image    string
+		env      map[string]string
+		interval string
+		err      string
+	}{
+		{
+			name: "not annotated",
+			svc:  service(nil, http),
+		},
+		{
+			name:     "probe path on the first port",
+			svc:      service(map[string]string{ProbePathAnnotation: "/healthz"}, http, https),
+			image:    "kuberhealthy/http-check:v1.5.0",
+			env:      map[string]string{"CHECK_URL": "http://web.shop.svc:8080/healthz"},
+			interval: "5m",
+		},
+		{
+			name: "named https port with an expected status",
+			svc: service(map[string]string{
+				ProbePathAnnotation:           "/ready",
+				ProbePortAnnotation:           "https",
+				ProbeIntervalAnnotation:       "30s",
+				ProbeExpectedStatusAnnotation: "204",
+			}, http, https),
+			image:    "kuberhealthy/http-check:v1.5.0",
+			env:      map[string]string{"CHECK_URL": "https://web.shop.svc:443/ready", "EXPECTED_STATUS_CODE": "204"},
+			interval: "30s",
+		},
+		{
+			name:     "tcp probe",
+			svc:      service(map[string]string{ProbeTypeAnnotation: "tcp", ProbePortAnnotation: "8080"}, https, http),
+			image:    "kuberhealthy/network-connection-check:v0.2.0",
+			env:      map[string]string{"CONNECTION_TARGET": "tcp://web.shop.svc:8080", "CONNECTION_TARGET_UNREACHABLE": "false"},
+			interval: "5m",
+		},
+		{
+			name: "unknown port",
+			svc:  service(map[string]string{ProbePathAnnotation: "/", ProbePortAnnotation: "grpc"}, http),
+			err:  "service has no port grpc",
+		},
+		{
+			name: "no ports",
+			svc:  service(map[string]string{ProbePathAnnotation: "/"}),
+			err:  "service has no ports to probe",
+		},
+		{
+			name: "relative path",
+			svc:  service(map[string]string{ProbePathAnnotation: "healthz"}, http),
+			err:  "must start with /",
+		},
+		{
+			name: "unknown type",
+			svc:  service(map[string]string{ProbeTypeAnnotation: "grpc"}, http),
+			err:  "must be http or tcp",
+		},
+		{
+			name: "bad interval",
+			svc:  service(map[string]string{ProbePathAnnotation: "/", ProbeIntervalAnnotation: "often"}, http),
+			err:  "must be a positive duration",
+		},
+		{
+			name: "bad status",
+			svc:  service(map[string]string{ProbePathAnnotation: "/", ProbeExpectedStatusAnnotation: "ok"}, http),
+			err:  "must be an HTTP status code",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			check, err := ForService(tc.svc, DefaultOptions())
+			if len(tc.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to generate khcheck: %s", err)
+			}
+			if tc.env == nil {
+				if check != nil {
+					t.Fatalf("expected no khcheck for a service that is not annotated, got %+v", check)
+				}
+				return
+			}
+
+			if check.Name != "svc-web-probe" || check.Namespace != "shop" || check.Spec.RunInterval != tc.interval {
+				t.Fatalf("khcheck was generated wrong: %s/%s every %s", check.Namespace, check.Name, check.Spec.RunInterval)
+			}
+			if check.Spec.PodSpec.Containers[0].Image != tc.image {
+				t.Fatalf("expected image %s, got %s", tc.image, check.Spec.PodSpec.Containers[0].Image)
+			}
+			vars := env(check)
+			if len(vars) != len(tc.env) {
+				t.Fatalf("expected env %v, got %v", tc.env, vars)
+			}
+			for k, v := range tc.env {
+				if vars[k] != v {
+					t.Fatalf("expected env %v, got %v", tc.env, vars)
+				}
+			}
+			if check.Labels[DiscoveredLabel] != "true" || check.Annotations[SourceAnnotation] != "Service/web" || check.Annotations[khcheckv1.TargetsAnnotation] != "Service/web" || check.Annotations[SpecHashAnnotation] != SpecHash(check.Spec) {
+				t.Fatalf("khcheck is not marked as discovered: %v %v", check.Labels, check.Annotations)
+			}
+			owner := check.OwnerReferences[0]
+			if owner.Kind != "Service" || owner.APIVersion != "v1" || owner.UID != "uid-1" {
+				t.Fatalf("khcheck is not owned by the service: %+v", owner)
+			}
+		})
+	}
+}
+
+func TestForIngress(t *testing.T) {
+	ingress := func(annotations map[string]string, hosts []string, tlsHosts []string) networkingv1.Ingress {
+		ing := networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "web", Annotations: annotations}}
+		for _, h := range hosts {
+			ing.Spec.Rules = append(ing.Spec.Rules, networkingv1.IngressRule{Host: h})
+		}
+		if len(tlsHosts) > 0 {
+			ing.Spec.TLS = []networkingv1.IngressTLS{{Hosts: tlsHosts}}
+		}
+		return ing
+	}
+
+	var testCases = []struct {
+		name string
+		ing  networkingv1.Ingress
+		url  string
+		err  string
+	}{
+		{
+			name: "plain http",
+			ing:  ingress(map[string]string{ProbePathAnnotation: "/healthz"}, []string{"shop.example.com"}, nil),
+			url:  "http://shop.example.com:80/healthz",
+		},
+		{
+			name: "tls host skips wildcards",
+			ing:  ingress(map[string]string{ProbePathAnnotation: "/"}, []string{"*.example.com", "shop.example.com"}, []string{"shop.example.com"}),
+			url:  "https://shop.example.com:443/",
+		},
+		{
+			name: "explicit scheme and port",
+			ing:  ingress(map[string]string{ProbePathAnnotation: "/", ProbeSchemeAnnotation: "http", ProbePortAnnotation: "8080"}, []string{"shop.example.com"}, []string{"shop.example.com"}),
+			url:  "http://shop.example.com:8080/",
+		},
+		{
+			name: "no host",
+			ing:  ingress(map[string]string{ProbePathAnnotation: "/"}, []string{""}, nil),
+			err:  "ingress has no host to probe",
+		},
+		{
+			name: "named port",
+			ing:  ingress(map[string]string{ProbePathAnnotation: "/", ProbePortAnnotation: "http"}, []string{"shop.example.com"}, nil),
+			err:  "must be a number on an ingress",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			check, err := ForIngress(tc.ing, DefaultOptions())
+			if len(tc.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to generate khcheck: %s", err)
+			}
+			if check.Name != "ing-shop-probe" || env(check)["CHECK_URL"] != tc.url {
+				t.Fatalf("expected khcheck ing-shop-probe to probe %s, got %s probing %s", tc.url, check.Name, env(check)["CHECK_URL"])
+			}
+			if check.OwnerReferences[0].APIVersion != "networking.k8s.io/v1" || check.Annotations[SourceAnnotation] != "Ingress/shop" {
+				t.Fatalf("khcheck is not owned by the ingress: %+v", check.OwnerReferences[0])
+			}
+		})
+	}
+}
+
+func TestCheckName(t *testing.T) {
+	if name := CheckName("svc", "web"); name != "svc-web-probe" {
+		t.Fatalf("expected a short name to be kept, got %s", name)
+	}
+
+	long := strings.Repeat("a", 60)
+	name := CheckName("svc", long)
+	other := CheckName("svc", long+"b")
+	if len(name) > maxNameLength || name == other || !strings.HasPrefix(name, "svc-aaa") {
+		t.Fatalf("expected long names to be shortened and kept unique, got %s and %s", name, other)
+	}
+}
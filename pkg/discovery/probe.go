@@ -0,0 +1,140 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+	khprobev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khprobe/v1"
+)
+
+// ProbeLabel is set on khchecks generated from a khprobe to the name of the khprobe
+const ProbeLabel = "kuberhealthy.io/khprobe"
+
+// probeAPIVersion is the API version of khprobes, which own the khchecks generated from them
+const probeAPIVersion = "comcast.github.io/v1"
+
+// Expansion holds the khchecks a khprobe expands into and the targets that could not be probed
+type Expansion struct {
+	Checks  []khcheckv1.KuberhealthyCheck
+	Targets []string // the names of the targets probed
+	Errors  []string // why targets could not be probed
+}
+
+// ForProbe expands a khprobe into a khcheck for each of its targets.  The targets are the services or pods that the
+// selector of the khprobe matched, depending on its target kind.  Pods that are not running are skipped, so their
+// khcheck is removed until they are running again.
+func ForProbe(kp khprobev1.KuberhealthyProbe, services []corev1.Service, pods []corev1.Pod, opts Options) (Expansion, error) {
+	p, err := probeConfig(kp.Spec).complete(opts)
+	if err != nil {
+		return Expansion{}, err
+	}
+
+	owner := ownerReference(probeAPIVersion, "KuberhealthyProbe", kp.ObjectMeta, true)
+	labels := map[string]string{ProbeLabel: kp.Name}
+	var exp Expansion
+	add := func(kind string, name string, address string, p probe) {
+		check := newCheck(CheckName(kp.Name, name), kp.Namespace, labels, kind+"/"+name, owner, p, address, opts)
+		exp.Checks = append(exp.Checks, *check)
+		exp.Targets = append(exp.Targets, name)
+	}
+
+	switch targetKind(kp.Spec) {
+	case khprobev1.TargetService:
+		for _, svc := range services {
+			target := p
+			address, err := serviceAddress(svc, &target)
+			if err != nil {
+				exp.Errors = append(exp.Errors, "Service/"+svc.Name+": "+err.Error())
+				continue
+			}
+			add("Service", svc.Name, address, target)
+		}
+	case khprobev1.TargetPod:
+		for _, pod := range pods {
+			if pod.Status.Phase != corev1.PodRunning || len(pod.Status.PodIP) == 0 || pod.DeletionTimestamp != nil {
+				continue
+			}
+			target := p
+			address, err := podAddress(pod, &target)
+			if err != nil {
+				exp.Errors = append(exp.Errors, "Pod/"+pod.Name+": "+err.Error())
+				continue
+			}
+			add("Pod", pod.Name, address, target)
+		}
+	default:
+		return Expansion{}, fmt.Errorf("target kind %q must be Service or Pod", kp.Spec.TargetKind)
+	}
+	return exp, nil
+}
+
+// probeConfig converts the spec of a khprobe into the probe of each of its targets
+func probeConfig(spec khprobev1.ProbeConfig) probe {
+	p := probe{
+		kind:     strings.ToLower(spec.Type),
+		path:     spec.Path,
+		scheme:   strings.ToLower(spec.Scheme),
+		interval: spec.RunInterval,
+		timeout:  spec.Timeout,
+	}
+	if spec.Port != (intstr.IntOrString{}) {
+		p.port = spec.Port.String()
+	}
+	if spec.ExpectedStatus != 0 {
+		p.expectedStatus = strconv.Itoa(spec.ExpectedStatus)
+	}
+	return p
+}
+
+// targetKind is the kind of the targets of a khprobe, which defaults to services
+func targetKind(spec khprobev1.ProbeConfig) khprobev1.TargetKind {
+	if len(spec.TargetKind) == 0 {
+		return khprobev1.TargetService
+	}
+	return spec.TargetKind
+}
+
+// podAddress is the IP and port of a pod that a probe connects to.  Named ports are looked up in the containers of
+// the pod, while numbered ports are used as they are, since containers need not declare the ports they listen on.
+func podAddress(pod corev1.Pod, p *probe) (string, error) {
+	var port *corev1.ContainerPort
+	for _, c := range pod.Spec.Containers {
+		for i := range c.Ports {
+			cp := &c.Ports[i]
+			if len(p.port) == 0 || cp.Name == p.port || strconv.Itoa(int(cp.ContainerPort)) == p.port {
+				port = cp
+				break
+			}
+		}
+		if port != nil {
+			break
+		}
+	}
+
+	var number string
+	switch {
+	case port != nil:
+		number = strconv.Itoa(int(port.ContainerPort))
+	case len(p.port) == 0:
+		return "", fmt.Errorf("pod declares no ports to probe")
+	default:
+		if _, err := strconv.Atoi(p.port); err != nil {
+			return "", fmt.Errorf("pod has no port %s", p.port)
+		}
+		number = p.port
+	}
+
+	if p.kind == ProbeHTTP && len(p.scheme) == 0 {
+		p.scheme = "http"
+		if number == "443" || (port != nil && port.Name == "https") {
+			p.scheme = "https"
+		}
+	}
+	return net.JoinHostPort(pod.Status.PodIP, number), nil
+}
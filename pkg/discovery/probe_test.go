@@ -0,0 +1,113 @@
+package discovery
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	khprobev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khprobe/v1"
+)
+
+func TestForProbe(t *testing.T) {
+	pod := func(name string, ip string, phase corev1.PodPhase, ports ...corev1.ContainerPort) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Ports: ports}}},
+			Status:     corev1.PodStatus{Phase: phase, PodIP: ip},
+		}
+	}
+	metrics := corev1.ContainerPort{Name: "metrics", ContainerPort: 9090}
+	web := corev1.ContainerPort{Name: "web", ContainerPort: 8080}
+	deleted := pod("web-4", "10.0.0.4", corev1.PodRunning, web)
+	deleted.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	pods := []corev1.Pod{
+		pod("web-1", "10.0.0.1", corev1.PodRunning, metrics, web),
+		pod("web-2", "", corev1.PodPending, web),
+		pod("web-3", "10.0.0.3", corev1.PodRunning, metrics),
+		deleted,
+	}
+	services := []corev1.Service{
+		{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"}, Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "https", Port: 443}}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "headless", Namespace: "shop"}},
+	}
+
+	var testCases = []struct {
+		name    string
+		spec    khprobev1.ProbeConfig
+		urls    map[string]string
+		targets []string
+		errors  []string
+		err     string
+	}{
+		{
+			name:    "services by default",
+			spec:    khprobev1.ProbeConfig{Path: "/healthz"},
+			urls:    map[string]string{"web-web-probe": "https://web.shop.svc:443/healthz"},
+			targets: []string{"web"},
+			errors:  []string{"Service/headless: service has no ports to probe"},
+		},
+		{
+			name:    "running pods by port name",
+			spec:    khprobev1.ProbeConfig{TargetKind: khprobev1.TargetPod, Port: intstr.FromString("web"), Path: "/ready"},
+			urls:    map[string]string{"web-web-1-probe": "http://10.0.0.1:8080/ready"},
+			targets: []string{"web-1"},
+			errors:  []string{"Pod/web-3: pod has no port web"},
+		},
+		{
+			name: "running pods by undeclared port number",
+			spec: khprobev1.ProbeConfig{TargetKind: khprobev1.TargetPod, Port: intstr.FromInt(8081)},
+			urls: map[string]string{
+				"web-web-1-probe": "http://10.0.0.1:8081/",
+				"web-web-3-probe": "http://10.0.0.3:8081/",
+			},
+			targets: []string{"web-1", "web-3"},
+		},
+		{
+			name:    "running pods on their first port",
+			spec:    khprobev1.ProbeConfig{TargetKind: khprobev1.TargetPod, Type: "tcp"},
+			targets: []string{"web-1", "web-3"},
+		},
+		{
+			name: "invalid probe",
+			spec: khprobev1.ProbeConfig{Type: "tcp", Path: "/"},
+			err:  "probe path can not be set on a tcp probe",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kp := khprobev1.NewKuberhealthyProbe("web", "shop", tc.spec)
+			kp.UID = "probe-uid"
+			exp, err := ForProbe(kp, services, pods, DefaultOptions())
+			if len(tc.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to expand khprobe: %s", err)
+			}
+			if !reflect.DeepEqual(exp.Targets, tc.targets) || !reflect.DeepEqual(exp.Errors, tc.errors) {
+				t.Fatalf("expected targets %v and errors %q, got %v and %q", tc.targets, tc.errors, exp.Targets, exp.Errors)
+			}
+			for _, check := range exp.Checks {
+				if check.Namespace != "shop" || check.Labels[ProbeLabel] != "web" || check.OwnerReferences[0].Kind != "KuberhealthyProbe" || check.OwnerReferences[0].UID != "probe-uid" {
+					t.Fatalf("khcheck %s is not owned by the khprobe: %v %+v", check.Name, check.Labels, check.OwnerReferences[0])
+				}
+				url, ok := tc.urls[check.Name]
+				if ok && env(&check)["CHECK_URL"] != url {
+					t.Fatalf("expected khcheck %s to probe %s, got %s", check.Name, url, env(&check)["CHECK_URL"])
+				}
+			}
+			if tc.spec.Type == "tcp" && env(&exp.Checks[0])["CONNECTION_TARGET"] != "tcp://10.0.0.1:9090" {
+				t.Fatalf("expected the first port of the pod to be probed, got %s", env(&exp.Checks[0])["CONNECTION_TARGET"])
+			}
+		})
+	}
+}
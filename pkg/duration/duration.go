@@ -0,0 +1,80 @@
+// Package duration parses the time durations used to configure Kuberhealthy.  Durations are written as Go and
+// Kubernetes style duration strings such as "90s", "10m" or "1h30m".  Older configurations that used a raw number of
+// seconds are still accepted.
+package duration
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parse parses a duration string such as "90s", "10m" or "1h30m".  A bare integer is treated as a number of seconds
+// so that configurations written before duration strings were supported keep working.  Negative durations are
+// rejected.
+func Parse(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return 0, errors.New("duration is empty")
+	}
+
+	// bare integers are a number of seconds
+	if seconds, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, fmt.Errorf("duration %q must not be negative", s)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: use a duration such as \"90s\", \"10m\" or \"1h30m\"", s)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("duration %q must not be negative", s)
+	}
+	return d, nil
+}
+
+// ParseOrDefault parses the supplied duration string with Parse.  Empty strings return the default duration.  When
+// parsing fails the default duration is returned along with the error.
+func ParseOrDefault(s string, defaultDuration time.Duration) (time.Duration, error) {
+	if len(strings.TrimSpace(s)) == 0 {
+		return defaultDuration, nil
+	}
+
+	d, err := Parse(s)
+	if err != nil {
+		return defaultDuration, err
+	}
+	return d, nil
+}
+
+// Duration is a time.Duration that is read from YAML configuration files with Parse.  This lets configuration files
+// use duration strings as well as a raw number of seconds.
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	err := unmarshal(&s)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalYAML implements the yaml.Marshaler interface
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.Duration.String(), nil
+}
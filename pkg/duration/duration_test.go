@@ -0,0 +1,89 @@
+package duration
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// TestParse ensures duration strings and bare seconds are both parsed
+func TestParse(t *testing.T) {
+	var testCases = []struct {
+		input    string
+		expected time.Duration
+		err      bool
+	}{
+		{"90s", 90 * time.Second, false},
+		{"10m", 10 * time.Minute, false},
+		{"1h30m", 90 * time.Minute, false},
+		{" 5m ", 5 * time.Minute, false},
+		{"600", 10 * time.Minute, false},
+		{"0", 0, false},
+		{"", 0, true},
+		{"ten minutes", 0, true},
+		{"10 m", 0, true},
+		{"-5m", 0, true},
+		{"-30", 0, true},
+	}
+
+	for _, tc := range testCases {
+		d, err := Parse(tc.input)
+		if (err != nil) != tc.err {
+			t.Fatalf("parsing %q returned error %v but expected error to be %t", tc.input, err, tc.err)
+		}
+		if d != tc.expected {
+			t.Fatalf("parsing %q returned %s but expected %s", tc.input, d, tc.expected)
+		}
+	}
+}
+
+// TestParseOrDefault ensures the default is used for empty and invalid durations
+func TestParseOrDefault(t *testing.T) {
+	var testCases = []struct {
+		input    string
+		expected time.Duration
+		err      bool
+	}{
+		{"", time.Minute, false},
+		{"2m", 2 * time.Minute, false},
+		{"bogus", time.Minute, true},
+	}
+
+	for _, tc := range testCases {
+		d, err := ParseOrDefault(tc.input, time.Minute)
+		if (err != nil) != tc.err {
+			t.Fatalf("parsing %q returned error %v but expected error to be %t", tc.input, err, tc.err)
+		}
+		if d != tc.expected {
+			t.Fatalf("parsing %q returned %s but expected %s", tc.input, d, tc.expected)
+		}
+	}
+}
+
+// TestDurationYAML ensures durations in YAML files are read as duration strings or seconds
+func TestDurationYAML(t *testing.T) {
+	var testCases = []struct {
+		input    string
+		expected time.Duration
+		err      bool
+	}{
+		{"age: 15m", 15 * time.Minute, false},
+		{"age: \"1h30m\"", 90 * time.Minute, false},
+		{"age: 900", 15 * time.Minute, false},
+		{"age: soon", 0, true},
+	}
+
+	for _, tc := range testCases {
+		var config struct {
+			Age Duration `yaml:"age"`
+		}
+		err := yaml.Unmarshal([]byte(tc.input), &config)
+		if (err != nil) != tc.err {
+			t.Fatalf("unmarshaling %q returned error %v but expected error to be %t", tc.input, err, tc.err)
+		}
+		if config.Age.Duration != tc.expected {
+			t.Fatalf("unmarshaling %q returned %s but expected %s", tc.input, config.Age.Duration, tc.expected)
+		}
+	}
+}
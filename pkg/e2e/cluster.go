@@ -0,0 +1,122 @@
+// Package e2e is a framework for end to end tests of Kuberhealthy.  It provisions a cluster, installs Kuberhealthy
+// into it and offers helpers to apply khchecks and wait for the scheduling, reporting, timeout and cleanup behaviors
+// expected of them.
+//
+// Two kinds of cluster are supported:
+//
+//   - kind clusters, which run Kuberhealthy and its checker pods from images like a real cluster does
+//   - envtest clusters, which are only an etcd and kube-apiserver started from the binaries in KUBEBUILDER_ASSETS.
+//     Nothing runs pods in an envtest cluster, so Kuberhealthy runs as a local process and checker pods never start.
+//
+// The tests using the framework live in test/e2e and are only built with the e2e build tag.
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Provider names a kind of cluster
+type Provider string
+
+// The supported cluster providers
+const (
+	ProviderKind    Provider = "kind"
+	ProviderEnvtest Provider = "envtest"
+)
+
+// Cluster is a Kubernetes cluster that end to end tests run against
+type Cluster interface {
+	// Provider returns the kind of the cluster
+	Provider() Provider
+	// KubeConfig returns the path of a kube config file with admin access to the cluster
+	KubeConfig() string
+	// RunsPods returns true when pods created in the cluster actually run
+	RunsPods() bool
+	// LoadImage makes a locally built image available to the nodes of the cluster
+	LoadImage(ctx context.Context, image string) error
+	// Delete tears the cluster down
+	Delete(ctx context.Context) error
+}
+
+// NewCluster provisions a cluster from the named provider.  Cluster state is kept in dir.
+func NewCluster(ctx context.Context, provider Provider, name string, dir string) (Cluster, error) {
+	switch provider {
+	case ProviderKind:
+		return NewKindCluster(ctx, name, dir)
+	case ProviderEnvtest:
+		return NewEnvtestCluster(ctx, dir)
+	default:
+		return nil, fmt.Errorf("unknown cluster provider %q, must be %s or %s", provider, ProviderKind, ProviderEnvtest)
+	}
+}
+
+// KindCluster is a cluster created with kind
+type KindCluster struct {
+	name       string
+	kubeConfig string
+}
+
+// NewKindCluster creates a kind cluster with the supplied name.  The kind binary must be on the PATH.  Set
+// KIND_NODE_IMAGE to choose the Kubernetes version of the cluster.
+func NewKindCluster(ctx context.Context, name string, dir string) (*KindCluster, error) {
+	c := &KindCluster{name: name, kubeConfig: filepath.Join(dir, "kubeconfig")}
+
+	args := []string{"create", "cluster", "--name", name, "--kubeconfig", c.kubeConfig, "--wait", "2m"}
+	if image := os.Getenv("KIND_NODE_IMAGE"); len(image) > 0 {
+		args = append(args, "--image", image)
+	}
+	_, err := run(ctx, "kind", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kind cluster %s: %w", name, err)
+	}
+	return c, nil
+}
+
+// Provider returns ProviderKind
+func (c *KindCluster) Provider() Provider {
+	return ProviderKind
+}
+
+// KubeConfig returns the path of the kube config file of the cluster
+func (c *KindCluster) KubeConfig() string {
+	return c.kubeConfig
+}
+
+// RunsPods returns true because kind clusters have nodes
+func (c *KindCluster) RunsPods() bool {
+	return true
+}
+
+// LoadImage loads a local docker image into the nodes of the cluster
+func (c *KindCluster) LoadImage(ctx context.Context, image string) error {
+	_, err := run(ctx, "kind", "load", "docker-image", image, "--name", c.name)
+	if err != nil {
+		return fmt.Errorf("failed to load image %s: %w", image, err)
+	}
+	return nil
+}
+
+// Delete deletes the kind cluster
+func (c *KindCluster) Delete(ctx context.Context) error {
+	_, err := run(ctx, "kind", "delete", "cluster", "--name", c.name)
+	return err
+}
+
+// run runs a command and returns its output.  Failures include the output of the command.
+func run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	if err != nil {
+		return out.Bytes(), fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(out.String()))
+	}
+	return out.Bytes(), nil
+}
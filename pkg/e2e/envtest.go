@@ -0,0 +1,239 @@
+package e2e
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// envtestToken is the bearer token of the admin user of envtest clusters
+const envtestToken = "kuberhealthy-e2e-admin"
+
+// envtestStartTimeout is how long the control plane of an envtest cluster has to become ready
+const envtestStartTimeout = time.Minute
+
+// EnvtestCluster is a control plane made of an etcd and a kube-apiserver process, like the clusters of the
+// controller-runtime envtest package.  The etcd and kube-apiserver binaries are taken from the directory in the
+// KUBEBUILDER_ASSETS environment variable, such as one set up by setup-envtest.
+type EnvtestCluster struct {
+	dir        string
+	kubeConfig string
+	etcd       *exec.Cmd
+	apiServer  *exec.Cmd
+}
+
+// NewEnvtestCluster starts etcd and kube-apiserver with their state in dir and waits for the API server to be ready
+func NewEnvtestCluster(ctx context.Context, dir string) (*EnvtestCluster, error) {
+	assets := os.Getenv("KUBEBUILDER_ASSETS")
+	if len(assets) == 0 {
+		return nil, fmt.Errorf("KUBEBUILDER_ASSETS must be set to a directory holding the etcd and kube-apiserver binaries")
+	}
+
+	c := &EnvtestCluster{dir: dir, kubeConfig: filepath.Join(dir, "kubeconfig")}
+	etcdPort, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	etcdPeerPort, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	apiPort, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+
+	etcdURL := "http://127.0.0.1:" + strconv.Itoa(etcdPort)
+	c.etcd = exec.Command(filepath.Join(assets, "etcd"),
+		"--data-dir", filepath.Join(dir, "etcd"),
+		"--listen-client-urls", etcdURL,
+		"--advertise-client-urls", etcdURL,
+		"--listen-peer-urls", "http://127.0.0.1:"+strconv.Itoa(etcdPeerPort),
+	)
+	err = c.start(c.etcd, "etcd.log")
+	if err != nil {
+		return nil, err
+	}
+
+	keyFile, err := writeServiceAccountKey(dir)
+	if err != nil {
+		c.Delete(ctx)
+		return nil, err
+	}
+	tokenFile := filepath.Join(dir, "tokens.csv")
+	err = os.WriteFile(tokenFile, []byte(envtestToken+`,admin,admin,"system:masters"`+"\n"), 0600)
+	if err != nil {
+		c.Delete(ctx)
+		return nil, fmt.Errorf("failed to write token file: %w", err)
+	}
+
+	// nothing runs the service account token controller, so pods are admitted without service accounts
+	c.apiServer = exec.Command(filepath.Join(assets, "kube-apiserver"),
+		"--etcd-servers", etcdURL,
+		"--cert-dir", filepath.Join(dir, "certs"),
+		"--bind-address", "127.0.0.1",
+		"--secure-port", strconv.Itoa(apiPort),
+		"--service-cluster-ip-range", "10.0.0.0/24",
+		"--service-account-issuer", "https://kubernetes.default.svc",
+		"--service-account-key-file", keyFile,
+		"--service-account-signing-key-file", keyFile,
+		"--token-auth-file", tokenFile,
+		"--authorization-mode", "RBAC",
+		"--disable-admission-plugins", "ServiceAccount",
+		"--allow-privileged=true",
+	)
+	err = c.start(c.apiServer, "kube-apiserver.log")
+	if err != nil {
+		c.Delete(ctx)
+		return nil, err
+	}
+
+	server := "https://127.0.0.1:" + strconv.Itoa(apiPort)
+	err = waitForAPIServer(ctx, server)
+	if err != nil {
+		c.Delete(ctx)
+		return nil, fmt.Errorf("%w. See the logs in %s", err, dir)
+	}
+
+	err = writeKubeConfig(c.kubeConfig, server)
+	if err != nil {
+		c.Delete(ctx)
+		return nil, err
+	}
+	return c, nil
+}
+
+// Provider returns ProviderEnvtest
+func (c *EnvtestCluster) Provider() Provider {
+	return ProviderEnvtest
+}
+
+// KubeConfig returns the path of the kube config file of the cluster
+func (c *EnvtestCluster) KubeConfig() string {
+	return c.kubeConfig
+}
+
+// RunsPods returns false because envtest clusters have no nodes or kubelets
+func (c *EnvtestCluster) RunsPods() bool {
+	return false
+}
+
+// LoadImage does nothing because envtest clusters never run images
+func (c *EnvtestCluster) LoadImage(ctx context.Context, image string) error {
+	return nil
+}
+
+// Delete stops kube-apiserver and etcd
+func (c *EnvtestCluster) Delete(ctx context.Context) error {
+	for _, cmd := range []*exec.Cmd{c.apiServer, c.etcd} {
+		if cmd == nil || cmd.Process == nil {
+			continue
+		}
+		cmd.Process.Kill()
+		cmd.Wait()
+	}
+	return nil
+}
+
+// start starts a control plane process with its output going to a log file in the cluster directory
+func (c *EnvtestCluster) start(cmd *exec.Cmd, logName string) error {
+	logFile, err := os.Create(filepath.Join(c.dir, logName))
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", logName, err)
+	}
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("failed to start %s: %w", cmd.Path, err)
+	}
+	return nil
+}
+
+// freePort returns a local port that is not in use
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// writeServiceAccountKey generates the key kube-apiserver signs service account tokens with
+func writeServiceAccountKey(dir string) (string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate service account key: %w", err)
+	}
+	keyFile := filepath.Join(dir, "sa.key")
+	b := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	err = os.WriteFile(keyFile, b, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to write service account key: %w", err)
+	}
+	return keyFile, nil
+}
+
+// waitForAPIServer polls the readyz endpoint of the API server until it passes
+func waitForAPIServer(ctx context.Context, server string) error {
+	// kube-apiserver generates its own self signed serving certificate
+	client := &http.Client{
+		Timeout:   time.Second * 5,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, envtestStartTimeout)
+	defer cancel()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server+"/readyz", nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+envtestToken)
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("kube-apiserver did not become ready within %s", envtestStartTimeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// writeKubeConfig writes a kube config file for the admin user of an envtest cluster
+func writeKubeConfig(path string, server string) error {
+	config := clientcmdapi.NewConfig()
+	config.Clusters["envtest"] = &clientcmdapi.Cluster{Server: server, InsecureSkipTLSVerify: true}
+	config.AuthInfos["admin"] = &clientcmdapi.AuthInfo{Token: envtestToken}
+	config.Contexts["envtest"] = &clientcmdapi.Context{Cluster: "envtest", AuthInfo: "admin"}
+	config.CurrentContext = "envtest"
+
+	err := clientcmd.WriteToFile(*config, path)
+	if err != nil {
+		return fmt.Errorf("failed to write kube config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,326 @@
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+)
+
+// pollInterval is how often the wait helpers check the cluster
+const pollInterval = time.Second * 2
+
+// installTimeout is how long Kuberhealthy has to become ready after it is installed
+const installTimeout = time.Minute * 3
+
+// checkerPodLabel is the label kuberhealthy sets on checker pods to the name of their khcheck
+const checkerPodLabel = "kuberhealthy-check-name"
+
+// the kuberhealthy custom resources applied and read by tests
+var (
+	khCheckResource = schema.GroupVersionResource{Group: "comcast.github.io", Version: "v1", Resource: "khchecks"}
+	khJobResource   = schema.GroupVersionResource{Group: "comcast.github.io", Version: "v1", Resource: "khjobs"}
+	khStateResource = schema.GroupVersionResource{Group: "comcast.github.io", Version: "v1", Resource: "khstates"}
+)
+
+// Options configure how the framework provisions a cluster and installs Kuberhealthy
+type Options struct {
+	Provider  Provider // the kind of cluster to provision
+	Name      string   // the name of the cluster
+	Dir       string   // where cluster state, configuration and logs are kept
+	Binary    string   // a kuberhealthy binary built from the tree under test
+	Image     string   // a locally built kuberhealthy image, used by clusters that run pods
+	Namespace string   // the namespace Kuberhealthy is installed into
+}
+
+// Framework is a cluster with Kuberhealthy installed along with clients for it
+type Framework struct {
+	Cluster   Cluster
+	Client    kubernetes.Interface
+	Dynamic   dynamic.Interface
+	Namespace string
+
+	opts  Options
+	local *exec.Cmd // kuberhealthy running as a local process, for clusters that do not run pods
+}
+
+// Setup provisions a cluster and installs Kuberhealthy into it.  Call Teardown when done, even when Setup fails.
+func Setup(ctx context.Context, opts Options) (*Framework, error) {
+	if len(opts.Namespace) == 0 {
+		opts.Namespace = "kuberhealthy"
+	}
+	if len(opts.Binary) == 0 {
+		return nil, fmt.Errorf("a kuberhealthy binary is required")
+	}
+
+	f := &Framework{Namespace: opts.Namespace, opts: opts}
+	cluster, err := NewCluster(ctx, opts.Provider, opts.Name, opts.Dir)
+	if err != nil {
+		return f, err
+	}
+	f.Cluster = cluster
+
+	restConfig, err := clientcmd.BuildConfigFromFlags("", cluster.KubeConfig())
+	if err != nil {
+		return f, fmt.Errorf("failed to load kube config: %w", err)
+	}
+	f.Client, err = kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return f, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	f.Dynamic, err = dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return f, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	return f, f.install(ctx)
+}
+
+// install installs Kuberhealthy with the install subcommand of the binary under test.  Clusters that run pods run the
+// installed deployment.  Other clusters run Kuberhealthy as a local process in forced master mode.
+func (f *Framework) install(ctx context.Context) error {
+	args := []string{"install", "--kubeconfig", f.Cluster.KubeConfig(), "--namespace", f.Namespace, "--replicas", "1"}
+	if f.Cluster.RunsPods() {
+		if len(f.opts.Image) == 0 {
+			return fmt.Errorf("a kuberhealthy image is required for %s clusters", f.Cluster.Provider())
+		}
+		err := f.Cluster.LoadImage(ctx, f.opts.Image)
+		if err != nil {
+			return err
+		}
+		args = append(args, "--image", f.opts.Image)
+	}
+
+	_, err := run(ctx, f.opts.Binary, args...)
+	if err != nil {
+		return fmt.Errorf("failed to install kuberhealthy: %w", err)
+	}
+
+	if !f.Cluster.RunsPods() {
+		return f.startLocal()
+	}
+	return wait.PollImmediateWithContext(ctx, pollInterval, installTimeout, func(ctx context.Context) (bool, error) {
+		d, err := f.Client.AppsV1().Deployments(f.Namespace).Get(ctx, "kuberhealthy", metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		return d.Status.ReadyReplicas > 0, nil
+	})
+}
+
+// startLocal runs kuberhealthy as a local process against the cluster
+func (f *Framework) startLocal() error {
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+	listen := "127.0.0.1:" + strconv.Itoa(port)
+
+	configFile := filepath.Join(f.opts.Dir, "kuberhealthy.yaml")
+	config := "listenAddress: " + strconv.Quote(listen) + "\nenableForceMaster: true\nlogLevel: debug\n"
+	err = os.WriteFile(configFile, []byte(config), 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write kuberhealthy config: %w", err)
+	}
+
+	// kuberhealthy reads the kube config of the current user when it is not running in a cluster
+	home := filepath.Join(f.opts.Dir, "home")
+	err = os.MkdirAll(filepath.Join(home, ".kube"), 0700)
+	if err != nil {
+		return fmt.Errorf("failed to create home directory: %w", err)
+	}
+	kubeConfig, err := os.ReadFile(f.Cluster.KubeConfig())
+	if err != nil {
+		return fmt.Errorf("failed to read kube config: %w", err)
+	}
+	err = os.WriteFile(filepath.Join(home, ".kube", "config"), kubeConfig, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write kube config: %w", err)
+	}
+
+	logFile, err := os.Create(filepath.Join(f.opts.Dir, "kuberhealthy.log"))
+	if err != nil {
+		return fmt.Errorf("failed to create kuberhealthy log: %w", err)
+	}
+	f.local = exec.Command(f.opts.Binary, "--config", configFile)
+	f.local.Env = append(os.Environ(),
+		"HOME="+home,
+		"POD_NAME=kuberhealthy-e2e",
+		"POD_NAMESPACE="+f.Namespace,
+		"KH_EXTERNAL_REPORTING_URL=http://"+listen+"/externalCheckStatus",
+	)
+	f.local.Stdout = logFile
+	f.local.Stderr = logFile
+	err = f.local.Start()
+	if err != nil {
+		return fmt.Errorf("failed to start kuberhealthy: %w", err)
+	}
+	return nil
+}
+
+// Teardown stops Kuberhealthy and deletes the cluster
+func (f *Framework) Teardown(ctx context.Context) error {
+	if f.local != nil && f.local.Process != nil {
+		f.local.Process.Kill()
+		f.local.Wait()
+	}
+	if f.Cluster == nil {
+		return nil
+	}
+	return f.Cluster.Delete(ctx)
+}
+
+// resourceFor returns the resource of the supplied kind of kuberhealthy object
+func resourceFor(kind string) (schema.GroupVersionResource, error) {
+	switch kind {
+	case "KuberhealthyCheck":
+		return khCheckResource, nil
+	case "KuberhealthyJob":
+		return khJobResource, nil
+	default:
+		return schema.GroupVersionResource{}, fmt.Errorf("can not apply objects of kind %s", kind)
+	}
+}
+
+// ApplyFile creates the khchecks and khjobs in a YAML file.  Objects without a namespace are created in the namespace
+// of Kuberhealthy.
+func (f *Framework) ApplyFile(ctx context.Context, path string) ([]*unstructured.Unstructured, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var created []*unstructured.Unstructured
+	decoder := yaml.NewYAMLOrJSONDecoder(file, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		err = decoder.Decode(&obj.Object)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return created, fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if len(obj.GetNamespace()) == 0 {
+			obj.SetNamespace(f.Namespace)
+		}
+
+		resource, err := resourceFor(obj.GetKind())
+		if err != nil {
+			return created, err
+		}
+		result, err := f.Dynamic.Resource(resource).Namespace(obj.GetNamespace()).Create(ctx, obj, metav1.CreateOptions{})
+		if err != nil {
+			return created, fmt.Errorf("failed to create %s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		created = append(created, result)
+	}
+	return created, nil
+}
+
+// Delete deletes an object created by ApplyFile
+func (f *Framework) Delete(ctx context.Context, obj *unstructured.Unstructured) error {
+	resource, err := resourceFor(obj.GetKind())
+	if err != nil {
+		return err
+	}
+	err = f.Dynamic.Resource(resource).Namespace(obj.GetNamespace()).Delete(ctx, obj.GetName(), metav1.DeleteOptions{})
+	if k8sErrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// State returns the khstate of a khcheck or khjob in the namespace of Kuberhealthy
+func (f *Framework) State(ctx context.Context, name string) (khstatev1.WorkloadDetails, error) {
+	u, err := f.Dynamic.Resource(khStateResource).Namespace(f.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return khstatev1.WorkloadDetails{}, err
+	}
+	// decode through JSON like the typed client does, since the unstructured converter trips over the unexported
+	// fields of WorkloadDetails
+	b, err := u.MarshalJSON()
+	if err != nil {
+		return khstatev1.WorkloadDetails{}, err
+	}
+	var state khstatev1.KuberhealthyState
+	err = json.Unmarshal(b, &state)
+	return state.Spec, err
+}
+
+// WaitForState waits until the khstate of a khcheck or khjob satisfies the supplied condition and returns it
+func (f *Framework) WaitForState(ctx context.Context, name string, timeout time.Duration, condition func(khstatev1.WorkloadDetails) bool) (khstatev1.WorkloadDetails, error) {
+	var last khstatev1.WorkloadDetails
+	err := wait.PollImmediateWithContext(ctx, pollInterval, timeout, func(ctx context.Context) (bool, error) {
+		state, err := f.State(ctx, name)
+		if err != nil {
+			return false, nil
+		}
+		last = state
+		return condition(state), nil
+	})
+	if err != nil {
+		return last, fmt.Errorf("khstate %s did not reach the expected state within %s, last seen as %+v: %w", name, timeout, last, err)
+	}
+	return last, nil
+}
+
+// WaitForStateDeleted waits until the khstate of a khcheck or khjob is removed
+func (f *Framework) WaitForStateDeleted(ctx context.Context, name string, timeout time.Duration) error {
+	err := wait.PollImmediateWithContext(ctx, pollInterval, timeout, func(ctx context.Context) (bool, error) {
+		_, err := f.Dynamic.Resource(khStateResource).Namespace(f.Namespace).Get(ctx, name, metav1.GetOptions{})
+		return k8sErrors.IsNotFound(err), nil
+	})
+	if err != nil {
+		return fmt.Errorf("khstate %s was not removed within %s: %w", name, timeout, err)
+	}
+	return nil
+}
+
+// CheckerPods lists the checker pods of a khcheck
+func (f *Framework) CheckerPods(ctx context.Context, checkName string) ([]corev1.Pod, error) {
+	pods, err := f.Client.CoreV1().Pods(f.Namespace).List(ctx, metav1.ListOptions{LabelSelector: checkerPodLabel + "=" + checkName})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+// WaitForCheckerPods waits until a khcheck has at least the supplied number of checker pods and returns them
+func (f *Framework) WaitForCheckerPods(ctx context.Context, checkName string, count int, timeout time.Duration) ([]corev1.Pod, error) {
+	var pods []corev1.Pod
+	err := wait.PollImmediateWithContext(ctx, pollInterval, timeout, func(ctx context.Context) (bool, error) {
+		var err error
+		pods, err = f.CheckerPods(ctx, checkName)
+		if err != nil {
+			return false, nil
+		}
+		return len(pods) >= count, nil
+	})
+	if err != nil {
+		return pods, fmt.Errorf("khcheck %s had %d of %d checker pods after %s: %w", checkName, len(pods), count, timeout, err)
+	}
+	return pods, nil
+}
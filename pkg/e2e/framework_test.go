@@ -0,0 +1,139 @@
+package e2e
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/clientcmd"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+)
+
+// testFramework makes a framework backed by a fake dynamic client
+func testFramework() *Framework {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		khCheckResource: "KuberhealthyCheckList",
+		khJobResource:   "KuberhealthyJobList",
+		khStateResource: "KuberhealthyStateList",
+	})
+	return &Framework{Dynamic: client, Namespace: "kuberhealthy"}
+}
+
+// TestApplyFile ensures every khcheck and khjob in a file is created, defaulting to the kuberhealthy namespace
+func TestApplyFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "checks.yaml")
+	err := os.WriteFile(file, []byte(`apiVersion: comcast.github.io/v1
+kind: KuberhealthyCheck
+metadata:
+  name: check
+spec:
+  runInterval: 30s
+---
+apiVersion: comcast.github.io/v1
+kind: KuberhealthyJob
+metadata:
+  name: job
+  namespace: other
+spec:
+  timeout: 1m
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := testFramework()
+	ctx := context.Background()
+	objects, err := f.ApplyFile(ctx, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 {
+		t.Fatalf("expected 2 objects, got %d", len(objects))
+	}
+
+	_, err = f.Dynamic.Resource(khCheckResource).Namespace("kuberhealthy").Get(ctx, "check", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("expected the khcheck to be created in the kuberhealthy namespace:", err)
+	}
+	_, err = f.Dynamic.Resource(khJobResource).Namespace("other").Get(ctx, "job", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("expected the khjob to be created in its own namespace:", err)
+	}
+
+	for _, o := range objects {
+		err = f.Delete(ctx, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestApplyFileUnsupportedKind ensures objects other than khchecks and khjobs are rejected
+func TestApplyFileUnsupportedKind(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "pod.yaml")
+	err := os.WriteFile(file, []byte("apiVersion: v1\nkind: Pod\nmetadata:\n  name: pod\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = testFramework().ApplyFile(context.Background(), file)
+	if err == nil {
+		t.Fatal("expected an error applying a pod")
+	}
+}
+
+// TestWaitForState ensures khstates are read and matched against the supplied condition
+func TestWaitForState(t *testing.T) {
+	f := testFramework()
+	ctx := context.Background()
+	state := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "comcast.github.io/v1",
+		"kind":       "KuberhealthyState",
+		"metadata":   map[string]interface{}{"name": "check", "namespace": "kuberhealthy"},
+		"spec":       map[string]interface{}{"OK": false, "Errors": []interface{}{"timed out"}},
+	}}
+	_, err := f.Dynamic.Resource(khStateResource).Namespace("kuberhealthy").Create(ctx, state, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	details, err := f.WaitForState(ctx, "check", time.Second*5, func(s khstatev1.WorkloadDetails) bool {
+		return !s.OK && len(s.Errors) == 1
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.Errors[0] != "timed out" {
+		t.Fatalf("unexpected errors: %v", details.Errors)
+	}
+
+	_, err = f.WaitForState(ctx, "check", time.Millisecond*10, func(s khstatev1.WorkloadDetails) bool {
+		return s.OK
+	})
+	if err == nil {
+		t.Fatal("expected waiting for an OK state to time out")
+	}
+}
+
+// TestWriteKubeConfig ensures envtest kube configs authenticate with the admin token
+func TestWriteKubeConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	err := writeKubeConfig(path, "https://127.0.0.1:6443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := clientcmd.BuildConfigFromFlags("", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "https://127.0.0.1:6443" || config.BearerToken != envtestToken || !config.Insecure {
+		t.Fatalf("unexpected client config: %+v", config)
+	}
+}
@@ -0,0 +1,59 @@
+package health
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CacheControl is the Cache-Control header sent with status responses.  Caches may store the response but must
+// revalidate it on every request so that pollers always see the latest state.  Because the ETag is computed from the
+// response itself, every Kuberhealthy replica behind a load balancer hands out the same ETag for the same state.
+const CacheControl = "no-cache"
+
+// ETag returns a strong entity tag for a response body
+func ETag(b []byte) string {
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// NotModified returns true when the If-None-Match header of a request matches the supplied ETag
+func NotModified(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if len(header) == 0 {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		// If-None-Match uses weak comparison, so weak validators from intermediate caches also match
+		if strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteCacheable writes a response body along with ETag and Cache-Control headers.  Requests that already hold the
+// current version of the body get a 304 with no body instead.
+func WriteCacheable(w http.ResponseWriter, r *http.Request, b []byte) error {
+	etag := ETag(b)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", CacheControl)
+
+	if NotModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	_, err := w.Write(b)
+	if err != nil {
+		log.Errorln("Error writing response to caller:", err)
+	}
+	return err
+}
@@ -3,6 +3,7 @@ package health
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -18,6 +19,9 @@ type State struct {
 	JobDetails    map[string]khstatev1.WorkloadDetails // map of job names to last run timestamp
 	CurrentMaster string
 	Metadata      map[string]string
+	Warnings      []string   `json:",omitempty"` // signs of degradation reported by checks, which do not make the cluster unhealthy
+	Degraded      bool       `json:",omitempty"` // the Kubernetes API is unavailable and the state may be out of date
+	StaleSince    *time.Time `json:",omitempty"` // when the Kubernetes API became unavailable
 }
 
 // AddError adds new errors to State
@@ -32,6 +36,18 @@ func (h *State) AddError(s ...string) {
 	}
 }
 
+// AddWarning adds new warnings to State.  Warnings do not change the OK state.
+func (h *State) AddWarning(s ...string) {
+	for _, str := range s {
+		if len(str) == 0 {
+			log.Warningln("AddWarning was called but the warning was blank so it was skipped.")
+			continue
+		}
+		log.Debugln("Appending warning:", str)
+		h.Warnings = append(h.Warnings, str)
+	}
+}
+
 // WriteHTTPStatusResponse writes a response to an http response writer
 func (h *State) WriteHTTPStatusResponse(w http.ResponseWriter) error {
 
@@ -54,6 +70,41 @@ func (h *State) WriteHTTPStatusResponse(w http.ResponseWriter) error {
 	return err
 }
 
+// WriteCacheableHTTPStatusResponse writes a response to an http response writer with ETag and Cache-Control headers.
+// Clients that send the ETag of the current state back in an If-None-Match header get a 304 with no body.
+func (h *State) WriteCacheableHTTPStatusResponse(w http.ResponseWriter, r *http.Request) error {
+	b, err := json.MarshalIndent(*h, "", "  ")
+	if err != nil {
+		log.Warningln("Error marshaling health check json for caller:", err)
+		return err
+	}
+	return WriteCacheable(w, r, b)
+}
+
+// Copy returns a copy of the state whose errors, details and metadata can be changed without changing the original
+func (h State) Copy() State {
+	c := h
+	c.Errors = append([]string{}, h.Errors...)
+	if h.Warnings != nil {
+		c.Warnings = append([]string{}, h.Warnings...)
+	}
+	c.CheckDetails = make(map[string]khstatev1.WorkloadDetails, len(h.CheckDetails))
+	for k, v := range h.CheckDetails {
+		c.CheckDetails[k] = v
+	}
+	c.JobDetails = make(map[string]khstatev1.WorkloadDetails, len(h.JobDetails))
+	for k, v := range h.JobDetails {
+		c.JobDetails[k] = v
+	}
+	if h.Metadata != nil {
+		c.Metadata = make(map[string]string, len(h.Metadata))
+		for k, v := range h.Metadata {
+			c.Metadata[k] = v
+		}
+	}
+	return c
+}
+
 // NewState creates a new health check result response
 func NewState() State {
 	s := State{}
@@ -64,3 +115,25 @@ func NewState() State {
 	s.Metadata = map[string]string{}
 	return s
 }
+
+// Delta holds the checks and jobs whose state changed since a cursor supplied by a client.  Clients that poll
+// frequently pass the returned Cursor back on their next request to only receive what changed in between.
+type Delta struct {
+	OK           bool                                 // the overall state of all checks and jobs, not just the changed ones
+	Since        string                               // the cursor supplied by the client
+	Cursor       string                               // the cursor to supply on the next request
+	CheckDetails map[string]khstatev1.WorkloadDetails // map of changed check names to their state
+	JobDetails   map[string]khstatev1.WorkloadDetails // map of changed job names to their state
+	Keys         []string                             // the names of all current checks and jobs, so that clients can drop removed ones
+}
+
+// NewDelta creates a new, empty delta for the supplied cursor
+func NewDelta(since string) Delta {
+	d := Delta{}
+	d.OK = true
+	d.Since = since
+	d.CheckDetails = make(map[string]khstatev1.WorkloadDetails)
+	d.JobDetails = make(map[string]khstatev1.WorkloadDetails)
+	d.Keys = []string{}
+	return d
+}
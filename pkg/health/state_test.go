@@ -1,8 +1,11 @@
 package health_test
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/health"
 	"github.com/stretchr/testify/assert"
 )
@@ -20,3 +23,57 @@ func TestAddError(t *testing.T) {
 	assert.Contains(t, s.Errors, "my error message")
 	assert.Contains(t, s.Errors, "my another error message")
 }
+
+func TestAddWarning(t *testing.T) {
+	s := health.NewState()
+	s.AddWarning("certificate expires in 20 days", "")
+
+	assert.True(t, s.OK)
+	assert.Empty(t, s.Errors)
+	assert.Equal(t, []string{"certificate expires in 20 days"}, s.Warnings)
+}
+
+func TestWriteCacheableHTTPStatusResponse(t *testing.T) {
+	s := health.NewState()
+
+	w := httptest.NewRecorder()
+	err := s.WriteCacheableHTTPStatusResponse(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.NoError(t, err)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, health.CacheControl, w.Header().Get("Cache-Control"))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// the same state sent back with its etag is not modified
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("If-None-Match", "W/"+etag)
+	w = httptest.NewRecorder()
+	err = s.WriteCacheableHTTPStatusResponse(w, r)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.Bytes())
+
+	// a changed state gets a new etag and a full response
+	s.AddError("my error message")
+	w = httptest.NewRecorder()
+	err = s.WriteCacheableHTTPStatusResponse(w, r)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+}
+
+func TestCopy(t *testing.T) {
+	s := health.NewState()
+	s.AddError("original error")
+	s.CheckDetails["kuberhealthy/check"] = khstatev1.WorkloadDetails{OK: true}
+
+	c := s.Copy()
+	c.AddError("copied error")
+	c.CheckDetails["kuberhealthy/other"] = khstatev1.WorkloadDetails{}
+	c.Metadata["cluster"] = "copy"
+
+	assert.Equal(t, []string{"original error"}, s.Errors)
+	assert.Len(t, s.CheckDetails, 1)
+	assert.Empty(t, s.Metadata)
+	assert.Len(t, c.CheckDetails, 2)
+}
@@ -0,0 +1,274 @@
+// Package imageversions tracks the versions of the official check images available in their registry, so that
+// khchecks running out of date images can be reported and optionally updated.  Image tags are compared as semantic
+// versions, such as v1.5.0 or 1.5.0.  Tags that are not plain versions, such as latest or v1.6.0-rc1, are ignored.
+package imageversions
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+)
+
+// DefaultRepositories are the repositories of the official check images
+var DefaultRepositories = []string{"docker.io/kuberhealthy"}
+
+// Version is an image tag read as a semantic version
+type Version struct {
+	Tag   string // the tag the version was read from
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseVersion reads a tag such as v1.5.0 or 1.5.0 as a version
+func ParseVersion(tag string) (Version, bool) {
+	parts := strings.Split(strings.TrimPrefix(tag, "v"), ".")
+	if len(parts) != 3 {
+		return Version{}, false
+	}
+	var numbers [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || strings.HasPrefix(p, "+") {
+			return Version{}, false
+		}
+		numbers[i] = n
+	}
+	return Version{Tag: tag, Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, true
+}
+
+// Less tells if a version is older than another
+func (v Version) Less(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Patch < o.Patch
+}
+
+// Constraint limits how far an image is automatically updated
+type Constraint string
+
+// These are the constraints of automatic updates.
+const (
+	ConstraintNone  Constraint = ""      // images are not updated
+	ConstraintPatch Constraint = "patch" // images are updated to newer patch versions of the same minor version
+	ConstraintMinor Constraint = "minor" // images are updated to newer minor versions of the same major version
+	ConstraintMajor Constraint = "major" // images are updated to any newer version
+)
+
+// ParseConstraint reads a constraint from configuration
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint(strings.ToLower(s))
+	switch c {
+	case ConstraintNone, ConstraintPatch, ConstraintMinor, ConstraintMajor:
+		return c, nil
+	}
+	return ConstraintNone, fmt.Errorf("image update constraint %q must be patch, minor or major", s)
+}
+
+// Allows tells if the constraint allows updating an image from one version to another
+func (c Constraint) Allows(from Version, to Version) bool {
+	if !from.Less(to) {
+		return false
+	}
+	switch c {
+	case ConstraintPatch:
+		return from.Major == to.Major && from.Minor == to.Minor
+	case ConstraintMinor:
+		return from.Major == to.Major
+	case ConstraintMajor:
+		return true
+	}
+	return false
+}
+
+// Newest finds the newest of the supplied tags and the newest that the constraint allows updating the current tag
+// to.  Skipped tags are never picked for an update.  Empty strings are returned when there is nothing newer.
+func Newest(current string, tags []string, c Constraint, skip map[string]bool) (latest string, allowed string) {
+	from, ok := ParseVersion(current)
+	if !ok {
+		return "", ""
+	}
+	newest, update := from, from
+	for _, tag := range tags {
+		v, ok := ParseVersion(tag)
+		if !ok {
+			continue
+		}
+		if newest.Less(v) {
+			newest = v
+		}
+		if !skip[tag] && c.Allows(from, v) && update.Less(v) {
+			update = v
+		}
+	}
+	if newest.Tag != from.Tag {
+		latest = newest.Tag
+	}
+	if update.Tag != from.Tag {
+		allowed = update.Tag
+	}
+	return latest, allowed
+}
+
+// Tracked tells if an image belongs to one of the supplied repositories, such as docker.io/kuberhealthy.  Images are
+// returned with their repository and tag.  Images pinned by digest are not tracked.
+func Tracked(image string, repositories []string) (repository string, tag string, ok bool) {
+	if strings.Contains(image, "@") {
+		return "", "", false
+	}
+	ref, err := name.NewTag(image)
+	if err != nil {
+		return "", "", false
+	}
+	full := ref.Context().Name()
+	for _, r := range repositories {
+		prefix := normalizeRepository(r)
+		if full == prefix || strings.HasPrefix(full, prefix+"/") {
+			return full, ref.TagStr(), true
+		}
+	}
+	return "", "", false
+}
+
+// normalizeRepository spells a repository or repository prefix the way image references are spelled once parsed, so
+// that docker.io/kuberhealthy matches index.docker.io/kuberhealthy/http-check
+func normalizeRepository(r string) string {
+	r = strings.TrimSuffix(r, "/")
+	if !strings.Contains(r, "/") {
+		return r
+	}
+	registry := r[:strings.Index(r, "/")]
+	if registry == "docker.io" || registry == name.DefaultRegistry {
+		return "index.docker.io" + r[len(registry):]
+	}
+	return r
+}
+
+// TagLister lists the tags of an image repository
+type TagLister interface {
+	ListTags(ctx context.Context, repository string) ([]string, error)
+}
+
+// RegistryTagLister lists tags from the registry of the repository anonymously
+type RegistryTagLister struct{}
+
+// ListTags lists the tags of a repository, such as index.docker.io/kuberhealthy/http-check
+func (RegistryTagLister) ListTags(ctx context.Context, repository string) ([]string, error) {
+	repo, err := name.NewRepository(repository)
+	if err != nil {
+		return nil, err
+	}
+	return remote.List(repo, remote.WithContext(ctx))
+}
+
+// Status is the version status of one container image of a khcheck
+type Status struct {
+	Namespace string `json:"namespace"`
+	Check     string `json:"check"`
+	Container string `json:"container"`
+	Image     string `json:"image"`
+	Current   string `json:"current"`           // the tag the check runs
+	Latest    string `json:"latest,omitempty"`  // the newest tag available, when newer than the current tag
+	Allowed   string `json:"allowed,omitempty"` // the newest tag the update constraint allows, when newer than the current tag
+	Error     string `json:"error,omitempty"`   // why the tags of the image could not be listed
+}
+
+// OutOfDate tells if a newer version of the image is available
+func (s Status) OutOfDate() bool {
+	return len(s.Latest) > 0
+}
+
+// Report holds the version status of the tracked images of all khchecks
+type Report struct {
+	Generated time.Time `json:"generated"`
+	Images    []Status  `json:"images"`
+	OutOfDate int       `json:"outOfDate"`
+}
+
+// Options configure which images are tracked and how far they may be updated
+type Options struct {
+	Repositories []string
+	Constraint   Constraint
+	Skip         map[string]bool // images, with their tag, that are never updated to, such as those rolled back
+}
+
+// Compute works out the version status of the tracked images of the supplied checks.  The tags of each repository are
+// listed once.
+func Compute(ctx context.Context, lister TagLister, checks []khcheckv1.KuberhealthyCheck, opts Options, now time.Time) Report {
+	report := Report{Generated: now, Images: []Status{}}
+	tags := make(map[string][]string)
+	listErrors := make(map[string]error)
+
+	for _, check := range checks {
+		for _, container := range check.Spec.EffectivePodSpec().Containers {
+			repository, tag, ok := Tracked(container.Image, opts.Repositories)
+			if !ok {
+				continue
+			}
+			s := Status{Namespace: check.Namespace, Check: check.Name, Container: container.Name, Image: container.Image, Current: tag}
+
+			if _, listed := tags[repository]; !listed && listErrors[repository] == nil {
+				t, err := lister.ListTags(ctx, repository)
+				if err != nil {
+					listErrors[repository] = err
+				}
+				tags[repository] = t
+			}
+			if err := listErrors[repository]; err != nil {
+				s.Error = err.Error()
+			} else {
+				skip := make(map[string]bool)
+				for _, t := range tags[repository] {
+					if opts.Skip[repository+":"+t] {
+						skip[t] = true
+					}
+				}
+				s.Latest, s.Allowed = Newest(tag, tags[repository], opts.Constraint, skip)
+			}
+			if s.OutOfDate() {
+				report.OutOfDate++
+			}
+			report.Images = append(report.Images, s)
+		}
+	}
+
+	sort.Slice(report.Images, func(i, j int) bool {
+		a, b := report.Images[i], report.Images[j]
+		return a.Namespace+"/"+a.Check+"/"+a.Container < b.Namespace+"/"+b.Check+"/"+b.Container
+	})
+	return report
+}
+
+// UpdatedImage is the image of a container with its tag replaced
+func UpdatedImage(image string, tag string) string {
+	return image[:strings.LastIndex(image, ":")+1] + tag
+}
+
+// PrometheusMetrics formats the image version report as Prometheus metrics
+func PrometheusMetrics(report Report) string {
+	output := "# HELP kuberhealthy_check_images_out_of_date Shows the number of check images with a newer version available\n"
+	output += "# TYPE kuberhealthy_check_images_out_of_date gauge\n"
+	output += fmt.Sprintf("kuberhealthy_check_images_out_of_date %d\n", report.OutOfDate)
+	output += "# HELP kuberhealthy_check_image_out_of_date Shows if a newer version of the image of a check is available\n"
+	output += "# TYPE kuberhealthy_check_image_out_of_date gauge\n"
+	for _, s := range report.Images {
+		outOfDate := 0
+		if s.OutOfDate() {
+			outOfDate = 1
+		}
+		output += fmt.Sprintf("kuberhealthy_check_image_out_of_date{namespace=\"%s\",check=\"%s\",container=\"%s\",current=\"%s\",latest=\"%s\"} %d\n", s.Namespace, s.Check, s.Container, s.Current, s.Latest, outOfDate)
+	}
+	return output
+}
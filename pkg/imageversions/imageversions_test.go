@@ -0,0 +1,157 @@
+package imageversions
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	khcheckv1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khcheck/v1"
+)
+
+// fakeTagLister lists fixed tags and counts how often each repository is listed
+type fakeTagLister struct {
+	tags  map[string][]string
+	calls map[string]int
+}
+
+func (f *fakeTagLister) ListTags(ctx context.Context, repository string) ([]string, error) {
+	f.calls[repository]++
+	tags, ok := f.tags[repository]
+	if !ok {
+		return nil, errors.New("repository not found")
+	}
+	return tags, nil
+}
+
+// TestParseVersion ensures that only plain versions are read from tags
+func TestParseVersion(t *testing.T) {
+	var testCases = []struct {
+		tag      string
+		expected Version
+		ok       bool
+	}{
+		{tag: "v1.5.0", expected: Version{Tag: "v1.5.0", Major: 1, Minor: 5}, ok: true},
+		{tag: "2.10.3", expected: Version{Tag: "2.10.3", Major: 2, Minor: 10, Patch: 3}, ok: true},
+		{tag: "latest"},
+		{tag: "v1.6"},
+		{tag: "v1.6.0-rc1"},
+		{tag: "v1.-1.0"},
+	}
+
+	for _, tc := range testCases {
+		v, ok := ParseVersion(tc.tag)
+		if ok != tc.ok || v != tc.expected {
+			t.Fatalf("expected %+v (%t) for %q, got %+v (%t)", tc.expected, tc.ok, tc.tag, v, ok)
+		}
+	}
+}
+
+// TestNewest ensures that the newest tag and the newest tag allowed by each constraint are found
+func TestNewest(t *testing.T) {
+	tags := []string{"latest", "v1.4.0", "v1.5.0", "v1.5.2", "v1.5.3", "v1.6.1", "v2.0.0", "v2.1.0-rc1"}
+	var testCases = []struct {
+		constraint Constraint
+		skip       map[string]bool
+		allowed    string
+	}{
+		{constraint: ConstraintNone},
+		{constraint: ConstraintPatch, allowed: "v1.5.3"},
+		{constraint: ConstraintPatch, skip: map[string]bool{"v1.5.3": true}, allowed: "v1.5.2"},
+		{constraint: ConstraintMinor, allowed: "v1.6.1"},
+		{constraint: ConstraintMajor, allowed: "v2.0.0"},
+	}
+
+	for _, tc := range testCases {
+		latest, allowed := Newest("v1.5.0", tags, tc.constraint, tc.skip)
+		if latest != "v2.0.0" || allowed != tc.allowed {
+			t.Fatalf("expected v2.0.0 and %q with constraint %q, got %q and %q", tc.allowed, tc.constraint, latest, allowed)
+		}
+	}
+
+	latest, allowed := Newest("v2.0.0", tags, ConstraintMajor, nil)
+	if len(latest) > 0 || len(allowed) > 0 {
+		t.Fatalf("expected nothing newer than v2.0.0, got %q and %q", latest, allowed)
+	}
+}
+
+// TestTracked ensures that images are matched against repositories the way docker spells them
+func TestTracked(t *testing.T) {
+	var testCases = []struct {
+		image      string
+		repository string
+		tag        string
+		ok         bool
+	}{
+		{image: "kuberhealthy/http-check:v1.5.0", repository: "index.docker.io/kuberhealthy/http-check", tag: "v1.5.0", ok: true},
+		{image: "docker.io/kuberhealthy/dns-resolution-check:v1.5.0", repository: "index.docker.io/kuberhealthy/dns-resolution-check", tag: "v1.5.0", ok: true},
+		{image: "kuberhealthy/http-check", repository: "index.docker.io/kuberhealthy/http-check", tag: "latest", ok: true},
+		{image: "example.com/kuberhealthy/http-check:v1.5.0"},
+		{image: "kuberhealthyfork/http-check:v1.5.0"},
+		{image: "kuberhealthy/http-check@sha256:0123456789012345678901234567890123456789012345678901234567890123"},
+	}
+
+	for _, tc := range testCases {
+		repository, tag, ok := Tracked(tc.image, DefaultRepositories)
+		if ok != tc.ok || repository != tc.repository || tag != tc.tag {
+			t.Fatalf("expected %q %q (%t) for %q, got %q %q (%t)", tc.repository, tc.tag, tc.ok, tc.image, repository, tag, ok)
+		}
+	}
+}
+
+// TestCompute ensures that tracked images are reported once per container and each repository is listed once
+func TestCompute(t *testing.T) {
+	check := func(name string, images ...string) khcheckv1.KuberhealthyCheck {
+		var containers []corev1.Container
+		for i, image := range images {
+			containers = append(containers, corev1.Container{Name: "c" + string(rune('0'+i)), Image: image})
+		}
+		return khcheckv1.NewKuberhealthyCheck(name, "kuberhealthy", khcheckv1.CheckConfig{PodSpec: corev1.PodSpec{Containers: containers}})
+	}
+	checks := []khcheckv1.KuberhealthyCheck{
+		check("web", "kuberhealthy/http-check:v1.5.0", "nginx:1.25.0"),
+		check("api", "kuberhealthy/http-check:v1.6.0"),
+		check("dns", "kuberhealthy/dns-resolution-check:v1.5.0"),
+	}
+	lister := &fakeTagLister{
+		tags:  map[string][]string{"index.docker.io/kuberhealthy/http-check": {"v1.5.0", "v1.5.1", "v1.6.0"}},
+		calls: make(map[string]int),
+	}
+	now := time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	report := Compute(context.Background(), lister, checks, Options{Repositories: DefaultRepositories, Constraint: ConstraintPatch}, now)
+	if len(report.Images) != 3 || report.OutOfDate != 1 {
+		t.Fatalf("expected 3 images with 1 out of date, got %+v", report)
+	}
+	if lister.calls["index.docker.io/kuberhealthy/http-check"] != 1 {
+		t.Fatalf("expected the http-check repository to be listed once, got %v", lister.calls)
+	}
+
+	api, dns, web := report.Images[0], report.Images[1], report.Images[2]
+	if api.Check != "api" || api.OutOfDate() {
+		t.Fatalf("expected the api check to be up to date, got %+v", api)
+	}
+	if dns.Check != "dns" || len(dns.Error) == 0 {
+		t.Fatalf("expected an error listing the dns check's repository, got %+v", dns)
+	}
+	if web.Check != "web" || web.Latest != "v1.6.0" || web.Allowed != "v1.5.1" {
+		t.Fatalf("expected the web check to be allowed v1.5.1 of v1.6.0, got %+v", web)
+	}
+
+	metrics := PrometheusMetrics(report)
+	if !strings.Contains(metrics, "kuberhealthy_check_images_out_of_date 1\n") ||
+		!strings.Contains(metrics, `kuberhealthy_check_image_out_of_date{namespace="kuberhealthy",check="web",container="c0",current="v1.5.0",latest="v1.6.0"} 1`) {
+		t.Fatalf("unexpected metrics:\n%s", metrics)
+	}
+}
+
+// TestUpdatedImage ensures that only the tag of an image is replaced, even with a registry port
+func TestUpdatedImage(t *testing.T) {
+	updated := UpdatedImage("registry.example.com:5000/kuberhealthy/http-check:v1.5.0", "v1.5.1")
+	if updated != "registry.example.com:5000/kuberhealthy/http-check:v1.5.1" {
+		t.Fatalf("unexpected updated image %q", updated)
+	}
+}
@@ -10,6 +10,16 @@ import (
 // Create returns a kubernetes api clientset that enables communication with
 // the kubernetes API via the internal service.
 func Create(kubeConfigFile string) (*kubernetes.Clientset, error) {
+	kubeconfig, err := RestConfig(kubeConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(kubeconfig)
+}
+
+// RestConfig returns the in cluster client config, falling back to the
+// supplied kube config file when running outside of a cluster.
+func RestConfig(kubeConfigFile string) (*rest.Config, error) {
 	kubeconfig, err := rest.InClusterConfig()
 	if err != nil {
 		// If not in cluster, use kube config file
@@ -18,5 +28,5 @@ func Create(kubeConfigFile string) (*kubernetes.Clientset, error) {
 			return nil, err
 		}
 	}
-	return kubernetes.NewForConfig(kubeconfig)
+	return kubeconfig, nil
 }
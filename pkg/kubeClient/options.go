@@ -0,0 +1,107 @@
+package kubeClient
+
+import (
+	"math/rand"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/rest"
+)
+
+// UserAgent is the user agent Kuberhealthy identifies itself to the API server with, so that its requests can be told
+// apart in audit logs and API priority and fairness debugging
+var UserAgent = "kuberhealthy/v2 (" + runtime.GOOS + "/" + runtime.GOARCH + ")"
+
+// defaultRetryBackoff is how long the first retry of a throttled request waits when the API server does not say
+const defaultRetryBackoff = time.Millisecond * 250
+
+// maxRetryBackoff is the longest a throttled request waits before it is retried
+const maxRetryBackoff = time.Second * 30
+
+// Options tune how clients talk to the API server.  Zero values keep the client-go defaults.
+type Options struct {
+	QPS        float32       // the sustained requests per second allowed to the API server
+	Burst      int           // the requests allowed above the QPS in a burst
+	Timeout    time.Duration // how long a single request can take
+	UserAgent  string        // the user agent of requests
+	MaxRetries int           // how many times requests throttled with a 429 are retried
+}
+
+// Configure applies the options to a rest config.  Throttled requests are retried with jittered backoff on top of the
+// retries client-go makes itself, because API priority and fairness does not always tell clients when to retry.
+func Configure(config *rest.Config, o Options) {
+	if o.QPS > 0 {
+		config.QPS = o.QPS
+	}
+	if o.Burst > 0 {
+		config.Burst = o.Burst
+	}
+	if o.Timeout > 0 {
+		config.Timeout = o.Timeout
+	}
+	if len(o.UserAgent) > 0 {
+		config.UserAgent = o.UserAgent
+	}
+	if o.MaxRetries > 0 {
+		config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return &retryTransport{next: rt, maxRetries: o.MaxRetries, after: time.After}
+		})
+	}
+}
+
+// retryTransport retries requests the API server throttled with a 429 Too Many Requests response
+type retryTransport struct {
+	next       http.RoundTripper
+	maxRetries int
+	after      func(time.Duration) <-chan time.Time
+}
+
+// RoundTrip sends the request, retrying it while it is throttled.  Requests with a body that can not be replayed are
+// not retried.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt >= t.maxRetries {
+			return resp, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			return resp, err
+		}
+
+		wait := retryBackoff(attempt, resp.Header.Get("Retry-After"))
+		resp.Body.Close()
+		log.Debugln("API request", req.Method, req.URL.Path, "was throttled. Retrying in", wait)
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-t.after(wait):
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryBackoff returns how long to wait before retrying a throttled request.  The Retry-After header is honored when
+// it is set, otherwise the wait doubles with each attempt.  Up to half of the wait is added as jitter so that throttled
+// clients do not all retry at the same moment.
+func retryBackoff(attempt int, retryAfter string) time.Duration {
+	wait := defaultRetryBackoff << uint(attempt)
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds > 0 {
+		wait = time.Duration(seconds) * time.Second
+	}
+	if wait > maxRetryBackoff || wait <= 0 {
+		wait = maxRetryBackoff
+	}
+	return wait + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
@@ -0,0 +1,100 @@
+package kubeClient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// noWait makes retries happen right away
+func noWait(time.Duration) <-chan time.Time {
+	c := make(chan time.Time, 1)
+	c <- time.Now()
+	return c
+}
+
+// throttlingServer answers the first throttled requests with a 429 and then echoes the request body
+func throttlingServer(t *testing.T, throttled int) (*httptest.Server, *int) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= throttled {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// TestRetryTransport ensures throttled requests are retried with their body until they succeed
+func TestRetryTransport(t *testing.T) {
+	server, requests := throttlingServer(t, 2)
+	client := &http.Client{Transport: &retryTransport{next: http.DefaultTransport, maxRetries: 5, after: noWait}}
+
+	resp, err := client.Post(server.URL, "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(b) != "payload" {
+		t.Fatalf("expected the retried request to succeed with its body, got %d %q", resp.StatusCode, b)
+	}
+	if *requests != 3 {
+		t.Fatalf("expected 3 requests, got %d", *requests)
+	}
+}
+
+// TestRetryTransportGivesUp ensures requests stop being retried after the maximum retries
+func TestRetryTransportGivesUp(t *testing.T) {
+	server, requests := throttlingServer(t, 10)
+	client := &http.Client{Transport: &retryTransport{next: http.DefaultTransport, maxRetries: 2, after: noWait}}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || *requests != 3 {
+		t.Fatalf("expected a 429 after 3 requests, got %d after %d", resp.StatusCode, *requests)
+	}
+}
+
+// TestRetryBackoff ensures the Retry-After header is honored, waits grow with each attempt and are capped
+func TestRetryBackoff(t *testing.T) {
+	var testCases = []struct {
+		attempt    int
+		retryAfter string
+		min        time.Duration
+	}{
+		{0, "", defaultRetryBackoff},
+		{3, "", defaultRetryBackoff * 8},
+		{0, "2", time.Second * 2},
+		{0, "600", maxRetryBackoff},
+		{40, "", maxRetryBackoff},
+	}
+	for _, tc := range testCases {
+		wait := retryBackoff(tc.attempt, tc.retryAfter)
+		if wait < tc.min || wait > tc.min+tc.min/2 {
+			t.Fatalf("backoff of attempt %d with Retry-After %q was %s, expected %s plus up to half of it as jitter", tc.attempt, tc.retryAfter, wait, tc.min)
+		}
+	}
+}
+
+// TestConfigure ensures options override the rest config and zero values leave it alone
+func TestConfigure(t *testing.T) {
+	config := &rest.Config{QPS: 5, Burst: 10}
+	Configure(config, Options{Burst: 40, UserAgent: UserAgent})
+	if config.QPS != 5 || config.Burst != 40 || config.UserAgent != UserAgent || config.Timeout != 0 {
+		t.Fatalf("unexpected config: %+v", config)
+	}
+}
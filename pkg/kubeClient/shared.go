@@ -0,0 +1,47 @@
+package kubeClient
+
+import (
+	"net/http"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Shared holds the rest config and HTTP client that the Kubernetes clients of a process are built from.  Clients
+// built from the same Shared reuse one transport and its connection pool instead of each dialing the API server and
+// negotiating TLS on their own.
+type Shared struct {
+	Config     *rest.Config
+	HTTPClient *http.Client
+}
+
+// NewShared loads the in cluster client config, falling back to the supplied kube config file, applies the options
+// to it and creates the HTTP client that clients built from it share
+func NewShared(kubeConfigFile string, o Options) (*Shared, error) {
+	config, err := RestConfig(kubeConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	Configure(config, o)
+	return NewSharedForConfig(config)
+}
+
+// NewSharedForConfig creates the HTTP client that clients built from the supplied rest config share
+func NewSharedForConfig(config *rest.Config) (*Shared, error) {
+	httpClient, err := rest.HTTPClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Shared{Config: config, HTTPClient: httpClient}, nil
+}
+
+// Clientset creates a kubernetes api clientset that uses the shared HTTP client
+func (s *Shared) Clientset() (*kubernetes.Clientset, error) {
+	return kubernetes.NewForConfigAndClient(s.Config, s.HTTPClient)
+}
+
+// Dynamic creates a dynamic client that uses the shared HTTP client
+func (s *Shared) Dynamic() (dynamic.Interface, error) {
+	return dynamic.NewForConfigAndClient(s.Config, s.HTTPClient)
+}
@@ -3,22 +3,26 @@ package metrics // import "github.com/kuberhealthy/kuberhealthy/v2/pkg/metrics"
 import (
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
 	"github.com/kuberhealthy/kuberhealthy/v2/pkg/health"
 )
 
 type PromMetricsConfig struct {
 	SuppressErrorLabel  bool `yaml:"suppressErrorLabel,omitempty"`  // do we want to supress error label in metrics output(default: false)
 	ErrorLabelMaxLength int  `yaml:"errorLabelMaxLength,omitempty"` // if not suppress, then bound the error label value length to a number of bytes
+	ProbeMetrics        bool `yaml:"probeMetrics,omitempty"`        // also publish check states as blackbox-exporter compatible probe_success and probe_duration_seconds series
 }
 
 // promMetricName: helper fn for GenerateMetrics, does a quick format of the metric line - checkOrJob is literally the string "check" or "job"
-func promMetricName(config PromMetricsConfig, checkOrJob string, checkName string, namespace string, status string, errors []string) string {
-	metricName := fmt.Sprintf("kuberhealthy_%s{check=\"%s\",namespace=\"%s\",status=\"%s\"", checkOrJob, checkName, namespace, status)
+func promMetricName(config PromMetricsConfig, checkOrJob string, checkName string, namespace string, status string, errors []string, labels map[string]string) string {
+	metricName := fmt.Sprintf("kuberhealthy_%s{check=\"%s\",namespace=\"%s\",status=\"%s\"%s", checkOrJob, checkName, namespace, status, promLabels(labels))
 	if !config.SuppressErrorLabel {
 		errorsStr := ""
 		if len(errors) > 0 {
@@ -38,6 +42,45 @@ func promMetricName(config PromMetricsConfig, checkOrJob string, checkName strin
 	return metricName
 }
 
+// promLabels formats the propagated labels of a check or job as extra metric labels.  Like kube-state-metrics, each
+// label is named label_<key> with the characters Prometheus does not allow in label names replaced by underscores.
+// When two keys end up with the same name, only the first in sorted order is kept.
+func promLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		name := "label_" + sanitizeLabelName(key)
+		if seen[name] {
+			log.Debugln("Not adding label", key, "to metrics because another label is also named", name)
+			continue
+		}
+		seen[name] = true
+		fmt.Fprintf(&b, ",%s=\"%s\"", name, escapeLabelValue(labels[key]))
+	}
+	return b.String()
+}
+
+// sanitizeLabelName replaces the characters of a Kubernetes label key that are not allowed in Prometheus label names
+func sanitizeLabelName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// escapeLabelValue escapes a label value for the Prometheus text format
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(value)
+}
+
 //GenerateMetrics takes the state and returns it in the Prometheus format
 func GenerateMetrics(state health.State, config PromMetricsConfig) string {
 	metricsOutput := ""
@@ -52,6 +95,16 @@ func GenerateMetrics(state health.State, config PromMetricsConfig) string {
 	metricsOutput += "# HELP kuberhealthy_cluster_state Shows the status of the cluster\n"
 	metricsOutput += "# TYPE kuberhealthy_cluster_state gauge\n"
 	metricsOutput += fmt.Sprintf("kuberhealthy_cluster_state %s\n", healthStatus)
+	degraded := "0"
+	if state.Degraded {
+		degraded = "1"
+	}
+	metricsOutput += "# HELP kuberhealthy_degraded Shows if the Kubernetes API is unavailable and the check states served are out of date\n"
+	metricsOutput += "# TYPE kuberhealthy_degraded gauge\n"
+	metricsOutput += fmt.Sprintf("kuberhealthy_degraded %s\n", degraded)
+	metricsOutput += "# HELP kuberhealthy_cluster_warnings Shows the number of warnings reported by checks and jobs, which do not change the status of the cluster\n"
+	metricsOutput += "# TYPE kuberhealthy_cluster_warnings gauge\n"
+	metricsOutput += fmt.Sprintf("kuberhealthy_cluster_warnings %d\n", len(state.Warnings))
 
 	metricCheckState := make(map[string]string)
 	metricCheckDuration := make(map[string]string)
@@ -64,8 +117,8 @@ func GenerateMetrics(state health.State, config PromMetricsConfig) string {
 		if d.OK {
 			checkStatus = "1"
 		}
-		metricName := promMetricName(config, "check", c, d.Namespace, checkStatus, d.Errors)
-		metricDurationName := fmt.Sprintf("kuberhealthy_check_duration_seconds{check=\"%s\",namespace=\"%s\"}", c, d.Namespace)
+		metricName := promMetricName(config, "check", c, d.Namespace, checkStatus, d.Errors, d.Labels)
+		metricDurationName := fmt.Sprintf("kuberhealthy_check_duration_seconds{check=\"%s\",namespace=\"%s\"%s}", c, d.Namespace, promLabels(d.Labels))
 		metricCheckState[metricName] = checkStatus
 
 		// if runDuration hasn't been set yet, ie. pod never ran or failed to provision, set runDuration to 0
@@ -85,8 +138,8 @@ func GenerateMetrics(state health.State, config PromMetricsConfig) string {
 		if d.OK {
 			jobStatus = "1"
 		}
-		metricName := promMetricName(config, "job", c, d.Namespace, jobStatus, d.Errors)
-		metricDurationName := fmt.Sprintf("kuberhealthy_job_duration_seconds{check=\"%s\",namespace=\"%s\"}", c, d.Namespace)
+		metricName := promMetricName(config, "job", c, d.Namespace, jobStatus, d.Errors, d.Labels)
+		metricDurationName := fmt.Sprintf("kuberhealthy_job_duration_seconds{check=\"%s\",namespace=\"%s\"%s}", c, d.Namespace, promLabels(d.Labels))
 		metricJobState[metricName] = jobStatus
 
 		// if runDuration hasn't been set yet, ie. pod never ran or failed to provision, set runDuration to 0
@@ -113,6 +166,10 @@ func GenerateMetrics(state health.State, config PromMetricsConfig) string {
 	for m, v := range metricCheckDuration {
 		metricsOutput += fmt.Sprintf("%s %s\n", m, v)
 	}
+	metricsOutput += checkUnknownMetrics(state)
+	metricsOutput += "# HELP kuberhealthy_check_warnings Shows the number of warnings reported by the last run of a Kuberhealthy check\n"
+	metricsOutput += "# TYPE kuberhealthy_check_warnings gauge\n"
+	metricsOutput += warningSeries("kuberhealthy_check_warnings", state.CheckDetails)
 	// Kuberhealthy job metrics
 	metricsOutput += "# HELP kuberhealthy_job Shows the status of a Kuberhealthy job\n"
 	metricsOutput += "# TYPE kuberhealthy_job gauge\n"
@@ -124,10 +181,168 @@ func GenerateMetrics(state health.State, config PromMetricsConfig) string {
 	for m, v := range metricJobDuration {
 		metricsOutput += fmt.Sprintf("%s %s\n", m, v)
 	}
+	metricsOutput += "# HELP kuberhealthy_job_warnings Shows the number of warnings reported by the last run of a Kuberhealthy job\n"
+	metricsOutput += "# TYPE kuberhealthy_job_warnings gauge\n"
+	metricsOutput += warningSeries("kuberhealthy_job_warnings", state.JobDetails)
+
+	metricsOutput += checkReportedMetrics(state)
+	metricsOutput += checkSubCheckMetrics(state)
+
+	if config.ProbeMetrics {
+		metricsOutput += probeMetrics(state)
+	}
 
 	return metricsOutput
 }
 
+// checkUnknownMetrics publishes which checks have not recorded a result for too long and are shown as unknown, so
+// that alerts can tell a check whose last result is out of date apart from one that is failing
+func checkUnknownMetrics(state health.State) string {
+	var checks []string
+	for c := range state.CheckDetails {
+		checks = append(checks, c)
+	}
+	sort.Strings(checks)
+
+	output := "# HELP kuberhealthy_check_unknown Shows if a Kuberhealthy check has not recorded a result for too long and its state is unknown\n"
+	output += "# TYPE kuberhealthy_check_unknown gauge\n"
+	for _, c := range checks {
+		d := state.CheckDetails[c]
+		unknown := "0"
+		if d.State == khstatev1.ResultUnknown {
+			unknown = "1"
+		}
+		output += fmt.Sprintf("kuberhealthy_check_unknown{check=\"%s\",namespace=\"%s\"} %s\n", c, d.Namespace, unknown)
+	}
+	return output
+}
+
+// warningSeries formats the number of warnings reported by the last run of each workload as series of the supplied
+// metric, so that alerts can tell a check that is degrading apart from one that is failing
+func warningSeries(metric string, details map[string]khstatev1.WorkloadDetails) string {
+	var workloads []string
+	for w := range details {
+		workloads = append(workloads, w)
+	}
+	sort.Strings(workloads)
+
+	series := ""
+	for _, w := range workloads {
+		d := details[w]
+		series += fmt.Sprintf("%s{check=\"%s\",namespace=\"%s\"%s} %d\n", metric, w, d.Namespace, promLabels(d.Labels), len(d.Warnings))
+	}
+	return series
+}
+
+// checkReportedMetrics publishes the measurements reported by checks and jobs along with their results as gauges
+// labeled by the check and the name of the metric.  Series are sorted so that the output is stable between scrapes.
+func checkReportedMetrics(state health.State) string {
+	output := "# HELP kuberhealthy_check_metric Shows a measurement reported by a Kuberhealthy check\n"
+	output += "# TYPE kuberhealthy_check_metric gauge\n"
+	output += reportedMetricSeries("kuberhealthy_check_metric", state.CheckDetails)
+	output += "# HELP kuberhealthy_job_metric Shows a measurement reported by a Kuberhealthy job\n"
+	output += "# TYPE kuberhealthy_job_metric gauge\n"
+	output += reportedMetricSeries("kuberhealthy_job_metric", state.JobDetails)
+	return output
+}
+
+// reportedMetricSeries formats the reported metrics of each workload as series of the supplied metric
+func reportedMetricSeries(metric string, details map[string]khstatev1.WorkloadDetails) string {
+	var workloads []string
+	for w := range details {
+		workloads = append(workloads, w)
+	}
+	sort.Strings(workloads)
+
+	series := ""
+	for _, w := range workloads {
+		d := details[w]
+		var names []string
+		for name := range d.Metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			series += fmt.Sprintf("%s{check=\"%s\",namespace=\"%s\",metric=\"%s\"} %s\n", metric, w, d.Namespace, name, strconv.FormatFloat(d.Metrics[name], 'g', -1, 64))
+		}
+	}
+	return series
+}
+
+// checkSubCheckMetrics publishes the result of each sub-check reported by the last run of checks and jobs as gauges
+// labeled by the check and the name of the sub-check, so that alerts can tell which part of a check is failing
+func checkSubCheckMetrics(state health.State) string {
+	output := "# HELP kuberhealthy_check_subcheck Shows the status of a sub-check reported by the last run of a Kuberhealthy check\n"
+	output += "# TYPE kuberhealthy_check_subcheck gauge\n"
+	output += subCheckSeries("kuberhealthy_check_subcheck", state.CheckDetails)
+	output += "# HELP kuberhealthy_job_subcheck Shows the status of a sub-check reported by the last run of a Kuberhealthy job\n"
+	output += "# TYPE kuberhealthy_job_subcheck gauge\n"
+	output += subCheckSeries("kuberhealthy_job_subcheck", state.JobDetails)
+	return output
+}
+
+// subCheckSeries formats the sub-checks of each workload as series of the supplied metric, in the order they were
+// reported
+func subCheckSeries(metric string, details map[string]khstatev1.WorkloadDetails) string {
+	var workloads []string
+	for w := range details {
+		workloads = append(workloads, w)
+	}
+	sort.Strings(workloads)
+
+	series := ""
+	for _, w := range workloads {
+		d := details[w]
+		for _, sc := range d.SubChecks {
+			ok := "0"
+			if sc.OK {
+				ok = "1"
+			}
+			series += fmt.Sprintf("%s{check=\"%s\",namespace=\"%s\",subcheck=\"%s\"%s} %s\n", metric, w, d.Namespace, escapeLabelValue(sc.Name), promLabels(d.Labels), ok)
+		}
+	}
+	return series
+}
+
+// probeMetrics publishes check states using the metric names of the Prometheus blackbox-exporter so that dashboards
+// and alert rules written for the blackbox-exporter keep working.  The instance label holds the same namespace/name
+// check key as the check label.  Prometheus replaces the instance label of scraped series unless honor_labels is set on the scrape.
+func probeMetrics(state health.State) string {
+	var checks []string
+	for c := range state.CheckDetails {
+		checks = append(checks, c)
+	}
+	sort.Strings(checks)
+
+	probeSuccess := ""
+	probeDuration := ""
+	for _, c := range checks {
+		d := state.CheckDetails[c]
+		labels := fmt.Sprintf("instance=\"%s\",check=\"%s\",namespace=\"%s\"", c, c, d.Namespace)
+
+		success := "0"
+		if d.OK {
+			success = "1"
+		}
+		probeSuccess += fmt.Sprintf("probe_success{%s} %s\n", labels, success)
+
+		// checks that have not run yet have no duration
+		runDuration, err := time.ParseDuration(d.RunDuration)
+		if err != nil {
+			runDuration = 0
+		}
+		probeDuration += fmt.Sprintf("probe_duration_seconds{%s} %f\n", labels, runDuration.Seconds())
+	}
+
+	output := "# HELP probe_success Displays whether or not the probe was a success\n"
+	output += "# TYPE probe_success gauge\n"
+	output += probeSuccess
+	output += "# HELP probe_duration_seconds Returns how long the probe took to complete in seconds\n"
+	output += "# TYPE probe_duration_seconds gauge\n"
+	output += probeDuration
+	return output
+}
+
 //ErrorStateMetrics is a Prometheus metric meant to show Kuberhealthy has error
 func ErrorStateMetrics(state health.State) string {
 	errorOutput := ""
@@ -44,6 +44,18 @@ func TestGenerateMetrics(t *testing.T) {
 	if metrics["kuberhealthy_cluster_state"] != "1" {
 		t.Fatal("Kuberhealthy shows cluster as not healthy when it is")
 	}
+	if metrics["kuberhealthy_degraded"] != "0" {
+		t.Fatal("Kuberhealthy shows as degraded when it isn't")
+	}
+	// Test degraded state
+	state = health.State{
+		OK:       true,
+		Degraded: true,
+	}
+	metrics = parseMetrics(GenerateMetrics(state, PromMetricsConfig{}))
+	if metrics["kuberhealthy_degraded"] != "1" {
+		t.Fatal("Kuberhealthy does not show as degraded when it is")
+	}
 	// Test not OK state
 	state = health.State{
 		OK: false,
@@ -99,6 +111,14 @@ func TestGenerateMetrics(t *testing.T) {
 	if metrics[`kuberhealthy_check{check="",namespace="",status="1",error=""}`] != "1" {
 		t.Fatal("Kuberhealthy good check shows as bad")
 	}
+	if metrics[`kuberhealthy_check_unknown{check="good",namespace=""}`] != "0" {
+		t.Fatal("Kuberhealthy good check shows as unknown")
+	}
+	state.CheckDetails["good"] = khstatev1.WorkloadDetails{OK: true, State: khstatev1.ResultUnknown}
+	metrics = parseMetrics(GenerateMetrics(state, PromMetricsConfig{}))
+	if metrics[`kuberhealthy_check_unknown{check="good",namespace=""}`] != "1" {
+		t.Fatal("Kuberhealthy stale check does not show as unknown")
+	}
 	state = health.State{
 		CheckDetails: map[string]khstatev1.WorkloadDetails{
 			"bad": {
@@ -181,3 +201,194 @@ func TestWriteMetricError(t *testing.T) {
 		t.Fatal("Error Metric does not match actual error metric function")
 	}
 }
+
+// TestGenerateProbeMetrics ensures that check states are published as blackbox-exporter compatible series only when
+// enabled
+func TestGenerateProbeMetrics(t *testing.T) {
+	state := health.State{
+		CheckDetails: map[string]khstatev1.WorkloadDetails{
+			"kuberhealthy/good": {
+				OK:          true,
+				Namespace:   "kuberhealthy",
+				RunDuration: "1.5s",
+			},
+			"checks/bad": {
+				OK:        false,
+				Namespace: "checks",
+			},
+		},
+	}
+
+	metrics := parseMetrics(GenerateMetrics(state, PromMetricsConfig{}))
+	for m := range metrics {
+		if strings.HasPrefix(m, "probe_") {
+			t.Fatal("probe metric published when probe metrics are disabled:", m)
+		}
+	}
+
+	metrics = parseMetrics(GenerateMetrics(state, PromMetricsConfig{ProbeMetrics: true}))
+	var testCases = []struct {
+		metric string
+		value  string
+	}{
+		{`probe_success{instance="kuberhealthy/good",check="kuberhealthy/good",namespace="kuberhealthy"}`, "1"},
+		{`probe_duration_seconds{instance="kuberhealthy/good",check="kuberhealthy/good",namespace="kuberhealthy"}`, "1.500000"},
+		{`probe_success{instance="checks/bad",check="checks/bad",namespace="checks"}`, "0"},
+		{`probe_duration_seconds{instance="checks/bad",check="checks/bad",namespace="checks"}`, "0.000000"},
+	}
+	for _, tc := range testCases {
+		if metrics[tc.metric] != tc.value {
+			t.Fatalf("metric %s was %q but expected %q", tc.metric, metrics[tc.metric], tc.value)
+		}
+	}
+}
+
+// TestGenerateReportedMetrics ensures the metrics reported by checks and jobs are published as gauges
+func TestGenerateReportedMetrics(t *testing.T) {
+	state := health.State{
+		CheckDetails: map[string]khstatev1.WorkloadDetails{
+			"kuberhealthy/dns": {
+				OK:        true,
+				Namespace: "kuberhealthy",
+				Metrics:   map[string]float64{"latency_ms": 12.5, "lookups": 3},
+			},
+		},
+		JobDetails: map[string]khstatev1.WorkloadDetails{
+			"kuberhealthy/scan": {
+				OK:        true,
+				Namespace: "kuberhealthy",
+				Metrics:   map[string]float64{"images": 42},
+			},
+		},
+	}
+
+	metrics := parseMetrics(GenerateMetrics(state, PromMetricsConfig{}))
+	var testCases = []struct {
+		metric string
+		value  string
+	}{
+		{`kuberhealthy_check_metric{check="kuberhealthy/dns",namespace="kuberhealthy",metric="latency_ms"}`, "12.5"},
+		{`kuberhealthy_check_metric{check="kuberhealthy/dns",namespace="kuberhealthy",metric="lookups"}`, "3"},
+		{`kuberhealthy_job_metric{check="kuberhealthy/scan",namespace="kuberhealthy",metric="images"}`, "42"},
+	}
+	for _, tc := range testCases {
+		if metrics[tc.metric] != tc.value {
+			t.Fatalf("metric %s was %q but expected %q", tc.metric, metrics[tc.metric], tc.value)
+		}
+	}
+}
+
+// TestGeneratePropagatedLabelMetrics ensures the propagated labels of checks and jobs are added to their series with
+// names Prometheus accepts and escaped values
+func TestGeneratePropagatedLabelMetrics(t *testing.T) {
+	state := health.State{
+		CheckDetails: map[string]khstatev1.WorkloadDetails{
+			"kuberhealthy/dns": {
+				OK:          true,
+				Namespace:   "kuberhealthy",
+				RunDuration: "2s",
+				Labels:      map[string]string{"team": "network", "app.kubernetes.io/tier": `"critical"`, "app_kubernetes_io/tier": "shadowed"},
+			},
+		},
+		JobDetails: map[string]khstatev1.WorkloadDetails{
+			"kuberhealthy/scan": {
+				OK:        false,
+				Namespace: "kuberhealthy",
+				Labels:    map[string]string{"team": "security"},
+			},
+		},
+	}
+
+	metrics := parseMetrics(GenerateMetrics(state, PromMetricsConfig{SuppressErrorLabel: true}))
+	var testCases = []struct {
+		metric string
+		value  string
+	}{
+		{`kuberhealthy_check{check="kuberhealthy/dns",namespace="kuberhealthy",status="1",label_app_kubernetes_io_tier="\"critical\"",label_team="network"}`, "1"},
+		{`kuberhealthy_check_duration_seconds{check="kuberhealthy/dns",namespace="kuberhealthy",label_app_kubernetes_io_tier="\"critical\"",label_team="network"}`, "2.000000"},
+		{`kuberhealthy_job{check="kuberhealthy/scan",namespace="kuberhealthy",status="0",label_team="security"}`, "0"},
+		{`kuberhealthy_job_duration_seconds{check="kuberhealthy/scan",namespace="kuberhealthy",label_team="security"}`, "0.000000"},
+	}
+	for _, tc := range testCases {
+		if metrics[tc.metric] != tc.value {
+			t.Fatalf("metric %s was %q but expected %q", tc.metric, metrics[tc.metric], tc.value)
+		}
+	}
+}
+
+// TestGenerateWarningMetrics ensures warnings are counted for the cluster and for each check and job without changing
+// their status
+func TestGenerateWarningMetrics(t *testing.T) {
+	state := health.State{
+		OK:       true,
+		Warnings: []string{"certificate expires in 20 days", "2 of 3 replicas ready"},
+		CheckDetails: map[string]khstatev1.WorkloadDetails{
+			"kuberhealthy/cert": {
+				OK:        true,
+				Namespace: "kuberhealthy",
+				Warnings:  []string{"certificate expires in 20 days"},
+			},
+			"kuberhealthy/dns": {
+				OK:        true,
+				Namespace: "kuberhealthy",
+			},
+		},
+		JobDetails: map[string]khstatev1.WorkloadDetails{
+			"kuberhealthy/rollout": {
+				OK:        true,
+				Namespace: "kuberhealthy",
+				Warnings:  []string{"2 of 3 replicas ready"},
+			},
+		},
+	}
+
+	metrics := parseMetrics(GenerateMetrics(state, PromMetricsConfig{SuppressErrorLabel: true}))
+	var testCases = []struct {
+		metric string
+		value  string
+	}{
+		{"kuberhealthy_cluster_state", "1"},
+		{"kuberhealthy_cluster_warnings", "2"},
+		{`kuberhealthy_check{check="kuberhealthy/cert",namespace="kuberhealthy",status="1"}`, "1"},
+		{`kuberhealthy_check_warnings{check="kuberhealthy/cert",namespace="kuberhealthy"}`, "1"},
+		{`kuberhealthy_check_warnings{check="kuberhealthy/dns",namespace="kuberhealthy"}`, "0"},
+		{`kuberhealthy_job_warnings{check="kuberhealthy/rollout",namespace="kuberhealthy"}`, "1"},
+	}
+	for _, tc := range testCases {
+		if metrics[tc.metric] != tc.value {
+			t.Fatalf("metric %s was %q but expected %q", tc.metric, metrics[tc.metric], tc.value)
+		}
+	}
+}
+
+// TestGenerateSubCheckMetrics ensures the result of each reported sub-check is published under its check
+func TestGenerateSubCheckMetrics(t *testing.T) {
+	state := health.State{
+		OK: false,
+		CheckDetails: map[string]khstatev1.WorkloadDetails{
+			"kuberhealthy/dns": {
+				Namespace: "kuberhealthy",
+				Errors:    []string{"example.com: lookup timed out"},
+				SubChecks: []khstatev1.SubCheckStatus{
+					{Name: "kube-dns", OK: true},
+					{Name: "example.com", Errors: []string{"lookup timed out"}},
+				},
+			},
+		},
+		JobDetails: map[string]khstatev1.WorkloadDetails{},
+	}
+
+	metrics := parseMetrics(GenerateMetrics(state, PromMetricsConfig{SuppressErrorLabel: true}))
+	var testCases = []struct {
+		metric string
+		value  string
+	}{
+		{`kuberhealthy_check_subcheck{check="kuberhealthy/dns",namespace="kuberhealthy",subcheck="kube-dns"}`, "1"},
+		{`kuberhealthy_check_subcheck{check="kuberhealthy/dns",namespace="kuberhealthy",subcheck="example.com"}`, "0"},
+	}
+	for _, tc := range testCases {
+		if metrics[tc.metric] != tc.value {
+			t.Fatalf("metric %s was %q but expected %q", tc.metric, metrics[tc.metric], tc.value)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/health"
+)
+
+// Sizes of panels in the generated dashboard, in grafana grid units.  The grafana grid is 24 units wide.
+const (
+	dashboardWidth      = 24
+	checkPanelWidth     = 4
+	checkPanelHeight    = 4
+	durationPanelHeight = 8
+)
+
+// dashboardUID is the uid of the generated dashboard.  It stays the same so that importing a newer dashboard
+// replaces the previous one.
+const dashboardUID = "kuberhealthy-checks"
+
+// grafanaDashboard is the subset of the grafana dashboard model used by the generated dashboard
+type grafanaDashboard struct {
+	UID           string            `json:"uid"`
+	Title         string            `json:"title"`
+	Tags          []string          `json:"tags"`
+	Editable      bool              `json:"editable"`
+	Refresh       string            `json:"refresh"`
+	SchemaVersion int               `json:"schemaVersion"`
+	Time          map[string]string `json:"time"`
+	Templating    grafanaTemplating `json:"templating"`
+	Panels        []grafanaPanel    `json:"panels"`
+}
+
+type grafanaTemplating struct {
+	List []grafanaVariable `json:"list"`
+}
+
+type grafanaVariable struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	Query string `json:"query"`
+}
+
+type grafanaPanel struct {
+	ID          int                    `json:"id"`
+	Type        string                 `json:"type"`
+	Title       string                 `json:"title"`
+	GridPos     grafanaGridPos         `json:"gridPos"`
+	Datasource  string                 `json:"datasource,omitempty"`
+	Targets     []grafanaTarget        `json:"targets,omitempty"`
+	FieldConfig map[string]interface{} `json:"fieldConfig,omitempty"`
+	Collapsed   bool                   `json:"collapsed,omitempty"`
+}
+
+type grafanaGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type grafanaTarget struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+	RefID        string `json:"refId"`
+}
+
+// GenerateDashboard builds a grafana dashboard for the checks in the supplied state.  The dashboard has an overview
+// row for Kuberhealthy itself followed by one row per namespace with a status panel for each check in the namespace
+// and a graph of their run durations.
+func GenerateDashboard(state health.State) ([]byte, error) {
+
+	// group checks by namespace so that each namespace gets its own row
+	checksByNamespace := make(map[string][]string)
+	for c, d := range state.CheckDetails {
+		checksByNamespace[d.Namespace] = append(checksByNamespace[d.Namespace], c)
+	}
+	var namespaces []string
+	for ns := range checksByNamespace {
+		namespaces = append(namespaces, ns)
+		sort.Strings(checksByNamespace[ns])
+	}
+	sort.Strings(namespaces)
+
+	d := grafanaDashboard{
+		UID:           dashboardUID,
+		Title:         "Kuberhealthy Checks",
+		Tags:          []string{"kuberhealthy"},
+		Editable:      true,
+		Refresh:       "1m",
+		SchemaVersion: 30,
+		Time:          map[string]string{"from": "now-6h", "to": "now"},
+		Templating: grafanaTemplating{List: []grafanaVariable{{
+			Name:  "datasource",
+			Label: "Data Source",
+			Type:  "datasource",
+			Query: "prometheus",
+		}}},
+	}
+
+	id := 0
+	y := 0
+	addPanel := func(p grafanaPanel) {
+		id++
+		p.ID = id
+		if p.Type != "row" {
+			p.Datasource = "${datasource}"
+		}
+		d.Panels = append(d.Panels, p)
+	}
+
+	addPanel(grafanaPanel{Type: "row", Title: "Kuberhealthy", GridPos: grafanaGridPos{H: 1, W: dashboardWidth, Y: y}})
+	y++
+	addPanel(grafanaPanel{
+		Type:        "stat",
+		Title:       "Kuberhealthy Running",
+		GridPos:     grafanaGridPos{H: checkPanelHeight, W: dashboardWidth / 2, Y: y},
+		Targets:     []grafanaTarget{{Expr: "max(kuberhealthy_running)", RefID: "A"}},
+		FieldConfig: statusFieldConfig("Running", "Not Running"),
+	})
+	addPanel(grafanaPanel{
+		Type:        "stat",
+		Title:       "Cluster State",
+		GridPos:     grafanaGridPos{H: checkPanelHeight, W: dashboardWidth / 2, X: dashboardWidth / 2, Y: y},
+		Targets:     []grafanaTarget{{Expr: "min(kuberhealthy_cluster_state)", RefID: "A"}},
+		FieldConfig: statusFieldConfig("OK", "Failing"),
+	})
+	y += checkPanelHeight
+
+	for _, ns := range namespaces {
+		addPanel(grafanaPanel{Type: "row", Title: "Namespace " + ns, GridPos: grafanaGridPos{H: 1, W: dashboardWidth, Y: y}})
+		y++
+
+		// lay check status panels out left to right, wrapping onto new lines as needed
+		x := 0
+		for _, c := range checksByNamespace[ns] {
+			if x+checkPanelWidth > dashboardWidth {
+				x = 0
+				y += checkPanelHeight
+			}
+			addPanel(grafanaPanel{
+				Type:        "stat",
+				Title:       strings.TrimPrefix(c, ns+"/"),
+				GridPos:     grafanaGridPos{H: checkPanelHeight, W: checkPanelWidth, X: x, Y: y},
+				Targets:     []grafanaTarget{{Expr: fmt.Sprintf("min(kuberhealthy_check{check=%q,namespace=%q})", c, ns), RefID: "A"}},
+				FieldConfig: statusFieldConfig("OK", "Failing"),
+			})
+			x += checkPanelWidth
+		}
+		y += checkPanelHeight
+
+		addPanel(grafanaPanel{
+			Type:    "timeseries",
+			Title:   "Check Run Durations",
+			GridPos: grafanaGridPos{H: durationPanelHeight, W: dashboardWidth, Y: y},
+			Targets: []grafanaTarget{{
+				Expr:         fmt.Sprintf("kuberhealthy_check_duration_seconds{namespace=%q}", ns),
+				LegendFormat: "{{check}}",
+				RefID:        "A",
+			}},
+			FieldConfig: map[string]interface{}{"defaults": map[string]interface{}{"unit": "s"}},
+		})
+		y += durationPanelHeight
+	}
+
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// statusFieldConfig maps a 1 or 0 status value to text and a green or red background
+func statusFieldConfig(okText string, failedText string) map[string]interface{} {
+	return map[string]interface{}{
+		"defaults": map[string]interface{}{
+			"mappings": []interface{}{
+				map[string]interface{}{
+					"type": "value",
+					"options": map[string]interface{}{
+						"0": map[string]interface{}{"text": failedText, "color": "red"},
+						"1": map[string]interface{}{"text": okText, "color": "green"},
+					},
+				},
+			},
+			"color": map[string]interface{}{"mode": "thresholds"},
+			"thresholds": map[string]interface{}{
+				"mode": "absolute",
+				"steps": []interface{}{
+					map[string]interface{}{"color": "red", "value": nil},
+					map[string]interface{}{"color": "green", "value": 1},
+				},
+			},
+		},
+	}
+}
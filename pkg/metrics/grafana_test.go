@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/health"
+)
+
+// TestGenerateDashboard ensures that the generated dashboard has a row per namespace with a panel for each check
+func TestGenerateDashboard(t *testing.T) {
+	state := health.State{
+		CheckDetails: map[string]khstatev1.WorkloadDetails{
+			"kuberhealthy/deployment": {Namespace: "kuberhealthy"},
+			"kuberhealthy/dns":        {Namespace: "kuberhealthy"},
+			"payments/http":           {Namespace: "payments"},
+		},
+	}
+
+	b, err := GenerateDashboard(state)
+	if err != nil {
+		t.Fatalf("failed to generate dashboard: %s", err)
+	}
+
+	dashboard := grafanaDashboard{}
+	err = json.Unmarshal(b, &dashboard)
+	if err != nil {
+		t.Fatalf("generated dashboard is not valid JSON: %s", err)
+	}
+
+	var rows []string
+	checkPanels := make(map[string]string)
+	ids := make(map[int]bool)
+	for _, p := range dashboard.Panels {
+		if ids[p.ID] {
+			t.Fatalf("panel id %d is used more than once", p.ID)
+		}
+		ids[p.ID] = true
+		if p.Type == "row" {
+			rows = append(rows, p.Title)
+			continue
+		}
+		if p.Type == "stat" && strings.HasPrefix(p.Targets[0].Expr, "min(kuberhealthy_check{") {
+			checkPanels[p.Title] = p.Targets[0].Expr
+		}
+	}
+
+	expectedRows := []string{"Kuberhealthy", "Namespace kuberhealthy", "Namespace payments"}
+	if strings.Join(rows, ",") != strings.Join(expectedRows, ",") {
+		t.Fatalf("dashboard rows were %v but expected %v", rows, expectedRows)
+	}
+	if len(checkPanels) != 3 {
+		t.Fatalf("expected 3 check panels but found %v", checkPanels)
+	}
+	if checkPanels["http"] != `min(kuberhealthy_check{check="payments/http",namespace="payments"})` {
+		t.Fatalf("unexpected query for the http check panel: %s", checkPanels["http"])
+	}
+}
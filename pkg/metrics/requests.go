@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DurationBuckets are the upper bounds, in seconds, of the buckets request durations are counted in
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// SizeBuckets are the upper bounds, in bytes, of the buckets request and response body sizes are counted in
+var SizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+
+// histogram counts observations into cumulative buckets the way a prometheus histogram does
+type histogram struct {
+	bounds []float64
+	counts []uint64 // the observations in each bucket, not cumulative, with the last counting those above every bound
+	sum    float64
+	count  uint64
+}
+
+// newHistogram makes a histogram with the supplied bucket upper bounds
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+// observe counts a value into its bucket
+func (h *histogram) observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// write appends the bucket, sum and count series of the histogram to b
+func (h *histogram) write(b *strings.Builder, name string, labels string) {
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		fmt.Fprintf(b, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(b, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(b, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// requestKey identifies the requests counted together in kuberhealthy_http_requests_total
+type requestKey struct {
+	endpoint string
+	method   string
+	code     int
+}
+
+// endpointMetrics are the histograms of the requests to one endpoint
+type endpointMetrics struct {
+	duration     *histogram
+	requestSize  *histogram
+	responseSize *histogram
+}
+
+// RequestMetrics records the requests served by the Kuberhealthy web server per endpoint, so that operators can see
+// clients hammering an endpoint or reports failing.  Endpoints should be the patterns requests are routed by rather
+// than request paths, which would give every run UUID its own series.  A nil RequestMetrics records nothing.  It is safe
+// for concurrent use.
+type RequestMetrics struct {
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	endpoints map[string]*endpointMetrics
+}
+
+// NewRequestMetrics makes an empty set of request metrics
+func NewRequestMetrics() *RequestMetrics {
+	return &RequestMetrics{
+		requests:  make(map[requestKey]uint64),
+		endpoints: make(map[string]*endpointMetrics),
+	}
+}
+
+// Observe records a request served by an endpoint
+func (m *RequestMetrics) Observe(endpoint string, method string, code int, duration time.Duration, requestSize int64, responseSize int64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestKey{endpoint: endpoint, method: method, code: code}]++
+
+	e, ok := m.endpoints[endpoint]
+	if !ok {
+		e = &endpointMetrics{
+			duration:     newHistogram(DurationBuckets),
+			requestSize:  newHistogram(SizeBuckets),
+			responseSize: newHistogram(SizeBuckets),
+		}
+		m.endpoints[endpoint] = e
+	}
+	e.duration.observe(duration.Seconds())
+	e.requestSize.observe(float64(requestSize))
+	e.responseSize.observe(float64(responseSize))
+}
+
+// PrometheusMetrics writes the request metrics in the prometheus text format
+func (m *RequestMetrics) PrometheusMetrics() string {
+	if m == nil {
+		return ""
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.requests) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].code < keys[j].code
+	})
+	b.WriteString("# HELP kuberhealthy_http_requests_total Shows the number of requests served by each endpoint of the kuberhealthy web server by method and status code\n")
+	b.WriteString("# TYPE kuberhealthy_http_requests_total counter\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "kuberhealthy_http_requests_total{endpoint=\"%s\",method=\"%s\",code=\"%d\"} %d\n", k.endpoint, k.method, k.code, m.requests[k])
+	}
+
+	endpoints := make([]string, 0, len(m.endpoints))
+	for endpoint := range m.endpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	b.WriteString("# HELP kuberhealthy_http_request_duration_seconds Shows how long each endpoint of the kuberhealthy web server takes to serve requests\n")
+	b.WriteString("# TYPE kuberhealthy_http_request_duration_seconds histogram\n")
+	for _, endpoint := range endpoints {
+		m.endpoints[endpoint].duration.write(&b, "kuberhealthy_http_request_duration_seconds", "endpoint=\""+endpoint+"\"")
+	}
+	b.WriteString("# HELP kuberhealthy_http_request_size_bytes Shows the size of the request bodies sent to each endpoint of the kuberhealthy web server\n")
+	b.WriteString("# TYPE kuberhealthy_http_request_size_bytes histogram\n")
+	for _, endpoint := range endpoints {
+		m.endpoints[endpoint].requestSize.write(&b, "kuberhealthy_http_request_size_bytes", "endpoint=\""+endpoint+"\"")
+	}
+	b.WriteString("# HELP kuberhealthy_http_response_size_bytes Shows the size of the response bodies written by each endpoint of the kuberhealthy web server\n")
+	b.WriteString("# TYPE kuberhealthy_http_response_size_bytes histogram\n")
+	for _, endpoint := range endpoints {
+		m.endpoints[endpoint].responseSize.write(&b, "kuberhealthy_http_response_size_bytes", "endpoint=\""+endpoint+"\"")
+	}
+	return b.String()
+}
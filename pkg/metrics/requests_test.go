@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRequestMetrics ensures requests are counted per endpoint, method and status code and that their durations and
+// sizes land in cumulative histogram buckets
+func TestRequestMetrics(t *testing.T) {
+	m := NewRequestMetrics()
+	if m.PrometheusMetrics() != "" {
+		t.Fatal("expected no metrics before any request")
+	}
+
+	m.Observe("/externalCheckStatus", "POST", 200, time.Millisecond*20, 300, 0)
+	m.Observe("/externalCheckStatus", "POST", 200, time.Millisecond*200, 500, 0)
+	m.Observe("/externalCheckStatus", "POST", 400, time.Second*3, 50, 0)
+	m.Observe("/", "GET", 200, time.Millisecond*2, 0, 20000)
+
+	output := m.PrometheusMetrics()
+	for _, expected := range []string{
+		"# TYPE kuberhealthy_http_requests_total counter\n",
+		"kuberhealthy_http_requests_total{endpoint=\"/\",method=\"GET\",code=\"200\"} 1\n",
+		"kuberhealthy_http_requests_total{endpoint=\"/externalCheckStatus\",method=\"POST\",code=\"200\"} 2\n",
+		"kuberhealthy_http_requests_total{endpoint=\"/externalCheckStatus\",method=\"POST\",code=\"400\"} 1\n",
+		"# TYPE kuberhealthy_http_request_duration_seconds histogram\n",
+		"kuberhealthy_http_request_duration_seconds_bucket{endpoint=\"/externalCheckStatus\",le=\"0.025\"} 1\n",
+		"kuberhealthy_http_request_duration_seconds_bucket{endpoint=\"/externalCheckStatus\",le=\"0.25\"} 2\n",
+		"kuberhealthy_http_request_duration_seconds_bucket{endpoint=\"/externalCheckStatus\",le=\"2.5\"} 2\n",
+		"kuberhealthy_http_request_duration_seconds_bucket{endpoint=\"/externalCheckStatus\",le=\"5\"} 3\n",
+		"kuberhealthy_http_request_duration_seconds_bucket{endpoint=\"/externalCheckStatus\",le=\"+Inf\"} 3\n",
+		"kuberhealthy_http_request_duration_seconds_sum{endpoint=\"/externalCheckStatus\"} 3.22\n",
+		"kuberhealthy_http_request_duration_seconds_count{endpoint=\"/externalCheckStatus\"} 3\n",
+		"kuberhealthy_http_request_size_bytes_bucket{endpoint=\"/externalCheckStatus\",le=\"100\"} 1\n",
+		"kuberhealthy_http_request_size_bytes_bucket{endpoint=\"/externalCheckStatus\",le=\"1000\"} 3\n",
+		"kuberhealthy_http_response_size_bytes_bucket{endpoint=\"/\",le=\"10000\"} 0\n",
+		"kuberhealthy_http_response_size_bytes_bucket{endpoint=\"/\",le=\"100000\"} 1\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("metrics are missing %q:\n%s", expected, output)
+		}
+	}
+}
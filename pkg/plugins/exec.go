@@ -0,0 +1,194 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/metrics"
+)
+
+// ProtocolVersion is the version of the JSON-RPC protocol spoken with executable plugins.  It changes whenever a
+// change to the protocol would break existing plugins.
+const ProtocolVersion = 1
+
+// The capabilities an executable plugin reports in its handshake
+const (
+	CapabilityNotifier   = "notifier"
+	CapabilityStateStore = "statestore"
+	CapabilityMetricSink = "metricsink"
+)
+
+// The methods of the JSON-RPC protocol.  Requests and responses are JSON-RPC 1.0 objects, one per line.
+const (
+	MethodHandshake = "Plugin.Handshake" // sent once after the plugin starts, before any other method
+	MethodNotify    = "Plugin.Notify"    // params: Result
+	MethodStore     = "Plugin.Store"     // params: State
+	MethodPush      = "Plugin.Push"      // params: PushArgs
+)
+
+// defaultCallTimeout is how long an executable plugin may take to answer a call
+const defaultCallTimeout = time.Second * 10
+
+// defaultExitTimeout is how long an executable plugin may take to exit after its stdin is closed before it is killed
+const defaultExitTimeout = time.Second * 5
+
+// HandshakeArgs are the params of the handshake
+type HandshakeArgs struct {
+	ProtocolVersion int               `json:"protocolVersion"`
+	Settings        map[string]string `json:"settings"`
+}
+
+// HandshakeReply is the result of the handshake
+type HandshakeReply struct {
+	ProtocolVersion int      `json:"protocolVersion"`
+	Capabilities    []string `json:"capabilities"`
+}
+
+// PushArgs are the params of a metric push
+type PushArgs struct {
+	Points metrics.Metric    `json:"points"`
+	Tags   map[string]string `json:"tags"`
+}
+
+// Empty is the result of the methods that return nothing
+type Empty struct{}
+
+// RPCPlugin is a plugin that Kuberhealthy talks to over JSON-RPC.  It implements Notifier, StateStore and MetricSink,
+// but only hands results to the plugin for the capabilities the plugin reported in its handshake.
+type RPCPlugin struct {
+	client       *rpc.Client
+	capabilities map[string]bool
+	CallTimeout  time.Duration
+	cmd          *exec.Cmd // the plugin process, if Kuberhealthy started it
+}
+
+// StartExec starts an executable plugin and performs the handshake with it.  The stderr of the plugin is passed
+// through to the stderr of Kuberhealthy.
+func StartExec(path string, args []string, settings map[string]string) (*RPCPlugin, error) {
+	cmd := exec.Command(path, args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", path, err)
+	}
+
+	p, err := NewRPCPlugin(pipe{Reader: stdout, WriteCloser: stdin}, settings)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, err
+	}
+	p.cmd = cmd
+	return p, nil
+}
+
+// pipe joins the stdout and stdin of a plugin process into one connection
+type pipe struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// NewRPCPlugin performs the handshake with a plugin over the supplied connection
+func NewRPCPlugin(conn io.ReadWriteCloser, settings map[string]string) (*RPCPlugin, error) {
+	p := &RPCPlugin{
+		client:       jsonrpc.NewClient(conn),
+		capabilities: make(map[string]bool),
+		CallTimeout:  defaultCallTimeout,
+	}
+
+	var reply HandshakeReply
+	err := p.call(context.Background(), MethodHandshake, HandshakeArgs{ProtocolVersion: ProtocolVersion, Settings: settings}, &reply)
+	if err != nil {
+		_ = p.client.Close()
+		return nil, fmt.Errorf("handshake failed: %w", err)
+	}
+	if reply.ProtocolVersion != ProtocolVersion {
+		_ = p.client.Close()
+		return nil, fmt.Errorf("plugin speaks protocol version %d, but Kuberhealthy speaks version %d", reply.ProtocolVersion, ProtocolVersion)
+	}
+	for _, c := range reply.Capabilities {
+		p.capabilities[c] = true
+	}
+	return p, nil
+}
+
+// Capable tells if the plugin reported the supplied capability in its handshake
+func (p *RPCPlugin) Capable(capability string) bool {
+	return p.capabilities[capability]
+}
+
+// call calls a method of the plugin and waits for its answer until the call timeout or the context runs out
+func (p *RPCPlugin) call(ctx context.Context, method string, args interface{}, reply interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, p.CallTimeout)
+	defer cancel()
+
+	c := p.client.Go(method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-c.Done:
+		return c.Error
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", method, ctx.Err())
+	}
+}
+
+// Notify hands a completed run to the plugin if it is a notifier
+func (p *RPCPlugin) Notify(ctx context.Context, r Result) error {
+	if !p.Capable(CapabilityNotifier) {
+		return nil
+	}
+	return p.call(ctx, MethodNotify, r, &Empty{})
+}
+
+// Store hands a stored state to the plugin if it is a state store
+func (p *RPCPlugin) Store(ctx context.Context, s State) error {
+	if !p.Capable(CapabilityStateStore) {
+		return nil
+	}
+	return p.call(ctx, MethodStore, s, &Empty{})
+}
+
+// Push hands metrics to the plugin if it is a metric sink
+func (p *RPCPlugin) Push(points metrics.Metric, tags map[string]string) error {
+	if !p.Capable(CapabilityMetricSink) {
+		return nil
+	}
+	return p.call(context.Background(), MethodPush, PushArgs{Points: points, Tags: tags}, &Empty{})
+}
+
+// Close closes the connection to the plugin.  A plugin process started by Kuberhealthy is expected to exit when its
+// stdin closes and is killed if it does not.
+func (p *RPCPlugin) Close() error {
+	err := p.client.Close()
+	if p.cmd == nil {
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- p.cmd.Wait()
+	}()
+	select {
+	case <-exited:
+	case <-time.After(defaultExitTimeout):
+		log.Warningln("plugins: Killing plugin", p.cmd.Path, "because it did not exit after its stdin was closed")
+		_ = p.cmd.Process.Kill()
+		<-exited
+	}
+	return err
+}
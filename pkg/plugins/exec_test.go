@@ -0,0 +1,99 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/metrics"
+)
+
+// TestRPCPlugin ensures that results reach a plugin over JSON-RPC for the capabilities it reports
+func TestRPCPlugin(t *testing.T) {
+	client, server := net.Pipe()
+	plugin := &notifierOnly{}
+	served := make(chan error, 1)
+	go func() {
+		served <- ServeConn(server, func(settings map[string]string) (Plugin, error) {
+			return plugin, nil
+		})
+	}()
+
+	p, err := NewRPCPlugin(client, map[string]string{"token": "secret"})
+	if err != nil {
+		t.Fatalf("failed to handshake: %s", err)
+	}
+	if !p.Capable(CapabilityNotifier) || p.Capable(CapabilityStateStore) || p.Capable(CapabilityMetricSink) {
+		t.Fatalf("expected the plugin to only be a notifier, got %v", p.capabilities)
+	}
+
+	err = p.Notify(context.Background(), Result{Name: "dns", Namespace: "kuberhealthy"})
+	if err != nil || plugin.notified != 1 {
+		t.Fatalf("expected the plugin to be notified, got %v", err)
+	}
+	err = p.Push(metrics.Metric{{"dns.kuberhealthy": 1}}, nil)
+	if err != nil {
+		t.Fatalf("expected pushing to a plugin that is not a metric sink to be skipped, got %s", err)
+	}
+
+	_ = p.Close()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("expected the plugin to close cleanly, got %s", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("expected the plugin to stop serving when the connection closed")
+	}
+}
+
+// TestHandshakeFailure ensures that a plugin that fails to start reports why
+func TestHandshakeFailure(t *testing.T) {
+	client, server := net.Pipe()
+	go ServeConn(server, func(settings map[string]string) (Plugin, error) {
+		return nil, errFakePlugin
+	})
+
+	_, err := NewRPCPlugin(client, nil)
+	if err == nil || !strings.Contains(err.Error(), errFakePlugin.Error()) {
+		t.Fatalf("expected the handshake to fail with the error of the plugin, got %v", err)
+	}
+}
+
+// errFakePlugin is returned by plugins that fail to start in tests
+var errFakePlugin = errors.New("missing token setting")
+
+// TestStartExec ensures that an executable plugin is started, served and stopped.  The test binary serves as the
+// plugin when KH_PLUGIN_HELPER is set.
+func TestStartExec(t *testing.T) {
+	if os.Getenv("KH_PLUGIN_HELPER") == "1" {
+		_ = Serve(func(settings map[string]string) (Plugin, error) {
+			return &recorder{settings: settings}, nil
+		})
+		os.Exit(0)
+	}
+
+	os.Setenv("KH_PLUGIN_HELPER", "1")
+	defer os.Unsetenv("KH_PLUGIN_HELPER")
+	p, err := StartExec(os.Args[0], []string{"-test.run=^TestStartExec$"}, nil)
+	if err != nil {
+		t.Fatalf("failed to start plugin: %s", err)
+	}
+	for _, c := range []string{CapabilityNotifier, CapabilityStateStore, CapabilityMetricSink} {
+		if !p.Capable(c) {
+			t.Fatalf("expected the plugin to report the %s capability", c)
+		}
+	}
+	err = p.Store(context.Background(), State{Name: "dns", Namespace: "kuberhealthy"})
+	if err != nil {
+		t.Fatalf("failed to store state: %s", err)
+	}
+	err = p.Close()
+	if err != nil {
+		t.Fatalf("failed to close plugin: %s", err)
+	}
+}
@@ -0,0 +1,249 @@
+// Package plugins lets users add their own notifiers, state stores and metric sinks to Kuberhealthy without forking
+// it.  Plugins are either compiled into a custom build of Kuberhealthy and registered by name with Register, or are
+// separate executables that Kuberhealthy starts and talks to over JSON-RPC on their stdin and stdout.  Executable
+// plugins written in Go can use Serve to implement the protocol.
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/metrics"
+)
+
+// Plugin is implemented by every plugin.  A plugin also implements one or more of Notifier, StateStore and MetricSink
+// to receive the results of checks.
+type Plugin interface {
+	Close() error // called when Kuberhealthy shuts down or the plugin fails to load
+}
+
+// Notifier is implemented by plugins that are told about every completed check or job run, such as to page someone
+type Notifier interface {
+	Notify(ctx context.Context, r Result) error
+}
+
+// StateStore is implemented by plugins that keep a copy of the state of checks and jobs, such as in a database.
+// The khstate resources in the cluster remain the state Kuberhealthy works from.
+type StateStore interface {
+	Store(ctx context.Context, s State) error
+}
+
+// MetricSink is implemented by plugins that receive the metrics otherwise forwarded to InfluxDB
+type MetricSink interface {
+	metrics.Client
+}
+
+// Result is the outcome of a completed check or job run
+type Result struct {
+	Name         string            `json:"name"`
+	Namespace    string            `json:"namespace"`
+	Workload     string            `json:"workload"` // KHCheck or KHJob
+	OK           bool              `json:"ok"`
+	Errors       []string          `json:"errors"`
+	RunDuration  string            `json:"runDuration,omitempty"`
+	UUID         string            `json:"uuid,omitempty"`
+	Node         string            `json:"node,omitempty"`
+	Correlations map[string]string `json:"correlations,omitempty"` // the correlation IDs configured in Kuberhealthy, such as a deploy SHA
+	Labels       map[string]string `json:"labels,omitempty"`       // the labels of the khcheck or khjob configured to be propagated
+}
+
+// State is the state of a check or job as stored in its khstate
+type State struct {
+	Name      string                    `json:"name"`
+	Namespace string                    `json:"namespace"`
+	Details   khstatev1.WorkloadDetails `json:"details"`
+}
+
+// Factory creates a compiled in plugin from the settings in its configuration
+type Factory func(settings map[string]string) (Plugin, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a compiled in plugin available under the supplied name.  It is meant to be called from the init
+// function of the package of the plugin and panics if the name is taken.
+func Register(name string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, taken := registry[name]; taken {
+		panic("plugins: Register called twice for plugin " + name)
+	}
+	registry[name] = f
+}
+
+// Registered lists the names of the compiled in plugins
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Config configures a plugin.  Compiled in plugins are picked by name.  Executable plugins set exec to the path of
+// the executable, and their name is only used in logs.
+type Config struct {
+	Name     string            `yaml:"name"`
+	Exec     string            `yaml:"exec,omitempty"`
+	Args     []string          `yaml:"args,omitempty"`
+	Settings map[string]string `yaml:"settings,omitempty"`
+}
+
+// loaded is a plugin with the name it was configured with
+type loaded struct {
+	name   string
+	plugin Plugin
+}
+
+// Set holds the loaded plugins and hands results to each of them.  A nil Set does nothing, which lets callers hand
+// results to plugins without checking if any are configured.
+type Set struct {
+	plugins []loaded
+}
+
+// Load starts or creates each configured plugin.  Plugins that fail to load are left out of the returned set and
+// reported in the returned errors, so that one broken plugin does not stop the others.
+func Load(configs []Config) (*Set, []error) {
+	s := &Set{}
+	var errs []error
+	for _, c := range configs {
+		p, err := load(c)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load plugin %s: %w", c.Name, err))
+			continue
+		}
+		s.plugins = append(s.plugins, loaded{name: c.Name, plugin: p})
+	}
+	return s, errs
+}
+
+// load creates a single plugin from its configuration
+func load(c Config) (Plugin, error) {
+	if len(c.Name) == 0 {
+		return nil, fmt.Errorf("plugin has no name")
+	}
+	if len(c.Exec) > 0 {
+		return StartExec(c.Exec, c.Args, c.Settings)
+	}
+
+	registryMu.RLock()
+	f, ok := registry[c.Name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no plugin is registered as %s and no executable is set. Registered plugins: %v", c.Name, Registered())
+	}
+	return f(c.Settings)
+}
+
+// Add adds an already created plugin to the set
+func (s *Set) Add(name string, p Plugin) {
+	s.plugins = append(s.plugins, loaded{name: name, plugin: p})
+}
+
+// Len is the number of plugins in the set
+func (s *Set) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.plugins)
+}
+
+// Notify hands a completed run to each notifier plugin.  The errors of the plugins that failed are returned.
+func (s *Set) Notify(ctx context.Context, r Result) []error {
+	if s == nil {
+		return nil
+	}
+	var errs []error
+	for _, l := range s.plugins {
+		n, ok := l.plugin.(Notifier)
+		if !ok {
+			continue
+		}
+		err := n.Notify(ctx, r)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("plugin %s failed to notify: %w", l.name, err))
+		}
+	}
+	return errs
+}
+
+// Store hands a stored check or job state to each state store plugin.  The errors of the plugins that failed are
+// returned.
+func (s *Set) Store(ctx context.Context, state State) []error {
+	if s == nil {
+		return nil
+	}
+	var errs []error
+	for _, l := range s.plugins {
+		store, ok := l.plugin.(StateStore)
+		if !ok {
+			continue
+		}
+		err := store.Store(ctx, state)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("plugin %s failed to store state: %w", l.name, err))
+		}
+	}
+	return errs
+}
+
+// HasMetricSinks tells if any of the plugins are metric sinks
+func (s *Set) HasMetricSinks() bool {
+	if s == nil {
+		return false
+	}
+	for _, l := range s.plugins {
+		if _, ok := l.plugin.(MetricSink); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Push forwards metrics to each metric sink plugin, so that the set can be used as the metric forwarder of
+// Kuberhealthy.  Every sink is pushed to even when some fail.
+func (s *Set) Push(points metrics.Metric, tags map[string]string) error {
+	if s == nil {
+		return nil
+	}
+	var failed []string
+	var lastErr error
+	for _, l := range s.plugins {
+		sink, ok := l.plugin.(MetricSink)
+		if !ok {
+			continue
+		}
+		err := sink.Push(points, tags)
+		if err != nil {
+			failed = append(failed, l.name)
+			lastErr = err
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("plugins %v failed to push metrics: %w", failed, lastErr)
+	}
+	return nil
+}
+
+// Close closes every plugin in the set
+func (s *Set) Close() []error {
+	if s == nil {
+		return nil
+	}
+	var errs []error
+	for _, l := range s.plugins {
+		err := l.plugin.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to close plugin %s: %w", l.name, err))
+		}
+	}
+	return errs
+}
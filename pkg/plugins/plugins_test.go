@@ -0,0 +1,137 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/metrics"
+)
+
+// recorder is a plugin that records what it is handed
+type recorder struct {
+	sync.Mutex
+	settings map[string]string
+	results  []Result
+	states   []State
+	pushed   []map[string]string
+	fail     bool
+	closed   bool
+}
+
+func (r *recorder) Notify(ctx context.Context, res Result) error {
+	r.Lock()
+	defer r.Unlock()
+	if r.fail {
+		return errors.New("notify failed")
+	}
+	r.results = append(r.results, res)
+	return nil
+}
+
+func (r *recorder) Store(ctx context.Context, s State) error {
+	r.Lock()
+	defer r.Unlock()
+	r.states = append(r.states, s)
+	return nil
+}
+
+func (r *recorder) Push(points metrics.Metric, tags map[string]string) error {
+	r.Lock()
+	defer r.Unlock()
+	r.pushed = append(r.pushed, tags)
+	return nil
+}
+
+func (r *recorder) Close() error {
+	r.Lock()
+	defer r.Unlock()
+	r.closed = true
+	return nil
+}
+
+// notifierOnly is a plugin that is only a notifier
+type notifierOnly struct {
+	notified int
+}
+
+func (n *notifierOnly) Notify(ctx context.Context, r Result) error {
+	n.notified++
+	return nil
+}
+
+func (n *notifierOnly) Close() error {
+	return nil
+}
+
+// TestLoad ensures that compiled in plugins are created with their settings and broken plugins are left out
+func TestLoad(t *testing.T) {
+	var created *recorder
+	Register("test-recorder", func(settings map[string]string) (Plugin, error) {
+		created = &recorder{settings: settings}
+		return created, nil
+	})
+
+	set, errs := Load([]Config{
+		{Name: "test-recorder", Settings: map[string]string{"channel": "#alerts"}},
+		{Name: "missing"},
+		{Name: "broken", Exec: "/nonexistent/plugin"},
+	})
+	if set.Len() != 1 || created == nil || created.settings["channel"] != "#alerts" {
+		t.Fatalf("expected only the compiled in plugin to load with its settings, got %d plugins", set.Len())
+	}
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), "no plugin is registered as missing") || !strings.Contains(errs[1].Error(), "failed to start") {
+		t.Fatalf("expected errors for the missing and broken plugins, got %v", errs)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected registering a name twice to panic")
+		}
+	}()
+	Register("test-recorder", nil)
+}
+
+// TestSet ensures that results are only handed to the plugins that take them and failures don't stop other plugins
+func TestSet(t *testing.T) {
+	failing := &recorder{fail: true}
+	all := &recorder{}
+	notifier := &notifierOnly{}
+	set := &Set{}
+	set.Add("failing", failing)
+	set.Add("all", all)
+	set.Add("notifier", notifier)
+
+	errs := set.Notify(context.Background(), Result{Name: "dns", Namespace: "kuberhealthy", OK: true})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "plugin failing failed to notify") {
+		t.Fatalf("expected an error from the failing plugin only, got %v", errs)
+	}
+	if len(all.results) != 1 || notifier.notified != 1 {
+		t.Fatalf("expected the other notifiers to be notified, got %d and %d", len(all.results), notifier.notified)
+	}
+
+	errs = set.Store(context.Background(), State{Name: "dns", Namespace: "kuberhealthy", Details: khstatev1.NewWorkloadDetails(khstatev1.KHCheck)})
+	if len(errs) != 0 || len(all.states) != 1 || len(failing.states) != 1 {
+		t.Fatalf("expected each state store to store the state, got %v", errs)
+	}
+
+	if !set.HasMetricSinks() {
+		t.Fatalf("expected the set to have metric sinks")
+	}
+	err := set.Push(metrics.Metric{{"dns.kuberhealthy": 1}}, map[string]string{"Name": "dns"})
+	if err != nil || len(all.pushed) != 1 {
+		t.Fatalf("expected the metrics to be pushed, got %v", err)
+	}
+
+	if len(set.Close()) != 0 || !all.closed || !failing.closed {
+		t.Fatalf("expected every plugin to be closed")
+	}
+
+	var none *Set
+	if none.Notify(context.Background(), Result{}) != nil || none.Push(nil, nil) != nil || none.HasMetricSinks() {
+		t.Fatalf("expected a nil set to do nothing")
+	}
+}
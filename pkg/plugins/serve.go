@@ -0,0 +1,116 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"sync"
+)
+
+// Serve serves the JSON-RPC protocol of executable plugins over stdin and stdout until Kuberhealthy closes stdin.
+// The new function is called with the settings of the plugin from the handshake and returns the plugin to serve.
+// The plugin is closed before Serve returns.
+func Serve(new Factory) error {
+	return ServeConn(pipe{Reader: os.Stdin, WriteCloser: os.Stdout}, new)
+}
+
+// ServeConn serves the JSON-RPC protocol of executable plugins over the supplied connection until it closes
+func ServeConn(conn io.ReadWriteCloser, new Factory) error {
+	s := &server{new: new}
+	srv := rpc.NewServer()
+	err := srv.RegisterName("Plugin", s)
+	if err != nil {
+		return err
+	}
+	srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+
+	p := s.current()
+	if p == nil {
+		return nil
+	}
+	return p.Close()
+}
+
+// server answers the JSON-RPC calls of Kuberhealthy with a plugin
+type server struct {
+	mu     sync.Mutex // not embedded, so that net/rpc does not see Lock and Unlock as methods
+	new    Factory
+	plugin Plugin // created by the handshake
+}
+
+// current returns the plugin created by the handshake, if it has been made
+func (s *server) current() Plugin {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.plugin
+}
+
+// errNoHandshake is returned for calls made before the handshake
+var errNoHandshake = errors.New("the handshake must be the first call")
+
+// Handshake creates the plugin with the supplied settings and reports its capabilities
+func (s *server) Handshake(args HandshakeArgs, reply *HandshakeReply) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.plugin != nil {
+		return errors.New("the handshake was already made")
+	}
+	p, err := s.new(args.Settings)
+	if err != nil {
+		return err
+	}
+	s.plugin = p
+
+	reply.ProtocolVersion = ProtocolVersion
+	if _, ok := p.(Notifier); ok {
+		reply.Capabilities = append(reply.Capabilities, CapabilityNotifier)
+	}
+	if _, ok := p.(StateStore); ok {
+		reply.Capabilities = append(reply.Capabilities, CapabilityStateStore)
+	}
+	if _, ok := p.(MetricSink); ok {
+		reply.Capabilities = append(reply.Capabilities, CapabilityMetricSink)
+	}
+	return nil
+}
+
+// Notify hands a completed run to the plugin
+func (s *server) Notify(r Result, reply *Empty) error {
+	p := s.current()
+	n, ok := p.(Notifier)
+	if !ok {
+		return errNotCapable(p)
+	}
+	return n.Notify(context.Background(), r)
+}
+
+// Store hands a stored state to the plugin
+func (s *server) Store(state State, reply *Empty) error {
+	p := s.current()
+	store, ok := p.(StateStore)
+	if !ok {
+		return errNotCapable(p)
+	}
+	return store.Store(context.Background(), state)
+}
+
+// Push hands metrics to the plugin
+func (s *server) Push(args PushArgs, reply *Empty) error {
+	p := s.current()
+	sink, ok := p.(MetricSink)
+	if !ok {
+		return errNotCapable(p)
+	}
+	return sink.Push(args.Points, args.Tags)
+}
+
+// errNotCapable explains why a call the plugin does not support failed
+func errNotCapable(p Plugin) error {
+	if p == nil {
+		return errNoHandshake
+	}
+	return errors.New("the plugin does not support this method")
+}
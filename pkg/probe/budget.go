@@ -0,0 +1,68 @@
+package probe
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/duration"
+)
+
+// The environment variables that set the latency budget of every built-in network check
+const (
+	BudgetDNSEnv     = "LATENCY_BUDGET_DNS"
+	BudgetConnectEnv = "LATENCY_BUDGET_CONNECT"
+	BudgetTLSEnv     = "LATENCY_BUDGET_TLS"
+	BudgetTTFBEnv    = "LATENCY_BUDGET_TTFB"
+	BudgetTotalEnv   = "LATENCY_BUDGET_TOTAL"
+)
+
+// Budget is the most each phase of a probe may take.  Phases without a budget are zero and are never over it.
+type Budget struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	TTFB    time.Duration
+	Total   time.Duration
+}
+
+// BudgetFromEnv reads a latency budget from the LATENCY_BUDGET_* environment variables.  Each takes a duration such
+// as 250ms, and phases whose variable is not set have no budget.
+func BudgetFromEnv() (Budget, error) {
+	b := Budget{}
+	for env, phase := range map[string]*time.Duration{
+		BudgetDNSEnv:     &b.DNS,
+		BudgetConnectEnv: &b.Connect,
+		BudgetTLSEnv:     &b.TLS,
+		BudgetTTFBEnv:    &b.TTFB,
+		BudgetTotalEnv:   &b.Total,
+	} {
+		d, err := duration.ParseOrDefault(os.Getenv(env), 0)
+		if err != nil {
+			return Budget{}, fmt.Errorf("failed to parse %s: %w", env, err)
+		}
+		*phase = d
+	}
+	return b, nil
+}
+
+// Check returns an error for each phase of a timing that went over its budget
+func (b Budget) Check(t Timing) []string {
+	var errs []string
+	for _, p := range []struct {
+		name   string
+		took   time.Duration
+		budget time.Duration
+	}{
+		{"dns", t.DNS, b.DNS},
+		{"connect", t.Connect, b.Connect},
+		{"tls", t.TLS, b.TLS},
+		{"ttfb", t.TTFB, b.TTFB},
+		{"total", t.Total, b.Total},
+	} {
+		if p.budget > 0 && p.took > p.budget {
+			errs = append(errs, fmt.Sprintf("%s took %s, which is over its budget of %s", p.name, round(p.took), p.budget))
+		}
+	}
+	return errs
+}
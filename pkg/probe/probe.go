@@ -0,0 +1,169 @@
+// Package probe implements the network probes shared by the built-in checks.  Every probe measures how long each of
+// its phases took, so that checks can hold each phase to a latency budget and log their timings the same way.
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
+)
+
+// MaxBodySize is the most of a response body that HTTP reads
+const MaxBodySize = 10 << 20
+
+// Timing is how long each phase of a probe took.  Phases that a probe did not go through are zero.
+type Timing struct {
+	DNS     time.Duration `json:"dns"`     // resolving the host name
+	Connect time.Duration `json:"connect"` // establishing the connection
+	TLS     time.Duration `json:"tls"`     // the TLS handshake
+	TTFB    time.Duration `json:"ttfb"`    // from sending the request to the first byte of the response
+	Total   time.Duration `json:"total"`   // the whole probe, from start to finish
+}
+
+// String formats a timing the same way for every check, such as "dns=2ms connect=1ms tls=0s ttfb=14ms total=18ms"
+func (t Timing) String() string {
+	return fmt.Sprintf("dns=%s connect=%s tls=%s ttfb=%s total=%s", round(t.DNS), round(t.Connect), round(t.TLS), round(t.TTFB), round(t.Total))
+}
+
+// round rounds a phase to a precision that is readable in logs
+func round(d time.Duration) time.Duration {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond)
+	}
+	return d.Round(time.Millisecond)
+}
+
+// HTTP sends a request with the supplied client and reads up to MaxBodySize of the response body.  The body of the
+// returned response has already been read and closed.  Connections reused from the client's pool have no DNS,
+// connect or TLS time.
+func HTTP(client *http.Client, req *http.Request) (*http.Response, []byte, Timing, error) {
+	var t Timing
+	var dnsStart, connectStart, tlsStart, wroteRequest time.Time
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.DNS = time.Since(dnsStart) },
+		ConnectStart: func(string, string) {
+			if connectStart.IsZero() {
+				connectStart = time.Now()
+			}
+		},
+		ConnectDone:          func(string, string, error) { t.Connect = time.Since(connectStart) },
+		TLSHandshakeStart:    func() { tlsStart = time.Now() },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.TLS = time.Since(tlsStart) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { wroteRequest = time.Now() },
+		GotFirstResponseByte: func() { t.TTFB = time.Since(wroteRequest) },
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Total = time.Since(start)
+		return nil, nil, t, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxBodySize))
+	t.Total = time.Since(start)
+	if err != nil {
+		return resp, body, t, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return resp, body, t, nil
+}
+
+// ParseHeaders reads the extra headers of a request from newline separated "Name: value" lines, such as the
+// Authorization or X-Scope-OrgID headers a check is configured with.  Blank lines are skipped.
+func ParseHeaders(s string) (http.Header, error) {
+	headers := http.Header{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		name, value, found := strings.Cut(line, ":")
+		if !found || len(strings.TrimSpace(name)) == 0 {
+			return nil, fmt.Errorf("header %q is not in the form Name: value", line)
+		}
+		headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return headers, nil
+}
+
+// Lookup resolves a host name with the supplied resolver, or the default resolver when it is nil
+func Lookup(ctx context.Context, resolver *net.Resolver, host string) ([]string, Timing, error) {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	start := time.Now()
+	addrs, err := resolver.LookupHost(ctx, host)
+	t := Timing{DNS: time.Since(start)}
+	t.Total = t.DNS
+	return addrs, t, err
+}
+
+// Dial connects to an address, resolving its host first when it is a name.  The connection is secured with TLS when
+// a TLS config is supplied.  The server name of the TLS config defaults to the host of the address.
+func Dial(ctx context.Context, network string, address string, tlsConfig *tls.Config) (net.Conn, Timing, error) {
+	start := time.Now()
+	var t Timing
+	finish := func(conn net.Conn, err error) (net.Conn, Timing, error) {
+		t.Total = time.Since(start)
+		return conn, t, err
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return finish(nil, err)
+	}
+
+	// resolve names up front so that resolving is timed apart from connecting
+	addrs := []string{host}
+	if net.ParseIP(strings.Trim(host, "[]")) == nil {
+		var lookup Timing
+		addrs, lookup, err = Lookup(ctx, nil, host)
+		t.DNS = lookup.DNS
+		if err != nil {
+			return finish(nil, fmt.Errorf("failed to resolve %s: %w", host, err))
+		}
+	}
+
+	connectStart := time.Now()
+	var conn net.Conn
+	d := net.Dialer{}
+	for _, addr := range addrs {
+		conn, err = d.DialContext(ctx, network, net.JoinHostPort(addr, port))
+		if err == nil {
+			break
+		}
+	}
+	t.Connect = time.Since(connectStart)
+	if err != nil {
+		return finish(nil, err)
+	}
+	if conn == nil {
+		return finish(nil, errors.New("no addresses to connect to"))
+	}
+
+	if tlsConfig == nil {
+		return finish(conn, nil)
+	}
+	config := tlsConfig.Clone()
+	if len(config.ServerName) == 0 {
+		config.ServerName = host
+	}
+	tlsStart := time.Now()
+	tlsConn := tls.Client(conn, config)
+	err = tlsConn.HandshakeContext(ctx)
+	t.TLS = time.Since(tlsStart)
+	if err != nil {
+		conn.Close()
+		return finish(nil, fmt.Errorf("TLS handshake failed: %w", err))
+	}
+	return finish(tlsConn, nil)
+}
@@ -0,0 +1,124 @@
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHTTP ensures that HTTP returns the response body and times every phase of a new connection
+func TestHTTP(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond * 20)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	// request the server by name so that the host has to be resolved
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %s", err)
+	}
+	client := server.Client()
+	client.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify = true
+
+	resp, body, timing, err := HTTP(client, req)
+	if err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+		t.Fatalf("got status %d and body %q", resp.StatusCode, body)
+	}
+	if timing.DNS <= 0 || timing.Connect <= 0 || timing.TLS <= 0 || timing.TTFB < time.Millisecond*20 || timing.Total < timing.TTFB {
+		t.Fatalf("phases of a new connection were not all timed: %s", timing)
+	}
+}
+
+// TestParseHeaders ensures headers are read one per line and must be named
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders("Authorization: Bearer abc\n\nX-Scope-OrgID: kh\nX-Scope-OrgID: ops\n")
+	if err != nil || headers.Get("Authorization") != "Bearer abc" || !reflect.DeepEqual(headers.Values("X-Scope-OrgID"), []string{"kh", "ops"}) {
+		t.Fatalf("parsed headers %v with error %v", headers, err)
+	}
+	for _, s := range []string{"no separator", ": no name"} {
+		if _, err := ParseHeaders(s); err == nil {
+			t.Fatalf("header %q was accepted", s)
+		}
+	}
+}
+
+// TestDial ensures that Dial times resolving, connecting and the TLS handshake
+func TestDial(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	conn, timing, err := Dial(context.Background(), "tcp", net.JoinHostPort("localhost", port), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("dial failed: %s", err)
+	}
+	conn.Close()
+	if _, ok := conn.(*tls.Conn); !ok {
+		t.Fatalf("dial with a TLS config did not return a TLS connection")
+	}
+	if timing.DNS <= 0 || timing.Connect <= 0 || timing.TLS <= 0 || timing.Total < timing.DNS+timing.Connect+timing.TLS {
+		t.Fatalf("phases of the dial were not all timed: %s", timing)
+	}
+
+	conn, timing, err = Dial(context.Background(), "tcp", server.Listener.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %s", err)
+	}
+	conn.Close()
+	if timing.DNS != 0 || timing.TLS != 0 {
+		t.Fatalf("dial of an IP without TLS was timed as resolving or doing a handshake: %s", timing)
+	}
+}
+
+// TestBudgetCheck ensures only phases over their budget are reported
+func TestBudgetCheck(t *testing.T) {
+	timing := Timing{DNS: time.Millisecond * 5, Connect: time.Millisecond * 30, TTFB: time.Millisecond * 300, Total: time.Millisecond * 340}
+
+	var testCases = []struct {
+		description string
+		budget      Budget
+		expected    []string
+	}{
+		{description: "no budget"},
+		{description: "within budget", budget: Budget{DNS: time.Millisecond * 10, Total: time.Second}},
+		{
+			description: "over budget",
+			budget:      Budget{DNS: time.Millisecond * 10, Connect: time.Millisecond * 20, TTFB: time.Millisecond * 250},
+			expected:    []string{"connect took 30ms, which is over its budget of 20ms", "ttfb took 300ms, which is over its budget of 250ms"},
+		},
+	}
+
+	for _, tc := range testCases {
+		errs := tc.budget.Check(timing)
+		if !reflect.DeepEqual(errs, tc.expected) {
+			t.Fatalf("%s: got errors %v but expected %v", tc.description, errs, tc.expected)
+		}
+	}
+}
+
+// TestBudgetFromEnv ensures budgets are read from the environment
+func TestBudgetFromEnv(t *testing.T) {
+	t.Setenv(BudgetTTFBEnv, "250ms")
+	t.Setenv(BudgetTotalEnv, "2")
+	budget, err := BudgetFromEnv()
+	if err != nil || !reflect.DeepEqual(budget, Budget{TTFB: time.Millisecond * 250, Total: time.Second * 2}) {
+		t.Fatalf("budget was %+v with error %v", budget, err)
+	}
+
+	t.Setenv(BudgetDNSEnv, "soon")
+	if _, err = BudgetFromEnv(); err == nil {
+		t.Fatalf("invalid budget was accepted")
+	}
+}
@@ -0,0 +1,217 @@
+// Package rules evaluates CEL expressions over the current check results to compute derived health signals, such as
+// failing when more than a fifth of the nodes in a zone fail a per node check.  Each rule is reported on the status page
+// as a synthetic check that is OK while its expression evaluates to true.
+//
+// Expressions can use the following variables:
+//
+//   - checks: the list of khcheck results
+//   - jobs: the list of khjob results
+//
+// Each result is a map with the keys name, namespace, ok, errors, node, runDuration, metadata, nodes and zones.  The
+// nodes list holds the per node results of checks that run on all nodes as maps with the keys node, zone, ok and
+// errors.  The zones list holds the per zone results of checks that run per zone as maps with the keys zone, ok and
+// errors.
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/health"
+)
+
+// CheckPrefix is prepended to the name of a rule to form the name of the synthetic check it is reported as
+const CheckPrefix = "rule-"
+
+// Rule is a CEL expression that evaluates to true while the cluster is healthy
+type Rule struct {
+	Name       string `yaml:"name"`              // the name of the rule, used to name its synthetic check
+	Expression string `yaml:"expression"`        // the CEL expression evaluated over the check results
+	Message    string `yaml:"message,omitempty"` // the error reported when the expression evaluates to false
+}
+
+// Result is the outcome of evaluating a single rule
+type Result struct {
+	Name  string // the name of the rule
+	OK    bool   // true when the expression evaluated to true
+	Error string // why the rule is not OK, if it is not
+}
+
+// CheckName returns the name of the synthetic check a rule is reported as
+func (r Result) CheckName() string {
+	return CheckPrefix + r.Name
+}
+
+// compiledRule is a rule along with its compiled program
+type compiledRule struct {
+	Rule
+	program cel.Program
+}
+
+// Evaluator evaluates a set of compiled rules
+type Evaluator struct {
+	rules []compiledRule
+}
+
+// newEnv creates the CEL environment rule expressions are compiled in
+func newEnv() (*cel.Env, error) {
+	result := cel.MapType(cel.StringType, cel.DynType)
+	return cel.NewEnv(
+		cel.Variable("checks", cel.ListType(result)),
+		cel.Variable("jobs", cel.ListType(result)),
+	)
+}
+
+// Compile validates and compiles the supplied rules.  An error is returned when a rule is unnamed, shares its name
+// with another rule, or does not compile to a boolean expression.
+func Compile(rules []Rule) (*Evaluator, error) {
+	env, err := newEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+
+	e := &Evaluator{}
+	seen := make(map[string]bool)
+	for _, r := range rules {
+		if len(r.Name) == 0 {
+			return nil, fmt.Errorf("rule with expression %q has no name", r.Expression)
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("rule %s is defined more than once", r.Name)
+		}
+		seen[r.Name] = true
+
+		ast, issues := env.Compile(r.Expression)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("rule %s failed to compile: %w", r.Name, issues.Err())
+		}
+		if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+			return nil, fmt.Errorf("rule %s must evaluate to a bool, not %s", r.Name, ast.OutputType())
+		}
+
+		program, err := env.Program(ast)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s failed to build: %w", r.Name, err)
+		}
+		e.rules = append(e.rules, compiledRule{Rule: r, program: program})
+	}
+	return e, nil
+}
+
+// Len returns the number of rules in the evaluator
+func (e *Evaluator) Len() int {
+	if e == nil {
+		return 0
+	}
+	return len(e.rules)
+}
+
+// Evaluate evaluates every rule over the supplied state.  nodeZones maps node names to their topology zone so that
+// per node results can be grouped by zone, and may be nil.  Rules that fail to evaluate are not OK.
+func (e *Evaluator) Evaluate(state health.State, nodeZones map[string]string) []Result {
+	if e.Len() == 0 {
+		return nil
+	}
+
+	vars := map[string]interface{}{
+		"checks": workloadValues(state.CheckDetails, nodeZones),
+		"jobs":   workloadValues(state.JobDetails, nodeZones),
+	}
+
+	results := make([]Result, 0, len(e.rules))
+	for _, r := range e.rules {
+		results = append(results, r.evaluate(vars))
+	}
+	return results
+}
+
+// evaluate runs the program of the rule against the supplied variables
+func (r compiledRule) evaluate(vars map[string]interface{}) Result {
+	result := Result{Name: r.Name}
+
+	out, _, err := r.program.Eval(vars)
+	if err != nil {
+		result.Error = fmt.Sprintf("rule %s failed to evaluate: %s", r.Name, err)
+		return result
+	}
+	ok, isBool := out.Value().(bool)
+	if !isBool {
+		result.Error = fmt.Sprintf("rule %s evaluated to %v instead of a bool", r.Name, out.Value())
+		return result
+	}
+
+	result.OK = ok
+	if !ok {
+		result.Error = r.Message
+		if len(result.Error) == 0 {
+			result.Error = fmt.Sprintf("rule %s is failing: %s", r.Name, r.Expression)
+		}
+	}
+	return result
+}
+
+// workloadValues converts the supplied khstate details into CEL values, sorted by namespace and name so that
+// evaluation is deterministic
+func workloadValues(details map[string]khstatev1.WorkloadDetails, nodeZones map[string]string) []interface{} {
+	keys := make([]string, 0, len(details))
+	for key := range details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		d := details[key]
+		name := key
+		if i := strings.LastIndex(key, "/"); i >= 0 {
+			name = key[i+1:]
+		}
+
+		nodes := make([]interface{}, 0, len(d.NodeStatuses))
+		for _, n := range d.NodeStatuses {
+			nodes = append(nodes, map[string]interface{}{
+				"node":   n.Node,
+				"zone":   nodeZones[n.Node],
+				"ok":     n.OK,
+				"errors": stringsOrEmpty(n.Errors),
+			})
+		}
+		zones := make([]interface{}, 0, len(d.ZoneStatuses))
+		for _, z := range d.ZoneStatuses {
+			zones = append(zones, map[string]interface{}{
+				"zone":   z.Zone,
+				"ok":     z.OK,
+				"errors": stringsOrEmpty(z.Errors),
+			})
+		}
+		metadata := d.Metadata
+		if metadata == nil {
+			metadata = map[string]string{}
+		}
+
+		values = append(values, map[string]interface{}{
+			"name":        name,
+			"namespace":   d.Namespace,
+			"ok":          d.OK,
+			"errors":      stringsOrEmpty(d.Errors),
+			"node":        d.Node,
+			"runDuration": d.RunDuration,
+			"metadata":    metadata,
+			"nodes":       nodes,
+			"zones":       zones,
+		})
+	}
+	return values
+}
+
+// stringsOrEmpty returns an empty slice in place of a nil one
+func stringsOrEmpty(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
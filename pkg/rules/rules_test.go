@@ -0,0 +1,100 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/health"
+)
+
+// testState makes a state with a per node check across two zones and a regular check
+func testState() health.State {
+	state := health.NewState()
+	state.CheckDetails["kuberhealthy/daemonset"] = khstatev1.WorkloadDetails{
+		Namespace: "kuberhealthy",
+		NodeStatuses: []khstatev1.NodeStatus{
+			{Node: "a-1", OK: false, Errors: []string{"timed out"}},
+			{Node: "a-2", OK: true},
+			{Node: "a-3", OK: true},
+			{Node: "b-1", OK: true},
+		},
+	}
+	state.CheckDetails["kuberhealthy/dns"] = khstatev1.WorkloadDetails{Namespace: "kuberhealthy", OK: true}
+	state.JobDetails["kuberhealthy/once"] = khstatev1.WorkloadDetails{Namespace: "kuberhealthy", OK: false}
+	return state
+}
+
+// testZones maps the nodes of testState to zones
+var testZones = map[string]string{"a-1": "zone-a", "a-2": "zone-a", "a-3": "zone-a", "b-1": "zone-b"}
+
+// TestEvaluate ensures rules are evaluated over checks, jobs and per node results
+func TestEvaluate(t *testing.T) {
+	zoneA := `checks.filter(c, c.name == "daemonset").all(c, c.nodes.filter(n, n.zone == "zone-a" && !n.ok).size() * 5 <= c.nodes.filter(n, n.zone == "zone-a").size())`
+
+	tests := []struct {
+		name string
+		rule Rule
+		ok   bool
+		err  string
+	}{
+		{name: "passing", rule: Rule{Name: "dns", Expression: `checks.exists(c, c.name == "dns" && c.ok)`}, ok: true},
+		{name: "zone threshold exceeded", rule: Rule{Name: "zone-a", Expression: zoneA, Message: "more than 20% of nodes in zone-a are failing"}, err: "more than 20% of nodes in zone-a are failing"},
+		{name: "default message", rule: Rule{Name: "jobs", Expression: `jobs.all(j, j.ok)`}, err: "rule jobs is failing"},
+		{name: "evaluation error", rule: Rule{Name: "missing", Expression: `checks[10].ok`}, err: "failed to evaluate"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := Compile([]Rule{tc.rule})
+			if err != nil {
+				t.Fatal(err)
+			}
+			results := e.Evaluate(testState(), testZones)
+			if len(results) != 1 {
+				t.Fatalf("expected 1 result, got %d", len(results))
+			}
+			r := results[0]
+			if r.OK != tc.ok {
+				t.Fatalf("expected OK to be %t, got %t: %s", tc.ok, r.OK, r.Error)
+			}
+			if !strings.Contains(r.Error, tc.err) {
+				t.Fatalf("expected error to contain %q, got %q", tc.err, r.Error)
+			}
+			if r.CheckName() != CheckPrefix+tc.rule.Name {
+				t.Fatalf("unexpected check name %s", r.CheckName())
+			}
+		})
+	}
+}
+
+// TestCompileErrors ensures invalid rules are rejected
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+	}{
+		{name: "unnamed", rules: []Rule{{Expression: "true"}}},
+		{name: "duplicate", rules: []Rule{{Name: "a", Expression: "true"}, {Name: "a", Expression: "false"}}},
+		{name: "syntax", rules: []Rule{{Name: "a", Expression: "checks.all(c,"}}},
+		{name: "not a bool", rules: []Rule{{Name: "a", Expression: "checks.size()"}}},
+		{name: "unknown variable", rules: []Rule{{Name: "a", Expression: "pods.size() > 0"}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Compile(tc.rules)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+// TestEvaluateEmpty ensures an evaluator without rules returns no results
+func TestEvaluateEmpty(t *testing.T) {
+	var e *Evaluator
+	if results := e.Evaluate(testState(), nil); results != nil {
+		t.Fatalf("expected no results, got %v", results)
+	}
+}
@@ -0,0 +1,231 @@
+// Package supportbundle reads and writes Kuberhealthy support bundles.  A support bundle is a gzipped tarball holding
+// a snapshot of the state of Kuberhealthy on a cluster, such as its current status, the history of each check, its
+// configuration and recent logs, that can be attached to an issue and inspected offline.  Secrets are redacted before
+// anything is written to a bundle.
+package supportbundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestFile is the name of the file in every bundle that describes its contents
+const ManifestFile = "manifest.json"
+
+// Redacted replaces the values of secrets in a bundle
+const Redacted = "REDACTED"
+
+// Manifest describes the contents of a bundle
+type Manifest struct {
+	Created   time.Time `json:"created"`          // when the bundle was collected
+	Namespace string    `json:"namespace"`        // the namespace Kuberhealthy runs in
+	Files     []string  `json:"files"`            // the files in the bundle other than the manifest, sorted
+	Errors    []string  `json:"errors,omitempty"` // what could not be collected, if anything
+}
+
+// Bundle is a manifest along with the files it describes
+type Bundle struct {
+	Manifest Manifest
+	Files    map[string][]byte
+}
+
+// New creates an empty bundle
+func New(namespace string, created time.Time) *Bundle {
+	return &Bundle{
+		Manifest: Manifest{Created: created.UTC(), Namespace: namespace},
+		Files:    make(map[string][]byte),
+	}
+}
+
+// Add adds a file to the bundle, replacing any file with the same name
+func (b *Bundle) Add(name string, content []byte) {
+	b.Files[name] = content
+}
+
+// AddJSON adds the supplied value to the bundle as an indented JSON file
+func (b *Bundle) AddJSON(name string, v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+	b.Add(name, content)
+	return nil
+}
+
+// AddError records something that could not be collected
+func (b *Bundle) AddError(err error) {
+	b.Manifest.Errors = append(b.Manifest.Errors, err.Error())
+}
+
+// Write writes the bundle to w as a gzipped tarball.  The manifest is written first so that it can be found without
+// reading the whole bundle.
+func (b *Bundle) Write(w io.Writer) error {
+	names := make([]string, 0, len(b.Files))
+	for name := range b.Files {
+		if name == ManifestFile {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	b.Manifest.Files = names
+
+	manifest, err := json.MarshalIndent(b.Manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	err = writeFile(tw, ManifestFile, manifest, b.Manifest.Created)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		err = writeFile(tw, name, b.Files[name], b.Manifest.Created)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close tarball: %w", err)
+	}
+	return gz.Close()
+}
+
+// writeFile writes a single file to a tarball
+func writeFile(tw *tar.Writer, name string, content []byte, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(content)),
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write header of %s: %w", name, err)
+	}
+	_, err = tw.Write(content)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// Read reads a bundle written by Write
+func Read(r io.Reader) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("bundle is not gzipped: %w", err)
+	}
+	defer gz.Close()
+
+	b := &Bundle{Files: make(map[string][]byte)}
+	var foundManifest bool
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// refuse paths that could escape a directory the bundle is extracted into
+		name := path.Clean(header.Name)
+		if path.IsAbs(name) || strings.HasPrefix(name, "../") || name == ".." {
+			return nil, fmt.Errorf("bundle contains invalid path %s", header.Name)
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if name == ManifestFile {
+			err = json.Unmarshal(content, &b.Manifest)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse manifest: %w", err)
+			}
+			foundManifest = true
+			continue
+		}
+		b.Files[name] = content
+	}
+
+	if !foundManifest {
+		return nil, fmt.Errorf("bundle has no %s", ManifestFile)
+	}
+	return b, nil
+}
+
+// sensitiveName matches the names of settings, environment variables and fields that hold secrets
+var sensitiveName = regexp.MustCompile(`(?i)(password|passwd|secret|token|apikey|api_key|credential|private_?key|authorization)`)
+
+// sensitiveText matches secrets in free text such as logs, like password=hunter2, token: abc or Bearer abc
+var sensitiveText = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)((?:password|passwd|secret|token|apikey|api_key|credential|private_?key)["']?\s*[:=]\s*["']?)[^\s"',}]+`),
+	regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*`),
+	regexp.MustCompile(`(://[^:/@\s]+:)[^@\s]+(@)`),
+}
+
+// IsSensitive returns true when the supplied name looks like it holds a secret
+func IsSensitive(name string) bool {
+	return sensitiveName.MatchString(name)
+}
+
+// RedactText replaces secrets found in free text, such as log lines
+func RedactText(b []byte) []byte {
+	for _, re := range sensitiveText {
+		if re.NumSubexp() == 2 {
+			b = re.ReplaceAll(b, []byte("${1}"+Redacted+"${2}"))
+			continue
+		}
+		b = re.ReplaceAll(b, []byte("${1}"+Redacted))
+	}
+	return b
+}
+
+// RedactObject replaces secrets in a decoded JSON or YAML object in place.  String values of keys that look
+// sensitive are redacted, as are the values of name/value pairs such as container environment variables whose name
+// looks sensitive.  Other strings are redacted as free text.
+func RedactObject(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if name, ok := t["name"].(string); ok && IsSensitive(name) {
+			if _, ok := t["value"].(string); ok {
+				t["value"] = Redacted
+			}
+		}
+		for k, child := range t {
+			if _, ok := child.(string); ok && IsSensitive(k) {
+				t[k] = Redacted
+				continue
+			}
+			t[k] = RedactObject(child)
+		}
+		return t
+	case []interface{}:
+		for i, child := range t {
+			t[i] = RedactObject(child)
+		}
+		return t
+	case string:
+		return string(RedactText([]byte(t)))
+	default:
+		return v
+	}
+}
@@ -0,0 +1,198 @@
+// Package testutil holds helpers for the unit tests of Kuberhealthy
+package testutil
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/clock"
+)
+
+// FakeClock is a clock.Clock that only moves when it is stepped.  Timers and tickers fire as the clock passes their
+// deadlines, which lets tests cross interval boundaries and deadlines without waiting for them.
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter is a timer or ticker of a fake clock.  Tickers have a period.
+type fakeWaiter struct {
+	clock  *FakeClock
+	when   time.Time
+	period time.Duration
+	c      chan time.Time
+}
+
+// NewFakeClock creates a fake clock set to the supplied time
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the time of the fake clock
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Since returns the time passed on the fake clock since t
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Until returns the time left on the fake clock until t
+func (c *FakeClock) Until(t time.Time) time.Duration {
+	return t.Sub(c.Now())
+}
+
+// After returns a channel that receives the time once the fake clock has moved by d
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// Sleep blocks until the fake clock has moved by d
+func (c *FakeClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+// NewTimer creates a timer that fires once the fake clock has moved by d
+func (c *FakeClock) NewTimer(d time.Duration) clock.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{clock: c, c: make(chan time.Time, 1)}
+	c.add(w, d)
+	return w
+}
+
+// NewTicker creates a ticker that fires each time the fake clock moves by d
+func (c *FakeClock) NewTicker(d time.Duration) clock.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{clock: c, period: d, c: make(chan time.Time, 1)}
+	c.add(w, d)
+	return fakeTicker{w}
+}
+
+// Step moves the fake clock forward by d and fires the timers and tickers whose deadlines were passed, in order
+func (c *FakeClock) Step(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setTime(c.now.Add(d))
+}
+
+// SetTime moves the fake clock to t and fires the timers and tickers whose deadlines were passed, in order
+func (c *FakeClock) SetTime(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setTime(t)
+}
+
+// Waiters returns the number of timers and tickers waiting for the fake clock to move
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntil blocks until at least n timers and tickers are waiting for the fake clock to move.  Tests use it to know
+// that the code under test has started waiting before stepping the clock.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
+
+// setTime moves the clock to t and fires the waiters that are due.  The lock must be held.
+func (c *FakeClock) setTime(t time.Time) {
+	for {
+		sort.SliceStable(c.waiters, func(i, j int) bool {
+			return c.waiters[i].when.Before(c.waiters[j].when)
+		})
+		if len(c.waiters) == 0 || c.waiters[0].when.After(t) {
+			break
+		}
+
+		// fire the earliest waiter at its own deadline so that tickers see each of their ticks in order
+		w := c.waiters[0]
+		c.now = w.when
+		select {
+		case w.c <- w.when:
+		default:
+			// like time.Ticker, ticks are dropped when the receiver falls behind
+		}
+		if w.period > 0 {
+			w.when = w.when.Add(w.period)
+			continue
+		}
+		c.remove(w)
+	}
+	c.now = t
+}
+
+// add schedules a waiter to fire after d.  The lock must be held.
+func (c *FakeClock) add(w *fakeWaiter, d time.Duration) {
+	w.when = c.now.Add(d)
+	c.waiters = append(c.waiters, w)
+	c.cond.Broadcast()
+}
+
+// remove stops a waiter and reports if it was waiting.  The lock must be held.
+func (c *FakeClock) remove(w *fakeWaiter) bool {
+	for i, existing := range c.waiters {
+		if existing == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			c.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+// C returns the channel the waiter fires on
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.c
+}
+
+// Stop stops the waiter from firing and reports if it was waiting
+func (w *fakeWaiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+	return w.clock.remove(w)
+}
+
+// Reset schedules the waiter to fire after d and reports if it was waiting
+func (w *fakeWaiter) Reset(d time.Duration) bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+	active := w.clock.remove(w)
+	if w.period > 0 {
+		w.period = d
+	}
+	w.clock.add(w, d)
+	return active
+}
+
+// fakeTicker is the clock.Ticker of a fake clock
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+// Stop stops the ticker
+func (t fakeTicker) Stop() {
+	t.fakeWaiter.Stop()
+}
+
+// Reset stops the ticker and resets its period to d
+func (t fakeTicker) Reset(d time.Duration) {
+	t.fakeWaiter.Reset(d)
+}
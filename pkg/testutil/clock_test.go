@@ -0,0 +1,92 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+)
+
+// TestFakeClockTimer ensures timers only fire once the clock passes their deadline and can be stopped and reset
+func TestFakeClockTimer(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+	timer := c.NewTimer(time.Minute)
+
+	c.Step(time.Second * 59)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired before its deadline")
+	default:
+	}
+
+	c.Step(time.Second)
+	select {
+	case fired := <-timer.C():
+		if !fired.Equal(start.Add(time.Minute)) {
+			t.Fatalf("timer fired at %s", fired)
+		}
+	default:
+		t.Fatal("timer did not fire at its deadline")
+	}
+	if c.Waiters() != 0 {
+		t.Fatalf("expected fired timers to stop waiting, %d are waiting", c.Waiters())
+	}
+
+	if timer.Reset(time.Minute) {
+		t.Fatal("expected resetting a fired timer to report it was not waiting")
+	}
+	if !timer.Stop() {
+		t.Fatal("expected stopping a reset timer to report it was waiting")
+	}
+	c.Step(time.Hour)
+	select {
+	case <-timer.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+}
+
+// TestFakeClockTicker ensures tickers fire on each interval boundary the clock crosses
+func TestFakeClockTicker(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+	ticker := c.NewTicker(time.Second * 10)
+	defer ticker.Stop()
+
+	for i := 1; i <= 3; i++ {
+		c.Step(time.Second * 10)
+		tick := <-ticker.C()
+		if !tick.Equal(start.Add(time.Duration(i) * time.Second * 10)) {
+			t.Fatalf("tick %d was at %s", i, tick)
+		}
+	}
+
+	// ticks are dropped when the receiver falls behind, like time.Ticker
+	c.Step(time.Minute)
+	<-ticker.C()
+	select {
+	case <-ticker.C():
+		t.Fatal("expected missed ticks to be dropped")
+	default:
+	}
+	if !c.Now().Equal(start.Add(time.Second * 90)) {
+		t.Fatalf("clock is at %s", c.Now())
+	}
+}
+
+// TestFakeClockBlockUntil ensures tests can wait for code to start waiting on the clock before stepping it
+func TestFakeClockBlockUntil(t *testing.T) {
+	c := NewFakeClock(time.Now())
+	slept := make(chan struct{})
+	go func() {
+		c.Sleep(time.Hour)
+		close(slept)
+	}()
+
+	c.BlockUntil(1)
+	c.Step(time.Hour)
+	select {
+	case <-slept:
+	case <-time.After(time.Second * 5):
+		t.Fatal("sleep did not end when the clock was stepped")
+	}
+}
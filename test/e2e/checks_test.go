@@ -0,0 +1,143 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	khstatev1 "github.com/kuberhealthy/kuberhealthy/v2/pkg/apis/khstate/v1"
+)
+
+// apply creates the objects in a testdata file and deletes them when the test ends
+func apply(t *testing.T, file string) []*unstructured.Unstructured {
+	t.Helper()
+	ctx := context.Background()
+	objects, err := framework.ApplyFile(ctx, "testdata/"+file)
+	t.Cleanup(func() {
+		for _, o := range objects {
+			err := framework.Delete(ctx, o)
+			if err != nil {
+				t.Logf("failed to delete %s %s: %s", o.GetKind(), o.GetName(), err)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return objects
+}
+
+// requirePods skips tests that need checker pods to run when the cluster does not run pods
+func requirePods(t *testing.T) {
+	if !framework.Cluster.RunsPods() {
+		t.Skipf("%s clusters do not run checker pods", framework.Cluster.Provider())
+	}
+}
+
+// TestScheduling ensures khchecks get checker pods and are run again on their interval
+func TestScheduling(t *testing.T) {
+	ctx := context.Background()
+	apply(t, "success.yaml")
+
+	_, err := framework.WaitForCheckerPods(ctx, "e2e-success", 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requirePods(t)
+	_, err = framework.WaitForState(ctx, "e2e-success", time.Minute*3, func(s khstatev1.WorkloadDetails) bool {
+		return len(s.History) >= 2
+	})
+	if err != nil {
+		t.Fatal("expected the check to run again after its run interval:", err)
+	}
+}
+
+// TestReporting ensures that the success and failure reports of checker pods end up in their khstate
+func TestReporting(t *testing.T) {
+	requirePods(t)
+	ctx := context.Background()
+	apply(t, "success.yaml")
+	apply(t, "failure.yaml")
+
+	state, err := framework.WaitForState(ctx, "e2e-success", time.Minute*2, func(s khstatev1.WorkloadDetails) bool {
+		return s.LastRun != nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.OK || len(state.Errors) != 0 {
+		t.Fatalf("expected e2e-success to report success, got %+v", state)
+	}
+
+	state, err = framework.WaitForState(ctx, "e2e-failure", time.Minute*2, func(s khstatev1.WorkloadDetails) bool {
+		return s.LastRun != nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.OK || len(state.Errors) == 0 {
+		t.Fatalf("expected e2e-failure to report a failure, got %+v", state)
+	}
+}
+
+// TestTimeout ensures checks that do not report in before their timeout fail.  Checker pods never start on clusters
+// that do not run pods, so there the run times out waiting for the pod to start instead.
+func TestTimeout(t *testing.T) {
+	ctx := context.Background()
+	apply(t, "timeout.yaml")
+
+	state, err := framework.WaitForState(ctx, "e2e-timeout", time.Minute*3, func(s khstatev1.WorkloadDetails) bool {
+		return !s.OK && len(s.Errors) > 0
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("e2e-timeout failed with:", state.Errors)
+}
+
+// TestCleanup ensures that removing a khcheck removes its khstate and checker pods
+func TestCleanup(t *testing.T) {
+	ctx := context.Background()
+	objects := apply(t, "cleanup.yaml")
+
+	// checks on clusters that do not run pods only get a khstate once their run times out
+	_, err := framework.WaitForState(ctx, "e2e-cleanup", time.Minute*4, func(s khstatev1.WorkloadDetails) bool {
+		return s.LastRun != nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, o := range objects {
+		err = framework.Delete(ctx, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = framework.WaitForStateDeleted(ctx, "e2e-cleanup", time.Minute*3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// checker pods are garbage collected through their owner reference, which needs a controller manager
+	requirePods(t)
+	deadline := time.Now().Add(time.Minute * 2)
+	for {
+		pods, err := framework.CheckerPods(ctx, "e2e-cleanup")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pods) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d checker pods of e2e-cleanup were left behind", len(pods))
+		}
+		time.Sleep(time.Second * 2)
+	}
+}
@@ -0,0 +1,76 @@
+//go:build e2e
+
+// Package e2e holds the end to end tests of Kuberhealthy.  They provision a cluster, install Kuberhealthy and apply the
+// khchecks in testdata.  Run them with make e2e-kind or make e2e-envtest from cmd/kuberhealthy, or with:
+//
+//	go test -tags e2e -timeout 30m ./test/e2e/
+//
+// The following environment variables configure the tests:
+//
+//   - E2E_PROVIDER: kind (the default) or envtest
+//   - E2E_BINARY: a built kuberhealthy binary.  Built from the tree when not set.
+//   - E2E_IMAGE: the locally built kuberhealthy image loaded into kind clusters
+//   - E2E_KEEP_CLUSTER: set to true to leave the cluster running after the tests for debugging
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/kuberhealthy/kuberhealthy/v2/pkg/e2e"
+)
+
+// framework is the cluster the tests run against
+var framework *e2e.Framework
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+// runTests sets up the cluster, runs the tests and tears the cluster down
+func runTests(m *testing.M) int {
+	ctx := context.Background()
+
+	dir, err := os.MkdirTemp("", "kuberhealthy-e2e-")
+	if err != nil {
+		fmt.Println("failed to create e2e directory:", err)
+		return 1
+	}
+	fmt.Println("e2e: cluster state and logs are kept in", dir)
+
+	provider := e2e.Provider(os.Getenv("E2E_PROVIDER"))
+	if len(provider) == 0 {
+		provider = e2e.ProviderKind
+	}
+
+	binary := os.Getenv("E2E_BINARY")
+	if len(binary) == 0 {
+		binary = filepath.Join(dir, "kuberhealthy")
+		out, err := exec.Command("go", "build", "-o", binary, "../../cmd/kuberhealthy").CombinedOutput()
+		if err != nil {
+			fmt.Println("failed to build kuberhealthy:", err, string(out))
+			return 1
+		}
+	}
+
+	framework, err = e2e.Setup(ctx, e2e.Options{
+		Provider: provider,
+		Name:     "kuberhealthy-e2e",
+		Dir:      dir,
+		Binary:   binary,
+		Image:    os.Getenv("E2E_IMAGE"),
+	})
+	if os.Getenv("E2E_KEEP_CLUSTER") != "true" {
+		defer framework.Teardown(ctx)
+	}
+	if err != nil {
+		fmt.Println("failed to set up e2e cluster:", err)
+		return 1
+	}
+
+	return m.Run()
+}